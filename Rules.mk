@@ -60,6 +60,12 @@ include $(dir)/Rules.mk
 dir := pin/internal/pb
 include $(dir)/Rules.mk
 
+dir := pubsub/pb
+include $(dir)/Rules.mk
+
+dir := core/coreapi/rpc/pb
+include $(dir)/Rules.mk
+
 
 # -------------------- #
 #   universal rules    #
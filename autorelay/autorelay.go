@@ -0,0 +1,361 @@
+// Package autorelay keeps the node dialable while it isn't publicly
+// reachable, such as behind a NAT. It finds peers willing to relay
+// connections (hop relays), among the connected peers and through a
+// rendezvous in the content routing, stays connected to a few of them and
+// announces addresses through them.
+package autorelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	ggio "github.com/gogo/protobuf/io"
+	cid "github.com/ipfs/go-cid"
+	u "github.com/ipfs/go-ipfs-util"
+	logging "github.com/ipfs/go-log"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	pb "github.com/libp2p/go-libp2p-circuit/pb"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	routing "github.com/libp2p/go-libp2p-routing"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("autorelay")
+
+const (
+	// connMgrTag tags the relays in the connection manager, so that their
+	// connections are kept.
+	connMgrTag      = "ipfs-autorelay"
+	connMgrTagValue = 1 << 10
+
+	connectTimeout = 30 * time.Second
+
+	// maxMessageSize bounds the messages read from relays.
+	maxMessageSize = 4096
+)
+
+var (
+	// DesiredRelays is the number of relays the node stays connected to.
+	DesiredRelays = 3
+
+	// checkInterval is how often the reachability of the node and its relays
+	// are checked. The first check waits as long, for the addresses observed
+	// by other peers to be known.
+	checkInterval = time.Minute
+
+	// advertiseInterval is how often hop relays announce themselves in the
+	// rendezvous.
+	advertiseInterval = 8 * time.Hour
+
+	// maxCandidates bounds the relays looked up in the rendezvous at once.
+	maxCandidates = 20
+)
+
+// Rendezvous is the CID hop relays announce themselves as providers of.
+var Rendezvous = cid.NewCidV1(cid.Raw, u.Hash([]byte("/libp2p/relay")))
+
+// AutoRelay maintains relays for the node while it isn't publicly
+// reachable.
+type AutoRelay struct {
+	public func([]ma.Multiaddr) bool
+
+	lk      sync.Mutex
+	host    host.Host
+	router  routing.ContentRouting
+	cancel  context.CancelFunc
+	private bool
+	relays  map[peer.ID]struct{}
+
+	// wake triggers a check, when a relay disconnects
+	wake chan struct{}
+}
+
+// New returns an AutoRelay, which considers the node publicly reachable when
+// public returns true for its addresses. Its Addrs method must be part of
+// the addresses factory of the host, and Start called once the host exists.
+func New(public func([]ma.Multiaddr) bool) *AutoRelay {
+	return &AutoRelay{
+		public: public,
+		relays: make(map[peer.ID]struct{}),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Start maintains relays for h, finding them among its connected peers and,
+// when router isn't nil, in the rendezvous.
+func (r *AutoRelay) Start(h host.Host, router routing.ContentRouting) {
+	r.lk.Lock()
+	if r.cancel != nil {
+		r.lk.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.host = h
+	r.router = router
+	r.cancel = cancel
+	r.lk.Unlock()
+
+	// outside of r.lk, which the notifications take
+	h.Network().Notify((*notifee)(r))
+	go r.loop(ctx)
+}
+
+// Stop stops maintaining relays, and forgets them.
+func (r *AutoRelay) Stop() {
+	r.lk.Lock()
+	if r.cancel == nil {
+		r.lk.Unlock()
+		return
+	}
+	r.cancel()
+	r.cancel = nil
+	h := r.host
+	for p := range r.relays {
+		h.ConnManager().UntagPeer(p, connMgrTag)
+	}
+	r.relays = make(map[peer.ID]struct{})
+	r.private = false
+	r.lk.Unlock()
+
+	h.Network().StopNotify((*notifee)(r))
+}
+
+// Relays returns the relays of the node.
+func (r *AutoRelay) Relays() []peer.ID {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	out := make([]peer.ID, 0, len(r.relays))
+	for p := range r.relays {
+		out = append(out, p)
+	}
+	return out
+}
+
+// Addrs is an addresses factory, adding the addresses of the node through its
+// relays to addrs while it isn't publicly reachable.
+func (r *AutoRelay) Addrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if !r.private || len(r.relays) == 0 {
+		return addrs
+	}
+
+	out := append([]ma.Multiaddr(nil), addrs...)
+	for p := range r.relays {
+		circ, err := ma.NewMultiaddr("/ipfs/" + p.Pretty() + "/p2p-circuit")
+		if err != nil {
+			continue
+		}
+		for _, a := range r.host.Peerstore().Addrs(p) {
+			if isRelayed(a) || !r.public([]ma.Multiaddr{a}) {
+				continue
+			}
+			out = append(out, a.Encapsulate(circ))
+		}
+	}
+	return out
+}
+
+func (r *AutoRelay) loop(ctx context.Context) {
+	t := time.NewTicker(checkInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-r.wake:
+		case <-ctx.Done():
+			return
+		}
+		r.update(ctx)
+	}
+}
+
+// update drops the relays no longer needed or connected, and finds new ones
+// when the node lacks some.
+func (r *AutoRelay) update(ctx context.Context) {
+	// outside of r.lk, the addresses of the host come through Addrs
+	private := !r.public(r.host.Addrs())
+
+	r.lk.Lock()
+	if private != r.private {
+		log.Infof("node reachability changed, private: %t", private)
+	}
+	r.private = private
+	for p := range r.relays {
+		if !private || r.host.Network().Connectedness(p) != inet.Connected {
+			r.dropRelay(p)
+		}
+	}
+	need := DesiredRelays - len(r.relays)
+	r.lk.Unlock()
+
+	if !private || need <= 0 {
+		return
+	}
+	for _, pi := range r.candidates(ctx) {
+		if err := r.addRelay(ctx, pi); err != nil {
+			log.Debugf("relay candidate %s: %s", pi.ID, err)
+			continue
+		}
+		log.Infof("using relay %s", pi.ID)
+		if need--; need == 0 {
+			return
+		}
+	}
+}
+
+// dropRelay forgets relay p. r.lk must be held.
+func (r *AutoRelay) dropRelay(p peer.ID) {
+	delete(r.relays, p)
+	r.host.ConnManager().UntagPeer(p, connMgrTag)
+}
+
+// candidates returns the connected peers which may relay, then the relays of
+// the rendezvous.
+func (r *AutoRelay) candidates(ctx context.Context) []pstore.PeerInfo {
+	r.lk.Lock()
+	known := make(map[peer.ID]struct{}, len(r.relays)+1)
+	for p := range r.relays {
+		known[p] = struct{}{}
+	}
+	r.lk.Unlock()
+	known[r.host.ID()] = struct{}{}
+
+	var out []pstore.PeerInfo
+	for _, p := range r.host.Network().Peers() {
+		if _, ok := known[p]; ok {
+			continue
+		}
+		protos, err := r.host.Peerstore().SupportsProtocols(p, string(circuit.ProtoID))
+		if err != nil || len(protos) == 0 {
+			continue
+		}
+		known[p] = struct{}{}
+		out = append(out, pstore.PeerInfo{ID: p})
+	}
+
+	if r.router == nil {
+		return out
+	}
+	fctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	for pi := range r.router.FindProvidersAsync(fctx, Rendezvous, maxCandidates) {
+		if _, ok := known[pi.ID]; ok {
+			continue
+		}
+		known[pi.ID] = struct{}{}
+		out = append(out, pi)
+	}
+	return out
+}
+
+// addRelay connects to pi and makes it a relay, if it relays connections.
+func (r *AutoRelay) addRelay(ctx context.Context, pi pstore.PeerInfo) error {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	if err := r.host.Connect(ctx, pi); err != nil {
+		return err
+	}
+	ok, err := canHop(ctx, r.host, pi.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("doesn't relay connections")
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.cancel == nil {
+		return errors.New("stopped")
+	}
+	r.relays[pi.ID] = struct{}{}
+	r.host.ConnManager().TagPeer(pi.ID, connMgrTag, connMgrTagValue)
+	return nil
+}
+
+// canHop asks p whether it relays connections.
+func canHop(ctx context.Context, h host.Host, p peer.ID) (bool, error) {
+	s, err := h.NewStream(ctx, p, circuit.ProtoID)
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	msg := pb.CircuitRelay{Type: pb.CircuitRelay_CAN_HOP.Enum()}
+	if err := ggio.NewDelimitedWriter(s).WriteMsg(&msg); err != nil {
+		s.Reset()
+		return false, err
+	}
+	msg.Reset()
+	if err := ggio.NewDelimitedReader(s, maxMessageSize).ReadMsg(&msg); err != nil {
+		s.Reset()
+		return false, err
+	}
+	if msg.GetType() != pb.CircuitRelay_STATUS {
+		return false, fmt.Errorf("unexpected relay response of type %s", msg.GetType())
+	}
+	return msg.GetCode() == pb.CircuitRelay_SUCCESS, nil
+}
+
+// Advertise announces the node as a relay in the rendezvous, until ctx is
+// done. Nodes relaying connections (with Swarm.EnableRelayHop) call it for
+// other nodes to find them.
+func Advertise(ctx context.Context, router routing.ContentRouting) {
+	for {
+		if err := router.Provide(ctx, Rendezvous, true); err != nil {
+			log.Warningf("announcing the relay in the rendezvous: %s", err)
+		}
+		select {
+		case <-time.After(advertiseInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func isRelayed(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(circuit.P_CIRCUIT)
+	return err == nil
+}
+
+func (r *AutoRelay) poke() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notifee triggers a check when a relay disconnects.
+type notifee AutoRelay
+
+func (nn *notifee) Disconnected(n inet.Network, c inet.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) == inet.Connected {
+		return
+	}
+	r := (*AutoRelay)(nn)
+	r.lk.Lock()
+	_, ok := r.relays[p]
+	r.lk.Unlock()
+	if ok {
+		r.poke()
+	}
+}
+
+func (nn *notifee) Listen(inet.Network, ma.Multiaddr)      {}
+func (nn *notifee) ListenClose(inet.Network, ma.Multiaddr) {}
+func (nn *notifee) Connected(inet.Network, inet.Conn)      {}
+func (nn *notifee) OpenedStream(inet.Network, inet.Stream) {}
+func (nn *notifee) ClosedStream(inet.Network, inet.Stream) {}
@@ -0,0 +1,69 @@
+package autorelay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestAddrs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public := func(addrs []ma.Multiaddr) bool {
+		for _, a := range addrs {
+			if strings.HasPrefix(a.String(), "/ip4/1.") {
+				return true
+			}
+		}
+		return false
+	}
+	parse := func(s string) ma.Multiaddr {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	h.Peerstore().AddAddrs(relay.ID(), []ma.Multiaddr{
+		parse("/ip4/1.2.3.4/tcp/4001"),
+		parse("/ip4/10.0.0.1/tcp/4001"),
+	}, pstore.PermanentAddrTTL)
+
+	r := New(public)
+	r.host = h
+	r.relays[relay.ID()] = struct{}{}
+	own := []ma.Multiaddr{parse("/ip4/10.0.0.2/tcp/4001")}
+
+	if addrs := r.Addrs(own); len(addrs) != 1 {
+		t.Fatalf("expected no relay addresses while reachable, got %s", addrs)
+	}
+
+	r.private = true
+	addrs := r.Addrs(own)
+	if len(addrs) != 2 {
+		t.Fatalf("expected one relay address, got %s", addrs)
+	}
+	expected := "/ip4/1.2.3.4/tcp/4001/ipfs/" + relay.ID().Pretty() + "/p2p-circuit"
+	if addrs[1].String() != expected {
+		t.Fatalf("expected %s, got %s", expected, addrs[1])
+	}
+	if !isRelayed(addrs[1]) || isRelayed(addrs[0]) {
+		t.Fatal("expected only the relay address to be relayed")
+	}
+}
@@ -0,0 +1,34 @@
+package blockstoreutil
+
+import (
+	"errors"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bs "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// ErrReadOnly is returned when writing to a read-only blockstore.
+var ErrReadOnly = errors.New("blockstore is read-only")
+
+// NewReadOnly returns a blockstore serving the blocks of b which returns
+// ErrReadOnly for every attempt to store or delete a block.
+func NewReadOnly(b bs.Blockstore) bs.Blockstore {
+	return &readOnly{b}
+}
+
+type readOnly struct {
+	bs.Blockstore
+}
+
+func (r *readOnly) Put(blocks.Block) error {
+	return ErrReadOnly
+}
+
+func (r *readOnly) PutMany([]blocks.Block) error {
+	return ErrReadOnly
+}
+
+func (r *readOnly) DeleteBlock(*cid.Cid) error {
+	return ErrReadOnly
+}
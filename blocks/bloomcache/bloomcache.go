@@ -0,0 +1,244 @@
+// Package bloomcache provides a blockstore answering Has for absent blocks
+// from a bloom filter which is saved in the datastore on shutdown, so that it
+// doesn't have to be rebuilt from the whole blockstore on every start.
+package bloomcache
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("bloomcache")
+
+var (
+	// filterKey is where the filter is saved.
+	filterKey = ds.NewKey("/local/bloom/filter")
+
+	// cleanKey marks the saved filter as matching the blockstore. It is
+	// removed as soon as the blockstore may change without the filter
+	// seeing it.
+	cleanKey = ds.NewKey("/local/bloom/clean")
+)
+
+// hashCount is the number of bits set per key.
+const hashCount = 7
+
+// Blockstore is a blockstore with a persisted bloom filter in front of Has.
+type Blockstore struct {
+	bstore.Blockstore
+	store ds.Datastore
+
+	lk   sync.RWMutex
+	bits []byte
+
+	// ready is set once the filter covers all blocks, before that Has
+	// always asks the blockstore.
+	ready int32
+
+	cancel  context.CancelFunc
+	rebuilt chan struct{}
+}
+
+// New puts a bloom filter of size bytes in front of bs. The filter saved in
+// store is used if it is of the same size and was saved on a clean
+// shutdown, otherwise the filter is rebuilt in the background. Close must be
+// called to save the filter again.
+func New(ctx context.Context, bs bstore.Blockstore, store ds.Datastore, size int) (*Blockstore, error) {
+	b := &Blockstore{
+		Blockstore: bs,
+		store:      store,
+		rebuilt:    make(chan struct{}),
+	}
+
+	clean, err := store.Has(cleanKey)
+	if err != nil {
+		return nil, err
+	}
+	if clean {
+		val, err := store.Get(filterKey)
+		if err != nil && err != ds.ErrNotFound {
+			return nil, err
+		}
+		if saved, ok := val.([]byte); ok && len(saved) == size {
+			b.bits = saved
+		}
+	}
+	// from here on the saved filter is stale until we save it again
+	if err := store.Delete(cleanKey); err != nil && err != ds.ErrNotFound {
+		return nil, err
+	}
+
+	if b.bits != nil {
+		log.Debug("loaded saved bloom filter")
+		atomic.StoreInt32(&b.ready, 1)
+		close(b.rebuilt)
+		return b, nil
+	}
+
+	b.bits = make([]byte, size)
+	ctx, b.cancel = context.WithCancel(ctx)
+	go b.rebuild(ctx)
+	return b, nil
+}
+
+func (b *Blockstore) rebuild(ctx context.Context) {
+	defer close(b.rebuilt)
+
+	keys, err := b.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		log.Errorf("rebuilding bloom filter: %s", err)
+		return
+	}
+	var n int
+	for c := range keys {
+		b.add(c)
+		n++
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	atomic.StoreInt32(&b.ready, 1)
+	log.Debugf("rebuilt bloom filter from %d blocks", n)
+}
+
+func (b *Blockstore) positions(c *cid.Cid) [hashCount]uint64 {
+	h := fnv.New64a()
+	h.Write(c.Bytes())
+	h1 := h.Sum64()
+	h.Write([]byte{0})
+	h2 := h.Sum64()
+
+	m := uint64(len(b.bits)) * 8
+	var pos [hashCount]uint64
+	for i := range pos {
+		pos[i] = (h1 + uint64(i)*h2) % m
+	}
+	return pos
+}
+
+func (b *Blockstore) add(c *cid.Cid) {
+	pos := b.positions(c)
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	for _, p := range pos {
+		b.bits[p/8] |= 1 << (p % 8)
+	}
+}
+
+func (b *Blockstore) mayHave(c *cid.Cid) bool {
+	pos := b.positions(c)
+
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+	for _, p := range pos {
+		if b.bits[p/8]&(1<<(p%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether the filter is in use, i.e. it was loaded or the
+// rebuild has finished.
+func (b *Blockstore) Ready() bool {
+	return atomic.LoadInt32(&b.ready) == 1
+}
+
+// Has implements Blockstore.
+func (b *Blockstore) Has(c *cid.Cid) (bool, error) {
+	if b.Ready() && !b.mayHave(c) {
+		return false, nil
+	}
+	return b.Blockstore.Has(c)
+}
+
+// Get implements Blockstore.
+func (b *Blockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	if b.Ready() && !b.mayHave(c) {
+		return nil, bstore.ErrNotFound
+	}
+	return b.Blockstore.Get(c)
+}
+
+// Put implements Blockstore.
+func (b *Blockstore) Put(blk blocks.Block) error {
+	// add first, a block must never be stored without being in the filter
+	b.add(blk.Cid())
+	return b.Blockstore.Put(blk)
+}
+
+// PutMany implements Blockstore.
+func (b *Blockstore) PutMany(blks []blocks.Block) error {
+	for _, blk := range blks {
+		b.add(blk.Cid())
+	}
+	return b.Blockstore.PutMany(blks)
+}
+
+// Close stops a running rebuild and saves the filter if it is complete.
+func (b *Blockstore) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	<-b.rebuilt
+	if !b.Ready() {
+		return nil
+	}
+
+	b.lk.RLock()
+	saved := append([]byte(nil), b.bits...)
+	b.lk.RUnlock()
+
+	if err := b.store.Put(filterKey, saved); err != nil {
+		return err
+	}
+	return b.store.Put(cleanKey, []byte{})
+}
+
+// Invalidating wraps bs so that the first write to it marks the filter saved
+// in store as stale. Nodes not using the filter must wrap their blockstore
+// with it, or the filter would miss their blocks.
+func Invalidating(bs bstore.Blockstore, store ds.Datastore) bstore.Blockstore {
+	return &invalidating{Blockstore: bs, store: store}
+}
+
+type invalidating struct {
+	bstore.Blockstore
+	store ds.Datastore
+	once  sync.Once
+	err   error
+}
+
+func (i *invalidating) invalidate() error {
+	i.once.Do(func() {
+		i.err = i.store.Delete(cleanKey)
+		if i.err == ds.ErrNotFound {
+			i.err = nil
+		}
+	})
+	return i.err
+}
+
+func (i *invalidating) Put(blk blocks.Block) error {
+	if err := i.invalidate(); err != nil {
+		return err
+	}
+	return i.Blockstore.Put(blk)
+}
+
+func (i *invalidating) PutMany(blks []blocks.Block) error {
+	if err := i.invalidate(); err != nil {
+		return err
+	}
+	return i.Blockstore.PutMany(blks)
+}
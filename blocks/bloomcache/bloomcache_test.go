@@ -0,0 +1,146 @@
+package bloomcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+const testSize = 1024
+
+func makeBlock(i int) blocks.Block {
+	return blocks.NewBlock([]byte(fmt.Sprintf("block %d", i)))
+}
+
+func waitReady(t *testing.T, b *Blockstore) {
+	select {
+	case <-b.rebuilt:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the rebuild")
+	}
+	if !b.Ready() {
+		t.Fatal("filter should be ready after the rebuild")
+	}
+}
+
+// countingBlockstore counts the Has calls reaching the blockstore.
+type countingBlockstore struct {
+	bstore.Blockstore
+	has int
+}
+
+func (c *countingBlockstore) Has(k *cid.Cid) (bool, error) {
+	c.has++
+	return c.Blockstore.Has(k)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	base := &countingBlockstore{Blockstore: bstore.NewBlockstore(dstore)}
+	if err := base.Put(makeBlock(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(ctx, base, dstore, testSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, b)
+	if err := b.Put(makeBlock(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = New(ctx, base, dstore, testSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Ready() {
+		t.Fatal("saved filter should be used right away")
+	}
+	for i := 0; i < 2; i++ {
+		has, err := b.Has(makeBlock(i).Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !has {
+			t.Fatalf("block %d should be found", i)
+		}
+	}
+
+	base.has = 0
+	for i := 100; i < 110; i++ {
+		if _, err := b.Has(makeBlock(i).Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.has > 2 {
+		t.Fatalf("filter should answer for most absent blocks, %d lookups hit the blockstore", base.has)
+	}
+}
+
+func TestStaleFilterIsRebuilt(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	base := bstore.NewBlockstore(dstore)
+
+	b, err := New(ctx, base, dstore, testSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, b)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// written without the filter
+	if err := Invalidating(base, dstore).Put(makeBlock(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = New(ctx, base, dstore, testSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, b)
+	has, err := b.Has(makeBlock(0).Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("block written without the filter should be found after a rebuild")
+	}
+}
+
+func TestUncleanShutdown(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	base := bstore.NewBlockstore(dstore)
+
+	b, err := New(ctx, base, dstore, testSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, b)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// opened but never closed
+	if _, err := New(ctx, base, dstore, testSize); err != nil {
+		t.Fatal(err)
+	}
+	if clean, err := dstore.Has(cleanKey); err != nil || clean {
+		t.Fatalf("saved filter should be stale after an unclean shutdown (clean=%t, err=%v)", clean, err)
+	}
+}
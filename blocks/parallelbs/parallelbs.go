@@ -0,0 +1,218 @@
+// Package parallelbs enumerates the blocks of a blockstore with several
+// concurrent datastore queries, one per key prefix, instead of a single query
+// over all keys. On large repos this speeds up everything walking the whole
+// blockstore, such as garbage collection and reproviding.
+package parallelbs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("parallelbs")
+
+// DefaultWorkers is the default number of concurrent queries.
+const DefaultWorkers = 8
+
+// alphabet is the alphabet of the base32 encoded block keys.
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+// HotPrefixes are the encoded prefixes shared by most block keys: CIDv0, and
+// CIDv1 sha2-256 dag-pb, raw and dag-cbor blocks. Shards splits them further
+// so that their blocks are spread over several shards.
+var HotPrefixes = []string{"CIQ", "AFYBEI", "AFKREI", "AFYREI"}
+
+// Shards returns the key prefixes enumerated separately. They are disjoint
+// and together cover all base32 keys. Prefixes of the hot ones are split
+// until each hot prefix is split once more.
+func Shards(hot []string) []string {
+	var shards []string
+	var split func(p string)
+	split = func(p string) {
+		for _, h := range hot {
+			if len(h) >= len(p) && h[:len(p)] == p {
+				for _, c := range alphabet {
+					split(p + string(c))
+				}
+				return
+			}
+		}
+		shards = append(shards, p)
+	}
+	split("")
+	return shards
+}
+
+var defaultShards = Shards(HotPrefixes)
+
+// AllKeysChan lists the blocks stored by bstore.NewBlockstore(d), running up
+// to workers queries at once. The keys come in no particular order. It fails
+// if d doesn't support prefix queries, e.g. flatfs.
+//
+// If a query fails while listing, the enumeration stops and the error is sent
+// on the error channel once the keys channel is closed; the keys listed are
+// then incomplete. The error channel is closed without a value otherwise.
+func AllKeysChan(ctx context.Context, d ds.Datastore, workers int) (<-chan *cid.Cid, <-chan error, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// the first query tells whether d supports prefixes at all
+	first, err := query(d, defaultShards[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	shards := make(chan string)
+	go func() {
+		defer close(shards)
+		for _, p := range defaultShards[1:] {
+			select {
+			case shards <- p:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var errOnce sync.Once
+	var qerr error
+	fail := func(err error) {
+		errOnce.Do(func() { qerr = err })
+		cancel()
+	}
+
+	out := make(chan *cid.Cid, dsq.KeysOnlyBufSize)
+	errc := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(res dsq.Results) {
+			defer wg.Done()
+			for {
+				if res != nil {
+					if err := send(ctx, res, out); err != nil {
+						fail(err)
+						return
+					}
+				}
+				var p string
+				var ok bool
+				select {
+				case p, ok = <-shards:
+				case <-ctx.Done():
+					return
+				}
+				if !ok {
+					return
+				}
+				var err error
+				if res, err = query(d, p); err != nil {
+					fail(fmt.Errorf("listing blocks under %s: %s", p, err))
+					return
+				}
+			}
+		}(first)
+		first = nil
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+		if qerr != nil {
+			errc <- qerr
+		}
+		cancel()
+		close(errc)
+		close(out)
+	}()
+	return out, errc, nil
+}
+
+func query(d ds.Datastore, prefix string) (dsq.Results, error) {
+	return d.Query(dsq.Query{
+		Prefix:   bstore.BlockPrefix.String() + "/" + prefix,
+		KeysOnly: true,
+	})
+}
+
+// send decodes the keys of res into out until res is exhausted. It returns
+// the error of res, or nil if ctx was cancelled.
+func send(ctx context.Context, res dsq.Results, out chan<- *cid.Cid) error {
+	defer res.Close()
+	for {
+		select {
+		case e, ok := <-res.Next():
+			if !ok {
+				return nil
+			}
+			if e.Error != nil {
+				return fmt.Errorf("listing blocks: %s", e.Error)
+			}
+
+			c, err := dshelp.DsKeyToCid(ds.RawKey(ds.RawKey(e.Key).BaseNamespace()))
+			if err != nil {
+				log.Warningf("error parsing key from DsKey: %s", err)
+				continue
+			}
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Wrap returns bs with AllKeysChan replaced by a parallel enumeration of d,
+// which must be the datastore bs was created from. Where d doesn't support
+// prefix queries, the keys are listed by bs.
+func Wrap(bs bstore.Blockstore, d ds.Datastore, workers int) bstore.Blockstore {
+	if workers <= 1 {
+		return bs
+	}
+	return &blockstore{Blockstore: bs, d: d, workers: workers}
+}
+
+type blockstore struct {
+	bstore.Blockstore
+	d       ds.Datastore
+	workers int
+}
+
+// AllKeysChan lists the keys in parallel. As with the blockstore it wraps,
+// the channel is closed early if listing fails, and the error is logged.
+func (bs *blockstore) AllKeysChan(ctx context.Context) (<-chan *cid.Cid, error) {
+	keys, errc, err := AllKeysChan(ctx, bs.d, bs.workers)
+	if err != nil {
+		log.Debugf("listing blocks with a single query: %s", err)
+		return bs.Blockstore.AllKeysChan(ctx)
+	}
+
+	out := make(chan *cid.Cid, dsq.KeysOnlyBufSize)
+	go func() {
+		defer close(out)
+		for c := range keys {
+			select {
+			case out <- c:
+			case <-ctx.Done():
+			}
+		}
+		if err := <-errc; err != nil {
+			log.Errorf("blockstore.AllKeysChan got err: %s", err)
+		}
+	}()
+	return out, nil
+}
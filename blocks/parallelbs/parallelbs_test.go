@@ -0,0 +1,209 @@
+package parallelbs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	levelds "github.com/ipfs/go-ds-leveldb"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func putBlocks(t testing.TB, bs bstore.Blockstore, n int) *cid.Set {
+	set := cid.NewSet()
+	for i := 0; i < n; i++ {
+		b := blocks.NewBlock([]byte(fmt.Sprintf("block %d", i)))
+		if err := bs.Put(b); err != nil {
+			t.Fatal(err)
+		}
+		set.Add(b.Cid())
+	}
+	return set
+}
+
+func TestShards(t *testing.T) {
+	shards := Shards(HotPrefixes)
+	for i, a := range shards {
+		for j, b := range shards {
+			if i != j && strings.HasPrefix(b, a) {
+				t.Fatalf("shards %s and %s overlap", a, b)
+			}
+		}
+	}
+	for _, h := range HotPrefixes {
+		var n int
+		for _, s := range shards {
+			if strings.HasPrefix(s, h) {
+				n++
+			}
+		}
+		if n != len(alphabet) {
+			t.Errorf("hot prefix %s is split into %d shards", h, n)
+		}
+	}
+}
+
+func TestAllKeysChan(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(d)
+	expected := putBlocks(t, bs, 500)
+
+	// some CIDv1 blocks as well
+	for i := 0; i < 10; i++ {
+		b := blocks.NewBlock([]byte(fmt.Sprintf("raw %d", i)))
+		c := cid.NewCidV1(cid.Raw, b.Cid().Hash())
+		rb, err := blocks.NewBlockWithCid(b.RawData(), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bs.Put(rb); err != nil {
+			t.Fatal(err)
+		}
+		expected.Add(c)
+	}
+
+	keys, err := Wrap(bs, d, 4).AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := cid.NewSet()
+	for c := range keys {
+		if !expected.Has(c) {
+			t.Fatalf("unexpected key %s", c)
+		}
+		if !seen.Visit(c) {
+			t.Fatalf("key %s listed twice", c)
+		}
+	}
+	if seen.Len() != expected.Len() {
+		t.Fatalf("expected %d keys, got %d", expected.Len(), seen.Len())
+	}
+}
+
+func TestCancel(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	putBlocks(t, bstore.NewBlockstore(d), 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	keys, _, err := AllKeysChan(ctx, d, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-keys
+	cancel()
+
+	var n int
+	for range keys {
+		n++
+	}
+	if n >= 999 {
+		t.Fatal("enumeration went on after cancellation")
+	}
+}
+
+// noPrefixDatastore refuses prefix queries like flatfs does.
+type noPrefixDatastore struct {
+	ds.Datastore
+}
+
+func (d *noPrefixDatastore) Query(q dsq.Query) (dsq.Results, error) {
+	if strings.HasPrefix(q.Prefix, bstore.BlockPrefix.String()+"/") {
+		return nil, errors.New("prefix queries are not supported")
+	}
+	return d.Datastore.Query(q)
+}
+
+// failingDatastore fails the queries of a shard.
+type failingDatastore struct {
+	ds.Datastore
+	shard string
+}
+
+func (d *failingDatastore) Query(q dsq.Query) (dsq.Results, error) {
+	if q.Prefix == bstore.BlockPrefix.String()+"/"+d.shard {
+		return nil, errors.New("query failed")
+	}
+	return d.Datastore.Query(q)
+}
+
+func TestQueryError(t *testing.T) {
+	d := &failingDatastore{dssync.MutexWrap(ds.NewMapDatastore()), defaultShards[len(defaultShards)/2]}
+	putBlocks(t, bstore.NewBlockstore(d), 100)
+
+	keys, errc, err := AllKeysChan(context.Background(), d, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range keys {
+	}
+	err = <-errc
+	if err == nil || !strings.Contains(err.Error(), "query failed") {
+		t.Fatalf("expected the query error, got %v", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	d := &noPrefixDatastore{dssync.MutexWrap(ds.NewMapDatastore())}
+	bs := bstore.NewBlockstore(d)
+	expected := putBlocks(t, bs, 100)
+
+	keys, err := Wrap(bs, d, 4).AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for range keys {
+		n++
+	}
+	if n != expected.Len() {
+		t.Fatalf("expected %d keys, got %d", expected.Len(), n)
+	}
+}
+
+func benchmarkAllKeysChan(b *testing.B, workers int) {
+	dir, err := ioutil.TempDir("", "parallelbs-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d, err := levelds.NewDatastore(dir, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer d.Close()
+
+	bs := bstore.NewBlockstore(d)
+	const count = 100000
+	putBlocks(b, bs, count)
+	bs = Wrap(bs, d, workers)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		keys, err := bs.AllKeysChan(context.Background())
+		if err != nil {
+			b.Fatal(err)
+		}
+		var n int
+		for range keys {
+			n++
+		}
+		if n != count {
+			b.Fatalf("expected %d keys, got %d", count, n)
+		}
+	}
+}
+
+func BenchmarkAllKeysChanSingle(b *testing.B) { benchmarkAllKeysChan(b, 1) }
+func BenchmarkAllKeysChan4(b *testing.B)      { benchmarkAllKeysChan(b, 4) }
+func BenchmarkAllKeysChan8(b *testing.B)      { benchmarkAllKeysChan(b, 8) }
+func BenchmarkAllKeysChan16(b *testing.B)     { benchmarkAllKeysChan(b, 16) }
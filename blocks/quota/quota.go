@@ -0,0 +1,462 @@
+// Package quota enforces storage quotas on a blockstore, accounting blocks
+// added locally separately from blocks cached from the network.
+package quota
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("quota")
+
+// ErrQuotaExceeded is returned when storing blocks would exceed a quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// networkIndexKey is where the blocks cached from the network are recorded,
+// along with their sizes. All other blocks count as added locally.
+var networkIndexKey = ds.NewKey("/local/quota/network")
+
+// Class tells how a block came to be stored.
+type Class int
+
+const (
+	// Local blocks were added by the user.
+	Local Class = iota
+	// Network blocks were fetched from other peers.
+	Network
+
+	numClasses
+)
+
+func (c Class) String() string {
+	switch c {
+	case Local:
+		return "local"
+	case Network:
+		return "network"
+	default:
+		return "unknown"
+	}
+}
+
+// Limit is a quota. Zero fields mean no limit.
+type Limit struct {
+	MaxBytes  uint64
+	MaxBlocks uint64
+}
+
+// Usage is the storage used by a class of blocks.
+type Usage struct {
+	Bytes  uint64
+	Blocks uint64
+}
+
+// percentOf returns how much of l u uses, in percent of the fuller of the
+// two limits.
+func (u Usage) percentOf(l Limit) int {
+	p := 0
+	if l.MaxBytes > 0 {
+		p = int(u.Bytes * 100 / l.MaxBytes)
+	}
+	if l.MaxBlocks > 0 {
+		if bp := int(u.Blocks * 100 / l.MaxBlocks); bp > p {
+			p = bp
+		}
+	}
+	return p
+}
+
+func (u Usage) fits(l Limit) bool {
+	return (l.MaxBytes == 0 || u.Bytes <= l.MaxBytes) &&
+		(l.MaxBlocks == 0 || u.Blocks <= l.MaxBlocks)
+}
+
+// Event is emitted when the usage of a class crosses a threshold upwards.
+type Event struct {
+	Class     Class
+	Usage     Usage
+	Limit     Limit
+	Threshold int // in percent of the limit
+}
+
+// Options configures a Quota.
+type Options struct {
+	Local   Limit
+	Network Limit
+
+	// Thresholds are the percentages of the limits at which events are
+	// emitted.
+	Thresholds []int
+}
+
+// Quota tracks the storage used by a blockstore and enforces limits on it.
+// Blocks are stored through the views returned by Blockstore.
+type Quota struct {
+	bs    bstore.GCBlockstore
+	index ds.Datastore
+
+	limits     [numClasses]Limit
+	thresholds []int
+
+	lk      sync.Mutex
+	usage   [numClasses]Usage
+	crossed [numClasses]int
+	network map[string]uint64 // KeyString -> size
+	subs    []func(Event)
+}
+
+// New creates a quota over bs, recording which blocks came from the network
+// in index. It scans bs to account for the blocks already stored.
+func New(ctx context.Context, bs bstore.GCBlockstore, index ds.Datastore, opts Options) (*Quota, error) {
+	q := &Quota{
+		bs:         bs,
+		index:      index,
+		thresholds: append([]int(nil), opts.Thresholds...),
+		network:    make(map[string]uint64),
+	}
+	q.limits[Local] = opts.Local
+	q.limits[Network] = opts.Network
+	sort.Ints(q.thresholds)
+
+	if err := q.load(ctx); err != nil {
+		return nil, err
+	}
+	for c := Class(0); c < numClasses; c++ {
+		q.crossed[c] = q.thresholdFor(q.usage[c].percentOf(q.limits[c]))
+	}
+	return q, nil
+}
+
+func (q *Quota) load(ctx context.Context) error {
+	res, err := q.index.Query(dsq.Query{Prefix: networkIndexKey.String()})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+	indexed := make(map[string]ds.Key)
+	for _, e := range entries {
+		k := ds.NewKey(e.Key)
+		c, err := dshelp.DsKeyToCid(ds.NewKey(k.BaseNamespace()))
+		if err != nil {
+			log.Warningf("dropping malformed quota index entry %s: %s", e.Key, err)
+			q.index.Delete(k)
+			continue
+		}
+		indexed[c.KeyString()] = k
+	}
+
+	keys, err := q.bs.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+	for c := range keys {
+		size, err := blockSize(q.bs, c)
+		if err == bstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		class := Local
+		if _, ok := indexed[c.KeyString()]; ok {
+			class = Network
+			q.network[c.KeyString()] = uint64(size)
+			delete(indexed, c.KeyString())
+		}
+		q.usage[class].Bytes += uint64(size)
+		q.usage[class].Blocks++
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// blocks removed while the quota wasn't in use
+	for _, k := range indexed {
+		if err := q.index.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Blockstore returns a view of the blockstore storing blocks as class c.
+func (q *Quota) Blockstore(c Class) bstore.GCBlockstore {
+	return q.Wrap(q.bs, c)
+}
+
+// Wrap returns a view of bs storing blocks as class c. bs must store its
+// blocks in the same place as the quota's blockstore, e.g. without some of
+// its wrappers.
+func (q *Quota) Wrap(bs bstore.GCBlockstore, c Class) bstore.GCBlockstore {
+	return &view{GCBlockstore: bs, q: q, class: c}
+}
+
+// Usage returns the storage used by class c.
+func (q *Quota) Usage(c Class) Usage {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	return q.usage[c]
+}
+
+// Limit returns the quota of class c.
+func (q *Quota) Limit(c Class) Limit {
+	return q.limits[c]
+}
+
+// Subscribe registers f to be called with every event. f must not block.
+func (q *Quota) Subscribe(f func(Event)) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	q.subs = append(q.subs, f)
+}
+
+// thresholdFor returns the highest threshold reached at percent, or 0.
+func (q *Quota) thresholdFor(percent int) int {
+	t := 0
+	for _, th := range q.thresholds {
+		if th <= percent {
+			t = th
+		}
+	}
+	return t
+}
+
+// updatedLocked records a change of the usage of class c, returning the
+// event to emit, if any.
+func (q *Quota) updatedLocked(c Class) *Event {
+	t := q.thresholdFor(q.usage[c].percentOf(q.limits[c]))
+	prev := q.crossed[c]
+	q.crossed[c] = t
+	if t <= prev {
+		return nil
+	}
+	return &Event{Class: c, Usage: q.usage[c], Limit: q.limits[c], Threshold: t}
+}
+
+func (q *Quota) emit(ev *Event) {
+	if ev == nil {
+		return
+	}
+	log.Warningf("%s blocks use %d%% of their quota (%d bytes in %d blocks)",
+		ev.Class, ev.Threshold, ev.Usage.Bytes, ev.Usage.Blocks)
+
+	q.lk.Lock()
+	subs := q.subs
+	q.lk.Unlock()
+	for _, f := range subs {
+		f(*ev)
+	}
+}
+
+// reservation is the accounting change of a write.
+type reservation struct {
+	add      [numClasses]Usage
+	sub      [numClasses]Usage
+	toIndex  []blocks.Block
+	reindex  []*cid.Cid // network blocks becoming local
+	newBlock []blocks.Block
+}
+
+// reserve accounts for storing blks as class c, failing if that would
+// exceed a quota. Blocks already stored only count against c if they move
+// from the network to the local class.
+func (q *Quota) reserve(c Class, blks []blocks.Block, stored []bool) (*reservation, []*Event, error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	r := &reservation{}
+	seen := make(map[string]bool)
+	for i, b := range blks {
+		k := b.Cid().KeyString()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		size := uint64(len(b.RawData()))
+
+		if stored[i] {
+			if netSize, ok := q.network[k]; ok && c == Local {
+				r.sub[Network].Bytes += netSize
+				r.sub[Network].Blocks++
+				r.add[Local].Bytes += netSize
+				r.add[Local].Blocks++
+				r.reindex = append(r.reindex, b.Cid())
+			}
+			continue
+		}
+
+		r.add[c].Bytes += size
+		r.add[c].Blocks++
+		r.newBlock = append(r.newBlock, b)
+		if c == Network {
+			r.toIndex = append(r.toIndex, b)
+		}
+	}
+
+	for cl := Class(0); cl < numClasses; cl++ {
+		u := q.usage[cl]
+		u.Bytes += r.add[cl].Bytes - r.sub[cl].Bytes
+		u.Blocks += r.add[cl].Blocks - r.sub[cl].Blocks
+		if r.add[cl].Blocks > 0 && !u.fits(q.limits[cl]) {
+			return nil, nil, ErrQuotaExceeded
+		}
+	}
+
+	var events []*Event
+	for cl := Class(0); cl < numClasses; cl++ {
+		q.usage[cl].Bytes += r.add[cl].Bytes - r.sub[cl].Bytes
+		q.usage[cl].Blocks += r.add[cl].Blocks - r.sub[cl].Blocks
+		if ev := q.updatedLocked(cl); ev != nil {
+			events = append(events, ev)
+		}
+	}
+	for _, b := range r.toIndex {
+		q.network[b.Cid().KeyString()] = uint64(len(b.RawData()))
+	}
+	for _, c := range r.reindex {
+		delete(q.network, c.KeyString())
+	}
+	return r, events, nil
+}
+
+// cancel undoes a reservation whose write failed.
+func (q *Quota) cancel(r *reservation) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	for cl := Class(0); cl < numClasses; cl++ {
+		q.usage[cl].Bytes -= r.add[cl].Bytes - r.sub[cl].Bytes
+		q.usage[cl].Blocks -= r.add[cl].Blocks - r.sub[cl].Blocks
+		q.updatedLocked(cl)
+	}
+	for _, b := range r.toIndex {
+		delete(q.network, b.Cid().KeyString())
+	}
+}
+
+func networkKey(c *cid.Cid) ds.Key {
+	return networkIndexKey.Child(dshelp.CidToDsKey(c))
+}
+
+// commitIndex persists the class changes of a reservation.
+func (q *Quota) commitIndex(r *reservation) error {
+	var buf [binary.MaxVarintLen64]byte
+	for _, b := range r.toIndex {
+		n := binary.PutUvarint(buf[:], uint64(len(b.RawData())))
+		if err := q.index.Put(networkKey(b.Cid()), append([]byte(nil), buf[:n]...)); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.reindex {
+		if err := q.index.Delete(networkKey(c)); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// put stores blks as class c. Concurrent puts of the same new block may
+// count it twice; the usage is corrected on the next start.
+func (q *Quota) put(bs bstore.Blockstore, c Class, blks []blocks.Block) error {
+	stored := make([]bool, len(blks))
+	for i, b := range blks {
+		has, err := bs.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		stored[i] = has
+	}
+
+	r, events, err := q.reserve(c, blks, stored)
+	if err != nil {
+		return err
+	}
+
+	// index before writing, so that a crash can't make network blocks
+	// count as local
+	if err := q.commitIndex(r); err != nil {
+		q.cancel(r)
+		return err
+	}
+	if len(r.newBlock) > 0 {
+		if err := bs.PutMany(r.newBlock); err != nil {
+			q.cancel(r)
+			return err
+		}
+	}
+
+	for _, ev := range events {
+		q.emit(ev)
+	}
+	return nil
+}
+
+// blockSize returns the size of the data of c.
+func blockSize(bs bstore.Blockstore, c *cid.Cid) (int, error) {
+	b, err := bs.Get(c)
+	if err != nil {
+		return 0, err
+	}
+	return len(b.RawData()), nil
+}
+
+func (q *Quota) deleteBlock(bs bstore.Blockstore, c *cid.Cid) error {
+	size, err := blockSize(bs, c)
+	if err != nil {
+		return err
+	}
+	if err := bs.DeleteBlock(c); err != nil {
+		return err
+	}
+
+	q.lk.Lock()
+	class := Local
+	if _, ok := q.network[c.KeyString()]; ok {
+		class = Network
+		delete(q.network, c.KeyString())
+	}
+	q.usage[class].Bytes -= uint64(size)
+	q.usage[class].Blocks--
+	q.updatedLocked(class)
+	q.lk.Unlock()
+
+	if class == Network {
+		if err := q.index.Delete(networkKey(c)); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// view stores blocks as a given class.
+type view struct {
+	bstore.GCBlockstore
+	q     *Quota
+	class Class
+}
+
+func (v *view) Put(b blocks.Block) error {
+	return v.q.put(v.GCBlockstore, v.class, []blocks.Block{b})
+}
+
+func (v *view) PutMany(blks []blocks.Block) error {
+	return v.q.put(v.GCBlockstore, v.class, blks)
+}
+
+func (v *view) DeleteBlock(c *cid.Cid) error {
+	return v.q.deleteBlock(v.GCBlockstore, c)
+}
@@ -0,0 +1,119 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func newTestQuota(t *testing.T, base bstore.Blockstore, index ds.Datastore, opts Options) *Quota {
+	q, err := New(context.Background(), bstore.NewGCBlockstore(base, bstore.NewGCLocker()), index, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func makeBlock(i int) blocks.Block {
+	// 10 bytes each
+	return blocks.NewBlock([]byte(fmt.Sprintf("block %04d", i)))
+}
+
+func TestSeparateQuotas(t *testing.T) {
+	base := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	index := dssync.MutexWrap(ds.NewMapDatastore())
+	q := newTestQuota(t, base, index, Options{
+		Local:      Limit{MaxBlocks: 2},
+		Network:    Limit{MaxBytes: 30},
+		Thresholds: []int{50, 100},
+	})
+
+	var events []Event
+	q.Subscribe(func(ev Event) {
+		events = append(events, ev)
+	})
+
+	local := q.Blockstore(Local)
+	network := q.Blockstore(Network)
+
+	if err := local.PutMany([]blocks.Block{makeBlock(0), makeBlock(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Put(makeBlock(2)); err != ErrQuotaExceeded {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	// storing a block again doesn't count
+	if err := local.Put(makeBlock(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 10; i < 13; i++ {
+		if err := network.Put(makeBlock(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := network.Put(makeBlock(13)); err != ErrQuotaExceeded {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if has, _ := base.Has(makeBlock(13).Cid()); has {
+		t.Fatal("block over quota should not have been stored")
+	}
+
+	if u := q.Usage(Local); u.Blocks != 2 || u.Bytes != 20 {
+		t.Fatalf("unexpected local usage %+v", u)
+	}
+	if u := q.Usage(Network); u.Blocks != 3 || u.Bytes != 30 {
+		t.Fatalf("unexpected network usage %+v", u)
+	}
+
+	// local jumped to 100% at once, network crossed 50% and then 100%
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+	if events[0].Class != Local || events[0].Threshold != 100 {
+		t.Fatalf("unexpected first event %+v", events[0])
+	}
+	if last := events[2]; last.Class != Network || last.Threshold != 100 {
+		t.Fatalf("unexpected last event %+v", last)
+	}
+
+	// freeing space makes room again
+	if err := local.DeleteBlock(makeBlock(10).Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.Put(makeBlock(13)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUsageSurvivesRestart(t *testing.T) {
+	base := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	index := dssync.MutexWrap(ds.NewMapDatastore())
+	q := newTestQuota(t, base, index, Options{})
+
+	if err := q.Blockstore(Local).Put(makeBlock(0)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 4; i++ {
+		if err := q.Blockstore(Network).Put(makeBlock(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// adding a cached block locally moves it to the local quota
+	if err := q.Blockstore(Local).Put(makeBlock(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	q = newTestQuota(t, base, index, Options{})
+	if u := q.Usage(Local); u.Blocks != 2 {
+		t.Fatalf("expected 2 local blocks, got %d", u.Blocks)
+	}
+	if u := q.Usage(Network); u.Blocks != 2 {
+		t.Fatalf("expected 2 network blocks, got %d", u.Blocks)
+	}
+}
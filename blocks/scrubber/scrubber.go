@@ -0,0 +1,313 @@
+// Package scrubber implements a background process which re-hashes the
+// blocks in a blockstore to detect, and optionally repair, corruption.
+package scrubber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("scrubber")
+
+// ErrRunning is returned by Scrub when a pass is already in progress.
+var ErrRunning = errors.New("a scrub pass is already running")
+
+// findingsKey is where findings are recorded in the report datastore.
+var findingsKey = ds.NewKey("/local/scrub/findings")
+
+// repairTimeout bounds how long fetching a replacement block may take.
+var repairTimeout = time.Minute
+
+// Finding records a corrupt block.
+type Finding struct {
+	Cid      *cid.Cid
+	Found    time.Time
+	Repaired bool
+
+	// Error says why the block couldn't be repaired.
+	Error string `json:",omitempty"`
+}
+
+// Status describes the progress of the scrubber.
+type Status struct {
+	// Running is true while a pass is in progress.
+	Running bool
+
+	// Passes is the number of completed passes.
+	Passes uint64
+
+	// Checked and CheckedSize count the blocks checked in the current or, if
+	// none is running, the last pass.
+	Checked     uint64
+	CheckedSize uint64
+
+	// Corrupt and Repaired count the corrupt blocks found and repaired
+	// since the scrubber was started.
+	Corrupt  uint64
+	Repaired uint64
+
+	LastPassStarted  time.Time
+	LastPassFinished time.Time
+}
+
+// Options configures a Scrubber.
+type Options struct {
+	// Rate bounds the block data read per second. Zero means unlimited.
+	Rate uint64
+
+	// Interval is the time between the end of a pass and the start of the
+	// next one.
+	Interval time.Duration
+
+	// Repair makes the scrubber replace corrupt blocks with copies fetched
+	// from the exchange.
+	Repair bool
+}
+
+// Scrubber re-hashes all blocks of a blockstore periodically.
+type Scrubber struct {
+	bs     bstore.Blockstore
+	report ds.Datastore
+	fetch  exchange.Fetcher
+	opts   Options
+
+	lk     sync.Mutex
+	status Status
+}
+
+// New creates a scrubber checking bs and recording findings in report.
+// fetch is used to repair blocks and may be nil if opts.Repair isn't set.
+func New(bs bstore.Blockstore, report ds.Datastore, fetch exchange.Fetcher, opts Options) *Scrubber {
+	return &Scrubber{
+		bs:     bs,
+		report: report,
+		fetch:  fetch,
+		opts:   opts,
+	}
+}
+
+// Run scrubs the blockstore every Interval until ctx is canceled.
+func (s *Scrubber) Run(ctx context.Context) {
+	// don't start right away, the node may just be starting up
+	after := time.After(time.Minute)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-after:
+		}
+
+		if err := s.Scrub(ctx); err != nil && err != context.Canceled {
+			log.Errorf("scrubbing blockstore: %s", err)
+		}
+		after = time.After(s.opts.Interval)
+	}
+}
+
+// Status returns the current progress.
+func (s *Scrubber) Status() Status {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.status
+}
+
+// Scrub runs a single pass over the blockstore.
+func (s *Scrubber) Scrub(ctx context.Context) error {
+	s.lk.Lock()
+	if s.status.Running {
+		s.lk.Unlock()
+		return ErrRunning
+	}
+	s.status.Running = true
+	s.status.Checked = 0
+	s.status.CheckedSize = 0
+	s.status.LastPassStarted = time.Now()
+	s.lk.Unlock()
+
+	err := s.scrub(ctx)
+
+	s.lk.Lock()
+	s.status.Running = false
+	if err == nil {
+		s.status.Passes++
+		s.status.LastPassFinished = time.Now()
+	}
+	s.lk.Unlock()
+	return err
+}
+
+func (s *Scrubber) scrub(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys, err := s.bs.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var read uint64
+	for c := range keys {
+		size, err := s.check(ctx, c)
+		if err != nil {
+			return err
+		}
+		read += uint64(size)
+
+		if s.opts.Rate == 0 {
+			continue
+		}
+		due := start.Add(time.Duration(float64(read) / float64(s.opts.Rate) * float64(time.Second)))
+		if wait := time.Until(due); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// check verifies the block c, returning the number of bytes read.
+func (s *Scrubber) check(ctx context.Context, c *cid.Cid) (int, error) {
+	b, err := s.bs.Get(c)
+	switch err {
+	case nil:
+	case bstore.ErrNotFound:
+		// removed since the listing
+		return 0, nil
+	case bstore.ErrHashMismatch:
+		// the blockstore verifies hashes itself
+		return 0, s.corrupt(ctx, c)
+	default:
+		return 0, err
+	}
+
+	size := len(b.RawData())
+	s.lk.Lock()
+	s.status.Checked++
+	s.status.CheckedSize += uint64(size)
+	s.lk.Unlock()
+
+	rehash, err := c.Prefix().Sum(b.RawData())
+	if err != nil {
+		log.Warningf("can't verify block %s: %s", c, err)
+		return size, nil
+	}
+	if rehash.Equals(c) {
+		return size, nil
+	}
+	return size, s.corrupt(ctx, c)
+}
+
+// corrupt records c as corrupt and tries to repair it.
+func (s *Scrubber) corrupt(ctx context.Context, c *cid.Cid) error {
+	log.Errorf("block %s is corrupt", c)
+	s.lk.Lock()
+	s.status.Corrupt++
+	s.lk.Unlock()
+
+	f := Finding{Cid: c, Found: time.Now()}
+	if s.opts.Repair && s.fetch != nil {
+		if err := s.repair(ctx, c); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Errorf("repairing block %s: %s", c, err)
+			f.Error = err.Error()
+		} else {
+			f.Repaired = true
+			s.lk.Lock()
+			s.status.Repaired++
+			s.lk.Unlock()
+		}
+	}
+	return s.record(f)
+}
+
+// repair replaces the stored copy of c with one fetched from the exchange.
+func (s *Scrubber) repair(ctx context.Context, c *cid.Cid) error {
+	// the corrupt copy must go, or it would be served instead
+	if err := s.bs.DeleteBlock(c); err != nil && err != bstore.ErrNotFound {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, repairTimeout)
+	defer cancel()
+	b, err := s.fetch.GetBlock(ctx, c)
+	if err != nil {
+		return err
+	}
+	if rehash, err := c.Prefix().Sum(b.RawData()); err != nil || !rehash.Equals(c) {
+		return errors.New("fetched block doesn't match its hash")
+	}
+	return s.bs.Put(b)
+}
+
+func (s *Scrubber) record(f Finding) error {
+	val, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return s.report.Put(findingsKey.Child(dshelp.CidToDsKey(f.Cid)), val)
+}
+
+// Findings returns the corrupt blocks found so far.
+func (s *Scrubber) Findings() ([]Finding, error) {
+	res, err := s.report.Query(dsq.Query{Prefix: findingsKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Finding
+	for _, e := range entries {
+		val, ok := e.Value.([]byte)
+		if !ok {
+			continue
+		}
+		var f Finding
+		if err := json.Unmarshal(val, &f); err != nil {
+			log.Warningf("dropping malformed scrub finding %s: %s", e.Key, err)
+			continue
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// ClearFindings removes all recorded findings.
+func (s *Scrubber) ClearFindings() error {
+	res, err := s.report.Query(dsq.Query{Prefix: findingsKey.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := s.report.Delete(ds.NewKey(e.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package scrubber
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+type fakeFetcher map[string]blocks.Block
+
+func (f fakeFetcher) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	b, ok := f[c.KeyString()]
+	if !ok {
+		return nil, bstore.ErrNotFound
+	}
+	return b, nil
+}
+
+func (f fakeFetcher) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	panic("not used")
+}
+
+func TestScrubFindsAndRepairsCorruption(t *testing.T) {
+	ctx := context.Background()
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	report := dssync.MutexWrap(ds.NewMapDatastore())
+
+	good := blocks.NewBlock([]byte("good block"))
+	damaged := blocks.NewBlock([]byte("damaged block"))
+	lost := blocks.NewBlock([]byte("lost block"))
+	if err := bs.Put(good); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []blocks.Block{damaged, lost} {
+		bad, err := blocks.NewBlockWithCid([]byte("bit rot"), b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bs.Put(bad); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fetch := fakeFetcher{damaged.Cid().KeyString(): damaged}
+	s := New(bs, report, fetch, Options{Repair: true})
+	if err := s.Scrub(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	st := s.Status()
+	if st.Passes != 1 || st.Checked != 3 || st.Corrupt != 2 || st.Repaired != 1 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	findings, err := s.Findings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d", len(findings))
+	}
+	for _, f := range findings {
+		switch {
+		case f.Cid.Equals(damaged.Cid()):
+			if !f.Repaired {
+				t.Fatal("damaged block should have been repaired")
+			}
+		case f.Cid.Equals(lost.Cid()):
+			if f.Repaired || f.Error == "" {
+				t.Fatal("lost block can't have been repaired")
+			}
+		default:
+			t.Fatalf("unexpected finding %s", f.Cid)
+		}
+	}
+
+	b, err := bs.Get(damaged.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b.RawData()) != "damaged block" {
+		t.Fatal("repaired block has wrong data")
+	}
+
+	if err := s.ClearFindings(); err != nil {
+		t.Fatal(err)
+	}
+	if findings, _ := s.Findings(); len(findings) != 0 {
+		t.Fatal("findings should have been cleared")
+	}
+}
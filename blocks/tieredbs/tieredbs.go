@@ -0,0 +1,431 @@
+// Package tieredbs implements a Blockstore keeping recently used blocks in a
+// fast hot store and moving the rest to a slower cold store.
+package tieredbs
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("tieredbs")
+
+var (
+	// sizeIndexKey is where the sizes of the hot blocks are recorded.
+	sizeIndexKey = ds.NewKey("/local/tiered/hot")
+
+	// indexedKey marks the size index as complete; without it, the hot
+	// blocks are read once to build it.
+	indexedKey = ds.NewKey("/local/tiered/indexed")
+)
+
+var _ bstore.Blockstore = (*Blockstore)(nil)
+
+// Blockstore stores blocks in one of two tiers. New blocks are written to the
+// hot tier. Once the hot tier grows over its capacity, the least recently
+// used blocks are moved to the cold tier; reading a cold block moves it back.
+// A block lives in exactly one tier at a time.
+type Blockstore struct {
+	hot, cold bstore.Blockstore
+	sizes     ds.Datastore // the size index of the hot blocks
+	capacity  uint64
+
+	// moveLk serializes moving blocks between the tiers
+	moveLk sync.Mutex
+
+	lk    sync.Mutex
+	size  uint64
+	lru   *list.List // of *entry, most recently used first
+	index map[string]*list.Element
+}
+
+type entry struct {
+	c    *cid.Cid
+	size int
+}
+
+// New creates a tiered blockstore over hot and cold, keeping at most capacity
+// bytes of block data in hot. The sizes of the hot blocks are recorded in
+// sizes, from which they are loaded at startup; the blocks are only read the
+// first time. The excess is evicted if the capacity was lowered.
+func New(ctx context.Context, hot, cold bstore.Blockstore, sizes ds.Datastore, capacity uint64) (*Blockstore, error) {
+	bs := &Blockstore{
+		hot:      hot,
+		cold:     cold,
+		sizes:    sizes,
+		capacity: capacity,
+		lru:      list.New(),
+		index:    make(map[string]*list.Element),
+	}
+
+	indexed, err := sizes.Has(indexedKey)
+	if err != nil {
+		return nil, err
+	}
+	if indexed {
+		err = bs.loadIndex()
+	} else {
+		err = bs.buildIndex(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bs.evict()
+	return bs, nil
+}
+
+func sizeKey(c *cid.Cid) ds.Key {
+	return sizeIndexKey.Child(dshelp.CidToDsKey(c))
+}
+
+// loadIndex loads the sizes of the hot blocks recorded in the index.
+func (bs *Blockstore) loadIndex() error {
+	res, err := bs.sizes.Query(dsq.Query{Prefix: sizeIndexKey.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		k := ds.NewKey(e.Key)
+		c, err := dshelp.DsKeyToCid(ds.NewKey(k.BaseNamespace()))
+		if err != nil {
+			log.Errorf("bad key in the size index: %s", k)
+			continue
+		}
+		val, ok := e.Value.([]byte)
+		if !ok {
+			return errors.New("bad value in the size index")
+		}
+		size, n := binary.Uvarint(val)
+		if n <= 0 {
+			return errors.New("bad value in the size index")
+		}
+		bs.addLocked(c, int(size))
+	}
+	return nil
+}
+
+// DropIndex marks the size index in d as outdated, so that it is built again
+// the next time the blockstore is tiered. It is called while the blockstore
+// isn't tiered, as the blocks written then aren't recorded.
+func DropIndex(d ds.Datastore) error {
+	err := d.Delete(indexedKey)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// buildIndex reads the blocks already stored in hot to record their sizes,
+// for repos which had none or an outdated one.
+func (bs *Blockstore) buildIndex(ctx context.Context) error {
+	if err := bs.clearIndex(); err != nil {
+		return err
+	}
+
+	keys, err := bs.hot.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+	for c := range keys {
+		b, err := bs.hot.Get(c)
+		if err != nil {
+			return err
+		}
+		if err := bs.recordSize(c, len(b.RawData())); err != nil {
+			return err
+		}
+		bs.lk.Lock()
+		bs.addLocked(c, len(b.RawData()))
+		bs.lk.Unlock()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return bs.sizes.Put(indexedKey, []byte{})
+}
+
+func (bs *Blockstore) clearIndex() error {
+	res, err := bs.sizes.Query(dsq.Query{Prefix: sizeIndexKey.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := bs.sizes.Delete(ds.NewKey(e.Key)); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordSize writes the size of c to the index. It is written before the
+// block is put in hot, and removed after the block left it, so that a hot
+// block always has its size recorded.
+func (bs *Blockstore) recordSize(c *cid.Cid, size int) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(size))
+	return bs.sizes.Put(sizeKey(c), buf[:n])
+}
+
+func (bs *Blockstore) forgetSize(c *cid.Cid) {
+	if err := bs.sizes.Delete(sizeKey(c)); err != nil && err != ds.ErrNotFound {
+		log.Errorf("removing %s from the size index: %s", c, err)
+	}
+}
+
+// addLocked marks c as the most recently used hot block.
+func (bs *Blockstore) addLocked(c *cid.Cid, size int) {
+	if e, ok := bs.index[c.KeyString()]; ok {
+		bs.lru.MoveToFront(e)
+		return
+	}
+	bs.index[c.KeyString()] = bs.lru.PushFront(&entry{c: c, size: size})
+	bs.size += uint64(size)
+}
+
+func (bs *Blockstore) removeLocked(c *cid.Cid) {
+	e, ok := bs.index[c.KeyString()]
+	if !ok {
+		return
+	}
+	bs.lru.Remove(e)
+	delete(bs.index, c.KeyString())
+	bs.size -= uint64(e.Value.(*entry).size)
+}
+
+// lookup returns the size of c if it is in the hot tier, marking it as used.
+func (bs *Blockstore) lookup(c *cid.Cid) (int, bool) {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	e, ok := bs.index[c.KeyString()]
+	if !ok {
+		return 0, false
+	}
+	bs.lru.MoveToFront(e)
+	return e.Value.(*entry).size, true
+}
+
+// evict moves least recently used blocks to the cold tier until the hot tier
+// fits its capacity.
+func (bs *Blockstore) evict() {
+	bs.moveLk.Lock()
+	defer bs.moveLk.Unlock()
+
+	for {
+		bs.lk.Lock()
+		if bs.size <= bs.capacity || bs.lru.Len() == 0 {
+			bs.lk.Unlock()
+			return
+		}
+		victim := bs.lru.Back().Value.(*entry)
+		bs.removeLocked(victim.c)
+		bs.lk.Unlock()
+
+		if err := bs.demote(victim.c); err != nil {
+			log.Errorf("moving %s to the cold tier: %s", victim.c, err)
+			// keep it hot rather than retrying forever
+			bs.lk.Lock()
+			bs.addLocked(victim.c, victim.size)
+			bs.lk.Unlock()
+			return
+		}
+	}
+}
+
+// demote copies c to the cold tier before removing it from the hot one, so
+// that it can always be found in one of them.
+func (bs *Blockstore) demote(c *cid.Cid) error {
+	b, err := bs.hot.Get(c)
+	switch err {
+	case nil:
+	case bstore.ErrNotFound:
+		// deleted in the meantime
+		bs.forgetSize(c)
+		return nil
+	default:
+		return err
+	}
+	if err := bs.cold.Put(b); err != nil {
+		return err
+	}
+	if err := bs.hot.DeleteBlock(c); err != nil && err != bstore.ErrNotFound {
+		return err
+	}
+	bs.forgetSize(c)
+	return nil
+}
+
+// promote moves b, read from the cold tier, into the hot tier.
+func (bs *Blockstore) promote(b blocks.Block) {
+	bs.moveLk.Lock()
+	if err := bs.recordSize(b.Cid(), len(b.RawData())); err != nil {
+		bs.moveLk.Unlock()
+		log.Debugf("promoting %s to the hot tier: %s", b.Cid(), err)
+		return
+	}
+	if err := bs.hot.Put(b); err != nil {
+		bs.moveLk.Unlock()
+		log.Debugf("promoting %s to the hot tier: %s", b.Cid(), err)
+		return
+	}
+	bs.lk.Lock()
+	bs.addLocked(b.Cid(), len(b.RawData()))
+	bs.lk.Unlock()
+	if err := bs.cold.DeleteBlock(b.Cid()); err != nil && err != bstore.ErrNotFound {
+		log.Debugf("removing promoted block %s from the cold tier: %s", b.Cid(), err)
+	}
+	bs.moveLk.Unlock()
+
+	bs.evict()
+}
+
+// Get returns a block from whichever tier holds it, promoting cold blocks.
+func (bs *Blockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	if _, ok := bs.lookup(c); ok {
+		b, err := bs.hot.Get(c)
+		if err != bstore.ErrNotFound {
+			return b, err
+		}
+		// demoted in the meantime
+	}
+
+	b, err := bs.cold.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	bs.promote(b)
+	return b, nil
+}
+
+// Has returns whether either tier holds c.
+func (bs *Blockstore) Has(c *cid.Cid) (bool, error) {
+	if _, ok := bs.lookup(c); ok {
+		return true, nil
+	}
+	return bs.cold.Has(c)
+}
+
+// Put stores b in the hot tier, unless it is already stored.
+func (bs *Blockstore) Put(b blocks.Block) error {
+	return bs.PutMany([]blocks.Block{b})
+}
+
+// PutMany stores blks in the hot tier, skipping blocks already stored.
+func (bs *Blockstore) PutMany(blks []blocks.Block) error {
+	var toPut []blocks.Block
+	for _, b := range blks {
+		has, err := bs.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			toPut = append(toPut, b)
+		}
+	}
+	if len(toPut) == 0 {
+		return nil
+	}
+
+	for _, b := range toPut {
+		if err := bs.recordSize(b.Cid(), len(b.RawData())); err != nil {
+			return err
+		}
+	}
+	if err := bs.hot.PutMany(toPut); err != nil {
+		return err
+	}
+	bs.lk.Lock()
+	for _, b := range toPut {
+		bs.addLocked(b.Cid(), len(b.RawData()))
+	}
+	bs.lk.Unlock()
+
+	bs.evict()
+	return nil
+}
+
+// DeleteBlock removes c from both tiers.
+func (bs *Blockstore) DeleteBlock(c *cid.Cid) error {
+	bs.moveLk.Lock()
+	defer bs.moveLk.Unlock()
+
+	bs.lk.Lock()
+	bs.removeLocked(c)
+	bs.lk.Unlock()
+
+	herr := bs.hot.DeleteBlock(c)
+	if herr != nil && herr != bstore.ErrNotFound {
+		return herr
+	}
+	bs.forgetSize(c)
+	cerr := bs.cold.DeleteBlock(c)
+	if cerr != nil && cerr != bstore.ErrNotFound {
+		return cerr
+	}
+	if herr == bstore.ErrNotFound && cerr == bstore.ErrNotFound {
+		return bstore.ErrNotFound
+	}
+	return nil
+}
+
+// AllKeysChan returns the keys of both tiers. A block being moved at the
+// time may be returned twice.
+func (bs *Blockstore) AllKeysChan(ctx context.Context) (<-chan *cid.Cid, error) {
+	hotCh, err := bs.hot.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coldCh, err := bs.cold.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *cid.Cid)
+	go func() {
+		defer close(out)
+		for _, ch := range []<-chan *cid.Cid{hotCh, coldCh} {
+			for c := range ch {
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// HashOnRead enables or disables hash verification in both tiers.
+func (bs *Blockstore) HashOnRead(enabled bool) {
+	bs.hot.HashOnRead(enabled)
+	bs.cold.HashOnRead(enabled)
+}
+
+// HotSize returns the number of bytes of block data in the hot tier.
+func (bs *Blockstore) HotSize() uint64 {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	return bs.size
+}
@@ -0,0 +1,193 @@
+package tieredbs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func newStores() (hot, cold bstore.Blockstore, sizes ds.Datastore) {
+	hot = bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	cold = bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sizes = dssync.MutexWrap(ds.NewMapDatastore())
+	return hot, cold, sizes
+}
+
+// countingBlockstore counts the blocks read from it.
+type countingBlockstore struct {
+	bstore.Blockstore
+	gets int
+}
+
+func (bs *countingBlockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	bs.gets++
+	return bs.Blockstore.Get(c)
+}
+
+func makeBlocks(n int) []blocks.Block {
+	var out []blocks.Block
+	for i := 0; i < n; i++ {
+		// 10 bytes each
+		out = append(out, blocks.NewBlock([]byte(fmt.Sprintf("block %03d", i)+"!")))
+	}
+	return out
+}
+
+func assertIn(t *testing.T, s bstore.Blockstore, b blocks.Block, want bool, tier string) {
+	has, err := s.Has(b.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has != want {
+		t.Fatalf("%s in %s tier: expected %t, got %t", b.Cid(), tier, want, has)
+	}
+}
+
+func TestEvictAndPromote(t *testing.T) {
+	ctx := context.Background()
+	hot, cold, sizes := newStores()
+	bs, err := New(ctx, hot, cold, sizes, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blks := makeBlocks(4)
+	for _, b := range blks {
+		if err := bs.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the oldest block doesn't fit anymore
+	assertIn(t, cold, blks[0], true, "cold")
+	assertIn(t, hot, blks[0], false, "hot")
+	for _, b := range blks[1:] {
+		assertIn(t, hot, b, true, "hot")
+	}
+	if bs.HotSize() != 30 {
+		t.Fatalf("expected 30 bytes in the hot tier, got %d", bs.HotSize())
+	}
+
+	// reading it brings it back, evicting the now least recently used one
+	got, err := bs.Get(blks[0].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.RawData()) != string(blks[0].RawData()) {
+		t.Fatal("got wrong data")
+	}
+	assertIn(t, hot, blks[0], true, "hot")
+	assertIn(t, cold, blks[0], false, "cold")
+	assertIn(t, cold, blks[1], true, "cold")
+
+	for _, b := range blks {
+		assertIn(t, bs, b, true, "tiered")
+	}
+}
+
+func TestAllKeysAndDelete(t *testing.T) {
+	ctx := context.Background()
+	hot, cold, sizes := newStores()
+	bs, err := New(ctx, hot, cold, sizes, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blks := makeBlocks(5)
+	if err := bs.PutMany(blks); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range keys {
+		n++
+	}
+	if n != len(blks) {
+		t.Fatalf("expected %d keys, got %d", len(blks), n)
+	}
+
+	for _, b := range []blocks.Block{blks[0], blks[4]} {
+		if err := bs.DeleteBlock(b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+		assertIn(t, bs, b, false, "tiered")
+	}
+	if err := bs.DeleteBlock(blks[0].Cid()); err != bstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestReindexOnStart(t *testing.T) {
+	ctx := context.Background()
+	hot, cold, sizes := newStores()
+	blks := makeBlocks(3)
+	if err := hot.PutMany(blks); err != nil {
+		t.Fatal(err)
+	}
+
+	// the capacity was lowered since the blocks were stored
+	bs, err := New(ctx, hot, cold, sizes, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.HotSize() != 10 {
+		t.Fatalf("expected 10 bytes in the hot tier, got %d", bs.HotSize())
+	}
+	for _, b := range blks {
+		assertIn(t, bs, b, true, "tiered")
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	ctx := context.Background()
+	hot, cold, sizes := newStores()
+	bs, err := New(ctx, hot, cold, sizes, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blks := makeBlocks(3)
+	if err := bs.PutMany(blks); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteBlock(blks[2].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sizes are loaded from the index, without reading the blocks
+	counted := &countingBlockstore{Blockstore: hot}
+	bs, err = New(ctx, counted, cold, sizes, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counted.gets != 0 {
+		t.Fatalf("expected no block to be read, %d were", counted.gets)
+	}
+	if bs.HotSize() != 10 {
+		t.Fatalf("expected 10 bytes in the hot tier, got %d", bs.HotSize())
+	}
+
+	// blocks written while the blockstore wasn't tiered aren't recorded
+	if err := hot.Put(blks[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := DropIndex(sizes); err != nil {
+		t.Fatal(err)
+	}
+	bs, err = New(ctx, hot, cold, sizes, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.HotSize() != 20 {
+		t.Fatalf("expected 20 bytes in the hot tier, got %d", bs.HotSize())
+	}
+}
@@ -0,0 +1,144 @@
+package blockservice
+
+import (
+	"context"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+)
+
+// FetchOptions controls how blocks missing from the local blockstore are
+// requested from the exchange by GetBlocks.
+type FetchOptions struct {
+	// BatchSize splits the misses into requests of at most this many CIDs.
+	// Zero means all misses are requested at once.
+	BatchSize int
+
+	// MaxInFlight bounds the number of wants outstanding at any time. A new
+	// batch is only requested once enough blocks of the previous ones have
+	// arrived. Zero means no bound.
+	MaxInFlight int
+}
+
+// WithFetchOptions makes the session request missing blocks according to
+// opts. Batches are requested in the order the CIDs were passed to
+// GetBlocks, so that earlier blocks (e.g. the beginning of a file being read
+// sequentially) are not held up behind later ones.
+func WithFetchOptions(opts FetchOptions) SessionOption {
+	return func(s *sessionSettings) {
+		s.fetch = opts
+	}
+}
+
+// fetchMisses requests misses from f in batches according to opts, sending
+// the received blocks on out and removing them from remaining. If sched is
+// set, every batch has to be let through by it first. It returns once all
+// batches are done or ctx is canceled.
+func fetchMisses(ctx context.Context, f exchange.Fetcher, misses []*cid.Cid, opts FetchOptions, sched *Scheduler, remaining *cid.Set, out chan<- blocks.Block) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(misses)
+	}
+	prio := exchange.PriorityFromContext(ctx)
+
+	results := make(chan blocks.Block)
+	// receives the number of wants a finished batch didn't deliver
+	done := make(chan int)
+	// receives the scheduler's answer for the pending batch
+	granted := make(chan error, 1)
+
+	var inflight, active, next int
+	var pending []*cid.Cid
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		if pending != nil {
+			if err := <-granted; err == nil {
+				sched.release(len(pending))
+			}
+		}
+		sched.release(inflight)
+	}()
+
+	startBatch := func(batch []*cid.Cid) {
+		rblocks, err := f.GetBlocks(ctx, batch)
+		if err != nil {
+			log.Debugf("Error with GetBlocks: %s", err)
+			sched.release(len(batch))
+			return
+		}
+		inflight += len(batch)
+		active++
+
+		go func(n int) {
+			got := 0
+			for b := range rblocks {
+				select {
+				case results <- b:
+					got++
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case done <- n - got:
+			case <-ctx.Done():
+			}
+		}(len(batch))
+	}
+
+	for {
+		for next < len(misses) && pending == nil {
+			end := next + batchSize
+			if end > len(misses) {
+				end = len(misses)
+			}
+			batch := misses[next:end]
+			if opts.MaxInFlight > 0 && inflight > 0 && inflight+len(batch) > opts.MaxInFlight {
+				break
+			}
+			next = end
+
+			if sched == nil {
+				startBatch(batch)
+				continue
+			}
+			pending = batch
+			go func(n int) {
+				granted <- sched.acquire(ctx, prio, n)
+			}(len(batch))
+		}
+
+		if active == 0 && pending == nil {
+			return
+		}
+
+		select {
+		case err := <-granted:
+			batch := pending
+			pending = nil
+			if err != nil {
+				return
+			}
+			startBatch(batch)
+		case b := <-results:
+			inflight--
+			sched.release(1)
+			remaining.Remove(b.Cid())
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		case n := <-done:
+			active--
+			inflight -= n
+			sched.release(n)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
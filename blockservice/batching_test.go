@@ -0,0 +1,112 @@
+package blockservice
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	butil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// batchRecordingFetcher serves blocks from a blockstore and records the
+// requested batches along with the largest number of outstanding wants.
+type batchRecordingFetcher struct {
+	bs blockstore.Blockstore
+
+	lk          sync.Mutex
+	batches     [][]*cid.Cid
+	outstanding int
+	maxOut      int
+}
+
+func (f *batchRecordingFetcher) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	return f.bs.Get(c)
+}
+
+func (f *batchRecordingFetcher) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	f.lk.Lock()
+	f.batches = append(f.batches, ks)
+	f.outstanding += len(ks)
+	if f.outstanding > f.maxOut {
+		f.maxOut = f.outstanding
+	}
+	f.lk.Unlock()
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for _, c := range ks {
+			b, err := f.bs.Get(c)
+			if err != nil {
+				continue
+			}
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+			f.lk.Lock()
+			f.outstanding--
+			f.lk.Unlock()
+		}
+	}()
+	return out, nil
+}
+
+func TestSessionFetchOptions(t *testing.T) {
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bgen := butil.NewBlockGenerator()
+	blks := bgen.Blocks(10)
+	var ks []*cid.Cid
+	for _, b := range blks {
+		if err := remote.Put(b); err != nil {
+			t.Fatal(err)
+		}
+		ks = append(ks, b.Cid())
+	}
+
+	local := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bserv := New(local, offline.Exchange(local))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	ses := NewSession(ctx, bserv, WithFetchOptions(FetchOptions{
+		BatchSize:   3,
+		MaxInFlight: 4,
+	}))
+	f := &batchRecordingFetcher{bs: remote}
+	ses.ses = f
+
+	got := 0
+	for range ses.GetBlocks(ctx, ks) {
+		got++
+	}
+	if got != len(ks) {
+		t.Fatalf("expected %d blocks, got %d", len(ks), got)
+	}
+
+	if len(f.batches) != 4 {
+		t.Fatalf("expected 4 batches, got %d", len(f.batches))
+	}
+	i := 0
+	for _, batch := range f.batches {
+		for _, c := range batch {
+			if !c.Equals(ks[i]) {
+				t.Fatal("batches should be requested in order")
+			}
+			i++
+		}
+	}
+	if f.maxOut > 4 {
+		t.Fatalf("expected at most 4 wants in flight, saw %d", f.maxOut)
+	}
+}
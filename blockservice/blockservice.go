@@ -4,18 +4,23 @@
 package blockservice
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	exchange "github.com/ipfs/go-ipfs/exchange"
 	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
+	tracing "github.com/ipfs/go-ipfs/tracing"
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
 )
 
 var log = logging.Logger("blockservice")
@@ -64,37 +69,88 @@ type BlockService interface {
 
 type blockService struct {
 	blockstore blockstore.Blockstore
-	exchange   exchange.Interface
+
+	exLk     sync.RWMutex
+	exchange exchange.Interface
 	// If checkFirst is true then first check that a block doesn't
 	// already exist to avoid republishing the block on the exchange.
 	checkFirst bool
+
+	// fallback, if set, is asked for blocks the exchange couldn't provide
+	// within fallbackDelay.
+	fallback      exchange.Fetcher
+	fallbackDelay time.Duration
+	// fallbackOff is set while SetExchange made the blockservice offline.
+	fallbackOff bool
+
+	// If announceWarnOnly is true, AddBlocks only logs failures to announce
+	// blocks on the exchange instead of returning them.
+	announceWarnOnly bool
+
+	// sched, if set, decides when wants for missing blocks are sent.
+	sched *Scheduler
+}
+
+// Option configures a BlockService created by New or NewWriteThrough.
+type Option func(*blockService)
+
+// AnnounceFailuresAsWarnings makes AddBlocks log blocks it couldn't announce
+// on the exchange instead of failing. The blocks are stored either way.
+func AnnounceFailuresAsWarnings() Option {
+	return func(s *blockService) {
+		s.announceWarnOnly = true
+	}
+}
+
+// AnnounceError is returned by AddBlocks when some of the blocks were
+// stored but couldn't be announced on the exchange.
+type AnnounceError struct {
+	Cids   []*cid.Cid
+	Errors []error
+}
+
+func (e *AnnounceError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "failed to announce %d blocks", len(e.Cids))
+	for i, c := range e.Cids {
+		fmt.Fprintf(&buf, "; %s: %s", c, e.Errors[i])
+	}
+	return buf.String()
 }
 
 // NewBlockService creates a BlockService with given datastore instance.
-func New(bs blockstore.Blockstore, rem exchange.Interface) BlockService {
+func New(bs blockstore.Blockstore, rem exchange.Interface, opts ...Option) BlockService {
 	if rem == nil {
 		log.Warning("blockservice running in local (offline) mode.")
 	}
 
-	return &blockService{
+	s := &blockService{
 		blockstore: bs,
 		exchange:   rem,
 		checkFirst: true,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // NewWriteThrough ceates a BlockService that guarantees writes will go
 // through to the blockstore and are not skipped by cache checks.
-func NewWriteThrough(bs blockstore.Blockstore, rem exchange.Interface) BlockService {
+func NewWriteThrough(bs blockstore.Blockstore, rem exchange.Interface, opts ...Option) BlockService {
 	if rem == nil {
 		log.Warning("blockservice running in local (offline) mode.")
 	}
 
-	return &blockService{
+	s := &blockService{
 		blockstore: bs,
 		exchange:   rem,
 		checkFirst: false,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Blockstore returns the blockstore behind this blockservice.
@@ -104,26 +160,98 @@ func (s *blockService) Blockstore() blockstore.Blockstore {
 
 // Exchange returns the exchange behind this blockservice.
 func (s *blockService) Exchange() exchange.Interface {
+	s.exLk.RLock()
+	defer s.exLk.RUnlock()
 	return s.exchange
 }
 
+// SetExchange makes the blockservice bs fetch and announce blocks with ex,
+// e.g. when its node goes offline. Sessions already created keep the exchange
+// they were created with. The previous exchange isn't closed. The fallback of
+// the blockservice is only used while ex is online. It returns false if bs
+// wasn't created by this package.
+func SetExchange(bs BlockService, ex exchange.Interface) bool {
+	s, ok := bs.(*blockService)
+	if !ok {
+		return false
+	}
+	s.exLk.Lock()
+	defer s.exLk.Unlock()
+	s.exchange = ex
+	s.fallbackOff = !ex.IsOnline()
+	return true
+}
+
+// SessionOption configures a Session created by NewSession.
+type SessionOption func(*sessionSettings)
+
+type sessionSettings struct {
+	providers []peer.ID
+	fetch     FetchOptions
+	priority  *exchange.Priority
+}
+
+// WithPriority makes the session's requests use priority p, e.g.
+// exchange.PriorityInteractive for requests a user is waiting on.
+func WithPriority(p exchange.Priority) SessionOption {
+	return func(s *sessionSettings) {
+		s.priority = &p
+	}
+}
+
+// WithProviders hints the session with peers that are already known to
+// provide the requested blocks (e.g. after resolving an IPNS name). When the
+// exchange supports it, wants are sent to these peers directly instead of
+// waiting for a provider search.
+func WithProviders(peers []peer.ID) SessionOption {
+	return func(s *sessionSettings) {
+		s.providers = append(s.providers, peers...)
+	}
+}
+
+// ExchangeStats returns the per-peer accounting of the exchange behind the
+// given blockservice. ok is false if the exchange doesn't keep any.
+func ExchangeStats(bs BlockService) (st exchange.Stats, ok bool) {
+	st, ok = bs.Exchange().(exchange.Stats)
+	return st, ok
+}
+
 // NewSession creates a new session that allows for
 // controlled exchange of wantlists to decrease the bandwidth overhead.
 // If the current exchange is a SessionExchange, a new exchange
 // session will be created. Otherwise, the current exchange will be used
 // directly.
-func NewSession(ctx context.Context, bs BlockService) *Session {
+func NewSession(ctx context.Context, bs BlockService, opts ...SessionOption) *Session {
+	settings := new(sessionSettings)
+	for _, opt := range opts {
+		opt(settings)
+	}
+
+	if settings.priority != nil {
+		ctx = exchange.WithPriority(ctx, *settings.priority)
+	}
+
+	var ses exchange.Fetcher
 	exch := bs.Exchange()
-	if sessEx, ok := exch.(exchange.SessionExchange); ok {
-		ses := sessEx.NewSession(ctx)
-		return &Session{
-			ses: ses,
-			bs:  bs.Blockstore(),
-		}
+	if hintEx, ok := exch.(exchange.HintedSessionExchange); ok && len(settings.providers) > 0 {
+		ses = hintEx.NewSessionWithProviders(ctx, settings.providers)
+	} else if sessEx, ok := exch.(exchange.SessionExchange); ok {
+		ses = sessEx.NewSession(ctx)
+	} else {
+		ses = exch
+	}
+
+	var sched *Scheduler
+	if s, ok := bs.(*blockService); ok {
+		ses = s.withFallback(ses)
+		sched = s.sched
 	}
 	return &Session{
-		ses: exch,
-		bs:  bs.Blockstore(),
+		ses:      ses,
+		bs:       bs.Blockstore(),
+		fetch:    settings.fetch,
+		sched:    sched,
+		priority: settings.priority,
 	}
 }
 
@@ -136,6 +264,9 @@ func (s *blockService) AddBlock(o blocks.Block) error {
 	if err != nil {
 		return err
 	}
+	if _, ok := identityBlock(c); ok {
+		return nil
+	}
 	if s.checkFirst {
 		if has, err := s.blockstore.Has(c); has || err != nil {
 			return err
@@ -146,7 +277,7 @@ func (s *blockService) AddBlock(o blocks.Block) error {
 		return err
 	}
 
-	if err := s.exchange.HasBlock(o); err != nil {
+	if err := s.Exchange().HasBlock(o); err != nil {
 		// TODO(#4623): really an error?
 		return errors.New("blockservice is closed")
 	}
@@ -162,6 +293,7 @@ func (s *blockService) AddBlocks(bs []blocks.Block) error {
 			return err
 		}
 	}
+	bs = withoutIdentity(bs)
 	var toput []blocks.Block
 	if s.checkFirst {
 		toput = make([]blocks.Block, 0, len(bs))
@@ -183,13 +315,24 @@ func (s *blockService) AddBlocks(bs []blocks.Block) error {
 		return err
 	}
 
+	// Keep announcing the remaining blocks when one fails, so that callers
+	// know exactly which announcements are missing.
+	var aerr AnnounceError
+	exch := s.Exchange()
 	for _, o := range toput {
-		if err := s.exchange.HasBlock(o); err != nil {
-			// TODO(#4623): Should this really *return*?
-			return fmt.Errorf("blockservice is closed (%s)", err)
+		if err := exch.HasBlock(o); err != nil {
+			aerr.Cids = append(aerr.Cids, o.Cid())
+			aerr.Errors = append(aerr.Errors, err)
 		}
 	}
-	return nil
+	if len(aerr.Cids) == 0 {
+		return nil
+	}
+	if s.announceWarnOnly {
+		log.Warning(aerr.Error())
+		return nil
+	}
+	return &aerr
 }
 
 // GetBlock retrieves a particular block from the service,
@@ -198,18 +341,31 @@ func (s *blockService) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block,
 	log.Debugf("BlockService GetBlock: '%s'", c)
 
 	var f exchange.Fetcher
-	if s.exchange != nil {
-		f = s.exchange
+	if exch := s.Exchange(); exch != nil {
+		f = s.withFallback(exch)
 	}
 
 	return getBlock(ctx, c, s.blockstore, f) // hash security
 }
 
-func getBlock(ctx context.Context, c *cid.Cid, bs blockstore.Blockstore, f exchange.Fetcher) (blocks.Block, error) {
-	err := verifcid.ValidateCid(c) // hash security
+func getBlock(ctx context.Context, c *cid.Cid, bs blockstore.Blockstore, f exchange.Fetcher) (blk blocks.Block, err error) {
+	ctx, span := tracing.StartSpan(ctx, "BlockService.GetBlock")
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+	span.SetAttribute("cid", c.String())
+
+	err = verifcid.ValidateCid(c) // hash security
 	if err != nil {
 		return nil, err
 	}
+	if block, ok := identityBlock(c); ok {
+		return block, nil
+	}
+	if spend(ctx, 1) == 0 {
+		return nil, ErrBudgetExceeded
+	}
 
 	block, err := bs.Get(c)
 	if err == nil {
@@ -220,6 +376,7 @@ func getBlock(ctx context.Context, c *cid.Cid, bs blockstore.Blockstore, f excha
 		// TODO be careful checking ErrNotFound. If the underlying
 		// implementation changes, this will break.
 		log.Debug("Blockservice: Searching bitswap")
+		span.SetAttribute("fetched", true)
 		blk, err := f.GetBlock(ctx, c)
 		if err != nil {
 			if err == blockstore.ErrNotFound {
@@ -242,10 +399,10 @@ func getBlock(ctx context.Context, c *cid.Cid, bs blockstore.Blockstore, f excha
 // the returned channel.
 // NB: No guarantees are made about order.
 func (s *blockService) GetBlocks(ctx context.Context, ks []*cid.Cid) <-chan blocks.Block {
-	return getBlocks(ctx, ks, s.blockstore, s.exchange) // hash security
+	return getBlocks(ctx, ks, s.blockstore, s.withFallback(s.Exchange()), FetchOptions{}, s.sched) // hash security
 }
 
-func getBlocks(ctx context.Context, ks []*cid.Cid, bs blockstore.Blockstore, f exchange.Fetcher) <-chan blocks.Block {
+func getBlocks(ctx context.Context, ks []*cid.Cid, bs blockstore.Blockstore, f exchange.Fetcher, opts FetchOptions, sched *Scheduler) <-chan blocks.Block {
 	out := make(chan blocks.Block)
 	for _, c := range ks {
 		// hash security
@@ -254,16 +411,28 @@ func getBlocks(ctx context.Context, ks []*cid.Cid, bs blockstore.Blockstore, f e
 		}
 	}
 
+	ctx, span := tracing.StartSpan(ctx, "BlockService.GetBlocks")
+	span.SetAttribute("blocks", len(ks))
+
 	go func() {
+		defer span.Finish()
 		defer close(out)
 		var misses []*cid.Cid
 		for _, c := range ks {
-			hit, err := bs.Get(c)
-			if err != nil {
-				misses = append(misses, c)
-				continue
+			hit, ok := identityBlock(c)
+			if !ok {
+				if spend(ctx, 1) == 0 {
+					log.Debug("Blockservice: block budget exceeded")
+					break
+				}
+				var err error
+				hit, err = bs.Get(c)
+				if err != nil {
+					misses = append(misses, c)
+					continue
+				}
+				log.Debug("Blockservice: Got data in datastore")
 			}
-			log.Debug("Blockservice: Got data in datastore")
 			select {
 			case out <- hit:
 			case <-ctx.Done():
@@ -271,27 +440,59 @@ func getBlocks(ctx context.Context, ks []*cid.Cid, bs blockstore.Blockstore, f e
 			}
 		}
 
+		span.SetAttribute("misses", len(misses))
 		if len(misses) == 0 {
 			return
 		}
 
-		rblocks, err := f.GetBlocks(ctx, misses)
-		if err != nil {
-			log.Debugf("Error with GetBlocks: %s", err)
-			return
-		}
+		// Make sure the exchange drops our wants once we stop listening,
+		// whether we're done or the consumer went away.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
 
-		for b := range rblocks {
-			select {
-			case out <- b:
-			case <-ctx.Done():
-				return
+		if pc, ok := f.(exchange.PresenceChecker); ok {
+			if pa, ok := f.(exchange.ProviderAdder); ok {
+				go prioritizeHavers(ctx, pc, pa, misses)
 			}
 		}
+
+		remaining := cid.NewSet()
+		for _, c := range misses {
+			remaining.Add(c)
+		}
+		if wc, ok := f.(exchange.WantCanceler); ok {
+			defer func() {
+				if remaining.Len() > 0 {
+					wc.CancelWants(remaining.Keys())
+				}
+			}()
+		}
+
+		fetchMisses(ctx, f, misses, opts, sched, remaining, out)
 	}()
 	return out
 }
 
+// prioritizeHavers asks peers which of them have the given blocks and points
+// the fetcher at the ones confirming presence, so that its wants stop being
+// broadcast to everyone and fewer duplicate blocks are received.
+func prioritizeHavers(ctx context.Context, pc exchange.PresenceChecker, pa exchange.ProviderAdder, ks []*cid.Cid) {
+	haves, err := pc.FindHaves(ctx, ks)
+	if err != nil {
+		log.Debugf("Error with FindHaves: %s", err)
+		return
+	}
+
+	seen := make(map[peer.ID]struct{})
+	for h := range haves {
+		if _, ok := seen[h.Peer]; ok {
+			continue
+		}
+		seen[h.Peer] = struct{}{}
+		pa.AddProviders([]peer.ID{h.Peer})
+	}
+}
+
 // DeleteBlock deletes a block in the blockservice from the datastore
 func (s *blockService) DeleteBlock(c *cid.Cid) error {
 	return s.blockstore.DeleteBlock(c)
@@ -299,23 +500,44 @@ func (s *blockService) DeleteBlock(c *cid.Cid) error {
 
 func (s *blockService) Close() error {
 	log.Debug("blockservice is shutting down...")
-	return s.exchange.Close()
+	return s.Exchange().Close()
 }
 
 // Session is a helper type to provide higher level access to bitswap sessions
 type Session struct {
-	bs  blockstore.Blockstore
-	ses exchange.Fetcher
+	bs    blockstore.Blockstore
+	ses   exchange.Fetcher
+	fetch FetchOptions
+	sched *Scheduler
+
+	// priority, if set, overrides the priority of the requests' contexts
+	priority *exchange.Priority
+}
+
+func (s *Session) withPriority(ctx context.Context) context.Context {
+	if s.priority == nil {
+		return ctx
+	}
+	return exchange.WithPriority(ctx, *s.priority)
 }
 
 // GetBlock gets a block in the context of a request session
 func (s *Session) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
-	return getBlock(ctx, c, s.bs, s.ses) // hash security
+	return getBlock(s.withPriority(ctx), c, s.bs, s.ses) // hash security
 }
 
 // GetBlocks gets blocks in the context of a request session
 func (s *Session) GetBlocks(ctx context.Context, ks []*cid.Cid) <-chan blocks.Block {
-	return getBlocks(ctx, ks, s.bs, s.ses) // hash security
+	return getBlocks(s.withPriority(ctx), ks, s.bs, s.ses, s.fetch, s.sched) // hash security
+}
+
+// Wantlist returns the blocks this session is still waiting for. It returns
+// nil if the underlying exchange cannot report its wantlist.
+func (s *Session) Wantlist() []*cid.Cid {
+	if wl, ok := s.ses.(exchange.Wantlister); ok {
+		return wl.Wantlist()
+	}
+	return nil
 }
 
 var _ BlockGetter = (*Session)(nil)
@@ -1,15 +1,21 @@
 package blockservice
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	butil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
 
 	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	peer "github.com/libp2p/go-libp2p-peer"
+	mh "github.com/multiformats/go-multihash"
 )
 
 func TestWriteThroughWorks(t *testing.T) {
@@ -47,3 +53,197 @@ func (bs *PutCountingBlockstore) Put(block blocks.Block) error {
 	bs.PutCounter++
 	return bs.Blockstore.Put(block)
 }
+
+func TestSessionWithProviders(t *testing.T) {
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	exch := &hintRecordingExchange{Interface: offline.Exchange(bstore)}
+	bserv := New(bstore, exch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	NewSession(ctx, bserv)
+	if exch.hinted != nil {
+		t.Fatal("session without providers should not be hinted")
+	}
+
+	provs := []peer.ID{"QmPeerA", "QmPeerB"}
+	NewSession(ctx, bserv, WithProviders(provs))
+	if len(exch.hinted) != len(provs) {
+		t.Fatalf("expected %d hinted providers, got %d", len(provs), len(exch.hinted))
+	}
+	for i, p := range provs {
+		if exch.hinted[i] != p {
+			t.Fatalf("expected provider %s, got %s", p, exch.hinted[i])
+		}
+	}
+}
+
+type hintRecordingExchange struct {
+	exchange.Interface
+	hinted []peer.ID
+}
+
+func (e *hintRecordingExchange) NewSessionWithProviders(ctx context.Context, provs []peer.ID) exchange.Fetcher {
+	e.hinted = provs
+	return e.Interface
+}
+
+type failingAnnounceExchange struct {
+	exchange.Interface
+	fail      map[string]bool
+	announced int
+}
+
+func (e *failingAnnounceExchange) HasBlock(b blocks.Block) error {
+	if e.fail[b.Cid().KeyString()] {
+		return errors.New("announce failed")
+	}
+	e.announced++
+	return nil
+}
+
+func TestAddBlocksPartialAnnounceFailure(t *testing.T) {
+	bgen := butil.NewBlockGenerator()
+	blks := bgen.Blocks(4)
+
+	newExchange := func(bstore blockstore.Blockstore) *failingAnnounceExchange {
+		return &failingAnnounceExchange{
+			Interface: offline.Exchange(bstore),
+			fail:      map[string]bool{blks[1].Cid().KeyString(): true},
+		}
+	}
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	exch := newExchange(bstore)
+	err := New(bstore, exch).AddBlocks(blks)
+	aerr, ok := err.(*AnnounceError)
+	if !ok {
+		t.Fatalf("expected an AnnounceError, got %v", err)
+	}
+	if len(aerr.Cids) != 1 || !aerr.Cids[0].Equals(blks[1].Cid()) {
+		t.Fatal("AnnounceError should list exactly the failed block")
+	}
+	if exch.announced != 3 {
+		t.Fatalf("expected the other 3 blocks to be announced, got %d", exch.announced)
+	}
+	for _, b := range blks {
+		if has, _ := bstore.Has(b.Cid()); !has {
+			t.Fatal("all blocks should have been stored")
+		}
+	}
+
+	bstore = blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	exch = newExchange(bstore)
+	if err := New(bstore, exch, AnnounceFailuresAsWarnings()).AddBlocks(blks); err != nil {
+		t.Fatalf("expected announce failures to be ignored, got %s", err)
+	}
+}
+
+func TestIdentityBlocks(t *testing.T) {
+	bstore := &PutCountingBlockstore{
+		blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore())),
+		0,
+	}
+	bserv := New(bstore, offline.Exchange(bstore))
+
+	data := []byte("inlined")
+	c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.ID, MhLength: -1}.Sum(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bserv.AddBlock(blk); err != nil {
+		t.Fatal(err)
+	}
+	if err := bserv.AddBlocks([]blocks.Block{blk}); err != nil {
+		t.Fatal(err)
+	}
+	if bstore.PutCounter != 0 {
+		t.Fatalf("expected identity blocks not to be stored, got %d puts", bstore.PutCounter)
+	}
+
+	out, err := bserv.GetBlock(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out.RawData()) != string(data) {
+		t.Fatalf("expected %q, got %q", data, out.RawData())
+	}
+
+	got := 0
+	for b := range bserv.GetBlocks(context.Background(), []*cid.Cid{c}) {
+		if !b.Cid().Equals(c) {
+			t.Fatalf("unexpected block %s", b.Cid())
+		}
+		got++
+	}
+	if got != 1 {
+		t.Fatalf("expected one block, got %d", got)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bserv := New(bstore, offline.Exchange(bstore))
+	bgen := butil.NewBlockGenerator()
+	blks := bgen.Blocks(4)
+	if err := bserv.AddBlocks(blks); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithBudget(context.Background(), 3)
+	if _, err := bserv.GetBlock(ctx, blks[0].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	var ks []*cid.Cid
+	for _, b := range blks {
+		ks = append(ks, b.Cid())
+	}
+	n := 0
+	for range bserv.GetBlocks(ctx, ks) {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected the 2 blocks left in the budget, got %d", n)
+	}
+
+	if _, err := bserv.GetBlock(ctx, blks[0].Cid()); err != ErrBudgetExceeded {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if _, err := bserv.GetBlock(context.Background(), blks[0].Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetExchange(t *testing.T) {
+	local := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bs := New(local, offline.Exchange(local))
+
+	b := blocks.NewBlock([]byte("only on the network"))
+	if err := remote.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := bs.GetBlock(ctx, b.Cid()); err == nil {
+		t.Fatal("expected the block not to be found")
+	}
+
+	ex := offline.Exchange(remote)
+	if !SetExchange(bs, ex) {
+		t.Fatal("expected the exchange to be set")
+	}
+	if bs.Exchange() != ex {
+		t.Fatal("expected the new exchange to be returned")
+	}
+	if _, err := bs.GetBlock(ctx, b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package blockservice
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrBudgetExceeded is returned when reading more blocks than the budget of
+// the context allows.
+var ErrBudgetExceeded = errors.New("block budget exceeded")
+
+type budgetKey struct{}
+
+// WithBudget returns a context allowing at most n blocks to be read with it,
+// from the blockstore or the exchange, so that a request can't traverse a DAG
+// of any size. Reading a block past the budget fails with ErrBudgetExceeded,
+// and GetBlocks stops returning blocks.
+func WithBudget(ctx context.Context, n int64) context.Context {
+	left := n
+	return context.WithValue(ctx, budgetKey{}, &left)
+}
+
+// spend takes n blocks from the budget of ctx and returns how many of them
+// it allows.
+func spend(ctx context.Context, n int) int {
+	left, ok := ctx.Value(budgetKey{}).(*int64)
+	if !ok {
+		return n
+	}
+	for {
+		l := atomic.LoadInt64(left)
+		allowed := int64(n)
+		if l < allowed {
+			allowed = l
+		}
+		if allowed <= 0 {
+			return 0
+		}
+		if atomic.CompareAndSwapInt64(left, l, l-allowed) {
+			return int(allowed)
+		}
+	}
+}
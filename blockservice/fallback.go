@@ -0,0 +1,150 @@
+package blockservice
+
+import (
+	"context"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// NewWithFallback creates a BlockService which fetches blocks through the
+// given exchange, and falls back to the given fetcher (e.g. an HTTP gateway
+// exchange) for blocks the exchange couldn't provide within delay.
+func NewWithFallback(bs blockstore.Blockstore, rem exchange.Interface, fallback exchange.Fetcher, delay time.Duration, opts ...Option) BlockService {
+	s := New(bs, rem, opts...).(*blockService)
+	s.fallback = fallback
+	s.fallbackDelay = delay
+	return s
+}
+
+// withFallback wraps the given fetcher with the fallback configured on this
+// blockservice, if any.
+func (s *blockService) withFallback(f exchange.Fetcher) exchange.Fetcher {
+	s.exLk.RLock()
+	disabled := s.fallbackOff
+	s.exLk.RUnlock()
+	if s.fallback == nil || disabled {
+		return f
+	}
+	return &fallbackFetcher{
+		primary:  f,
+		fallback: s.fallback,
+		delay:    s.fallbackDelay,
+	}
+}
+
+// fallbackFetcher asks the primary fetcher first, and hands the blocks it
+// hasn't delivered to the fallback fetcher once the primary gives up or the
+// delay expires.
+type fallbackFetcher struct {
+	primary  exchange.Fetcher
+	fallback exchange.Fetcher
+	delay    time.Duration
+}
+
+func (f *fallbackFetcher) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch, err := f.GetBlocks(ctx, []*cid.Cid{c})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case blk, ok := <-ch:
+		if !ok {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, blockstore.ErrNotFound
+		}
+		return blk, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *fallbackFetcher) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	primary, err := f.primary.GetBlocks(ctx, ks)
+	if err != nil {
+		log.Debugf("primary GetBlocks failed, using fallback: %s", err)
+		primary = nil
+	}
+
+	remaining := cid.NewSet()
+	for _, c := range ks {
+		remaining.Add(c)
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer cancel()
+		defer close(out)
+
+		timer := time.NewTimer(f.delay)
+		defer timer.Stop()
+
+		var secondary <-chan blocks.Block
+		started := false
+		startFallback := func() {
+			if started {
+				return
+			}
+			started = true
+
+			fb, err := f.fallback.GetBlocks(ctx, remaining.Keys())
+			if err != nil {
+				log.Debugf("fallback GetBlocks failed: %s", err)
+				return
+			}
+			secondary = fb
+		}
+
+		if primary == nil {
+			startFallback()
+		}
+
+		for remaining.Len() > 0 && (primary != nil || secondary != nil || !started) {
+			var blk blocks.Block
+			select {
+			case b, ok := <-primary:
+				if !ok {
+					primary = nil
+					startFallback()
+					continue
+				}
+				blk = b
+			case b, ok := <-secondary:
+				if !ok {
+					secondary = nil
+					continue
+				}
+				blk = b
+			case <-timer.C:
+				startFallback()
+				continue
+			case <-ctx.Done():
+				return
+			}
+
+			if !remaining.Has(blk.Cid()) {
+				continue
+			}
+			remaining.Remove(blk.Cid())
+
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package blockservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	butil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestFallbackFetchesMisses(t *testing.T) {
+	local := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+
+	bgen := butil.NewBlockGenerator()
+	blks := bgen.Blocks(10)
+	if err := remote.PutMany(blks[5:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.PutMany(blks[:5]); err != nil {
+		t.Fatal(err)
+	}
+
+	// the primary exchange never finds anything
+	empty := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bs := NewWithFallback(local, offline.Exchange(empty), offline.Exchange(remote), time.Millisecond*10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if _, err := bs.GetBlock(ctx, blks[7].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	var ks []*cid.Cid
+	for _, b := range blks {
+		ks = append(ks, b.Cid())
+	}
+
+	got := cid.NewSet()
+	for b := range bs.GetBlocks(ctx, ks) {
+		got.Add(b.Cid())
+	}
+	if got.Len() != len(blks) {
+		t.Fatalf("expected %d blocks, got %d", len(blks), got.Len())
+	}
+}
@@ -0,0 +1,99 @@
+package blockservice
+
+import (
+	"context"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// FetchDAG makes sure all blocks of the DAG under root matched by sel are
+// available locally. If the exchange is a DAGFetcher, the DAG is requested in
+// a single negotiation first. Whatever is still missing afterwards is fetched
+// block by block through a session.
+func FetchDAG(ctx context.Context, bs BlockService, root *cid.Cid, sel exchange.Selector) error {
+	if df, ok := bs.Exchange().(exchange.DAGFetcher); ok {
+		if err := fetchDAGBulk(ctx, bs, df, root, sel); err != nil {
+			log.Debugf("bulk fetch of %s failed, falling back: %s", root, err)
+		}
+	}
+
+	return walkDAG(ctx, NewSession(ctx, bs), root, sel)
+}
+
+func fetchDAGBulk(ctx context.Context, bs BlockService, df exchange.DAGFetcher, root *cid.Cid, sel exchange.Selector) error {
+	ch, err := df.FetchDAG(ctx, root, sel)
+	if err != nil {
+		return err
+	}
+
+	for blk := range ch {
+		// hash security
+		if err := verifyBlock(blk); err != nil {
+			log.Warningf("dropping bad block %s from DAG fetch: %s", blk.Cid(), err)
+			continue
+		}
+		if err := bs.Blockstore().Put(blk); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func verifyBlock(blk blocks.Block) error {
+	c := blk.Cid()
+	if err := verifcid.ValidateCid(c); err != nil {
+		return err
+	}
+
+	chk, err := c.Prefix().Sum(blk.RawData())
+	if err != nil {
+		return err
+	}
+	if !chk.Equals(c) {
+		return blocks.ErrWrongHash
+	}
+	return nil
+}
+
+// walkDAG fetches the selected DAG breadth first, requesting one level at a
+// time so that the fetcher can batch the wants.
+func walkDAG(ctx context.Context, bg BlockGetter, root *cid.Cid, sel exchange.Selector) error {
+	seen := cid.NewSet()
+	seen.Add(root)
+
+	level := []*cid.Cid{root}
+	for depth := 0; len(level) > 0; depth++ {
+		var next []*cid.Cid
+		got := 0
+		for blk := range bg.GetBlocks(ctx, level) {
+			got++
+			if sel.MaxDepth >= 0 && depth >= sel.MaxDepth {
+				continue
+			}
+
+			nd, err := ipld.Decode(blk)
+			if err != nil {
+				return err
+			}
+			for _, l := range nd.Links() {
+				if seen.Visit(l.Cid) {
+					next = append(next, l.Cid)
+				}
+			}
+		}
+
+		if got != len(level) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return ErrNotFound
+		}
+		level = next
+	}
+	return nil
+}
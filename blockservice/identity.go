@@ -0,0 +1,36 @@
+package blockservice
+
+import (
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// identityBlock returns the block of c if c inlines its data with the
+// identity hash. Such blocks are never stored nor fetched.
+func identityBlock(c *cid.Cid) (blocks.Block, bool) {
+	if c.Prefix().MhType != mh.ID {
+		return nil, false
+	}
+	dmh, err := mh.Decode(c.Hash())
+	if err != nil {
+		return nil, false
+	}
+	b, err := blocks.NewBlockWithCid(dmh.Digest, c)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// withoutIdentity returns the blocks of bs which don't inline their data in
+// their CID.
+func withoutIdentity(bs []blocks.Block) []blocks.Block {
+	out := make([]blocks.Block, 0, len(bs))
+	for _, b := range bs {
+		if b.Cid().Prefix().MhType != mh.ID {
+			out = append(out, b)
+		}
+	}
+	return out
+}
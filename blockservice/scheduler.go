@@ -0,0 +1,160 @@
+package blockservice
+
+import (
+	"context"
+	"sync"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+)
+
+// DefaultMaxInFlightWants is the default number of wants a Scheduler lets
+// through at once.
+const DefaultMaxInFlightWants = 256
+
+// DefaultPriorityWeights are the default scheduler weights, indexed by
+// exchange.Priority.
+var DefaultPriorityWeights = [exchange.NumPriorities]int{
+	exchange.PriorityBackground:  1,
+	exchange.PriorityNormal:      4,
+	exchange.PriorityInteractive: 16,
+}
+
+// Scheduler bounds the number of wants sent to the exchange for blocks
+// missing locally. When requests have to queue, it lets priority classes
+// through in proportion to their weights, so that interactive requests
+// outrank background work without starving it.
+type Scheduler struct {
+	lk       sync.Mutex
+	max      int
+	inflight int
+	weights  [exchange.NumPriorities]int
+	current  [exchange.NumPriorities]int
+	queues   [exchange.NumPriorities][]*schedWaiter
+}
+
+type schedWaiter struct {
+	n     int
+	ready chan struct{}
+}
+
+// NewScheduler creates a scheduler letting at most max wants through at once,
+// sharing them between priority classes according to weights.
+func NewScheduler(max int, weights [exchange.NumPriorities]int) *Scheduler {
+	return &Scheduler{
+		max:     max,
+		weights: weights,
+	}
+}
+
+// WithScheduler makes the blockservice and its sessions request missing
+// blocks through s.
+func WithScheduler(s *Scheduler) Option {
+	return func(bs *blockService) {
+		bs.sched = s
+	}
+}
+
+func (s *Scheduler) fitsLocked(n int) bool {
+	return s.inflight == 0 || s.inflight+n <= s.max
+}
+
+func (s *Scheduler) queuedLocked() bool {
+	for _, q := range s.queues {
+		if len(q) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire waits until n wants of class p may be sent. A request larger than
+// the limit is let through once nothing else is in flight.
+func (s *Scheduler) acquire(ctx context.Context, p exchange.Priority, n int) error {
+	if s == nil {
+		return nil
+	}
+
+	s.lk.Lock()
+	if !s.queuedLocked() && s.fitsLocked(n) {
+		s.inflight += n
+		s.lk.Unlock()
+		return nil
+	}
+	w := &schedWaiter{n: n, ready: make(chan struct{})}
+	s.queues[p] = append(s.queues[p], w)
+	s.lk.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	select {
+	case <-w.ready:
+		// granted while we were giving up
+		s.inflight -= n
+		s.dispatchLocked()
+	default:
+		q := s.queues[p]
+		for i, qw := range q {
+			if qw == w {
+				s.queues[p] = append(q[:i], q[i+1:]...)
+				break
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// release returns n wants acquired earlier.
+func (s *Scheduler) release(n int) {
+	if s == nil || n == 0 {
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.inflight -= n
+	s.dispatchLocked()
+}
+
+// dispatchLocked grants queued requests while they fit, picking classes by
+// smooth weighted round robin.
+func (s *Scheduler) dispatchLocked() {
+	for {
+		total := 0
+		best := -1
+		for c, q := range s.queues {
+			if len(q) == 0 {
+				continue
+			}
+			s.current[c] += s.weights[c]
+			total += s.weights[c]
+			if best < 0 || s.current[c] > s.current[best] {
+				best = c
+			}
+		}
+		if best < 0 {
+			return
+		}
+
+		w := s.queues[best][0]
+		if !s.fitsLocked(w.n) {
+			// undo this round, the head waits for more capacity
+			for c, q := range s.queues {
+				if len(q) > 0 {
+					s.current[c] -= s.weights[c]
+				}
+			}
+			return
+		}
+
+		s.current[best] -= total
+		s.queues[best] = s.queues[best][1:]
+		s.inflight += w.n
+		close(w.ready)
+	}
+}
@@ -0,0 +1,82 @@
+package blockservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+)
+
+func TestSchedulerWeightedFairness(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var weights [exchange.NumPriorities]int
+	weights[exchange.PriorityBackground] = 1
+	weights[exchange.PriorityInteractive] = 2
+	s := NewScheduler(1, weights)
+
+	// occupy the only slot so that everything else queues
+	if err := s.acquire(ctx, exchange.PriorityNormal, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	const perClass = 6
+	granted := make(chan exchange.Priority)
+	for _, p := range []exchange.Priority{exchange.PriorityBackground, exchange.PriorityInteractive} {
+		for i := 0; i < perClass; i++ {
+			go func(p exchange.Priority) {
+				if err := s.acquire(ctx, p, 1); err != nil {
+					t.Error(err)
+					return
+				}
+				granted <- p
+			}(p)
+		}
+	}
+
+	for {
+		s.lk.Lock()
+		n := len(s.queues[exchange.PriorityBackground]) + len(s.queues[exchange.PriorityInteractive])
+		s.lk.Unlock()
+		if n == 2*perClass {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	counts := make(map[exchange.Priority]int)
+	for i := 0; i < perClass; i++ {
+		s.release(1)
+		select {
+		case p := <-granted:
+			counts[p]++
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for a grant")
+		}
+	}
+
+	if counts[exchange.PriorityInteractive] != 4 || counts[exchange.PriorityBackground] != 2 {
+		t.Fatalf("expected a 2:1 split, got %d interactive and %d background",
+			counts[exchange.PriorityInteractive], counts[exchange.PriorityBackground])
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := NewScheduler(1, DefaultPriorityWeights)
+	if err := s.acquire(context.Background(), exchange.PriorityNormal, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.acquire(ctx, exchange.PriorityNormal, 1); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	s.release(1)
+	if s.inflight != 0 || s.queuedLocked() {
+		t.Fatal("cancelled request should not hold or wait for a slot")
+	}
+}
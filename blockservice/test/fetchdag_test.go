@@ -0,0 +1,129 @@
+package bstest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/ipfs/go-ipfs/blockservice"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// bulkExchange serves FetchDAG requests with a fixed set of blocks, and
+// single block requests from a remote blockstore.
+type bulkExchange struct {
+	exchange.Interface
+	bulk  []blocks.Block
+	calls int
+}
+
+func (e *bulkExchange) FetchDAG(ctx context.Context, root *cid.Cid, sel exchange.Selector) (<-chan blocks.Block, error) {
+	e.calls++
+	out := make(chan blocks.Block, len(e.bulk))
+	for _, b := range e.bulk {
+		out <- b
+	}
+	close(out)
+	return out, nil
+}
+
+// makeTree builds a root with two children, each with two leaves, and
+// returns the nodes by level.
+func makeTree(t *testing.T) [][]*dag.ProtoNode {
+	var leaves []*dag.ProtoNode
+	var mids []*dag.ProtoNode
+	for i := 0; i < 2; i++ {
+		mid := dag.NodeWithData([]byte{byte(i)})
+		for j := 0; j < 2; j++ {
+			leaf := dag.NodeWithData([]byte{byte(i), byte(j)})
+			if err := mid.AddNodeLink(fmt.Sprintf("leaf%d", j), leaf); err != nil {
+				t.Fatal(err)
+			}
+			leaves = append(leaves, leaf)
+		}
+		mids = append(mids, mid)
+	}
+
+	root := dag.NodeWithData([]byte("root"))
+	for i, mid := range mids {
+		if err := root.AddNodeLink(fmt.Sprintf("mid%d", i), mid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return [][]*dag.ProtoNode{{root}, mids, leaves}
+}
+
+func TestFetchDAGPrefersBulk(t *testing.T) {
+	levels := makeTree(t)
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	local := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+
+	var all []blocks.Block
+	for _, lvl := range levels {
+		for _, nd := range lvl {
+			all = append(all, nd)
+		}
+	}
+	if err := remote.PutMany(all); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bulk fetch only returns part of the DAG, the rest has to come
+	// through regular block requests
+	exch := &bulkExchange{
+		Interface: offline.Exchange(remote),
+		bulk:      all[:3],
+	}
+	bs := New(local, exch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	root := levels[0][0].Cid()
+	if err := FetchDAG(ctx, bs, root, exchange.SelectAll); err != nil {
+		t.Fatal(err)
+	}
+	if exch.calls != 1 {
+		t.Fatal("expected the bulk fetch to be used")
+	}
+	for _, b := range all[:3] {
+		if has, _ := local.Has(b.Cid()); !has {
+			t.Fatalf("bulk fetched block %s was not stored", b.Cid())
+		}
+	}
+}
+
+func TestFetchDAGMaxDepth(t *testing.T) {
+	levels := makeTree(t)
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+
+	// leave out the leaves
+	for _, lvl := range levels[:2] {
+		for _, nd := range lvl {
+			if err := remote.Put(nd); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	bs := New(remote, offline.Exchange(remote))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	root := levels[0][0].Cid()
+	if err := FetchDAG(ctx, bs, root, exchange.Selector{MaxDepth: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := FetchDAG(ctx, bs, root, exchange.SelectAll); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for incomplete DAG, got %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+package blockservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+)
+
+// ErrTxnDone is returned when using a transaction which was already
+// committed or aborted.
+var ErrTxnDone = errors.New("blockservice: transaction already done")
+
+// txnJournalKey is where transactions are journaled. Each transaction keeps
+// its entries under a key of its own, with the blocks it wrote under
+// "blocks" and, once committed, a "committed" marker.
+var txnJournalKey = ds.NewKey("/local/blocktxn")
+
+var txnCounter uint64
+
+// TxnGuard keeps rolling back a transaction from removing blocks other adds
+// still need. The zero TxnGuard doesn't protect anything.
+type TxnGuard struct {
+	// Locker is GC-locked while rolling back. Adds have to commit their
+	// transaction and pin its blocks under a PinLock of Locker, so that
+	// their pins are visible to Pinned when a rollback runs.
+	Locker blockstore.GCLocker
+
+	// Pinned returns the set of cids which are pinned, those are kept.
+	Pinned func(cids []*cid.Cid) (*cid.Set, error)
+}
+
+// claims counts the open transactions which added each block, keyed by
+// cid, whether they wrote it or found it stored already. A block claimed by
+// another transaction is never rolled back.
+var claims = struct {
+	sync.Mutex
+	m map[string]int
+}{m: make(map[string]int)}
+
+// Txn stages blocks so that they are either all kept or all removed.
+//
+// The datastores don't support transactions, so blocks are written right
+// away and recorded in a journal. Commit makes them permanent and announces
+// them on the exchange; Abort removes them again, except the ones pinned or
+// added by other open transactions meanwhile. Transactions interrupted by a
+// crash are rolled back by RecoverTxns.
+//
+// Txn implements BlockService, so a DAGService can be built on top of it to
+// import a whole DAG in one transaction. Reads see the staged blocks.
+type Txn struct {
+	bs      BlockService
+	journal ds.Datastore
+	guard   TxnGuard
+	key     ds.Key
+
+	lk        sync.Mutex
+	staged    []*cid.Cid
+	claimed   map[string]struct{}
+	done      bool
+	committed bool
+}
+
+var _ BlockService = (*Txn)(nil)
+
+// NewTxn starts a transaction adding blocks to bs, journaled in journal.
+// Rolling it back keeps the blocks guard protects.
+func NewTxn(bs BlockService, journal ds.Datastore, guard TxnGuard) *Txn {
+	id := fmt.Sprintf("%d-%d", time.Now().UnixNano(), atomic.AddUint64(&txnCounter, 1))
+	return &Txn{
+		bs:      bs,
+		journal: journal,
+		guard:   guard,
+		key:     txnJournalKey.ChildString(id),
+		claimed: make(map[string]struct{}),
+	}
+}
+
+// claim records that t added bs. t.lk must be held.
+func (t *Txn) claim(bs []blocks.Block) {
+	claims.Lock()
+	defer claims.Unlock()
+	for _, b := range bs {
+		k := b.Cid().KeyString()
+		if _, ok := t.claimed[k]; ok {
+			continue
+		}
+		t.claimed[k] = struct{}{}
+		claims.m[k]++
+	}
+}
+
+// release drops the claims of t. t.lk must be held.
+func (t *Txn) release() {
+	claims.Lock()
+	defer claims.Unlock()
+	for k := range t.claimed {
+		if claims.m[k]--; claims.m[k] <= 0 {
+			delete(claims.m, k)
+		}
+	}
+	t.claimed = nil
+}
+
+// claimedByOthers returns whether an open transaction other than t added c.
+// t.lk must be held.
+func (t *Txn) claimedByOthers(c *cid.Cid) bool {
+	claims.Lock()
+	defer claims.Unlock()
+	k := c.KeyString()
+	n := claims.m[k]
+	if _, ok := t.claimed[k]; ok {
+		n--
+	}
+	return n > 0
+}
+
+func (t *Txn) blockKey(c *cid.Cid) ds.Key {
+	return t.key.ChildString("blocks").Child(dshelp.CidToDsKey(c))
+}
+
+// AddBlock stages b.
+func (t *Txn) AddBlock(b blocks.Block) error {
+	return t.AddBlocks([]blocks.Block{b})
+}
+
+// AddBlocks stages bs. Blocks which are stored already are left alone, so
+// that rolling back never removes data the transaction didn't write. Once
+// committed, the transaction only accepts blocks stored already, e.g. the
+// root of the DAG being pinned.
+func (t *Txn) AddBlocks(bs []blocks.Block) error {
+	for _, b := range bs {
+		if err := verifcid.ValidateCid(b.Cid()); err != nil {
+			return err
+		}
+	}
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	if t.committed {
+		return t.checkStored(bs)
+	}
+	if t.done {
+		return ErrTxnDone
+	}
+
+	bs = withoutIdentity(bs)
+	// claimed before checking whether they are stored, so that a
+	// concurrent rollback of the transaction writing them keeps them
+	t.claim(bs)
+
+	bstore := t.bs.Blockstore()
+	var toput []blocks.Block
+	for _, b := range bs {
+		has, err := bstore.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			toput = append(toput, b)
+		}
+	}
+	if len(toput) == 0 {
+		return nil
+	}
+
+	// journal first, so that whatever gets written can be rolled back
+	if err := t.putJournal(toput); err != nil {
+		return err
+	}
+	if err := bstore.PutMany(toput); err != nil {
+		return err
+	}
+	for _, b := range toput {
+		t.staged = append(t.staged, b.Cid())
+	}
+	return nil
+}
+
+// checkStored returns ErrTxnDone unless all of bs are stored.
+func (t *Txn) checkStored(bs []blocks.Block) error {
+	for _, b := range withoutIdentity(bs) {
+		has, err := t.bs.Blockstore().Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrTxnDone
+		}
+	}
+	return nil
+}
+
+func (t *Txn) putJournal(bs []blocks.Block) error {
+	if b, ok := t.journal.(ds.Batching); ok {
+		batch, err := b.Batch()
+		if err != nil {
+			return err
+		}
+		for _, blk := range bs {
+			if err := batch.Put(t.blockKey(blk.Cid()), []byte{}); err != nil {
+				return err
+			}
+		}
+		return batch.Commit()
+	}
+
+	for _, blk := range bs {
+		if err := t.journal.Put(t.blockKey(blk.Cid()), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit makes the staged blocks permanent and announces them on the
+// exchange. Like AddBlocks, it returns an *AnnounceError if announcing some
+// of them failed; the blocks are committed nonetheless.
+func (t *Txn) Commit() error {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	if t.done {
+		return ErrTxnDone
+	}
+
+	if err := t.journal.Put(t.key.ChildString("committed"), []byte{}); err != nil {
+		return err
+	}
+	t.done = true
+	t.committed = true
+	defer t.release()
+
+	var aerr AnnounceError
+	if ex := t.bs.Exchange(); ex != nil {
+		for _, c := range t.staged {
+			b, err := t.bs.Blockstore().Get(c)
+			if err == nil {
+				err = ex.HasBlock(b)
+			}
+			if err != nil {
+				aerr.Cids = append(aerr.Cids, c)
+				aerr.Errors = append(aerr.Errors, err)
+			}
+		}
+	}
+
+	if err := clearTxn(t.journal, t.key); err != nil {
+		log.Warningf("clearing journal of committed transaction: %s", err)
+	}
+	if len(aerr.Cids) > 0 {
+		return &aerr
+	}
+	return nil
+}
+
+// Abort removes the staged blocks.
+func (t *Txn) Abort() error {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	if t.done {
+		return ErrTxnDone
+	}
+	t.done = true
+	defer t.release()
+
+	return rollback(t.bs.Blockstore(), t.journal, t.key, t.guard, t.claimedByOthers)
+}
+
+// GetBlock retrieves a block through the underlying blockservice.
+func (t *Txn) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	return t.bs.GetBlock(ctx, c)
+}
+
+// GetBlocks retrieves blocks through the underlying blockservice.
+func (t *Txn) GetBlocks(ctx context.Context, ks []*cid.Cid) <-chan blocks.Block {
+	return t.bs.GetBlocks(ctx, ks)
+}
+
+// Blockstore returns the underlying blockstore.
+func (t *Txn) Blockstore() blockstore.Blockstore {
+	return t.bs.Blockstore()
+}
+
+// Exchange returns the underlying exchange.
+func (t *Txn) Exchange() exchange.Interface {
+	return t.bs.Exchange()
+}
+
+// DeleteBlock deletes a block through the underlying blockservice.
+func (t *Txn) DeleteBlock(c *cid.Cid) error {
+	return t.bs.DeleteBlock(c)
+}
+
+// Close does nothing, end the transaction with Commit or Abort instead. The
+// underlying blockservice stays open.
+func (t *Txn) Close() error {
+	return nil
+}
+
+// rollback removes the blocks written by the transaction journaled under key,
+// and then its journal. Blocks pinned according to guard, or for which
+// claimed returns true, are kept.
+func rollback(bstore blockstore.Blockstore, journal ds.Datastore, key ds.Key, guard TxnGuard, claimed func(*cid.Cid) bool) error {
+	if guard.Locker != nil {
+		defer guard.Locker.GCLock().Unlock()
+	}
+
+	res, err := journal.Query(dsq.Query{Prefix: key.ChildString("blocks").String() + "/", KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	var written []*cid.Cid
+	for _, e := range entries {
+		c, err := dshelp.DsKeyToCid(ds.NewKey(ds.NewKey(e.Key).BaseNamespace()))
+		if err != nil {
+			log.Warningf("malformed transaction journal entry %s: %s", e.Key, err)
+			continue
+		}
+		if claimed != nil && claimed(c) {
+			continue
+		}
+		written = append(written, c)
+	}
+
+	pinned := cid.NewSet()
+	if guard.Pinned != nil && len(written) > 0 {
+		pinned, err = guard.Pinned(written)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range written {
+		if pinned.Has(c) {
+			continue
+		}
+		if err := bstore.DeleteBlock(c); err != nil && err != blockstore.ErrNotFound {
+			return err
+		}
+	}
+	return clearTxn(journal, key)
+}
+
+// clearTxn removes the journal of the transaction under key.
+func clearTxn(journal ds.Datastore, key ds.Key) error {
+	// the trailing slash keeps transaction 1 from matching transaction 10
+	res, err := journal.Query(dsq.Query{Prefix: key.String() + "/", KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := journal.Delete(ds.NewKey(e.Key)); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecoverTxns rolls back the transactions which were neither committed nor
+// aborted, e.g. because the node crashed, and finishes cleaning up committed
+// ones. Blocks guard protects are kept. It returns the number of
+// transactions rolled back. It must be run before any new transactions are
+// started.
+func RecoverTxns(bstore blockstore.Blockstore, journal ds.Datastore, guard TxnGuard) (int, error) {
+	res, err := journal.Query(dsq.Query{Prefix: txnJournalKey.String(), KeysOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return 0, err
+	}
+
+	txns := make(map[string]bool) // id -> committed
+	for _, e := range entries {
+		rel := strings.TrimPrefix(e.Key, txnJournalKey.String()+"/")
+		parts := strings.SplitN(rel, "/", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		txns[parts[0]] = txns[parts[0]] || parts[1] == "committed"
+	}
+
+	rolledBack := 0
+	for id, committed := range txns {
+		key := txnJournalKey.ChildString(id)
+		if committed {
+			if err := clearTxn(journal, key); err != nil {
+				return rolledBack, err
+			}
+			continue
+		}
+		if err := rollback(bstore, journal, key, guard, nil); err != nil {
+			return rolledBack, err
+		}
+		rolledBack++
+	}
+	return rolledBack, nil
+}
@@ -0,0 +1,185 @@
+package blockservice
+
+import (
+	"testing"
+
+	butil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func newTxnTestService() (BlockService, ds.Datastore) {
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	return New(bstore, offline.Exchange(bstore)), dssync.MutexWrap(ds.NewMapDatastore())
+}
+
+func journalSize(t *testing.T, journal ds.Datastore) int {
+	res, err := journal.Query(dsq.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestTxnCommitAndAbort(t *testing.T) {
+	bs, journal := newTxnTestService()
+	bgen := butil.NewBlockGenerator()
+
+	existing := bgen.Next()
+	if err := bs.AddBlock(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	committed := bgen.Next()
+	txn := NewTxn(bs, journal, TxnGuard{})
+	if err := txn.AddBlock(committed); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.AddBlock(bgen.Next()); err != ErrTxnDone {
+		t.Fatalf("expected ErrTxnDone, got %v", err)
+	}
+	if err := txn.AddBlock(committed); err != nil {
+		t.Fatal("re-adding a committed block:", err)
+	}
+
+	aborted := bgen.Next()
+	txn = NewTxn(bs, journal, TxnGuard{})
+	if err := txn.AddBlocks([]blocks.Block{existing, aborted}); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := bs.Blockstore().Has(aborted.Cid()); !has {
+		t.Fatal("staged block should be readable before commit")
+	}
+	if err := txn.Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		b    blocks.Block
+		want bool
+	}{
+		{"existing", existing, true},
+		{"committed", committed, true},
+		{"aborted", aborted, false},
+	} {
+		has, err := bs.Blockstore().Has(c.b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != c.want {
+			t.Errorf("%s block: expected has=%t", c.name, c.want)
+		}
+	}
+	if n := journalSize(t, journal); n != 0 {
+		t.Fatalf("expected an empty journal, got %d entries", n)
+	}
+}
+
+func TestRecoverTxns(t *testing.T) {
+	bs, journal := newTxnTestService()
+	bgen := butil.NewBlockGenerator()
+
+	// the node "crashes" before committing
+	interrupted := bgen.Next()
+	if err := NewTxn(bs, journal, TxnGuard{}).AddBlock(interrupted); err != nil {
+		t.Fatal(err)
+	}
+	committed := bgen.Next()
+	txn := NewTxn(bs, journal, TxnGuard{})
+	if err := txn.AddBlock(committed); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := RecoverTxns(bs.Blockstore(), journal, TxnGuard{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected one transaction to be rolled back, got %d", n)
+	}
+	if has, _ := bs.Blockstore().Has(interrupted.Cid()); has {
+		t.Fatal("block of the interrupted transaction should have been removed")
+	}
+	if has, _ := bs.Blockstore().Has(committed.Cid()); !has {
+		t.Fatal("committed block should have been kept")
+	}
+	if n := journalSize(t, journal); n != 0 {
+		t.Fatalf("expected an empty journal, got %d entries", n)
+	}
+}
+
+func TestTxnAbortKeepsSharedBlocks(t *testing.T) {
+	bs, journal := newTxnTestService()
+	bgen := butil.NewBlockGenerator()
+	shared := bgen.Next()
+	pinned := bgen.Next()
+	other := bgen.Next()
+
+	guard := TxnGuard{
+		Locker: blockstore.NewGCLocker(),
+		Pinned: func(cids []*cid.Cid) (*cid.Set, error) {
+			set := cid.NewSet()
+			for _, c := range cids {
+				if c.Equals(pinned.Cid()) {
+					set.Add(c)
+				}
+			}
+			return set, nil
+		},
+	}
+
+	a := NewTxn(bs, journal, guard)
+	if err := a.AddBlocks([]blocks.Block{shared, pinned, other}); err != nil {
+		t.Fatal(err)
+	}
+	// b finds shared stored already, and doesn't journal it
+	b := NewTxn(bs, journal, guard)
+	if err := b.AddBlock(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		b    blocks.Block
+		want bool
+	}{
+		{"shared", shared, true},
+		{"pinned", pinned, true},
+		{"other", other, false},
+	} {
+		has, err := bs.Blockstore().Has(c.b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != c.want {
+			t.Errorf("%s block: expected has=%t", c.name, c.want)
+		}
+	}
+
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := journalSize(t, journal); n != 0 {
+		t.Fatalf("expected an empty journal, got %d entries", n)
+	}
+}
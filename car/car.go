@@ -0,0 +1,368 @@
+// Package car reads and writes CARv1 (content addressable archive) files,
+// which hold a set of blocks together with the roots of the DAGs they form.
+// They allow moving DAGs between nodes without the network.
+package car
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("car")
+
+func init() {
+	cbor.RegisterCborType(Header{})
+}
+
+// maxSectionSize bounds the size of the sections read, so that a corrupt
+// length can't make us allocate arbitrary amounts of memory.
+const maxSectionSize = 32 << 20
+
+// importBatchSize is the number of blocks added at once by Import.
+const importBatchSize = 128
+
+var (
+	// ErrBadVersion is returned when reading a CAR file of a version other
+	// than 1.
+	ErrBadVersion = errors.New("car: unsupported version")
+
+	// ErrNoRoots is returned when reading or writing a CAR file without
+	// roots.
+	ErrNoRoots = errors.New("car: no roots")
+)
+
+// Header is the header of a CAR file.
+type Header struct {
+	Roots   []*cid.Cid `refmt:"roots"`
+	Version uint64     `refmt:"version"`
+}
+
+// Progress reports how far an export or import has come.
+type Progress struct {
+	Blocks uint64 // number of blocks written or read
+	Bytes  uint64 // bytes of block data written or read
+}
+
+// Writer writes a CAR file.
+type Writer struct {
+	w        io.Writer
+	progress Progress
+}
+
+// NewWriter writes the header of a CAR file with the given roots to w and
+// returns a writer for its blocks.
+func NewWriter(w io.Writer, roots []*cid.Cid) (*Writer, error) {
+	if len(roots) == 0 {
+		return nil, ErrNoRoots
+	}
+
+	hb, err := cbor.DumpObject(&Header{Roots: roots, Version: 1})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeSection(w, hb); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// Put appends b to the CAR file.
+func (cw *Writer) Put(b blocks.Block) error {
+	if err := writeSection(cw.w, b.Cid().Bytes(), b.RawData()); err != nil {
+		return err
+	}
+	cw.progress.Blocks++
+	cw.progress.Bytes += uint64(len(b.RawData()))
+	return nil
+}
+
+// Progress returns how many blocks were written so far.
+func (cw *Writer) Progress() Progress {
+	return cw.progress
+}
+
+func writeSection(w io.Writer, parts ...[]byte) error {
+	var total int
+	for _, p := range parts {
+		total += len(p)
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(total))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reader reads a CAR file.
+type Reader struct {
+	r        *bufio.Reader
+	header   Header
+	progress Progress
+}
+
+// NewReader reads the header of the CAR file in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	hb, err := readSection(br)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("car: reading header: %s", err)
+	}
+
+	var h Header
+	if err := cbor.DecodeInto(hb, &h); err != nil {
+		return nil, fmt.Errorf("car: invalid header: %s", err)
+	}
+	if h.Version != 1 {
+		return nil, ErrBadVersion
+	}
+	if len(h.Roots) == 0 {
+		return nil, ErrNoRoots
+	}
+	return &Reader{r: br, header: h}, nil
+}
+
+// Header returns the header of the CAR file.
+func (cr *Reader) Header() Header {
+	return cr.header
+}
+
+// Progress returns how many blocks were read so far.
+func (cr *Reader) Progress() Progress {
+	return cr.progress
+}
+
+// Next returns the next block of the CAR file, or io.EOF at the end of it.
+// The data of each block is checked against its CID, returning
+// bstore.ErrHashMismatch if they don't match.
+func (cr *Reader) Next() (blocks.Block, error) {
+	data, err := readSection(cr.r)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := cidLen(data)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cid.Cast(data[:n])
+	if err != nil {
+		return nil, fmt.Errorf("car: invalid block cid: %s", err)
+	}
+	data = data[n:]
+
+	actual, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !actual.Equals(c) {
+		return nil, bstore.ErrHashMismatch
+	}
+
+	cr.progress.Blocks++
+	cr.progress.Bytes += uint64(len(data))
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// cidLen returns the length of the binary CID at the start of data.
+func cidLen(data []byte) (int, error) {
+	// CIDv0 is a bare sha2-256 multihash
+	if len(data) >= 34 && data[0] == 0x12 && data[1] == 0x20 {
+		return 34, nil
+	}
+
+	// version, codec, hash function and digest length
+	var off int
+	var digest uint64
+	for i := 0; i < 4; i++ {
+		v, n := binary.Uvarint(data[off:])
+		if n <= 0 {
+			return 0, errors.New("car: invalid block cid")
+		}
+		off += n
+		digest = v
+	}
+	if digest > uint64(len(data)-off) {
+		return 0, errors.New("car: invalid block cid")
+	}
+	return off + int(digest), nil
+}
+
+// readSection reads a length prefixed section. It returns io.EOF only if r
+// ends right before the section.
+func readSection(r *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	if l > maxSectionSize {
+		return nil, fmt.Errorf("car: section of %d bytes too large", l)
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ExportDAG writes the DAGs below roots to w, reading the nodes from ng.
+// Blocks are written in depth-first order, each block once. If progress is
+// not nil, it is called after every block.
+func ExportDAG(ctx context.Context, ng ipld.NodeGetter, roots []*cid.Cid, w io.Writer, progress func(Progress)) error {
+	cw, err := NewWriter(w, roots)
+	if err != nil {
+		return err
+	}
+
+	seen := cid.NewSet()
+	var walk func(c *cid.Cid) error
+	walk = func(c *cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := cw.Put(nd); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(cw.Progress())
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, c := range roots {
+		if err := walk(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportBlockstore writes all blocks of bs to w, recording roots in the
+// header. If progress is not nil, it is called after every block.
+func ExportBlockstore(ctx context.Context, bs bstore.Blockstore, roots []*cid.Cid, w io.Writer, progress func(Progress)) error {
+	cw, err := NewWriter(w, roots)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	keys, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+
+	for c := range keys {
+		b, err := bs.Get(c)
+		if err == bstore.ErrNotFound {
+			// removed while we were exporting
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := cw.Put(b); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(cw.Progress())
+		}
+	}
+	return ctx.Err()
+}
+
+// Import adds the blocks of the CAR file in r to bs and returns its header.
+// Blocks whose data doesn't match their CID abort the import with
+// bstore.ErrHashMismatch; the blocks added before stay. If progress is not
+// nil, it is called after every batch of blocks added.
+func Import(ctx context.Context, bs bserv.BlockService, r io.Reader, progress func(Progress)) (Header, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return Header{}, err
+	}
+
+	batch := make([]blocks.Block, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := bs.AddBlocks(batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if progress != nil {
+			progress(cr.Progress())
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return cr.Header(), err
+		}
+
+		b, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cr.Header(), err
+		}
+
+		batch = append(batch, b)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return cr.Header(), err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return cr.Header(), err
+	}
+
+	p := cr.Progress()
+	log.Debugf("imported %d blocks (%d bytes)", p.Blocks, p.Bytes)
+	return cr.Header(), nil
+}
@@ -0,0 +1,136 @@
+package car
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+func newBlockService() (bstore.Blockstore, bserv.BlockService) {
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	return bs, bserv.New(bs, offline.Exchange(bs))
+}
+
+// buildDAG creates a root with two children sharing a grandchild.
+func buildDAG(t *testing.T, dserv ipld.DAGService) []ipld.Node {
+	ctx := context.Background()
+	leaf := dag.NodeWithData([]byte("leaf"))
+	a := dag.NodeWithData([]byte("a"))
+	b := dag.NodeWithData([]byte("b"))
+	root := dag.NodeWithData([]byte("root"))
+	for _, l := range []struct{ parent, child *dag.ProtoNode }{{a, leaf}, {b, leaf}, {root, a}, {root, b}} {
+		if err := l.parent.AddNodeLinkClean(string(l.child.Data()), l.child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodes := []ipld.Node{leaf, a, b, root}
+	if err := dserv.AddMany(ctx, nodes); err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
+
+func TestExportImportDAG(t *testing.T) {
+	ctx := context.Background()
+	_, src := newBlockService()
+	nodes := buildDAG(t, dag.NewDAGService(src))
+	root := nodes[len(nodes)-1].Cid()
+
+	var progress Progress
+	var buf bytes.Buffer
+	err := ExportDAG(ctx, dag.NewDAGService(src), []*cid.Cid{root}, &buf, func(p Progress) {
+		progress = p
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Blocks != uint64(len(nodes)) {
+		t.Fatalf("expected %d blocks exported, got %d", len(nodes), progress.Blocks)
+	}
+
+	dstBs, dst := newBlockService()
+	h, err := Import(ctx, dst, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Roots) != 1 || !h.Roots[0].Equals(root) {
+		t.Fatalf("unexpected roots %v", h.Roots)
+	}
+	for _, nd := range nodes {
+		has, err := dstBs.Has(nd.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !has {
+			t.Fatalf("%s missing after import", nd.Cid())
+		}
+	}
+}
+
+func TestExportBlockstore(t *testing.T) {
+	ctx := context.Background()
+	srcBs, src := newBlockService()
+	nodes := buildDAG(t, dag.NewDAGService(src))
+
+	var buf bytes.Buffer
+	if err := ExportBlockstore(ctx, srcBs, []*cid.Cid{nodes[0].Cid()}, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cr, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := cr.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cr.Progress().Blocks != uint64(len(nodes)) {
+		t.Fatalf("expected %d blocks, got %d", len(nodes), cr.Progress().Blocks)
+	}
+}
+
+func TestImportRejectsCorruptBlocks(t *testing.T) {
+	ctx := context.Background()
+	_, src := newBlockService()
+	nodes := buildDAG(t, dag.NewDAGService(src))
+	root := nodes[len(nodes)-1].Cid()
+
+	var buf bytes.Buffer
+	if err := ExportDAG(ctx, dag.NewDAGService(src), []*cid.Cid{root}, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	_, dst := newBlockService()
+	if _, err := Import(ctx, dst, bytes.NewReader(corrupt), nil); err != bstore.ErrHashMismatch {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+
+	_, dst = newBlockService()
+	if _, err := Import(ctx, dst, bytes.NewReader(data[:len(data)-1]), nil); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected ErrUnexpectedEOF for a truncated file, got %v", err)
+	}
+}
+
+func TestNoRoots(t *testing.T) {
+	if _, err := NewWriter(new(bytes.Buffer), nil); err != ErrNoRoots {
+		t.Fatalf("expected ErrNoRoots, got %v", err)
+	}
+}
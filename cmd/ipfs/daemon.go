@@ -10,16 +10,21 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"time"
 
 	utilmain "github.com/ipfs/go-ipfs/cmd/ipfs/util"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
 	commands "github.com/ipfs/go-ipfs/core/commands"
+	corerpc "github.com/ipfs/go-ipfs/core/coreapi/rpc"
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	logs "github.com/ipfs/go-ipfs/core/logs"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	migrate "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+	tracing "github.com/ipfs/go-ipfs/tracing"
 
 	"github.com/gxed/client_golang/prometheus"
 	"github.com/ipfs/go-ipfs-cmdkit"
@@ -36,17 +41,20 @@ const (
 	initOptionKwd             = "init"
 	ipfsMountKwd              = "mount-ipfs"
 	ipnsMountKwd              = "mount-ipns"
+	mfsMountKwd               = "mount-mfs"
 	migrateKwd                = "migrate"
 	mountKwd                  = "mount"
 	offlineKwd                = "offline"
 	routingOptionKwd          = "routing"
 	routingOptionSupernodeKwd = "supernode"
 	routingOptionDHTClientKwd = "dhtclient"
+	routingOptionDHTAutoKwd   = "dhtauto"
 	routingOptionDHTKwd       = "dht"
 	routingOptionNoneKwd      = "none"
 	unencryptTransportKwd     = "disable-transport-encryption"
 	unrestrictedApiAccessKwd  = "unrestricted-api"
 	writableKwd               = "writable"
+	watchConfigKwd            = "watch-config"
 	enableFloodSubKwd         = "enable-pubsub-experiment"
 	enableIPNSPubSubKwd       = "enable-namesys-pubsub"
 	enableMultiplexKwd        = "enable-mplex-experiment"
@@ -54,6 +62,9 @@ const (
 	// swarmAddrKwd  = "address-swarm"
 )
 
+// configWatchInterval is how often --watch-config reads the config file.
+const configWatchInterval = 10 * time.Second
+
 var daemonCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Run a network-connected IPFS node.",
@@ -75,7 +86,9 @@ The API address can be changed the same way:
 
   ipfs config Addresses.API /ip4/127.0.0.1/tcp/5002
 
-Make sure to restart the daemon after changing addresses.
+Make sure to restart the daemon after changing addresses. The gateway can
+move without restarting, with 'ipfs config reload', or by starting the daemon
+with --watch-config to apply the changes of the config file as it is saved.
 
 By default, the gateway is only accessible locally. To expose it to
 other computers in the network, use 0.0.0.0 as the ip address:
@@ -125,14 +138,15 @@ environment variable:
 
 Routing
 
-IPFS by default will use a DHT for content routing. There is a highly
-experimental alternative that operates the DHT in a 'client only' mode that
-can be enabled by running the daemon as:
+IPFS by default will use a DHT for content routing, answering the DHT
+requests of other peers. Nodes with little resources can run the DHT in a
+'client only' mode, sending their own requests only:
 
   ipfs daemon --routing=dhtclient
 
-This will later be transitioned into a config option once it gets out of the
-'experimental' stage.
+With --routing=dhtauto, the node answers requests while it has a public
+address. The routing defaults to Routing.Type in the config, and the mode can
+be switched at runtime with 'ipfs dht mode'.
 
 DEPRECATION NOTICE
 
@@ -148,11 +162,12 @@ Headers.
 
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption(initOptionKwd, "Initialize ipfs with default settings if not already initialized"),
-		cmdkit.StringOption(routingOptionKwd, "Overrides the routing option").WithDefault("dht"),
+		cmdkit.StringOption(routingOptionKwd, "Overrides the routing option. Defaults to Routing.Type in the config, or \"dht\"."),
 		cmdkit.BoolOption(mountKwd, "Mounts IPFS to the filesystem"),
 		cmdkit.BoolOption(writableKwd, "Enable writing objects (with POST, PUT and DELETE)"),
 		cmdkit.StringOption(ipfsMountKwd, "Path to the mountpoint for IPFS (if using --mount). Defaults to config setting."),
 		cmdkit.StringOption(ipnsMountKwd, "Path to the mountpoint for IPNS (if using --mount). Defaults to config setting."),
+		cmdkit.StringOption(mfsMountKwd, "Path to the read-write mountpoint for the files of 'ipfs files' (if using --mount). Defaults to config setting."),
 		cmdkit.BoolOption(unrestrictedApiAccessKwd, "Allow API access to unlisted hashes"),
 		cmdkit.BoolOption(unencryptTransportKwd, "Disable transport encryption (for debugging protocols)"),
 		cmdkit.BoolOption(enableGCKwd, "Enable automatic periodic repo garbage collection"),
@@ -162,6 +177,7 @@ Headers.
 		cmdkit.BoolOption(enableFloodSubKwd, "Instantiate the ipfs daemon with the experimental pubsub feature enabled."),
 		cmdkit.BoolOption(enableIPNSPubSubKwd, "Enable IPNS record distribution through pubsub; enables pubsub."),
 		cmdkit.BoolOption(enableMultiplexKwd, "Add the experimental 'go-multiplex' stream muxer to libp2p on construction.").WithDefault(true),
+		cmdkit.BoolOption(watchConfigKwd, "Apply the changes made to the config file while running, as 'ipfs config reload' does."),
 
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmdkit.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -189,6 +205,9 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		log.Errorf("Injecting prometheus handler for metrics failed with message: %s\n", err.Error())
 	}
 
+	// keep the recent entries of the event log for 'ipfs log dump'
+	logs.Start(logs.DefaultSize)
+
 	// let the user know we're going.
 	fmt.Printf("Initializing daemon...\n")
 
@@ -296,12 +315,20 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	}
 
 	routingOption, _ := req.Options[routingOptionKwd].(string)
+	if routingOption == "" {
+		routingOption = cfg.Routing.Type
+	}
+	if routingOption == "" {
+		routingOption = routingOptionDHTKwd
+	}
 	switch routingOption {
 	case routingOptionSupernodeKwd:
 		re.SetError(errors.New("supernode routing was never fully implemented and has been removed"), cmdkit.ErrNormal)
 		return
 	case routingOptionDHTClientKwd:
 		ncfg.Routing = core.DHTClientOption
+	case routingOptionDHTAutoKwd:
+		ncfg.Routing = core.DHTAutoOption
 	case routingOptionDHTKwd:
 		ncfg.Routing = core.DHTOption
 	case routingOptionNoneKwd:
@@ -338,6 +365,13 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		}
 	}()
 
+	for _, name := range sortedTenants(cfg.Tenants) {
+		if err := openTenant(node, name, cfg.Tenants[name]); err != nil {
+			re.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+	}
+
 	cctx.ConstructNode = func() (*core.IpfsNode, error) {
 		return node, nil
 	}
@@ -370,6 +404,9 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return
 	}
 
+	// evict least recently used blocks - if Datastore.Eviction is enabled
+	evictErrc := maybeRunEviction(req, node)
+
 	// construct http gateway - if it is set in the config
 	var gwErrc <-chan error
 	if len(cfg.Addresses.Gateway) > 0 {
@@ -381,13 +418,33 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		}
 	}
 
+	// construct gRPC core API - if it is set in the config
+	var rpcErrc <-chan error
+	if len(cfg.Addresses.RPC) > 0 {
+		var err error
+		rpcErrc, err = serveRPC(cctx)
+		if err != nil {
+			re.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+	}
+
+	// apply config changes - if --watch-config is set
+	if watch, _ := req.Options[watchConfigKwd].(bool); watch {
+		go node.WatchConfig(node.Context(), configWatchInterval)
+	}
+
+	// export traces - if Tracing.OTLPEndpoint is set
+	stopTracing := startTracing(node, cfg.Tracing.OTLPEndpoint)
+	defer stopTracing()
+
 	// initialize metrics collector
 	prometheus.MustRegister(&corehttp.IpfsNodeCollector{Node: node})
 
 	fmt.Printf("Daemon is ready\n")
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesnt follow this pattern for graceful shutdown
-	for err := range merge(apiErrc, gwErrc, gcErrc) {
+	for err := range merge(apiErrc, gwErrc, rpcErrc, gcErrc, evictErrc) {
 		if err != nil {
 			log.Error(err)
 			re.SetError(err, cmdkit.ErrNormal)
@@ -440,6 +497,7 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		defaultMux("/debug/pprof/"),
 		corehttp.MetricsScrapingOption("/debug/metrics/prometheus"),
 		corehttp.LogOption(),
+		corehttp.EventsOption(),
 	}
 
 	if len(cfg.Gateway.RootRedirect) > 0 {
@@ -463,6 +521,38 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 	return errc, nil
 }
 
+// serveRPC creates the listener of the gRPC core API, prints status message
+// and starts serving requests
+func serveRPC(cctx *oldcmds.Context) (<-chan error, error) {
+	cfg, err := cctx.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("serveRPC: GetConfig() failed: %s", err)
+	}
+
+	rpcMaddr, err := ma.NewMultiaddr(cfg.Addresses.RPC)
+	if err != nil {
+		return nil, fmt.Errorf("serveRPC: invalid RPC address: %q (err: %s)", cfg.Addresses.RPC, err)
+	}
+
+	rpcLis, err := manet.Listen(rpcMaddr)
+	if err != nil {
+		return nil, fmt.Errorf("serveRPC: manet.Listen(%s) failed: %s", rpcMaddr, err)
+	}
+	fmt.Printf("RPC server listening on %s\n", rpcLis.Multiaddr())
+
+	node, err := cctx.ConstructNode()
+	if err != nil {
+		return nil, fmt.Errorf("serveRPC: ConstructNode() failed: %s", err)
+	}
+
+	errc := make(chan error)
+	go func() {
+		errc <- corerpc.Serve(node, rpcLis.NetListener())
+		close(errc)
+	}()
+	return errc, nil
+}
+
 // printSwarmAddrs prints the addresses of the host
 func printSwarmAddrs(node *core.IpfsNode) {
 	if !node.OnlineMode() {
@@ -517,6 +607,8 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 	}
 	// we might have listened to /tcp/0 - lets see what we are listing on
 	gatewayMaddr = gwLis.Multiaddr()
+	// moved when Addresses.Gateway changes
+	gwSwapLis := newSwappableListener(gwLis)
 
 	if writable {
 		fmt.Printf("Gateway (writable) server listening on %s\n", gatewayMaddr)
@@ -526,6 +618,7 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("gateway"),
+		corehttp.GatewayLimitsOption(),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsROOption(*cctx),
 		corehttp.VersionOption(),
@@ -542,14 +635,52 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		return nil, fmt.Errorf("serveHTTPGateway: ConstructNode() failed: %s", err)
 	}
 
+	node.RegisterConfigApplier("Addresses.Gateway", func(cfg *config.Config) error {
+		maddr, err := ma.NewMultiaddr(cfg.Addresses.Gateway)
+		if err != nil {
+			return fmt.Errorf("invalid gateway address: %q (err: %s)", cfg.Addresses.Gateway, err)
+		}
+		lis, err := manet.Listen(maddr)
+		if err != nil {
+			return err
+		}
+		gwSwapLis.swap(lis)
+		fmt.Printf("Gateway server listening on %s\n", lis.Multiaddr())
+		return nil
+	})
+
 	errc := make(chan error)
 	go func() {
-		errc <- corehttp.Serve(node, gwLis.NetListener(), opts...)
+		errc <- corehttp.Serve(node, gwSwapLis, opts...)
 		close(errc)
 	}()
 	return errc, nil
 }
 
+// startTracing exports the spans of the node to the OTLP endpoint, if any,
+// following the changes of Tracing.OTLPEndpoint, and returns a function
+// stopping the export.
+func startTracing(node *core.IpfsNode, endpoint string) func() {
+	var lk sync.Mutex
+	stop := func() {}
+	start := func(endpoint string) {
+		lk.Lock()
+		defer lk.Unlock()
+		stop()
+		stop = func() {}
+		if endpoint != "" {
+			stop = tracing.Start(tracing.NewOTLPExporter(endpoint))
+		}
+	}
+
+	start(endpoint)
+	node.RegisterConfigApplier("Tracing", func(cfg *config.Config) error {
+		start(cfg.Tracing.OTLPEndpoint)
+		return nil
+	})
+	return func() { start("") }
+}
+
 //collects options and opens the fuse mountpoint
 func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 	cfg, err := cctx.GetConfig()
@@ -567,6 +698,11 @@ func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 		nsdir = cfg.Mounts.IPNS
 	}
 
+	mfsdir, found := req.Options[mfsMountKwd].(string)
+	if !found {
+		mfsdir = cfg.Mounts.MFS
+	}
+
 	node, err := cctx.ConstructNode()
 	if err != nil {
 		return fmt.Errorf("mountFuse: ConstructNode() failed: %s", err)
@@ -578,21 +714,79 @@ func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 	}
 	fmt.Printf("IPFS mounted at: %s\n", fsdir)
 	fmt.Printf("IPNS mounted at: %s\n", nsdir)
+
+	if mfsdir != "" {
+		if err := nodeMount.MountMfs(node, mfsdir); err != nil {
+			return err
+		}
+		fmt.Printf("MFS mounted at: %s\n", mfsdir)
+	}
+	return nil
+}
+
+func sortedTenants(tenants map[string]config.Tenant) []string {
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openTenant opens the repo of a tenant and adds it to the node.
+func openTenant(node *core.IpfsNode, name string, t config.Tenant) error {
+	r, err := fsrepo.Open(t.Path)
+	if err != nil {
+		return fmt.Errorf("opening repo of tenant %q: %s", name, err)
+	}
+	if _, err := node.AddTenant(name, r); err != nil {
+		r.Close()
+		return err
+	}
+	fmt.Printf("Opened repo of tenant %s\n", name)
 	return nil
 }
 
+// maybeRunGC collects the garbage of the node and of each of its tenants
+// periodically, following the config of their own repos.
 func maybeRunGC(req *cmds.Request, node *core.IpfsNode) (<-chan error, error) {
 	enableGC, _ := req.Options[enableGCKwd].(bool)
 	if !enableGC {
 		return nil, nil
 	}
 
+	nodes := []*core.IpfsNode{node}
+	for _, name := range node.Tenants() {
+		t, err := node.Tenant(name)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, t)
+	}
+
+	var errcs []<-chan error
+	for _, n := range nodes {
+		errc := make(chan error)
+		go func(n *core.IpfsNode) {
+			errc <- corerepo.PeriodicGC(req.Context, n)
+			close(errc)
+		}(n)
+		errcs = append(errcs, errc)
+	}
+	return merge(errcs...), nil
+}
+
+func maybeRunEviction(req *cmds.Request, node *core.IpfsNode) <-chan error {
+	if node.Evictor == nil {
+		return nil
+	}
+
 	errc := make(chan error)
 	go func() {
-		errc <- corerepo.PeriodicGC(req.Context, node)
+		errc <- corerepo.PeriodicEviction(req.Context, node)
 		close(errc)
 	}()
-	return errc, nil
+	return errc
 }
 
 // merge does fan-in of multiple read-only error channels
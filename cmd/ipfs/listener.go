@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+var errListenerClosed = errors.New("listener closed")
+
+// swappableListener accepts the connections of a listener which can be
+// replaced while serving, so that a server can move to a new address
+// without restarting.
+type swappableListener struct {
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	lk  sync.Mutex
+	cur manet.Listener
+}
+
+func newSwappableListener(l manet.Listener) *swappableListener {
+	sl := &swappableListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+		cur:    l,
+	}
+	go sl.accept(l)
+	return sl
+}
+
+// swap makes the listener accept the connections of l, and closes the
+// previous one.
+func (sl *swappableListener) swap(l manet.Listener) {
+	sl.lk.Lock()
+	prev := sl.cur
+	sl.cur = l
+	sl.lk.Unlock()
+
+	go sl.accept(l)
+	prev.Close()
+}
+
+func (sl *swappableListener) accept(l manet.Listener) {
+	for {
+		c, err := l.NetListener().Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return
+		}
+
+		select {
+		case sl.conns <- c:
+		case <-sl.closed:
+			c.Close()
+			return
+		}
+	}
+}
+
+func (sl *swappableListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-sl.conns:
+		return c, nil
+	case <-sl.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (sl *swappableListener) Close() error {
+	sl.closeOnce.Do(func() { close(sl.closed) })
+
+	sl.lk.Lock()
+	defer sl.lk.Unlock()
+	return sl.cur.Close()
+}
+
+func (sl *swappableListener) Addr() net.Addr {
+	sl.lk.Lock()
+	defer sl.lk.Unlock()
+	return sl.cur.Addr()
+}
@@ -291,6 +291,10 @@ func commandShouldRunOnDaemon(details cmdDetails, req *cmds.Request, root *cmds.
 	if details.cannotRunOnClient {
 		return nil, cmds.ClientError("must run on the ipfs daemon")
 	}
+	if tenant, _ := req.Options[coreCmds.TenantOption].(string); tenant != "" {
+		// tenants are only opened by the daemon
+		return nil, cmds.ClientError("tenants are served by the ipfs daemon, which isn't running")
+	}
 
 	return nil, nil
 }
@@ -49,9 +49,21 @@ func (c *Context) GetNode() (*core.IpfsNode, error) {
 		}
 		c.node, err = c.ConstructNode()
 	}
+	if c.node != nil {
+		// don't start running on services being swapped by a mode switch
+		c.node.LockServices()()
+	}
 	return c.node, err
 }
 
+// WithNode returns a copy of c whose commands run on n, e.g. a tenant of the
+// node of c.
+func (c *Context) WithNode(n *core.IpfsNode) *Context {
+	nc := *c
+	nc.node = n
+	return &nc
+}
+
 // Context returns the node's context.
 func (c *Context) Context() context.Context {
 	n, err := c.GetNode()
@@ -5,22 +5,40 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
 	"syscall"
 	"time"
 
+	bsutil "github.com/ipfs/go-ipfs/blocks/blockstoreutil"
+	bloomcache "github.com/ipfs/go-ipfs/blocks/bloomcache"
+	parallelbs "github.com/ipfs/go-ipfs/blocks/parallelbs"
+	quota "github.com/ipfs/go-ipfs/blocks/quota"
+	tieredbs "github.com/ipfs/go-ipfs/blocks/tieredbs"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	events "github.com/ipfs/go-ipfs/core/events"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	httpgateway "github.com/ipfs/go-ipfs/exchange/httpgateway"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	peerpolicy "github.com/ipfs/go-ipfs/exchange/peerpolicy"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
 	pin "github.com/ipfs/go-ipfs/pin"
+	evict "github.com/ipfs/go-ipfs/pin/evict"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	remote "github.com/ipfs/go-ipfs/pin/remote"
 	repo "github.com/ipfs/go-ipfs/repo"
 	cfg "github.com/ipfs/go-ipfs/repo/config"
 	"github.com/ipfs/go-ipfs/thirdparty/verifbs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	mimeindex "github.com/ipfs/go-ipfs/unixfs/mimeindex"
 
+	humanize "github.com/dustin/go-humanize"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
+	dsns "github.com/ipfs/go-datastore/namespace"
 	dsync "github.com/ipfs/go-datastore/sync"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	metrics "github.com/ipfs/go-metrics-interface"
@@ -31,6 +49,17 @@ import (
 	retry "github.com/whyrusleeping/retry-datastore"
 )
 
+const defaultGatewayFallbackDelay = time.Second * 10
+
+var defaultQuotaThresholds = []int{90, 100}
+
+const (
+	defaultTieredHotCapacity = "1GB"
+	defaultTieredColdPrefix  = "/cold"
+)
+
+const defaultEvictionPeriod = time.Minute
+
 type BuildCfg struct {
 	// If online is set, the node will have networking enabled
 	Online bool
@@ -48,6 +77,17 @@ type BuildCfg struct {
 	Routing RoutingOption
 	Host    HostOption
 	Repo    repo.Repo
+
+	// The subsystems below are built by these options, so that embedders
+	// can replace or disable them (see NilExchangeOption, NilNamesysOption
+	// and NilPinnerOption). Exchange is only used by online nodes.
+	Exchange ExchangeOption
+	Namesys  NamesysOption
+	Pinner   PinnerOption
+
+	// Blocks constructs the block service. If nil, it is configured by the
+	// Exchange and Datastore sections of the repo config.
+	Blocks BlockServiceOption
 }
 
 func (cfg *BuildCfg) getOpt(key string) bool {
@@ -85,6 +125,18 @@ func (cfg *BuildCfg) fillDefaults() error {
 		cfg.Host = DefaultHostOption
 	}
 
+	if cfg.Exchange == nil {
+		cfg.Exchange = BitswapOption
+	}
+
+	if cfg.Namesys == nil {
+		cfg.Namesys = DefaultNamesysOption
+	}
+
+	if cfg.Pinner == nil {
+		cfg.Pinner = DefaultPinnerOption
+	}
+
 	return nil
 }
 
@@ -133,6 +185,9 @@ func NewNode(ctx context.Context, cfg *BuildCfg) (*IpfsNode, error) {
 		Repo:      cfg.Repo,
 		ctx:       ctx,
 		Peerstore: pstore.NewPeerstore(),
+
+		exchangeOption: cfg.Exchange,
+		namesysOption:  cfg.Namesys,
 	}
 	if cfg.Online {
 		n.mode = onlineMode
@@ -171,24 +226,70 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		TempErrFunc: isTooManyFDError,
 	}
 
-	// hash security
-	bs := bstore.NewBlockstore(rds)
-	bs = &verifbs.VerifBS{bs}
-
-	opts := bstore.DefaultCacheOpts()
 	conf, err := n.Repo.Config()
 	if err != nil {
 		return err
 	}
 
+	workers := conf.Datastore.EnumerationWorkers
+	if workers == 0 {
+		workers = parallelbs.DefaultWorkers
+	}
+	bs := parallelbs.Wrap(bstore.NewBlockstore(rds), rds, workers)
+	if conf.Datastore.Tiered.Enabled {
+		bs, err = tieredBlockstore(ctx, bs, rds, conf.Datastore.Tiered)
+		if err != nil {
+			return err
+		}
+	} else if err := tieredbs.DropIndex(rds); err != nil {
+		return err
+	}
+
+	// hash security
+	bs = &verifbs.VerifBS{bs}
+
+	n.readOnly = conf.Datastore.ReadOnly
+	if n.readOnly {
+		bs = bsutil.NewReadOnly(bs)
+	}
+
+	opts := bstore.DefaultCacheOpts()
+
 	// TEMP: setting global sharding switch here
 	uio.UseHAMTSharding = conf.Experimental.ShardingEnabled
+	if conf.Experimental.ShardingThreshold > 0 {
+		uio.ShardSplitThreshold = conf.Experimental.ShardingThreshold
+	}
+	if conf.Experimental.ShardingSizeLimit > 0 {
+		uio.ShardSizeThreshold = conf.Experimental.ShardingSizeLimit
+	}
 
 	opts.HasBloomFilterSize = conf.Datastore.BloomFilterSize
 	if !cfg.Permanent {
 		opts.HasBloomFilterSize = 0
 	}
 
+	switch {
+	case conf.Datastore.ReadOnly:
+		// nothing can change, and nothing can be saved
+	case conf.Datastore.PersistBloomFilter && opts.HasBloomFilterSize > 0:
+		n.BloomCache, err = bloomcache.New(ctx, bs, n.Repo.Datastore(), opts.HasBloomFilterSize)
+		if err != nil {
+			return err
+		}
+		bs = n.BloomCache
+		opts.HasBloomFilterSize = 0
+	default:
+		// don't let a saved filter miss the blocks we write
+		bs = bloomcache.Invalidating(bs, n.Repo.Datastore())
+	}
+
+	if !conf.Datastore.ReadOnly {
+		// let gc run while blocks are written
+		n.GCJournal = gc.NewJournal()
+		bs = n.GCJournal.Blockstore(bs)
+	}
+
 	cbs, err := bstore.CachedBlockstore(ctx, bs, opts)
 	if err != nil {
 		return err
@@ -196,15 +297,51 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 
 	n.BaseBlocks = cbs
 	n.GCLocker = bstore.NewGCLocker()
+	n.GCEvents = gc.NewBus()
+	n.Events = events.NewBus()
+	n.Events.ForwardGC(n.GCEvents)
 	n.Blockstore = bstore.NewGCBlockstore(cbs, n.GCLocker)
 
-	if conf.Experimental.FilestoreEnabled {
+	if conf.Experimental.FilestoreEnabled || conf.Experimental.UrlstoreEnabled {
+		fm := n.Repo.FileManager()
+		fm.AllowFiles = conf.Experimental.FilestoreEnabled
+		fm.AllowUrls = conf.Experimental.UrlstoreEnabled
+
 		// hash security
-		n.Filestore = filestore.NewFilestore(bs, n.Repo.FileManager())
-		n.Blockstore = bstore.NewGCBlockstore(n.Filestore, n.GCLocker)
+		n.Filestore = filestore.NewFilestore(bs, fm)
+		var fbs bstore.Blockstore = n.Filestore
+		if conf.Datastore.ReadOnly {
+			fbs = bsutil.NewReadOnly(fbs)
+		} else {
+			fbs = n.GCJournal.Blockstore(fbs)
+		}
+		n.Blockstore = bstore.NewGCBlockstore(fbs, n.GCLocker)
 		n.Blockstore = &verifbs.VerifBSGC{n.Blockstore}
 	}
 
+	if quotaEnabled(conf.Datastore.Quota) {
+		opts, err := quotaOptions(conf.Datastore.Quota)
+		if err != nil {
+			return err
+		}
+		n.Quota, err = quota.New(ctx, n.Blockstore, n.Repo.Datastore(), opts)
+		if err != nil {
+			return err
+		}
+		n.Blockstore = n.Quota.Blockstore(quota.Local)
+	}
+
+	var tracker *evict.Tracker
+	if conf.Datastore.Eviction.Enabled && !conf.Datastore.ReadOnly {
+		period, err := evictionPeriod(conf.Datastore.Eviction)
+		if err != nil {
+			return err
+		}
+		tracker = evict.NewTracker(n.Blockstore, n.Repo.Datastore())
+		go tracker.Run(ctx, period)
+		n.Blockstore = tracker
+	}
+
 	rcfg, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -214,34 +351,310 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		bs.HashOnRead(true)
 	}
 
-	if cfg.Online {
+	n.startOnline = func(ctx context.Context) error {
 		do := setupDiscoveryOption(rcfg.Discovery)
 		if err := n.startOnlineServices(ctx, cfg.Routing, cfg.Host, do, cfg.getOpt("pubsub"), cfg.getOpt("ipnsps"), cfg.getOpt("mplex")); err != nil {
 			return err
 		}
+		return n.configureExchange(rcfg.Exchange)
+	}
+	onlineCtx := ctx
+	if cfg.Online {
+		onlineCtx, n.stopOnline = context.WithCancel(ctx)
+		if err := n.startOnline(onlineCtx); err != nil {
+			return err
+		}
 	} else {
 		n.Exchange = offline.Exchange(n.Blockstore)
 	}
 
-	n.Blocks = bserv.New(n.Blockstore, n.Exchange)
-	n.DAG = dag.NewDAGService(n.Blocks)
+	n.FetchAhead.Blocks = rcfg.Exchange.FetchAheadBlocks
+	if rcfg.Exchange.FetchAheadMemory != "" {
+		mem, err := humanize.ParseBytes(rcfg.Exchange.FetchAheadMemory)
+		if err != nil {
+			return fmt.Errorf("parsing Exchange.FetchAheadMemory: %s", err)
+		}
+		n.FetchAhead.Bytes = int(mem)
+	}
+
+	sched := bserv.NewScheduler(bserv.DefaultMaxInFlightWants, bserv.DefaultPriorityWeights)
+	n.Blocks = bserv.New(n.Blockstore, n.Exchange, bserv.WithScheduler(sched))
+	if cfg.Blocks != nil {
+		n.Blocks, err = cfg.Blocks(ctx, n.Blockstore, n.Exchange)
+		if err != nil {
+			return err
+		}
+	} else if rcfg.Datastore.ReadOnly {
+		// fetched blocks couldn't be stored, only serve local ones. The
+		// exchange keeps providing them to other peers.
+		n.Blocks = bserv.New(n.Blockstore, offline.Exchange(n.Blockstore))
+	} else if cfg.Online && len(rcfg.Exchange.GatewayFallback) > 0 {
+		delay := defaultGatewayFallbackDelay
+		if rcfg.Exchange.GatewayFallbackDelay != "" {
+			delay, err = time.ParseDuration(rcfg.Exchange.GatewayFallbackDelay)
+			if err != nil {
+				return fmt.Errorf("parsing Exchange.GatewayFallbackDelay: %s", err)
+			}
+		}
+
+		gw := httpgateway.New(n.networkBlockstore(), rcfg.Exchange.GatewayFallback)
+		n.Blocks = bserv.NewWithFallback(n.Blockstore, n.Exchange, gw, delay, bserv.WithScheduler(sched))
+	}
+	n.Validators = dag.NewValidators()
+	n.DAG = &dag.ValidatingService{
+		DAGService: dag.NewDAGService(n.Blocks),
+		Validators: n.Validators,
+	}
 
 	internalDag := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
-	n.Pinning, err = pin.LoadPinner(n.Repo.Datastore(), n.DAG, internalDag)
+	n.Pinning, err = cfg.Pinner(n.Repo.Datastore(), n.DAG, internalDag)
 	if err != nil {
-		// TODO: we should move towards only running 'NewPinner' explicity on
-		// node init instead of implicitly here as a result of the pinner keys
-		// not being found in the datastore.
-		// this is kinda sketchy and could cause data loss
-		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG, internalDag)
+		return err
+	}
+	if rcfg.Datastore.ReadOnly {
+		n.Pinning = pin.NewReadOnlyPinner(n.Pinning)
+	} else {
+		// roll back adds interrupted by a crash, keeping the blocks pinned
+		// by adds committed since
+		rolledBack, err := bserv.RecoverTxns(n.Blockstore, n.Repo.Datastore(), n.TxnGuard())
+		if err != nil {
+			return err
+		}
+		if rolledBack > 0 {
+			log.Warningf("rolled back %d interrupted block transactions", rolledBack)
+		}
 	}
 	n.Resolver = resolver.NewBasicResolver(n.DAG)
+	n.RemotePins = remotePinManager(n, rcfg.Pinning)
+	n.PartialPins = pin.NewPartialPins(n.Repo.Datastore(), internalDag, n.DAG, n.Pinning, n.Blockstore)
+	if rcfg.Datastore.ReadOnly {
+		n.PartialPins.SetReadOnly()
+	}
+	if rcfg.Experimental.MimeIndexEnabled {
+		n.MimeIndex = mimeindex.New(n.Repo.Datastore(), ds.NewKey("/local/mimetypes"))
+	}
+
+	if tracker != nil {
+		n.Evictor = evict.New(tracker, n.Pinning, func() ([]*cid.Cid, error) {
+			c, err := n.filesRootCid()
+			if err != nil {
+				return nil, err
+			}
+			partial, err := n.PartialPins.Roots()
+			if err != nil {
+				return nil, err
+			}
+			return append([]*cid.Cid{c}, partial...), nil
+		})
+	}
 
 	if cfg.Online {
-		if err := n.startLateOnlineServices(ctx); err != nil {
+		if err := n.startLateOnlineServices(onlineCtx); err != nil {
 			return err
 		}
 	}
 
+	if err := n.setupConfigReload(rcfg); err != nil {
+		return err
+	}
+
 	return n.loadFilesRoot()
 }
+
+// configureExchange applies the Exchange section of the config to the
+// exchange of the node, if it is bitswap.
+func (n *IpfsNode) configureExchange(ecfg cfg.Exchange) error {
+	bs, ok := n.Exchange.(*bitswap.Bitswap)
+	if !ok {
+		return nil
+	}
+
+	if ecfg.PersistentWantsTTL != "" {
+		ttl, err := time.ParseDuration(ecfg.PersistentWantsTTL)
+		if err != nil {
+			return fmt.Errorf("parsing Exchange.PersistentWantsTTL: %s", err)
+		}
+		if err := bs.PersistWants(n.Repo.Datastore(), ttl); err != nil {
+			return err
+		}
+	}
+
+	limits, err := uploadLimits(ecfg)
+	if err != nil {
+		return err
+	}
+	bs.SetUploadLimits(limits)
+
+	policy, err := peerPolicy(ecfg)
+	if err != nil {
+		return err
+	}
+	if policy != nil {
+		bs.SetPeerPolicy(policy)
+	}
+
+	if n.Resources != nil {
+		bs.SetMemoryManager(n.Resources)
+	}
+
+	// the blocks of the tenants are served to other peers, but never found
+	// in the node's own blockstore
+	bs.SetServeBlockstore(&tenantBlockstore{Blockstore: n.Blockstore, node: n})
+
+	bs.SetReceiveHook(func(p peer.ID, b blocks.Block) {
+		n.Events.Emit(events.Event{Type: events.BlockFetched, Peer: p, Cid: b.Cid()})
+	})
+	return nil
+}
+
+// peerPolicy returns the policy of the peers blocks are traded with, nil if
+// all peers are allowed and never banned.
+func peerPolicy(ecfg cfg.Exchange) (*peerpolicy.Policy, error) {
+	if len(ecfg.AllowPeers) == 0 && len(ecfg.BlockPeers) == 0 && ecfg.BanThreshold < 0 {
+		return nil, nil
+	}
+
+	policy := peerpolicy.New()
+	for name, opt := range map[string]struct {
+		peers []string
+		add   func(...peer.ID)
+	}{
+		"AllowPeers": {ecfg.AllowPeers, policy.Allow},
+		"BlockPeers": {ecfg.BlockPeers, policy.Block},
+	} {
+		for _, s := range opt.peers {
+			pid, err := peer.IDB58Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("parsing Exchange.%s: %s", name, err)
+			}
+			opt.add(pid)
+		}
+	}
+
+	switch {
+	case ecfg.BanThreshold < 0:
+		policy.BanThreshold = 0
+	case ecfg.BanThreshold > 0:
+		policy.BanThreshold = ecfg.BanThreshold
+	}
+	if ecfg.BanDuration != "" {
+		d, err := time.ParseDuration(ecfg.BanDuration)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Exchange.BanDuration: %s", err)
+		}
+		policy.BanDuration = d
+	}
+	return policy, nil
+}
+
+func uploadLimits(ecfg cfg.Exchange) (bitswap.UploadLimits, error) {
+	var limits bitswap.UploadLimits
+	for name, opt := range map[string]struct {
+		val   string
+		field *int64
+	}{
+		"UploadRateLimit":     {ecfg.UploadRateLimit, &limits.GlobalRate},
+		"UploadRateBurst":     {ecfg.UploadRateBurst, &limits.GlobalBurst},
+		"PeerUploadRateLimit": {ecfg.PeerUploadRateLimit, &limits.PeerRate},
+		"PeerUploadRateBurst": {ecfg.PeerUploadRateBurst, &limits.PeerBurst},
+	} {
+		if opt.val == "" {
+			continue
+		}
+		n, err := humanize.ParseBytes(opt.val)
+		if err != nil {
+			return limits, fmt.Errorf("parsing Exchange.%s: %s", name, err)
+		}
+		*opt.field = int64(n)
+	}
+	return limits, nil
+}
+
+// evictionPeriod parses Datastore.Eviction.Period.
+func evictionPeriod(ecfg cfg.Eviction) (time.Duration, error) {
+	if ecfg.Period == "" {
+		return defaultEvictionPeriod, nil
+	}
+	period, err := time.ParseDuration(ecfg.Period)
+	if err != nil {
+		return 0, fmt.Errorf("parsing Datastore.Eviction.Period: %s", err)
+	}
+	if period <= 0 {
+		return 0, fmt.Errorf("Datastore.Eviction.Period must be positive")
+	}
+	return period, nil
+}
+
+// tieredBlockstore puts hot in front of a cold tier stored under the
+// configured prefix of d.
+func tieredBlockstore(ctx context.Context, hot bstore.Blockstore, d ds.Batching, tcfg cfg.TieredBlocks) (bstore.Blockstore, error) {
+	capacity := tcfg.HotCapacity
+	if capacity == "" {
+		capacity = defaultTieredHotCapacity
+	}
+	n, err := humanize.ParseBytes(capacity)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Datastore.Tiered.HotCapacity: %s", err)
+	}
+
+	prefix := tcfg.ColdPrefix
+	if prefix == "" {
+		prefix = defaultTieredColdPrefix
+	}
+	cold := bstore.NewBlockstore(dsns.Wrap(d, ds.NewKey(prefix)))
+
+	return tieredbs.New(ctx, hot, cold, d, n)
+}
+
+// quotaEnabled returns whether qcfg limits any of the blocks.
+func quotaEnabled(qcfg cfg.Quota) bool {
+	return qcfg.Local != (cfg.QuotaLimit{}) || qcfg.Network != (cfg.QuotaLimit{})
+}
+
+func quotaOptions(qcfg cfg.Quota) (quota.Options, error) {
+	opts := quota.Options{
+		Local:      quota.Limit{MaxBlocks: qcfg.Local.MaxBlocks},
+		Network:    quota.Limit{MaxBlocks: qcfg.Network.MaxBlocks},
+		Thresholds: qcfg.Thresholds,
+	}
+	if len(opts.Thresholds) == 0 {
+		opts.Thresholds = defaultQuotaThresholds
+	}
+	for name, opt := range map[string]struct {
+		val   string
+		field *uint64
+	}{
+		"Local.MaxSize":   {qcfg.Local.MaxSize, &opts.Local.MaxBytes},
+		"Network.MaxSize": {qcfg.Network.MaxSize, &opts.Network.MaxBytes},
+	} {
+		if opt.val == "" {
+			continue
+		}
+		n, err := humanize.ParseBytes(opt.val)
+		if err != nil {
+			return opts, fmt.Errorf("parsing Datastore.Quota.%s: %s", name, err)
+		}
+		*opt.field = n
+	}
+	return opts, nil
+}
+
+func remotePinManager(n *IpfsNode, conf cfg.Pinning) *pin.RemoteManager {
+	services := make(map[string]*remote.Client, len(conf.RemoteServices))
+	for name, svc := range conf.RemoteServices {
+		services[name] = remote.NewClient(svc.Endpoint, svc.Key)
+	}
+
+	m := pin.NewRemoteManager(n.Repo.Datastore(), n.Pinning, services)
+	m.Origins = func() []string {
+		if n.PeerHost == nil {
+			return nil
+		}
+		var addrs []string
+		for _, a := range n.PeerHost.Addrs() {
+			addrs = append(addrs, a.String()+"/ipfs/"+n.Identity.Pretty())
+		}
+		return addrs
+	}
+	return m
+}
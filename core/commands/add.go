@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	gopath "path"
 	"strings"
 
+	quota "github.com/ipfs/go-ipfs/blocks/quota"
 	blockservice "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/core/coreunix"
@@ -14,6 +16,7 @@ import (
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
 	mfs "github.com/ipfs/go-ipfs/mfs"
+	path "github.com/ipfs/go-ipfs/path"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 
 	pb "github.com/cheggaaa/pb"
@@ -21,6 +24,7 @@ import (
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	files "github.com/ipfs/go-ipfs-cmdkit/files"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
 	mh "github.com/multiformats/go-multihash"
 )
 
@@ -33,6 +37,10 @@ const (
 	silentOptionName      = "silent"
 	progressOptionName    = "progress"
 	trickleOptionName     = "trickle"
+	layoutOptionName      = "layout"
+	preserveModeName      = "preserve-mode"
+	preserveMtimeName     = "preserve-mtime"
+	preserveXAttrsName    = "preserve-xattrs"
 	wrapOptionName        = "wrap-with-directory"
 	hiddenOptionName      = "hidden"
 	onlyHashOptionName    = "only-hash"
@@ -43,10 +51,19 @@ const (
 	fstoreCacheOptionName = "fscache"
 	cidVersionOptionName  = "cid-version"
 	hashOptionName        = "hash"
+	syncOptionName        = "sync"
+	symlinksOptionName    = "symlinks"
+	reproducibleName      = "reproducible"
+	inlineOptionName      = "inline"
+	inlineLimitOptionName = "inline-limit"
 )
 
 const adderOutChanSize = 8
 
+// maxInlineLimit is the size of the largest blocks inlined, so that CIDs stay
+// short enough to be used in paths and links.
+const maxInlineLimit = 128
+
 var AddCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Add a file or directory to ipfs.",
@@ -77,10 +94,70 @@ The chunker option, '-s', specifies the chunking strategy that dictates
 how to break files into blocks. Blocks with same content can
 be deduplicated. The default is a fixed block size of
 256 * 1024 bytes, 'size-262144'. Alternatively, you can use the
-rabin chunker for content defined chunking by specifying
-rabin-[min]-[avg]-[max] (where min/avg/max refer to the resulting
-chunk sizes). Using other chunking strategies will produce
-different hashes for the same file.
+rabin or buzhash chunkers for content defined chunking by specifying
+rabin-[min]-[avg]-[max] or buzhash-[min]-[avg]-[max] (where
+min/avg/max refer to the resulting chunk sizes), or only 'rabin-[avg]'
+or 'buzhash-[avg]' for a min of avg/3 and a max of avg*3/2. The presets
+'small' ('size-65536') and 'large' ('size-1048576') are shortcuts for
+common block sizes. Using other chunking strategies will produce
+different hashes for the same file, but the same chunker always produces
+the same hash for the same file, on any node.
+
+The layout option, '--layout', specifies how the chunks are arranged in
+the dag: 'balanced' (the default) or 'trickle', which is the same as
+'--trickle', or a layout registered by a plugin. The name of a layout
+provided by a plugin is recorded in the root of the dag, so the file can
+be added again the same way.
+
+The '--inline' option inlines the blocks of up to '--inline-limit' bytes
+(32 by default, 128 at most) in their CIDs, with the identity hash, instead
+of storing them: the CID holds the data of the block. This saves storing,
+providing and fetching many tiny blocks when adding lots of small files.
+It implies CIDv1.
+
+The progress option, '-p', streams the progress of each file, shown as a
+progress bar on the command line: the 'Event' of the updates is 'started'
+before the file is read, 'hashing' with the bytes hashed so far, and
+'completed' once its blocks are written, with the number of blocks
+written ('Blocks') and how many of them were already stored ('Dedup').
+
+The '--preserve-mode', '--preserve-mtime' and '--preserve-xattrs' options
+record the permissions, modification time and extended attributes of the
+files and directories added in their unixfs nodes, which 'ipfs get'
+restores. They change the hashes of the files, and only apply to files
+read from disk by the process adding them: files sent to a daemon over
+the API don't carry these attributes.
+
+The '--sync' option takes the path of a previous add of the same files,
+such as the previous version of a website, and reuses the hashes of the
+files which didn't change since instead of reading them again: a file is
+considered unchanged if its size, and its modification time, recorded by
+'--preserve-mtime' which '--sync' implies, are the same. Only the files
+changed are chunked, so a large tree with a few changes is added again
+quickly, and so is an interrupted sync started again. Like the
+'--preserve-*' options, it only applies to files read from disk by the
+process adding them.
+
+The '--symlinks' option sets how symlinks are added: 'preserve', the
+default, adds them as unixfs symlinks, 'follow' adds the files and
+directories they point to in their place, and 'forbid' fails the add.
+Symlinks are followed by the process reading the files, and loops of
+symlinks to directories fail the add. Files with several hard links are
+only read once: the other links to them reuse their hash.
+
+The '--reproducible' option adds the files the same way on any node and
+with any configuration, so the same tree always gets the same hash: the
+default chunker and balanced layout build CIDv0 nodes without raw leaves,
+no attributes are recorded, and the links of directories are sorted by
+name. The DAG added is checked to be built this way before the add
+completes. It can't be combined with the options changing how files are
+added, and fails if the node shards directories other than the default
+way. 'ipfs dag export' of such a DAG gives the same CAR file on any node,
+so continuous integration can check the hash of a website to publish:
+
+  > test "$(ipfs add -r -Q --reproducible site)" = QmPublishedSite
+
+  > ipfs add -r --sync=/ipfs/QmOldSite site
 
   > ipfs add --chunker=size-2048 ipfs-logo.svg
   added QmafrLBfzRLV4XSH1XcaMMeaXEUhDJjmtDfsYU95TrWG87 ipfs-logo.svg
@@ -109,18 +186,41 @@ You can now check what blocks have been created by:
 		cmdkit.BoolOption(silentOptionName, "Write no output."),
 		cmdkit.BoolOption(progressOptionName, "p", "Stream progress data."),
 		cmdkit.BoolOption(trickleOptionName, "t", "Use trickle-dag format for dag generation."),
+		cmdkit.BoolOption(preserveModeName, "Record the permissions of the files added."),
+		cmdkit.BoolOption(preserveMtimeName, "Record the modification time of the files added."),
+		cmdkit.BoolOption(preserveXAttrsName, "Record the extended attributes of the files added (linux only)."),
+		cmdkit.StringOption(syncOptionName, "Reuse the unchanged files of this previous add of the same files. Implies --preserve-mtime."),
+		cmdkit.StringOption(symlinksOptionName, "How to add symlinks: preserve, follow or forbid.").WithDefault("preserve"),
+		cmdkit.BoolOption(reproducibleName, "Add the files the same way with any configuration, and check it."),
+		cmdkit.StringOption(layoutOptionName, "Layout of the dag, balanced, trickle, or one provided by a plugin. Overridden by --trickle.").WithDefault("balanced"),
 		cmdkit.BoolOption(onlyHashOptionName, "n", "Only chunk and hash - do not write to disk."),
 		cmdkit.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
 		cmdkit.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add."),
-		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes] or rabin-[min]-[avg]-[max]").WithDefault("size-262144"),
+		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max], buzhash-[min]-[avg]-[max] or a preset").WithDefault("size-262144"),
 		cmdkit.BoolOption(pinOptionName, "Pin this object when adding.").WithDefault(true),
 		cmdkit.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
 		cmdkit.BoolOption(noCopyOptionName, "Add the file using filestore. Implies raw-leaves. (experimental)"),
 		cmdkit.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
 		cmdkit.IntOption(cidVersionOptionName, "CID version. Defaults to 0 unless an option that depends on CIDv1 is passed. (experimental)"),
 		cmdkit.StringOption(hashOptionName, "Hash function to use. Implies CIDv1 if not sha2-256. (experimental)").WithDefault("sha2-256"),
+		cmdkit.BoolOption(inlineOptionName, "Inline small blocks into their CIDs. Implies CIDv1. (experimental)"),
+		cmdkit.IntOption(inlineLimitOptionName, "Maximum size of the blocks to inline. (experimental)").WithDefault(32),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		symlinks, _ := req.Options[symlinksOptionName].(string)
+		policy, err := coreunix.ParseSymlinkPolicy(symlinks)
+		if err != nil {
+			return err
+		}
+		// symlinks are followed here, where the files are read, so the
+		// daemon receives the files they point to
+		if policy == coreunix.SymlinksFollow && req.Files != nil {
+			req.Files, err = coreunix.FollowSymlinks(req.Files)
+			if err != nil {
+				return err
+			}
+		}
+
 		quiet, _ := req.Options[quietOptionName].(bool)
 		quieter, _ := req.Options[quieterOptionName].(bool)
 		quiet = quiet || quieter
@@ -161,8 +261,16 @@ You can now check what blocks have been created by:
 
 		progress, _ := req.Options[progressOptionName].(bool)
 		trickle, _ := req.Options[trickleOptionName].(bool)
+		layout, _ := req.Options[layoutOptionName].(string)
+		preserveMode, _ := req.Options[preserveModeName].(bool)
+		preserveMtime, _ := req.Options[preserveMtimeName].(bool)
+		preserveXAttrs, _ := req.Options[preserveXAttrsName].(bool)
 		wrap, _ := req.Options[wrapOptionName].(bool)
 		hash, _ := req.Options[onlyHashOptionName].(bool)
+		if n.ReadOnly() && !hash {
+			res.SetError(core.ErrReadOnly, cmdkit.ErrNormal)
+			return
+		}
 		hidden, _ := req.Options[hiddenOptionName].(bool)
 		silent, _ := req.Options[silentOptionName].(bool)
 		chunker, _ := req.Options[chunkerOptionName].(string)
@@ -172,12 +280,34 @@ You can now check what blocks have been created by:
 		fscache, _ := req.Options[fstoreCacheOptionName].(bool)
 		cidVer, cidVerSet := req.Options[cidVersionOptionName].(int)
 		hashFunStr, _ := req.Options[hashOptionName].(string)
+		syncPath, _ := req.Options[syncOptionName].(string)
+		symlinks, _ := req.Options[symlinksOptionName].(string)
+		reproducible, _ := req.Options[reproducibleName].(bool)
+		inline, _ := req.Options[inlineOptionName].(bool)
+		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
+
+		// reproducible -> the default settings
+		if reproducible {
+			for _, name := range []string{trickleOptionName, preserveModeName, preserveMtimeName,
+				preserveXAttrsName, syncOptionName, rawLeavesOptionName, noCopyOptionName,
+				cidVersionOptionName, inlineOptionName} {
+				if _, set := req.Options[name]; set {
+					res.SetError(fmt.Errorf("--%s can't be used with --%s", name, reproducibleName), cmdkit.ErrClient)
+					return
+				}
+			}
+			if (layout != "" && layout != "balanced") || (chunker != "" && chunker != "size-262144") || hashFunStr != "sha2-256" {
+				res.SetError(fmt.Errorf("--%s needs the default layout, chunker and hash function", reproducibleName), cmdkit.ErrClient)
+				return
+			}
+		}
 
 		// The arguments are subject to the following constraints.
 		//
 		// nocopy -> filestoreEnabled
 		// nocopy -> rawblocks
 		// (hash != sha2-256) -> cidv1
+		// inline -> cidv1
 
 		// NOTE: 'rawblocks -> cidv1' is missing. Legacy reasons.
 
@@ -214,6 +344,22 @@ You can now check what blocks have been created by:
 			cidVer = 1
 		}
 
+		// inline -> CIDv1
+		if inline && cidVer == 0 {
+			if cidVerSet {
+				res.SetError(
+					errors.New("CIDv0 can't inline blocks"),
+					cmdkit.ErrClient,
+				)
+				return
+			}
+			cidVer = 1
+		}
+		if inline && inlineLimit > maxInlineLimit {
+			res.SetError(fmt.Errorf("blocks of more than %d bytes can't be inlined", maxInlineLimit), cmdkit.ErrClient)
+			return
+		}
+
 		// cidV1 -> raw blocks (by default)
 		if cidVer > 0 && !rbset {
 			rawblks = true
@@ -234,6 +380,29 @@ You can now check what blocks have been created by:
 		prefix.MhType = hashFunCode
 		prefix.MhLength = -1
 
+		symlinkPolicy, err := coreunix.ParseSymlinkPolicy(symlinks)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		// sync -> preserve-mtime
+		var syncRoot ipld.Node
+		syncServ := n.DAG
+		if syncPath != "" {
+			p, err := path.ParsePath(syncPath)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			syncRoot, err = core.Resolve(req.Context, n.Namesys, n.Resolver, p)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			preserveMtime = true
+		}
+
 		if hash {
 			nilnode, err := core.NewNode(n.Context(), &core.BuildCfg{
 				//TODO: need this to be true or all files
@@ -250,6 +419,9 @@ You can now check what blocks have been created by:
 		addblockstore := n.Blockstore
 		if !(fscache || nocopy) {
 			addblockstore = bstore.NewGCBlockstore(n.BaseBlocks, n.GCLocker)
+			if n.Quota != nil {
+				addblockstore = n.Quota.Wrap(addblockstore, quota.Local)
+			}
 		}
 
 		exch := n.Exchange
@@ -259,7 +431,14 @@ You can now check what blocks have been created by:
 		}
 
 		bserv := blockservice.New(addblockstore, exch) // hash security 001
+		// add everything in one transaction, so that an interrupted add
+		// doesn't leave a partial DAG behind. Only hashing stores nothing.
+		var txn *blockservice.Txn
 		dserv := dag.NewDAGService(bserv)
+		if !hash {
+			txn = blockservice.NewTxn(bserv, n.Repo.Datastore(), n.TxnGuard())
+			dserv = dag.NewDAGService(txn)
+		}
 
 		outChan := make(chan interface{}, adderOutChanSize)
 
@@ -274,12 +453,30 @@ You can now check what blocks have been created by:
 		fileAdder.Progress = progress
 		fileAdder.Hidden = hidden
 		fileAdder.Trickle = trickle
+		fileAdder.Layout = layout
+		fileAdder.PreserveMode = preserveMode
+		fileAdder.PreserveMtime = preserveMtime
+		fileAdder.PreserveXAttrs = preserveXAttrs
+		fileAdder.Symlinks = symlinkPolicy
+		if inline {
+			fileAdder.InlineLimit = inlineLimit
+		}
+		fileAdder.MimeIndex = n.MimeIndex
 		fileAdder.Wrap = wrap
 		fileAdder.Pin = dopin
 		fileAdder.Silent = silent
 		fileAdder.RawLeaves = rawblks
 		fileAdder.NoCopy = nocopy
 		fileAdder.Prefix = &prefix
+		if syncRoot != nil {
+			fileAdder.SetSyncRoot(syncRoot, syncServ)
+		}
+		if reproducible {
+			if err := fileAdder.SetReproducible(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
 
 		if hash {
 			md := dagtest.Mock()
@@ -292,7 +489,34 @@ You can now check what blocks have been created by:
 			fileAdder.SetMfsRoot(mr)
 		}
 
-		addAllAndPin := func(f files.File) error {
+		// commitAndPin commits the transaction and pins the root under the
+		// pin lock, so that rollbacks of other adds see the pins
+		commitAndPin := func() error {
+			if txn == nil {
+				return nil
+			}
+			defer n.Blockstore.PinLock().Unlock()
+
+			if err := txn.Commit(); err != nil {
+				aerr, ok := err.(*blockservice.AnnounceError)
+				if !ok {
+					return err
+				}
+				// the blocks are stored, they are announced on reprovide
+				log.Warningf("add: %s", aerr)
+			}
+			return fileAdder.PinRoot()
+		}
+
+		addAllAndPin := func(f files.File) (err error) {
+			defer func() {
+				if err != nil && txn != nil {
+					if aerr := txn.Abort(); aerr != nil && aerr != blockservice.ErrTxnDone {
+						log.Errorf("rolling back add: %s", aerr)
+					}
+				}
+			}()
+
 			// Iterate over each top-level file and add individually. Otherwise the
 			// single files.File f is treated as a directory, affecting hidden file
 			// semantics.
@@ -310,16 +534,18 @@ You can now check what blocks have been created by:
 			}
 
 			// copy intermediary nodes from editor to our actual dagservice
-			_, err := fileAdder.Finalize()
+			root, err := fileAdder.Finalize()
 			if err != nil {
 				return err
 			}
 
-			if hash {
-				return nil
+			if reproducible {
+				if err := coreunix.VerifyReproducible(req.Context, dserv, root.Cid()); err != nil {
+					return err
+				}
 			}
 
-			return fileAdder.PinRoot()
+			return commitAndPin()
 		}
 
 		errCh := make(chan error)
@@ -424,12 +650,20 @@ You can now check what blocks have been created by:
 								continue
 							}
 
-							if len(lastFile) == 0 {
-								lastFile = output.Name
-							}
-							if output.Name != lastFile || output.Bytes < lastBytes {
+							switch output.Event {
+							case coreunix.ProgressStarted:
 								prevFiles += lastBytes
 								lastFile = output.Name
+								bar.Prefix(gopath.Base(output.Name) + " ")
+							case "":
+								// daemons without progress events only send the bytes
+								if len(lastFile) == 0 {
+									lastFile = output.Name
+								}
+								if output.Name != lastFile || output.Bytes < lastBytes {
+									prevFiles += lastBytes
+									lastFile = output.Name
+								}
 							}
 							lastBytes = output.Bytes
 							delta := prevFiles + lastBytes - totalProgress
@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	"github.com/ipfs/go-ipfs/core/coreunix"
+	path "github.com/ipfs/go-ipfs/path"
+	uarchive "github.com/ipfs/go-ipfs/unixfs/archive"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var ArchiveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import and export tar and zip archives as unixfs directories.",
+		ShortDescription: `
+'ipfs archive add' adds the content of a tar or zip archive as the unixfs
+directory it holds, and 'ipfs archive get' writes a unixfs directory or
+file as a tar or zip archive, without extracting them to disk.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add": archiveAddCmd,
+		"get": archiveGetCmd,
+	},
+}
+
+var archiveAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add the content of a tar or zip archive.",
+		ShortDescription: `
+'ipfs archive add' adds the directories, files and symlinks held by a tar
+archive, compressed with gzip or not, or a zip archive, and prints the hash
+of the directory holding them. The format of the archive is detected from
+its content.
+
+The '--preserve-mode', '--preserve-mtime' and '--preserve-xattrs' options
+record the permissions, modification time and extended attributes of the
+entries, as 'ipfs add' does.
+
+Zip archives can only be read from their end, so they are copied to a
+temporary file first.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "Archive to add.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(preserveModeName, "Record the permissions of the entries added."),
+		cmdkit.BoolOption(preserveMtimeName, "Record the modification time of the entries added."),
+		cmdkit.BoolOption(preserveXAttrsName, "Record the extended attributes of the entries added."),
+		cmdkit.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
+		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max], buzhash-[min]-[avg]-[max] or a preset").WithDefault("size-262144"),
+		cmdkit.BoolOption(pinOptionName, "Pin the directory added.").WithDefault(true),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		fi, err := req.Files.NextFile()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer fi.Close()
+
+		adder, err := coreunix.NewAdder(req.Context, n.Pinning, n.Blockstore, n.DAG)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		adder.Wrap = true
+		adder.PreserveMode, _ = req.Options[preserveModeName].(bool)
+		adder.PreserveMtime, _ = req.Options[preserveMtimeName].(bool)
+		adder.PreserveXAttrs, _ = req.Options[preserveXAttrsName].(bool)
+		adder.RawLeaves, _ = req.Options[rawLeavesOptionName].(bool)
+		adder.Chunker, _ = req.Options[chunkerOptionName].(string)
+		adder.Pin, _ = req.Options[pinOptionName].(bool)
+
+		if err := addArchive(adder, fi); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		root, err := adder.Finalize()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if err := adder.PinRoot(); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.Emit(&coreunix.AddedObject{
+			Name: fi.FileName(),
+			Hash: root.Cid().String(),
+		})
+	},
+	Type: coreunix.AddedObject{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*coreunix.AddedObject)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			_, err := fmt.Fprintln(w, out.Hash)
+			return err
+		}),
+	},
+}
+
+// addArchive adds the archive read from r with adder, detecting its format.
+func addArchive(adder *coreunix.Adder, r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+		return adder.AddTar(gzr)
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		tmp, err := ioutil.TempFile("", "ipfs-archive")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		size, err := io.Copy(tmp, br)
+		if err != nil {
+			return err
+		}
+		return adder.AddZip(tmp, size)
+	default:
+		return adder.AddTar(br)
+	}
+}
+
+var archiveGetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write a unixfs directory or file as a tar or zip archive.",
+		ShortDescription: `
+'ipfs archive get' writes the directory or file at the given path as a tar
+archive, or as a zip archive with '--format=zip', to the standard output.
+The entries of the directories are written in the order of their names,
+and the ones which don't record their modification time get a fixed one,
+so the same directory always gives the same archive.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, false, "The path to the directory or file to archive.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("format", "f", "Format of the archive, tar or zip.").WithDefault("tar"),
+		cmdkit.BoolOption("compress", "C", "Compress the tar archive with GZIP compression."),
+		cmdkit.IntOption("compression-level", "l", "The level of compression (1-9)."),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		_, err := getCompressOptions(req)
+		return err
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		cmplvl, err := getCompressOptions(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+		nd, err := core.Resolve(req.Context, n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		var r io.Reader
+		switch format, _ := req.Options["format"].(string); format {
+		case "tar":
+			r, err = uarchive.DagArchive(req.Context, nd, p.String(), n.DAG, true, cmplvl)
+		case "zip":
+			if cmplvl != gzip.NoCompression {
+				res.SetError(fmt.Errorf("zip archives are always compressed"), cmdkit.ErrClient)
+				return
+			}
+			r, err = uarchive.DagZipArchive(req.Context, nd, p.String(), n.DAG)
+		default:
+			res.SetError(fmt.Errorf("unknown archive format: %s", format), cmdkit.ErrClient)
+			return
+		}
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.Emit(r)
+	},
+}
@@ -2,9 +2,11 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
@@ -29,6 +31,7 @@ var BitswapCmd = &cmds.Command{
 		"wantlist":  lgc.NewCommand(showWantlistCmd),
 		"unwant":    lgc.NewCommand(unwantCmd),
 		"ledger":    lgc.NewCommand(ledgerCmd),
+		"ratelimit": lgc.NewCommand(rateLimitCmd),
 		"reprovide": lgc.NewCommand(reprovideCmd),
 	},
 }
@@ -219,9 +222,9 @@ prints the ledger associated with a given peer.
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		st, ok := bserv.ExchangeStats(nd.Blocks)
 		if !ok {
-			res.SetError(e.TypeErr(bs, nd.Exchange), cmdkit.ErrNormal)
+			res.SetError(errors.New("exchange does not keep ledgers"), cmdkit.ErrNormal)
 			return
 		}
 
@@ -230,7 +233,17 @@ prints the ledger associated with a given peer.
 			res.SetError(err, cmdkit.ErrClient)
 			return
 		}
-		res.SetOutput(bs.LedgerForPeer(partner))
+
+		ps := st.PeerStats(partner)
+		res.SetOutput(&decision.Receipt{
+			Peer:       ps.Peer.Pretty(),
+			Value:      ps.DebtRatio,
+			Sent:       ps.BytesSent,
+			Recv:       ps.BytesRecv,
+			Exchanged:  ps.Exchanges,
+			BlocksSent: ps.BlocksSent,
+			BlocksRecv: ps.BlocksRecv,
+		})
 	},
 	Marshalers: oldcmds.MarshalerMap{
 		oldcmds.Text: func(res oldcmds.Response) (io.Reader, error) {
@@ -249,9 +262,107 @@ prints the ledger associated with a given peer.
 				"Debt ratio:\t%f\n"+
 				"Exchanges:\t%d\n"+
 				"Bytes sent:\t%d\n"+
-				"Bytes received:\t%d\n\n",
+				"Bytes received:\t%d\n"+
+				"Blocks sent:\t%d\n"+
+				"Blocks received:\t%d\n\n",
 				out.Peer, out.Value, out.Exchanged,
-				out.Sent, out.Recv)
+				out.Sent, out.Recv, out.BlocksSent, out.BlocksRecv)
+			return buf, nil
+		},
+	},
+}
+
+var rateLimitCmd = &oldcmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show or change the upload rate limits for serving blocks.",
+		ShortDescription: `
+Prints the current bitswap upload rate limits, after applying the given
+changes. Rates are per second, e.g. '1MB'. A rate of 0 means unlimited. A burst
+of 0 allows one second worth of the rate.
+
+Changes are not persisted, set Exchange.UploadRateLimit and related options in
+the config for that.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("global", "Total upload rate."),
+		cmdkit.StringOption("global-burst", "Total upload burst size."),
+		cmdkit.StringOption("peer", "Upload rate to each peer."),
+		cmdkit.StringOption("peer-burst", "Upload burst size to each peer."),
+	},
+	Type: bitswap.UploadLimits{},
+	Run: func(req oldcmds.Request, res oldcmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		if !ok {
+			res.SetError(e.TypeErr(bs, nd.Exchange), cmdkit.ErrNormal)
+			return
+		}
+
+		limits := bs.UploadLimits()
+		changed := false
+		for opt, field := range map[string]*int64{
+			"global":       &limits.GlobalRate,
+			"global-burst": &limits.GlobalBurst,
+			"peer":         &limits.PeerRate,
+			"peer-burst":   &limits.PeerBurst,
+		} {
+			v, found, err := req.Option(opt).String()
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			if !found {
+				continue
+			}
+			n, err := humanize.ParseBytes(v)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid %s: %s", opt, err), cmdkit.ErrClient)
+				return
+			}
+			*field = int64(n)
+			changed = true
+		}
+
+		if changed {
+			bs.SetUploadLimits(limits)
+		}
+		res.SetOutput(&limits)
+	},
+	Marshalers: oldcmds.MarshalerMap{
+		oldcmds.Text: func(res oldcmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*bitswap.UploadLimits)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			rate := func(n int64) string {
+				if n == 0 {
+					return "unlimited"
+				}
+				return humanize.Bytes(uint64(n)) + "/s"
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Global rate:\t%s\n", rate(out.GlobalRate))
+			fmt.Fprintf(buf, "Global burst:\t%s\n", humanize.Bytes(uint64(out.GlobalBurst)))
+			fmt.Fprintf(buf, "Peer rate:\t%s\n", rate(out.PeerRate))
+			fmt.Fprintf(buf, "Peer burst:\t%s\n", humanize.Bytes(uint64(out.PeerBurst)))
 			return buf, nil
 		},
 	},
@@ -23,6 +23,7 @@ func TestROCommands(t *testing.T) {
 		"/cat",
 		"/commands",
 		"/dag",
+		"/dag/export",
 		"/dag/get",
 		"/dag/resolve",
 		"/dns",
@@ -69,8 +70,12 @@ func TestROCommands(t *testing.T) {
 func TestCommands(t *testing.T) {
 	list := []string{
 		"/add",
+		"/archive",
+		"/archive/add",
+		"/archive/get",
 		"/bitswap",
 		"/bitswap/ledger",
+		"/bitswap/ratelimit",
 		"/bitswap/reprovide",
 		"/bitswap/stat",
 		"/bitswap/unwant",
@@ -91,17 +96,23 @@ func TestCommands(t *testing.T) {
 		"/config",
 		"/config/edit",
 		"/config/replace",
+		"/config/reload",
 		"/config/show",
 		"/config/profile",
 		"/config/profile/apply",
 		"/dag",
+		"/dag/export",
 		"/dag/get",
+		"/dag/graft",
+		"/dag/import",
 		"/dag/put",
 		"/dag/resolve",
+		"/dag/stat",
 		"/dht",
 		"/dht/findpeer",
 		"/dht/findprovs",
 		"/dht/get",
+		"/dht/mode",
 		"/dht/provide",
 		"/dht/put",
 		"/dht/query",
@@ -109,8 +120,10 @@ func TestCommands(t *testing.T) {
 		"/diag/cmds",
 		"/diag/cmds/clear",
 		"/diag/cmds/set-time",
+		"/diag/profile",
 		"/diag/sys",
 		"/dns",
+		"/events",
 		"/file",
 		"/file/ls",
 		"/files",
@@ -124,9 +137,12 @@ func TestCommands(t *testing.T) {
 		"/files/rm",
 		"/files/stat",
 		"/filestore",
+		"/filestore/clean",
 		"/filestore/dups",
 		"/filestore/ls",
 		"/filestore/verify",
+		"/files/watch",
+		"/files/quota",
 		"/files/write",
 		"/get",
 		"/id",
@@ -136,10 +152,12 @@ func TestCommands(t *testing.T) {
 		"/key/rename",
 		"/key/rm",
 		"/log",
+		"/log/dump",
 		"/log/level",
 		"/log/ls",
 		"/log/tail",
 		"/ls",
+		"/mode",
 		"/mount",
 		"/name",
 		"/name/publish",
@@ -173,10 +191,30 @@ func TestCommands(t *testing.T) {
 		"/pin",
 		"/pin/add",
 		"/ping",
+		"/pin/export",
+		"/pin/import",
+		"/pin/label",
 		"/pin/ls",
+		"/pin/partial",
+		"/pin/partial/add",
+		"/pin/partial/ls",
+		"/pin/partial/rm",
+		"/pin/remote",
+		"/pin/remote/add",
+		"/pin/remote/ls",
+		"/pin/remote/reconcile",
+		"/pin/remote/rm",
+		"/pin/replicate",
+		"/pin/replicate/add",
+		"/pin/replicate/rm",
+		"/pin/replicate/status",
 		"/pin/rm",
 		"/pin/update",
 		"/pin/verify",
+		"/provider",
+		"/provider/add",
+		"/provider/ls",
+		"/provider/rm",
 		"/pubsub",
 		"/pubsub/ls",
 		"/pubsub/peers",
@@ -185,8 +223,13 @@ func TestCommands(t *testing.T) {
 		"/refs",
 		"/refs/local",
 		"/repo",
+		"/repo/blockstat",
+		"/repo/compact",
 		"/repo/fsck",
 		"/repo/gc",
+		"/repo/scrub",
+		"/repo/scrub/findings",
+		"/repo/scrub/status",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
@@ -195,7 +238,9 @@ func TestCommands(t *testing.T) {
 		"/stats",
 		"/stats/bitswap",
 		"/stats/bw",
+		"/stats/gc",
 		"/stats/repo",
+		"/stats/resources",
 		"/swarm",
 		"/swarm/addrs",
 		"/swarm/addrs/listen",
@@ -205,11 +250,18 @@ func TestCommands(t *testing.T) {
 		"/swarm/filters",
 		"/swarm/filters/add",
 		"/swarm/filters/rm",
+		"/swarm/nat",
+		"/swarm/peering",
+		"/swarm/peering/add",
+		"/swarm/peering/ls",
+		"/swarm/peering/rm",
 		"/swarm/peers",
 		"/tar",
 		"/tar/add",
 		"/tar/cat",
 		"/update",
+		"/urlstore",
+		"/urlstore/add",
 		"/version",
 	}
 
@@ -9,9 +9,11 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
@@ -143,6 +145,7 @@ Set the value of the 'Datastore.Path' key:
 		"edit":    configEditCmd,
 		"replace": configReplaceCmd,
 		"profile": configProfileCmd,
+		"reload":  configReloadCmd,
 	},
 }
 
@@ -293,6 +296,70 @@ can't be undone.
 	},
 }
 
+var configReloadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Apply the changes of the config to the running daemon.",
+		ShortDescription: `
+'ipfs config reload' applies the changes made to the config since the daemon
+started, including edits of the config file, without restarting it. It prints
+the keys which were applied, and those which only take effect on restart.
+
+Only some sections can be applied while running: Addresses.Gateway,
+Swarm.ConnMgr and Reprovider.Strategy.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if nd.LocalMode() {
+			res.SetError(errors.New("daemon not running"), cmdkit.ErrClient)
+			return
+		}
+
+		out, err := nd.ReloadConfig()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*core.ConfigReload)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range out.Applied {
+				fmt.Fprintf(buf, "applied %s\n", k)
+			}
+			for _, k := range out.Restart {
+				fmt.Fprintf(buf, "restart required for %s\n", k)
+			}
+			sections := make([]string, 0, len(out.Errors))
+			for s := range out.Errors {
+				sections = append(sections, s)
+			}
+			sort.Strings(sections)
+			for _, s := range sections {
+				fmt.Fprintf(buf, "failed to apply %s: %s\n", s, out.Errors[s])
+			}
+			return buf, nil
+		},
+	},
+	Type: core.ConfigReload{},
+}
+
 var configProfileCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Apply profiles to config.",
@@ -2,14 +2,22 @@ package dagcmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"strings"
+	"time"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	car "github.com/ipfs/go-ipfs/car"
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	coredag "github.com/ipfs/go-ipfs/core/coredag"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
 
@@ -37,6 +45,10 @@ to deprecate and replace the existing 'ipfs object' command moving forward.
 		"put":     DagPutCmd,
 		"get":     DagGetCmd,
 		"resolve": DagResolveCmd,
+		"export":  DagExportCmd,
+		"import":  DagImportCmd,
+		"graft":   DagGraftCmd,
+		"stat":    DagStatCmd,
 	},
 }
 
@@ -102,8 +114,7 @@ into an object of the specified format.
 		res.SetOutput((<-chan interface{})(outChan))
 
 		addAllAndPin := func(f files.File) error {
-			cids := cid.NewSet()
-			b := ipld.NewBatch(req.Context(), n.DAG)
+			b := dag.NewBuilder(req.Context(), n.DAG)
 
 			for {
 				file, err := f.NextFile()
@@ -122,29 +133,30 @@ into an object of the specified format.
 					return fmt.Errorf("no node returned from ParseInputs")
 				}
 
-				for _, nd := range nds {
+				if err := b.AddRoot(nds[0]); err != nil {
+					return err
+				}
+				for _, nd := range nds[1:] {
 					err := b.Add(nd)
 					if err != nil {
 						return err
 					}
 				}
 
-				cid := nds[0].Cid()
-				cids.Add(cid)
-				outChan <- &OutputObject{Cid: cid}
+				outChan <- &OutputObject{Cid: nds[0].Cid()}
 			}
 
-			if err := b.Commit(); err != nil {
+			roots, err := b.Commit()
+			if err != nil {
 				return err
 			}
 
 			if dopin {
 				defer n.Blockstore.PinLock().Unlock()
 
-				cids.ForEach(func(c *cid.Cid) error {
+				for _, c := range roots {
 					n.Pinning.PinWithMode(c, pin.Recursive)
-					return nil
-				})
+				}
 
 				err := n.Pinning.Flush()
 				if err != nil {
@@ -282,6 +294,420 @@ var DagResolveCmd = &cmds.Command{
 	Type: ResolveOutput{},
 }
 
+var DagGraftCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Extract the DAG below a path as a standalone root.",
+		ShortDescription: `
+'ipfs dag graft' resolves a path into a larger DAG, like a folder of an
+archive, and prints the cid of the DAG below it, which can be shared on its
+own. No block is copied: the DAG keeps sharing its blocks with the DAG it was
+taken from.
+
+With --wrap, the DAG is wrapped in a new directory under the last name of the
+path, so the folder keeps its name. With --pin, the result is pinned, so it is
+kept even if the DAG it was taken from is unpinned.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "The path of the DAG to extract.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("wrap", "w", "Wrap the DAG in a directory, keeping its name."),
+		cmdkit.BoolOption("pin", "Pin the extracted DAG."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		nd, rem, err := n.Resolver.ResolveToLastNode(req.Context(), p)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if len(rem) > 0 {
+			res.SetError(fmt.Errorf("%s is a value within %s, not a DAG", path.Join(rem), nd.Cid()), cmdkit.ErrNormal)
+			return
+		}
+
+		var name string
+		if wrap, _, _ := req.Option("wrap").Bool(); wrap {
+			segs := p.Segments()
+			name = segs[len(segs)-1]
+		}
+
+		root, err := dagutils.Graft(req.Context(), n.DAG, nd, name)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if dopin, _, _ := req.Option("pin").Bool(); dopin {
+			defer n.Blockstore.PinLock().Unlock()
+
+			err := n.Pinning.Pin(req.Context(), root, true)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			err = n.Pinning.Flush()
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		res.SetOutput(&OutputObject{Cid: root.Cid()})
+	},
+	Type: OutputObject{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			oobj, ok := v.(*OutputObject)
+			if !ok {
+				return nil, e.TypeErr(oobj, v)
+			}
+
+			return strings.NewReader(oobj.Cid.String() + "\n"), nil
+		},
+	},
+}
+
+// StatOutput is the output type of 'dag stat' command
+type StatOutput struct {
+	*dag.DagStat
+
+	AvgFanout      float64
+	DuplicateRatio float64
+
+	// Done is set on the final statistics, after the progress updates.
+	Done bool
+}
+
+var DagStatCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print statistics about the shape of a DAG.",
+		ShortDescription: `
+'ipfs dag stat' walks the DAG below a path and prints its number of distinct
+nodes, their total size, its maximum depth, the average number of links of
+its nodes, the share of references pointing to nodes referenced before, and a
+histogram of the block sizes. It helps choosing chunking and layout options.
+
+With --local, only the blocks available locally are walked, and the others
+are counted as missing.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "The path of the DAG.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("local", "Don't fetch missing blocks from the network."),
+		cmdkit.BoolOption("progress", "p", "Report the progress of the walk."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		local, _, _ := req.Option("local").Bool()
+		showProgress, _, _ := req.Option("progress").Bool()
+
+		var ng ipld.NodeGetter = n.DAG
+		if local {
+			ng = dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+		}
+
+		root, err := core.ResolveToCid(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		outChan := make(chan interface{}, 8)
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+
+			var progress func(*dag.DagStat)
+			if showProgress {
+				var last time.Time
+				progress = func(st *dag.DagStat) {
+					if time.Since(last) < statProgressInterval {
+						return
+					}
+					last = time.Now()
+					cp := *st
+					cp.SizeHistogram = nil
+					outChan <- &StatOutput{DagStat: &cp}
+				}
+			}
+
+			st, err := dag.Stat(req.Context(), ng, root, progress)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			outChan <- &StatOutput{
+				DagStat:        st,
+				AvgFanout:      st.AvgFanout(),
+				DuplicateRatio: st.DuplicateRatio(),
+				Done:           true,
+			}
+		}()
+	},
+	Type: StatOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*StatOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			if !out.Done {
+				fmt.Fprintf(buf, "walked %d nodes (%d bytes)\n", out.Nodes, out.Size)
+				return buf, nil
+			}
+
+			fmt.Fprintf(buf, "Nodes:           %d\n", out.Nodes)
+			fmt.Fprintf(buf, "Size:            %d\n", out.Size)
+			if out.Missing > 0 {
+				fmt.Fprintf(buf, "Missing:         %d\n", out.Missing)
+			}
+			fmt.Fprintf(buf, "MaxDepth:        %d\n", out.MaxDepth)
+			fmt.Fprintf(buf, "AvgFanout:       %.2f\n", out.AvgFanout)
+			fmt.Fprintf(buf, "DuplicateRatio:  %.4f\n", out.DuplicateRatio)
+			fmt.Fprintf(buf, "Block sizes:\n")
+			for i, count := range out.SizeHistogram {
+				if count == 0 {
+					continue
+				}
+				low := 0
+				if i > 0 {
+					low = 1 << uint(i-1)
+				}
+				fmt.Fprintf(buf, "  %8d - %8d: %d\n", low, (1<<uint(i))-1, count)
+			}
+			return buf, nil
+		},
+	},
+}
+
+// statProgressInterval is the minimum time between the progress updates of
+// 'dag stat'.
+const statProgressInterval = 500 * time.Millisecond
+
+// ImportOutput is the output type of 'dag import' command
+type ImportOutput struct {
+	Root     *cid.Cid      `json:",omitempty"`
+	Progress *car.Progress `json:",omitempty"`
+}
+
+var DagExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export dag objects to a CAR file.",
+		ShortDescription: `
+'ipfs dag export' writes the DAGs below the given roots to stdout as a CARv1
+file, which 'ipfs dag import' reads back on another node.
+`,
+		LongDescription: `
+'ipfs dag export' writes the DAGs below the given roots to stdout as a CARv1
+file, which 'ipfs dag import' reads back on another node. Blocks missing
+locally are fetched from the network, unless the node is offline.
+
+With --all, every block in the repo is exported instead, with the recursively
+pinned objects as roots. This can be used to back up a repo.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root", false, true, "The roots of the DAGs to export.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("all", "a", "Export all blocks in the repo."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		all, _, _ := req.Option("all").Bool()
+		if all == (len(req.Arguments()) > 0) {
+			res.SetError(errors.New("specify either roots or --all"), cmdkit.ErrClient)
+			return
+		}
+
+		var roots []*cid.Cid
+		if all {
+			roots = n.Pinning.RecursiveKeys()
+			if len(roots) == 0 {
+				res.SetError(errors.New("no recursive pins to record as roots"), cmdkit.ErrNormal)
+				return
+			}
+		} else {
+			for _, arg := range req.Arguments() {
+				p, err := path.ParsePath(arg)
+				if err != nil {
+					res.SetError(err, cmdkit.ErrNormal)
+					return
+				}
+				c, err := core.ResolveToCid(req.Context(), n.Namesys, n.Resolver, p)
+				if err != nil {
+					res.SetError(err, cmdkit.ErrNormal)
+					return
+				}
+				roots = append(roots, c)
+			}
+		}
+
+		r, w := io.Pipe()
+		go func() {
+			if all {
+				unlocker := n.Blockstore.GCLock()
+				defer unlocker.Unlock()
+				w.CloseWithError(car.ExportBlockstore(req.Context(), n.Blockstore, roots, w, nil))
+				return
+			}
+			w.CloseWithError(car.ExportDAG(req.Context(), n.DAG, roots, w, nil))
+		}()
+
+		res.SetOutput(r)
+	},
+}
+
+var DagImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import the contents of CAR files.",
+		ShortDescription: `
+'ipfs dag import' adds the blocks of the given CARv1 files and pins the roots
+recorded in them. The data of every block is checked against its hash.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("path", true, true, "The CAR files to import.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("pin-roots", "Pin the roots of the imported DAGs.").WithDefault(true),
+		cmdkit.BoolOption("progress", "p", "Report the number of blocks imported."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		dopin, _, _ := req.Option("pin-roots").Bool()
+		showProgress, _, _ := req.Option("progress").Bool()
+
+		outChan := make(chan interface{}, 8)
+		res.SetOutput((<-chan interface{})(outChan))
+
+		importAll := func(f files.File) error {
+			if dopin {
+				// keep GC from removing the blocks before they are pinned
+				defer n.Blockstore.PinLock().Unlock()
+			}
+
+			var progress func(car.Progress)
+			if showProgress {
+				progress = func(p car.Progress) {
+					outChan <- &ImportOutput{Progress: &p}
+				}
+			}
+
+			var roots []*cid.Cid
+			for {
+				file, err := f.NextFile()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return err
+				}
+
+				h, err := car.Import(req.Context(), n.Blocks, file, progress)
+				file.Close()
+				if err != nil {
+					return fmt.Errorf("importing %s: %s", file.FileName(), err)
+				}
+				roots = append(roots, h.Roots...)
+			}
+
+			for _, c := range roots {
+				if dopin {
+					nd, err := n.DAG.Get(req.Context(), c)
+					if err != nil {
+						return err
+					}
+					if err := n.Pinning.Pin(req.Context(), nd, true); err != nil {
+						return fmt.Errorf("pinning root %s: %s", c, err)
+					}
+				}
+				outChan <- &ImportOutput{Root: c}
+			}
+
+			if dopin {
+				return n.Pinning.Flush()
+			}
+			return nil
+		}
+
+		go func() {
+			defer close(outChan)
+			if err := importAll(req.Files()); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}()
+	},
+	Type: ImportOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ImportOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			if out.Progress != nil {
+				return strings.NewReader(fmt.Sprintf("imported %d blocks (%d bytes)\n", out.Progress.Blocks, out.Progress.Bytes)), nil
+			}
+			return strings.NewReader(out.Root.String() + "\n"), nil
+		},
+	},
+}
+
 // copy+pasted from ../commands.go
 func unwrapOutput(i interface{}) (interface{}, error) {
 	var (
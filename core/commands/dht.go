@@ -16,7 +16,6 @@ import (
 	cid "github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	ipld "github.com/ipfs/go-ipld-format"
-	ipdht "github.com/libp2p/go-libp2p-kad-dht"
 	peer "github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
 	routing "github.com/libp2p/go-libp2p-routing"
@@ -39,6 +38,7 @@ var DhtCmd = &cmds.Command{
 		"get":       getValueDhtCmd,
 		"put":       putValueDhtCmd,
 		"provide":   provideRefDhtCmd,
+		"mode":      modeDhtCmd,
 	},
 }
 
@@ -61,8 +61,8 @@ var queryDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmdkit.ErrNormal)
 			return
 		}
@@ -158,8 +158,8 @@ var findProvidersDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmdkit.ErrNormal)
 			return
 		}
@@ -423,8 +423,8 @@ var findPeerDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmdkit.ErrNormal)
 			return
 		}
@@ -529,8 +529,8 @@ Different key types can specify other 'best' rules.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmdkit.ErrNormal)
 			return
 		}
@@ -644,8 +644,8 @@ NOTE: A value may not exceed 2048 bytes.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmdkit.ErrNormal)
 			return
 		}
@@ -793,3 +793,73 @@ func escapeDhtKey(s string) (string, error) {
 		return "", errors.New("invalid key")
 	}
 }
+
+// DHTModeOutput is the output of ipfs dht mode.
+type DHTModeOutput struct {
+	Mode    string
+	Serving bool
+}
+
+var modeDhtCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show or change the DHT mode.",
+		ShortDescription: `
+Outputs the DHT mode of the node, and whether it answers the DHT requests of
+other peers. When given a mode, switches to it first. The modes are:
+
+  server - answer the requests of other peers
+  client - only send requests
+  auto   - answer requests while the node has a public address
+
+Changes are not persisted, set Routing.Type in the config for that.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("mode", false, false, "The mode to switch to: 'server', 'client' or 'auto'."),
+	},
+	Type: DHTModeOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		if len(req.Arguments()) > 0 {
+			if err := n.SetDHTMode(req.Arguments()[0]); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		mode, serving, err := n.DHTMode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(&DHTModeOutput{Mode: mode, Serving: serving})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*DHTModeOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Mode:\t%s\n", out.Mode)
+			fmt.Fprintf(buf, "Serving:\t%t\n", out.Serving)
+			return buf, nil
+		},
+	},
+}
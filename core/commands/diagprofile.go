@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	logs "github.com/ipfs/go-ipfs/core/logs"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	ipdht "github.com/libp2p/go-libp2p-kad-dht"
+)
+
+const (
+	profileCPUTimeOptionName = "cpu-profile-time"
+	defaultProfileCPUTime    = "30s"
+)
+
+// redactedConfigKeys are the keys of the config left out of profiles, as
+// paths into the config. "*" matches any key of a map, and "**" any number
+// of keys of maps and items of arrays, e.g. the mounts of the datastore spec.
+var redactedConfigKeys = [][]string{
+	{"Identity", "PrivKey"},
+	{"Gateway", "WriteTokens"},
+	{"Pinning", "RemoteServices", "*", "Key"},
+	{"Datastore", "Spec", "**", "accessKey"},
+	{"Datastore", "Spec", "**", "secretKey"},
+}
+
+var diagProfileCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Collect a diagnostics archive for bug reports.",
+		ShortDescription: `
+'ipfs diag profile' outputs a zip archive with everything needed to debug the
+daemon:
+
+  goroutines.stacks  the stacks of all goroutines
+  heap.pprof         a heap profile
+  cpu.pprof          a CPU profile, taken over --cpu-profile-time
+  config.json        the config, with the private key, tokens and
+                     credentials removed
+  logs.ndjson        the recent entries of the event log
+  bitswap.json       the bitswap statistics
+  dht.json           the connected peers speaking the DHT protocol
+  repo.json          the repo statistics
+  system.json        the output of 'ipfs diag sys'
+
+Sections which can't be collected, e.g. bitswap.json when offline, are
+replaced by a .error file explaining why.
+
+  > ipfs diag profile > ipfs-profile.zip
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(profileCPUTimeOptionName, "How long to profile the CPU for, 0 to skip.").WithDefault(defaultProfileCPUTime),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cpuTimeStr, _, _ := req.Option(profileCPUTimeOptionName).String()
+		cpuTime, err := time.ParseDuration(cpuTimeStr)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid CPU profile time: %s", err), cmdkit.ErrClient)
+			return
+		}
+
+		ctx := req.Context()
+		r, w := io.Pipe()
+		go func() {
+			w.CloseWithError(writeProfile(ctx, w, n, cpuTime))
+		}()
+		res.SetOutput(r)
+	},
+}
+
+// writeProfile writes the diagnostics archive of n to w.
+func writeProfile(ctx context.Context, w io.Writer, n *core.IpfsNode, cpuTime time.Duration) error {
+	z := zip.NewWriter(w)
+
+	sections := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"goroutines.stacks", func(w io.Writer) error {
+			return pprof.Lookup("goroutine").WriteTo(w, 2)
+		}},
+		{"heap.pprof", func(w io.Writer) error {
+			return pprof.Lookup("heap").WriteTo(w, 0)
+		}},
+		{"cpu.pprof", func(w io.Writer) error {
+			return writeCPUProfile(ctx, w, cpuTime)
+		}},
+		{"config.json", func(w io.Writer) error {
+			return writeProfileConfig(w, n)
+		}},
+		{"logs.ndjson", func(w io.Writer) error {
+			return writeProfileLogs(w)
+		}},
+		{"bitswap.json", func(w io.Writer) error {
+			bs, ok := n.Exchange.(*bitswap.Bitswap)
+			if !n.OnlineMode() || !ok {
+				return errNotOnline
+			}
+			st, err := bs.Stat()
+			if err != nil {
+				return err
+			}
+			return writeProfileJSON(w, st)
+		}},
+		{"dht.json", func(w io.Writer) error {
+			return writeProfileDHT(w, n)
+		}},
+		{"repo.json", func(w io.Writer) error {
+			st, err := corerepo.RepoStat(n, ctx)
+			if err != nil {
+				return err
+			}
+			return writeProfileJSON(w, st)
+		}},
+		{"system.json", func(w io.Writer) error {
+			info := make(map[string]interface{})
+			for _, f := range []func(map[string]interface{}) error{runtimeInfo, envVarInfo, diskSpaceInfo, memInfo} {
+				if err := f(info); err != nil {
+					return err
+				}
+			}
+			if err := netInfo(n.OnlineMode(), info); err != nil {
+				return err
+			}
+			info["ipfs_version"] = config.CurrentVersionNumber
+			info["ipfs_commit"] = config.CurrentCommit
+			return writeProfileJSON(w, info)
+		}},
+	}
+
+	for _, s := range sections {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.name == "cpu.pprof" && cpuTime <= 0 {
+			continue
+		}
+
+		// sections are collected in memory first, so that a section
+		// failing halfway doesn't leave a truncated file in the archive
+		var buf bytes.Buffer
+		if err := s.write(&buf); err != nil {
+			f, zerr := z.Create(s.name + ".error")
+			if zerr != nil {
+				return zerr
+			}
+			fmt.Fprintln(f, err)
+			continue
+		}
+		f, err := z.Create(s.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func writeCPUProfile(ctx context.Context, w io.Writer, d time.Duration) error {
+	if err := pprof.StartCPUProfile(w); err != nil {
+		return err
+	}
+	defer pprof.StopCPUProfile()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func writeProfileConfig(w io.Writer, n *core.IpfsNode) error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	m, err := config.ToMap(cfg)
+	if err != nil {
+		return err
+	}
+	for _, key := range redactedConfigKeys {
+		redactConfigKey(m, key)
+	}
+	return writeProfileJSON(w, m)
+}
+
+// redactConfigKey removes the values at key from the config v, if any.
+func redactConfigKey(v interface{}, key []string) {
+	switch v := v.(type) {
+	case []interface{}:
+		if key[0] != "**" {
+			return
+		}
+		for _, item := range v {
+			redactConfigKey(item, key)
+		}
+	case map[string]interface{}:
+		if key[0] == "**" {
+			redactConfigKey(v, key[1:])
+			for _, sub := range v {
+				redactConfigKey(sub, key)
+			}
+			return
+		}
+		if len(key) == 1 {
+			delete(v, key[0])
+			return
+		}
+		for k, sub := range v {
+			if key[0] != "*" && k != key[0] {
+				continue
+			}
+			redactConfigKey(sub, key[1:])
+		}
+	}
+}
+
+func writeProfileLogs(w io.Writer) error {
+	l := logs.Default()
+	if l == nil {
+		return errNoEventLog
+	}
+	enc := json.NewEncoder(w)
+	for _, e := range l.Entries() {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProfileDHT writes the connected peers speaking the DHT protocol. The
+// DHT doesn't expose its routing table, these are the peers it can have in it.
+func writeProfileDHT(w io.Writer, n *core.IpfsNode) error {
+	if !n.OnlineMode() || n.PeerHost == nil {
+		return errNotOnline
+	}
+	if n.DHT() == nil {
+		return fmt.Errorf("routing is not done through the DHT")
+	}
+
+	peers := []string{}
+	ps := n.PeerHost.Peerstore()
+	for _, p := range n.PeerHost.Network().Peers() {
+		protos, err := ps.SupportsProtocols(p, string(ipdht.ProtocolDHT))
+		if err != nil || len(protos) == 0 {
+			continue
+		}
+		peers = append(peers, p.Pretty())
+	}
+	return writeProfileJSON(w, map[string]interface{}{"Peers": peers})
+}
+
+func writeProfileJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestRedactConfig(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Identity.PeerID = "QmPeer"
+	cfg.Identity.PrivKey = "secret"
+	cfg.Gateway.WriteTokens = []string{"secret"}
+	cfg.Pinning.RemoteServices = map[string]config.RemotePinningService{
+		"svc": {Endpoint: "https://pinning.example.com", Key: "secret"},
+	}
+	cfg.Datastore.Spec = map[string]interface{}{
+		"type": "mount",
+		"mounts": []interface{}{
+			map[string]interface{}{
+				"mountpoint": "/blocks",
+				"type":       "measure",
+				"prefix":     "s3.datastore",
+				"child": map[string]interface{}{
+					"type":      "s3ds",
+					"bucket":    "blocks",
+					"accessKey": "secret",
+					"secretKey": "secret",
+				},
+			},
+		},
+	}
+
+	m, err := config.ToMap(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range redactedConfigKeys {
+		redactConfigKey(m, key)
+	}
+
+	ident := m["Identity"].(map[string]interface{})
+	if _, ok := ident["PrivKey"]; ok || ident["PeerID"] != "QmPeer" {
+		t.Fatalf("wrong identity: %v", ident)
+	}
+	if _, ok := m["Gateway"].(map[string]interface{})["WriteTokens"]; ok {
+		t.Fatal("write tokens weren't redacted")
+	}
+	svc := m["Pinning"].(map[string]interface{})["RemoteServices"].(map[string]interface{})["svc"].(map[string]interface{})
+	if _, ok := svc["Key"]; ok || svc["Endpoint"] != "https://pinning.example.com" {
+		t.Fatalf("wrong remote service: %v", svc)
+	}
+	mount := m["Datastore"].(map[string]interface{})["Spec"].(map[string]interface{})["mounts"].([]interface{})[0].(map[string]interface{})
+	s3 := mount["child"].(map[string]interface{})
+	if _, ok := s3["accessKey"]; ok {
+		t.Fatal("S3 access key wasn't redacted")
+	}
+	if _, ok := s3["secretKey"]; ok || s3["bucket"] != "blocks" || mount["mountpoint"] != "/blocks" {
+		t.Fatalf("wrong S3 mount: %v", mount)
+	}
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	events "github.com/ipfs/go-ipfs/core/events"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// eventsBuffer is the number of events buffered for a slow client before
+// dropping them.
+const eventsBuffer = 256
+
+var EventsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream the events of the node.",
+		ShortDescription: `
+'ipfs events' prints the events of the subsystems of the node as they happen,
+until interrupted.
+`,
+		LongDescription: `
+'ipfs events' prints the events of the subsystems of the node as they happen,
+until interrupted. The events are:
+
+  peer-connected      a connection to a peer opened
+  peer-disconnected   a connection to a peer closed
+  block-fetched       a block was received from a peer
+  pin-completed       a DAG was pinned
+  gc-finished         a garbage collection finished
+  ipns-published      an IPNS name was published
+
+--type limits them to a comma separated list of types:
+
+  > ipfs events --type=peer-connected,peer-disconnected
+
+The daemon also serves the events at /events of the API as server-sent events,
+with the same filter in the type parameter, for browsers.
+
+Events are dropped for clients which don't keep up.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("type", "t", "Comma separated list of the event types to print."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		nd, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if nd.Events == nil {
+			res.SetError(errors.New("this node doesn't report events"), cmdkit.ErrNormal)
+			return
+		}
+
+		typesOpt, _ := req.Options["type"].(string)
+		types, err := events.ParseTypes(typesOpt)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		evs := make(chan events.Event, eventsBuffer)
+		cancel := nd.Events.Subscribe(func(ev events.Event) {
+			select {
+			case evs <- ev:
+			default:
+				// don't slow down the node for a slow client
+			}
+		}, types...)
+		defer cancel()
+
+		for {
+			select {
+			case ev := <-evs:
+				if err := res.Emit(&ev); err != nil {
+					return
+				}
+			case <-req.Context.Done():
+				return
+			}
+		}
+	},
+	Type: events.Event{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			ev, ok := v.(*events.Event)
+			if !ok {
+				return fmt.Errorf("unexpected type: %T", v)
+			}
+
+			prefix := ev.Time.Format(time.RFC3339) + " " + string(ev.Type)
+			var err error
+			switch ev.Type {
+			case events.PeerConnected, events.PeerDisconnected:
+				_, err = fmt.Fprintf(w, "%s %s %s\n", prefix, ev.Peer.Pretty(), ev.Addr)
+			case events.BlockFetched:
+				_, err = fmt.Fprintf(w, "%s %s from %s\n", prefix, ev.Cid, ev.Peer.Pretty())
+			case events.PinCompleted:
+				_, err = fmt.Fprintf(w, "%s %s\n", prefix, ev.Cid)
+			case events.GCFinished:
+				_, err = fmt.Fprintf(w, "%s removed %d blocks in %s\n", prefix, ev.GC.Removed, ev.GC.Duration)
+			case events.IpnsPublished:
+				_, err = fmt.Fprintf(w, "%s %s: %s\n", prefix, ev.Name, ev.Value)
+			default:
+				_, err = fmt.Fprintln(w, prefix)
+			}
+			return err
+		}),
+	},
+}
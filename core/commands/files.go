@@ -9,6 +9,7 @@ import (
 	"os"
 	gopath "path"
 	"strings"
+	"time"
 
 	bservice "github.com/ipfs/go-ipfs/blockservice"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
@@ -66,6 +67,226 @@ operations.
 		"rm":    lgc.NewCommand(filesRmCmd),
 		"flush": lgc.NewCommand(filesFlushCmd),
 		"chcid": lgc.NewCommand(filesChcidCmd),
+		"watch": filesWatchCmd,
+		"quota": filesQuotaCmd,
+	},
+}
+
+var filesWatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the changes of the files API content as they happen.",
+		ShortDescription: `
+'ipfs files watch' prints an event for every change of the content below the
+given path, or of the whole files API: entries added, directories created,
+entries removed, and files written. Each event carries the path changed,
+the hashes of the entry before and after the change, and a sequence number.
+
+If the daemon records the changes, with the Experimental.FilesJournal config
+option set to the number of changes to keep, --since prints the recorded
+changes following the one with the given sequence number first. Clients can
+so resume watching after a restart without missing changes.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", false, false, "Path to watch the changes below.").WithDefault("/"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("since", "Print the recorded changes following the one with this sequence number first."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		nd, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		root := "/"
+		if len(req.Arguments) > 0 {
+			root, err = checkPath(req.Arguments[0])
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+		below := func(ev *mfs.Event) bool {
+			return root == "/" || ev.Path == root || strings.HasPrefix(ev.Path, root+"/")
+		}
+
+		events := make(chan mfs.Event, 128)
+		cancel := nd.FilesRoot.Subscribe(func(ev mfs.Event) {
+			select {
+			case events <- ev:
+			default:
+				// don't slow down the files API for a slow client
+			}
+		})
+		defer cancel()
+
+		// replay the recorded events before the ones happening now
+		var last uint64
+		if since, ok := req.Options["since"].(int); ok {
+			if since < 0 {
+				res.SetError(errors.New("since must be positive"), cmdkit.ErrClient)
+				return
+			}
+			past, err := nd.FilesRoot.EventsSince(uint64(since))
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			for i := range past {
+				last = past[i].Seq
+				if !below(&past[i]) {
+					continue
+				}
+				if err := res.Emit(&past[i]); err != nil {
+					return
+				}
+			}
+		}
+
+		for {
+			select {
+			case ev := <-events:
+				if ev.Seq <= last || !below(&ev) {
+					continue
+				}
+				if err := res.Emit(&ev); err != nil {
+					return
+				}
+			case <-req.Context.Done():
+				return
+			}
+		}
+	},
+	Type: mfs.Event{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			ev, ok := v.(*mfs.Event)
+			if !ok {
+				return e.TypeErr(ev, v)
+			}
+
+			fmt.Fprintf(w, "%d %s %s", ev.Seq, ev.Op, ev.Path)
+			if ev.Old != nil {
+				fmt.Fprintf(w, " old=%s", ev.Old)
+			}
+			if ev.New != nil {
+				fmt.Fprintf(w, " new=%s", ev.New)
+			}
+			_, err := fmt.Fprintln(w)
+			return err
+		}),
+	},
+}
+
+type filesQuotaOutput struct {
+	Path  string
+	Usage uint64
+	Quota uint64
+}
+
+var filesQuotaCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the space used by a directory, and limit it.",
+		ShortDescription: `
+'ipfs files quota' prints the cumulative size of the given directory, and
+the quota set on it, if any. With a limit, such as "10GB", it sets the quota
+of the directory first; a limit of 0 removes it.
+
+Adding entries to a directory, or below it, and flushing the changes to the
+files below it fail once they would make it use more space than its quota.
+Quotas are kept across restarts, and stay attached to the path when the
+directory is moved.
+
+Without a path, the quotas set are listed.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", false, false, "Path to the directory."),
+		cmdkit.StringArg("limit", false, false, "Space the directory may use, e.g. 10GB, or 0 to remove its quota."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		nd, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		root := nd.FilesRoot
+
+		if len(req.Arguments) == 0 {
+			for _, q := range root.Quotas() {
+				out := &filesQuotaOutput{Path: q.Path, Quota: q.Limit}
+				if fsn, err := mfs.Lookup(root, q.Path); err == nil {
+					if d, ok := fsn.(*mfs.Directory); ok {
+						out.Usage, _ = d.Usage()
+					}
+				}
+				if err := res.Emit(out); err != nil {
+					return
+				}
+			}
+			return
+		}
+
+		path, err := checkPath(req.Arguments[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		fsn, err := mfs.Lookup(root, path)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		dir, ok := fsn.(*mfs.Directory)
+		if !ok {
+			res.SetError(fmt.Errorf("%s is not a directory", path), cmdkit.ErrNormal)
+			return
+		}
+
+		if len(req.Arguments) > 1 {
+			limit, err := humanize.ParseBytes(req.Arguments[1])
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			if err := root.SetQuota(path, limit); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		usage, err := dir.Usage()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		out := &filesQuotaOutput{Path: path, Usage: usage}
+		for _, q := range root.Quotas() {
+			if q.Path == path {
+				out.Quota = q.Limit
+			}
+		}
+		res.Emit(out)
+	},
+	Type: filesQuotaOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*filesQuotaOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			quota := "none"
+			if out.Quota > 0 {
+				quota = humanize.Bytes(out.Quota)
+			}
+			_, err := fmt.Fprintf(w, "%s\tused: %s\tquota: %s\n", out.Path, humanize.Bytes(out.Usage), quota)
+			return err
+		}),
 	},
 }
 
@@ -83,6 +304,8 @@ type statOutput struct {
 	WithLocality   bool   `json:",omitempty"`
 	Local          bool   `json:",omitempty"`
 	SizeLocal      uint64 `json:",omitempty"`
+	Mode           string `json:",omitempty"`
+	Mtime          string `json:",omitempty"`
 }
 
 const defaultStatFormat = `<hash>
@@ -101,7 +324,7 @@ var filesStatCmd = &cmds.Command{
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("format", "Print statistics in given format. Allowed tokens: "+
-			"<hash> <size> <cumulsize> <type> <childs>. Conflicts with other format options.").WithDefault(defaultStatFormat),
+			"<hash> <size> <cumulsize> <type> <childs> <mode> <mtime>. Conflicts with other format options.").WithDefault(defaultStatFormat),
 		cmdkit.BoolOption("hash", "Print only hash. Implies '--format=<hash>'. Conflicts with other format options."),
 		cmdkit.BoolOption("size", "Print only size. Implies '--format=<cumulsize>'. Conflicts with other format options."),
 		cmdkit.BoolOption("with-local", "Compute the amount of the dag that is local, and if possible the total size"),
@@ -170,15 +393,26 @@ var filesStatCmd = &cmds.Command{
 				return e.TypeErr(out, v)
 			}
 
-			s, _ := statGetFormatOptions(req)
-			s = strings.Replace(s, "<hash>", out.Hash, -1)
+			format, _ := statGetFormatOptions(req)
+			s := strings.Replace(format, "<hash>", out.Hash, -1)
 			s = strings.Replace(s, "<size>", fmt.Sprintf("%d", out.Size), -1)
 			s = strings.Replace(s, "<cumulsize>", fmt.Sprintf("%d", out.CumulativeSize), -1)
 			s = strings.Replace(s, "<childs>", fmt.Sprintf("%d", out.Blocks), -1)
 			s = strings.Replace(s, "<type>", out.Type, -1)
+			s = strings.Replace(s, "<mode>", out.Mode, -1)
+			s = strings.Replace(s, "<mtime>", out.Mtime, -1)
 
 			fmt.Fprintln(w, s)
 
+			if format == defaultStatFormat {
+				if out.Mode != "" {
+					fmt.Fprintf(w, "Mode: %s\n", out.Mode)
+				}
+				if out.Mtime != "" {
+					fmt.Fprintf(w, "Mtime: %s\n", out.Mtime)
+				}
+			}
+
 			if out.WithLocality {
 				fmt.Fprintf(w, "Local: %s of %s (%.2f%%)\n",
 					humanize.Bytes(out.SizeLocal),
@@ -241,13 +475,21 @@ func statNode(nd ipld.Node) (*statOutput, error) {
 			return nil, fmt.Errorf("unrecognized node type: %s", d.GetType())
 		}
 
-		return &statOutput{
+		out := &statOutput{
 			Hash:           c.String(),
 			Blocks:         len(nd.Links()),
 			Size:           d.GetFilesize(),
 			CumulativeSize: cumulsize,
 			Type:           ndtype,
-		}, nil
+		}
+		attrs := ft.AttrsFromPB(d)
+		if attrs.Mode != 0 {
+			out.Mode = fmt.Sprintf("%04o", attrs.UnixMode())
+		}
+		if !attrs.ModTime.IsZero() {
+			out.Mtime = attrs.ModTime.Format(time.RFC3339Nano)
+		}
+		return out, nil
 	case *dag.RawNode:
 		return &statOutput{
 			Hash:           c.String(),
@@ -25,6 +25,7 @@ var FileStoreCmd = &cmds.Command{
 		"ls":     lsFileStore,
 		"verify": lgc.NewCommand(verifyFileStore),
 		"dups":   lgc.NewCommand(dupsFileStore),
+		"clean":  cleanFileStore,
 	},
 }
 
@@ -198,6 +199,72 @@ For ERROR entries the error will also be printed to stderr.
 	Type: filestore.ListRes{},
 }
 
+var cleanFileStore = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove references to data which can't be read back from the filestore.",
+		LongDescription: `
+Remove the references of the filestore to backing files or URLs whose data
+can't be read back. The backing files are left untouched.
+
+By default, the references to data which changed or was removed are removed.
+Other kinds of references can be selected with <status>, which is one of the
+statuses printed by 'ipfs filestore verify': changed, no-file, error.
+
+The removed references are printed like 'ipfs filestore verify' does. Blocks
+which are still needed will have to be added again.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("status", false, true, "Status of the references to remove."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		_, fs, err := getFilestore(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		statuses := []filestore.Status{filestore.StatusFileChanged, filestore.StatusFileNotFound}
+		if len(req.Arguments) > 0 {
+			statuses = nil
+			for _, arg := range req.Arguments {
+				switch arg {
+				case filestore.StatusFileChanged.String():
+					statuses = append(statuses, filestore.StatusFileChanged)
+				case filestore.StatusFileNotFound.String():
+					statuses = append(statuses, filestore.StatusFileNotFound)
+				case filestore.StatusFileError.String():
+					statuses = append(statuses, filestore.StatusFileError)
+				default:
+					res.SetError(fmt.Errorf("invalid status: %s", arg), cmdkit.ErrClient)
+					return
+				}
+			}
+		}
+
+		removed, err := filestore.Clean(fs, statuses...)
+		for _, r := range removed {
+			if err := res.Emit(r); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+		}
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			r, ok := v.(*filestore.ListRes)
+			if !ok {
+				return e.TypeErr(r, v)
+			}
+			_, err := fmt.Fprintf(w, "%s %s\n", r.Status.Format(), r.FormatLong())
+			return err
+		}),
+	},
+	Type: filestore.ListRes{},
+}
+
 var dupsFileStore = &oldCmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List blocks that are both in the filestore and standard block storage.",
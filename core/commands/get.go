@@ -15,6 +15,7 @@ import (
 	path "github.com/ipfs/go-ipfs/path"
 	tar "github.com/ipfs/go-ipfs/thirdparty/tar"
 	uarchive "github.com/ipfs/go-ipfs/unixfs/archive"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	"github.com/cheggaaa/pb"
 	"github.com/ipfs/go-ipfs-cmdkit"
@@ -89,7 +90,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		}
 
 		archive, _ := req.Options["archive"].(bool)
-		reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, cmplvl)
+		reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, cmplvl, uio.WithFetchAhead(node.FetchAhead))
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
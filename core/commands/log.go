@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	logs "github.com/ipfs/go-ipfs/core/logs"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	logging "github.com/ipfs/go-log"
@@ -16,6 +20,8 @@ import (
 // we convert it at this step.
 var logAllKeyword = "all"
 
+var errNoEventLog = errors.New("the event log is not kept by this node")
+
 var LogCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Interact with the daemon log output.",
@@ -26,6 +32,7 @@ output of a running daemon.
 	},
 
 	Subcommands: map[string]*cmds.Command{
+		"dump":  logDumpCmd,
 		"level": logLevelCmd,
 		"ls":    logLsCmd,
 		"tail":  logTailCmd,
@@ -36,8 +43,8 @@ var logLevelCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Change the logging level.",
 		ShortDescription: `
-Change the verbosity of one or all subsystems log output. This does not affect
-the event log.
+Change the verbosity of one or all subsystems log output, and of the
+structured entries of the event log kept by the daemon.
 `,
 	},
 
@@ -62,6 +69,12 @@ the event log.
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		if l := logs.Default(); l != nil {
+			if err := l.SetLevel(subsystem, level); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
 
 		s := fmt.Sprintf("Changed log level of '%s' to '%s'\n", subsystem, level)
 		log.Info(s)
@@ -95,17 +108,76 @@ var logTailCmd = &cmds.Command{
 		Tagline: "Read the event log.",
 		ShortDescription: `
 Outputs event log messages (not other log messages) as they are generated.
+When the daemon keeps structured entries, they are output as JSON objects, one
+per line, with the subsystem, level, CID, peer and trace ID of each message.
 `,
 	},
 
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
 		r, w := io.Pipe()
+		l := logs.Default()
+		if l == nil {
+			go func() {
+				defer w.Close()
+				<-ctx.Done()
+			}()
+			logging.WriterGroup.AddWriter(w)
+			res.SetOutput(r)
+			return
+		}
+
+		entries := make(chan logs.Entry, 128)
+		cancel := l.Subscribe(func(e logs.Entry) {
+			select {
+			case entries <- e:
+			default:
+				// the client is too slow, drop the entry
+			}
+		})
 		go func() {
 			defer w.Close()
-			<-ctx.Done()
+			defer cancel()
+			enc := json.NewEncoder(w)
+			for {
+				select {
+				case e := <-entries:
+					if err := enc.Encode(e); err != nil {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
 		}()
-		logging.WriterGroup.AddWriter(w)
 		res.SetOutput(r)
 	},
 }
+
+var logDumpCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Output the recent entries of the event log.",
+		ShortDescription: `
+Outputs the structured entries of the event log kept in memory by the daemon,
+oldest first, as JSON objects, one per line. Attach the output to bug reports.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		l := logs.Default()
+		if l == nil {
+			res.SetError(errNoEventLog, cmdkit.ErrNormal)
+			return
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, e := range l.Entries() {
+			if err := enc.Encode(e); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(&buf)
+	},
+}
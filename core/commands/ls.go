@@ -2,9 +2,12 @@ package commands
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"text/tabwriter"
+	"time"
 
 	blockservice "github.com/ipfs/go-ipfs/blockservice"
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -16,6 +19,7 @@ import (
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
 	unixfs "github.com/ipfs/go-ipfs/unixfs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	mimeindex "github.com/ipfs/go-ipfs/unixfs/mimeindex"
 	unixfspb "github.com/ipfs/go-ipfs/unixfs/pb"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
@@ -26,6 +30,10 @@ type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfspb.Data_DataType
+	Mode       string `json:",omitempty"`
+	Mtime      string `json:",omitempty"`
+	Target     string `json:",omitempty"`
+	MimeType   string `json:",omitempty"`
 }
 
 type LsObject struct {
@@ -35,8 +43,15 @@ type LsObject struct {
 
 type LsOutput struct {
 	Objects []LsObject
+
+	// Err is the error which interrupted a streamed listing.
+	Err string `json:",omitempty"`
 }
 
+// errLsLimit interrupts the listing of an object once enough entries were
+// listed.
+var errLsLimit = errors.New("limit reached")
+
 var LsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List directory contents for Unix filesystem objects.",
@@ -46,7 +61,20 @@ the following format:
 
   <link base58 hash> <link size in bytes> <link name>
 
-The JSON output contains type information.
+Directories are printed with a trailing slash, and symlinks followed by
+their target, as in '<link name> -> <target>'.
+
+With '--long', the mode and modification time recorded for the entries, if
+any, are printed first. The JSON output contains type information, and
+these attributes when recorded, as well as the content type of files when
+the experimental MIME index has it.
+
+With '--stream', entries are output as they are read instead of once the
+whole listing is built, so listing huge directories doesn't take memory for
+all of their entries. Listings interrupted after some entry can be resumed
+from the next one with '--after', and '--limit' lists at most the given
+number of entries. Entries of directories are listed in the order of their
+names, or of the hashes of their names for sharded directories.
 `,
 	},
 
@@ -56,6 +84,10 @@ The JSON output contains type information.
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("headers", "v", "Print table headers (Hash, Size, Name)."),
 		cmdkit.BoolOption("resolve-type", "Resolve linked objects to find out their types.").WithDefault(true),
+		cmdkit.BoolOption("long", "l", "Print the mode and modification time of entries."),
+		cmdkit.BoolOption("stream", "s", "Output entries as they are read."),
+		cmdkit.StringOption("after", "List the entries of directories following the one with this name."),
+		cmdkit.IntOption("limit", "List at most this many entries of each object. 0 lists all of them.").WithDefault(0),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		nd, err := req.InvocContext().GetNode()
@@ -76,6 +108,28 @@ The JSON output contains type information.
 			return
 		}
 
+		stream, _, err := req.Option("stream").Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		after, _, err := req.Option("after").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		limit, _, err := req.Option("limit").Int()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if limit < 0 {
+			res.SetError(fmt.Errorf("limit must be positive"), cmdkit.ErrNormal)
+			return
+		}
+
 		dserv := nd.DAG
 		if !resolve {
 			offlineexch := offline.Exchange(nd.Blockstore)
@@ -106,62 +160,96 @@ The JSON output contains type information.
 			dagnodes = append(dagnodes, dagnode)
 		}
 
-		output := make([]LsObject, len(req.Arguments()))
-
-		for i, dagnode := range dagnodes {
-			dir, err := uio.NewDirectoryFromNode(nd.DAG, dagnode)
+		// forEach calls f on the entries of the i'th object listed
+		forEach := func(i int, f func(LsLink) error) error {
+			dir, err := uio.NewDirectoryFromNode(nd.DAG, dagnodes[i])
 			if err != nil && err != uio.ErrNotADir {
-				res.SetError(err, cmdkit.ErrNormal)
-				return
+				return err
 			}
 
-			var links []*ipld.Link
-			if dir == nil {
-				links = dagnode.Links()
-			} else {
-				links, err = dir.Links(req.Context())
+			count := 0
+			each := func(link *ipld.Link) error {
+				if limit > 0 && count == limit {
+					return errLsLimit
+				}
+				count++
+
+				l, err := makeLsLink(req.Context(), dserv, nd.MimeIndex, link, resolve)
 				if err != nil {
-					res.SetError(err, cmdkit.ErrNormal)
-					return
+					return err
 				}
+				return f(l)
 			}
 
-			output[i] = LsObject{
-				Hash:  paths[i],
-				Links: make([]LsLink, len(links)),
+			if dir != nil {
+				err = dir.ForEachLinkAfter(req.Context(), after, each)
+			} else {
+				// not a directory, list the links of the node
+				err = nil
+				for _, link := range dagnodes[i].Links() {
+					if err = each(link); err != nil {
+						break
+					}
+				}
 			}
+			if err == errLsLimit {
+				return nil
+			}
+			return err
+		}
 
-			for j, link := range links {
-				t := unixfspb.Data_DataType(-1)
+		if stream {
+			out := make(chan interface{})
+			res.SetOutput((<-chan interface{})(out))
 
-				linkNode, err := link.GetNode(req.Context(), dserv)
-				if err == ipld.ErrNotFound && !resolve {
-					// not an error
-					linkNode = nil
-				} else if err != nil {
-					res.SetError(err, cmdkit.ErrNormal)
-					return
+			// send gives up when the client goes away
+			send := func(o *LsOutput) error {
+				select {
+				case out <- o:
+					return nil
+				case <-req.Context().Done():
+					return req.Context().Err()
 				}
+			}
+
+			go func() {
+				defer close(out)
 
-				if pn, ok := linkNode.(*merkledag.ProtoNode); ok {
-					d, err := unixfs.FromBytes(pn.Data())
+				for i := range dagnodes {
+					// an object without links starts the listing of each
+					// object, its entries follow one by one
+					if send(&LsOutput{Objects: []LsObject{{Hash: paths[i], Links: []LsLink{}}}}) != nil {
+						return
+					}
+					err := forEach(i, func(l LsLink) error {
+						return send(&LsOutput{Objects: []LsObject{{Hash: paths[i], Links: []LsLink{l}}}})
+					})
 					if err != nil {
-						res.SetError(err, cmdkit.ErrNormal)
+						send(&LsOutput{Err: err.Error()})
 						return
 					}
-
-					t = d.GetType()
-				}
-				output[i].Links[j] = LsLink{
-					Name: link.Name,
-					Hash: link.Cid.String(),
-					Size: link.Size,
-					Type: t,
 				}
+			}()
+			return
+		}
+
+		output := make([]LsObject, len(req.Arguments()))
+		for i := range dagnodes {
+			output[i] = LsObject{
+				Hash:  paths[i],
+				Links: []LsLink{},
+			}
+			err := forEach(i, func(l LsLink) error {
+				output[i].Links = append(output[i].Links, l)
+				return nil
+			})
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
 			}
 		}
 
-		res.SetOutput(&LsOutput{output})
+		res.SetOutput(&LsOutput{Objects: output})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -172,27 +260,44 @@ The JSON output contains type information.
 			}
 
 			headers, _, _ := res.Request().Option("headers").Bool()
+			long, _, _ := res.Request().Option("long").Bool()
+			stream, _, _ := res.Request().Option("stream").Bool()
 			output, ok := v.(*LsOutput)
 			if !ok {
 				return nil, e.TypeErr(output, v)
 			}
+			if output.Err != "" {
+				return nil, errors.New(output.Err)
+			}
 
 			buf := new(bytes.Buffer)
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			multiple := len(output.Objects) > 1 || len(res.Request().Arguments()) > 1
 			for _, object := range output.Objects {
-				if len(output.Objects) > 1 {
+				// streamed entries come one by one after their object
+				start := !stream || len(object.Links) == 0
+				if multiple && start {
 					fmt.Fprintf(w, "%s:\n", object.Hash)
 				}
-				if headers {
+				if headers && start {
+					if long {
+						fmt.Fprint(w, "Mode\tMtime\t")
+					}
 					fmt.Fprintln(w, "Hash\tSize\tName")
 				}
 				for _, link := range object.Links {
-					if link.Type == unixfspb.Data_Directory {
+					switch link.Type {
+					case unixfspb.Data_Directory:
 						link.Name += "/"
+					case unixfspb.Data_Symlink:
+						link.Name += " -> " + link.Target
+					}
+					if long {
+						fmt.Fprintf(w, "%s\t%s\t", orDash(link.Mode), orDash(link.Mtime))
 					}
 					fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
 				}
-				if len(output.Objects) > 1 {
+				if multiple && !stream {
 					fmt.Fprintln(w)
 				}
 			}
@@ -203,3 +308,59 @@ The JSON output contains type information.
 	},
 	Type: LsOutput{},
 }
+
+// makeLsLink returns the entry of link, with the type and attributes of the
+// node it links to if resolve is set or it is available locally, and its
+// content type if recorded in mimes.
+func makeLsLink(ctx context.Context, dserv ipld.DAGService, mimes *mimeindex.Index, link *ipld.Link, resolve bool) (LsLink, error) {
+	t := unixfspb.Data_DataType(-1)
+
+	linkNode, err := link.GetNode(ctx, dserv)
+	if err == ipld.ErrNotFound && !resolve {
+		// not an error
+		linkNode = nil
+	} else if err != nil {
+		return LsLink{}, err
+	}
+
+	var attrs unixfs.Attrs
+	var target string
+	if pn, ok := linkNode.(*merkledag.ProtoNode); ok {
+		d, err := unixfs.FromBytes(pn.Data())
+		if err != nil {
+			return LsLink{}, err
+		}
+
+		t = d.GetType()
+		attrs = unixfs.AttrsFromPB(d)
+		if t == unixfspb.Data_Symlink {
+			target = string(d.GetData())
+		}
+	}
+	l := LsLink{
+		Name:   link.Name,
+		Hash:   link.Cid.String(),
+		Size:   link.Size,
+		Type:   t,
+		Target: target,
+	}
+	if attrs.Mode != 0 {
+		l.Mode = fmt.Sprintf("%04o", attrs.UnixMode())
+	}
+	if !attrs.ModTime.IsZero() {
+		l.Mtime = attrs.ModTime.Format(time.RFC3339Nano)
+	}
+	if mimes != nil && t == unixfspb.Data_File {
+		if entry, ok := mimes.Get(link.Cid); ok {
+			l.MimeType = entry.Type
+		}
+	}
+	return l, nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// ModeOutput is the mode of the node reported by 'ipfs mode'.
+type ModeOutput struct {
+	Mode string
+}
+
+const (
+	modeOnline  = "online"
+	modeOffline = "offline"
+)
+
+var ModeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show or switch the online mode of the daemon.",
+		ShortDescription: `
+'ipfs mode' prints whether the daemon is online or offline. 'ipfs mode online'
+and 'ipfs mode offline' switch it without restarting it.
+`,
+		LongDescription: `
+'ipfs mode' prints whether the daemon is online or offline. 'ipfs mode online'
+and 'ipfs mode offline' switch it without restarting it.
+
+Going offline closes all connections, and stops the DHT, bitswap, reproviding
+and IPNS republishing. Blocks are then only read from the local repo, as in a
+daemon started with --offline. Going online brings these back up.
+
+Commands started while the mode switches wait for it to finish; commands
+already running may fail. Tenants of the daemon switch along with it.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("mode", false, false, "The mode to switch to: 'online' or 'offline'."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		nd, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if len(req.Arguments) > 0 {
+			var online bool
+			switch req.Arguments[0] {
+			case modeOnline:
+				online = true
+			case modeOffline:
+			default:
+				res.SetError(fmt.Errorf("unknown mode %q, expected 'online' or 'offline'", req.Arguments[0]), cmdkit.ErrClient)
+				return
+			}
+
+			if nd.LocalMode() {
+				res.SetError(fmt.Errorf("daemon not running"), cmdkit.ErrClient)
+				return
+			}
+			if err := nd.SetOnline(online); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		out := &ModeOutput{Mode: modeOffline}
+		if nd.OnlineMode() {
+			out.Mode = modeOnline
+		}
+		res.Emit(out)
+	},
+	Type: ModeOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*ModeOutput)
+			if !ok {
+				return fmt.Errorf("unexpected type: %T", v)
+			}
+			_, err := fmt.Fprintln(w, out.Mode)
+			return err
+		}),
+	},
+}
@@ -71,11 +71,24 @@ baz
 baz
 > cat /ipfs/QmWLdkp93sNxGRjnFHPaYg8tCQ35NBY3XPn6KiETd3Z4WR
 baz
+
+The files of 'ipfs files' can also be mounted, read-write, at the path given
+by '--mfs-path' or the Mounts.MFS configuration setting. Changes to a file
+are visible to 'ipfs files' once it is closed, and recorded in the root of
+the files with fsync, 'ipfs files flush', or when unmounting. A file can
+only be open for writing once, and not while it is open for reading: other
+opens fail with "device or resource busy".
+
+> ipfs mount --mfs-path=/mfs
+> echo "baz" > /mfs/bar
+> ipfs files read /bar
+baz
 `,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("ipfs-path", "f", "The path where IPFS should be mounted."),
 		cmdkit.StringOption("ipns-path", "n", "The path where IPNS should be mounted."),
+		cmdkit.StringOption("mfs-path", "m", "The path where the files of 'ipfs files' should be mounted."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		cfg, err := req.InvocContext().GetConfig()
@@ -115,15 +128,33 @@ baz
 			nsdir = cfg.Mounts.IPNS // NB: be sure to not redeclare!
 		}
 
+		mfsdir, found, err := req.Option("m").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if !found {
+			mfsdir = cfg.Mounts.MFS
+		}
+
 		err = nodeMount.Mount(node, fsdir, nsdir)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
 
+		if mfsdir != "" {
+			err = nodeMount.MountMfs(node, mfsdir)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
 		var output config.Mounts
 		output.IPFS = fsdir
 		output.IPNS = nsdir
+		output.MFS = mfsdir
 		res.SetOutput(&output)
 	},
 	Type: config.Mounts{},
@@ -141,6 +172,9 @@ baz
 
 			s := fmt.Sprintf("IPFS mounted at: %s\n", mnts.IPFS)
 			s += fmt.Sprintf("IPNS mounted at: %s\n", mnts.IPNS)
+			if mnts.MFS != "" {
+				s += fmt.Sprintf("MFS mounted at: %s\n", mnts.MFS)
+			}
 			return strings.NewReader(s), nil
 		},
 	},
@@ -3,26 +3,26 @@ package commands
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
-	bserv "github.com/ipfs/go-ipfs/blockservice"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
-	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
 	pin "github.com/ipfs/go-ipfs/pin"
-	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	cid "github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	u "github.com/ipfs/go-ipfs-util"
+	ipld "github.com/ipfs/go-ipld-format"
 )
 
 var PinCmd = &cmds.Command{
@@ -31,11 +31,17 @@ var PinCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"add":    addPinCmd,
-		"rm":     rmPinCmd,
-		"ls":     listPinCmd,
-		"verify": verifyPinCmd,
-		"update": updatePinCmd,
+		"add":       addPinCmd,
+		"rm":        rmPinCmd,
+		"ls":        listPinCmd,
+		"verify":    verifyPinCmd,
+		"update":    updatePinCmd,
+		"label":     labelPinCmd,
+		"partial":   partialPinCmd,
+		"remote":    remotePinCmd,
+		"replicate": replicatePinCmd,
+		"export":    exportPinCmd,
+		"import":    importPinCmd,
 	},
 }
 
@@ -60,6 +66,8 @@ var addPinCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s).").WithDefault(true),
 		cmdkit.BoolOption("progress", "Show progress"),
+		cmdkit.StringOption("name", "A name for the pins."),
+		cmdkit.StringOption("meta", "Labels for the pins, as comma separated key=value pairs."),
 	},
 	Type: AddPinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -79,8 +87,23 @@ var addPinCmd = &cmds.Command{
 		}
 		showProgress, _, _ := req.Option("progress").Bool()
 
+		name, _, _ := req.Option("name").String()
+		metaStr, _, _ := req.Option("meta").String()
+		meta, err := parseMeta(metaStr)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+		pinAndLabel := func(ctx context.Context) ([]*cid.Cid, error) {
+			added, err := corerepo.Pin(n, ctx, req.Arguments(), recursive)
+			if err != nil || (name == "" && meta == nil) {
+				return added, err
+			}
+			return added, corerepo.Label(n, added, name, meta)
+		}
+
 		if !showProgress {
-			added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+			added, err := pinAndLabel(req.Context())
 			if err != nil {
 				res.SetError(err, cmdkit.ErrNormal)
 				return
@@ -100,7 +123,7 @@ var addPinCmd = &cmds.Command{
 		}
 		ch := make(chan pinResult, 1)
 		go func() {
-			added, err := corerepo.Pin(n, ctx, req.Arguments(), recursive)
+			added, err := pinAndLabel(ctx)
 			ch <- pinResult{pins: added, err: err}
 		}()
 
@@ -280,6 +303,8 @@ Example:
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("type", "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").WithDefault("all"),
 		cmdkit.BoolOption("quiet", "q", "Write just hashes of objects."),
+		cmdkit.StringOption("name", "List only direct and recursive pins of this name."),
+		cmdkit.StringOption("meta", "List only direct and recursive pins with these labels, as comma separated key=value pairs. Use key= to match any value."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -302,10 +327,24 @@ Example:
 			return
 		}
 
+		name, _, _ := req.Option("name").String()
+		metaStr, _, _ := req.Option("meta").String()
+		filter := name != "" || metaStr != ""
+		if filter && (typeStr == "indirect" || len(req.Arguments()) > 0) {
+			res.SetError(errors.New("--name and --meta only apply to listing direct and recursive pins"), cmdkit.ErrClient)
+			return
+		}
+
 		var keys map[string]RefKeyObject
 
 		if len(req.Arguments()) > 0 {
 			keys, err = pinLsKeys(req.Arguments(), typeStr, req.Context(), n)
+		} else if filter {
+			var meta map[string]string
+			meta, err = parseMeta(metaStr)
+			if err == nil {
+				keys, err = pinLsQuery(typeStr, name, meta, n)
+			}
 		} else {
 			keys, err = pinLsAll(typeStr, req.Context(), n)
 		}
@@ -337,6 +376,8 @@ Example:
 			for k, v := range keys.Keys {
 				if quiet {
 					fmt.Fprintf(out, "%s\n", k)
+				} else if v.Name != "" {
+					fmt.Fprintf(out, "%s %s %s\n", k, v.Type, v.Name)
 				} else {
 					fmt.Fprintf(out, "%s %s\n", k, v.Type)
 				}
@@ -371,6 +412,11 @@ new pin and removing the old one.
 			return
 		}
 
+		if n.ReadOnly() {
+			res.SetError(core.ErrReadOnly, cmdkit.ErrNormal)
+			return
+		}
+
 		unpin, _, err := req.Option("unpin").Bool()
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -428,6 +474,80 @@ new pin and removing the old one.
 	},
 }
 
+var labelPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Set the name and labels of pins.",
+		ShortDescription: `
+Replaces the name and the labels of direct or recursive pins. They can be
+listed and filtered with 'ipfs pin ls --name' and 'ipfs pin ls --meta'.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, true, "Path to pinned object(s).").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("name", "A name for the pins."),
+		cmdkit.StringOption("meta", "Labels for the pins, as comma separated key=value pairs."),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		name, _, _ := req.Option("name").String()
+		metaStr, _, _ := req.Option("meta").String()
+		meta, err := parseMeta(metaStr)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		cids := make([]*cid.Cid, 0, len(req.Arguments()))
+		for _, arg := range req.Arguments() {
+			p, err := path.ParsePath(arg)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			c, err := core.ResolveToCid(req.Context(), n.Namesys, n.Resolver, p)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			cids = append(cids, c)
+		}
+
+		if err := corerepo.Label(n, cids, name, meta); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(&PinOutput{cidsToStrings(cids)})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PinOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range out.Pins {
+				fmt.Fprintf(buf, "labeled %s\n", k)
+			}
+			return buf, nil
+		},
+	},
+}
+
 var verifyPinCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Verify that recursive pins are complete.",
@@ -435,6 +555,7 @@ var verifyPinCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("verbose", "Also write the hashes of non-broken pins."),
 		cmdkit.BoolOption("quiet", "q", "Write just hashes of broken pins."),
+		cmdkit.BoolOption("rehash", "Re-hash all blocks to find corrupt ones too."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -450,9 +571,12 @@ var verifyPinCmd = &cmds.Command{
 			res.SetError(fmt.Errorf("The --verbose and --quiet options can not be used at the same time"), cmdkit.ErrNormal)
 		}
 
+		rehash, _, _ := res.Request().Option("rehash").Bool()
+
 		opts := pinVerifyOpts{
 			explain:   !quiet,
 			includeOk: verbose,
+			rehash:    rehash,
 		}
 		out := pinVerify(req.Context(), n, opts)
 
@@ -486,6 +610,8 @@ var verifyPinCmd = &cmds.Command{
 
 type RefKeyObject struct {
 	Type string
+	Name string            `json:",omitempty"`
+	Meta map[string]string `json:",omitempty"`
 }
 
 type RefKeyList struct {
@@ -545,9 +671,16 @@ func pinLsAll(typeStr string, ctx context.Context, n *core.IpfsNode) (map[string
 
 	AddToResultKeys := func(keyList []*cid.Cid, typeStr string) {
 		for _, c := range keyList {
-			keys[c.String()] = RefKeyObject{
+			obj := RefKeyObject{
 				Type: typeStr,
 			}
+			if typeStr != "indirect" {
+				if info, err := n.Pinning.PinInfo(c); err == nil {
+					obj.Name = info.Name
+					obj.Meta = info.Meta
+				}
+			}
+			keys[c.String()] = obj
 		}
 	}
 
@@ -557,7 +690,12 @@ func pinLsAll(typeStr string, ctx context.Context, n *core.IpfsNode) (map[string
 	if typeStr == "indirect" || typeStr == "all" {
 		set := cid.NewSet()
 		for _, k := range n.Pinning.RecursiveKeys() {
-			err := dag.EnumerateChildren(n.Context(), dag.GetLinksWithDAG(n.DAG), k, set.Visit)
+			err := dag.Walk(n.Context(), n.DAG, k, dag.SelectAll, func(nd ipld.Node, depth int) error {
+				if depth > 0 && !set.Visit(nd.Cid()) {
+					return dag.SkipLinks
+				}
+				return nil
+			})
 			if err != nil {
 				return nil, err
 			}
@@ -571,6 +709,40 @@ func pinLsAll(typeStr string, ctx context.Context, n *core.IpfsNode) (map[string
 	return keys, nil
 }
 
+func pinLsQuery(typeStr, name string, meta map[string]string, n *core.IpfsNode) (map[string]RefKeyObject, error) {
+	mode, ok := pin.StringToMode(typeStr)
+	if !ok {
+		return nil, fmt.Errorf("invalid pin mode '%s'", typeStr)
+	}
+
+	keys := make(map[string]RefKeyObject)
+	for _, info := range n.Pinning.Query(pin.Filter{Mode: mode, Name: name, Meta: meta}) {
+		modeStr, _ := pin.ModeToString(info.Mode)
+		keys[info.Cid.String()] = RefKeyObject{
+			Type: modeStr,
+			Name: info.Name,
+			Meta: info.Meta,
+		}
+	}
+	return keys, nil
+}
+
+// parseMeta parses comma separated key=value pairs.
+func parseMeta(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	meta := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", kv)
+		}
+		meta[kv[:i]] = kv[i+1:]
+	}
+	return meta, nil
+}
+
 // PinVerifyRes is the result returned for each pin checked in "pin verify"
 type PinVerifyRes struct {
 	Cid string
@@ -592,63 +764,26 @@ type BadNode struct {
 type pinVerifyOpts struct {
 	explain   bool
 	includeOk bool
+	rehash    bool
 }
 
 func pinVerify(ctx context.Context, n *core.IpfsNode, opts pinVerifyOpts) <-chan interface{} {
-	visited := make(map[string]PinStatus)
-
-	bs := n.Blocks.Blockstore()
-	DAG := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
-	getLinks := dag.GetLinksWithDAG(DAG)
-	recPins := n.Pinning.RecursiveKeys()
-
-	var checkPin func(root *cid.Cid) PinStatus
-	checkPin = func(root *cid.Cid) PinStatus {
-		key := root.String()
-		if status, ok := visited[key]; ok {
-			return status
-		}
-
-		if err := verifcid.ValidateCid(root); err != nil {
-			status := PinStatus{Ok: false}
-			if opts.explain {
-				status.BadNodes = []BadNode{BadNode{Cid: key, Err: err.Error()}}
-			}
-			visited[key] = status
-			return status
-		}
-
-		links, err := getLinks(ctx, root)
-		if err != nil {
-			status := PinStatus{Ok: false}
-			if opts.explain {
-				status.BadNodes = []BadNode{BadNode{Cid: key, Err: err.Error()}}
-			}
-			visited[key] = status
-			return status
-		}
-
-		status := PinStatus{Ok: true}
-		for _, lnk := range links {
-			res := checkPin(lnk.Cid)
-			if !res.Ok {
-				status.Ok = false
-				status.BadNodes = append(status.BadNodes, res.BadNodes...)
-			}
-		}
-
-		visited[key] = status
-		return status
-	}
+	results := pin.VerifyPins(ctx, n.Pinning, n.Blocks.Blockstore(), pin.VerifyOptions{
+		Rehash:    opts.rehash,
+		IncludeOk: opts.includeOk,
+	})
 
 	out := make(chan interface{})
 	go func() {
 		defer close(out)
-		for _, cid := range recPins {
-			pinStatus := checkPin(cid)
-			if !pinStatus.Ok || opts.includeOk {
-				out <- &PinVerifyRes{cid.String(), pinStatus}
+		for r := range results {
+			status := PinStatus{Ok: r.Ok}
+			if opts.explain {
+				for _, bn := range r.BadNodes {
+					status.BadNodes = append(status.BadNodes, BadNode{Cid: bn.Cid.String(), Err: bn.Err.Error()})
+				}
 			}
+			out <- &PinVerifyRes{r.Cid.String(), status}
 		}
 	}()
 
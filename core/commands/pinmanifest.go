@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var exportPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export the pins as a signed manifest.",
+		ShortDescription: `
+Writes a JSON manifest of the direct and recursive pins, with their names and
+labels, signed with the identity of the node. 'ipfs pin import' adds the pins
+of a manifest on another node.
+`,
+	},
+
+	Type: pin.Manifest{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		m, err := corerepo.ExportPins(n)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(m)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			m, ok := v.(*pin.Manifest)
+			if !ok {
+				return nil, e.TypeErr(m, v)
+			}
+
+			buf, err := json.MarshalIndent(m, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(append(buf, '\n')), nil
+		},
+	},
+}
+
+var importPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add the pins of a manifest written by 'ipfs pin export'.",
+		ShortDescription: `
+Verifies the signature of the manifest and adds its pins with their names and
+labels. When the daemon is running, the content is fetched in the background
+and the command returns right away; check the progress with 'ipfs pin ls'.
+Offline, only content available locally is pinned.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("manifest", true, false, "The manifest to import.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Only accept a manifest signed by this peer ID."),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		var from peer.ID
+		if s, _, _ := req.Option("from").String(); s != "" {
+			from, err = peer.IDB58Decode(s)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		var m pin.Manifest
+		if err := json.NewDecoder(file).Decode(&m); err != nil {
+			res.SetError(fmt.Errorf("invalid pin manifest: %s", err), cmdkit.ErrNormal)
+			return
+		}
+
+		cids, err := corerepo.ImportPins(n, req.Context(), &m, from)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(&PinOutput{cidsToStrings(cids)})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PinOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range out.Pins {
+				fmt.Fprintf(buf, "importing %s\n", k)
+			}
+			return buf, nil
+		},
+	},
+}
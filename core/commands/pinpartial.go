@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+var partialPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin the available parts of objects.",
+		ShortDescription: `
+Partial pins keep whatever blocks of a DAG are available locally, for content
+which is only intermittently reachable. The missing parts are fetched again
+in the background every 10 minutes while the daemon runs, and once the DAG is
+complete it is pinned recursively, replacing the partial pin.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add": addPartialPinCmd,
+		"ls":  listPartialPinCmd,
+		"rm":  rmPartialPinCmd,
+	},
+}
+
+type PartialPinOutput struct {
+	Cid       string
+	Name      string `json:",omitempty"`
+	Missing   []string
+	Created   time.Time
+	LastTried time.Time
+}
+
+type PartialPinList struct {
+	Pins []PartialPinOutput
+}
+
+func partialPinOutput(p *pin.PartialPin) PartialPinOutput {
+	return PartialPinOutput{
+		Cid:       p.Cid.String(),
+		Name:      p.Name,
+		Missing:   cidsToStrings(p.Missing),
+		Created:   p.Created,
+		LastTried: p.LastTried,
+	}
+}
+
+var addPartialPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin the locally available parts of objects.",
+		ShortDescription: `
+Pins the blocks of the objects available locally, without fetching anything.
+Objects available entirely are pinned recursively right away.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, true, "Path to object(s) to be pinned.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("name", "A name for the pins."),
+	},
+	Type: PartialPinList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cids, err := resolveCids(req, n)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		name, _, _ := req.Option("name").String()
+		out := &PartialPinList{Pins: []PartialPinOutput{}}
+		for _, c := range cids {
+			p, err := n.PartialPins.Add(req.Context(), c, name)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			out.Pins = append(out.Pins, partialPinOutput(p))
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PartialPinList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, p := range out.Pins {
+				if len(p.Missing) == 0 {
+					fmt.Fprintf(buf, "pinned %s recursively\n", p.Cid)
+				} else {
+					fmt.Fprintf(buf, "pinned %s partially, %d parts missing\n", p.Cid, len(p.Missing))
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+var listPartialPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List partial pins.",
+		ShortDescription: `
+Lists the partial pins with the number of missing parts. Partial pins which
+became complete are listed by 'ipfs pin ls' instead.
+`,
+	},
+
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("missing", "m", "List the roots of the missing parts."),
+	},
+	Type: PartialPinList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		pins, err := n.PartialPins.List()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		out := &PartialPinList{Pins: make([]PartialPinOutput, len(pins))}
+		for i, p := range pins {
+			out.Pins[i] = partialPinOutput(p)
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PartialPinList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+			missing, _, _ := res.Request().Option("missing").Bool()
+
+			buf := new(bytes.Buffer)
+			for _, p := range out.Pins {
+				fmt.Fprintf(buf, "%s %d missing", p.Cid, len(p.Missing))
+				if p.Name != "" {
+					fmt.Fprintf(buf, " %q", p.Name)
+				}
+				buf.WriteString("\n")
+				if missing {
+					for _, m := range p.Missing {
+						fmt.Fprintf(buf, "  %s\n", m)
+					}
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+var rmPartialPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove partial pins.",
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, true, "Path to object(s) to be unpinned.").EnableStdin(),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cids, err := resolveCids(req, n)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		for _, c := range cids {
+			if err := n.PartialPins.Remove(c); err != nil {
+				res.SetError(fmt.Errorf("%s: %s", c, err), cmdkit.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(&PinOutput{cidsToStrings(cids)})
+	},
+	Marshalers: pinOutputMarshaler("unpinned"),
+}
@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+var remotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin objects to remote pinning services.",
+		ShortDescription: `
+Delegates pins to remote pinning services implementing the IPFS pinning
+service API. The services are configured in Pinning.RemoteServices, and are
+referred to by their name there.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add":       addRemotePinCmd,
+		"rm":        rmRemotePinCmd,
+		"ls":        listRemotePinCmd,
+		"reconcile": reconcileRemotePinCmd,
+	},
+}
+
+type RemotePinOutput struct {
+	Service   string
+	RequestID string
+	Cid       string
+	Name      string `json:",omitempty"`
+	Status    string
+	Created   time.Time
+}
+
+type RemotePinList struct {
+	Pins []RemotePinOutput
+}
+
+type ReconcileOutput struct {
+	Added   []string
+	Removed []string
+}
+
+func remotePinOutput(p *pin.RemotePin) RemotePinOutput {
+	return RemotePinOutput{
+		Service:   p.Service,
+		RequestID: p.RequestID,
+		Cid:       p.Cid.String(),
+		Name:      p.Name,
+		Status:    string(p.Status),
+		Created:   p.Created,
+	}
+}
+
+var addRemotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin an object to a remote pinning service.",
+		ShortDescription: `
+Asks the service to pin the object. The service fetches it from the network,
+so this node should stay online until the pin is pinned; its status is shown
+by 'ipfs pin remote ls --refresh'.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("service", true, false, "Name of the pinning service."),
+		cmdkit.StringArg("ipfs-path", true, false, "Path to the object to be pinned."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("name", "An optional name for the pin."),
+	},
+	Type: RemotePinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		c, err := core.ResolveToCid(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		name, _, _ := req.Option("name").String()
+		rp, err := n.RemotePins.Add(req.Context(), req.Arguments()[0], c, name)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		out := remotePinOutput(rp)
+		res.SetOutput(&out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*RemotePinOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+			return bytes.NewBufferString(fmt.Sprintf("%s %s %s\n", out.Cid, out.Status, out.RequestID)), nil
+		},
+	},
+}
+
+var rmRemotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove an object from a remote pinning service.",
+		ShortDescription: `
+Removes all pins of the object made by this node from the service, letting it
+drop the data.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("service", true, false, "Name of the pinning service."),
+		cmdkit.StringArg("cid", true, true, "CIDs of the objects to be unpinned.").EnableStdin(),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		service := req.Arguments()[0]
+		var removed []*cid.Cid
+		for _, arg := range req.Arguments()[1:] {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			if err := n.RemotePins.Remove(req.Context(), service, c); err != nil {
+				res.SetError(fmt.Errorf("%s: %s", c, err), cmdkit.ErrNormal)
+				return
+			}
+			removed = append(removed, c)
+		}
+
+		res.SetOutput(&PinOutput{cidsToStrings(removed)})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PinOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range out.Pins {
+				fmt.Fprintf(buf, "unpinned %s\n", k)
+			}
+			return buf, nil
+		},
+	},
+}
+
+var listRemotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the objects pinned to a remote pinning service.",
+		ShortDescription: `
+Lists the pins made by this node, newest first, with their status as last
+seen. Use --refresh to ask the service for their current status.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("service", true, false, "Name of the pinning service."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("refresh", "Update the status of the pins from the service."),
+	},
+	Type: RemotePinList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		service := req.Arguments()[0]
+		refresh, _, _ := req.Option("refresh").Bool()
+		if refresh {
+			if err := n.RemotePins.Refresh(req.Context(), service); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		pins, err := n.RemotePins.List(service)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		out := &RemotePinList{Pins: make([]RemotePinOutput, 0, len(pins))}
+		for _, p := range pins {
+			out.Pins = append(out.Pins, remotePinOutput(p))
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*RemotePinList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, p := range out.Pins {
+				fmt.Fprintf(buf, "%s %s %s\n", p.Cid, p.Status, p.Name)
+			}
+			return buf, nil
+		},
+	},
+}
+
+var reconcileRemotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin all recursively pinned objects to a remote pinning service.",
+		ShortDescription: `
+Asks the service to pin every object pinned recursively on this node which it
+doesn't pin yet. Failed remote pins are requested again. With --unpin, pins of
+objects no longer pinned on this node are removed from the service.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("service", true, false, "Name of the pinning service."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("unpin", "Remove remote pins of objects no longer pinned locally."),
+	},
+	Type: ReconcileOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		service := req.Arguments()[0]
+		unpin, _, _ := req.Option("unpin").Bool()
+
+		// pick up failures before deciding what to add again
+		if err := n.RemotePins.Refresh(req.Context(), service); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		added, removed, err := n.RemotePins.Reconcile(req.Context(), service, unpin)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&ReconcileOutput{
+			Added:   cidsToStrings(added),
+			Removed: cidsToStrings(removed),
+		})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ReconcileOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range out.Added {
+				fmt.Fprintf(buf, "pinned %s\n", k)
+			}
+			for _, k := range out.Removed {
+				fmt.Fprintf(buf, "unpinned %s\n", k)
+			}
+			return buf, nil
+		},
+	},
+}
@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	path "github.com/ipfs/go-ipfs/path"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+var errReplicationDisabled = errors.New("pin replication is not enabled, see Pinning.Replication in the config")
+
+var replicatePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Replicate pins among trusted peers.",
+		ShortDescription: `
+Shares a ledger of pins with the peers configured in Pinning.Replication, and
+keeps each pin of the ledger on a number of them. The peers holding a pin
+change as peers come and go. Needs a running daemon.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add":    addReplicatePinCmd,
+		"rm":     rmReplicatePinCmd,
+		"status": statusReplicatePinCmd,
+	},
+}
+
+type ReplicationStatus struct {
+	Cid     string
+	Name    string `json:",omitempty"`
+	Factor  int
+	Holders []string
+}
+
+type ReplicationStatusList struct {
+	Pins []ReplicationStatus
+}
+
+var addReplicatePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add objects to the replication ledger.",
+		ShortDescription: `
+Adds the objects to the ledger shared with the trusted peers. The peers
+responsible for them pin them in the background, which 'ipfs pin replicate
+status' shows.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, true, "Path to objects to be replicated.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("factor", "Number of peers holding the objects, Pinning.Replication.Factor by default."),
+		cmdkit.StringOption("name", "A name for the pins."),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if n.Replication == nil {
+			res.SetError(errReplicationDisabled, cmdkit.ErrClient)
+			return
+		}
+
+		factor, _, _ := req.Option("factor").Int()
+		if factor < 0 {
+			res.SetError(errors.New("factor must be positive"), cmdkit.ErrClient)
+			return
+		}
+		name, _, _ := req.Option("name").String()
+
+		cids, err := resolveCids(req, n)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		for _, c := range cids {
+			if err := n.Replication.Add(c, name, factor); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(&PinOutput{cidsToStrings(cids)})
+	},
+	Marshalers: pinOutputMarshaler("replicating"),
+}
+
+var rmReplicatePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove objects from the replication ledger.",
+		ShortDescription: `
+Removes the objects from the ledger, the peers holding them unpin them. Pins
+made directly with 'ipfs pin add' are kept.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, true, "Path to objects to stop replicating.").EnableStdin(),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if n.Replication == nil {
+			res.SetError(errReplicationDisabled, cmdkit.ErrClient)
+			return
+		}
+
+		cids, err := resolveCids(req, n)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		for _, c := range cids {
+			if err := n.Replication.Remove(c); err != nil {
+				res.SetError(fmt.Errorf("%s: %s", c, err), cmdkit.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(&PinOutput{cidsToStrings(cids)})
+	},
+	Marshalers: pinOutputMarshaler("removed"),
+}
+
+var statusReplicatePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the replication status of the ledger.",
+		ShortDescription: `
+Lists the pins of the ledger with the number of peers which should hold them,
+and the peers known to hold them. The peers' state is only as recent as their
+last announcement.
+`,
+	},
+
+	Type: ReplicationStatusList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if n.Replication == nil {
+			res.SetError(errReplicationDisabled, cmdkit.ErrClient)
+			return
+		}
+
+		out := &ReplicationStatusList{Pins: []ReplicationStatus{}}
+		for _, st := range n.Replication.Status() {
+			holders := make([]string, len(st.Holders))
+			for i, p := range st.Holders {
+				holders[i] = p.Pretty()
+			}
+			out.Pins = append(out.Pins, ReplicationStatus{
+				Cid:     st.Cid.String(),
+				Name:    st.Name,
+				Factor:  st.Factor,
+				Holders: holders,
+			})
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ReplicationStatusList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, st := range out.Pins {
+				fmt.Fprintf(buf, "%s %d/%d", st.Cid, len(st.Holders), st.Factor)
+				if st.Name != "" {
+					fmt.Fprintf(buf, " %q", st.Name)
+				}
+				if len(st.Holders) > 0 {
+					fmt.Fprintf(buf, " %s", strings.Join(st.Holders, ","))
+				}
+				buf.WriteString("\n")
+			}
+			return buf, nil
+		},
+	},
+}
+
+func resolveCids(req cmds.Request, n *core.IpfsNode) ([]*cid.Cid, error) {
+	cids := make([]*cid.Cid, 0, len(req.Arguments()))
+	for _, arg := range req.Arguments() {
+		p, err := path.ParsePath(arg)
+		if err != nil {
+			return nil, err
+		}
+		c, err := core.ResolveToCid(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			return nil, err
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
+
+func pinOutputMarshaler(verb string) cmds.MarshalerMap {
+	return cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PinOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range out.Pins {
+				fmt.Fprintf(buf, "%s %s\n", verb, k)
+			}
+			return buf, nil
+		},
+	}
+}
@@ -0,0 +1,288 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	provided "github.com/ipfs/go-ipfs/exchange/provided"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+var ProviderCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and control the announcements of content.",
+		ShortDescription: `
+The node announces the content it has to the network (through the DHT, and
+the delegates and trackers configured), so that other peers can find it. These
+commands show what was announced and when, and announce or stop announcing
+content on demand.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"ls":  lsProviderCmd,
+		"add": addProviderCmd,
+		"rm":  rmProviderCmd,
+	},
+}
+
+type ProvideRecordOutput struct {
+	Cid          string
+	LastProvided time.Time `json:",omitempty"`
+	LastAttempt  time.Time `json:",omitempty"`
+	Err          string    `json:",omitempty"`
+	Valid        bool
+	Stopped      bool `json:",omitempty"`
+}
+
+type ProvideRecordList struct {
+	Records []ProvideRecordOutput
+}
+
+func provideRecordOutput(r *provided.Record) ProvideRecordOutput {
+	return ProvideRecordOutput{
+		Cid:          r.Cid.String(),
+		LastProvided: r.LastProvided,
+		LastAttempt:  r.LastAttempt,
+		Err:          r.Err,
+		Valid:        r.Valid(),
+	}
+}
+
+func getProvided(req cmds.Request) (*core.IpfsNode, error) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	if !n.OnlineMode() || n.Provided == nil {
+		return nil, errNotOnline
+	}
+	return n, nil
+}
+
+var lsProviderCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the content announced.",
+		ShortDescription: `
+Lists the content whose provider records announced by this node are still
+valid, most recently announced first, with the time of the last announcement
+and the error of the last attempt, if it failed. With --all, lists the content
+whose records expired too, and the content stopped with 'ipfs provider rm'.
+Given CIDs, lists their records only.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", false, true, "CIDs to show the records of."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("all", "a", "Also list expired records and stopped content."),
+	},
+	Type: ProvideRecordList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := getProvided(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		out := &ProvideRecordList{Records: []ProvideRecordOutput{}}
+		if len(req.Arguments()) > 0 {
+			for _, arg := range req.Arguments() {
+				c, err := cid.Decode(arg)
+				if err != nil {
+					res.SetError(err, cmdkit.ErrClient)
+					return
+				}
+				r, err := n.Provided.Record(c)
+				if err != nil {
+					res.SetError(err, cmdkit.ErrNormal)
+					return
+				}
+				out.Records = append(out.Records, provideRecordOutput(r))
+			}
+			res.SetOutput(out)
+			return
+		}
+
+		all, _, _ := req.Option("all").Bool()
+		records, err := n.Provided.Records()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		for _, r := range records {
+			if all || r.Valid() {
+				out.Records = append(out.Records, provideRecordOutput(r))
+			}
+		}
+		if all {
+			stopped, err := n.Provided.Stopped()
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			for _, c := range stopped {
+				out.Records = append(out.Records, ProvideRecordOutput{Cid: c.String(), Stopped: true})
+			}
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ProvideRecordList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, r := range out.Records {
+				switch {
+				case r.Stopped:
+					fmt.Fprintf(buf, "%s stopped\n", r.Cid)
+				case r.LastAttempt.IsZero():
+					fmt.Fprintf(buf, "%s never announced\n", r.Cid)
+				case r.Err != "":
+					fmt.Fprintf(buf, "%s failed %s: %s\n", r.Cid, r.LastAttempt.Format(time.RFC3339), r.Err)
+				case r.Valid:
+					fmt.Fprintf(buf, "%s announced %s\n", r.Cid, r.LastProvided.Format(time.RFC3339))
+				default:
+					fmt.Fprintf(buf, "%s expired %s\n", r.Cid, r.LastProvided.Format(time.RFC3339))
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+var addProviderCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Announce content now.",
+		ShortDescription: `
+Announces the content immediately, without waiting for the reprovider, and
+resumes its announcements if they were stopped with 'ipfs provider rm'. The
+content doesn't need to be stored by this node.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, true, "CIDs of the content to announce.").EnableStdin(),
+	},
+	Type: ProvideRecordList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := getProvided(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		out := &ProvideRecordList{Records: []ProvideRecordOutput{}}
+		for _, arg := range req.Arguments() {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			if err := n.Provided.Announce(req.Context(), c); err != nil {
+				res.SetError(fmt.Errorf("%s: %s", c, err), cmdkit.ErrNormal)
+				return
+			}
+			r, err := n.Provided.Record(c)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			out.Records = append(out.Records, provideRecordOutput(r))
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ProvideRecordList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, r := range out.Records {
+				fmt.Fprintf(buf, "announced %s\n", r.Cid)
+			}
+			return buf, nil
+		},
+	},
+}
+
+var rmProviderCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stop announcing content.",
+		ShortDescription: `
+Stops announcing the content, through the reprovider or when it is fetched,
+until it is announced again with 'ipfs provider add'. The provider records
+already announced can't be withdrawn, other peers drop them once they expire,
+within 24 hours.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, true, "CIDs of the content to stop announcing.").EnableStdin(),
+	},
+	Type: ProvideRecordList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := getProvided(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		out := &ProvideRecordList{Records: []ProvideRecordOutput{}}
+		for _, arg := range req.Arguments() {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			if err := n.Provided.Stop(c); err != nil {
+				res.SetError(fmt.Errorf("%s: %s", c, err), cmdkit.ErrNormal)
+				return
+			}
+			out.Records = append(out.Records, ProvideRecordOutput{Cid: c.String(), Stopped: true})
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ProvideRecordList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, r := range out.Records {
+				fmt.Fprintf(buf, "stopped %s\n", r.Cid)
+			}
+			return buf, nil
+		},
+	},
+}
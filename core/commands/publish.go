@@ -11,6 +11,7 @@ import (
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	events "github.com/ipfs/go-ipfs/core/events"
 	keystore "github.com/ipfs/go-ipfs/keystore"
 	path "github.com/ipfs/go-ipfs/path"
 
@@ -187,6 +188,7 @@ func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.P
 	if err != nil {
 		return nil, err
 	}
+	n.Events.Emit(events.Event{Type: events.IpnsPublished, Name: pid.Pretty(), Value: ref.String()})
 
 	return &IpnsEntry{
 		Name:  pid.Pretty(),
@@ -12,12 +12,12 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	pubsub "github.com/ipfs/go-ipfs/pubsub"
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
-	floodsub "github.com/libp2p/go-floodsub"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
 )
 
@@ -61,6 +61,13 @@ to be used in a production environment.
 
 To use, the daemon must be run with '--enable-pubsub-experiment'.
 
+Messages are signed by the node publishing them, and messages whose
+signature doesn't verify are dropped, unless Pubsub.DisableSigning is set in
+the config (then only on the topics listed in Pubsub.SignedTopics).
+
+With '--replay', the messages of the topic kept by the node (see
+Pubsub.PersistMessages in the config) are output first.
+
 This command outputs data in the following encodings:
   * "json"
 (Specified by the "--encoding" or "--enc" flag)
@@ -71,6 +78,7 @@ This command outputs data in the following encodings:
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("discover", "try to discover other peers subscribed to the same topic"),
+		cmdkit.BoolOption("replay", "output the messages of the topic kept by the node first"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
 		n, err := GetNode(env)
@@ -85,13 +93,13 @@ This command outputs data in the following encodings:
 			return
 		}
 
-		if n.Floodsub == nil {
+		if n.PubSub == nil {
 			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use."), cmdkit.ErrNormal)
 			return
 		}
 
 		topic := req.Arguments[0]
-		sub, err := n.Floodsub.Subscribe(topic)
+		sub, err := n.PubSub.Subscribe(topic)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -116,6 +124,18 @@ This command outputs data in the following encodings:
 			f.Flush()
 		}
 
+		replay, _ := req.Options["replay"].(bool)
+		if replay {
+			msgs, err := n.PubSub.Replay(topic)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			for _, msg := range msgs {
+				res.Emit(newPubsubMessage(msg))
+			}
+		}
+
 		for {
 			msg, err := sub.Next(req.Context)
 			if err == io.EOF || err == context.Canceled {
@@ -125,12 +145,12 @@ This command outputs data in the following encodings:
 				return
 			}
 
-			res.Emit(msg)
+			res.Emit(newPubsubMessage(msg))
 		}
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
-			m, ok := v.(*floodsub.Message)
+			m, ok := v.(*pubsubMessage)
 			if !ok {
 				return fmt.Errorf("unexpected type: %T", v)
 			}
@@ -139,7 +159,7 @@ This command outputs data in the following encodings:
 			return err
 		}),
 		"ndpayload": cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
-			m, ok := v.(*floodsub.Message)
+			m, ok := v.(*pubsubMessage)
 			if !ok {
 				return fmt.Errorf("unexpected type: %T", v)
 			}
@@ -149,7 +169,7 @@ This command outputs data in the following encodings:
 			return err
 		}),
 		"lenpayload": cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
-			m, ok := v.(*floodsub.Message)
+			m, ok := v.(*pubsubMessage)
 			if !ok {
 				return fmt.Errorf("unexpected type: %T", v)
 			}
@@ -162,7 +182,29 @@ This command outputs data in the following encodings:
 			return err
 		}),
 	},
-	Type: floodsub.Message{},
+	Type: pubsubMessage{},
+}
+
+// pubsubMessage is the output of ipfs pubsub sub, encoded as the floodsub
+// messages used to be.
+type pubsubMessage struct {
+	From      []byte   `json:"from,omitempty"`
+	Data      []byte   `json:"data,omitempty"`
+	Seqno     []byte   `json:"seqno,omitempty"`
+	TopicIDs  []string `json:"topicIDs,omitempty"`
+	Signature []byte   `json:"signature,omitempty"`
+}
+
+func newPubsubMessage(m *pubsub.Message) *pubsubMessage {
+	seqno := make([]byte, 8)
+	binary.BigEndian.PutUint64(seqno, m.Seqno)
+	return &pubsubMessage{
+		From:      []byte(m.From),
+		Data:      m.Data,
+		Seqno:     seqno,
+		TopicIDs:  []string{m.Topic},
+		Signature: m.Signature,
+	}
 }
 
 func connectToPubSubPeers(ctx context.Context, n *core.IpfsNode, cid *cid.Cid) {
@@ -218,7 +260,7 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 			return
 		}
 
-		if n.Floodsub == nil {
+		if n.PubSub == nil {
 			res.SetError("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use.", cmdkit.ErrNormal)
 			return
 		}
@@ -232,7 +274,7 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 		}
 
 		for _, data := range req.Arguments[1:] {
-			if err := n.Floodsub.Publish(topic, []byte(data)); err != nil {
+			if err := n.PubSub.Publish(topic, []byte(data)); err != nil {
 				res.SetError(err, cmdkit.ErrNormal)
 				return
 			}
@@ -265,12 +307,12 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 			return
 		}
 
-		if n.Floodsub == nil {
+		if n.PubSub == nil {
 			res.SetError("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use.", cmdkit.ErrNormal)
 			return
 		}
 
-		cmds.EmitOnce(res, stringList{n.Floodsub.GetTopics()})
+		cmds.EmitOnce(res, stringList{n.PubSub.Topics()})
 	},
 	Type: stringList{},
 	Encoders: cmds.EncoderMap{
@@ -322,7 +364,7 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 			return
 		}
 
-		if n.Floodsub == nil {
+		if n.PubSub == nil {
 			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use."), cmdkit.ErrNormal)
 			return
 		}
@@ -332,7 +374,7 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 			topic = req.Arguments[0]
 		}
 
-		peers := n.Floodsub.ListPeers(topic)
+		peers := n.PubSub.Peers(topic)
 		list := &stringList{make([]string, 0, len(peers))}
 
 		for _, peer := range peers {
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,10 +10,12 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	scrubber "github.com/ipfs/go-ipfs/blocks/scrubber"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
@@ -36,11 +39,14 @@ var RepoCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"stat":    repoStatCmd,
-		"gc":      lgc.NewCommand(repoGcCmd),
-		"fsck":    lgc.NewCommand(RepoFsckCmd),
-		"version": lgc.NewCommand(repoVersionCmd),
-		"verify":  lgc.NewCommand(repoVerifyCmd),
+		"stat":      repoStatCmd,
+		"blockstat": repoBlockStatCmd,
+		"compact":   repoCompactCmd,
+		"scrub":     repoScrubCmd,
+		"gc":        lgc.NewCommand(repoGcCmd),
+		"fsck":      lgc.NewCommand(RepoFsckCmd),
+		"version":   lgc.NewCommand(repoVersionCmd),
+		"verify":    lgc.NewCommand(repoVerifyCmd),
 	},
 }
 
@@ -48,6 +54,12 @@ var RepoCmd = &cmds.Command{
 type GcResult struct {
 	Key   *cid.Cid
 	Error string `json:",omitempty"`
+
+	// Size is reported for each block with --dry-run, Count and TotalSize
+	// in the last result.
+	Size      uint64 `json:",omitempty"`
+	Count     uint64 `json:",omitempty"`
+	TotalSize uint64 `json:",omitempty"`
 }
 
 var repoGcCmd = &oldcmds.Command{
@@ -57,11 +69,16 @@ var repoGcCmd = &oldcmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+
+With --dry-run, nothing is removed: the objects that would be removed are
+listed with their size, followed by their count and total size. Adds and
+pins aren't blocked meanwhile, so the result is an estimate.
 `,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("stream-errors", "Stream errors."),
 		cmdkit.BoolOption("quiet", "q", "Write minimal output."),
+		cmdkit.BoolOption("dry-run", "List what would be removed without removing it."),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -71,8 +88,14 @@ order to reclaim hard disk space.
 		}
 
 		streamErrors, _, _ := res.Request().Option("stream-errors").Bool()
+		dryRun, _, _ := res.Request().Option("dry-run").Bool()
 
-		gcOutChan := corerepo.GarbageCollectAsync(n, req.Context())
+		var gcOutChan <-chan gc.Result
+		if dryRun {
+			gcOutChan = corerepo.GarbageCollectDryRun(n, req.Context())
+		} else {
+			gcOutChan = corerepo.GarbageCollectAsync(n, req.Context())
+		}
 
 		outChan := make(chan interface{})
 		res.SetOutput(outChan)
@@ -80,6 +103,21 @@ order to reclaim hard disk space.
 		go func() {
 			defer close(outChan)
 
+			var total GcResult
+			if dryRun {
+				defer func() {
+					select {
+					case outChan <- &total:
+					case <-req.Context().Done():
+					}
+				}()
+			}
+			removed := func(r gc.Result) *GcResult {
+				total.Count++
+				total.TotalSize += r.Size
+				return &GcResult{Key: r.KeyRemoved, Size: r.Size}
+			}
+
 			if streamErrors {
 				errs := false
 				for res := range gcOutChan {
@@ -92,7 +130,7 @@ order to reclaim hard disk space.
 						errs = true
 					} else {
 						select {
-						case outChan <- &GcResult{Key: res.KeyRemoved}:
+						case outChan <- removed(res):
 						case <-req.Context().Done():
 							return
 						}
@@ -102,9 +140,9 @@ order to reclaim hard disk space.
 					res.SetError(fmt.Errorf("encountered errors during gc run"), cmdkit.ErrNormal)
 				}
 			} else {
-				err := corerepo.CollectResult(req.Context(), gcOutChan, func(k *cid.Cid) {
+				err := corerepo.CollectResult(req.Context(), gcOutChan, func(r gc.Result) {
 					select {
-					case outChan <- &GcResult{Key: k}:
+					case outChan <- removed(r):
 					case <-req.Context().Done():
 					}
 				})
@@ -137,8 +175,20 @@ order to reclaim hard disk space.
 				return nil, nil
 			}
 
+			dryRun, _, _ := res.Request().Option("dry-run").Bool()
+			if obj.Key == nil {
+				if quiet {
+					return nil, nil
+				}
+				return strings.NewReader(fmt.Sprintf("would remove %d objects, %d bytes\n", obj.Count, obj.TotalSize)), nil
+			}
+
 			msg := obj.Key.String() + "\n"
-			if !quiet {
+			switch {
+			case quiet:
+			case dryRun:
+				msg = fmt.Sprintf("would remove %s (%d bytes)\n", obj.Key, obj.Size)
+			default:
 				msg = "removed " + msg
 			}
 
@@ -147,6 +197,203 @@ order to reclaim hard disk space.
 	},
 }
 
+var repoBlockStatCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show how the blockstore uses disk space.",
+		ShortDescription: `
+'ipfs repo blockstat' scans all stored blocks and prints their count and total
+size, the distribution of block sizes, orphaned temporary files left behind by
+interrupted writes, and the storage amplification (disk used per byte of block
+data). Scanning large repos can take a while.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		stat, err := corerepo.BlockstoreStat(req.Context, n)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cmds.EmitOnce(res, stat)
+	},
+	Type: corerepo.BlockStat{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			stat, ok := v.(*corerepo.BlockStat)
+			if !ok {
+				return e.TypeErr(stat, v)
+			}
+
+			wtr := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			fmt.Fprintf(wtr, "NumBlocks:\t%d\n", stat.NumBlocks)
+			fmt.Fprintf(wtr, "BlocksSize:\t%d\n", stat.BlocksSize)
+			fmt.Fprintf(wtr, "RepoSize:\t%d\n", stat.RepoSize)
+			fmt.Fprintf(wtr, "Amplification:\t%.2f\n", stat.Amplification)
+			fmt.Fprintf(wtr, "OrphanedTempFiles:\t%d (%d bytes)\n", stat.OrphanedTempFiles, stat.OrphanedTempFilesSize)
+			fmt.Fprintf(wtr, "SizeDistribution:\n")
+			for _, b := range stat.SizeDistribution {
+				if b.UpTo == 0 {
+					fmt.Fprintf(wtr, "  larger:\t%d\n", b.Count)
+				} else {
+					fmt.Fprintf(wtr, "  <= %d:\t%d\n", b.UpTo, b.Count)
+				}
+			}
+			return wtr.Flush()
+		}),
+	},
+}
+
+var repoCompactCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Reclaim disk space held by the repo's storage.",
+		ShortDescription: `
+'ipfs repo compact' removes orphaned temporary files left behind by interrupted
+writes, and compacts the datastore if its backend supports it. It does not
+remove unpinned blocks, use 'ipfs repo gc' for that.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if err := corerepo.Compact(n); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.Close()
+	},
+}
+
+var errScrubberDisabled = errors.New("the blockstore scrubber is not running, enable it with Datastore.Scrub.Enabled")
+
+var repoScrubCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the background blockstore scrubber.",
+		ShortDescription: `
+When Datastore.Scrub.Enabled is set, the daemon periodically re-hashes all
+stored blocks to detect corruption, and replaces corrupt blocks with copies
+from the network if Datastore.Scrub.Repair is set.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status":   repoScrubStatusCmd,
+		"findings": repoScrubFindingsCmd,
+	},
+}
+
+var repoScrubStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the progress of the blockstore scrubber.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if n.Scrubber == nil {
+			res.SetError(errScrubberDisabled, cmdkit.ErrClient)
+			return
+		}
+
+		st := n.Scrubber.Status()
+		cmds.EmitOnce(res, &st)
+	},
+	Type: scrubber.Status{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			st, ok := v.(*scrubber.Status)
+			if !ok {
+				return e.TypeErr(st, v)
+			}
+
+			wtr := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			fmt.Fprintf(wtr, "Running:\t%t\n", st.Running)
+			fmt.Fprintf(wtr, "Passes:\t%d\n", st.Passes)
+			fmt.Fprintf(wtr, "Checked:\t%d (%d bytes)\n", st.Checked, st.CheckedSize)
+			fmt.Fprintf(wtr, "Corrupt:\t%d\n", st.Corrupt)
+			fmt.Fprintf(wtr, "Repaired:\t%d\n", st.Repaired)
+			if !st.LastPassStarted.IsZero() {
+				fmt.Fprintf(wtr, "LastPassStarted:\t%s\n", st.LastPassStarted)
+			}
+			if !st.LastPassFinished.IsZero() {
+				fmt.Fprintf(wtr, "LastPassFinished:\t%s\n", st.LastPassFinished)
+			}
+			return wtr.Flush()
+		}),
+	},
+}
+
+// ScrubFindings lists the corrupt blocks found by the scrubber.
+type ScrubFindings struct {
+	Findings []scrubber.Finding
+}
+
+var repoScrubFindingsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the corrupt blocks found by the blockstore scrubber.",
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("clear", "Forget the findings after listing them."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if n.Scrubber == nil {
+			res.SetError(errScrubberDisabled, cmdkit.ErrClient)
+			return
+		}
+
+		findings, err := n.Scrubber.Findings()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if clear, _ := req.Options["clear"].(bool); clear {
+			if err := n.Scrubber.ClearFindings(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		cmds.EmitOnce(res, &ScrubFindings{Findings: findings})
+	},
+	Type: ScrubFindings{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			list, ok := v.(*ScrubFindings)
+			if !ok {
+				return e.TypeErr(list, v)
+			}
+
+			for _, f := range list.Findings {
+				switch {
+				case f.Repaired:
+					fmt.Fprintf(w, "%s found %s, repaired\n", f.Cid, f.Found)
+				case f.Error != "":
+					fmt.Fprintf(w, "%s found %s, repair failed: %s\n", f.Cid, f.Found, f.Error)
+				default:
+					fmt.Fprintf(w, "%s found %s\n", f.Cid, f.Found)
+				}
+			}
+			return nil
+		}),
+	},
+}
+
 var repoStatCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Get stats for the currently used repo.",
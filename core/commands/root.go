@@ -19,13 +19,14 @@ import (
 var log = logging.Logger("core/commands")
 
 const (
-	ApiOption = "api"
+	ApiOption    = "api"
+	TenantOption = "tenant"
 )
 
 var Root = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:  "Global p2p merkle-dag filesystem.",
-		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--api=<api>] <command> ...",
+		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--api=<api>] [--tenant=<tenant>] <command> ...",
 		Subcommands: `
 BASIC COMMANDS
   init          Initialize ipfs local configuration
@@ -51,6 +52,8 @@ ADVANCED COMMANDS
   pin           Pin objects to local storage
   repo          Manipulate the IPFS repository
   stats         Various operational stats
+  events        Stream the events of the node
+  mode          Show or switch the online mode of the daemon
   p2p           Libp2p stream mounting
   filestore     Manage the filestore (experimental)
 
@@ -59,6 +62,7 @@ NETWORK COMMANDS
   bootstrap     Add or remove bootstrap peers
   swarm         Manage connections to the p2p network
   dht           Query the DHT for values or peers
+  provider      Inspect and control the announcements of content
   ping          Measure the latency of a connection
   diag          Print diagnostics
 
@@ -91,6 +95,7 @@ The CLI will exit with one of the following values:
 		cmdkit.BoolOption("h", "Show a short version of the command help text."),
 		cmdkit.BoolOption("local", "L", "Run the command locally, instead of using the daemon."),
 		cmdkit.StringOption(ApiOption, "Use a specific API instance (defaults to /ip4/127.0.0.1/tcp/5001)"),
+		cmdkit.StringOption(TenantOption, "Run the command on the repo of a tenant of the daemon."),
 
 		// global options, added to every command
 		cmds.OptionEncodingType,
@@ -104,16 +109,20 @@ var CommandsDaemonCmd = CommandsCmd(Root)
 
 var rootSubcommands = map[string]*cmds.Command{
 	"add":       AddCmd,
+	"archive":   ArchiveCmd,
 	"bitswap":   BitswapCmd,
 	"block":     BlockCmd,
 	"cat":       CatCmd,
 	"commands":  CommandsDaemonCmd,
+	"events":    EventsCmd,
 	"files":     FilesCmd,
 	"filestore": FileStoreCmd,
 	"get":       GetCmd,
+	"mode":      ModeCmd,
 	"pubsub":    PubsubCmd,
 	"repo":      RepoCmd,
 	"stats":     StatsCmd,
+	"urlstore":  urlStoreCmd,
 	"bootstrap": lgc.NewCommand(BootstrapCmd),
 	"config":    lgc.NewCommand(ConfigCmd),
 	"dag":       lgc.NewCommand(dag.DagCmd),
@@ -129,6 +138,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"object":    ocmd.ObjectCmd,
 	"pin":       lgc.NewCommand(PinCmd),
 	"ping":      lgc.NewCommand(PingCmd),
+	"provider":  lgc.NewCommand(ProviderCmd),
 	"p2p":       lgc.NewCommand(P2PCmd),
 	"refs":      lgc.NewCommand(RefsCmd),
 	"resolve":   lgc.NewCommand(ResolveCmd),
@@ -176,6 +186,7 @@ var rootROSubcommands = map[string]*cmds.Command{
 		Subcommands: map[string]*oldcmds.Command{
 			"get":     dag.DagGetCmd,
 			"resolve": dag.DagResolveCmd,
+			"export":  dag.DagExportCmd,
 		},
 	}),
 	"resolve": lgc.NewCommand(ResolveCmd),
@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
 	"time"
 
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	resource "github.com/ipfs/go-ipfs/resource"
+
 	humanize "github.com/dustin/go-humanize"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -26,9 +32,11 @@ for your IPFS node.`,
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"bw":      statBwCmd,
-		"repo":    repoStatCmd,
-		"bitswap": bitswapStatCmd,
+		"bw":        statBwCmd,
+		"repo":      repoStatCmd,
+		"bitswap":   bitswapStatCmd,
+		"gc":        statGcCmd,
+		"resources": statResourcesCmd,
 	},
 }
 
@@ -187,3 +195,169 @@ func printStats(out io.Writer, bs *metrics.Stats) {
 	fmt.Fprintf(out, "RateIn: %s/s\n", humanize.Bytes(uint64(bs.RateIn)))
 	fmt.Fprintf(out, "RateOut: %s/s\n", humanize.Bytes(uint64(bs.RateOut)))
 }
+
+var statGcCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print garbage collection statistics.",
+		ShortDescription: `
+'ipfs stats gc' prints the statistics of the last garbage collection of the
+daemon. With --stream, it prints the progress of garbage collections as they
+run instead: when they start, when the blocks to keep are marked, and when
+they finish.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("stream", "s", "Print the progress of garbage collections as they run."),
+		cmdkit.BoolOption("blocks", "Also print every block removed, with --stream."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		nd, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		stream, _ := req.Options["stream"].(bool)
+		if !stream {
+			last := nd.GCEvents.Last()
+			if last == nil {
+				res.SetError(errors.New("no garbage collection finished yet"), cmdkit.ErrNormal)
+				return
+			}
+			cmds.EmitOnce(res, &gc.Event{
+				Type:  gc.EventFinished,
+				Time:  last.Started.Add(last.Duration),
+				Stats: *last,
+			})
+			return
+		}
+
+		blocks, _ := req.Options["blocks"].(bool)
+		events := make(chan gc.Event, 128)
+		cancel := nd.GCEvents.Subscribe(func(ev gc.Event) {
+			if ev.Type == gc.EventRemoved && !blocks {
+				return
+			}
+			select {
+			case events <- ev:
+			default:
+				// don't slow down the gc for a slow client
+			}
+		})
+		defer cancel()
+
+		for {
+			select {
+			case ev := <-events:
+				if err := res.Emit(&ev); err != nil {
+					return
+				}
+			case <-req.Context.Done():
+				return
+			}
+		}
+	},
+	Type: gc.Event{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			ev, ok := v.(*gc.Event)
+			if !ok {
+				return fmt.Errorf("unexpected type: %T", v)
+			}
+
+			st := ev.Stats
+			var err error
+			switch ev.Type {
+			case gc.EventStarted:
+				_, err = fmt.Fprintf(w, "gc started at %s\n", st.Started.Format(time.RFC3339))
+			case gc.EventMarked:
+				_, err = fmt.Fprintf(w, "marked %d blocks from %d roots in %s\n", st.Marked, st.Roots, st.MarkDuration)
+			case gc.EventRemoved:
+				_, err = fmt.Fprintf(w, "removed %s\n", ev.Key)
+			case gc.EventFinished:
+				_, err = fmt.Fprintf(w, "gc started at %s finished in %s: marked %d blocks from %d roots, removed %d blocks, %d errors\n",
+					st.Started.Format(time.RFC3339), st.Duration, st.Marked, st.Roots, st.Removed, st.Errors)
+			}
+			return err
+		}),
+	},
+}
+
+var statResourcesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the resources used by connections and streams.",
+		ShortDescription: `
+'ipfs stats resources' prints the connections, streams and memory used by the
+daemon, and by each subsystem and protocol, next to their limits. The limits
+are set in the "Swarm.ResourceMgr" config key. With --peers, it prints the
+usage of each peer too.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("peers", "p", "Also print the usage of each peer."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		nd, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() || nd.Resources == nil {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		st := nd.Resources.Stat()
+		if peers, _ := req.Options["peers"].(bool); !peers {
+			st.Peers = nil
+		}
+		cmds.EmitOnce(res, &st)
+	},
+	Type: resource.Stat{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			st, ok := v.(*resource.Stat)
+			if !ok {
+				return fmt.Errorf("unexpected type: %T", v)
+			}
+
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Scope\tConns\tStreams In\tStreams Out\tMemory")
+			printScope := func(name string, s resource.ScopeStat) {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", name,
+					usageOf(strconv.Itoa(s.Usage.Conns), s.Limit.Conns > 0, strconv.Itoa(s.Limit.Conns)),
+					s.Usage.StreamsIn, s.Usage.StreamsOut,
+					usageOf(humanize.Bytes(uint64(s.Usage.Memory)), s.Limit.Memory > 0, humanize.Bytes(uint64(s.Limit.Memory))))
+			}
+
+			printScope("system", st.System)
+			for _, group := range []struct {
+				prefix string
+				scopes map[string]resource.ScopeStat
+			}{
+				{"subsystem:", st.Subsystems},
+				{"protocol:", st.Protocols},
+				{"peer:", st.Peers},
+			} {
+				names := make([]string, 0, len(group.scopes))
+				for name := range group.scopes {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					printScope(group.prefix+name, group.scopes[name])
+				}
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+// usageOf formats a usage, followed by its limit if limited.
+func usageOf(usage string, limited bool, limit string) string {
+	if !limited {
+		return usage
+	}
+	return usage + "/" + limit
+}
@@ -45,6 +45,8 @@ ipfs peers in the internet.
 		"connect":    swarmConnectCmd,
 		"disconnect": swarmDisconnectCmd,
 		"filters":    swarmFiltersCmd,
+		"nat":        swarmNATCmd,
+		"peering":    swarmPeeringCmd,
 		"peers":      swarmPeersCmd,
 	},
 }
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+var swarmNATCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the reachability of the node.",
+		ShortDescription: `
+'ipfs swarm nat' shows whether the node is publicly reachable, and the state
+of the NAT traversal keeping it dialable otherwise: the relays it announces
+addresses through, with "Swarm.EnableAutoRelay", and the hole punches upgrading
+relayed connections to direct ones, with "Swarm.EnableHolePunching". When the
+node may not be dialable, the reasons are listed.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		st, err := n.NATStatus()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(st)
+	},
+	Type: core.NATStatus{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			st, ok := v.(*core.NATStatus)
+			if !ok {
+				return nil, e.TypeErr(st, v)
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Reachability: %s\n", st.Reachability)
+			fmt.Fprintln(buf, "Addresses:")
+			for _, a := range st.Addrs {
+				fmt.Fprintf(buf, "\t%s\n", a)
+			}
+
+			if st.AutoRelay {
+				fmt.Fprintf(buf, "AutoRelay: enabled, %d relays\n", len(st.Relays))
+				for _, p := range st.Relays {
+					fmt.Fprintf(buf, "\t%s\n", p)
+				}
+			} else {
+				fmt.Fprintln(buf, "AutoRelay: disabled")
+			}
+
+			if st.HolePunching {
+				hp := st.HolePunches
+				fmt.Fprintf(buf, "Hole punching: enabled, %d of %d succeeded\n", hp.Successes, hp.Attempts)
+				if hp.LastError != "" {
+					fmt.Fprintf(buf, "\tlast error: %s\n", hp.LastError)
+				}
+			} else {
+				fmt.Fprintln(buf, "Hole punching: disabled")
+			}
+
+			for _, r := range st.Reasons {
+				fmt.Fprintf(buf, "Not dialable: %s\n", r)
+			}
+			return buf, nil
+		},
+	},
+}
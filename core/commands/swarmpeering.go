@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+)
+
+var swarmPeeringCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Modify the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering' manages the peers this node stays connected to. Their
+connections are reconnected when they drop, and aren't closed by the
+connection manager.
+
+The peers default to those in the "Peering.Peers" config key. Changes made
+with these commands are not persisted, and are replaced when the config is
+reloaded.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": swarmPeeringAddCmd,
+		"ls":  swarmPeeringLsCmd,
+		"rm":  swarmPeeringRmCmd,
+	},
+}
+
+var swarmPeeringAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add peers to the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering add' keeps the node connected to the peers at the given
+addresses. Adding a peer again replaces its addresses. The address format is
+an IPFS multiaddr:
+
+ipfs swarm peering add /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, true, "Address of the peer to stay connected to.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Peering == nil {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		pis, err := peersWithAddresses(req.Arguments())
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		// the addresses of a peer given separately are added together
+		merged := make(map[peer.ID]*pstore.PeerInfo)
+		var order []peer.ID
+		for _, pi := range pis {
+			if m, ok := merged[pi.ID]; ok {
+				m.Addrs = append(m.Addrs, pi.Addrs...)
+				continue
+			}
+			pi := pi
+			merged[pi.ID] = &pi
+			order = append(order, pi.ID)
+		}
+
+		output := make([]string, 0, len(order))
+		for _, id := range order {
+			if err := n.Peering.AddPeer(*merged[id]); err != nil {
+				res.SetError(fmt.Errorf("add %s failure: %s", id.Pretty(), err), cmdkit.ErrNormal)
+				return
+			}
+			output = append(output, "add "+id.Pretty()+" success")
+		}
+
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmPeeringLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the peers of the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering ls' lists the addresses of the peers this node stays
+connected to.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Peering == nil {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		output := []string{}
+		for _, pi := range n.Peering.ListPeers() {
+			suffix := "/ipfs/" + pi.ID.Pretty()
+			if len(pi.Addrs) == 0 {
+				output = append(output, suffix)
+			}
+			for _, a := range pi.Addrs {
+				output = append(output, a.String()+suffix)
+			}
+		}
+		sort.Strings(output)
+
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmPeeringRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove peers from the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering rm' stops keeping the node connected to the given peers.
+Their connections are left open, for the connection manager to close.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer-id", true, true, "ID of the peer to remove.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Peering == nil {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		output := make([]string, 0, len(req.Arguments()))
+		for _, arg := range req.Arguments() {
+			id, err := peer.IDB58Decode(arg)
+			if err != nil {
+				res.SetError(cmds.ClientError("invalid peer ID: "+err.Error()), cmdkit.ErrClient)
+				return
+			}
+			n.Peering.RemovePeer(id)
+			output = append(output, "rm "+id.Pretty()+" success")
+		}
+
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
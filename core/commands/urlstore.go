@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	filestore "github.com/ipfs/go-ipfs/filestore"
+	balanced "github.com/ipfs/go-ipfs/importer/balanced"
+	ihelper "github.com/ipfs/go-ipfs/importer/helpers"
+	trickle "github.com/ipfs/go-ipfs/importer/trickle"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var urlStoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Interact with urlstore.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": urlAdd,
+	},
+}
+
+// UrlstoreAddOutput is the output of 'ipfs urlstore add'.
+type UrlstoreAddOutput struct {
+	Key  string
+	Size uint64
+}
+
+var urlAdd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add URL via urlstore.",
+		LongDescription: `
+Add URLs to ipfs without storing the data locally.
+
+The URL provided must be stable and ideally on a web server under your
+control, which supports range requests.
+
+The file is added using raw-leaves but otherwise using the default
+settings for 'ipfs add'. The blocks are fetched from the URL, and
+verified, every time they are requested; 'ipfs filestore verify'
+and 'ipfs filestore clean' check and remove the references to URLs
+whose content changed.
+
+This command is considered temporary until a better solution can be
+found. It may disappear or the semantics can change at any time.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("trickle", "t", "Use trickle-dag format for dag generation."),
+		cmdkit.BoolOption("pin", "Pin this object when adding.").WithDefault(true),
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("url", true, false, "URL to add to IPFS"),
+	},
+	Type: UrlstoreAddOutput{},
+
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		url := req.Arguments[0]
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !filestore.IsURL(url) {
+			res.SetError(fmt.Errorf("unsupported url syntax: %s", url), cmdkit.ErrNormal)
+			return
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !cfg.Experimental.UrlstoreEnabled {
+			res.SetError(fmt.Errorf("urlstore not enabled"), cmdkit.ErrNormal)
+			return
+		}
+
+		useTrickledag, _ := req.Options["trickle"].(bool)
+		dopin, _ := req.Options["pin"].(bool)
+
+		hreq, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		hres, err := http.DefaultClient.Do(hreq.WithContext(req.Context))
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer hres.Body.Close()
+
+		if hres.StatusCode != http.StatusOK {
+			res.SetError(fmt.Errorf("expected code 200, got: %d", hres.StatusCode), cmdkit.ErrNormal)
+			return
+		}
+
+		if dopin {
+			defer n.Blockstore.PinLock().Unlock()
+		}
+
+		chk := chunker.NewSizeSplitter(hres.Body, chunker.DefaultBlockSize)
+		dbp := &ihelper.DagBuilderParams{
+			Dagserv:   n.DAG,
+			RawLeaves: true,
+			Maxlinks:  ihelper.DefaultLinksPerBlock,
+			NoCopy:    true,
+			URL:       url,
+		}
+
+		layout := balanced.Layout
+		if useTrickledag {
+			layout = trickle.Layout
+		}
+		root, err := layout(dbp.New(chk))
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		size, err := root.Size()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if dopin {
+			n.Pinning.PinWithMode(root.Cid(), pin.Recursive)
+			if err := n.Pinning.Flush(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		res.Emit(&UrlstoreAddOutput{
+			Key:  root.Cid().String(),
+			Size: size,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*UrlstoreAddOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			_, err := fmt.Fprintln(w, out.Key)
+			return err
+		}),
+	},
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	ifconnmgr "github.com/libp2p/go-libp2p-interface-connmgr"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// reloadableConnMgr is the connection manager of the host, delegating to the
+// one configured by Swarm.ConnMgr, so that it can be replaced when the config
+// changes.
+type reloadableConnMgr struct {
+	lk sync.RWMutex
+	cm ifconnmgr.ConnManager
+}
+
+func newReloadableConnMgr(cm ifconnmgr.ConnManager) *reloadableConnMgr {
+	m := new(reloadableConnMgr)
+	m.set(cm)
+	return m
+}
+
+func (m *reloadableConnMgr) set(cm ifconnmgr.ConnManager) {
+	if cm == nil {
+		cm = &ifconnmgr.NullConnMgr{}
+	}
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.cm = cm
+}
+
+func (m *reloadableConnMgr) get() ifconnmgr.ConnManager {
+	m.lk.RLock()
+	defer m.lk.RUnlock()
+	return m.cm
+}
+
+// replace makes cm manage the connections of network, with the tags of the
+// peers set so far.
+func (m *reloadableConnMgr) replace(cm ifconnmgr.ConnManager, network inet.Network) {
+	old := m.get()
+	m.set(cm)
+	cm = m.get()
+
+	nf := cm.Notifee()
+	for _, c := range network.Conns() {
+		nf.Connected(network, c)
+	}
+	for _, p := range network.Peers() {
+		info := old.GetTagInfo(p)
+		if info == nil {
+			continue
+		}
+		for tag, v := range info.Tags {
+			cm.TagPeer(p, tag, v)
+		}
+	}
+}
+
+func (m *reloadableConnMgr) TagPeer(p peer.ID, tag string, v int) {
+	m.get().TagPeer(p, tag, v)
+}
+
+func (m *reloadableConnMgr) UntagPeer(p peer.ID, tag string) {
+	m.get().UntagPeer(p, tag)
+}
+
+func (m *reloadableConnMgr) GetTagInfo(p peer.ID) *ifconnmgr.TagInfo {
+	return m.get().GetTagInfo(p)
+}
+
+func (m *reloadableConnMgr) TrimOpenConns(ctx context.Context) {
+	m.get().TrimOpenConns(ctx)
+}
+
+func (m *reloadableConnMgr) Notifee() inet.Notifiee {
+	return (*connMgrNotifee)(m)
+}
+
+// connMgrNotifee passes the notifications of the network to the current
+// connection manager.
+type connMgrNotifee reloadableConnMgr
+
+func (nn *connMgrNotifee) notifee() inet.Notifiee {
+	return (*reloadableConnMgr)(nn).get().Notifee()
+}
+
+func (nn *connMgrNotifee) Listen(n inet.Network, a ma.Multiaddr) {
+	nn.notifee().Listen(n, a)
+}
+
+func (nn *connMgrNotifee) ListenClose(n inet.Network, a ma.Multiaddr) {
+	nn.notifee().ListenClose(n, a)
+}
+
+func (nn *connMgrNotifee) Connected(n inet.Network, c inet.Conn) {
+	nn.notifee().Connected(n, c)
+}
+
+func (nn *connMgrNotifee) Disconnected(n inet.Network, c inet.Conn) {
+	nn.notifee().Disconnected(n, c)
+}
+
+func (nn *connMgrNotifee) OpenedStream(n inet.Network, s inet.Stream) {
+	nn.notifee().OpenedStream(n, s)
+}
+
+func (nn *connMgrNotifee) ClosedStream(n inet.Network, s inet.Stream) {
+	nn.notifee().ClosedStream(n, s)
+}
@@ -19,26 +19,45 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	autorelay "github.com/ipfs/go-ipfs/autorelay"
+	bsutil "github.com/ipfs/go-ipfs/blocks/blockstoreutil"
+	bloomcache "github.com/ipfs/go-ipfs/blocks/bloomcache"
+	quota "github.com/ipfs/go-ipfs/blocks/quota"
+	scrubber "github.com/ipfs/go-ipfs/blocks/scrubber"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	events "github.com/ipfs/go-ipfs/core/events"
 	exchange "github.com/ipfs/go-ipfs/exchange"
-	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
-	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
+	delegated "github.com/ipfs/go-ipfs/exchange/delegated"
+	provided "github.com/ipfs/go-ipfs/exchange/provided"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+	tracker "github.com/ipfs/go-ipfs/exchange/tracker"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
+	holepunch "github.com/ipfs/go-ipfs/holepunch"
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ipnsrp "github.com/ipfs/go-ipfs/namesys/republisher"
 	p2p "github.com/ipfs/go-ipfs/p2p"
 	"github.com/ipfs/go-ipfs/path/resolver"
+	peering "github.com/ipfs/go-ipfs/peering"
 	pin "github.com/ipfs/go-ipfs/pin"
+	evict "github.com/ipfs/go-ipfs/pin/evict"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	replicate "github.com/ipfs/go-ipfs/pin/replicate"
+	pubsub "github.com/ipfs/go-ipfs/pubsub"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
+	resource "github.com/ipfs/go-ipfs/resource"
 	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	mimeindex "github.com/ipfs/go-ipfs/unixfs/mimeindex"
 
+	humanize "github.com/dustin/go-humanize"
 	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
@@ -48,6 +67,7 @@ import (
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
 	goprocess "github.com/jbenet/goprocess"
+	goprocessctx "github.com/jbenet/goprocess/context"
 	addrutil "github.com/libp2p/go-addr-util"
 	floodsub "github.com/libp2p/go-floodsub"
 	circuit "github.com/libp2p/go-libp2p-circuit"
@@ -56,11 +76,11 @@ import (
 	p2phost "github.com/libp2p/go-libp2p-host"
 	ifconnmgr "github.com/libp2p/go-libp2p-interface-connmgr"
 	ipnet "github.com/libp2p/go-libp2p-interface-pnet"
-	dht "github.com/libp2p/go-libp2p-kad-dht"
 	metrics "github.com/libp2p/go-libp2p-metrics"
 	peer "github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
 	pnet "github.com/libp2p/go-libp2p-pnet"
+	protocol "github.com/libp2p/go-libp2p-protocol"
 	routing "github.com/libp2p/go-libp2p-routing"
 	swarm "github.com/libp2p/go-libp2p-swarm"
 	discovery "github.com/libp2p/go-libp2p/p2p/discovery"
@@ -80,11 +100,17 @@ import (
 const IpnsValidatorTag = "ipns"
 
 const kReprovideFrequency = time.Hour * 12
+const kScrubInterval = time.Hour * 24
+const kScrubRate = "1MB"
 const discoveryConnTimeout = time.Second * 30
 
 var log = logging.Logger("core")
 
-type mode int
+// ErrReadOnly is returned when changing the blocks or pins of a node whose
+// repo is read-only.
+var ErrReadOnly = errors.New("the repo is read-only")
+
+type mode int32
 
 const (
 	// zero value is not a valid mode, must be explicitly set
@@ -106,23 +132,35 @@ type IpfsNode struct {
 	Repo repo.Repo
 
 	// Local node
-	Pinning         pin.Pinner // the pinning manager
-	Mounts          Mounts     // current mount state, if any.
-	PrivateKey      ic.PrivKey // the local node's private Key
-	PNetFingerprint []byte     // fingerprint of private network
+	Pinning         pin.Pinner            // the pinning manager
+	RemotePins      *pin.RemoteManager    // pins delegated to remote pinning services
+	PartialPins     *pin.PartialPins      // best-effort pins of partly available DAGs
+	Replication     *replicate.Replicator // pins replicated with trusted peers, if enabled
+	Mounts          Mounts                // current mount state, if any.
+	PrivateKey      ic.PrivKey            // the local node's private Key
+	PNetFingerprint []byte                // fingerprint of private network
 
 	// Services
-	Peerstore  pstore.Peerstore     // storage for other Peer instances
-	Blockstore bstore.GCBlockstore  // the block store (lower level)
-	Filestore  *filestore.Filestore // the filestore blockstore
-	BaseBlocks bstore.Blockstore    // the raw blockstore, no filestore wrapping
-	GCLocker   bstore.GCLocker      // the locker used to protect the blockstore during gc
-	Blocks     bserv.BlockService   // the block service, get/add blocks.
-	DAG        ipld.DAGService      // the merkle dag service, get/add objects.
-	Resolver   *resolver.Resolver   // the path resolution system
+	Peerstore  pstore.Peerstore       // storage for other Peer instances
+	Blockstore bstore.GCBlockstore    // the block store (lower level)
+	Filestore  *filestore.Filestore   // the filestore blockstore
+	BaseBlocks bstore.Blockstore      // the raw blockstore, no filestore wrapping
+	GCLocker   bstore.GCLocker        // the locker used to protect the blockstore during gc
+	GCJournal  *gc.Journal            // the blocks written during a concurrent gc
+	GCEvents   *gc.Bus                // the progress of garbage collections
+	Events     *events.Bus            // the events of the subsystems of the node
+	BloomCache *bloomcache.Blockstore // the persisted bloom filter, if enabled
+	Quota      *quota.Quota           // the storage quotas, if configured
+	Evictor    *evict.Evictor         // the LRU block evictor, if enabled
+	Blocks     bserv.BlockService     // the block service, get/add blocks.
+	DAG        ipld.DAGService        // the merkle dag service, get/add objects.
+	Validators *merkledag.Validators  // the shape validators of the nodes of DAG
+	Resolver   *resolver.Resolver     // the path resolution system
+	FetchAhead uio.FetchAheadLimits   // the fetch-ahead limits of the file readers
 	Reporter   metrics.Reporter
 	Discovery  discovery.Service
 	FilesRoot  *mfs.Root
+	MimeIndex  *mimeindex.Index // the content types detected for files, if enabled
 
 	// Online
 	PeerHost     p2phost.Host        // the network host (server+client)
@@ -131,17 +169,61 @@ type IpfsNode struct {
 	Exchange     exchange.Interface  // the block exchange + strategy (bitswap)
 	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
 	Ping         *ping.PingService
-	Reprovider   *rp.Reprovider // the value reprovider system
+	Reprovider   *rp.Reprovider     // the value reprovider system
+	Provided     *provided.Provided // the records of the content announced
+	Scrubber     *scrubber.Scrubber // the blockstore scrubber, if enabled
 	IpnsRepub    *ipnsrp.Republisher
 
 	Floodsub *floodsub.PubSub
+	PubSub   *pubsub.PubSub // messages over Floodsub, signed on some topics
 	P2P      *p2p.P2P
+	Peering  *peering.Service // the peers kept connected
+
+	AutoRelay *autorelay.AutoRelay // the relays of the node while unreachable, if enabled
+	HolePunch *holepunch.Service   // the upgrade of relayed connections, if enabled
+
+	Resources *resource.Manager // the accounting and limits of connections and streams
 
 	proc goprocess.Process
 	ctx  context.Context
 
 	mode         mode
 	localModeSet bool
+	readOnly     bool // see Datastore.ReadOnly in the config
+
+	// provideRouting is where provide announcements go. It is Routing,
+	// possibly extended with delegated providers, recorded by Provided.
+	provideRouting routing.ContentRouting
+
+	// exchangeOption and namesysOption construct the exchange and the name
+	// system when the node goes online, or sets up offline routing.
+	exchangeOption ExchangeOption
+	namesysOption  NamesysOption
+
+	// onlineLk serializes switching modes with SetOnline. startOnline
+	// brings up the online services under a context, which stopOnline
+	// cancels.
+	onlineLk    sync.Mutex
+	startOnline func(ctx context.Context) error
+	stopOnline  context.CancelFunc
+
+	// servicesLk guards the fields SetOnline swaps, such as PeerHost,
+	// Routing, Namesys, Provided and Exchange: SetOnline holds it for
+	// writing while it switches, see LockServices.
+	servicesLk sync.RWMutex
+
+	connMgr *reloadableConnMgr
+
+	// reloadLk guards the appliers of config sections, and the config they
+	// last applied, as a map.
+	reloadLk      sync.Mutex
+	appliers      map[string]ConfigApplier
+	appliedConfig map[string]interface{}
+
+	tenantsLk sync.RWMutex
+	tenants   map[string]*IpfsNode
+	// tenantOf is the node whose services a tenant shares.
+	tenantOf *IpfsNode
 }
 
 // Mounts defines what the node's mount state is. This should
@@ -150,6 +232,7 @@ type IpfsNode struct {
 type Mounts struct {
 	Ipfs mount.Mount
 	Ipns mount.Mount
+	Mfs  mount.Mount
 }
 
 func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption, pubsub, ipnsps, mplex bool) error {
@@ -211,6 +294,9 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 				case <-n.Process().Closing():
 					t.Stop()
 					return
+				case <-ctx.Done():
+					t.Stop()
+					return
 				}
 			}
 		}()
@@ -221,17 +307,27 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		return err
 	}
 
+	if cfg.Swarm.EnableAutoRelay {
+		if cfg.Swarm.DisableRelay {
+			return errors.New("the relay transport, required by Swarm.EnableAutoRelay, is disabled by Swarm.DisableRelay")
+		}
+		n.AutoRelay = autorelay.New(hasPublicAddr)
+		addrsFactory = composeAddrsFactory(n.AutoRelay.Addrs, addrsFactory)
+	}
+
 	connmgr, err := constructConnMgr(cfg.Swarm.ConnMgr)
 	if err != nil {
 		return err
 	}
+	// replaced when Swarm.ConnMgr changes
+	n.connMgr = newReloadableConnMgr(connmgr)
 
 	hostopts := &ConstructPeerHostOpts{
 		AddrsFactory:      addrsFactory,
 		DisableNatPortMap: cfg.Swarm.DisableNatPortMap,
 		DisableRelay:      cfg.Swarm.DisableRelay,
 		EnableRelayHop:    cfg.Swarm.EnableRelayHop,
-		ConnectionManager: connmgr,
+		ConnectionManager: n.connMgr,
 	}
 	peerhost, err := hostOption(ctx, n.Identity, n.Peerstore, n.Reporter,
 		addrfilter, tpt, protec, hostopts)
@@ -240,6 +336,15 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		return err
 	}
 
+	limits, err := resourceLimits(cfg.Swarm.ResourceMgr)
+	if err != nil {
+		return err
+	}
+	// wrapped before any service sets its stream handlers, so that all
+	// their streams are accounted for
+	n.Resources = resource.NewManager(limits)
+	peerhost = resource.Wrap(peerhost, n.Resources)
+
 	if err := n.startOnlineServicesWithHost(ctx, peerhost, routingOption); err != nil {
 		return err
 	}
@@ -249,21 +354,50 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		return err
 	}
 
-	if pubsub || ipnsps {
+	n.Peering = peering.NewService(n.PeerHost)
+	if err := n.setPeeringPeers(cfg.Peering); err != nil {
+		return err
+	}
+	if err := n.Peering.Start(); err != nil {
+		return err
+	}
+
+	if n.AutoRelay != nil {
+		n.AutoRelay.Start(n.PeerHost, n.Routing)
+	}
+	if cfg.Swarm.EnableRelayHop && !cfg.Swarm.DisableRelay && n.Routing != nil {
+		go autorelay.Advertise(ctx, n.Routing)
+	}
+	if cfg.Swarm.EnableHolePunching {
+		n.HolePunch = holepunch.New(n.PeerHost)
+		n.HolePunch.Start()
+	}
+
+	replication := cfg.Pinning.Replication.Enabled && !cfg.Datastore.ReadOnly
+	if pubsub || ipnsps || replication {
 		service, err := floodsub.NewFloodSub(ctx, peerhost)
 		if err != nil {
 			return err
 		}
 		n.Floodsub = service
+		if err := n.setupPubSub(cfg.Pubsub); err != nil {
+			return err
+		}
 	}
 
-	if ipnsps {
+	if _, ok := n.Namesys.(nilNamesys); ipnsps && !ok {
 		err = namesys.AddPubsubNameSystem(ctx, n.Namesys, n.PeerHost, n.Routing, n.Repo.Datastore(), n.Floodsub)
 		if err != nil {
 			return err
 		}
 	}
 
+	if replication {
+		if err := n.startReplication(ctx, cfg.Pinning.Replication); err != nil {
+			return err
+		}
+	}
+
 	n.P2P = p2p.NewP2P(n.Identity, n.PeerHost, n.Peerstore)
 
 	// setup local discovery
@@ -299,27 +433,93 @@ func constructConnMgr(cfg config.ConnMgr) (ifconnmgr.ConnManager, error) {
 	}
 }
 
+// resourceLimits returns the limits of the resource manager configured by cfg.
+func resourceLimits(cfg config.ResourceMgr) (resource.Limits, error) {
+	limit := func(name string, l config.ResourceLimit) (resource.Limit, error) {
+		out := resource.Limit{Conns: l.Conns, Streams: l.Streams}
+		if l.Memory != "" {
+			mem, err := humanize.ParseBytes(l.Memory)
+			if err != nil {
+				return out, fmt.Errorf("parsing Swarm.ResourceMgr.%s.Memory: %s", name, err)
+			}
+			out.Memory = int64(mem)
+		}
+		return out, nil
+	}
+
+	var limits resource.Limits
+	var err error
+	if limits.System, err = limit("System", cfg.System); err != nil {
+		return limits, err
+	}
+	if limits.Peer, err = limit("Peer", cfg.Peer); err != nil {
+		return limits, err
+	}
+	limits.Subsystems = make(map[string]resource.Limit, len(cfg.Subsystems))
+	for name, l := range cfg.Subsystems {
+		if limits.Subsystems[name], err = limit("Subsystems."+name, l); err != nil {
+			return limits, err
+		}
+	}
+	limits.Protocols = make(map[protocol.ID]resource.Limit, len(cfg.Protocols))
+	for pid, l := range cfg.Protocols {
+		if limits.Protocols[protocol.ID(pid)], err = limit("Protocols."+pid, l); err != nil {
+			return limits, err
+		}
+	}
+	return limits, nil
+}
+
+// setPeeringPeers makes the peering service keep the node connected to the
+// peers of cfg only.
+func (n *IpfsNode) setPeeringPeers(cfg config.Peering) error {
+	parsed, err := config.ParseBootstrapPeers(cfg.Peers)
+	if err != nil {
+		return err
+	}
+	peers := toPeerInfos(parsed)
+
+	keep := make(map[peer.ID]struct{}, len(peers))
+	for _, pi := range peers {
+		if err := n.Peering.AddPeer(pi); err != nil {
+			return err
+		}
+		keep[pi.ID] = struct{}{}
+	}
+	for _, pi := range n.Peering.ListPeers() {
+		if _, ok := keep[pi.ID]; !ok {
+			n.Peering.RemovePeer(pi.ID)
+		}
+	}
+	return nil
+}
+
+// setupPubSub layers signed messages over the floodsub of the node, for every
+// topic unless signing is disabled, and then for the topics configured to be
+// signed.
+func (n *IpfsNode) setupPubSub(cfg config.Pubsub) error {
+	ps, err := pubsub.New(n.Floodsub, n.PrivateKey, n.Repo.Datastore(), cfg.PersistMessages, !cfg.DisableSigning)
+	if err != nil {
+		return err
+	}
+	for _, topic := range cfg.SignedTopics {
+		ps.SignTopic(topic)
+	}
+	n.PubSub = ps
+	return nil
+}
+
 func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 	cfg, err := n.Repo.Config()
 	if err != nil {
 		return err
 	}
 
-	var keyProvider rp.KeyChanFunc
-
-	switch cfg.Reprovider.Strategy {
-	case "all":
-		fallthrough
-	case "":
-		keyProvider = rp.NewBlockstoreProvider(n.Blockstore)
-	case "roots":
-		keyProvider = rp.NewPinnedProvider(n.Pinning, n.DAG, true)
-	case "pinned":
-		keyProvider = rp.NewPinnedProvider(n.Pinning, n.DAG, false)
-	default:
-		return fmt.Errorf("unknown reprovider strategy '%s'", cfg.Reprovider.Strategy)
+	strategy, err := n.reprovideStrategy(cfg.Reprovider.Strategy)
+	if err != nil {
+		return err
 	}
-	n.Reprovider = rp.NewReprovider(ctx, n.Routing, keyProvider)
+	n.Reprovider = rp.NewReprovider(ctx, n.provideRouting, strategy.Keys)
 
 	reproviderInterval := kReprovideFrequency
 	if cfg.Reprovider.Interval != "" {
@@ -333,6 +533,54 @@ func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 
 	go n.Reprovider.Run(reproviderInterval)
 
+	if cfg.Datastore.Scrub.Enabled && !cfg.Datastore.ReadOnly {
+		if err := n.startScrubber(ctx, cfg.Datastore.Scrub); err != nil {
+			return err
+		}
+	}
+
+	if !cfg.Datastore.ReadOnly {
+		go n.PartialPins.Run(ctx, pin.DefaultPartialRetryInterval)
+	}
+
+	return nil
+}
+
+// reprovideStrategy returns the reprovider strategy called name, over the
+// storage of the node.
+func (n *IpfsNode) reprovideStrategy(name string) (rp.Strategy, error) {
+	return rp.NewStrategy(name, rp.Env{
+		Blockstore: n.Blockstore,
+		Pinning:    n.Pinning,
+		DAG:        n.DAG,
+		MFSRoot:    n.filesRootCid,
+	})
+}
+
+func (n *IpfsNode) startScrubber(ctx context.Context, scfg config.Scrub) error {
+	opts := scrubber.Options{
+		Interval: kScrubInterval,
+		Repair:   scfg.Repair,
+	}
+	if scfg.Interval != "" {
+		dur, err := time.ParseDuration(scfg.Interval)
+		if err != nil {
+			return fmt.Errorf("parsing Datastore.Scrub.Interval: %s", err)
+		}
+		opts.Interval = dur
+	}
+	rate := scfg.Rate
+	if rate == "" {
+		rate = kScrubRate
+	}
+	r, err := humanize.ParseBytes(rate)
+	if err != nil {
+		return fmt.Errorf("parsing Datastore.Scrub.Rate: %s", err)
+	}
+	opts.Rate = r
+
+	n.Scrubber = scrubber.New(n.BaseBlocks, n.Repo.Datastore(), n.Exchange, opts)
+	go n.Scrubber.Run(ctx)
 	return nil
 }
 
@@ -441,31 +689,84 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	// setup diagnostics service
 	n.Ping = ping.NewPingService(host)
 
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
 	// setup routing service
-	r, err := routingOption(ctx, host, n.Repo.Datastore())
+	dstore, err := limitRecords(n.Repo.Datastore(), cfg.Routing.MaxRecords, host.ID())
 	if err != nil {
 		return err
 	}
+	r, err := routingOption(ctx, host, dstore)
+	if err != nil {
+		return err
+	}
+	if d, ok := r.(*dhtRouting); ok {
+		d.limitQueries(cfg.Routing.MaxConcurrentQueries)
+	}
 	n.Routing = r
 
+	n.provideRouting = n.Routing
+	if len(cfg.Provider.Delegates) > 0 {
+		var delegates []*delegated.Provider
+		for _, ep := range cfg.Provider.Delegates {
+			d := delegated.New(ep, n.Identity, host.Addrs)
+			go d.Run(ctx)
+			delegates = append(delegates, d)
+		}
+		n.provideRouting = delegated.Wrap(n.Routing, cfg.Provider.DelegateOnly, delegates...)
+	}
+	if trackers := tracker.Registered(); len(trackers) > 0 {
+		self := func() pstore.PeerInfo {
+			return pstore.PeerInfo{ID: host.ID(), Addrs: host.Addrs()}
+		}
+		n.provideRouting = tracker.Wrap(n.provideRouting, self, trackers...)
+	}
+	n.Provided = provided.Wrap(n.provideRouting, n.Repo.Datastore())
+	go n.Provided.Run(ctx)
+	n.provideRouting = n.Provided
+
 	// Wrap standard peer host with routing system to allow unknown peer lookups
 	n.PeerHost = rhost.Wrap(host, n.Routing)
+	n.PeerHost.Network().Notify(n.Events.Notifiee())
 
 	// setup exchange service
-	const alwaysSendToPeer = true // use YesManStrategy
-	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
-	n.Exchange = bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer)
-
-	size, err := n.getCacheSize()
+	exchangeOption := n.exchangeOption
+	if exchangeOption == nil {
+		exchangeOption = BitswapOption
+	}
+	n.Exchange, err = exchangeOption(ctx, n.Identity, n.PeerHost, n.provideRouting, n.networkBlockstore())
 	if err != nil {
 		return err
 	}
 
 	// setup name system
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	if err := n.setupNamesys(ctx); err != nil {
+		return err
+	}
+	if _, ok := n.Namesys.(nilNamesys); ok {
+		return nil
+	}
 
 	// setup ipns republishing
-	return n.setupIpnsRepublisher()
+	return n.setupIpnsRepublisher(ctx)
+}
+
+// setupNamesys constructs the name system of the node over its routing.
+func (n *IpfsNode) setupNamesys(ctx context.Context) error {
+	size, err := n.getCacheSize()
+	if err != nil {
+		return err
+	}
+
+	namesysOption := n.namesysOption
+	if namesysOption == nil {
+		namesysOption = DefaultNamesysOption
+	}
+	n.Namesys, err = namesysOption(ctx, n.Routing, n.Repo.Datastore(), size)
+	return err
 }
 
 // getCacheSize returns cache life and cache size
@@ -485,7 +786,7 @@ func (n *IpfsNode) getCacheSize() (int, error) {
 	return cs, nil
 }
 
-func (n *IpfsNode) setupIpnsRepublisher() error {
+func (n *IpfsNode) setupIpnsRepublisher(ctx context.Context) error {
 	cfg, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -515,7 +816,8 @@ func (n *IpfsNode) setupIpnsRepublisher() error {
 		n.IpnsRepub.RecordLifetime = d
 	}
 
-	n.Process().Go(n.IpnsRepub.Run)
+	// stop republishing when the node goes offline
+	goprocessctx.CloseAfterContext(n.Process().Go(n.IpnsRepub.Run), ctx)
 
 	return nil
 }
@@ -538,6 +840,36 @@ func (n *IpfsNode) Context() context.Context {
 	return n.ctx
 }
 
+// TxnGuard returns the guard of the block transactions of n: rolling back
+// one keeps the blocks pinned meanwhile.
+func (n *IpfsNode) TxnGuard() bserv.TxnGuard {
+	return bserv.TxnGuard{
+		Locker: n.Blockstore,
+		Pinned: func(cids []*cid.Cid) (*cid.Set, error) {
+			pinned, err := n.Pinning.CheckIfPinned(cids...)
+			if err != nil {
+				return nil, err
+			}
+			set := cid.NewSet()
+			for _, p := range pinned {
+				if p.Pinned() {
+					set.Add(p.Key)
+				}
+			}
+			return set, nil
+		},
+	}
+}
+
+// networkBlockstore returns the blockstore blocks fetched from other peers
+// are stored in, so that they count against the network quota.
+func (n *IpfsNode) networkBlockstore() bstore.GCBlockstore {
+	if n.Quota != nil {
+		return n.Quota.Blockstore(quota.Network)
+	}
+	return n.Blockstore
+}
+
 // teardown closes owned children. If any errors occur, this function returns
 // the first error.
 func (n *IpfsNode) teardown() error {
@@ -546,6 +878,16 @@ func (n *IpfsNode) teardown() error {
 	// regardless of which constructor was used to add them to the node.
 	var closers []io.Closer
 
+	if n.Peering != nil {
+		n.Peering.Stop()
+	}
+	if n.AutoRelay != nil {
+		n.AutoRelay.Stop()
+	}
+	if n.HolePunch != nil {
+		n.HolePunch.Stop()
+	}
+
 	// NOTE: The order that objects are added(closed) matters, if an object
 	// needs to use another during its shutdown/cleanup process, it should be
 	// closed before that other object
@@ -554,6 +896,12 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.FilesRoot)
 	}
 
+	n.tenantsLk.RLock()
+	for _, t := range n.tenants {
+		closers = append(closers, t.FilesRoot)
+	}
+	n.tenantsLk.RUnlock()
+
 	if n.Exchange != nil {
 		closers = append(closers, n.Exchange)
 	}
@@ -564,8 +912,11 @@ func (n *IpfsNode) teardown() error {
 	if n.Mounts.Ipns != nil && !n.Mounts.Ipns.IsActive() {
 		closers = append(closers, mount.Closer(n.Mounts.Ipns))
 	}
+	if n.Mounts.Mfs != nil && !n.Mounts.Mfs.IsActive() {
+		closers = append(closers, mount.Closer(n.Mounts.Mfs))
+	}
 
-	if dht, ok := n.Routing.(*dht.IpfsDHT); ok {
+	if dht := n.DHT(); dht != nil {
 		closers = append(closers, dht.Process())
 	}
 
@@ -581,6 +932,16 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.PeerHost)
 	}
 
+	if n.BloomCache != nil {
+		closers = append(closers, n.BloomCache)
+	}
+
+	n.tenantsLk.RLock()
+	for _, t := range n.tenants {
+		closers = append(closers, t.Repo)
+	}
+	n.tenantsLk.RUnlock()
+
 	// Repo closed last, most things need to preserve state here
 	closers = append(closers, n.Repo)
 
@@ -596,9 +957,29 @@ func (n *IpfsNode) teardown() error {
 	return nil
 }
 
+func (n *IpfsNode) getMode() mode {
+	return mode(atomic.LoadInt32((*int32)(&n.mode)))
+}
+
+func (n *IpfsNode) setMode(m mode) {
+	atomic.StoreInt32((*int32)(&n.mode), int32(m))
+}
+
+// LockServices holds off switching modes with SetOnline until the returned
+// function is called, so that the online services of the node, such as
+// PeerHost, Routing and Exchange, don't change while they are read. The
+// services of tenants are guarded by the lock of their node.
+func (n *IpfsNode) LockServices() (unlock func()) {
+	if n.tenantOf != nil {
+		return n.tenantOf.LockServices()
+	}
+	n.servicesLk.RLock()
+	return n.servicesLk.RUnlock
+}
+
 // OnlineMode returns whether or not the IpfsNode is in OnlineMode.
 func (n *IpfsNode) OnlineMode() bool {
-	switch n.mode {
+	switch n.getMode() {
 	case onlineMode:
 		return true
 	default:
@@ -609,7 +990,7 @@ func (n *IpfsNode) OnlineMode() bool {
 // SetLocal will set the IpfsNode to local mode
 func (n *IpfsNode) SetLocal(isLocal bool) {
 	if isLocal {
-		n.mode = localMode
+		n.setMode(localMode)
 	}
 	n.localModeSet = true
 }
@@ -620,7 +1001,7 @@ func (n *IpfsNode) LocalMode() bool {
 		// programmer error should not happen
 		panic("local mode not set")
 	}
-	switch n.mode {
+	switch n.getMode() {
 	case localMode:
 		return true
 	default:
@@ -628,6 +1009,12 @@ func (n *IpfsNode) LocalMode() bool {
 	}
 }
 
+// ReadOnly returns whether the repo of the node is read-only: its blocks,
+// pins and files can't change.
+func (n *IpfsNode) ReadOnly() bool {
+	return n.readOnly
+}
+
 // Bootstrap will set and call the IpfsNodes bootstrap function.
 func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 
@@ -732,6 +1119,18 @@ func (n *IpfsNode) loadBootstrapPeers() ([]pstore.PeerInfo, error) {
 	return toPeerInfos(parsed), nil
 }
 
+// filesRootCid returns the CID of the current files API root.
+func (n *IpfsNode) filesRootCid() (*cid.Cid, error) {
+	if n.FilesRoot == nil {
+		return nil, errors.New("files root not loaded")
+	}
+	nd, err := n.FilesRoot.GetValue().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	return nd.Cid(), nil
+}
+
 func (n *IpfsNode) loadFilesRoot() error {
 	dsk := ds.NewKey("/local/filesroot")
 	pf := func(ctx context.Context, c *cid.Cid) error {
@@ -745,7 +1144,8 @@ func (n *IpfsNode) loadFilesRoot() error {
 	case err == ds.ErrNotFound || val == nil:
 		nd = ft.EmptyDirNode()
 		err := n.DAG.Add(n.Context(), nd)
-		if err != nil {
+		// a read-only repo gets an empty root which only lives in memory
+		if err != nil && err != bsutil.ErrReadOnly {
 			return fmt.Errorf("failure writing to dagstore: %s", err)
 		}
 	case err == nil:
@@ -774,6 +1174,20 @@ func (n *IpfsNode) loadFilesRoot() error {
 		return err
 	}
 
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	if cfg.Experimental.FilesJournal > 0 {
+		j := mfs.NewDatastoreJournal(n.Repo.Datastore(), ds.NewKey("/local/filesjournal"), cfg.Experimental.FilesJournal)
+		if err := mr.SetJournal(j); err != nil {
+			return err
+		}
+	}
+	if err := mr.SetQuotaStore(n.Repo.Datastore(), ds.NewKey("/local/filesquotas")); err != nil {
+		return err
+	}
+
 	n.FilesRoot = mr
 	return nil
 }
@@ -793,14 +1207,7 @@ func (n *IpfsNode) SetupOfflineRouting() error {
 
 	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
 
-	size, err := n.getCacheSize()
-	if err != nil {
-		return err
-	}
-
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
-
-	return nil
+	return n.setupNamesys(n.ctx)
 }
 
 func loadPrivateKey(cfg *config.Identity, id peer.ID) (ic.PrivKey, error) {
@@ -948,17 +1355,15 @@ func startListening(ctx context.Context, host p2phost.Host, cfg *config.Config)
 }
 
 func constructDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.Batching) (routing.IpfsRouting, error) {
-	dhtRouting := dht.NewDHT(ctx, host, dstore)
-	dhtRouting.Validator[IpnsValidatorTag] = namesys.NewIpnsRecordValidator(host.Peerstore())
-	dhtRouting.Selector[IpnsValidatorTag] = namesys.IpnsSelectorFunc
-	return dhtRouting, nil
+	return newDHTRouting(ctx, host, dstore, DHTModeServer)
 }
 
 func constructClientDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.Batching) (routing.IpfsRouting, error) {
-	dhtRouting := dht.NewDHTClient(ctx, host, dstore)
-	dhtRouting.Validator[IpnsValidatorTag] = namesys.NewIpnsRecordValidator(host.Peerstore())
-	dhtRouting.Selector[IpnsValidatorTag] = namesys.IpnsSelectorFunc
-	return dhtRouting, nil
+	return newDHTRouting(ctx, host, dstore, DHTModeClient)
+}
+
+func constructAutoDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.Batching) (routing.IpfsRouting, error) {
+	return newDHTRouting(ctx, host, dstore, DHTModeAuto)
 }
 
 type RoutingOption func(context.Context, p2phost.Host, ds.Batching) (routing.IpfsRouting, error)
@@ -967,4 +1372,5 @@ type DiscoveryOption func(context.Context, p2phost.Host) (discovery.Service, err
 
 var DHTOption RoutingOption = constructDHTRouting
 var DHTClientOption RoutingOption = constructClientDHTRouting
+var DHTAutoOption RoutingOption = constructAutoDHTRouting
 var NilRouterOption RoutingOption = nilrouting.ConstructNilRouting
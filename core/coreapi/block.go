@@ -28,6 +28,10 @@ type BlockStat struct {
 }
 
 func (api *BlockAPI) Put(ctx context.Context, src io.Reader, opts ...caopts.BlockPutOption) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	settings, err := caopts.BlockPutOptions(opts...)
 	if err != nil {
 		return nil, err
@@ -81,6 +85,10 @@ func (api *BlockAPI) Get(ctx context.Context, p coreiface.Path) (io.Reader, erro
 }
 
 func (api *BlockAPI) Rm(ctx context.Context, p coreiface.Path, opts ...caopts.BlockRmOption) error {
+	if err := api.checkWritable(); err != nil {
+		return err
+	}
+
 	settings, err := caopts.BlockRmOptions(opts...)
 	if err != nil {
 		return err
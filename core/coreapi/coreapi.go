@@ -24,9 +24,18 @@ func NewCoreAPI(n *core.IpfsNode) coreiface.CoreAPI {
 	return api
 }
 
+// checkWritable returns core.ErrReadOnly when the repo of the node is
+// read-only, before starting a write which would fail block by block.
+func (api *CoreAPI) checkWritable() error {
+	if api.node.ReadOnly() {
+		return core.ErrReadOnly
+	}
+	return nil
+}
+
 // Unixfs returns the UnixfsAPI interface backed by the go-ipfs node
 func (api *CoreAPI) Unixfs() coreiface.UnixfsAPI {
-	return (*UnixfsAPI)(api)
+	return &UnixfsAPI{api, nil}
 }
 
 func (api *CoreAPI) Block() coreiface.BlockAPI {
@@ -57,6 +66,11 @@ func (api *CoreAPI) Pin() coreiface.PinAPI {
 	return &PinAPI{api, nil}
 }
 
+// PubSub returns the PubSubAPI interface backed by the go-ipfs node
+func (api *CoreAPI) PubSub() coreiface.PubSubAPI {
+	return &PubSubAPI{api, nil}
+}
+
 // ResolveNode resolves the path `p` using Unixfx resolver, gets and returns the
 // resolved Node.
 func (api *CoreAPI) ResolveNode(ctx context.Context, p coreiface.Path) (coreiface.Node, error) {
@@ -23,6 +23,10 @@ type DagAPI struct {
 // `WithCodes` or `WithHash`, the defaults "dag-cbor" and "sha256" are used.
 // Returns the path of the inserted data.
 func (api *DagAPI) Put(ctx context.Context, src io.Reader, opts ...caopts.DagPutOption) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	settings, err := caopts.DagPutOptions(opts...)
 	if err != nil {
 		return nil, err
@@ -12,6 +12,7 @@ import (
 
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	peer "github.com/libp2p/go-libp2p-peer"
 )
 
 // Path is a generic wrapper for paths used in the API. A path can be resolved
@@ -106,6 +107,9 @@ type CoreAPI interface {
 	// ObjectAPI returns an implementation of Object API
 	Object() ObjectAPI
 
+	// PubSub returns an implementation of PubSub API
+	PubSub() PubSubAPI
+
 	// ResolvePath resolves the path using Unixfs resolver
 	ResolvePath(context.Context, Path) (Path, error)
 
@@ -117,7 +121,36 @@ type CoreAPI interface {
 // UnixfsAPI is the basic interface to immutable files in IPFS
 type UnixfsAPI interface {
 	// Add imports the data from the reader into merkledag file
-	Add(context.Context, io.Reader) (Path, error)
+	Add(context.Context, io.Reader, ...options.UnixfsAddOption) (Path, error)
+
+	// WithChunker is an option for Add which specifies the chunker splitting
+	// the data into blocks, like "size-262144", "rabin-[min]-[avg]-[max]" or
+	// "buzhash-[min]-[avg]-[max]". The same data added with the same chunker
+	// always gets the same cid. Default: "size-262144"
+	WithChunker(chunker string) options.UnixfsAddOption
+
+	// WithLayout is an option for Add which specifies the layout of the DAG
+	// built from the chunks, like "balanced", "trickle", or one registered
+	// by a plugin. The name of plugin layouts is recorded in the root of the
+	// DAG. Default: "balanced"
+	WithLayout(layout string) options.UnixfsAddOption
+
+	// WithProgress is an option for Add which calls progress with updates
+	// as the data is added: once started, every 256KiB hashed, and once all
+	// the blocks are written, with the number of blocks written and how many
+	// of them were already stored.
+	WithProgress(progress func(options.UnixfsAddProgress)) options.UnixfsAddOption
+
+	// Write writes the data from the reader over the file at the path, from
+	// the given offset, and returns the path of the modified file. The file
+	// grows if the data goes past its end. Only the blocks holding the
+	// range written are rewritten, the others are shared with the original
+	// file.
+	Write(ctx context.Context, p Path, offset int64, r io.Reader, opts ...options.UnixfsWriteOption) (Path, error)
+
+	// WithTruncate is an option for Write which truncates the file at the end
+	// of the data written. Default: false
+	WithTruncate(truncate bool) options.UnixfsWriteOption
 
 	// Cat returns a reader for the file
 	Cat(context.Context, Path) (Reader, error)
@@ -385,5 +418,59 @@ type PinAPI interface {
 	Verify(context.Context) (<-chan PinStatus, error)
 }
 
+// PubSubMessage is a message received on a pubsub topic
+type PubSubMessage interface {
+	// From returns the ID of the peer which published the message
+	From() peer.ID
+
+	// Data returns the message body
+	Data() []byte
+
+	// Seq returns the sequence number of the message, unique to its author
+	Seq() uint64
+
+	// Topic returns the topic the message was published on
+	Topic() string
+}
+
+// PubSubSubscription is an active subscription to a topic
+type PubSubSubscription interface {
+	io.Closer
+
+	// Next returns the next incoming message
+	Next(context.Context) (PubSubMessage, error)
+}
+
+// PubSubAPI specifies the interface to PubSub. Messages are signed by the
+// node publishing them, and the ones whose signature doesn't verify are
+// dropped, unless Pubsub.DisableSigning is set in the config: then only on
+// the topics of Pubsub.SignedTopics.
+type PubSubAPI interface {
+	// Ls lists subscribed topics by name
+	Ls(context.Context) ([]string, error)
+
+	// Peers list peers we are currently pubsubbing with
+	Peers(context.Context, ...options.PubSubPeersOption) ([]peer.ID, error)
+
+	// WithTopic is an option for Peers which specifies the topic to list the
+	// peers of. By default the peers of all topics are listed
+	WithTopic(topic string) options.PubSubPeersOption
+
+	// Publish a message to a given pubsub topic
+	Publish(ctx context.Context, topic string, data []byte) error
+
+	// Subscribe to messages on a given topic
+	Subscribe(ctx context.Context, topic string, opts ...options.PubSubSubscribeOption) (PubSubSubscription, error)
+
+	// WithDiscover is an option for Subscribe which specifies whether to try
+	// to discover other peers subscribed to the same topic. Default: false
+	WithDiscover(discover bool) options.PubSubSubscribeOption
+
+	// WithReplay is an option for Subscribe which specifies whether the
+	// messages of the topic kept by the node are returned first.
+	// Default: false
+	WithReplay(replay bool) options.PubSubSubscribeOption
+}
+
 var ErrIsDir = errors.New("object is a directory")
 var ErrOffline = errors.New("can't resolve, ipfs node is offline")
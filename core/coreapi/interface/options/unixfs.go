@@ -0,0 +1,84 @@
+package options
+
+type UnixfsAddSettings struct {
+	Chunker  string
+	Layout   string
+	Progress func(UnixfsAddProgress)
+}
+
+// UnixfsAddProgress is a progress update of an add.
+type UnixfsAddProgress struct {
+	Event  string // "started", "hashing" or "completed"
+	Bytes  int64  // bytes hashed so far
+	Blocks uint64 // blocks written so far
+	Dedup  uint64 // blocks written which were already stored
+}
+
+type UnixfsAddOption func(*UnixfsAddSettings) error
+
+func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, error) {
+	options := &UnixfsAddSettings{
+		Chunker: "size-262144",
+		Layout:  "balanced",
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+type UnixfsWriteSettings struct {
+	Truncate bool
+}
+
+type UnixfsWriteOption func(*UnixfsWriteSettings) error
+
+func UnixfsWriteOptions(opts ...UnixfsWriteOption) (*UnixfsWriteSettings, error) {
+	options := &UnixfsWriteSettings{
+		Truncate: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+type UnixfsOptions struct{}
+
+func (api *UnixfsOptions) WithChunker(chunker string) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Chunker = chunker
+		return nil
+	}
+}
+
+func (api *UnixfsOptions) WithLayout(layout string) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Layout = layout
+		return nil
+	}
+}
+
+func (api *UnixfsOptions) WithProgress(progress func(UnixfsAddProgress)) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Progress = progress
+		return nil
+	}
+}
+
+func (api *UnixfsOptions) WithTruncate(truncate bool) UnixfsWriteOption {
+	return func(settings *UnixfsWriteSettings) error {
+		settings.Truncate = truncate
+		return nil
+	}
+}
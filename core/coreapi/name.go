@@ -10,6 +10,7 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	events "github.com/ipfs/go-ipfs/core/events"
 	keystore "github.com/ipfs/go-ipfs/keystore"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ipath "github.com/ipfs/go-ipfs/path"
@@ -78,6 +79,7 @@ func (api *NameAPI) Publish(ctx context.Context, p coreiface.Path, opts ...caopt
 	if err != nil {
 		return nil, err
 	}
+	n.Events.Emit(events.Event{Type: events.IpnsPublished, Name: pid.Pretty(), Value: pth.String()})
 
 	return &ipnsEntry{
 		name:  pid.Pretty(),
@@ -39,6 +39,10 @@ type Node struct {
 }
 
 func (api *ObjectAPI) New(ctx context.Context, opts ...caopts.ObjectNewOption) (coreiface.Node, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	options, err := caopts.ObjectNewOptions(opts...)
 	if err != nil {
 		return nil, err
@@ -60,6 +64,10 @@ func (api *ObjectAPI) New(ctx context.Context, opts ...caopts.ObjectNewOption) (
 }
 
 func (api *ObjectAPI) Put(ctx context.Context, src io.Reader, opts ...caopts.ObjectPutOption) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	options, err := caopts.ObjectPutOptions(opts...)
 	if err != nil {
 		return nil, err
@@ -184,6 +192,10 @@ func (api *ObjectAPI) Stat(ctx context.Context, path coreiface.Path) (*coreiface
 }
 
 func (api *ObjectAPI) AddLink(ctx context.Context, base coreiface.Path, name string, child coreiface.Path, opts ...caopts.ObjectAddLinkOption) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	options, err := caopts.ObjectAddLinkOptions(opts...)
 	if err != nil {
 		return nil, err
@@ -225,6 +237,10 @@ func (api *ObjectAPI) AddLink(ctx context.Context, base coreiface.Path, name str
 }
 
 func (api *ObjectAPI) RmLink(ctx context.Context, base coreiface.Path, link string) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	baseNd, err := api.core().ResolveNode(ctx, base)
 	if err != nil {
 		return nil, err
@@ -259,6 +275,10 @@ func (api *ObjectAPI) SetData(ctx context.Context, path coreiface.Path, r io.Rea
 }
 
 func (api *ObjectAPI) patchData(ctx context.Context, path coreiface.Path, r io.Reader, appendData bool) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	nd, err := api.core().ResolveNode(ctx, path)
 	if err != nil {
 		return nil, err
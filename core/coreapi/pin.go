@@ -4,11 +4,9 @@ import (
 	"context"
 	"fmt"
 
-	bserv "github.com/ipfs/go-ipfs/blockservice"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
-	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	pin "github.com/ipfs/go-ipfs/pin"
 
@@ -22,6 +20,10 @@ type PinAPI struct {
 }
 
 func (api *PinAPI) Add(ctx context.Context, p coreiface.Path, opts ...caopts.PinAddOption) error {
+	if err := api.checkWritable(); err != nil {
+		return err
+	}
+
 	settings, err := caopts.PinAddOptions(opts...)
 	if err != nil {
 		return err
@@ -53,6 +55,10 @@ func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) ([]coreif
 }
 
 func (api *PinAPI) Rm(ctx context.Context, p coreiface.Path) error {
+	if err := api.checkWritable(); err != nil {
+		return err
+	}
+
 	_, err := corerepo.Unpin(api.node, ctx, []string{p.String()}, true)
 	if err != nil {
 		return err
@@ -62,6 +68,10 @@ func (api *PinAPI) Rm(ctx context.Context, p coreiface.Path) error {
 }
 
 func (api *PinAPI) Update(ctx context.Context, from coreiface.Path, to coreiface.Path, opts ...caopts.PinUpdateOption) error {
+	if err := api.checkWritable(); err != nil {
+		return err
+	}
+
 	settings, err := caopts.PinUpdateOptions(opts...)
 	if err != nil {
 		return err
@@ -99,45 +109,17 @@ func (n *badNode) Err() error {
 }
 
 func (api *PinAPI) Verify(ctx context.Context) (<-chan coreiface.PinStatus, error) {
-	visited := make(map[string]*pinStatus)
-	bs := api.node.Blocks.Blockstore()
-	DAG := merkledag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
-	getLinks := merkledag.GetLinksWithDAG(DAG)
-	recPins := api.node.Pinning.RecursiveKeys()
-
-	var checkPin func(root *cid.Cid) *pinStatus
-	checkPin = func(root *cid.Cid) *pinStatus {
-		key := root.String()
-		if status, ok := visited[key]; ok {
-			return status
-		}
-
-		links, err := getLinks(ctx, root)
-		if err != nil {
-			status := &pinStatus{ok: false, cid: root}
-			status.badNodes = []coreiface.BadPinNode{&badNode{cid: root, err: err}}
-			visited[key] = status
-			return status
-		}
-
-		status := &pinStatus{ok: true, cid: root}
-		for _, lnk := range links {
-			res := checkPin(lnk.Cid)
-			if !res.ok {
-				status.ok = false
-				status.badNodes = append(status.badNodes, res.badNodes...)
-			}
-		}
-
-		visited[key] = status
-		return status
-	}
+	results := pin.VerifyPins(ctx, api.node.Pinning, api.node.Blocks.Blockstore(), pin.VerifyOptions{IncludeOk: true})
 
 	out := make(chan coreiface.PinStatus)
 	go func() {
 		defer close(out)
-		for _, c := range recPins {
-			out <- checkPin(c)
+		for r := range results {
+			status := &pinStatus{ok: r.Ok, cid: r.Cid}
+			for _, bn := range r.BadNodes {
+				status.badNodes = append(status.badNodes, &badNode{cid: bn.Cid, err: bn.Err})
+			}
+			out <- status
 		}
 	}()
 
@@ -176,7 +158,12 @@ func pinLsAll(typeStr string, ctx context.Context, pinning pin.Pinner, dag ipld.
 	if typeStr == "indirect" || typeStr == "all" {
 		set := cid.NewSet()
 		for _, k := range pinning.RecursiveKeys() {
-			err := merkledag.EnumerateChildren(ctx, merkledag.GetLinksWithDAG(dag), k, set.Visit)
+			err := merkledag.Walk(ctx, dag, k, merkledag.SelectAll, func(nd ipld.Node, depth int) error {
+				if depth > 0 && !set.Visit(nd.Cid()) {
+					return merkledag.SkipLinks
+				}
+				return nil
+			})
 			if err != nil {
 				return nil, err
 			}
@@ -4,6 +4,13 @@ import (
 	"context"
 	"strings"
 	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
 )
 
 func TestPinAdd(t *testing.T) {
@@ -207,3 +214,30 @@ func TestPinRecursive(t *testing.T) {
 		t.Errorf("unexpected verify result count: %d", n)
 	}
 }
+
+func TestReadOnlyRepo(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{Identity: config.Identity{PeerID: testPeerID}}
+	cfg.Datastore.ReadOnly = true
+	n, err := core.NewNode(ctx, &core.BuildCfg{Repo: &repo.Mock{
+		C: cfg,
+		D: ds2.ThreadSafeCloserMapDatastore(),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := coreapi.NewCoreAPI(n)
+
+	if _, err := api.Unixfs().Add(ctx, strings.NewReader("foo")); err != core.ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly adding, got %v", err)
+	}
+	if err := api.Pin().Add(ctx, emptyDir); err != core.ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly pinning, got %v", err)
+	}
+	if _, err := api.Block().Put(ctx, strings.NewReader("foo")); err != core.ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly putting a block, got %v", err)
+	}
+	if err := corerepo.GarbageCollect(n, ctx); err != core.ErrReadOnly {
+		t.Fatalf("expected a single ErrReadOnly from gc, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	pubsub "github.com/ipfs/go-ipfs/pubsub"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+)
+
+var log = logging.Logger("coreapi")
+
+var errPubSubDisabled = errors.New("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use.")
+
+type PubSubAPI struct {
+	*CoreAPI
+	*caopts.PubSubOptions
+}
+
+type pubSubMessage struct {
+	msg *pubsub.Message
+}
+
+func (m *pubSubMessage) From() peer.ID { return m.msg.From }
+func (m *pubSubMessage) Data() []byte  { return m.msg.Data }
+func (m *pubSubMessage) Seq() uint64   { return m.msg.Seqno }
+func (m *pubSubMessage) Topic() string { return m.msg.Topic }
+
+type pubSubSubscription struct {
+	sub *pubsub.Subscription
+
+	// replayed messages, returned before the incoming ones
+	replay []*pubsub.Message
+}
+
+// Close cancels the subscription.
+func (s *pubSubSubscription) Close() error {
+	s.sub.Cancel()
+	return nil
+}
+
+// Next returns the next replayed message, then the next incoming one.
+func (s *pubSubSubscription) Next(ctx context.Context) (coreiface.PubSubMessage, error) {
+	if len(s.replay) > 0 {
+		m := s.replay[0]
+		s.replay = s.replay[1:]
+		return &pubSubMessage{m}, nil
+	}
+
+	m, err := s.sub.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pubSubMessage{m}, nil
+}
+
+// Ls lists the topics the node is subscribed to.
+func (api *PubSubAPI) Ls(ctx context.Context) ([]string, error) {
+	ps, err := api.pubsub()
+	if err != nil {
+		return nil, err
+	}
+	return ps.Topics(), nil
+}
+
+// Peers lists the peers subscribed to the topic, or to any topic, the node
+// is connected to.
+func (api *PubSubAPI) Peers(ctx context.Context, opts ...caopts.PubSubPeersOption) ([]peer.ID, error) {
+	settings, err := caopts.PubSubPeersOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := api.pubsub()
+	if err != nil {
+		return nil, err
+	}
+	return ps.Peers(settings.Topic), nil
+}
+
+// Publish publishes data to topic, signed if the topic is.
+func (api *PubSubAPI) Publish(ctx context.Context, topic string, data []byte) error {
+	ps, err := api.pubsub()
+	if err != nil {
+		return err
+	}
+	return ps.Publish(topic, data)
+}
+
+// Subscribe subscribes to topic.
+func (api *PubSubAPI) Subscribe(ctx context.Context, topic string, opts ...caopts.PubSubSubscribeOption) (coreiface.PubSubSubscription, error) {
+	settings, err := caopts.PubSubSubscribeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := api.pubsub()
+	if err != nil {
+		return nil, err
+	}
+
+	var replay []*pubsub.Message
+	if settings.Replay {
+		replay, err = ps.Replay(topic)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.Discover {
+		go func() {
+			blk := blocks.NewBlock([]byte("floodsub:" + topic))
+			if err := api.node.Blocks.AddBlock(blk); err != nil {
+				log.Error("pubsub discovery: ", err)
+				return
+			}
+			connectToPubSubPeers(ctx, api.node, blk.Cid())
+		}()
+	}
+
+	return &pubSubSubscription{sub: sub, replay: replay}, nil
+}
+
+func (api *PubSubAPI) pubsub() (*pubsub.PubSub, error) {
+	n := api.node
+	if !n.OnlineMode() {
+		return nil, coreiface.ErrOffline
+	}
+	if n.PubSub == nil {
+		return nil, errPubSubDisabled
+	}
+	return n.PubSub, nil
+}
+
+func connectToPubSubPeers(ctx context.Context, n *core.IpfsNode, c *cid.Cid) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	provs := n.Routing.FindProvidersAsync(ctx, c, 10)
+	var wg sync.WaitGroup
+	for p := range provs {
+		wg.Add(1)
+		go func(pi pstore.PeerInfo) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+			defer cancel()
+			if err := n.PeerHost.Connect(ctx, pi); err != nil {
+				log.Info("pubsub discover: ", err)
+				return
+			}
+			log.Info("connected to pubsub peer:", pi.ID)
+		}(p)
+	}
+	wg.Wait()
+}
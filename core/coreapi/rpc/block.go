@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"context"
+	"io"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	pb "github.com/ipfs/go-ipfs/core/coreapi/rpc/pb"
+)
+
+type blockServer struct {
+	*server
+}
+
+func (s *blockServer) Get(req *pb.PathRequest, stream pb.Block_GetServer) error {
+	p, err := coreapi.ParsePath(req.Path)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.api.Block().Get(stream.Context(), p)
+	if err != nil {
+		return err
+	}
+	return sendChunks(r, stream.Send)
+}
+
+func (s *blockServer) Put(stream pb.Block_PutServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		first = new(pb.BlockPutRequest)
+	} else if err != nil {
+		return err
+	}
+
+	var opts []caopts.BlockPutOption
+	if first.Format != "" {
+		opts = append(opts, s.api.Block().WithFormat(first.Format))
+	}
+	if first.Mhtype != 0 {
+		mhlen := int(first.Mhlen)
+		if mhlen == 0 {
+			mhlen = -1
+		}
+		opts = append(opts, s.api.Block().WithHash(first.Mhtype, mhlen))
+	}
+
+	r := &chunkReader{
+		buf: first.Data,
+		next: func() ([]byte, error) {
+			m, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			return m.Data, nil
+		},
+	}
+	p, err := s.api.Block().Put(stream.Context(), r, opts...)
+	if err != nil {
+		return err
+	}
+
+	st, err := s.stat(stream.Context(), p.String())
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(st)
+}
+
+func (s *blockServer) Stat(ctx context.Context, req *pb.PathRequest) (*pb.BlockStat, error) {
+	return s.stat(ctx, req.Path)
+}
+
+func (s *blockServer) stat(ctx context.Context, path string) (*pb.BlockStat, error) {
+	p, err := coreapi.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := s.api.Block().Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BlockStat{Path: st.Path().String(), Size: uint64(st.Size())}, nil
+}
+
+func (s *blockServer) Rm(ctx context.Context, req *pb.BlockRmRequest) (*pb.Empty, error) {
+	p, err := coreapi.ParsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.api.Block().Rm(ctx, p, s.api.Block().WithForce(req.Force))
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	car "github.com/ipfs/go-ipfs/car"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	pb "github.com/ipfs/go-ipfs/core/coreapi/rpc/pb"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+type dagServer struct {
+	*server
+}
+
+func (s *dagServer) Get(ctx context.Context, req *pb.PathRequest) (*pb.DagNode, error) {
+	p, err := coreapi.ParsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := s.api.Dag().Get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DagNode{Cid: nd.Cid().String(), Data: nd.RawData()}, nil
+}
+
+func (s *dagServer) Put(ctx context.Context, req *pb.DagPutRequest) (*pb.PathResponse, error) {
+	var opts []caopts.DagPutOption
+	if req.InputEnc != "" {
+		opts = append(opts, s.api.Dag().WithInputEnc(req.InputEnc))
+	}
+	if req.Format != "" {
+		codec, ok := cid.Codecs[req.Format]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized format: %s", req.Format)
+		}
+		opts = append(opts, s.api.Dag().WithCodec(codec))
+	}
+	if req.Mhtype != 0 {
+		opts = append(opts, s.api.Dag().WithHash(req.Mhtype, -1))
+	}
+
+	if req.Pin {
+		// keep GC from removing the node before it is pinned
+		defer s.node.Blockstore.PinLock().Unlock()
+	}
+
+	p, err := s.api.Dag().Put(ctx, bytes.NewReader(req.Data), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Pin {
+		s.node.Pinning.PinWithMode(p.Cid(), pin.Recursive)
+		if err := s.node.Pinning.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return &pb.PathResponse{Path: p.String()}, nil
+}
+
+func (s *dagServer) Import(stream pb.Dag_ImportServer) error {
+	r := &chunkReader{
+		next: func() ([]byte, error) {
+			m, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			return m.Data, nil
+		},
+	}
+
+	var last car.Progress
+	var sent time.Time
+	progress := func(p car.Progress) {
+		last = p
+		if time.Since(sent) < progressInterval {
+			return
+		}
+		sent = time.Now()
+		// if sending fails, the stream is broken and the import is
+		// cancelled along with its context
+		stream.Send(&pb.ImportProgress{Blocks: p.Blocks, Bytes: p.Bytes})
+	}
+
+	h, err := car.Import(stream.Context(), s.node.Blocks, r, progress)
+	if err != nil {
+		return err
+	}
+
+	roots := make([]string, len(h.Roots))
+	for i, c := range h.Roots {
+		roots[i] = c.String()
+	}
+	return stream.Send(&pb.ImportProgress{Blocks: last.Blocks, Bytes: last.Bytes, Roots: roots})
+}
+
+func (s *dagServer) Export(req *pb.PathRequest, stream pb.Dag_ExportServer) error {
+	p, err := coreapi.ParsePath(req.Path)
+	if err != nil {
+		return err
+	}
+
+	rp, err := s.api.ResolvePath(stream.Context(), p)
+	if err != nil {
+		return err
+	}
+	return car.ExportDAG(stream.Context(), s.node.DAG, []*cid.Cid{rp.Cid()}, chunkWriter(stream.Send), nil)
+}
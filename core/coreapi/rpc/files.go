@@ -0,0 +1,362 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+
+	pb "github.com/ipfs/go-ipfs/core/coreapi/rpc/pb"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+type filesServer struct {
+	*server
+}
+
+func (s *filesServer) Read(req *pb.FilesReadRequest, stream pb.Files_ReadServer) error {
+	path, err := checkPath(req.Path)
+	if err != nil {
+		return err
+	}
+	if req.Offset < 0 {
+		return fmt.Errorf("cannot specify negative offset")
+	}
+	if req.Count < 0 {
+		return fmt.Errorf("cannot specify negative 'count'")
+	}
+
+	fsn, err := mfs.Lookup(s.node.FilesRoot, path)
+	if err != nil {
+		return err
+	}
+	fi, ok := fsn.(*mfs.File)
+	if !ok {
+		return fmt.Errorf("%s was not a file", path)
+	}
+
+	rfd, err := fi.Open(mfs.OpenReadOnly, false)
+	if err != nil {
+		return err
+	}
+	defer rfd.Close()
+
+	filen, err := rfd.Size()
+	if err != nil {
+		return err
+	}
+	if req.Offset > filen {
+		return fmt.Errorf("offset was past end of file (%d > %d)", req.Offset, filen)
+	}
+	if _, err := rfd.Seek(req.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var r io.Reader = &ctxReader{r: rfd, ctx: stream.Context()}
+	if req.Count > 0 {
+		r = io.LimitReader(r, req.Count)
+	}
+	return sendChunks(r, stream.Send)
+}
+
+func (s *filesServer) Write(stream pb.Files_WriteServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return errors.New("no path given to write")
+	} else if err != nil {
+		return err
+	}
+
+	path, err := checkPath(first.Path)
+	if err != nil {
+		return err
+	}
+	if first.Offset < 0 {
+		return fmt.Errorf("cannot have negative write offset")
+	}
+
+	if first.Parents {
+		dir := gopath.Dir(path)
+		if dir != "/" {
+			err := mfs.Mkdir(s.node.FilesRoot, dir, mfs.MkdirOpts{Mkparents: true})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fi, err := getFileHandle(s.node.FilesRoot, path, first.Create)
+	if err != nil {
+		return err
+	}
+
+	wfd, err := fi.Open(mfs.OpenWriteOnly, true)
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(wfd, first, stream)
+	if cerr := wfd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(new(pb.Empty))
+}
+
+func writeFile(wfd mfs.FileDescriptor, first *pb.FilesWriteRequest, stream pb.Files_WriteServer) error {
+	if first.Truncate {
+		if err := wfd.Truncate(0); err != nil {
+			return err
+		}
+	}
+
+	if _, err := wfd.Seek(first.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := &chunkReader{
+		buf: first.Data,
+		next: func() ([]byte, error) {
+			m, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			return m.Data, nil
+		},
+	}
+	_, err := io.Copy(wfd, r)
+	return err
+}
+
+func (s *filesServer) Ls(req *pb.FilesPathRequest, stream pb.Files_LsServer) error {
+	path, err := checkPath(req.Path)
+	if err != nil {
+		return err
+	}
+
+	fsn, err := mfs.Lookup(s.node.FilesRoot, path)
+	if err != nil {
+		return err
+	}
+
+	switch fsn := fsn.(type) {
+	case *mfs.Directory:
+		return fsn.ForEachEntry(stream.Context(), func(nl mfs.NodeListing) error {
+			return stream.Send(&pb.FilesEntry{
+				Name: nl.Name,
+				Hash: nl.Hash,
+				Size: uint64(nl.Size),
+				Type: typeName(mfs.NodeType(nl.Type)),
+			})
+		})
+	default:
+		e, err := entry(path, fsn)
+		if err != nil {
+			return err
+		}
+		return stream.Send(e)
+	}
+}
+
+func (s *filesServer) Stat(ctx context.Context, req *pb.FilesPathRequest) (*pb.FilesEntry, error) {
+	path, err := checkPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fsn, err := mfs.Lookup(s.node.FilesRoot, path)
+	if err != nil {
+		return nil, err
+	}
+	return entry(path, fsn)
+}
+
+func (s *filesServer) Mkdir(ctx context.Context, req *pb.FilesMkdirRequest) (*pb.Empty, error) {
+	path, err := checkPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mfs.Mkdir(s.node.FilesRoot, path, mfs.MkdirOpts{
+		Mkparents: req.Parents,
+		Flush:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+func (s *filesServer) Rm(ctx context.Context, req *pb.FilesRmRequest) (*pb.Empty, error) {
+	path, err := checkPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "/" {
+		return nil, fmt.Errorf("cannot delete root")
+	}
+
+	// 'rm a/b/c/' will fail unless we trim the slash at the end
+	if path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	}
+
+	dir, name := gopath.Split(path)
+	parent, err := mfs.Lookup(s.node.FilesRoot, dir)
+	if err != nil {
+		return nil, fmt.Errorf("parent lookup: %s", err)
+	}
+	pdir, ok := parent.(*mfs.Directory)
+	if !ok {
+		return nil, fmt.Errorf("no such file or directory: %s", path)
+	}
+
+	// if recursive, don't check the type of the child: its block may not
+	// even exist
+	if !req.Recursive {
+		child, err := pdir.Child(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := child.(*mfs.Directory); ok {
+			return nil, fmt.Errorf("%s is a directory, use recursive to remove directories", path)
+		}
+	}
+
+	if err := pdir.Unlink(name); err != nil {
+		return nil, err
+	}
+	if err := pdir.Flush(); err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+func (s *filesServer) Mv(ctx context.Context, req *pb.FilesMvRequest) (*pb.Empty, error) {
+	src, err := checkPath(req.Source)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := checkPath(req.Dest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mfs.Mv(s.node.FilesRoot, src, dst); err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+// entry returns the FilesEntry of fsn, found at path.
+func entry(path string, fsn mfs.FSNode) (*pb.FilesEntry, error) {
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &pb.FilesEntry{
+		Name: gopath.Base(path),
+		Hash: nd.Cid().String(),
+		Type: typeName(fsn.Type()),
+	}
+	if fi, ok := fsn.(*mfs.File); ok {
+		size, err := fi.Size()
+		if err != nil {
+			return nil, err
+		}
+		e.Size = uint64(size)
+	}
+	return e, nil
+}
+
+func typeName(t mfs.NodeType) string {
+	if t == mfs.TDir {
+		return "directory"
+	}
+	return "file"
+}
+
+// getFileHandle returns the file at path, creating it if create is set and
+// it doesn't exist, like 'ipfs files write'.
+func getFileHandle(r *mfs.Root, path string, create bool) (*mfs.File, error) {
+	target, err := mfs.Lookup(r, path)
+	switch err {
+	case nil:
+		fi, ok := target.(*mfs.File)
+		if !ok {
+			return nil, fmt.Errorf("%s was not a file", path)
+		}
+		return fi, nil
+
+	case os.ErrNotExist:
+		if !create {
+			return nil, err
+		}
+
+		dirname, fname := gopath.Split(path)
+		pdiri, err := mfs.Lookup(r, dirname)
+		if err != nil {
+			return nil, err
+		}
+		pdir, ok := pdiri.(*mfs.Directory)
+		if !ok {
+			return nil, fmt.Errorf("%s was not a directory", dirname)
+		}
+
+		nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+		nd.SetPrefix(pdir.GetPrefix())
+		if err := pdir.AddChild(fname, nd); err != nil {
+			return nil, err
+		}
+
+		fsn, err := pdir.Child(fname)
+		if err != nil {
+			return nil, err
+		}
+		fi, ok := fsn.(*mfs.File)
+		if !ok {
+			return nil, errors.New("expected *mfs.File, didnt get it. This is likely a race condition")
+		}
+		return fi, nil
+
+	default:
+		return nil, err
+	}
+}
+
+func checkPath(p string) (string, error) {
+	if len(p) == 0 {
+		return "", fmt.Errorf("paths must not be empty")
+	}
+
+	if p[0] != '/' {
+		return "", fmt.Errorf("paths must start with a leading slash")
+	}
+
+	cleaned := gopath.Clean(p)
+	if p[len(p)-1] == '/' && p != "/" {
+		cleaned += "/"
+	}
+	return cleaned, nil
+}
+
+// ctxReader reads a file of the files root, giving up when ctx is done.
+type ctxReader struct {
+	r   mfs.FileDescriptor
+	ctx context.Context
+}
+
+func (r *ctxReader) Read(b []byte) (int, error) {
+	n, err := r.r.CtxReadFull(r.ctx, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
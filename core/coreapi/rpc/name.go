@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	pb "github.com/ipfs/go-ipfs/core/coreapi/rpc/pb"
+)
+
+type nameServer struct {
+	*server
+}
+
+func (s *nameServer) Publish(ctx context.Context, req *pb.NamePublishRequest) (*pb.NameEntry, error) {
+	p, err := coreapi.ParsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []caopts.NamePublishOption
+	if req.Key != "" {
+		opts = append(opts, s.api.Name().WithKey(req.Key))
+	}
+	if req.ValidTime != 0 {
+		opts = append(opts, s.api.Name().WithValidTime(time.Duration(req.ValidTime)*time.Second))
+	}
+
+	e, err := s.api.Name().Publish(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.NameEntry{Name: e.Name(), Value: e.Value().String()}, nil
+}
+
+func (s *nameServer) Resolve(ctx context.Context, req *pb.NameResolveRequest) (*pb.PathResponse, error) {
+	p, err := s.api.Name().Resolve(ctx, req.Name,
+		s.api.Name().WithRecursive(req.Recursive),
+		s.api.Name().WithLocal(req.Local),
+		s.api.Name().WithCache(req.Cache),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PathResponse{Path: p.String()}, nil
+}
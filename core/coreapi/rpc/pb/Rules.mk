@@ -0,0 +1,11 @@
+include mk/header.mk
+
+PB_$(d) = $(wildcard $(d)/*.proto)
+TGTS_$(d) = $(PB_$(d):.proto=.pb.go)
+
+# the services need the grpc plugin
+$(TGTS_$(d)): PROTOC = protoc --gogo_out=plugins=grpc:. --proto_path=.:/usr/local/opt/protobuf/include:$(dir $@) $<
+
+#DEPS_GO += $(TGTS_$(d))
+
+include mk/footer.mk
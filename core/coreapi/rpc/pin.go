@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	pb "github.com/ipfs/go-ipfs/core/coreapi/rpc/pb"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+type pinServer struct {
+	*server
+}
+
+func (s *pinServer) Add(req *pb.PinAddRequest, stream pb.Pin_AddServer) error {
+	p, err := coreapi.ParsePath(req.Path)
+	if err != nil {
+		return err
+	}
+
+	v := new(dag.ProgressTracker)
+	ctx := v.DeriveContext(stream.Context())
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.api.Pin().Add(ctx, p, s.api.Pin().WithRecursive(req.Recursive))
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+			return stream.Send(&pb.PinProgress{Fetched: uint64(v.Value()), Done: true})
+		case <-ticker.C:
+			// returning cancels the context of the pin
+			if err := stream.Send(&pb.PinProgress{Fetched: uint64(v.Value())}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *pinServer) Rm(ctx context.Context, req *pb.PinRmRequest) (*pb.Empty, error) {
+	_, err := corerepo.Unpin(s.node, ctx, []string{req.Path}, req.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+func (s *pinServer) Ls(req *pb.PinLsRequest, stream pb.Pin_LsServer) error {
+	var opts []caopts.PinLsOption
+	if req.Type != "" {
+		opts = append(opts, s.api.Pin().WithType(req.Type))
+	}
+
+	pins, err := s.api.Pin().Ls(stream.Context(), opts...)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pins {
+		err := stream.Send(&pb.PinInfo{Path: p.Path().String(), Type: p.Type()})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
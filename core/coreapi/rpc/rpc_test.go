@@ -0,0 +1,146 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	pb "github.com/ipfs/go-ipfs/core/coreapi/rpc/pb"
+	coremock "github.com/ipfs/go-ipfs/core/mock"
+
+	grpc "google.golang.org/grpc"
+)
+
+func dialServer(t *testing.T) *grpc.ClientConn {
+	n, err := coremock.NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go Serve(n, lis)
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cc
+}
+
+func readChunks(recv func() (*pb.Chunk, error)) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		c, err := recv()
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(c.Data)
+	}
+}
+
+func TestBlockPutGet(t *testing.T) {
+	ctx := context.Background()
+	cc := dialServer(t)
+	defer cc.Close()
+	c := pb.NewBlockClient(cc)
+
+	// more than a chunk, sent in two messages
+	data := bytes.Repeat([]byte("block"), chunkSize/2)
+	put, err := c.Put(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := put.Send(&pb.BlockPutRequest{Format: "raw", Data: data[:10]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := put.Send(&pb.BlockPutRequest{Data: data[10:]}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := put.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size != uint64(len(data)) {
+		t.Fatalf("expected size %d, got %d", len(data), st.Size)
+	}
+
+	get, err := c.Get(ctx, &pb.PathRequest{Path: st.Path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := readChunks(get.Recv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("got different data back")
+	}
+}
+
+func TestFilesWriteRead(t *testing.T) {
+	ctx := context.Background()
+	cc := dialServer(t)
+	defer cc.Close()
+	c := pb.NewFilesClient(cc)
+
+	w, err := c.Write(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Send(&pb.FilesWriteRequest{Path: "/a/b/file", Create: true, Parents: true, Data: []byte("hello ")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(&pb.FilesWriteRequest{Data: []byte("world")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.Read(ctx, &pb.FilesReadRequest{Path: "/a/b/file", Offset: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := readChunks(r.Recv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "world" {
+		t.Fatalf("expected %q, got %q", "world", out)
+	}
+
+	ls, err := c.Ls(ctx, &pb.FilesPathRequest{Path: "/a/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := ls.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Name != "file" || e.Type != "file" || e.Size != 11 {
+		t.Fatalf("unexpected entry: %v", e)
+	}
+	if _, err := ls.Recv(); err != io.EOF {
+		t.Fatalf("expected a single entry, got %v", err)
+	}
+
+	_, err = c.Rm(ctx, &pb.FilesRmRequest{Path: "/a"})
+	if err == nil {
+		t.Fatal("expected removing a directory without recursive to fail")
+	}
+	if _, err := c.Rm(ctx, &pb.FilesRmRequest{Path: "/a", Recursive: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Stat(ctx, &pb.FilesPathRequest{Path: "/a"}); err == nil {
+		t.Fatal("expected /a to be removed")
+	}
+}
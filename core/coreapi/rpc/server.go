@@ -0,0 +1,149 @@
+// Package rpc serves the core API over gRPC, with the services defined in
+// pb/api.proto.
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	pb "github.com/ipfs/go-ipfs/core/coreapi/rpc/pb"
+
+	logging "github.com/ipfs/go-log"
+	goprocess "github.com/jbenet/goprocess"
+	grpc "google.golang.org/grpc"
+)
+
+var log = logging.Logger("coreapi/rpc")
+
+// chunkSize is the most data sent in one Chunk, well below the 4MiB limit
+// of gRPC messages.
+const chunkSize = 256 << 10
+
+// progressInterval is how often the progress of long calls is reported.
+var progressInterval = 500 * time.Millisecond
+
+// server holds what the services need of the node.
+type server struct {
+	node *core.IpfsNode
+	api  coreiface.CoreAPI
+}
+
+// NewServer returns a gRPC server with the services of the core API of n
+// registered. Like the commands, calls wait for a switch of the mode of the
+// node in progress to complete.
+func NewServer(n *core.IpfsNode) *grpc.Server {
+	gs := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			n.LockServices()()
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			n.LockServices()()
+			return handler(srv, ss)
+		}),
+	)
+
+	s := &server{node: n, api: coreapi.NewCoreAPI(n)}
+	pb.RegisterBlockServer(gs, &blockServer{s})
+	pb.RegisterDagServer(gs, &dagServer{s})
+	pb.RegisterPinServer(gs, &pinServer{s})
+	pb.RegisterNameServer(gs, &nameServer{s})
+	pb.RegisterFilesServer(gs, &filesServer{s})
+	return gs
+}
+
+// Serve serves the core API of node over gRPC on lis, until the node is
+// closed.
+func Serve(node *core.IpfsNode, lis net.Listener) error {
+	select {
+	case <-node.Process().Closing():
+		return fmt.Errorf("failed to start server, process closing")
+	default:
+	}
+
+	gs := NewServer(node)
+
+	var serverError error
+	serverExited := make(chan struct{})
+	node.Process().Go(func(p goprocess.Process) {
+		serverError = gs.Serve(lis)
+		close(serverExited)
+	})
+
+	select {
+	case <-serverExited:
+	case <-node.Process().Closing():
+		log.Infof("server at %s terminating...", lis.Addr())
+		gs.Stop()
+		<-serverExited
+		// if the server exited as we are closing, we really dont care about errors
+		serverError = nil
+	}
+
+	log.Infof("server at %s terminated", lis.Addr())
+	return serverError
+}
+
+// sendChunks streams the content of r as Chunks of at most chunkSize bytes.
+func sendChunks(r io.Reader, send func(*pb.Chunk) error) error {
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := send(&pb.Chunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// chunkWriter sends what is written to it as Chunks.
+type chunkWriter func(*pb.Chunk) error
+
+func (w chunkWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := len(b)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		if err := w(&pb.Chunk{Data: b[:n]}); err != nil {
+			return written, err
+		}
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// chunkReader reads the data of the messages returned by next, until it
+// returns an error; io.EOF marks the end of the stream.
+type chunkReader struct {
+	buf  []byte
+	next func() ([]byte, error)
+}
+
+func (r *chunkReader) Read(b []byte) (int, error) {
+	for len(r.buf) == 0 {
+		data, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = data
+	}
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
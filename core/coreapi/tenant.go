@@ -0,0 +1,16 @@
+package coreapi
+
+import (
+	core "github.com/ipfs/go-ipfs/core"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+)
+
+// NewTenantAPI creates a new instance of IPFS CoreAPI working on the repo of
+// the tenant of n named name.
+func NewTenantAPI(n *core.IpfsNode, name string) (coreiface.CoreAPI, error) {
+	t, err := n.Tenant(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewCoreAPI(t), nil
+}
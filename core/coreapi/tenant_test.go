@@ -0,0 +1,91 @@
+package coreapi_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	repo "github.com/ipfs/go-ipfs/repo"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	node, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &repo.Mock{
+		D: ds2.ThreadSafeCloserMapDatastore(),
+		K: keystore.NewMemKeystore(),
+	}
+	tenant, err := node.AddTenant("alice", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.AddTenant("alice", r); err == nil {
+		t.Fatal("adding a tenant twice should fail")
+	}
+	if _, err := coreapi.NewTenantAPI(node, "bob"); err == nil {
+		t.Fatal("expected an error for an unknown tenant")
+	}
+
+	tapi, err := coreapi.NewTenantAPI(node, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := tapi.Block().Put(ctx, strings.NewReader("tenant data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := tapi.Block().Get(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "tenant data" {
+		t.Fatalf("unexpected block data %q", data)
+	}
+
+	has, err := node.Blockstore.Has(p.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("tenant block should not be stored in the node's repo")
+	}
+
+	if _, err := tapi.Key().Generate(ctx, "tenantkey"); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := api.Key().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if k.Name() == "tenantkey" {
+			t.Fatal("tenant key should not be in the node's keystore")
+		}
+	}
+
+	if err := corerepo.GarbageCollect(tenant, ctx); err != nil {
+		t.Fatal(err)
+	}
+	has, err = tenant.Blockstore.Has(p.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("unpinned tenant block should have been collected")
+	}
+}
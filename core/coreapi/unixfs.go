@@ -5,19 +5,45 @@ import (
 	"io"
 
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	mod "github.com/ipfs/go-ipfs/unixfs/mod"
 
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
 )
 
-type UnixfsAPI CoreAPI
+type UnixfsAPI struct {
+	*CoreAPI
+	*caopts.UnixfsOptions
+}
 
 // Add builds a merkledag node from a reader, adds it to the blockstore,
 // and returns the key representing that node.
-func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader) (coreiface.Path, error) {
-	k, err := coreunix.AddWithContext(ctx, api.node, r)
+func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader, opts ...caopts.UnixfsAddOption) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	settings, err := caopts.UnixfsAddOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var onProgress func(coreunix.AddProgress)
+	if settings.Progress != nil {
+		onProgress = func(p coreunix.AddProgress) {
+			settings.Progress(caopts.UnixfsAddProgress{
+				Event:  string(p.Event),
+				Bytes:  p.Bytes,
+				Blocks: p.Blocks,
+				Dedup:  p.Dedup,
+			})
+		}
+	}
+
+	k, err := coreunix.AddWithSettings(ctx, api.node, r, settings.Chunker, settings.Layout, onProgress)
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +54,34 @@ func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader) (coreiface.Path, err
 	return ParseCid(c), nil
 }
 
+// Write writes the data of r over the file at path p from offset, and returns
+// the path of the modified file, which shares the blocks not written with
+// the original one.
+func (api *UnixfsAPI) Write(ctx context.Context, p coreiface.Path, offset int64, r io.Reader, opts ...caopts.UnixfsWriteOption) (coreiface.Path, error) {
+	if err := api.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	settings, err := caopts.UnixfsWriteOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	dagnode, err := api.core().ResolveNode(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uio.NewDirectoryFromNode(api.node.DAG, dagnode); err == nil {
+		return nil, coreiface.ErrIsDir
+	}
+
+	nd, err := mod.Patch(ctx, dagnode, api.node.DAG, offset, r, settings.Truncate)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCid(nd.Cid()), nil
+}
+
 // Cat returns the data contained by an IPFS or IPNS object(s) at path `p`.
 func (api *UnixfsAPI) Cat(ctx context.Context, p coreiface.Path) (coreiface.Reader, error) {
 	dget := api.node.DAG // TODO: use a session here once routing perf issues are resolved
@@ -37,7 +91,7 @@ func (api *UnixfsAPI) Cat(ctx context.Context, p coreiface.Path) (coreiface.Read
 		return nil, err
 	}
 
-	r, err := uio.NewDagReader(ctx, dagnode, dget)
+	r, err := uio.NewDagReader(ctx, dagnode, dget, uio.WithFetchAhead(api.node.FetchAhead))
 	if err == uio.ErrIsDir {
 		return nil, coreiface.ErrIsDir
 	} else if err != nil {
@@ -77,5 +131,5 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path) ([]*coreiface.Li
 }
 
 func (api *UnixfsAPI) core() coreiface.CoreAPI {
-	return (*CoreAPI)(api)
+	return api.CoreAPI
 }
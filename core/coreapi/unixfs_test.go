@@ -6,12 +6,14 @@ import (
 	"encoding/base64"
 	"io"
 	"math"
+	"math/rand"
 	"strings"
 	"testing"
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	options "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	keystore "github.com/ipfs/go-ipfs/keystore"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
@@ -116,6 +118,81 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestAddChunker(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Repeat(helloStr, 1000)
+	add := func(opts ...options.UnixfsAddOption) coreiface.Path {
+		p, err := api.Unixfs().Add(ctx, strings.NewReader(data), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	def := add()
+	if p := add(api.Unixfs().WithChunker("size-262144")); p.String() != def.String() {
+		t.Fatalf("expected the default chunker to give %s, got %s", def, p)
+	}
+	small := add(api.Unixfs().WithChunker("size-1024"))
+	if small.String() == def.String() {
+		t.Fatal("expected a different chunker to give a different path")
+	}
+	if p := add(api.Unixfs().WithChunker("size-1024")); p.String() != small.String() {
+		t.Fatalf("expected the same chunker to give %s, got %s", small, p)
+	}
+
+	if _, err := api.Unixfs().Add(ctx, strings.NewReader(data), api.Unixfs().WithChunker("foo")); err == nil {
+		t.Fatal("expected an unknown chunker to fail")
+	}
+}
+
+func TestAddProgress(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 600<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+	add := func() []options.UnixfsAddProgress {
+		var updates []options.UnixfsAddProgress
+		progress := func(p options.UnixfsAddProgress) {
+			updates = append(updates, p)
+		}
+		if _, err := api.Unixfs().Add(ctx, bytes.NewReader(data), api.Unixfs().WithProgress(progress)); err != nil {
+			t.Fatal(err)
+		}
+		return updates
+	}
+
+	updates := add()
+	if len(updates) != 4 {
+		t.Fatalf("expected 4 updates, got %v", updates)
+	}
+	if updates[0].Event != "started" || updates[1].Event != "hashing" || updates[1].Bytes != 256<<10 {
+		t.Fatalf("unexpected updates %v", updates)
+	}
+	// 3 leaves, and the root
+	last := updates[3]
+	if last.Event != "completed" || last.Bytes != int64(len(data)) || last.Blocks != 4 {
+		t.Fatalf("unexpected completion %v", last)
+	}
+	if last.Dedup != 0 {
+		t.Fatalf("expected no block to be stored before, got %d", last.Dedup)
+	}
+
+	last = add()[3]
+	if last.Blocks != 4 || last.Dedup != 4 {
+		t.Fatalf("expected all the blocks to be stored before, got %v", last)
+	}
+}
+
 func TestAddEmptyFile(t *testing.T) {
 	ctx := context.Background()
 	_, api, err := makeAPI(ctx)
@@ -134,6 +211,59 @@ func TestAddEmptyFile(t *testing.T) {
 	}
 }
 
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strings.NewReader(helloStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cat := func(p coreiface.Path) string {
+		r, err := api.Unixfs().Cat(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		if _, err := buf.ReadFrom(r); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	p, err = api.Unixfs().Write(ctx, p, 7, strings.NewReader("there"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := cat(p); s != "hello, there!" {
+		t.Fatalf("expected [hello, there!], got [%s]", s)
+	}
+
+	p, err = api.Unixfs().Write(ctx, p, 13, strings.NewReader(" bye"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := cat(p); s != "hello, there! bye" {
+		t.Fatalf("expected [hello, there! bye], got [%s]", s)
+	}
+
+	p, err = api.Unixfs().Write(ctx, p, 0, strings.NewReader("bye"), api.Unixfs().WithTruncate(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := cat(p); s != "bye" {
+		t.Fatalf("expected [bye], got [%s]", s)
+	}
+
+	if _, err := api.Unixfs().Write(ctx, emptyDir, 0, strings.NewReader("bye")); err == nil {
+		t.Fatal("expected writing to a directory to fail")
+	}
+}
+
 func TestCatBasic(t *testing.T) {
 	ctx := context.Background()
 	node, api, err := makeAPI(ctx)
@@ -0,0 +1,35 @@
+package coredag
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/ipfs/go-ipfs/merkledag"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func dagjsonParser(r io.Reader, mhType uint64, mhLen int) ([]ipld.Node, error) {
+	if mhType == math.MaxUint64 {
+		mhType = mh.SHA2_256
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := merkledag.NewJSONNode(data, cid.Prefix{
+		Version:  1,
+		MhType:   mhType,
+		MhLength: mhLen,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []ipld.Node{nd}, nil
+}
@@ -30,6 +30,8 @@ var defaultJSONParsers = FormatParsers{
 
 	"protobuf": dagpbJSONParser,
 	"dag-pb":   dagpbJSONParser,
+
+	"dag-json": dagjsonParser,
 }
 
 var defaultRawParsers = FormatParsers{
@@ -39,6 +41,8 @@ var defaultRawParsers = FormatParsers{
 	"protobuf": dagpbRawParser,
 	"dag-pb":   dagpbRawParser,
 
+	"dag-json": dagjsonParser,
+
 	"raw": rawRawParser,
 }
 
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
@@ -133,11 +134,64 @@ func commandsOption(cctx oldcmds.Context, command *cmds.Command) ServeOption {
 		patchCORSVars(cfg, l.Addr())
 
 		cmdHandler := cmdsHttp.NewHandler(&cctx, command, cfg)
-		mux.Handle(APIPath+"/", cmdHandler)
+		mux.Handle(APIPath+"/", &tenantHandler{
+			node:    n,
+			cctx:    &cctx,
+			command: command,
+			cfg:     cfg,
+			root:    cmdHandler,
+		})
 		return mux, nil
 	}
 }
 
+// tenantHandler runs the commands of requests with the tenant option on the
+// tenant they name, and the other ones on the node.
+type tenantHandler struct {
+	node    *core.IpfsNode
+	cctx    *oldcmds.Context
+	command *cmds.Command
+	cfg     *cmdsHttp.ServerConfig
+	root    http.Handler
+
+	lk      sync.Mutex
+	tenants map[string]http.Handler
+}
+
+func (h *tenantHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get(corecommands.TenantOption)
+	if name == "" {
+		h.root.ServeHTTP(w, r)
+		return
+	}
+
+	th, err := h.tenant(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	th.ServeHTTP(w, r)
+}
+
+func (h *tenantHandler) tenant(name string) (http.Handler, error) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	if th, ok := h.tenants[name]; ok {
+		return th, nil
+	}
+
+	t, err := h.node.Tenant(name)
+	if err != nil {
+		return nil, err
+	}
+	if h.tenants == nil {
+		h.tenants = make(map[string]http.Handler)
+	}
+	th := cmdsHttp.NewHandler(h.cctx.WithNode(t), h.command, h.cfg)
+	h.tenants[name] = th
+	return th, nil
+}
+
 // CommandsOption constructs a ServerOption for hooking the commands into the
 // HTTP server.
 func CommandsOption(cctx oldcmds.Context) ServeOption {
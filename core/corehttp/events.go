@@ -0,0 +1,69 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
+)
+
+// eventsBuffer is the number of events buffered for a slow client before
+// dropping them.
+const eventsBuffer = 256
+
+// EventsOption serves the events of the node at /events as server-sent
+// events, limited to the comma separated event types of the type parameter.
+func EventsOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+			flusher, ok := w.(http.Flusher)
+			if !ok || n.Events == nil {
+				http.Error(w, "events can't be streamed", http.StatusInternalServerError)
+				return
+			}
+			types, err := events.ParseTypes(r.URL.Query().Get("type"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			evs := make(chan events.Event, eventsBuffer)
+			cancel := n.Events.Subscribe(func(ev events.Event) {
+				select {
+				case evs <- ev:
+				default:
+					// don't slow down the node for a slow client
+				}
+			}, types...)
+			defer cancel()
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			flusher.Flush()
+
+			for {
+				select {
+				case ev := <-evs:
+					data, err := json.Marshal(ev)
+					if err != nil {
+						log.Errorf("encoding event: %s", err)
+						continue
+					}
+					if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+						return
+					}
+					flusher.Flush()
+				case <-r.Context().Done():
+					return
+				case <-n.Process().Closing():
+					return
+				}
+			}
+		})
+		return mux, nil
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
@@ -16,6 +17,17 @@ type GatewayConfig struct {
 	Headers      map[string][]string
 	Writable     bool
 	PathPrefixes []string
+
+	// MaxRequestBlocks bounds the blocks a request reads, if positive, and
+	// MaxRequestDuration the time it takes.
+	MaxRequestBlocks   int64
+	MaxRequestDuration time.Duration
+
+	// WriteTokens, if any, are the bearer tokens accepted for writes.
+	WriteTokens []string
+
+	// Archives enables downloading directories as archives.
+	Archives bool
 }
 
 func GatewayOption(writable bool, paths ...string) ServeOption {
@@ -25,10 +37,22 @@ func GatewayOption(writable bool, paths ...string) ServeOption {
 			return nil, err
 		}
 
+		maxDuration := defaultMaxRequestDuration
+		if d := cfg.Gateway.Limits.MaxRequestDuration; d != "" {
+			maxDuration, err = time.ParseDuration(d)
+			if err != nil {
+				return nil, fmt.Errorf("parsing Gateway.Limits.MaxRequestDuration: %s", err)
+			}
+		}
+
 		gateway := newGatewayHandler(n, GatewayConfig{
-			Headers:      cfg.Gateway.HTTPHeaders,
-			Writable:     writable,
-			PathPrefixes: cfg.Gateway.PathPrefixes,
+			Headers:            cfg.Gateway.HTTPHeaders,
+			Writable:           writable,
+			PathPrefixes:       cfg.Gateway.PathPrefixes,
+			MaxRequestBlocks:   cfg.Gateway.Limits.MaxRequestBlocks,
+			MaxRequestDuration: maxDuration,
+			WriteTokens:        cfg.Gateway.WriteTokens,
+			Archives:           cfg.Gateway.Archives,
 		}, coreapi.NewCoreAPI(n))
 
 		for _, p := range paths {
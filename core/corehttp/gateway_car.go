@@ -0,0 +1,127 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	car "github.com/ipfs/go-ipfs/car"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// The content types of the verifiable responses of the gateway, which clients
+// can check against the CID requested instead of trusting the gateway.
+const (
+	carContentType = "application/vnd.ipld.car"
+	rawContentType = "application/vnd.ipld.raw"
+)
+
+// verifiableFormat returns the verifiable response format r asks for, "car"
+// or "raw", with ?format= or the Accept header, or "" for the usual response.
+func verifiableFormat(r *http.Request) string {
+	switch format := r.URL.Query().Get("format"); format {
+	case "car", "raw":
+		return format
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case carContentType:
+			return "car"
+		case rawContentType:
+			return "raw"
+		}
+	}
+	return ""
+}
+
+// serveVerifiable writes the block c, for the raw format, or a CAR file of the
+// DAG below c, for the car format, as an attachment.
+func (i *gatewayHandler) serveVerifiable(ctx context.Context, w http.ResponseWriter, r *http.Request, c *cid.Cid, format string) {
+	etag := "\"" + c.String() + "." + format + "\""
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	i.addUserHeaders(w)
+	w.Header().Set("Etag", etag)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Vary", "Accept")
+	if strings.HasPrefix(r.URL.Path, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	}
+
+	if format == "raw" {
+		blk, err := i.node.Blocks.GetBlock(ctx, c)
+		if err != nil {
+			webError(w, "ipfs block get "+c.String(), err, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", rawContentType)
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+c.String()+".bin\"")
+		if r.Method == "HEAD" {
+			return
+		}
+		if _, err := w.Write(blk.RawData()); err != nil {
+			log.Debugf("writing block %s: %s", c, err)
+		}
+		return
+	}
+
+	// fail before writing anything if the root is missing
+	if _, err := i.node.DAG.Get(ctx, c); err != nil {
+		webError(w, "ipfs dag get "+c.String(), err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", carContentType+"; version=1")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+c.String()+".car\"")
+	if r.Method == "HEAD" {
+		return
+	}
+	// the blocks are streamed as they are read, so errors past the first
+	// block can only end the response early; clients detect it as the CAR
+	// file then misses blocks
+	if err := car.ExportDAG(ctx, i.node.DAG, []*cid.Cid{c}, w, nil); err != nil {
+		log.Debugf("writing the CAR of %s: %s", c, err)
+	}
+}
+
+// postCARHandler imports the blocks of the CAR file posted, pins its root and
+// redirects to it.
+func (i *gatewayHandler) postCARHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	h, err := car.Import(ctx, i.node.Blocks, r.Body, nil)
+	if err != nil {
+		webError(w, "importing the CAR file", err, http.StatusBadRequest)
+		return
+	}
+	if len(h.Roots) != 1 {
+		webError(w, "importing the CAR file", errors.New("expected a single root"), http.StatusBadRequest)
+		return
+	}
+	root := h.Roots[0]
+
+	nd, err := i.node.DAG.Get(ctx, root)
+	if err != nil {
+		webError(w, "ipfs dag get "+root.String(), err, http.StatusBadRequest)
+		return
+	}
+	if err := i.node.Pinning.Pin(ctx, nd, true); err != nil {
+		webError(w, "pinning "+root.String(), err, http.StatusInternalServerError)
+		return
+	}
+	if err := i.node.Pinning.Flush(); err != nil {
+		internalWebError(w, err)
+		return
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("IPFS-Hash", root.String())
+	http.Redirect(w, r, ipfsPathPrefix+root.String(), http.StatusCreated)
+}
@@ -1,32 +1,45 @@
 package corehttp
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	gopath "path"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
+	blockservice "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	logs "github.com/ipfs/go-ipfs/core/logs"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	"github.com/ipfs/go-ipfs/importer"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 	path "github.com/ipfs/go-ipfs/path"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
+	tracing "github.com/ipfs/go-ipfs/tracing"
 	ft "github.com/ipfs/go-ipfs/unixfs"
+	uarchive "github.com/ipfs/go-ipfs/unixfs/archive"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	mimeindex "github.com/ipfs/go-ipfs/unixfs/mimeindex"
 
 	humanize "github.com/dustin/go-humanize"
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	chunker "github.com/ipfs/go-ipfs-chunker"
 	ipld "github.com/ipfs/go-ipld-format"
+	peer "github.com/libp2p/go-libp2p-peer"
 	routing "github.com/libp2p/go-libp2p-routing"
 	multibase "github.com/multiformats/go-multibase"
 )
@@ -36,6 +49,17 @@ const (
 	ipnsPathPrefix = "/ipns/"
 )
 
+// errNoWriteTokens is returned for the writes that need a write token on a
+// gateway configured without any.
+var errNoWriteTokens = errors.New("the gateway has no write tokens configured")
+
+// errArchivesDisabled is returned for archive downloads from a gateway
+// without Gateway.Archives.
+var errArchivesDisabled = errors.New("the gateway doesn't serve archives")
+
+// errArchiveAborted stops writing an archive nobody reads anymore.
+var errArchiveAborted = errors.New("archive download aborted")
+
 // gatewayHandler is a HTTP handler that serves IPFS objects (accessible by default at /ipfs/<path>)
 // (it serves requests like GET /ipfs/QmVRzPKPzNtSrEzBFm2UZfxmPAgnaLke4DMcerbsGGSaFe/link)
 type gatewayHandler struct {
@@ -64,10 +88,37 @@ func (i *gatewayHandler) newDagFromReader(r io.Reader) (ipld.Node, error) {
 
 // TODO(btc): break this apart into separate handlers using a more expressive muxer
 func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(i.node.Context(), time.Hour)
-	// the hour is a hard fallback, we don't expect it to happen, but just in case
+	timeout := i.config.MaxRequestDuration
+	if timeout <= 0 {
+		timeout = defaultMaxRequestDuration
+	}
+	ctx, cancel := context.WithTimeout(i.node.Context(), timeout)
+	// unless limited, the hour is a hard fallback, we don't expect it to
+	// happen, but just in case
 	defer cancel()
 
+	if i.config.MaxRequestBlocks > 0 {
+		ctx = blockservice.WithBudget(ctx, i.config.MaxRequestBlocks)
+	}
+
+	// someone is waiting on this page load, let it outrank background work
+	ctx = exchange.WithPriority(ctx, exchange.PriorityInteractive)
+
+	// let the log entries and spans of the request be found from its
+	// response
+	ctx, span := tracing.StartSpan(ctx, "Gateway.ServeHTTP")
+	defer span.Finish()
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.Path)
+	var trace string
+	if span != nil {
+		trace = span.TraceID()
+		ctx = logs.WithTraceID(ctx, trace)
+	} else {
+		ctx, trace = logs.WithTrace(ctx)
+	}
+	w.Header().Set("X-Ipfs-Trace", trace)
+
 	if cn, ok := w.(http.CloseNotifier); ok {
 		clientGone := cn.CloseNotify()
 		go func() {
@@ -88,12 +139,21 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	if i.config.Writable {
+		switch r.Method {
+		case "POST", "PUT", "DELETE":
+			if !i.writeAuthorized(r) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				webErrorWithCode(w, "writing through the gateway", errors.New("missing or invalid write token"), http.StatusUnauthorized)
+				return
+			}
+		}
+
 		switch r.Method {
 		case "POST":
 			i.postHandler(ctx, w, r)
 			return
 		case "PUT":
-			i.putHandler(w, r)
+			i.putHandler(ctx, w, r)
 			return
 		case "DELETE":
 			i.deleteHandler(w, r)
@@ -168,6 +228,12 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	// IPNS paths can be cached as long as the records they resolve through
+	var ipnsTTL func() (time.Duration, bool)
+	if strings.HasPrefix(urlPath, ipnsPathPrefix) {
+		ctx, ipnsTTL = namesys.ContextWithTTL(ctx)
+	}
+
 	// Resolve path to the final DAG node for the ETag
 	resolvedPath, err := i.api.ResolvePath(ctx, parsedPath)
 	switch err {
@@ -179,10 +245,19 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		}
 		fallthrough
 	default:
+		if i.serveRedirects(ctx, w, r, urlPath, prefix, ipnsHostname) {
+			return
+		}
 		webError(w, "ipfs resolve -r "+escapedURLPath, err, http.StatusNotFound)
 		return
 	}
 
+	// the block or DAG itself, for clients verifying what they get
+	if format := verifiableFormat(r); format != "" {
+		i.serveVerifiable(ctx, w, r, resolvedPath.Cid(), format)
+		return
+	}
+
 	dr, err := i.api.Unixfs().Cat(ctx, resolvedPath)
 	dir := false
 	switch err {
@@ -196,9 +271,25 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	// only cache what won't change: /ipfs files for good, and what /ipns
+	// paths resolve to for the TTL of their records
+	cacheControl := ""
+	switch {
+	case strings.HasPrefix(urlPath, ipfsPathPrefix) && !dir:
+		cacheControl = "public, max-age=29030400, immutable"
+	case ipnsTTL != nil:
+		if ttl, ok := ipnsTTL(); ok {
+			cacheControl = fmt.Sprintf("public, max-age=%d", int64(ttl/time.Second))
+		}
+	}
+
 	// Check etag send back to us
 	etag := "\"" + resolvedPath.Cid().String() + "\""
-	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("Etag", etag)
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
 		w.WriteHeader(http.StatusNotModified)
 		return
 	}
@@ -217,8 +308,9 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 
 	var allowedHeaders = strings.Join(allowedHeadersArr, ", ")
 
-	w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
-	w.Header().Set("Access-Control-Expose-Headers", allowedHeaders)
+	// Range is sent by clients seeking in media, which Accept-Ranges allows
+	w.Header().Set("Access-Control-Allow-Headers", allowedHeaders+", Range")
+	w.Header().Set("Access-Control-Expose-Headers", allowedHeaders+", Accept-Ranges")
 
 	// Suborigin header, sandboxes apps from each other in the browser (even
 	// though they are served from the same gateway domain).
@@ -259,16 +351,17 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	// TODO: break this out when we split /ipfs /ipns routes.
 	modtime := time.Now()
 
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
 	if strings.HasPrefix(urlPath, ipfsPathPrefix) && !dir {
-		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
-
 		// set modtime to a really long time ago, since files are immutable and should stay cached
 		modtime = time.Unix(1, 0)
 	}
 
 	if !dir {
 		name := gopath.Base(urlPath)
-		i.serveFile(w, r, name, modtime, dr)
+		i.serveFile(w, r, name, resolvedPath.Cid(), modtime, dr)
 		return
 	}
 
@@ -278,12 +371,29 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	// ?format=tar or ?format=zip downloads the directory as an archive
+	if format := r.URL.Query().Get("format"); format == "tar" || format == "zip" {
+		if !i.config.Archives {
+			webError(w, "downloading archives", errArchivesDisabled, http.StatusForbidden)
+			return
+		}
+		i.serveArchive(ctx, w, r, nd, gopath.Base(urlPath), format)
+		return
+	}
+
 	dirr, err := uio.NewDirectoryFromNode(i.node.DAG, nd)
 	if err != nil {
 		internalWebError(w, err)
 		return
 	}
 
+	// directories are listed in HTML or JSON depending on Accept
+	w.Header().Add("Vary", "Accept")
+	if wantsJSONListing(r) {
+		i.serveJSONListing(ctx, w, r, nd, dirr)
+		return
+	}
+
 	ixnd, err := dirr.Find(ctx, "index.html")
 	switch {
 	case err == nil:
@@ -303,7 +413,7 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		defer dr.Close()
 
 		// write to request
-		http.ServeContent(w, r, "index.html", modtime, dr)
+		i.serveFile(w, r, "index.html", ixnd.Cid(), modtime, dr)
 		return
 	default:
 		internalWebError(w, err)
@@ -387,17 +497,166 @@ func (s *sizeSeeker) Seek(offset int64, whence int) (int64, error) {
 	return s.sizeReadSeeker.Seek(offset, whence)
 }
 
-func (i *gatewayHandler) serveFile(w http.ResponseWriter, req *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+// serveArchive writes the directory nd as a tar or zip archive named name.
+func (i *gatewayHandler) serveArchive(ctx context.Context, w http.ResponseWriter, r *http.Request, nd ipld.Node, name, format string) {
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+	} else {
+		w.Header().Set("Content-Type", "application/x-tar")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", name, format))
+
+	// the archive is written as it is read, don't start writing it for
+	// nobody to read
+	if r.Method == "HEAD" {
+		return
+	}
+
+	var ar io.Reader
+	var err error
+	if format == "zip" {
+		ar, err = uarchive.DagZipArchive(ctx, nd, name, i.node.DAG, uio.WithFetchAhead(i.node.FetchAhead))
+	} else {
+		ar, err = uarchive.DagArchive(ctx, nd, name, i.node.DAG, true, gzip.NoCompression, uio.WithFetchAhead(i.node.FetchAhead))
+	}
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+	// stops the writing goroutine when the client goes away mid-archive
+	if pr, ok := ar.(*io.PipeReader); ok {
+		defer pr.CloseWithError(errArchiveAborted)
+	}
+
+	if _, err := io.Copy(w, ar); err != nil {
+		log.Debugf("writing archive of %s: %s", name, err)
+	}
+}
+
+// fetchEnder is implemented by the readers of files which can limit the blocks
+// they request ahead.
+type fetchEnder interface {
+	SetFetchEnd(end int64)
+}
+
+// serveFile writes the file content, with root c, handling conditional and
+// range requests: single ranges are answered with 206 Partial Content, several
+// ones with a multipart/byteranges body, and unsatisfiable ones with 416.
+func (i *gatewayHandler) serveFile(w http.ResponseWriter, req *http.Request, name string, c *cid.Cid, modtime time.Time, content io.ReadSeeker) {
+	fe, canLimit := content.(fetchEnder)
 	if sp, ok := content.(sizeReadSeeker); ok {
 		content = &sizeSeeker{
 			sizeReadSeeker: sp,
 		}
 	}
 
+	// without an extension to go by, ServeContent would sniff the content
+	// type every time, so use the one recorded in the index instead
+	if i.node.MimeIndex != nil && mime.TypeByExtension(gopath.Ext(name)) == "" {
+		ctype, err := i.contentType(c, content)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", ctype)
+	}
+
+	// only fetch the blocks of the ranges requested, not the ones following
+	if end, ok := rangesEnd(req.Header.Get("Range")); ok && canLimit {
+		fe.SetFetchEnd(end)
+	}
+
 	http.ServeContent(w, req, name, modtime, content)
 }
 
+// rangesEnd returns the offset following the last byte requested by the
+// Range header h, if all of its ranges have an end.
+func rangesEnd(h string) (int64, bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(h, prefix) {
+		return 0, false
+	}
+	var end int64
+	for _, ra := range strings.Split(h[len(prefix):], ",") {
+		ra = strings.TrimSpace(ra)
+		i := strings.Index(ra, "-")
+		if i <= 0 {
+			// suffix ranges end at the end of the file
+			return 0, false
+		}
+		last, err := strconv.ParseInt(strings.TrimSpace(ra[i+1:]), 10, 64)
+		if err != nil || last < 0 {
+			return 0, false
+		}
+		if last+1 > end {
+			end = last + 1
+		}
+	}
+	return end, true
+}
+
+// contentType returns the content type of the file with root c, detecting and
+// recording it if the index doesn't have it yet. content is left at its start.
+func (i *gatewayHandler) contentType(c *cid.Cid, content io.ReadSeeker) (string, error) {
+	if e, ok := i.node.MimeIndex.Get(c); ok {
+		return e.Type, nil
+	}
+
+	ctype, err := mimeindex.Sniff(content)
+	if err != nil {
+		return "", err
+	}
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if err := i.node.MimeIndex.Put(c, mimeindex.Entry{Type: ctype, Size: uint64(size)}); err != nil {
+		log.Warningf("recording the content type of %s: %s", c, err)
+	}
+	return ctype, nil
+}
+
+// writeAuthorized reports whether r carries one of the write tokens of the
+// gateway, if it has any.
+func (i *gatewayHandler) writeAuthorized(r *http.Request) bool {
+	if len(i.config.WriteTokens) == 0 {
+		return true
+	}
+	return i.hasWriteToken(r)
+}
+
+// hasWriteToken reports whether r carries one of the write tokens of the
+// gateway. Unlike writeAuthorized, it is false when the gateway has none:
+// the writes importing CAR files or updating IPNS names need a token.
+func (i *gatewayHandler) hasWriteToken(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(auth, prefix))
+	for _, t := range i.config.WriteTokens {
+		if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
 func (i *gatewayHandler) postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == carContentType {
+		if !i.hasWriteToken(r) {
+			webError(w, "importing the CAR file", errNoWriteTokens, http.StatusForbidden)
+			return
+		}
+		i.postCARHandler(ctx, w, r)
+		return
+	}
+
 	p, err := i.api.Unixfs().Add(ctx, r.Body)
 	if err != nil {
 		internalWebError(w, err)
@@ -409,11 +668,7 @@ func (i *gatewayHandler) postHandler(ctx context.Context, w http.ResponseWriter,
 	http.Redirect(w, r, p.String(), http.StatusCreated)
 }
 
-func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO(cryptix): move me to ServeHTTP and pass into all handlers
-	ctx, cancel := context.WithCancel(i.node.Context())
-	defer cancel()
-
+func (i *gatewayHandler) putHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	rootPath, err := path.ParsePath(r.URL.Path)
 	if err != nil {
 		webError(w, "putHandler: IPFS path not valid", err, http.StatusBadRequest)
@@ -421,11 +676,118 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rsegs := rootPath.Segments()
-	if rsegs[0] == ipnsPathPrefix {
-		webError(w, "putHandler: updating named entries not supported", errors.New("WritableGateway: ipns put not supported"), http.StatusBadRequest)
+	if rsegs[0] == "ipns" {
+		if !i.hasWriteToken(r) {
+			webError(w, "putHandler: updating named entries", errNoWriteTokens, http.StatusForbidden)
+			return
+		}
+		i.putIPNSHandler(ctx, w, r, rsegs)
+		return
+	}
+
+	newcid, newPath, ok := i.putNode(ctx, w, r, rootPath, rsegs)
+	if !ok {
+		return
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("IPFS-Hash", newcid.String())
+	http.Redirect(w, r, gopath.Join(ipfsPathPrefix, newcid.String(), newPath), http.StatusCreated)
+}
+
+// putIPNSHandler writes the request body at the path under an IPNS name like
+// putHandler does under /ipfs, and publishes the new root of the name. Only
+// the names of the keys of the node can be updated; the ones never published
+// start out as empty directories. When the name can't be resolved, the record
+// last published by the node is updated.
+func (i *gatewayHandler) putIPNSHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, rsegs []string) {
+	name := rsegs[1]
+	key, err := i.nameKey(ctx, name)
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+	if key == "" {
+		webError(w, "putHandler: updating named entries", fmt.Errorf("%s isn't the name of a key of this node", name), http.StatusForbidden)
+		return
+	}
+
+	resolved, err := i.node.Namesys.Resolve(ctx, ipnsPathPrefix+name)
+	if err == namesys.ErrResolveFailed {
+		var id peer.ID
+		id, err = peer.IDB58Decode(name)
+		if err != nil {
+			webError(w, "putHandler: updating named entries", err, http.StatusBadRequest)
+			return
+		}
+		resolved, err = namesys.GetPublished(i.node.Repo.Datastore(), id)
+	}
+
+	var root string
+	switch err {
+	case nil:
+		segs := resolved.Segments()
+		if len(segs) != 2 || segs[0] != "ipfs" {
+			webError(w, "putHandler: updating named entries", fmt.Errorf("%s points into %s instead of at a root", name, resolved), http.StatusConflict)
+			return
+		}
+		root = segs[1]
+	case ds.ErrNotFound:
+		empty := ft.EmptyDirNode()
+		if err := i.node.DAG.Add(ctx, empty); err != nil {
+			internalWebError(w, err)
+			return
+		}
+		root = empty.Cid().String()
+	default:
+		webError(w, "putHandler: could not resolve "+name, err, http.StatusInternalServerError)
+		return
+	}
+
+	rootPath, err := path.FromSegments(ipfsPathPrefix, append([]string{root}, rsegs[2:]...)...)
+	if err != nil {
+		webError(w, "putHandler: IPFS path not valid", err, http.StatusBadRequest)
+		return
+	}
+	newcid, newPath, ok := i.putNode(ctx, w, r, rootPath, rootPath.Segments())
+	if !ok {
+		return
+	}
+
+	newRoot, err := coreapi.ParsePath(ipfsPathPrefix + newcid.String())
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+	if _, err := i.api.Name().Publish(ctx, newRoot, i.api.Name().WithKey(key)); err != nil {
+		webError(w, "putHandler: could not publish "+name, err, http.StatusInternalServerError)
 		return
 	}
 
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("IPFS-Hash", newcid.String())
+	http.Redirect(w, r, gopath.Join(ipnsPathPrefix, name, newPath), http.StatusCreated)
+}
+
+// nameKey returns the name of the key of the node publishing the IPNS name,
+// or "" if there is none.
+func (i *gatewayHandler) nameKey(ctx context.Context, name string) (string, error) {
+	keys, err := i.api.Key().List(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range keys {
+		if k.Path().String() == ipnsPathPrefix+name {
+			return k.Name(), nil
+		}
+	}
+	return "", nil
+}
+
+// putNode writes the request body at rootPath, with segments rsegs, and
+// returns the new root and the path written under it. It writes the error
+// and returns false if it fails.
+func (i *gatewayHandler) putNode(ctx context.Context, w http.ResponseWriter, r *http.Request, rootPath path.Path, rsegs []string) (*cid.Cid, string, bool) {
 	var newnode ipld.Node
 	if rsegs[len(rsegs)-1] == "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn" {
 		newnode = ft.EmptyDirNode()
@@ -433,7 +795,7 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 		putNode, err := i.newDagFromReader(r.Body)
 		if err != nil {
 			webError(w, "putHandler: Could not create DAG from request", err, http.StatusInternalServerError)
-			return
+			return nil, "", false
 		}
 		newnode = putNode
 	}
@@ -453,32 +815,32 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 		c, err := cid.Decode(rsegs[1])
 		if err != nil {
 			webError(w, "putHandler: bad input path", err, http.StatusBadRequest)
-			return
+			return nil, "", false
 		}
 
 		rnode, err := i.node.DAG.Get(ctx, c)
 		if err != nil {
 			webError(w, "putHandler: Could not create DAG from request", err, http.StatusInternalServerError)
-			return
+			return nil, "", false
 		}
 
 		pbnd, ok := rnode.(*dag.ProtoNode)
 		if !ok {
 			webError(w, "Cannot read non protobuf nodes through gateway", dag.ErrNotProtobuf, http.StatusBadRequest)
-			return
+			return nil, "", false
 		}
 
 		e := dagutils.NewDagEditor(pbnd, i.node.DAG)
 		err = e.InsertNodeAtPath(ctx, newPath, newnode, ft.EmptyDirNode)
 		if err != nil {
 			webError(w, "putHandler: InsertNodeAtPath failed", err, http.StatusInternalServerError)
-			return
+			return nil, "", false
 		}
 
 		nnode, err := e.Finalize(ctx, i.node.DAG)
 		if err != nil {
 			webError(w, "putHandler: could not get node", err, http.StatusInternalServerError)
-			return
+			return nil, "", false
 		}
 
 		newcid = nnode.Cid()
@@ -487,13 +849,13 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 		pbnd, ok := rnode.(*dag.ProtoNode)
 		if !ok {
 			webError(w, "Cannot read non protobuf nodes through gateway", dag.ErrNotProtobuf, http.StatusBadRequest)
-			return
+			return nil, "", false
 		}
 
 		pbnewnode, ok := newnode.(*dag.ProtoNode)
 		if !ok {
 			webError(w, "Cannot read non protobuf nodes through gateway", dag.ErrNotProtobuf, http.StatusBadRequest)
-			return
+			return nil, "", false
 		}
 
 		// object set-data case
@@ -504,16 +866,14 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			nnk := newnode.Cid()
 			webError(w, fmt.Sprintf("putHandler: Could not add newnode(%q) to root(%q)", nnk.String(), newcid.String()), err, http.StatusInternalServerError)
-			return
+			return nil, "", false
 		}
 	default:
 		webError(w, "could not resolve root DAG", ev, http.StatusInternalServerError)
-		return
+		return nil, "", false
 	}
 
-	i.addUserHeaders(w) // ok, _now_ write user's headers.
-	w.Header().Set("IPFS-Hash", newcid.String())
-	http.Redirect(w, r, gopath.Join(ipfsPathPrefix, newcid.String(), newPath), http.StatusCreated)
+	return newcid, newPath, true
 }
 
 func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
@@ -606,6 +966,9 @@ func webError(w http.ResponseWriter, message string, err error, defaultCode int)
 		webErrorWithCode(w, message, err, http.StatusNotFound)
 	} else if err == context.DeadlineExceeded {
 		webErrorWithCode(w, message, err, http.StatusRequestTimeout)
+	} else if err == blockservice.ErrBudgetExceeded {
+		// the request reads more of the DAG than a request may
+		webErrorWithCode(w, message, err, http.StatusRequestEntityTooLarge)
 	} else {
 		webErrorWithCode(w, message, err, defaultCode)
 	}
@@ -624,3 +987,15 @@ func webErrorWithCode(w http.ResponseWriter, message string, err error, code int
 func internalWebError(w http.ResponseWriter, err error) {
 	webErrorWithCode(w, "internalWebError", err, http.StatusInternalServerError)
 }
+
+// etagMatch reports whether the If-None-Match header h matches etag: whether
+// it lists etag, strong or weak, or is *.
+func etagMatch(h, etag string) bool {
+	for _, t := range strings.Split(h, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	tokenbucket "github.com/ipfs/go-ipfs/thirdparty/tokenbucket"
+
+	humanize "github.com/dustin/go-humanize"
+	metrics "github.com/ipfs/go-metrics-interface"
+)
+
+// defaultMaxRequestDuration is how long a gateway request may take without
+// Gateway.Limits.MaxRequestDuration.
+const defaultMaxRequestDuration = time.Hour
+
+// clientSweepPeriod is how often the request rates of the clients which are
+// back to their full burst are forgotten.
+const clientSweepPeriod = time.Minute
+
+// throttleChunk is the most bytes of a response written at once under the
+// bandwidth limit, so that responses share it smoothly.
+const throttleChunk = 32 << 10
+
+// GatewayLimitsOption enforces the Gateway.Limits of the config on the
+// requests to the handlers registered after it, so that a public gateway
+// survives abuse: clients over their request rate get 429, requests over the
+// concurrency cap get 503, and all responses share the bandwidth limit.
+func GatewayLimitsOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		l, err := newGatewayLimiter(n.Context(), cfg.Gateway.Limits)
+		if err != nil {
+			return nil, err
+		}
+
+		childMux := http.NewServeMux()
+		mux.Handle("/", l.wrap(childMux))
+		return childMux, nil
+	}
+}
+
+// gatewayLimiter enforces GatewayLimits on the requests of a listener.
+type gatewayLimiter struct {
+	clientRate  int64 // requests per minute
+	clientBurst int64
+
+	lk        sync.Mutex
+	clients   map[string]*tokenbucket.Bucket
+	lastSweep time.Time
+	bandwidth *tokenbucket.Bucket
+
+	// slots holds a token per request being served, if they are capped
+	slots chan struct{}
+
+	inflight           metrics.Gauge
+	rateLimited        metrics.Counter
+	concurrencyLimited metrics.Counter
+	throttledBytes     metrics.Counter
+
+	now func() time.Time
+}
+
+func newGatewayLimiter(ctx context.Context, limits config.GatewayLimits) (*gatewayLimiter, error) {
+	ctx = metrics.CtxSubScope(ctx, "gateway")
+	l := &gatewayLimiter{
+		clientRate:  int64(limits.ClientRequestsPerMinute),
+		clientBurst: int64(limits.ClientRequestBurst),
+		clients:     make(map[string]*tokenbucket.Bucket),
+
+		inflight: metrics.NewCtx(ctx, "requests_inflight",
+			"Number of requests being served").Gauge(),
+		rateLimited: metrics.NewCtx(ctx, "rate_limited_total",
+			"Number of requests refused for exceeding the request rate of their client").Counter(),
+		concurrencyLimited: metrics.NewCtx(ctx, "concurrency_limited_total",
+			"Number of requests refused for exceeding the concurrent requests cap").Counter(),
+		throttledBytes: metrics.NewCtx(ctx, "throttled_bytes_total",
+			"Number of bytes written under the bandwidth limit").Counter(),
+
+		now: time.Now,
+	}
+	l.lastSweep = l.now()
+
+	if limits.MaxConcurrentRequests > 0 {
+		l.slots = make(chan struct{}, limits.MaxConcurrentRequests)
+	}
+
+	if limits.BandwidthLimit != "" {
+		rate, err := humanize.ParseBytes(limits.BandwidthLimit)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Gateway.Limits.BandwidthLimit: %s", err)
+		}
+		var burst uint64
+		if limits.BandwidthBurst != "" {
+			burst, err = humanize.ParseBytes(limits.BandwidthBurst)
+			if err != nil {
+				return nil, fmt.Errorf("parsing Gateway.Limits.BandwidthBurst: %s", err)
+			}
+		}
+		if rate > 0 {
+			l.bandwidth = tokenbucket.New(float64(rate), float64(burst), l.now())
+		}
+	}
+	return l, nil
+}
+
+func (l *gatewayLimiter) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait, ok := l.allowClient(clientIP(r)); !ok {
+			l.rateLimited.Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		if l.slots != nil {
+			select {
+			case l.slots <- struct{}{}:
+				defer func() { <-l.slots }()
+			default:
+				l.concurrencyLimited.Inc()
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		l.inflight.Inc()
+		defer l.inflight.Dec()
+
+		if l.bandwidth != nil {
+			w = &throttledWriter{ResponseWriter: w, l: l, ctx: r.Context()}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// allowClient takes a request from the rate of the client ip, or returns how
+// long until it can make one.
+func (l *gatewayLimiter) allowClient(ip string) (time.Duration, bool) {
+	if l.clientRate <= 0 {
+		return 0, true
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= clientSweepPeriod {
+		for ip, b := range l.clients {
+			if b.Full(now) {
+				delete(l.clients, ip)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.clients[ip]
+	if !ok {
+		burst := l.clientBurst
+		if burst <= 0 {
+			burst = l.clientRate
+		}
+		b = tokenbucket.New(float64(l.clientRate)/60, float64(burst), now)
+		l.clients[ip] = b
+	}
+	return b.Allow(now)
+}
+
+// reserve accounts for n bytes written and returns how long to wait before
+// writing them.
+func (l *gatewayLimiter) reserve(n int) time.Duration {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.bandwidth.Take(n, l.now())
+}
+
+// clientIP returns the IP address the request r comes from.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// throttledWriter writes a response under the bandwidth limit.
+type throttledWriter struct {
+	http.ResponseWriter
+	l   *gatewayLimiter
+	ctx context.Context
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > throttleChunk {
+			chunk = chunk[:throttleChunk]
+		}
+
+		if d := w.l.reserve(len(chunk)); d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-t.C:
+			case <-w.ctx.Done():
+				t.Stop()
+				return written, w.ctx.Err()
+			}
+		}
+
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		w.l.throttledBytes.Add(float64(n))
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// CloseNotify lets handlers wrapped by the limiter notice clients going away.
+func (w *throttledWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+func (w *throttledWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,142 @@
+package corehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	unixfspb "github.com/ipfs/go-ipfs/unixfs/pb"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// defaultListingLimit is the number of entries of the JSON listings of
+// directories without a limit, and maxListingLimit the largest one allowed.
+const (
+	defaultListingLimit = 1000
+	maxListingLimit     = 10000
+)
+
+// errListingLimit interrupts the listing of a directory once a page is full.
+var errListingLimit = errors.New("limit reached")
+
+// jsonListing is the JSON listing of a directory.
+type jsonListing struct {
+	Hash    string
+	Entries []jsonListingEntry
+
+	// Next is the name to pass as after to list the following entries, if
+	// there are any.
+	Next string `json:",omitempty"`
+}
+
+type jsonListingEntry struct {
+	Name string
+	Hash string
+	Size uint64
+	Type string `json:",omitempty"` // file, directory or symlink, if known
+}
+
+// wantsJSONListing reports whether r asks for the listing of a directory in
+// JSON, with ?format=json or by accepting application/json over HTML.
+func wantsJSONListing(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(accept)
+	return err == nil && mt == "application/json"
+}
+
+// serveJSONListing writes the entries of the directory dir with root nd
+// following the one named by the after parameter, at most limit of them.
+func (i *gatewayHandler) serveJSONListing(ctx context.Context, w http.ResponseWriter, r *http.Request, nd ipld.Node, dir *uio.Directory) {
+	limit := defaultListingLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > maxListingLimit {
+			webError(w, "invalid limit", fmt.Errorf("the limit must be between 1 and %d", maxListingLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	// list one more entry than the limit to know whether there are more
+	var links []*ipld.Link
+	err := dir.ForEachLinkAfter(ctx, r.URL.Query().Get("after"), func(l *ipld.Link) error {
+		if len(links) == limit+1 {
+			return errListingLimit
+		}
+		links = append(links, l)
+		return nil
+	})
+	if err != nil && err != errListingLimit {
+		internalWebError(w, err)
+		return
+	}
+
+	out := jsonListing{Hash: nd.Cid().String(), Entries: []jsonListingEntry{}}
+	if len(links) > limit {
+		links = links[:limit]
+		out.Next = links[limit-1].Name
+	}
+
+	cids := make([]*cid.Cid, len(links))
+	for j, l := range links {
+		cids[j] = l.Cid
+	}
+	for j, p := range ipld.GetNodes(ctx, i.node.DAG, cids) {
+		child, err := p.Get(ctx)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+		out.Entries = append(out.Entries, jsonListingEntry{
+			Name: links[j].Name,
+			Hash: links[j].Cid.String(),
+			Size: links[j].Size,
+			Type: unixfsType(child),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == "HEAD" {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Debugf("writing the listing of %s: %s", nd.Cid(), err)
+	}
+}
+
+// unixfsType returns the kind of unixfs node nd is, or "" if it isn't one.
+func unixfsType(nd ipld.Node) string {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return "file"
+	case *dag.ProtoNode:
+		fsn, err := ft.FromBytes(nd.Data())
+		if err != nil {
+			return ""
+		}
+		switch fsn.GetType() {
+		case unixfspb.Data_File, unixfspb.Data_Raw:
+			return "file"
+		case unixfspb.Data_Directory, unixfspb.Data_HAMTShard:
+			return "directory"
+		case unixfspb.Data_Symlink:
+			return "symlink"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package corehttp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	gopath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+// maxRedirectsSize is the size of the largest _redirects file applied.
+const maxRedirectsSize = 64 << 10
+
+// redirectRule is a rule of the _redirects file at the root of a site: the
+// requests for paths matching from, which can hold :placeholder segments and
+// end with a * splat, are redirected to to with status, or answered with the
+// file at to for the 200 and 404 status. :placeholder and :splat are replaced
+// in to by the parts of the path they matched.
+type redirectRule struct {
+	from   string
+	to     string
+	status int
+}
+
+// parseRedirects parses the rules of a _redirects file: one rule per line,
+// as "from to [status]", with the status defaulting to 301. Empty lines and
+// lines starting with # are ignored.
+func parseRedirects(r io.Reader) ([]redirectRule, error) {
+	var rules []redirectRule
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("_redirects line %d: expected 'from to [status]'", n)
+		}
+		rule := redirectRule{from: fields[0], to: fields[1], status: http.StatusMovedPermanently}
+		if !strings.HasPrefix(rule.from, "/") {
+			return nil, fmt.Errorf("_redirects line %d: %s is not an absolute path", n, rule.from)
+		}
+		if len(fields) == 3 {
+			status, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("_redirects line %d: invalid status %s", n, fields[2])
+			}
+			rule.status = status
+		}
+
+		switch rule.status {
+		case http.StatusOK, http.StatusNotFound:
+			if !strings.HasPrefix(rule.to, "/") {
+				return nil, fmt.Errorf("_redirects line %d: only paths of the site can be served", n)
+			}
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return nil, fmt.Errorf("_redirects line %d: unsupported status %d", n, rule.status)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, s.Err()
+}
+
+// match returns the target of the rule for the path p of the site, if p
+// matches it.
+func (rule redirectRule) match(p string) (string, bool) {
+	from := strings.Split(strings.Trim(rule.from, "/"), "/")
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+
+	to := rule.to
+	for i, f := range from {
+		if f == "*" && i == len(from)-1 {
+			splat := strings.Join(segs[i:], "/")
+			return strings.Replace(to, ":splat", splat, -1), true
+		}
+		if i >= len(segs) {
+			return "", false
+		}
+		switch {
+		case strings.HasPrefix(f, ":") && len(f) > 1:
+			to = strings.Replace(to, f, segs[i], -1)
+		case f != segs[i]:
+			return "", false
+		}
+	}
+	if len(segs) != len(from) {
+		return "", false
+	}
+	return to, true
+}
+
+// serveRedirects answers the request for urlPath, which wasn't found, with the
+// first matching rule of the _redirects file at the root of its site, or with
+// the 404.html file there. It returns false if neither applies, so the
+// request fails as usual. Redirects to paths of the site are relative to
+// prefix, and to the root of the site unless the site is the host.
+func (i *gatewayHandler) serveRedirects(ctx context.Context, w http.ResponseWriter, r *http.Request, urlPath, prefix string, ipnsHostname bool) bool {
+	// the site is the /ipfs/<hash> or /ipns/<name> the path starts with
+	segs := path.SplitList(urlPath)
+	if len(segs) < 3 || segs[0] != "" {
+		return false
+	}
+	root := "/" + gopath.Join(segs[1], segs[2])
+	sitePath := "/" + strings.Join(segs[3:], "/")
+
+	rules, err := i.siteRedirects(ctx, root)
+	if err != nil {
+		log.Debugf("reading the _redirects of %s: %s", root, err)
+	}
+	for _, rule := range rules {
+		to, ok := rule.match(sitePath)
+		if !ok {
+			continue
+		}
+		switch rule.status {
+		case http.StatusOK, http.StatusNotFound:
+			if i.serveSiteFile(ctx, w, r, root+to, rule.status) {
+				return true
+			}
+		default:
+			if strings.HasPrefix(to, "/") {
+				if ipnsHostname {
+					to = prefix + to
+				} else {
+					to = prefix + root + to
+				}
+			}
+			http.Redirect(w, r, to, rule.status)
+			return true
+		}
+	}
+
+	return i.serveSiteFile(ctx, w, r, root+"/404.html", http.StatusNotFound)
+}
+
+// siteRedirects returns the rules of the _redirects file of the site at root,
+// if it has one.
+func (i *gatewayHandler) siteRedirects(ctx context.Context, root string) ([]redirectRule, error) {
+	p, err := coreapi.ParsePath(root + "/_redirects")
+	if err != nil {
+		return nil, err
+	}
+	dr, err := i.api.Unixfs().Cat(ctx, p)
+	if err != nil {
+		// no _redirects
+		return nil, nil
+	}
+	defer dr.Close()
+	return parseRedirects(io.LimitReader(dr, maxRedirectsSize))
+}
+
+// serveSiteFile answers the request with the file at the path p, with status.
+// It returns false if there is no such file.
+func (i *gatewayHandler) serveSiteFile(ctx context.Context, w http.ResponseWriter, r *http.Request, p string, status int) bool {
+	parsed, err := coreapi.ParsePath(p)
+	if err != nil {
+		return false
+	}
+	resolved, err := i.api.ResolvePath(ctx, parsed)
+	if err != nil {
+		return false
+	}
+	dr, err := i.api.Unixfs().Cat(ctx, resolved)
+	if err != nil {
+		return false
+	}
+	defer dr.Close()
+
+	i.addUserHeaders(w)
+	if status == http.StatusOK {
+		// rewrites are served like the file itself, ranges included
+		w.Header().Set("Etag", "\""+resolved.Cid().String()+"\"")
+		i.serveFile(w, r, gopath.Base(p), resolved.Cid(), time.Time{}, dr)
+		return true
+	}
+
+	ctype := mime.TypeByExtension(gopath.Ext(p))
+	if ctype == "" {
+		ctype = "text/html; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.WriteHeader(status)
+	if r.Method != "HEAD" {
+		if _, err := io.Copy(w, dr); err != nil {
+			log.Debugf("writing %s: %s", p, err)
+		}
+	}
+	return true
+}
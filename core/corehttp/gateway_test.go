@@ -1,8 +1,12 @@
 package corehttp
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	car "github.com/ipfs/go-ipfs/car"
 	core "github.com/ipfs/go-ipfs/core"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -18,7 +23,11 @@ import (
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
 	ci "github.com/libp2p/go-libp2p-crypto"
 	id "github.com/libp2p/go-libp2p/p2p/protocol/identify"
 )
@@ -52,6 +61,24 @@ func (m mockNamesys) GetResolver(subs string) (namesys.Resolver, bool) {
 	return nil, false
 }
 
+// ttlNamesys is a mockNamesys whose records have a TTL.
+type ttlNamesys struct {
+	mockNamesys
+	ttl time.Duration
+}
+
+func (m ttlNamesys) Resolve(ctx context.Context, name string) (value path.Path, err error) {
+	return m.ResolveN(ctx, name, namesys.DefaultDepthLimit)
+}
+
+func (m ttlNamesys) ResolveN(ctx context.Context, name string, depth int) (value path.Path, err error) {
+	p, err := m.mockNamesys.ResolveN(ctx, name, depth)
+	if err == nil {
+		namesys.RecordTTL(ctx, m.ttl)
+	}
+	return p, err
+}
+
 func newNodeWithMockNamesys(ns mockNamesys) (*core.IpfsNode, error) {
 	c := config.Config{
 		Identity: config.Identity{
@@ -552,3 +579,641 @@ func TestVersion(t *testing.T) {
 		t.Fatalf("response doesn't contain protocol version:\n%s", s)
 	}
 }
+
+func TestGatewayRange(t *testing.T) {
+	ts, n := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		rng    string
+		status int
+		ctype  string
+		text   string
+	}{
+		{"bytes=1-3", http.StatusPartialContent, "", "nor"},
+		{"bytes=2-", http.StatusPartialContent, "", "ord"},
+		{"bytes=-2", http.StatusPartialContent, "", "rd"},
+		{"bytes=0-0,3-4", http.StatusPartialContent, "multipart/byteranges", ""},
+		{"bytes=10-20", http.StatusRequestedRangeNotSatisfiable, "", ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+"/ipfs/"+k, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Range", test.rng)
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.rng, test.status, res.StatusCode)
+			continue
+		}
+		if test.ctype != "" && !strings.HasPrefix(res.Header.Get("Content-Type"), test.ctype) {
+			t.Errorf("%s: expected a %s response, got %s", test.rng, test.ctype, res.Header.Get("Content-Type"))
+		}
+		if test.text != "" && string(body) != test.text {
+			t.Errorf("%s: expected %q, got %q", test.rng, test.text, body)
+		}
+	}
+}
+
+func TestRangesEnd(t *testing.T) {
+	for h, end := range map[string]int64{
+		"bytes=0-99":         100,
+		"bytes=0-0, 500-999": 1000,
+		"bytes=100-":         -1,
+		"bytes=-100":         -1,
+		"items=0-1":          -1,
+		"":                   -1,
+	} {
+		out, ok := rangesEnd(h)
+		if (end < 0 && ok) || (end >= 0 && (!ok || out != end)) {
+			t.Errorf("%q: expected %d, got %d (%t)", h, end, out, ok)
+		}
+	}
+}
+
+func TestGatewayJSONListing(t *testing.T) {
+	ts, n := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	ctx := context.Background()
+	dir := uio.NewDirectory(n.DAG)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := dir.AddChild(ctx, name, ft.EmptyDirNode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := dag.NodeWithData(ft.FilePBData([]byte("fnord"), 5))
+	if err := n.DAG.Add(ctx, file); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.AddChild(ctx, "d", file); err != nil {
+		t.Fatal(err)
+	}
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.DAG.AddMany(ctx, []ipld.Node{nd, ft.EmptyDirNode()}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(query, accept string) jsonListing {
+		req, err := http.NewRequest("GET", ts.URL+"/ipfs/"+nd.Cid().String()+"/"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d", query, res.StatusCode)
+		}
+		var out jsonListing
+		if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	out := list("", "application/json")
+	if out.Hash != nd.Cid().String() || len(out.Entries) != 4 || out.Next != "" {
+		t.Fatalf("unexpected listing %v", out)
+	}
+	if out.Entries[0].Type != "directory" || out.Entries[3].Type != "file" || out.Entries[3].Size == 0 {
+		t.Fatalf("unexpected entries %v", out.Entries)
+	}
+
+	out = list("?format=json&limit=2", "")
+	if len(out.Entries) != 2 || out.Next != "b" {
+		t.Fatalf("unexpected first page %v", out)
+	}
+	out = list("?format=json&limit=2&after="+out.Next, "")
+	if len(out.Entries) != 2 || out.Entries[0].Name != "c" || out.Next != "" {
+		t.Fatalf("unexpected second page %v", out)
+	}
+}
+
+func TestGatewayArchive(t *testing.T) {
+	n, err := newNodeWithMockNamesys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	dir := uio.NewDirectory(n.DAG)
+	file := dag.NodeWithData(ft.FilePBData([]byte("fnord"), 5))
+	if err := dir.AddChild(ctx, "file", file); err != nil {
+		t.Fatal(err)
+	}
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.DAG.AddMany(ctx, []ipld.Node{nd, file}); err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, GatewayOption(false, "/ipfs", "/ipns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+"/ipfs/"+nd.Cid().String()+"?format=tar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := do("GET")
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected archives to be refused by default, got %d", res.StatusCode)
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.Archives = true
+	dh.Handler, err = makeHandler(n, ts.Listener, GatewayOption(false, "/ipfs", "/ipns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res = do("HEAD")
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/x-tar" {
+		t.Fatalf("unexpected HEAD response: %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	res = do("GET")
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", res.StatusCode)
+	}
+	tr := tar.NewReader(res.Body)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if len(names) != 2 || !strings.HasSuffix(names[1], "/file") {
+		t.Fatalf("unexpected archive entries %v", names)
+	}
+}
+
+func TestGatewayVerifiable(t *testing.T) {
+	ts, n := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	ctx := context.Background()
+	dir := uio.NewDirectory(n.DAG)
+	file := dag.NodeWithData(ft.FilePBData([]byte("fnord"), 5))
+	if err := dir.AddChild(ctx, "file", file); err != nil {
+		t.Fatal(err)
+	}
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.DAG.AddMany(ctx, []ipld.Node{nd, file}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(p, query, accept, ctype string) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+p+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s%s: unexpected status %d", p, query, res.StatusCode)
+		}
+		if !strings.HasPrefix(res.Header.Get("Content-Type"), ctype) {
+			t.Fatalf("%s%s: expected %s, got %s", p, query, ctype, res.Header.Get("Content-Type"))
+		}
+		return res
+	}
+
+	// the block of the file, resolved through the directory
+	res := get("/ipfs/"+nd.Cid().String()+"/file", "?format=raw", "", rawContentType)
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != string(file.RawData()) {
+		t.Fatal("unexpected block data")
+	}
+
+	// the whole directory, which is checked by the CAR reader
+	res = get("/ipfs/"+nd.Cid().String(), "", carContentType, carContentType)
+	defer res.Body.Close()
+	cr, err := car.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := cr.Header().Roots; len(roots) != 1 || !roots[0].Equals(nd.Cid()) {
+		t.Fatalf("unexpected roots %v", roots)
+	}
+	count := 0
+	for {
+		_, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 blocks, got %d", count)
+	}
+}
+
+func TestRedirectRules(t *testing.T) {
+	rules, err := parseRedirects(strings.NewReader(`
+# comment
+/old/:year/:name /new/:name/:year
+/docs/* /manual/:splat 302
+/app/* /index.html 200
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[0].status != http.StatusMovedPermanently || rules[2].status != http.StatusOK {
+		t.Fatalf("unexpected rules %v", rules)
+	}
+
+	for _, test := range []struct {
+		rule int
+		path string
+		to   string
+	}{
+		{0, "/old/2018/post", "/new/post/2018"},
+		{0, "/old/2018", ""},
+		{0, "/old/2018/post/more", ""},
+		{1, "/docs", "/manual/"},
+		{1, "/docs/a/b", "/manual/a/b"},
+		{1, "/doc/a", ""},
+		{2, "/app/route/1", "/index.html"},
+	} {
+		to, ok := rules[test.rule].match(test.path)
+		if ok != (test.to != "") || to != test.to {
+			t.Errorf("%s: expected %q, got %q (%t)", test.path, test.to, to, ok)
+		}
+	}
+
+	for _, bad := range []string{"/a", "a /b", "/a /b 500", "/a https://example.com 200"} {
+		if _, err := parseRedirects(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestGatewayRedirects(t *testing.T) {
+	ts, n := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	ctx := context.Background()
+	dir := uio.NewDirectory(n.DAG)
+	var nodes []ipld.Node
+	for name, data := range map[string]string{
+		"_redirects": "/old/* /new/:splat\n/app/* /index.html 200\n",
+		"index.html": "index",
+		"404.html":   "not found",
+	} {
+		file := dag.NodeWithData(ft.FilePBData([]byte(data), uint64(len(data))))
+		if err := dir.AddChild(ctx, name, file); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, file)
+	}
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.DAG.AddMany(ctx, append(nodes, nd)); err != nil {
+		t.Fatal(err)
+	}
+	root := "/ipfs/" + nd.Cid().String()
+
+	for _, test := range []struct {
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{"/app/some/route", http.StatusOK, "index", ""},
+		{"/old/page", http.StatusMovedPermanently, "", root + "/new/page"},
+		{"/missing", http.StatusNotFound, "not found", ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+root+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, res.StatusCode)
+			continue
+		}
+		if test.body != "" && string(body) != test.body {
+			t.Errorf("%s: expected %q, got %q", test.path, test.body, body)
+		}
+		if loc := res.Header.Get("Location"); loc != test.location {
+			t.Errorf("%s: expected location %q, got %q", test.path, test.location, loc)
+		}
+	}
+}
+
+func TestGatewayCacheHeaders(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+	n.Namesys = ttlNamesys{mockNamesys: ns, ttl: 90 * time.Second}
+
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns["/ipns/example.com"] = path.FromString("/ipfs/" + k)
+	etag := "\"" + k + "\""
+
+	for _, test := range []struct {
+		path         string
+		ifNoneMatch  string
+		status       int
+		cacheControl string
+	}{
+		{"/ipfs/" + k, "", http.StatusOK, "public, max-age=29030400, immutable"},
+		{"/ipfs/" + k, etag, http.StatusNotModified, "public, max-age=29030400, immutable"},
+		{"/ipfs/" + k, "\"other\", W/" + etag, http.StatusNotModified, "public, max-age=29030400, immutable"},
+		{"/ipfs/" + k, "\"other\"", http.StatusOK, "public, max-age=29030400, immutable"},
+		{"/ipns/example.com", "", http.StatusOK, "public, max-age=90"},
+		{"/ipns/example.com", "*", http.StatusNotModified, "public, max-age=90"},
+		{emptyDir + "/", "", http.StatusOK, ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != test.status {
+			t.Errorf("%s (%s): expected status %d, got %d", test.path, test.ifNoneMatch, test.status, res.StatusCode)
+			continue
+		}
+		if cc := res.Header.Get("Cache-Control"); cc != test.cacheControl {
+			t.Errorf("%s (%s): expected Cache-Control %q, got %q", test.path, test.ifNoneMatch, test.cacheControl, cc)
+		}
+	}
+}
+
+func TestGatewayLimiter(t *testing.T) {
+	l, err := newGatewayLimiter(context.Background(), config.GatewayLimits{
+		ClientRequestsPerMinute: 60,
+		ClientRequestBurst:      2,
+		MaxConcurrentRequests:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+
+	block := make(chan struct{})
+	h := l.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			<-block
+		}
+	}))
+	serve := func(ip, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := serve("10.0.0.1", "/"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := serve("10.0.0.1", "/")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 retrying after 1s, got %d (%q)", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve("10.0.0.2", "/"); w.Code != http.StatusOK {
+		t.Fatalf("expected other clients to be served, got %d", w.Code)
+	}
+	now = now.Add(time.Second)
+	if w := serve("10.0.0.1", "/"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after a second, got %d", w.Code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		serve("10.0.0.3", "/block")
+		close(done)
+	}()
+	for len(l.slots) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if w := serve("10.0.0.4", "/"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 over the concurrency cap, got %d", w.Code)
+	}
+	close(block)
+	<-done
+	if w := serve("10.0.0.4", "/"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 once a slot is free, got %d", w.Code)
+	}
+}
+
+func TestWritableGateway(t *testing.T) {
+	n, err := newNodeWithMockNamesys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.WriteTokens = []string{"secret"}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, GatewayOption(true, "/ipfs", "/ipns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := dag.NodeWithData(ft.FilePBData([]byte("fnord"), 5))
+	dir := ft.EmptyDirNode()
+	if err := dir.AddNodeLink("file", file); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	cw, err := car.NewWriter(&buf, []*cid.Cid{dir.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []ipld.Node{dir, file} {
+		if err := cw.Put(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := func(token string) *http.Response {
+		req, err := http.NewRequest("POST", ts.URL+"/ipfs/", bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", carContentType)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if res := post(token); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 with token %q, got %d", token, res.StatusCode)
+		}
+	}
+
+	res := post("secret")
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", res.StatusCode)
+	}
+	if h := res.Header.Get("IPFS-Hash"); h != dir.Cid().String() {
+		t.Fatalf("expected the root %s, got %s", dir.Cid(), h)
+	}
+	if loc := res.Header.Get("Location"); loc != "/ipfs/"+dir.Cid().String() {
+		t.Fatalf("unexpected location %q", loc)
+	}
+
+	_, pinned, err := n.Pinning.IsPinned(dir.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pinned {
+		t.Fatal("expected the root of the CAR file to be pinned")
+	}
+
+	got, err := http.Get(ts.URL + "/ipfs/" + dir.Cid().String() + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Body.Close()
+	body, err := ioutil.ReadAll(got.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "fnord" {
+		t.Fatalf("expected fnord, got %q", body)
+	}
+}
+
+func TestWritableGatewayWithoutTokens(t *testing.T) {
+	n, err := newNodeWithMockNamesys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, GatewayOption(true, "/ipfs", "/ipns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := car.NewWriter(&buf, []*cid.Cid{ft.EmptyDirNode().Cid()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		method, path, ctype string
+	}{
+		{"POST", "/ipfs/", carContentType},
+		{"PUT", "/ipns/" + n.Identity.Pretty() + "/file", "text/plain"},
+	} {
+		req, err := http.NewRequest(tc.method, ts.URL+tc.path, bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", tc.ctype)
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("%s %s: expected 403 without write tokens, got %d", tc.method, tc.path, res.StatusCode)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package corehttp
 
 import (
+	"encoding/json"
 	"io"
 	"net"
 	"net/http"
 
 	core "github.com/ipfs/go-ipfs/core"
+	logs "github.com/ipfs/go-ipfs/core/logs"
 	logging "github.com/ipfs/go-log"
 )
 
@@ -47,12 +49,66 @@ func (w *writeErrNotifier) Close() error {
 func LogOption() ServeOption {
 	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 		mux.HandleFunc("/logs", func(w http.ResponseWriter, r *http.Request) {
+			if l := logs.Default(); l != nil {
+				serveEntries(n, l, w, r)
+				return
+			}
 			w.WriteHeader(200)
 			wnf, errs := newWriteErrNotifier(w)
 			logging.WriterGroup.AddWriter(wnf)
 			log.Event(n.Context(), "log API client connected")
 			<-errs
 		})
+		mux.HandleFunc("/logs/dump", func(w http.ResponseWriter, r *http.Request) {
+			l := logs.Default()
+			if l == nil {
+				http.Error(w, "the event log is not kept by this node", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="ipfs-logs.ndjson"`)
+			enc := json.NewEncoder(w)
+			for _, e := range l.Entries() {
+				if err := enc.Encode(e); err != nil {
+					return
+				}
+			}
+		})
 		return mux, nil
 	}
 }
+
+// serveEntries streams the structured entries of l to w until the client goes
+// away.
+func serveEntries(n *core.IpfsNode, l *logs.Logger, w http.ResponseWriter, r *http.Request) {
+	entries := make(chan logs.Entry, eventsBuffer)
+	cancel := l.Subscribe(func(e logs.Entry) {
+		select {
+		case entries <- e:
+		default:
+			// don't slow down the node for a slow client
+		}
+	})
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(200)
+	log.Event(n.Context(), "log API client connected")
+
+	wnf, errs := newWriteErrNotifier(w)
+	enc := json.NewEncoder(wnf)
+	for {
+		select {
+		case e := <-entries:
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		case <-errs:
+			return
+		case <-r.Context().Done():
+			return
+		case <-n.Process().Closing():
+			return
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package corehttp
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	events "github.com/ipfs/go-ipfs/core/events"
 	config "github.com/ipfs/go-ipfs/repo/config"
 )
 
@@ -73,3 +76,49 @@ func TestCheckVersionOption(t *testing.T) {
 		}
 	}
 }
+
+func TestEventsOption(t *testing.T) {
+	n, err := newNodeWithMockNamesys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, EventsOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Get(ts.URL + "/events?type=ipns-published")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	// the subscription is made before the headers are sent
+	n.Events.Emit(events.Event{Type: events.PinCompleted})
+	n.Events.Emit(events.Event{Type: events.IpnsPublished, Name: "name", Value: "/ipfs/value"})
+
+	r := bufio.NewReader(res.Body)
+	for _, prefix := range []string{"event: ipns-published\n", "data: {"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, prefix) {
+			t.Fatalf("expected %q, got %q", prefix, line)
+		}
+		if prefix == "data: {" && !strings.Contains(line, `"Name":"name"`) {
+			t.Fatalf("unexpected data %q", line)
+		}
+	}
+
+	if res, err := http.Get(ts.URL + "/events?type=nope"); err != nil || res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown types, got %v (%v)", res, err)
+	}
+}
@@ -0,0 +1,101 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs/go-ipfs/core"
+	repo "github.com/ipfs/go-ipfs/repo"
+)
+
+// ErrCompactionUnsupported is returned by Compact if the repo can't compact
+// its storage.
+var ErrCompactionUnsupported = errors.New("repo does not support compaction")
+
+// sizeBuckets are the upper bounds of the block size distribution buckets.
+var sizeBuckets = []uint64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+// SizeBucket counts the blocks of at most UpTo bytes that didn't fit a
+// smaller bucket. The last bucket has UpTo 0 and holds all larger blocks.
+type SizeBucket struct {
+	UpTo  uint64
+	Count uint64
+}
+
+// BlockStat describes how the blockstore uses disk space.
+type BlockStat struct {
+	NumBlocks  uint64
+	BlocksSize uint64 // total size of the blocks' data in bytes
+	RepoSize   uint64 // disk usage of the repo in bytes
+
+	// Amplification is RepoSize divided by BlocksSize, i.e. how many bytes
+	// of disk are used per byte of block data.
+	Amplification float64
+
+	SizeDistribution []SizeBucket
+
+	OrphanedTempFiles     uint64
+	OrphanedTempFilesSize uint64
+}
+
+// BlockstoreStat scans the blockstore and reports its size, block size
+// distribution, orphaned temporary files and storage amplification.
+func BlockstoreStat(ctx context.Context, n *core.IpfsNode) (*BlockStat, error) {
+	st := &BlockStat{
+		SizeDistribution: make([]SizeBucket, len(sizeBuckets)+1),
+	}
+	for i, b := range sizeBuckets {
+		st.SizeDistribution[i].UpTo = b
+	}
+
+	keys, err := n.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for c := range keys {
+		blk, err := n.Blockstore.Get(c)
+		if err != nil {
+			log.Debugf("blockstat: reading %s: %s", c, err)
+			continue
+		}
+
+		size := uint64(len(blk.RawData()))
+		st.NumBlocks++
+		st.BlocksSize += size
+
+		i := 0
+		for i < len(sizeBuckets) && size > sizeBuckets[i] {
+			i++
+		}
+		st.SizeDistribution[i].Count++
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	st.RepoSize, err = n.Repo.GetStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+	if st.BlocksSize > 0 {
+		st.Amplification = float64(st.RepoSize) / float64(st.BlocksSize)
+	}
+
+	if tf, ok := n.Repo.(repo.TempFileCounter); ok {
+		st.OrphanedTempFiles, st.OrphanedTempFilesSize, err = tf.OrphanedTempFiles()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// Compact reclaims disk space the repo's storage holds on to, such as
+// orphaned temporary files.
+func Compact(n *core.IpfsNode) error {
+	c, ok := n.Repo.(repo.Compacter)
+	if !ok {
+		return ErrCompactionUnsupported
+	}
+	return c.Compact()
+}
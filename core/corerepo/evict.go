@@ -0,0 +1,82 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+)
+
+const defaultEvictionLowWatermark = 80
+
+// ErrEvictionDisabled is returned when eviction is requested on a node
+// without Datastore.Eviction enabled.
+var ErrEvictionDisabled = errors.New("block eviction is not enabled")
+
+// PeriodicEviction checks the repo size every Datastore.Eviction.Period and,
+// once it exceeds StorageGCWatermark, evicts least recently used unpinned
+// blocks until it is back under LowWatermark.
+func PeriodicEviction(ctx context.Context, node *core.IpfsNode) error {
+	if node.Evictor == nil {
+		return ErrEvictionDisabled
+	}
+
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	period := time.Minute
+	if cfg.Datastore.Eviction.Period != "" {
+		period, err = time.ParseDuration(cfg.Datastore.Eviction.Period)
+		if err != nil {
+			return err
+		}
+	}
+
+	low := cfg.Datastore.Eviction.LowWatermark
+	if low == 0 {
+		low = defaultEvictionLowWatermark
+	}
+
+	gc, err := NewGC(node)
+	if err != nil {
+		return err
+	}
+	target := gc.StorageMax * uint64(low) / 100
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(period):
+			if err := gc.maybeEvict(ctx, target); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+func (gc *GC) maybeEvict(ctx context.Context, target uint64) error {
+	storage, err := gc.Repo.GetStorageUsage()
+	if err != nil {
+		return err
+	}
+	if storage <= gc.StorageGC {
+		return nil
+	}
+	if storage > gc.StorageMax {
+		log.Warningf("pre-eviction: %s", ErrMaxStorageExceeded)
+	}
+
+	log.Info("Watermark exceeded. Evicting least recently used blocks...")
+	defer log.EventBegin(ctx, "repoEvict").Done()
+
+	var need uint64
+	if storage > target {
+		need = storage - target
+	}
+	_, err = gc.Node.Evictor.Evict(ctx, need)
+	return err
+}
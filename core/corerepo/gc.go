@@ -79,14 +79,27 @@ func BestEffortRoots(filesRoot *mfs.Root) ([]*cid.Cid, error) {
 	return []*cid.Cid{rootDag.Cid()}, nil
 }
 
-func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel() // in case error occurs during operation
+// gcRoots returns the roots a garbage collection of n keeps the available
+// descendants of: the files root and the partial pins.
+func gcRoots(n *core.IpfsNode) ([]*cid.Cid, error) {
 	roots, err := BestEffortRoots(n.FilesRoot)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	rmed := gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	if n.PartialPins != nil {
+		partial, err := n.PartialPins.Roots()
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, partial...)
+	}
+	return roots, nil
+}
+
+func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // in case error occurs during operation
+	rmed := GarbageCollectAsync(n, ctx)
 
 	return CollectResult(ctx, rmed, nil)
 }
@@ -94,7 +107,7 @@ func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 // CollectResult collects the output of a garbage collection run and calls the
 // given callback for each object removed.  It also collects all errors into a
 // MultiError which is returned after the gc is completed.
-func CollectResult(ctx context.Context, gcOut <-chan gc.Result, cb func(*cid.Cid)) error {
+func CollectResult(ctx context.Context, gcOut <-chan gc.Result, cb func(gc.Result)) error {
 	var errors []error
 loop:
 	for {
@@ -106,7 +119,7 @@ loop:
 			if res.Error != nil {
 				errors = append(errors, res.Error)
 			} else if res.KeyRemoved != nil && cb != nil {
-				cb(res.KeyRemoved)
+				cb(res)
 			}
 		case <-ctx.Done():
 			errors = append(errors, ctx.Err())
@@ -145,10 +158,28 @@ func (e *MultiError) Error() string {
 	return buf.String()
 }
 
+// GarbageCollectAsync runs a garbage collection, blocking writes only when
+// starting and sweeping if the node journals its writes. Its progress is
+// emitted on the node's GCEvents.
 func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result {
-	roots, err := BestEffortRoots(n.FilesRoot)
+	if n.ReadOnly() {
+		out := make(chan gc.Result, 1)
+		out <- gc.Result{Error: core.ErrReadOnly}
+		close(out)
+		return out
+	}
+
+	ctx = gc.WithEvents(ctx, n.GCEvents)
+	if n.GCJournal != nil {
+		roots := func() ([]*cid.Cid, error) {
+			return gcRoots(n)
+		}
+		return gc.ConcurrentGC(ctx, n.Blockstore, n.GCJournal, n.Repo.Datastore(), n.Pinning, roots)
+	}
+
+	roots, err := gcRoots(n)
 	if err != nil {
-		out := make(chan gc.Result)
+		out := make(chan gc.Result, 1)
 		out <- gc.Result{Error: err}
 		close(out)
 		return out
@@ -157,7 +188,26 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result
 	return gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
 }
 
+// GarbageCollectDryRun reports the blocks a garbage collection would remove
+// and their size, without removing them.
+func GarbageCollectDryRun(n *core.IpfsNode, ctx context.Context) <-chan gc.Result {
+	roots, err := gcRoots(n)
+	if err != nil {
+		out := make(chan gc.Result, 1)
+		out <- gc.Result{Error: err}
+		close(out)
+		return out
+	}
+
+	return gc.DryRun(ctx, n.Blockstore, n.Pinning, roots)
+}
+
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
+	if node.ReadOnly() {
+		log.Warning("garbage collection is disabled, the repo is read-only")
+		return nil
+	}
+
 	cfg, err := node.Repo.Config()
 	if err != nil {
 		return err
@@ -195,6 +245,10 @@ func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
 }
 
 func ConditionalGC(ctx context.Context, node *core.IpfsNode, offset uint64) error {
+	if node.ReadOnly() {
+		// nothing is written, and nothing can be freed
+		return nil
+	}
 	gc, err := NewGC(node)
 	if err != nil {
 		return err
@@ -18,16 +18,28 @@ import (
 	"fmt"
 
 	"github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
+	pin "github.com/ipfs/go-ipfs/pin"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
 )
 
 func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+	if n.ReadOnly() {
+		return nil, core.ErrReadOnly
+	}
+
 	out := make([]*cid.Cid, len(paths))
 
+	// fetching pinned DAGs shouldn't hold up interactive requests
+	ctx = exchange.WithPriority(ctx, exchange.PriorityBackground)
+
 	r := &resolver.Resolver{
 		DAG:         n.DAG,
 		ResolveOnce: uio.ResolveUnixfsOnce,
@@ -55,10 +67,117 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		return nil, err
 	}
 
+	for _, c := range out {
+		n.Events.Emit(events.Event{Type: events.PinCompleted, Cid: c})
+	}
 	return out, nil
 }
 
+// Label sets the name and labels of the direct or recursive pins of cids.
+func Label(n *core.IpfsNode, cids []*cid.Cid, name string, meta map[string]string) error {
+	if n.ReadOnly() {
+		return core.ErrReadOnly
+	}
+	for _, c := range cids {
+		if err := n.Pinning.Label(c, name, meta); err != nil {
+			return fmt.Errorf("label %s: %s", c, err)
+		}
+	}
+	return n.Pinning.Flush()
+}
+
+// ExportPins returns a manifest of the pins of n signed with its identity.
+func ExportPins(n *core.IpfsNode) (*pin.Manifest, error) {
+	if err := n.LoadPrivateKey(); err != nil {
+		return nil, err
+	}
+	return pin.Export(n.Pinning, n.PrivateKey)
+}
+
+// ImportPins verifies the signature of m, and that it was signed by from if
+// it isn't empty, and adds its pins with their names and labels. Online,
+// the content is fetched in the background and ImportPins returns once the
+// manifest is verified; offline, only content available locally can be
+// pinned. It returns the pins to be added.
+func ImportPins(n *core.IpfsNode, ctx context.Context, m *pin.Manifest, from peer.ID) ([]*cid.Cid, error) {
+	if n.ReadOnly() {
+		return nil, core.ErrReadOnly
+	}
+	signer, err := m.Verify()
+	if err != nil {
+		return nil, err
+	}
+	if from != "" && signer != from {
+		return nil, fmt.Errorf("pin manifest was signed by %s, not %s", signer.Pretty(), from.Pretty())
+	}
+	infos, err := m.PinInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*cid.Cid, len(infos))
+	for i, info := range infos {
+		out[i] = info.Cid
+	}
+
+	if !n.OnlineMode() {
+		return out, importPins(n, ctx, infos)
+	}
+	go func() {
+		if err := importPins(n, n.Context(), infos); err != nil {
+			log.Errorf("pin import: %s", err)
+		}
+	}()
+	return out, nil
+}
+
+func importPins(n *core.IpfsNode, ctx context.Context, infos []*pin.PinInfo) error {
+	// fetching pinned DAGs shouldn't hold up interactive requests
+	ctx = exchange.WithPriority(ctx, exchange.PriorityBackground)
+
+	var failed int
+	for _, info := range infos {
+		if err := importPin(n, ctx, info); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warningf("pin import: %s: %s", info.Cid, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pins couldn't be imported", failed, len(infos))
+	}
+	return nil
+}
+
+func importPin(n *core.IpfsNode, ctx context.Context, info *pin.PinInfo) error {
+	// fetch before taking the pin lock, not to hold up gc meanwhile
+	if info.Mode == pin.Recursive {
+		if err := dag.FetchGraph(ctx, info.Cid, n.DAG); err != nil {
+			return err
+		}
+	}
+	nd, err := n.DAG.Get(ctx, info.Cid)
+	if err != nil {
+		return err
+	}
+
+	defer n.Blockstore.PinLock().Unlock()
+	if err := n.Pinning.Pin(ctx, nd, info.Mode == pin.Recursive); err != nil {
+		return err
+	}
+	if err := n.Pinning.Label(info.Cid, info.Name, info.Meta); err != nil {
+		return err
+	}
+	return n.Pinning.Flush()
+}
+
 func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+	if n.ReadOnly() {
+		return nil, core.ErrReadOnly
+	}
+
 	unpinned := make([]*cid.Cid, len(paths))
 
 	r := &resolver.Resolver{
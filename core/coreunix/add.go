@@ -12,19 +12,19 @@ import (
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/exchange/offline"
-	balanced "github.com/ipfs/go-ipfs/importer/balanced"
+	importer "github.com/ipfs/go-ipfs/importer"
+	chunkers "github.com/ipfs/go-ipfs/importer/chunkers"
 	ihelper "github.com/ipfs/go-ipfs/importer/helpers"
-	trickle "github.com/ipfs/go-ipfs/importer/trickle"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	"github.com/ipfs/go-ipfs/pin"
 	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	mimeindex "github.com/ipfs/go-ipfs/unixfs/mimeindex"
 
 	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	syncds "github.com/ipfs/go-datastore/sync"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
-	chunker "github.com/ipfs/go-ipfs-chunker"
 	files "github.com/ipfs/go-ipfs-cmdkit/files"
 	posinfo "github.com/ipfs/go-ipfs-posinfo"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -70,6 +70,12 @@ type AddedObject struct {
 	Hash  string `json:",omitempty"`
 	Bytes int64  `json:",omitempty"`
 	Size  string `json:",omitempty"`
+
+	// Event, Blocks and Dedup are set in the progress updates of files, as
+	// in AddProgress.
+	Event  ProgressEvent `json:",omitempty"`
+	Blocks uint64        `json:",omitempty"`
+	Dedup  uint64        `json:",omitempty"`
 }
 
 // NewAdder Returns a new Adder used for a file add operation.
@@ -85,6 +91,7 @@ func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCBlockstore, ds ipld
 		Trickle:    false,
 		Wrap:       false,
 		Chunker:    "",
+		Layout:     "",
 	}, nil
 }
 
@@ -104,12 +111,48 @@ type Adder struct {
 	Wrap       bool
 	NoCopy     bool
 	Chunker    string
+	Layout     string
 	root       ipld.Node
 	mroot      *mfs.Root
 	unlocker   bstore.Unlocker
 	tempRoot   *cid.Cid
 	Prefix     *cid.Prefix
 	liveNodes  uint64
+
+	// PreserveMode, PreserveMtime and PreserveXAttrs record the mode, the
+	// modification time and the extended attributes of the files added
+	// from disk.
+	PreserveMode   bool
+	PreserveMtime  bool
+	PreserveXAttrs bool
+
+	// InlineLimit is the size up to which the nodes of files are inlined
+	// in their CID, with CIDv1.
+	InlineLimit int
+
+	// Symlinks is how the symlinks of the files added are handled.
+	Symlinks SymlinkPolicy
+
+	// MimeIndex records the content type of the files added, if set.
+	MimeIndex *mimeindex.Index
+
+	// OnProgress, if set, is called with the progress updates of the
+	// files added, as they are sent over Out with Progress.
+	OnProgress func(AddProgress)
+
+	// hardlinks are the nodes of the files added from disk with other hard
+	// links, by inode, so the files they link to are only chunked once.
+	hardlinks map[inode]ipld.Node
+
+	// syncRoot is the root of a previous add whose unchanged files are
+	// reused, read from syncServ.
+	syncRoot ipld.Node
+	syncServ ipld.DAGService
+}
+
+// inode identifies a file on disk.
+type inode struct {
+	dev, ino uint64
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -131,26 +174,34 @@ func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 	adder.mroot = r
 }
 
-// Constructs a node from reader's data, and adds it. Doesn't pin.
-func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
-	chnk, err := chunker.FromString(reader, adder.Chunker)
+// Constructs a node from reader's data, and adds it. Doesn't pin. The blocks
+// written are counted in progress, if not nil.
+func (adder *Adder) add(reader io.Reader, attrs unixfs.Attrs, progress *AddProgress) (ipld.Node, error) {
+	chnk, err := chunkers.FromString(reader, adder.Chunker)
 	if err != nil {
 		return nil, err
 	}
 
+	dserv := adder.dagService
+	if progress != nil {
+		dserv = &countingDAG{dserv, adder.blockstore, progress}
+	}
+
 	params := ihelper.DagBuilderParams{
-		Dagserv:   adder.dagService,
-		RawLeaves: adder.RawLeaves,
-		Maxlinks:  ihelper.DefaultLinksPerBlock,
-		NoCopy:    adder.NoCopy,
-		Prefix:    adder.Prefix,
+		Dagserv:     dserv,
+		RawLeaves:   adder.RawLeaves,
+		Maxlinks:    ihelper.DefaultLinksPerBlock,
+		NoCopy:      adder.NoCopy,
+		Prefix:      adder.Prefix,
+		Attrs:       attrs,
+		InlineLimit: adder.InlineLimit,
 	}
 
+	layout := adder.Layout
 	if adder.Trickle {
-		return trickle.Layout(params.New(chnk))
+		layout = "trickle"
 	}
-
-	return balanced.Layout(params.New(chnk))
+	return importer.BuildDag(params, chnk, layout)
 }
 
 // RootNode returns the root node of the Added.
@@ -312,17 +363,37 @@ func Add(n *core.IpfsNode, r io.Reader) (string, error) {
 
 // AddWithContext does the same as Add, but with a custom context.
 func AddWithContext(ctx context.Context, n *core.IpfsNode, r io.Reader) (string, error) {
+	return AddWithSettings(ctx, n, r, "", "", nil)
+}
+
+// AddWithSettings does the same as AddWithContext, but splits the data with
+// the chunker described by chunker, as understood by chunkers.FromString,
+// and builds its DAG with the layout called layout. If onProgress is not nil,
+// it is called with the progress updates of the add.
+func AddWithSettings(ctx context.Context, n *core.IpfsNode, r io.Reader, chunker, layout string, onProgress func(AddProgress)) (string, error) {
 	defer n.Blockstore.PinLock().Unlock()
 
 	fileAdder, err := NewAdder(ctx, n.Pinning, n.Blockstore, n.DAG)
 	if err != nil {
 		return "", err
 	}
+	fileAdder.Chunker = chunker
+	fileAdder.Layout = layout
+	fileAdder.OnProgress = onProgress
 
-	node, err := fileAdder.add(r)
+	var progress *AddProgress
+	if onProgress != nil {
+		rdr := fileAdder.startProgress("", r)
+		r, progress = rdr, rdr.progress
+	}
+
+	node, err := fileAdder.add(r, unixfs.Attrs{}, progress)
 	if err != nil {
 		return "", err
 	}
+	if progress != nil {
+		fileAdder.completeProgress(progress, node)
+	}
 
 	return node.Cid().String(), nil
 }
@@ -435,72 +506,136 @@ func (adder *Adder) AddFile(file files.File) error {
 		}
 	}()
 
+	if adder.Symlinks == SymlinksFollow {
+		var err error
+		file, err = FollowSymlinks(file)
+		if err != nil {
+			return err
+		}
+	}
+
 	return adder.addFile(file)
 }
 
 func (adder *Adder) addFile(file files.File) error {
-	err := adder.maybePauseForGC()
-	if err != nil {
+	if err := adder.checkpoint(); err != nil {
 		return err
 	}
 
-	if adder.liveNodes >= liveCacheSize {
-		// TODO: A smarter cache that uses some sort of lru cache with an eviction handler
-		mr, err := adder.mfsRoot()
-		if err != nil {
-			return err
-		}
-		if err := mr.FlushMemFree(adder.ctx); err != nil {
-			return err
-		}
-
-		adder.liveNodes = 0
-	}
-	adder.liveNodes++
-
 	if file.IsDirectory() {
 		return adder.addDir(file)
 	}
 
 	// case for symlink
 	if s, ok := file.(*files.Symlink); ok {
-		sdata, err := unixfs.SymlinkData(s.Target)
-		if err != nil {
-			return err
-		}
-
-		dagnode := dag.NodeWithData(sdata)
-		dagnode.SetPrefix(adder.Prefix)
-		err = adder.dagService.Add(adder.ctx, dagnode)
-		if err != nil {
-			return err
-		}
-
-		return adder.addNode(dagnode, s.FileName())
+		return adder.addSymlink(s.Target, s.FileName())
 	}
 
 	// case for regular file
-	// if the progress flag was specified, wrap the file so that we can send
+	// if the progress of files is tracked, wrap the file so that we can send
 	// progress updates to the client (over the output channel)
 	var reader io.Reader = file
-	if adder.Progress {
-		rdr := &progressReader{file: file, out: adder.Out}
+	var progress *AddProgress
+	if adder.tracksProgress() {
+		rdr := adder.startProgress(file.FileName(), file)
+		progress = rdr.progress
 		if fi, ok := file.(files.FileInfo); ok {
 			reader = &progressReader2{rdr, fi}
 		} else {
 			reader = rdr
 		}
 	}
+	var sniffer *mimeindex.Sniffer
+	if adder.MimeIndex != nil {
+		sniffer = &mimeindex.Sniffer{R: reader}
+		if fi, ok := reader.(files.FileInfo); ok {
+			reader = &sniffReader{sniffer, fi}
+		} else {
+			reader = sniffer
+		}
+	}
 
-	dagnode, err := adder.add(reader)
+	attrs, err := adder.attrsOf(file)
 	if err != nil {
 		return err
 	}
 
+	if nd := adder.syncedFile(file, attrs); nd != nil {
+		return adder.reuseNode(file, nd)
+	}
+
+	ino, linked := adder.inodeOf(file)
+	if nd, ok := adder.hardlinks[ino]; linked && ok {
+		return adder.reuseNode(file, nd)
+	}
+
+	dagnode, err := adder.add(reader, attrs, progress)
+	if err != nil {
+		return err
+	}
+	if progress != nil {
+		adder.completeProgress(progress, dagnode)
+	}
+	if sniffer != nil {
+		if err := adder.MimeIndex.Put(dagnode.Cid(), sniffer.Entry()); err != nil {
+			return err
+		}
+	}
+	if linked {
+		if adder.hardlinks == nil {
+			adder.hardlinks = make(map[inode]ipld.Node)
+		}
+		adder.hardlinks[ino] = dagnode
+	}
+
 	// patch it into the root
 	return adder.addNode(dagnode, file.FileName())
 }
 
+// reuseNode adds nd, the node of a file added before, in place of file,
+// which is not read.
+func (adder *Adder) reuseNode(file files.File, nd ipld.Node) error {
+	if adder.tracksProgress() {
+		adder.report(AddProgress{
+			Event: ProgressCompleted,
+			Name:  file.FileName(),
+			Bytes: file.(files.FileInfo).Stat().Size(),
+			Cid:   nd.Cid(),
+		})
+	}
+	return adder.addNode(nd, file.FileName())
+}
+
+// inodeOf returns the inode of file, if it is a file on disk with other hard
+// links.
+func (adder *Adder) inodeOf(file files.File) (inode, bool) {
+	fi, ok := file.(files.FileInfo)
+	if !ok || fi.Stat() == nil {
+		return inode{}, false
+	}
+	return inodeOf(fi.Stat())
+}
+
+func (adder *Adder) addSymlink(target, path string) error {
+	if adder.Symlinks == SymlinksForbid {
+		return fmt.Errorf("%s is a symlink", path)
+	}
+
+	sdata, err := unixfs.SymlinkData(target)
+	if err != nil {
+		return err
+	}
+
+	dagnode := dag.NodeWithData(sdata)
+	dagnode.SetPrefix(adder.Prefix)
+	err = adder.dagService.Add(adder.ctx, dagnode)
+	if err != nil {
+		return err
+	}
+
+	return adder.addNode(dagnode, path)
+}
+
 func (adder *Adder) addDir(dir files.File) error {
 	log.Infof("adding directory: %s", dir.FileName())
 
@@ -537,6 +672,69 @@ func (adder *Adder) addDir(dir files.File) error {
 		}
 	}
 
+	attrs, err := adder.attrsOf(dir)
+	if err != nil || attrs.IsZero() {
+		return err
+	}
+	fsn, err := mfs.Lookup(mr, dir.FileName())
+	if err != nil {
+		return err
+	}
+	mdir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("%s is not a directory", dir.FileName())
+	}
+	return mdir.SetAttrs(attrs)
+}
+
+// attrsOf returns the attributes of file the adder preserves, if it is a file
+// on disk.
+func (adder *Adder) attrsOf(file files.File) (unixfs.Attrs, error) {
+	var attrs unixfs.Attrs
+	fi, ok := file.(files.FileInfo)
+	if !ok || fi.Stat() == nil {
+		return attrs, nil
+	}
+
+	st := fi.Stat()
+	if adder.PreserveMode {
+		attrs.Mode = st.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+	if adder.PreserveMtime {
+		attrs.ModTime = st.ModTime()
+	}
+	if adder.PreserveXAttrs {
+		xattrs, err := readXAttrs(fi.AbsPath())
+		if err != nil {
+			return attrs, err
+		}
+		attrs.XAttrs = xattrs
+	}
+	return attrs, nil
+}
+
+// checkpoint is called before adding each file: it lets the garbage
+// collector run if requested, and frees the memory used by the nodes added
+// after a while.
+func (adder *Adder) checkpoint() error {
+	err := adder.maybePauseForGC()
+	if err != nil {
+		return err
+	}
+
+	if adder.liveNodes >= liveCacheSize {
+		// TODO: A smarter cache that uses some sort of lru cache with an eviction handler
+		mr, err := adder.mfsRoot()
+		if err != nil {
+			return err
+		}
+		if err := mr.FlushMemFree(adder.ctx); err != nil {
+			return err
+		}
+
+		adder.liveNodes = 0
+	}
+	adder.liveNodes++
 	return nil
 }
 
@@ -605,29 +803,9 @@ func getOutput(dagnode ipld.Node) (*Object, error) {
 	return output, nil
 }
 
-type progressReader struct {
-	file         files.File
-	out          chan interface{}
-	bytes        int64
-	lastProgress int64
-}
-
-func (i *progressReader) Read(p []byte) (int, error) {
-	n, err := i.file.Read(p)
-
-	i.bytes += int64(n)
-	if i.bytes-i.lastProgress >= progressReaderIncrement || err == io.EOF {
-		i.lastProgress = i.bytes
-		i.out <- &AddedObject{
-			Name:  i.file.FileName(),
-			Bytes: i.bytes,
-		}
-	}
-
-	return n, err
-}
-
-type progressReader2 struct {
-	*progressReader
+// sniffReader keeps the file information of the files sniffed, used by the
+// filestore.
+type sniffReader struct {
+	*mimeindex.Sniffer
 	files.FileInfo
 }
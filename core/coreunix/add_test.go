@@ -1,6 +1,7 @@
 package coreunix
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"io"
@@ -17,12 +18,15 @@ import (
 	"github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/config"
 	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	archive "github.com/ipfs/go-ipfs/unixfs/archive"
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	files "github.com/ipfs/go-ipfs-cmdkit/files"
 	pi "github.com/ipfs/go-ipfs-posinfo"
+	ipld "github.com/ipfs/go-ipld-format"
 )
 
 const testPeerID = "QmTFauExutTsy4XP6JbMFcw2Wa9645HJt2bTqL6qYDCKfe"
@@ -269,3 +273,408 @@ func (fi *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (fi *dummyFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *dummyFileInfo) IsDir() bool        { return false }
 func (fi *dummyFileInfo) Sys() interface{}   { return nil }
+
+func TestAddSync(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: ds2.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "add-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "sub/b"} {
+		if err := ioutil.WriteFile(dir+"/"+name, []byte("content of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add := func(sync ipld.Node) ipld.Node {
+		stat, err := os.Lstat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := files.NewSerialFile("dir", dir, false, stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder.PreserveMtime = true
+		if sync != nil {
+			adder.SetSyncRoot(sync, node.DAG)
+		}
+		if err := adder.AddFile(f); err != nil {
+			t.Fatal(err)
+		}
+		nd, err := adder.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+	find := func(root ipld.Node, p string) *cid.Cid {
+		adder := &Adder{ctx: context.Background(), syncRoot: root, syncServ: node.DAG}
+		nd, err := adder.syncLookup("dir/" + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd.Cid()
+	}
+
+	first := add(nil)
+
+	// a keeps its size and modification time, so it is not read again
+	st, err := os.Stat(dir + "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dir+"/a", []byte("CONTENT OF a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(dir+"/a", st.ModTime(), st.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	// b changes
+	if err := ioutil.WriteFile(dir+"/sub/b", []byte("new content of b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	synced := add(first)
+	if !find(synced, "a").Equals(find(first, "a")) {
+		t.Fatal("expected unchanged file to be reused")
+	}
+	if find(synced, "sub/b").Equals(find(first, "sub/b")) {
+		t.Fatal("expected changed file to be added again")
+	}
+
+	// without syncing, the new content of a is read
+	full := add(nil)
+	if find(full, "a").Equals(find(first, "a")) {
+		t.Fatal("expected file to be read again")
+	}
+	if !find(full, "sub/b").Equals(find(synced, "sub/b")) {
+		t.Fatal("expected changed file to be added the same way")
+	}
+}
+
+func TestAddSymlinks(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: ds2.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "add-symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "sub/b"} {
+		if err := ioutil.WriteFile(dir+"/"+name, []byte("content of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a", dir+"/l"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", dir+"/d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(dir+"/a", dir+"/h"); err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(policy SymlinkPolicy) (*Adder, ipld.Node, error) {
+		stat, err := os.Lstat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := files.NewSerialFile("dir", dir, false, stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder.Symlinks = policy
+		if err := adder.AddFile(f); err != nil {
+			return nil, nil, err
+		}
+		nd, err := adder.Finalize()
+		return adder, nd, err
+	}
+	find := func(root ipld.Node, p string) ipld.Node {
+		adder := &Adder{ctx: context.Background(), syncRoot: root, syncServ: node.DAG}
+		nd, err := adder.syncLookup("dir/" + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+
+	adder, preserved, err := add(SymlinksPreserve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"l", "d"} {
+		fsn, err := unixfs.FSNodeFromBytes(find(preserved, p).(*dag.ProtoNode).Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fsn.Type != unixfs.TSymlink {
+			t.Fatalf("expected %s to be a symlink", p)
+		}
+	}
+	// the hard link to a reuses its node
+	if !find(preserved, "h").Cid().Equals(find(preserved, "a").Cid()) {
+		t.Fatal("expected hard links to have the same hash")
+	}
+	if len(adder.hardlinks) != 1 {
+		t.Fatalf("expected one hard linked file, got %d", len(adder.hardlinks))
+	}
+
+	_, followed, err := add(SymlinksFollow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !find(followed, "l").Cid().Equals(find(followed, "a").Cid()) {
+		t.Fatal("expected symlink to a file to be followed")
+	}
+	if !find(followed, "d/b").Cid().Equals(find(followed, "sub/b").Cid()) {
+		t.Fatal("expected symlink to a directory to be followed")
+	}
+
+	if _, _, err := add(SymlinksForbid); err == nil {
+		t.Fatal("expected symlinks to be forbidden")
+	}
+
+	// a symlink to a directory holding it can't be followed
+	if err := os.Symlink("..", dir+"/sub/up"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := add(SymlinksFollow); err == nil {
+		t.Fatal("expected symlink loop to fail the add")
+	}
+}
+
+func TestAddReproducible(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: ds2.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "add-reproducible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	big := make([]byte, 1<<20+1000)
+	rand.New(rand.NewSource(1)).Read(big)
+	if err := ioutil.WriteFile(dir+"/sub/big", big, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dir+"/small", []byte("small"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(set func(*Adder)) ipld.Node {
+		stat, err := os.Lstat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := files.NewSerialFile("dir", dir, false, stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set(adder)
+		if err := adder.AddFile(f); err != nil {
+			t.Fatal(err)
+		}
+		nd, err := adder.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+
+	reproducible := func(adder *Adder) {
+		adder.RawLeaves = true
+		adder.PreserveMtime = true
+		if err := adder.SetReproducible(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := add(reproducible)
+	if err := VerifyReproducible(context.Background(), node.DAG, first.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if second := add(reproducible); !second.Cid().Equals(first.Cid()) {
+		t.Fatal("expected the same files to be added the same way")
+	}
+
+	for name, set := range map[string]func(*Adder){
+		"raw leaves": func(adder *Adder) { adder.RawLeaves = true },
+		"mtime":      func(adder *Adder) { adder.PreserveMtime = true },
+		"chunker":    func(adder *Adder) { adder.Chunker = "size-1000" },
+	} {
+		nd := add(set)
+		if err := VerifyReproducible(context.Background(), node.DAG, nd.Cid()); err == nil {
+			t.Fatalf("expected add with %s not to be reproducible", name)
+		}
+	}
+}
+
+func TestAddArchives(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: ds2.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []struct {
+		h    tar.Header
+		data string
+	}{
+		{tar.Header{Name: "site/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "site/index.html", Typeflag: tar.TypeReg, Mode: 0644}, "<html></html>"},
+		{tar.Header{Name: "site/css/style.css", Typeflag: tar.TypeReg, Mode: 0644}, "body {}"},
+		{tar.Header{Name: "site/latest", Typeflag: tar.TypeSymlink, Linkname: "index.html"}, ""},
+		{tar.Header{Name: "site/copy.html", Typeflag: tar.TypeLink, Linkname: "site/index.html"}, ""},
+	}
+	for _, ent := range entries {
+		h := ent.h
+		h.Size = int64(len(ent.data))
+		if err := tw.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(ent.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Wrap = true
+	if err := adder.AddTar(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fromTar, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := &Adder{ctx: context.Background(), Wrap: true, syncRoot: fromTar, syncServ: node.DAG}
+	index, err := lookup.syncLookup("site/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := lookup.syncLookup("site/copy.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Cid().Equals(index.Cid()) {
+		t.Fatal("expected hard link to have the content of its target")
+	}
+	if _, err := lookup.syncLookup("site/css/style.css"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the zip archive of the directory holds the same entries
+	zr, err := archive.DagZipArchive(context.Background(), fromTar, "", node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := archive.DagZipArchive(context.Background(), fromTar, "", node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataAgain, err := ioutil.ReadAll(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, dataAgain) {
+		t.Fatal("expected archives of the same directory to be the same")
+	}
+
+	adder, err = NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Wrap = true
+	if err := adder.AddZip(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	fromZip, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fromZip.Cid().Equals(fromTar.Cid()) {
+		t.Fatalf("expected %s from the zip archive, got %s", fromTar.Cid(), fromZip.Cid())
+	}
+}
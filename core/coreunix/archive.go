@@ -0,0 +1,263 @@
+package coreunix
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
+)
+
+// AddTar adds the entries of the tar archive read from r, with the same
+// structure: the directories, regular files, symlinks and hard links it
+// holds are added at their path in the archive, from the root of the adder,
+// which should wrap them. The mode, modification time and extended
+// attributes of the entries are recorded if the adder preserves them.
+func (adder *Adder) AddTar(r io.Reader) error {
+	if adder.Pin {
+		adder.unlocker = adder.blockstore.PinLock()
+	}
+	defer func() {
+		if adder.unlocker != nil {
+			adder.unlocker.Unlock()
+		}
+	}()
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := adder.checkpoint(); err != nil {
+			return err
+		}
+
+		p, err := archivePath(h.Name)
+		if err != nil {
+			return err
+		}
+		if p == "" {
+			continue
+		}
+
+		xattrs := make(map[string][]byte, len(h.Xattrs))
+		for name, val := range h.Xattrs {
+			xattrs[name] = []byte(val)
+		}
+		attrs := adder.archiveAttrs(h.FileInfo().Mode(), h.ModTime, xattrs)
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = adder.addArchiveDir(p, attrs)
+		case tar.TypeReg, tar.TypeRegA:
+			err = adder.addArchiveFile(p, tr, attrs)
+		case tar.TypeSymlink:
+			err = adder.addArchiveEntry(p, func() error {
+				return adder.addSymlink(h.Linkname, p)
+			})
+		case tar.TypeLink:
+			err = adder.addArchiveLink(p, h.Linkname)
+		default:
+			log.Warningf("skipping %s: unsupported tar entry type %q", h.Name, h.Typeflag)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", h.Name, err)
+		}
+	}
+}
+
+// AddZip adds the entries of the zip archive read from r, of the given size,
+// like AddTar does.
+func (adder *Adder) AddZip(r io.ReaderAt, size int64) error {
+	if adder.Pin {
+		adder.unlocker = adder.blockstore.PinLock()
+	}
+	defer func() {
+		if adder.unlocker != nil {
+			adder.unlocker.Unlock()
+		}
+	}()
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if err := adder.checkpoint(); err != nil {
+			return err
+		}
+
+		p, err := archivePath(f.Name)
+		if err != nil {
+			return err
+		}
+		if p == "" {
+			continue
+		}
+
+		mode := f.Mode()
+		attrs := adder.archiveAttrs(mode, f.ModTime(), nil)
+		switch {
+		case mode.IsDir() || strings.HasSuffix(f.Name, "/"):
+			err = adder.addArchiveDir(p, attrs)
+		case mode&os.ModeSymlink != 0:
+			err = adder.addArchiveEntry(p, func() error {
+				target, err := readZipFile(f)
+				if err != nil {
+					return err
+				}
+				return adder.addSymlink(string(target), p)
+			})
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			rc, err = f.Open()
+			if err != nil {
+				break
+			}
+			err = adder.addArchiveFile(p, rc, attrs)
+			rc.Close()
+		default:
+			log.Warningf("skipping %s: unsupported zip entry mode %s", f.Name, mode)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// archivePath returns the path of an entry of an archive, relative to the
+// root of the adder.
+func archivePath(name string) (string, error) {
+	p := gopath.Clean("/" + name)
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid path in archive: %s", name)
+		}
+	}
+	return strings.TrimPrefix(p, "/"), nil
+}
+
+// archiveAttrs returns the attributes of an entry of an archive the adder
+// preserves.
+func (adder *Adder) archiveAttrs(mode os.FileMode, mtime time.Time, xattrs map[string][]byte) unixfs.Attrs {
+	var attrs unixfs.Attrs
+	if adder.PreserveMode {
+		attrs.Mode = mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+	if adder.PreserveMtime {
+		attrs.ModTime = mtime
+	}
+	if adder.PreserveXAttrs && len(xattrs) > 0 {
+		attrs.XAttrs = xattrs
+	}
+	return attrs
+}
+
+// addArchiveEntry calls add to add the entry at p, after removing the one
+// added there before, as the last entry of an archive with a given path
+// replaces the others.
+func (adder *Adder) addArchiveEntry(p string, add func() error) error {
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	if _, err := mfs.Lookup(mr, p); err == nil {
+		dirp, _ := gopath.Split(p)
+		fsn, err := mfs.Lookup(mr, dirp)
+		if err != nil {
+			return err
+		}
+		dir, ok := fsn.(*mfs.Directory)
+		if !ok {
+			return fmt.Errorf("%s is not a directory", dirp)
+		}
+		if err := dir.Unlink(gopath.Base(p)); err != nil {
+			return err
+		}
+	}
+	return add()
+}
+
+func (adder *Adder) addArchiveFile(p string, r io.Reader, attrs unixfs.Attrs) error {
+	return adder.addArchiveEntry(p, func() error {
+		nd, err := adder.add(r, attrs, nil)
+		if err != nil {
+			return err
+		}
+		return adder.addNode(nd, p)
+	})
+}
+
+func (adder *Adder) addArchiveLink(p, target string) error {
+	tp, err := archivePath(target)
+	if err != nil {
+		return err
+	}
+
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	fsn, err := mfs.Lookup(mr, tp)
+	if err != nil {
+		return fmt.Errorf("hard link to %s: %s", target, err)
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return err
+	}
+
+	return adder.addArchiveEntry(p, func() error {
+		return adder.addNode(nd, p)
+	})
+}
+
+func (adder *Adder) addArchiveDir(p string, attrs unixfs.Attrs) error {
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	err = mfs.Mkdir(mr, p, mfs.MkdirOpts{
+		Mkparents: true,
+		Flush:     false,
+		Prefix:    adder.Prefix,
+	})
+	if err != nil {
+		return err
+	}
+	if attrs.IsZero() {
+		return nil
+	}
+
+	fsn, err := mfs.Lookup(mr, p)
+	if err != nil {
+		return err
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("%s is not a directory", p)
+	}
+	return dir.SetAttrs(attrs)
+}
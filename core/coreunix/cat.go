@@ -20,5 +20,5 @@ func Cat(ctx context.Context, n *core.IpfsNode, pstr string) (uio.DagReader, err
 		return nil, err
 	}
 
-	return uio.NewDagReader(ctx, dagNode, n.DAG)
+	return uio.NewDagReader(ctx, dagNode, n.DAG, uio.WithFetchAhead(n.FetchAhead))
 }
@@ -0,0 +1,11 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package coreunix
+
+import "os"
+
+// inodeOf returns the device and inode of the file described by st, if it
+// has other hard links. Hard links are not detected on this platform.
+func inodeOf(st os.FileInfo) (inode, bool) {
+	return inode{}, false
+}
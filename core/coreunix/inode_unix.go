@@ -0,0 +1,18 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package coreunix
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the device and inode of the file described by st, if it
+// has other hard links.
+func inodeOf(st os.FileInfo) (inode, bool) {
+	sys, ok := st.Sys().(*syscall.Stat_t)
+	if !ok || sys.Nlink < 2 {
+		return inode{}, false
+	}
+	return inode{dev: uint64(sys.Dev), ino: uint64(sys.Ino)}, true
+}
@@ -0,0 +1,134 @@
+package coreunix
+
+import (
+	"context"
+	"io"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	files "github.com/ipfs/go-ipfs-cmdkit/files"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ProgressEvent is the kind of a progress update of an add.
+type ProgressEvent string
+
+const (
+	// ProgressStarted is sent before a file is read.
+	ProgressStarted ProgressEvent = "started"
+	// ProgressHashing is sent every progressReaderIncrement bytes read.
+	ProgressHashing ProgressEvent = "hashing"
+	// ProgressCompleted is sent once all the blocks of a file are written.
+	ProgressCompleted ProgressEvent = "completed"
+)
+
+// AddProgress is how far the add of a file has come.
+type AddProgress struct {
+	Event ProgressEvent
+	Name  string
+
+	Bytes  int64  // bytes of the file hashed so far
+	Blocks uint64 // blocks of the file written so far
+	Dedup  uint64 // blocks written which were already stored
+
+	Cid *cid.Cid // the root of the file, once completed
+}
+
+// tracksProgress reports whether the progress of files is reported.
+func (adder *Adder) tracksProgress() bool {
+	return adder.Progress || adder.OnProgress != nil
+}
+
+// report passes p to OnProgress, and sends it over the output channel if the
+// progress flag was specified.
+func (adder *Adder) report(p AddProgress) {
+	if adder.OnProgress != nil {
+		adder.OnProgress(p)
+	}
+	if adder.Progress {
+		adder.Out <- &AddedObject{
+			Name:   p.Name,
+			Bytes:  p.Bytes,
+			Event:  p.Event,
+			Blocks: p.Blocks,
+			Dedup:  p.Dedup,
+		}
+	}
+}
+
+// startProgress reports that the file called name, read from r, is started,
+// and returns r wrapped to report the bytes read from it.
+func (adder *Adder) startProgress(name string, r io.Reader) *progressReader {
+	rdr := &progressReader{
+		r:        r,
+		adder:    adder,
+		progress: &AddProgress{Event: ProgressStarted, Name: name},
+	}
+	adder.report(*rdr.progress)
+	return rdr
+}
+
+// completeProgress reports that the file of progress was added as nd.
+func (adder *Adder) completeProgress(progress *AddProgress, nd ipld.Node) {
+	progress.Event = ProgressCompleted
+	progress.Cid = nd.Cid()
+	adder.report(*progress)
+}
+
+type progressReader struct {
+	r            io.Reader
+	adder        *Adder
+	progress     *AddProgress
+	lastProgress int64
+}
+
+func (i *progressReader) Read(p []byte) (int, error) {
+	n, err := i.r.Read(p)
+
+	i.progress.Bytes += int64(n)
+	if i.progress.Bytes-i.lastProgress >= progressReaderIncrement {
+		i.lastProgress = i.progress.Bytes
+		i.progress.Event = ProgressHashing
+		i.adder.report(*i.progress)
+	}
+
+	return n, err
+}
+
+type progressReader2 struct {
+	*progressReader
+	files.FileInfo
+}
+
+// countingDAG counts the blocks of a file written, and those which were
+// already in the blockstore, in progress.
+type countingDAG struct {
+	ipld.DAGService
+	bs       bstore.Blockstore
+	progress *AddProgress
+}
+
+func (d *countingDAG) Add(ctx context.Context, nd ipld.Node) error {
+	d.count(nd)
+	return d.DAGService.Add(ctx, nd)
+}
+
+func (d *countingDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		d.count(nd)
+	}
+	return d.DAGService.AddMany(ctx, nds)
+}
+
+func (d *countingDAG) count(nd ipld.Node) {
+	if dag.IsInlined(nd.Cid()) {
+		// not written
+		return
+	}
+	d.progress.Blocks++
+	if has, err := d.bs.Has(nd.Cid()); err == nil && has {
+		d.progress.Dedup++
+	}
+}
@@ -0,0 +1,160 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	gopath "path"
+
+	ihelper "github.com/ipfs/go-ipfs/importer/helpers"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	cid "github.com/ipfs/go-cid"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// SetReproducible makes the adder build the same DAG for the same files on
+// any node, whatever its configuration: files are split into chunks of the
+// default size, arranged as balanced DAGs of CIDv0 nodes without raw leaves,
+// and none of their attributes are recorded. The links of directories are
+// always sorted by name.
+//
+// It fails if directories aren't sharded the default way, which is set for
+// the whole process by the configuration of the node.
+func (adder *Adder) SetReproducible() error {
+	if uio.UseHAMTSharding || uio.ShardSplitThreshold != 0 ||
+		uio.ShardSizeThreshold != uio.DefaultShardSizeThreshold {
+		return fmt.Errorf("reproducible adds need the default directory sharding settings")
+	}
+
+	prefix, err := dag.PrefixForCidVersion(0)
+	if err != nil {
+		return err
+	}
+	adder.Prefix = &prefix
+	adder.Chunker = ""
+	adder.Layout = ""
+	adder.Trickle = false
+	adder.RawLeaves = false
+	adder.NoCopy = false
+	adder.InlineLimit = 0
+	adder.PreserveMode = false
+	adder.PreserveMtime = false
+	adder.PreserveXAttrs = false
+	adder.syncRoot = nil
+	return nil
+}
+
+// VerifyReproducible checks that the DAG below root, read from ng, is built
+// the way adders set with SetReproducible build it, so adding the same files
+// again gives the same root. Exports of such DAGs as CAR files are the same
+// byte for byte too, as their blocks are written in the order of the links.
+func VerifyReproducible(ctx context.Context, ng ipld.NodeGetter, root *cid.Cid) error {
+	v := &reproducibleVerifier{ng: ng, root: root, seen: cid.NewSet()}
+	return v.verify(ctx, root, "")
+}
+
+type reproducibleVerifier struct {
+	ng   ipld.NodeGetter
+	root *cid.Cid
+	seen *cid.Set
+}
+
+func (v *reproducibleVerifier) verify(ctx context.Context, c *cid.Cid, p string) error {
+	if !v.seen.Visit(c) {
+		return nil
+	}
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%s is not reproducible: %s", gopath.Join("/ipfs", v.root.String(), p), fmt.Sprintf(format, args...))
+	}
+
+	if c.Prefix().Version != 0 {
+		return fail("not a CIDv0")
+	}
+	nd, err := v.ng.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return fail("not a protobuf node")
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pbnd.Data())
+	if err != nil {
+		return err
+	}
+	if !fsn.Attrs.IsZero() {
+		return fail("records attributes")
+	}
+
+	switch fsn.Type {
+	case unixfs.TDirectory:
+		for i, l := range pbnd.Links() {
+			if i > 0 && pbnd.Links()[i-1].Name >= l.Name {
+				return fail("links not sorted by name")
+			}
+			if err := v.verify(ctx, l.Cid, gopath.Join(p, l.Name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case unixfs.THAMTShard:
+		for _, l := range pbnd.Links() {
+			if err := v.verify(ctx, l.Cid, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	case unixfs.TSymlink:
+		return nil
+	case unixfs.TFile:
+		if fsn.Layout != "" {
+			return fail("built with the %s layout", fsn.Layout)
+		}
+		var leaves []uint64
+		if err := v.fileLeaves(ctx, pbnd, &leaves); err != nil {
+			return fail("%s", err)
+		}
+		for i, size := range leaves {
+			if i < len(leaves)-1 && size != uint64(chunker.DefaultBlockSize) {
+				return fail("chunk %d of %d bytes", i, size)
+			}
+		}
+		return nil
+	default:
+		return fail("unixfs node of type %s", fsn.Type)
+	}
+}
+
+// fileLeaves appends the sizes of the data of the leaves of the file below
+// nd to leaves, in order.
+func (v *reproducibleVerifier) fileLeaves(ctx context.Context, nd *dag.ProtoNode, leaves *[]uint64) error {
+	if len(nd.Links()) > ihelper.DefaultLinksPerBlock {
+		return fmt.Errorf("%d links in a node", len(nd.Links()))
+	}
+	if len(nd.Links()) == 0 {
+		fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return err
+		}
+		*leaves = append(*leaves, uint64(len(fsn.Data)))
+		return nil
+	}
+
+	for _, l := range nd.Links() {
+		child, err := l.GetNode(ctx, v.ng)
+		if err != nil {
+			return err
+		}
+		pbchild, ok := child.(*dag.ProtoNode)
+		if !ok || child.Cid().Prefix().Version != 0 {
+			return fmt.Errorf("raw leaves")
+		}
+		if err := v.fileLeaves(ctx, pbchild, leaves); err != nil {
+			return err
+		}
+	}
+	return nil
+}
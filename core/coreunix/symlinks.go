@@ -0,0 +1,133 @@
+package coreunix
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	files "github.com/ipfs/go-ipfs-cmdkit/files"
+)
+
+// SymlinkPolicy is how an adder handles the symlinks of the files added.
+type SymlinkPolicy int
+
+const (
+	// SymlinksPreserve adds symlinks as unixfs symlinks, the default.
+	SymlinksPreserve SymlinkPolicy = iota
+	// SymlinksFollow adds the files symlinks point to in their place.
+	SymlinksFollow
+	// SymlinksForbid fails adds which meet a symlink.
+	SymlinksForbid
+)
+
+// ParseSymlinkPolicy returns the symlink policy with the given name:
+// preserve, follow or forbid.
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	switch name {
+	case "", "preserve":
+		return SymlinksPreserve, nil
+	case "follow":
+		return SymlinksFollow, nil
+	case "forbid":
+		return SymlinksForbid, nil
+	default:
+		return 0, fmt.Errorf("unknown symlink policy: %s", name)
+	}
+}
+
+// FollowSymlinks returns file with the symlinks it holds, or it is, replaced
+// by the files or directories they point to on disk, with their name. It
+// fails on symlinks which weren't read from disk, and the directories it
+// returns fail on symlinks to one of the directories holding them.
+//
+// Files are sent to a daemon over the API with their symlinks replaced the
+// same way, so symlinks are followed by the process reading the files.
+func FollowSymlinks(file files.File) (files.File, error) {
+	return followSymlinks(file, make(map[string]bool))
+}
+
+// followSymlinks follows file, inside the directories of ancestors, by their
+// path with symlinks resolved.
+func followSymlinks(file files.File, ancestors map[string]bool) (files.File, error) {
+	if s, ok := file.(*files.Symlink); ok {
+		if s.FullPath() == "" {
+			return nil, fmt.Errorf("cannot follow symlink %s: not read from disk", s.FileName())
+		}
+		p, err := filepath.EvalSymlinks(s.FullPath())
+		if err != nil {
+			return nil, err
+		}
+		st, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if st.IsDir() {
+			file, err = files.NewSerialFile(s.FileName(), p, true, st)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			f, err := os.Open(p)
+			if err != nil {
+				return nil, err
+			}
+			file = files.NewReaderFile(s.FileName(), p, f, st)
+		}
+	}
+
+	if !file.IsDirectory() {
+		return file, nil
+	}
+
+	d := &followDir{File: file, ancestors: ancestors}
+	if fi, ok := file.(files.FileInfo); ok && fi.AbsPath() != "" {
+		p, err := filepath.EvalSymlinks(fi.AbsPath())
+		if err != nil {
+			return nil, err
+		}
+		if ancestors[p] {
+			return nil, fmt.Errorf("symlink loop at %s", file.FileName())
+		}
+		ancestors[p] = true
+		d.path = p
+	}
+	return d, nil
+}
+
+// followDir is a directory whose symlinks are followed.
+type followDir struct {
+	files.File
+
+	// path is the path of the directory with symlinks resolved, which is
+	// in ancestors while its entries are read.
+	path      string
+	ancestors map[string]bool
+}
+
+func (d *followDir) NextFile() (files.File, error) {
+	file, err := d.File.NextFile()
+	if err == io.EOF && d.path != "" {
+		delete(d.ancestors, d.path)
+	}
+	if err != nil || file == nil {
+		return file, err
+	}
+	return followSymlinks(file, d.ancestors)
+}
+
+// AbsPath and Stat keep the attributes of directories read from disk.
+func (d *followDir) AbsPath() string {
+	if fi, ok := d.File.(files.FileInfo); ok {
+		return fi.AbsPath()
+	}
+	return ""
+}
+
+func (d *followDir) Stat() os.FileInfo {
+	if fi, ok := d.File.(files.FileInfo); ok {
+		return fi.Stat()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package coreunix
+
+import (
+	"strings"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	files "github.com/ipfs/go-ipfs-cmdkit/files"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// SetSyncRoot makes the adder sync the files added with root, the root of a
+// previous add of the same files read from dserv: the files whose size and
+// recorded attributes, including their modification time, didn't change
+// since are not read again, their nodes in root are reused instead.
+//
+// The modification time of the files is only known for files read from disk
+// by the adder, and only compared if the adder preserves it, so the adds to
+// sync with should set PreserveMtime.
+func (adder *Adder) SetSyncRoot(root ipld.Node, dserv ipld.DAGService) {
+	adder.syncRoot = root
+	adder.syncServ = dserv
+}
+
+// syncedFile returns the node of file in the sync root if file didn't change
+// since, or nil.
+func (adder *Adder) syncedFile(file files.File, attrs unixfs.Attrs) ipld.Node {
+	if adder.syncRoot == nil || attrs.ModTime.IsZero() {
+		return nil
+	}
+	fi, ok := file.(files.FileInfo)
+	if !ok || fi.Stat() == nil {
+		return nil
+	}
+
+	nd, err := adder.syncLookup(file.FileName())
+	if err != nil {
+		log.Debugf("syncing %s: %s", file.FileName(), err)
+		return nil
+	}
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pbnd.Data())
+	if err != nil || fsn.Type != unixfs.TFile {
+		return nil
+	}
+	old, err := unixfs.AttrsFromBytes(pbnd.Data())
+	if err != nil {
+		return nil
+	}
+
+	if fsn.FileSize() != uint64(fi.Stat().Size()) || !old.Equal(attrs) {
+		return nil
+	}
+	return nd
+}
+
+// syncLookup returns the node at the path of a file added in the sync root.
+func (adder *Adder) syncLookup(name string) (ipld.Node, error) {
+	parts := strings.Split(name, "/")
+	if !adder.Wrap {
+		// the root is the node of the first file added
+		parts = parts[1:]
+	}
+
+	nd := adder.syncRoot
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		dir, err := uio.NewDirectoryFromNode(adder.syncServ, nd)
+		if err != nil {
+			return nil, err
+		}
+		nd, err = dir.Find(adder.ctx, part)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nd, nil
+}
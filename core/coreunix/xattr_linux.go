@@ -0,0 +1,41 @@
+package coreunix
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// readXAttrs returns the extended attributes of the file at path.
+func readXAttrs(path string) (map[string][]byte, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err == syscall.ENOTSUP {
+		return nil, nil
+	}
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string][]byte)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		vsize, err := syscall.Getxattr(path, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		val := make([]byte, vsize)
+		vsize, err = syscall.Getxattr(path, string(name), val)
+		if err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = val[:vsize]
+	}
+	return xattrs, nil
+}
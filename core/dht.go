@@ -0,0 +1,452 @@
+package core
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	p2phost "github.com/libp2p/go-libp2p-host"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+	routing "github.com/libp2p/go-libp2p-routing"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DHT modes. In server mode the node answers the DHT requests of other
+// peers, in client mode it only sends its own. In auto mode it serves while
+// it has a public address.
+const (
+	DHTModeServer = "server"
+	DHTModeClient = "client"
+	DHTModeAuto   = "auto"
+)
+
+// ErrNoDHT is returned by SetDHTMode for nodes not routing through the DHT.
+var ErrNoDHT = errors.New("routing is not done through the DHT")
+
+// ErrRecordLimit is returned when the DHT stores as many records as allowed
+// by Routing.MaxRecords.
+var ErrRecordLimit = errors.New("dht record limit reached")
+
+// reachabilityInterval is how often nodes in auto mode check whether they
+// have a public address.
+var reachabilityInterval = time.Minute
+
+// DHT returns the DHT the node routes through, or nil.
+func (n *IpfsNode) DHT() *dht.IpfsDHT {
+	switch r := n.Routing.(type) {
+	case *dhtRouting:
+		return r.IpfsDHT
+	case *dht.IpfsDHT:
+		return r
+	}
+	return nil
+}
+
+// DHTMode returns the mode of the DHT of the node, and whether it currently
+// answers the requests of other peers.
+func (n *IpfsNode) DHTMode() (mode string, serving bool, err error) {
+	d, ok := n.Routing.(*dhtRouting)
+	if !ok {
+		return "", false, ErrNoDHT
+	}
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	return d.mode, d.host.isServing(), nil
+}
+
+// SetDHTMode switches the DHT of the node to mode, until the node goes
+// offline.
+func (n *IpfsNode) SetDHTMode(mode string) error {
+	d, ok := n.Routing.(*dhtRouting)
+	if !ok {
+		return ErrNoDHT
+	}
+	return d.setMode(mode)
+}
+
+// dhtHost is the host of the DHT. It keeps the stream handlers of the DHT
+// instead of setting them, so that they are only set in server mode.
+type dhtHost struct {
+	p2phost.Host
+
+	lk       sync.Mutex
+	handlers map[protocol.ID]inet.StreamHandler
+	serving  bool
+}
+
+func (h *dhtHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	h.handlers[pid] = handler
+	if h.serving {
+		h.Host.SetStreamHandler(pid, handler)
+	}
+}
+
+func (h *dhtHost) RemoveStreamHandler(pid protocol.ID) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	delete(h.handlers, pid)
+	h.Host.RemoveStreamHandler(pid)
+}
+
+// serve sets the stream handlers of the DHT on the host, or removes them.
+func (h *dhtHost) serve(serving bool) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	if serving == h.serving {
+		return
+	}
+	h.serving = serving
+	for pid, handler := range h.handlers {
+		if serving {
+			h.Host.SetStreamHandler(pid, handler)
+		} else {
+			h.Host.RemoveStreamHandler(pid)
+		}
+	}
+}
+
+func (h *dhtHost) isServing() bool {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return h.serving
+}
+
+// dhtRouting is the DHT of the node, running in one of the DHT modes, and
+// bounding the queries it runs at once.
+type dhtRouting struct {
+	*dht.IpfsDHT
+	host *dhtHost
+	ctx  context.Context
+
+	lk         sync.Mutex
+	mode       string
+	cancelAuto func()
+
+	// queries holds a token per running query, nil when unlimited
+	queries chan struct{}
+}
+
+func newDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.Batching, mode string) (*dhtRouting, error) {
+	h := &dhtHost{
+		Host:     host,
+		handlers: make(map[protocol.ID]inet.StreamHandler),
+	}
+	d := &dhtRouting{
+		IpfsDHT: dht.NewDHT(ctx, h, dstore),
+		host:    h,
+		ctx:     ctx,
+	}
+	d.Validator[IpnsValidatorTag] = namesys.NewIpnsRecordValidator(host.Peerstore())
+	d.Selector[IpnsValidatorTag] = namesys.IpnsSelectorFunc
+	if err := d.setMode(mode); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *dhtRouting) setMode(mode string) error {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	switch mode {
+	case DHTModeServer, DHTModeClient, DHTModeAuto:
+	default:
+		return fmt.Errorf("unknown dht mode: %q", mode)
+	}
+
+	if d.cancelAuto != nil {
+		d.cancelAuto()
+		d.cancelAuto = nil
+	}
+	d.mode = mode
+
+	switch mode {
+	case DHTModeServer:
+		d.host.serve(true)
+	case DHTModeClient:
+		d.host.serve(false)
+	case DHTModeAuto:
+		ctx, cancel := context.WithCancel(d.ctx)
+		d.cancelAuto = cancel
+		d.host.serve(hasPublicAddr(d.host.Addrs()))
+		go d.autoServe(ctx)
+	}
+	return nil
+}
+
+// autoServe serves while the host has a public address.
+func (d *dhtRouting) autoServe(ctx context.Context) {
+	t := time.NewTicker(reachabilityInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			serving := hasPublicAddr(d.host.Addrs())
+			if serving != d.host.isServing() {
+				log.Infof("dht auto mode: serving set to %t", serving)
+			}
+			d.host.serve(serving)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// limitQueries bounds the queries run at once to max, when above 0. It must
+// be called before the DHT is used.
+func (d *dhtRouting) limitQueries(max int) {
+	if max > 0 {
+		d.queries = make(chan struct{}, max)
+	}
+}
+
+func (d *dhtRouting) acquire(ctx context.Context) error {
+	if d.queries == nil {
+		return nil
+	}
+	select {
+	case d.queries <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *dhtRouting) release() {
+	if d.queries != nil {
+		<-d.queries
+	}
+}
+
+func (d *dhtRouting) PutValue(ctx context.Context, key string, value []byte) error {
+	if err := d.acquire(ctx); err != nil {
+		return err
+	}
+	defer d.release()
+	return d.IpfsDHT.PutValue(ctx, key, value)
+}
+
+func (d *dhtRouting) GetValue(ctx context.Context, key string) ([]byte, error) {
+	if err := d.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.release()
+	return d.IpfsDHT.GetValue(ctx, key)
+}
+
+func (d *dhtRouting) GetValues(ctx context.Context, key string, count int) ([]routing.RecvdVal, error) {
+	if err := d.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.release()
+	return d.IpfsDHT.GetValues(ctx, key, count)
+}
+
+func (d *dhtRouting) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	if err := d.acquire(ctx); err != nil {
+		return err
+	}
+	defer d.release()
+	return d.IpfsDHT.Provide(ctx, c, brdcst)
+}
+
+func (d *dhtRouting) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	if err := d.acquire(ctx); err != nil {
+		return pstore.PeerInfo{}, err
+	}
+	defer d.release()
+	return d.IpfsDHT.FindPeer(ctx, p)
+}
+
+func (d *dhtRouting) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo)
+	go func() {
+		defer close(out)
+		if err := d.acquire(ctx); err != nil {
+			return
+		}
+		defer d.release()
+		for info := range d.IpfsDHT.FindProvidersAsync(ctx, c, count) {
+			select {
+			case out <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+var privateNets []*net.IPNet
+
+func init() {
+	for _, s := range []string{
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+	} {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		privateNets = append(privateNets, n)
+	}
+}
+
+// hasPublicAddr returns whether one of addrs is a public IP address, not
+// through a relay.
+func hasPublicAddr(addrs []ma.Multiaddr) bool {
+	for _, a := range addrs {
+		if _, err := a.ValueForProtocol(circuit.P_CIRCUIT); err == nil {
+			continue
+		}
+		var s string
+		var err error
+		if s, err = a.ValueForProtocol(ma.P_IP4); err != nil {
+			if s, err = a.ValueForProtocol(ma.P_IP6); err != nil {
+				continue
+			}
+		}
+		ip := net.ParseIP(s)
+		if ip == nil || ip.IsUnspecified() {
+			continue
+		}
+		private := false
+		for _, n := range privateNets {
+			if n.Contains(ip) {
+				private = true
+				break
+			}
+		}
+		if !private {
+			return true
+		}
+	}
+	return false
+}
+
+// recordLimitDatastore is the datastore of the DHT, refusing new records
+// once max of them are stored. Only the records the DHT stores for other
+// peers count: values, stored at the root under the base32 of their key,
+// and provider records, under /providers/<cid>/<peer>. The provider records
+// of the node itself and its own IPNS record and public key are always
+// stored, as is everything else the repo keeps in the datastore.
+type recordLimitDatastore struct {
+	ds.Batching
+	max  int
+	self peer.ID
+
+	lk   sync.Mutex
+	keys map[ds.Key]struct{}
+}
+
+// limitRecords wraps d to store at most max records of other peers,
+// counting those already stored.
+func limitRecords(d ds.Batching, max int, self peer.ID) (ds.Batching, error) {
+	if max <= 0 {
+		return d, nil
+	}
+	l := &recordLimitDatastore{
+		Batching: d,
+		max:      max,
+		self:     self,
+		keys:     make(map[ds.Key]struct{}),
+	}
+
+	res, err := d.Query(dsq.Query{Prefix: "/", KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		k := ds.NewKey(e.Key)
+		if l.limited(k) {
+			l.keys[k] = struct{}{}
+		}
+	}
+	return l, nil
+}
+
+// limited returns whether k is the key of a record counting towards the
+// limit.
+func (d *recordLimitDatastore) limited(k ds.Key) bool {
+	ns := k.Namespaces()
+	switch {
+	case len(ns) == 1:
+		if !isRecordKey(ns[0]) {
+			return false
+		}
+		for _, own := range []string{"/ipns/", "/pk/"} {
+			if ns[0] == base32.RawStdEncoding.EncodeToString([]byte(own+string(d.self))) {
+				return false
+			}
+		}
+		return true
+	case len(ns) == 3 && ns[0] == "providers":
+		return ns[2] != base32.RawStdEncoding.EncodeToString([]byte(d.self))
+	}
+	return false
+}
+
+// isRecordKey returns whether s is in the unpadded base32 the DHT encodes
+// the keys of its records with.
+func isRecordKey(s string) bool {
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && (r < '2' || r > '7') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (d *recordLimitDatastore) Put(k ds.Key, v interface{}) error {
+	if !d.limited(k) {
+		return d.Batching.Put(k, v)
+	}
+
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	if _, ok := d.keys[k]; !ok {
+		if len(d.keys) >= d.max {
+			return ErrRecordLimit
+		}
+		d.keys[k] = struct{}{}
+	}
+	return d.Batching.Put(k, v)
+}
+
+func (d *recordLimitDatastore) Delete(k ds.Key) error {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	delete(d.keys, k)
+	return d.Batching.Delete(k)
+}
+
+func (d *recordLimitDatastore) Batch() (ds.Batch, error) {
+	return ds.NewBasicBatch(d), nil
+}
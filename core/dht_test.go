@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/base32"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestHasPublicAddr(t *testing.T) {
+	for s, public := range map[string]bool{
+		"/ip4/127.0.0.1/tcp/4001":      false,
+		"/ip4/192.168.1.10/tcp/4001":   false,
+		"/ip4/100.64.3.1/tcp/4001":     false,
+		"/ip4/0.0.0.0/tcp/4001":        false,
+		"/ip6/::1/tcp/4001":            false,
+		"/ip6/fe80::1/tcp/4001":        false,
+		"/ip4/8.8.8.8/tcp/4001":        true,
+		"/ip6/2001:db8::1/udp/4001":    true,
+		"/dns4/example.com/tcp/4001":   false,
+		"/ip4/104.131.131.82/tcp/4001": true,
+		"/ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit": false,
+	} {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasPublicAddr([]ma.Multiaddr{a}) != public {
+			t.Errorf("%s: expected public to be %t", s, public)
+		}
+	}
+}
+
+func TestLimitRecords(t *testing.T) {
+	self := peer.ID("self")
+	other := peer.ID("other")
+	b32 := func(s string) string {
+		return base32.RawStdEncoding.EncodeToString([]byte(s))
+	}
+	record := func(s string) ds.Key {
+		return ds.NewKey(b32(s))
+	}
+	provider := func(s string, p peer.ID) ds.Key {
+		return ds.NewKey("/providers/" + b32(s)).ChildString(b32(string(p)))
+	}
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	for _, k := range []ds.Key{record("old"), ds.NewKey("/local/filesroot")} {
+		if err := d.Put(k, []byte("old")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := limitRecords(d, 3, self)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []ds.Key{record("a"), provider("b", other), record("a"), record("old")} {
+		if err := l.Put(k, []byte("v")); err != nil {
+			t.Fatalf("putting %s: %s", k, err)
+		}
+	}
+	if err := l.Put(record("c"), []byte("c")); err != ErrRecordLimit {
+		t.Fatalf("expected %s, got %v", ErrRecordLimit, err)
+	}
+
+	// the records of the node itself and the rest of the repo aren't limited
+	for _, k := range []ds.Key{
+		provider("c", self),
+		record("/ipns/" + string(self)),
+		ds.NewKey("/local/pins"),
+	} {
+		if err := l.Put(k, []byte("v")); err != nil {
+			t.Fatalf("putting %s: %s", k, err)
+		}
+	}
+
+	if err := l.Delete(record("a")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(record("c"), []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(record("d"), []byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != ErrRecordLimit {
+		t.Fatalf("expected %s, got %v", ErrRecordLimit, err)
+	}
+}
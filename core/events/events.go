@@ -0,0 +1,182 @@
+// Package events delivers the events of the subsystems of a node to the
+// subscribers interested in them, so that dashboards can follow a node without
+// polling it.
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+
+	cid "github.com/ipfs/go-cid"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// Type is the kind of an Event.
+type Type string
+
+const (
+	// PeerConnected is emitted when a connection to a peer opens.
+	PeerConnected Type = "peer-connected"
+
+	// PeerDisconnected is emitted when a connection to a peer closes.
+	PeerDisconnected Type = "peer-disconnected"
+
+	// BlockFetched is emitted for every block received from a peer.
+	BlockFetched Type = "block-fetched"
+
+	// PinCompleted is emitted once a DAG is pinned.
+	PinCompleted Type = "pin-completed"
+
+	// GCFinished is emitted once a garbage collection finishes.
+	GCFinished Type = "gc-finished"
+
+	// IpnsPublished is emitted once an IPNS name is published.
+	IpnsPublished Type = "ipns-published"
+)
+
+// Types are all the kinds of events.
+var Types = []Type{PeerConnected, PeerDisconnected, BlockFetched, PinCompleted, GCFinished, IpnsPublished}
+
+// Event is something which happened on the node.
+type Event struct {
+	Type Type
+	Time time.Time
+
+	// Peer is the peer connected, disconnected or which sent the block.
+	Peer peer.ID `json:",omitempty"`
+
+	// Addr is the address of the peer connected or disconnected.
+	Addr string `json:",omitempty"`
+
+	// Cid is the block fetched or the root of the DAG pinned.
+	Cid *cid.Cid `json:",omitempty"`
+
+	// Name is the IPNS name published, and Value the path it points to.
+	Name  string `json:",omitempty"`
+	Value string `json:",omitempty"`
+
+	// GC are the statistics of the garbage collection finished.
+	GC *gc.Stats `json:",omitempty"`
+}
+
+// Bus delivers events to subscribers.
+type Bus struct {
+	lk     sync.Mutex
+	subs   map[int]subscription
+	nextID int
+}
+
+type subscription struct {
+	f     func(Event)
+	types map[Type]bool // nil for all
+}
+
+// NewBus creates a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]subscription)}
+}
+
+// Subscribe registers f to be called with every event of the given types, or
+// of all types if none are given, until cancel is called. f must not block.
+func (b *Bus) Subscribe(f func(Event), types ...Type) (cancel func()) {
+	sub := subscription{f: f}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = sub
+	return func() {
+		b.lk.Lock()
+		delete(b.subs, id)
+		b.lk.Unlock()
+	}
+}
+
+// Emit delivers ev to the subscribers of its type, stamping it with the
+// current time unless it has one.
+func (b *Bus) Emit(ev Event) {
+	if b == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.lk.Lock()
+	fs := make([]func(Event), 0, len(b.subs))
+	for _, sub := range b.subs {
+		if sub.types == nil || sub.types[ev.Type] {
+			fs = append(fs, sub.f)
+		}
+	}
+	b.lk.Unlock()
+
+	for _, f := range fs {
+		f(ev)
+	}
+}
+
+// Notifiee returns a network notifiee emitting the connections and
+// disconnections of peers on b.
+func (b *Bus) Notifiee() inet.Notifiee {
+	emit := func(t Type, c inet.Conn) {
+		b.Emit(Event{Type: t, Peer: c.RemotePeer(), Addr: c.RemoteMultiaddr().String()})
+	}
+	return &inet.NotifyBundle{
+		ConnectedF: func(_ inet.Network, c inet.Conn) {
+			emit(PeerConnected, c)
+		},
+		DisconnectedF: func(_ inet.Network, c inet.Conn) {
+			emit(PeerDisconnected, c)
+		},
+	}
+}
+
+// ForwardGC emits the garbage collections finished on bus on b, until
+// cancel is called.
+func (b *Bus) ForwardGC(bus *gc.Bus) (cancel func()) {
+	return bus.Subscribe(func(ev gc.Event) {
+		if ev.Type != gc.EventFinished {
+			return
+		}
+		stats := ev.Stats
+		b.Emit(Event{Type: GCFinished, Time: ev.Time, GC: &stats})
+	})
+}
+
+// ParseTypes parses the comma separated list of event types s. An empty s
+// stands for all types.
+func ParseTypes(s string) ([]Type, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var out []Type
+	for _, name := range strings.Split(s, ",") {
+		t := Type(strings.TrimSpace(name))
+		known := false
+		for _, k := range Types {
+			if t == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown event type %q", t)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
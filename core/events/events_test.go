@@ -0,0 +1,54 @@
+package events
+
+import (
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	u "github.com/ipfs/go-ipfs-util"
+)
+
+func TestSubscribe(t *testing.T) {
+	b := NewBus()
+	c := cid.NewCidV0(u.Hash([]byte("block")))
+
+	var all, pins []Event
+	cancelAll := b.Subscribe(func(ev Event) { all = append(all, ev) })
+	cancelPins := b.Subscribe(func(ev Event) { pins = append(pins, ev) }, PinCompleted)
+
+	b.Emit(Event{Type: PinCompleted, Cid: c})
+	b.Emit(Event{Type: IpnsPublished, Name: "name", Value: "/ipfs/" + c.String()})
+	if len(all) != 2 || len(pins) != 1 {
+		t.Fatalf("expected 2 events and 1 pin, got %d and %d", len(all), len(pins))
+	}
+	if !pins[0].Cid.Equals(c) || pins[0].Time.IsZero() {
+		t.Fatalf("unexpected event %+v", pins[0])
+	}
+
+	cancelPins()
+	b.Emit(Event{Type: PinCompleted, Cid: c})
+	if len(all) != 3 || len(pins) != 1 {
+		t.Fatalf("expected 3 events and 1 pin, got %d and %d", len(all), len(pins))
+	}
+	cancelAll()
+
+	// nodes without a bus ignore events
+	var nilBus *Bus
+	nilBus.Emit(Event{Type: PinCompleted, Cid: c})
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes("peer-connected, gc-finished")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0] != PeerConnected || types[1] != GCFinished {
+		t.Fatalf("unexpected types %v", types)
+	}
+
+	if types, err := ParseTypes(""); err != nil || types != nil {
+		t.Fatalf("expected all types, got %v (%v)", types, err)
+	}
+	if _, err := ParseTypes("peer-connected,nope"); err == nil {
+		t.Fatal("expected unknown types to be rejected")
+	}
+}
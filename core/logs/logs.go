@@ -0,0 +1,278 @@
+// Package logs keeps the events logged by the subsystems of ipfs as
+// structured entries: it filters them by the level of their subsystem, keeps
+// the last ones in memory for bug reports, and streams them to subscribers.
+package logs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+)
+
+// DefaultSize is the number of entries kept in memory by default.
+const DefaultSize = 10000
+
+// Levels are the levels of entries, from the least to the most verbose, as
+// accepted by 'ipfs log level'.
+var Levels = []string{"critical", "error", "warning", "info", "debug"}
+
+const (
+	levelError = 1
+	levelInfo  = 3
+)
+
+// Entry is a structured log entry. The fields the subsystems commonly log are
+// promoted out of Fields: the CID and peer the entry is about, and the trace
+// ID of the operation it is part of, set with WithTrace.
+type Entry struct {
+	Time   time.Time              `json:"time"`
+	Level  string                 `json:"level"`
+	System string                 `json:"system"`
+	Event  string                 `json:"event"`
+	Cid    string                 `json:"cid,omitempty"`
+	Peer   string                 `json:"peer,omitempty"`
+	Trace  string                 `json:"trace,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Logger receives the events of go-log, written to it as JSON, and keeps
+// those its levels let through.
+type Logger struct {
+	lk      sync.Mutex
+	partial []byte
+
+	ring []Entry
+	next int
+	full bool
+
+	levels       map[string]int
+	defaultLevel int
+
+	subs   map[int]func(Entry)
+	nextID int
+}
+
+// New returns a logger keeping the last size entries.
+func New(size int) *Logger {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Logger{
+		ring:         make([]Entry, size),
+		levels:       make(map[string]int),
+		defaultLevel: levelInfo,
+		subs:         make(map[int]func(Entry)),
+	}
+}
+
+// Write parses the JSON events of p, one per line.
+func (l *Logger) Write(p []byte) (int, error) {
+	l.lk.Lock()
+	data := append(l.partial, p...)
+	var lines [][]byte
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, data[:i])
+		data = data[i+1:]
+	}
+	l.partial = append([]byte(nil), data...)
+	l.lk.Unlock()
+
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		e, err := parseEvent(line)
+		if err != nil {
+			// not worth failing the writer, and being removed from
+			// the writer group, for
+			continue
+		}
+		l.Add(e)
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer, so that the logger can be added to the
+// WriterGroup of go-log.
+func (l *Logger) Close() error {
+	return nil
+}
+
+// Add keeps e if the level of its system lets it through.
+func (l *Logger) Add(e Entry) {
+	l.lk.Lock()
+	if levelIndex(e.Level) > l.levelLocked(e.System) {
+		l.lk.Unlock()
+		return
+	}
+	l.ring[l.next] = e
+	l.next = (l.next + 1) % len(l.ring)
+	if l.next == 0 {
+		l.full = true
+	}
+	subs := make([]func(Entry), 0, len(l.subs))
+	for _, f := range l.subs {
+		subs = append(subs, f)
+	}
+	l.lk.Unlock()
+
+	for _, f := range subs {
+		f(e)
+	}
+}
+
+func (l *Logger) levelLocked(system string) int {
+	if lvl, ok := l.levels[system]; ok {
+		return lvl
+	}
+	return l.defaultLevel
+}
+
+// Entries returns the entries kept, oldest first.
+func (l *Logger) Entries() []Entry {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if !l.full {
+		return append([]Entry(nil), l.ring[:l.next]...)
+	}
+	out := make([]Entry, 0, len(l.ring))
+	out = append(out, l.ring[l.next:]...)
+	return append(out, l.ring[:l.next]...)
+}
+
+// Subscribe calls f with the entries kept from now on, until cancel is
+// called. f must not block.
+func (l *Logger) Subscribe(f func(Entry)) (cancel func()) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	id := l.nextID
+	l.nextID++
+	l.subs[id] = f
+	return func() {
+		l.lk.Lock()
+		defer l.lk.Unlock()
+		delete(l.subs, id)
+	}
+}
+
+// SetLevel sets the level of the entries of system kept, or of all systems
+// if system is "*".
+func (l *Logger) SetLevel(system, level string) error {
+	lvl := levelIndex(level)
+	if lvl < 0 {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	if system == "*" {
+		l.defaultLevel = lvl
+		l.levels = make(map[string]int)
+		return nil
+	}
+	l.levels[system] = lvl
+	return nil
+}
+
+func levelIndex(level string) int {
+	for i, l := range Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseEvent turns an event of go-log into an entry. Events are at level info,
+// or error when they carry an error.
+func parseEvent(data []byte) (Entry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{Level: Levels[levelInfo]}
+	take := func(key string) string {
+		v, ok := fields[key]
+		if !ok {
+			return ""
+		}
+		delete(fields, key)
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Sprint(v)
+		}
+		return s
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, take("time")); err == nil {
+		e.Time = t
+	} else {
+		e.Time = time.Now()
+	}
+	e.System = take("system")
+	e.Event = take("event")
+	e.Cid = take("cid")
+	e.Peer = take("peerID")
+	e.Trace = take("trace")
+	e.Error = take("error")
+	if e.Error != "" {
+		e.Level = Levels[levelError]
+	}
+	if len(fields) > 0 {
+		e.Fields = fields
+	}
+	return e, nil
+}
+
+var (
+	defaultLk sync.Mutex
+	defLogger *Logger
+)
+
+// Start makes the events logged by all subsystems go to a logger keeping the
+// last size entries, and returns it. Later calls return the same logger.
+func Start(size int) *Logger {
+	defaultLk.Lock()
+	defer defaultLk.Unlock()
+	if defLogger == nil {
+		defLogger = New(size)
+		logging.WriterGroup.AddWriter(defLogger)
+	}
+	return defLogger
+}
+
+// Default returns the logger started by Start, or nil.
+func Default() *Logger {
+	defaultLk.Lock()
+	defer defaultLk.Unlock()
+	return defLogger
+}
+
+// WithTrace returns a context whose events carry a new trace ID, so that the
+// entries of an operation can be found, and the ID.
+func WithTrace(ctx context.Context) (context.Context, string) {
+	var b [8]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	return WithTraceID(ctx, id), id
+}
+
+// WithTraceID returns a context whose events carry the trace ID id, such as
+// the ID of the trace the operation is recorded in by package tracing.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return logging.ContextWithLoggable(ctx, logging.LoggableMap{"trace": id})
+}
@@ -0,0 +1,89 @@
+package logs
+
+import (
+	"testing"
+)
+
+func TestWriteParsesEvents(t *testing.T) {
+	l := New(10)
+	ev := `{"event":"fetched","system":"bitswap","time":"2018-01-02T03:04:05.000000006Z","cid":"QmFoo","peerID":"QmBar","trace":"abc","size":3}` + "\n"
+
+	// events can be split across writes
+	if _, err := l.Write([]byte(ev[:20])); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries()) != 0 {
+		t.Fatal("partial event was kept")
+	}
+	if _, err := l.Write([]byte(ev[20:] + "not json\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	es := l.Entries()
+	if len(es) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(es))
+	}
+	e := es[0]
+	if e.System != "bitswap" || e.Event != "fetched" || e.Level != "info" {
+		t.Fatalf("wrong entry: %+v", e)
+	}
+	if e.Cid != "QmFoo" || e.Peer != "QmBar" || e.Trace != "abc" {
+		t.Fatalf("fields not promoted: %+v", e)
+	}
+	if e.Time.Nanosecond() != 6 {
+		t.Fatalf("wrong time: %s", e.Time)
+	}
+	if len(e.Fields) != 1 || e.Fields["size"] != 3.0 {
+		t.Fatalf("wrong fields: %v", e.Fields)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	l := New(10)
+	if err := l.SetLevel("dht", "error"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetLevel("dht", "loud"); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+
+	l.Write([]byte(`{"event":"a","system":"dht"}` + "\n"))
+	l.Write([]byte(`{"event":"b","system":"dht","error":"failed"}` + "\n"))
+	l.Write([]byte(`{"event":"c","system":"bitswap"}` + "\n"))
+
+	es := l.Entries()
+	if len(es) != 2 || es[0].Event != "b" || es[0].Level != "error" || es[1].Event != "c" {
+		t.Fatalf("wrong entries: %+v", es)
+	}
+
+	// setting the level of all systems resets the others
+	if err := l.SetLevel("*", "critical"); err != nil {
+		t.Fatal(err)
+	}
+	l.Write([]byte(`{"event":"d","system":"dht","error":"failed"}` + "\n"))
+	if len(l.Entries()) != 2 {
+		t.Fatal("entry above the level was kept")
+	}
+}
+
+func TestRingAndSubscribe(t *testing.T) {
+	l := New(3)
+	var got []string
+	cancel := l.Subscribe(func(e Entry) {
+		got = append(got, e.Event)
+	})
+
+	for _, ev := range []string{"a", "b", "c", "d", "e"} {
+		l.Add(Entry{Event: ev, Level: "info"})
+	}
+	cancel()
+	l.Add(Entry{Event: "f", Level: "info"})
+
+	es := l.Entries()
+	if len(es) != 3 || es[0].Event != "d" || es[1].Event != "e" || es[2].Event != "f" {
+		t.Fatalf("wrong entries: %+v", es)
+	}
+	if len(got) != 5 {
+		t.Fatalf("subscriber got %v", got)
+	}
+}
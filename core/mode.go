@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+)
+
+// ErrModeSwitchUnsupported is returned by SetOnline for nodes which weren't
+// constructed by NewNode.
+var ErrModeSwitchUnsupported = errors.New("node can't switch between online and offline modes")
+
+// SetOnline switches the node between online and offline modes at runtime.
+// Going offline closes the host, the routing and the exchange, and stops the
+// services using them, such as reproviding and IPNS republishing; going
+// online brings them up again as NewNode would. The block service of the node
+// switches exchange with it, so that it only fetches blocks from the network
+// while online, and so do the tenants of the node.
+//
+// SetOnline waits for the holders of LockServices. Operations running during
+// the switch without it may fail. Nodes in local mode can't switch modes.
+func (n *IpfsNode) SetOnline(online bool) error {
+	n.onlineLk.Lock()
+	defer n.onlineLk.Unlock()
+
+	if online == n.OnlineMode() {
+		return nil
+	}
+	if n.startOnline == nil || (n.localModeSet && n.LocalMode()) {
+		return ErrModeSwitchUnsupported
+	}
+
+	n.servicesLk.Lock()
+	defer n.servicesLk.Unlock()
+
+	var err error
+	if online {
+		err = n.goOnline()
+	} else {
+		n.goOffline()
+	}
+	n.repointTenants()
+	return err
+}
+
+func (n *IpfsNode) goOnline() error {
+	ctx, cancel := context.WithCancel(n.Context())
+	prev := n.Exchange
+	// routing set up for offline IPNS operations is replaced
+	n.Routing = nil
+	n.Namesys = nil
+
+	err := n.startOnline(ctx)
+	if err == nil {
+		n.switchExchange(prev)
+		err = n.startLateOnlineServices(ctx)
+	}
+	if err != nil {
+		cancel()
+		failed := n.Exchange
+		n.Exchange = prev
+		n.switchExchange(failed)
+		n.stopOnlineServices()
+		if failed != nil && failed != prev {
+			failed.Close()
+		}
+		return err
+	}
+
+	if prev != nil {
+		prev.Close()
+	}
+	n.stopOnline = cancel
+	n.setMode(onlineMode)
+	log.Info("node is online")
+	return nil
+}
+
+func (n *IpfsNode) goOffline() {
+	n.stopOnline()
+	n.stopOnline = nil
+
+	prev := n.Exchange
+	n.Exchange = offline.Exchange(n.Blockstore)
+	n.switchExchange(prev)
+	n.stopOnlineServices()
+	if prev != nil {
+		prev.Close()
+	}
+
+	n.setMode(offlineMode)
+	log.Info("node is offline")
+}
+
+// switchExchange makes the block service use the current exchange of the node
+// in place of prev. Block services built with another exchange are kept.
+func (n *IpfsNode) switchExchange(prev exchange.Interface) {
+	if n.Blocks == nil || n.Blocks.Exchange() != prev {
+		return
+	}
+	bserv.SetExchange(n.Blocks, n.Exchange)
+}
+
+// stopOnlineServices closes the services started by startOnlineServices, once
+// their context is cancelled, except the exchange.
+func (n *IpfsNode) stopOnlineServices() {
+	if n.Peering != nil {
+		n.Peering.Stop()
+	}
+	if n.AutoRelay != nil {
+		n.AutoRelay.Stop()
+	}
+	if n.HolePunch != nil {
+		n.HolePunch.Stop()
+	}
+
+	var closers []io.Closer
+	if n.Bootstrapper != nil {
+		closers = append(closers, n.Bootstrapper)
+	}
+	if n.Discovery != nil {
+		closers = append(closers, n.Discovery)
+	}
+	if d := n.DHT(); d != nil {
+		closers = append(closers, d.Process())
+	}
+	if n.PeerHost != nil {
+		closers = append(closers, n.PeerHost)
+	}
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			log.Warning("stopping online services: ", err)
+		}
+	}
+
+	n.Bootstrapper = nil
+	n.Discovery = nil
+	n.connMgr = nil
+	n.PeerHost = nil
+	n.Routing = nil
+	n.provideRouting = nil
+	n.Provided = nil
+	n.Peering = nil
+	n.Resources = nil
+	n.AutoRelay = nil
+	n.HolePunch = nil
+	n.Namesys = nil
+	n.IpnsRepub = nil
+	n.Reprovider = nil
+	n.Scrubber = nil
+	n.Replication = nil
+	n.Floodsub = nil
+	n.PubSub = nil
+	n.P2P = nil
+	n.Ping = nil
+}
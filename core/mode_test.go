@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	"github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+
+	host "github.com/libp2p/go-libp2p-host"
+	ipnet "github.com/libp2p/go-libp2p-interface-pnet"
+	metrics "github.com/libp2p/go-libp2p-metrics"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	smux "github.com/libp2p/go-stream-muxer"
+)
+
+func TestSetOnline(t *testing.T) {
+	ctx := context.Background()
+	r := &repo.Mock{
+		C: config.Config{Identity: testIdentity},
+		D: ds2.ThreadSafeCloserMapDatastore(),
+	}
+	hosts := 0
+	n, err := NewNode(ctx, &BuildCfg{
+		Repo:   r,
+		Online: true,
+		Host: func(ctx context.Context, id peer.ID, ps pstore.Peerstore, _ metrics.Reporter, _ []*net.IPNet, _ smux.Transport, _ ipnet.Protector, _ *ConstructPeerHostOpts) (host.Host, error) {
+			hosts++
+			return mocknet.New(ctx).AddPeerWithPeerstore(id, ps)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	tenant, err := n.AddTenant("alice", &repo.Mock{
+		D: ds2.ThreadSafeCloserMapDatastore(),
+		K: keystore.NewMemKeystore(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.SetOnline(false); err != nil {
+		t.Fatal(err)
+	}
+	if n.OnlineMode() || n.PeerHost != nil || n.Routing != nil {
+		t.Fatal("expected the node to be offline")
+	}
+	if n.Blocks.Exchange() != n.Exchange || n.Exchange.IsOnline() {
+		t.Fatal("expected the block service to use the offline exchange")
+	}
+	if tenant.OnlineMode() || tenant.PeerHost != nil || tenant.Exchange.IsOnline() {
+		t.Fatal("expected the tenant to follow the node offline")
+	}
+
+	if err := n.SetOnline(true); err != nil {
+		t.Fatal(err)
+	}
+	if !n.OnlineMode() || n.PeerHost == nil || n.Namesys == nil {
+		t.Fatal("expected the node to be online")
+	}
+	if n.Blocks.Exchange() != n.Exchange || !n.Exchange.IsOnline() {
+		t.Fatal("expected the block service to use the online exchange")
+	}
+	if !tenant.OnlineMode() || tenant.PeerHost != n.PeerHost || tenant.Namesys != n.Namesys {
+		t.Fatal("expected the tenant to share the new online services")
+	}
+	if tenant.Blocks.Exchange() != tenant.Exchange || !tenant.Exchange.IsOnline() {
+		t.Fatal("expected the tenant to fetch through the online exchange")
+	}
+	if hosts != 2 {
+		t.Fatalf("expected 2 hosts to be constructed, got %d", hosts)
+	}
+}
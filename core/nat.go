@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"sort"
+
+	holepunch "github.com/ipfs/go-ipfs/holepunch"
+)
+
+// Reachabilities of the node.
+const (
+	ReachabilityPublic  = "public"
+	ReachabilityPrivate = "private"
+)
+
+// ErrOffline is returned by NATStatus for nodes offline.
+var ErrOffline = errors.New("the node is offline")
+
+// NATStatus is the reachability of the node, and the state of the NAT
+// traversal keeping it dialable.
+type NATStatus struct {
+	// Reachability is ReachabilityPublic when the node has a public address,
+	// observed by other peers or of one of its interfaces.
+	Reachability string
+	Addrs        []string
+
+	AutoRelay bool
+	Relays    []string
+
+	HolePunching bool
+	HolePunches  holepunch.Stats
+
+	// Reasons explain why other peers may be unable to dial the node.
+	Reasons []string
+}
+
+// NATStatus returns the reachability of the node.
+func (n *IpfsNode) NATStatus() (*NATStatus, error) {
+	if n.PeerHost == nil {
+		return nil, ErrOffline
+	}
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := n.PeerHost.Addrs()
+	st := &NATStatus{
+		Reachability: ReachabilityPrivate,
+		Addrs:        make([]string, 0, len(addrs)),
+		Relays:       []string{},
+		AutoRelay:    n.AutoRelay != nil,
+		HolePunching: n.HolePunch != nil,
+		Reasons:      []string{},
+	}
+	if hasPublicAddr(addrs) {
+		st.Reachability = ReachabilityPublic
+	}
+	for _, a := range addrs {
+		st.Addrs = append(st.Addrs, a.String())
+	}
+	if n.AutoRelay != nil {
+		for _, p := range n.AutoRelay.Relays() {
+			st.Relays = append(st.Relays, p.Pretty())
+		}
+		sort.Strings(st.Relays)
+	}
+	if n.HolePunch != nil {
+		st.HolePunches = n.HolePunch.Stats()
+	}
+
+	if st.Reachability == ReachabilityPublic {
+		return st, nil
+	}
+	st.Reasons = append(st.Reasons, "no public address: the node is behind a NAT or firewall, or too few peers observed its public address yet")
+	switch {
+	case cfg.Swarm.DisableRelay:
+		st.Reasons = append(st.Reasons, "the relay transport is disabled (Swarm.DisableRelay)")
+	case n.AutoRelay == nil:
+		st.Reasons = append(st.Reasons, "autorelay is disabled (Swarm.EnableAutoRelay), no relay addresses are announced")
+	case len(st.Relays) == 0:
+		st.Reasons = append(st.Reasons, "no relay found yet, relays are looked for every minute")
+	}
+	if n.HolePunch == nil {
+		st.Reasons = append(st.Reasons, "hole punching is disabled (Swarm.EnableHolePunching), connections through relays stay relayed")
+	}
+	return st, nil
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	ds "github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+	ic "github.com/libp2p/go-libp2p-crypto"
+	p2phost "github.com/libp2p/go-libp2p-host"
+	peer "github.com/libp2p/go-libp2p-peer"
+	routing "github.com/libp2p/go-libp2p-routing"
+)
+
+// ExchangeOption constructs the exchange of an online node, exchanging the
+// blocks of bs with the peers of host and announcing them to r.
+type ExchangeOption func(ctx context.Context, id peer.ID, host p2phost.Host, r routing.ContentRouting, bs bstore.Blockstore) (exchange.Interface, error)
+
+// BitswapOption exchanges blocks with bitswap.
+var BitswapOption ExchangeOption = constructBitswap
+
+// NilExchangeOption disables the exchange: the node neither fetches blocks
+// from the network nor serves its own to other peers.
+var NilExchangeOption ExchangeOption = constructNilExchange
+
+// BlockServiceOption constructs the block service of a node from its
+// blockstore and exchange.
+type BlockServiceOption func(ctx context.Context, bs bstore.Blockstore, ex exchange.Interface) (bserv.BlockService, error)
+
+// OfflineBlockServiceOption only reads blocks from the blockstore, the
+// exchange still provides them to other peers.
+var OfflineBlockServiceOption BlockServiceOption = func(ctx context.Context, bs bstore.Blockstore, ex exchange.Interface) (bserv.BlockService, error) {
+	return bserv.New(bs, offline.Exchange(bs)), nil
+}
+
+// NamesysOption constructs the name system of a node from its routing and
+// its datastore, caching cacheSize resolved names.
+type NamesysOption func(ctx context.Context, r routing.ValueStore, d ds.Datastore, cacheSize int) (namesys.NameSystem, error)
+
+// DefaultNamesysOption resolves and publishes IPNS names through the
+// routing system, and resolves DNS and proquint names.
+var DefaultNamesysOption NamesysOption = func(ctx context.Context, r routing.ValueStore, d ds.Datastore, cacheSize int) (namesys.NameSystem, error) {
+	return namesys.NewNameSystem(r, d, cacheSize), nil
+}
+
+// NilNamesysOption disables the name system: names can't be resolved nor
+// published, and the records of the node aren't republished.
+var NilNamesysOption NamesysOption = func(ctx context.Context, r routing.ValueStore, d ds.Datastore, cacheSize int) (namesys.NameSystem, error) {
+	return nilNamesys{}, nil
+}
+
+// PinnerOption constructs the pinner of a node, storing the pins in d. The
+// pinned DAGs are read from dserv, the pin sets from internal.
+type PinnerOption func(d ds.Datastore, dserv, internal ipld.DAGService) (pin.Pinner, error)
+
+// DefaultPinnerOption loads the pins stored in the datastore.
+var DefaultPinnerOption PinnerOption = loadPinner
+
+// NilPinnerOption disables pinning: nothing is pinned, and pinning fails
+// with pin.ErrReadOnly.
+var NilPinnerOption PinnerOption = func(d ds.Datastore, dserv, internal ipld.DAGService) (pin.Pinner, error) {
+	return pin.NewReadOnlyPinner(pin.NewPinner(ds.NewMapDatastore(), dserv, internal)), nil
+}
+
+func constructBitswap(ctx context.Context, id peer.ID, host p2phost.Host, r routing.ContentRouting, bs bstore.Blockstore) (exchange.Interface, error) {
+	const alwaysSendToPeer = true // use YesManStrategy
+	bitswapNetwork := bsnet.NewFromIpfsHost(host, r)
+	return bitswap.New(ctx, id, bitswapNetwork, bs, alwaysSendToPeer), nil
+}
+
+func constructNilExchange(ctx context.Context, id peer.ID, host p2phost.Host, r routing.ContentRouting, bs bstore.Blockstore) (exchange.Interface, error) {
+	return offline.Exchange(bs), nil
+}
+
+func loadPinner(d ds.Datastore, dserv, internal ipld.DAGService) (pin.Pinner, error) {
+	p, err := pin.LoadPinner(d, dserv, internal)
+	if err != nil {
+		// TODO: we should move towards only running 'NewPinner' explicity on
+		// node init instead of implicitly here as a result of the pinner keys
+		// not being found in the datastore.
+		// this is kinda sketchy and could cause data loss
+		p = pin.NewPinner(d, dserv, internal)
+	}
+	return p, nil
+}
+
+// nilNamesys is the name system of nodes built with NilNamesysOption.
+type nilNamesys struct{}
+
+func (nilNamesys) Resolve(ctx context.Context, name string) (path.Path, error) {
+	return "", namesys.ErrResolveFailed
+}
+
+func (nilNamesys) ResolveN(ctx context.Context, name string, depth int) (path.Path, error) {
+	return "", namesys.ErrResolveFailed
+}
+
+func (nilNamesys) Publish(ctx context.Context, name ic.PrivKey, value path.Path) error {
+	return namesys.ErrPublishFailed
+}
+
+func (nilNamesys) PublishWithEOL(ctx context.Context, name ic.PrivKey, value path.Path, eol time.Time) error {
+	return namesys.ErrPublishFailed
+}
+
+func (nilNamesys) GetResolver(subs string) (namesys.Resolver, bool) {
+	return nil, false
+}
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// ConfigApplier applies the changes to a section of the config to the running
+// node.
+type ConfigApplier func(cfg *config.Config) error
+
+// ConfigReload reports the changed keys of the config applied by ReloadConfig.
+type ConfigReload struct {
+	// Applied are the keys now in effect.
+	Applied []string
+	// Restart are the keys which only take effect once the node restarts.
+	Restart []string
+	// Errors are the errors of the appliers which failed, by section. Their
+	// keys aren't in effect.
+	Errors map[string]string `json:",omitempty"`
+}
+
+// RegisterConfigApplier makes ReloadConfig call f when the keys of section,
+// such as "Swarm.ConnMgr", change. The applier of the longest section
+// containing a key is called.
+func (n *IpfsNode) RegisterConfigApplier(section string, f ConfigApplier) {
+	n.reloadLk.Lock()
+	defer n.reloadLk.Unlock()
+	if n.appliers == nil {
+		n.appliers = make(map[string]ConfigApplier)
+	}
+	n.appliers[section] = f
+}
+
+// setupConfigReload records cfg as the config the node runs with, and
+// registers the appliers of the sections the node itself can apply.
+func (n *IpfsNode) setupConfigReload(cfg *config.Config) error {
+	applied, err := config.ToMap(cfg)
+	if err != nil {
+		return err
+	}
+	n.reloadLk.Lock()
+	n.appliedConfig = applied
+	n.reloadLk.Unlock()
+
+	n.RegisterConfigApplier("Swarm.ConnMgr", func(cfg *config.Config) error {
+		if n.connMgr == nil || n.PeerHost == nil {
+			// picked up when going online
+			return nil
+		}
+		cm, err := constructConnMgr(cfg.Swarm.ConnMgr)
+		if err != nil {
+			return err
+		}
+		n.connMgr.replace(cm, n.PeerHost.Network())
+		return nil
+	})
+	n.RegisterConfigApplier("Swarm.ResourceMgr", func(cfg *config.Config) error {
+		if n.Resources == nil {
+			return nil
+		}
+		limits, err := resourceLimits(cfg.Swarm.ResourceMgr)
+		if err != nil {
+			return err
+		}
+		n.Resources.SetLimits(limits)
+		return nil
+	})
+	n.RegisterConfigApplier("Peering", func(cfg *config.Config) error {
+		if n.Peering == nil {
+			return nil
+		}
+		return n.setPeeringPeers(cfg.Peering)
+	})
+	n.RegisterConfigApplier("Reprovider.Strategy", func(cfg *config.Config) error {
+		if n.Reprovider == nil {
+			return nil
+		}
+		strategy, err := n.reprovideStrategy(cfg.Reprovider.Strategy)
+		if err != nil {
+			return err
+		}
+		n.Reprovider.SetKeyProvider(strategy.Keys)
+		return nil
+	})
+	return nil
+}
+
+// ReloadConfig applies the changes made to the config of the repo since the
+// node started, or since the last reload, with the appliers of their sections.
+// Repos whose config can be edited behind their back read it again first.
+func (n *IpfsNode) ReloadConfig() (*ConfigReload, error) {
+	if r, ok := n.Repo.(repo.ConfigReloader); ok {
+		if err := r.ReloadConfig(); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	cur, err := config.ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	n.reloadLk.Lock()
+	defer n.reloadLk.Unlock()
+	// the appliers use the online services, keep them from being swapped
+	defer n.LockServices()()
+
+	out := new(ConfigReload)
+	if n.appliedConfig == nil {
+		// not built by NewNode, start tracking changes from now on
+		n.appliedConfig = cur
+		return out, nil
+	}
+	changes := make(map[string][]string)
+	for _, k := range diffConfig("", n.appliedConfig, cur) {
+		section := ""
+		for s := range n.appliers {
+			if (k == s || strings.HasPrefix(k, s+".")) && len(s) > len(section) {
+				section = s
+			}
+		}
+		if section == "" {
+			out.Restart = append(out.Restart, k)
+			continue
+		}
+		changes[section] = append(changes[section], k)
+	}
+
+	for section, keys := range changes {
+		if err := n.appliers[section](cfg); err != nil {
+			if out.Errors == nil {
+				out.Errors = make(map[string]string)
+			}
+			out.Errors[section] = err.Error()
+			continue
+		}
+		for _, k := range keys {
+			copyConfigKey(n.appliedConfig, cur, k)
+		}
+		out.Applied = append(out.Applied, keys...)
+	}
+	sort.Strings(out.Applied)
+	sort.Strings(out.Restart)
+	return out, nil
+}
+
+// WatchConfig reloads the config every interval until ctx is done, logging
+// the changes.
+func (n *IpfsNode) WatchConfig(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+
+		res, err := n.ReloadConfig()
+		if err != nil {
+			log.Error("reloading the config: ", err)
+			continue
+		}
+		if len(res.Applied) > 0 {
+			log.Infof("applied config changes: %s", strings.Join(res.Applied, ", "))
+		}
+		if len(res.Restart) > 0 {
+			log.Warningf("config changes awaiting a restart: %s", strings.Join(res.Restart, ", "))
+		}
+		for section, err := range res.Errors {
+			log.Errorf("applying config changes to %s: %s", section, err)
+		}
+	}
+}
+
+// diffConfig returns the keys, below prefix, whose values differ between the
+// configs a and b. Objects are compared key by key, other values whole.
+func diffConfig(prefix string, a, b map[string]interface{}) []string {
+	var keys []string
+	for k, av := range a {
+		bv, ok := b[k]
+		if !ok {
+			keys = append(keys, prefix+k)
+			continue
+		}
+		am, aok := av.(map[string]interface{})
+		bm, bok := bv.(map[string]interface{})
+		if aok && bok {
+			keys = append(keys, diffConfig(prefix+k+".", am, bm)...)
+		} else if !reflect.DeepEqual(av, bv) {
+			keys = append(keys, prefix+k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, prefix+k)
+		}
+	}
+	return keys
+}
+
+// copyConfigKey sets the dotted key of dst to its value in src, removing it
+// if src has none.
+func copyConfigKey(dst, src map[string]interface{}, key string) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		src, _ = src[p].(map[string]interface{})
+
+		d, ok := dst[p].(map[string]interface{})
+		if !ok {
+			d = make(map[string]interface{})
+			dst[p] = d
+		}
+		dst = d
+	}
+
+	last := parts[len(parts)-1]
+	if v, ok := src[last]; ok {
+		dst[last] = v
+	} else {
+		delete(dst, last)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+)
+
+func TestReloadConfig(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{Identity: testIdentity},
+		D: ds2.ThreadSafeCloserMapDatastore(),
+	}
+	n, err := NewNode(context.Background(), &BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	var applied []string
+	n.RegisterConfigApplier("Gateway", func(cfg *config.Config) error {
+		applied = append(applied, cfg.Gateway.RootRedirect)
+		return nil
+	})
+	n.RegisterConfigApplier("Gateway.Writable", func(cfg *config.Config) error {
+		return errors.New("can't change")
+	})
+
+	cfg, _ := r.Config()
+	cfg.Gateway.RootRedirect = "/ipns/example.com"
+	cfg.Gateway.Writable = true
+	cfg.Reprovider.Interval = "1h"
+
+	res, err := n.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Applied, []string{"Gateway.RootRedirect"}) {
+		t.Fatalf("unexpected applied keys: %v", res.Applied)
+	}
+	if !reflect.DeepEqual(res.Restart, []string{"Reprovider.Interval"}) {
+		t.Fatalf("unexpected restart keys: %v", res.Restart)
+	}
+	if res.Errors["Gateway.Writable"] != "can't change" {
+		t.Fatalf("unexpected errors: %v", res.Errors)
+	}
+	if !reflect.DeepEqual(applied, []string{"/ipns/example.com"}) {
+		t.Fatalf("unexpected applier calls: %v", applied)
+	}
+
+	// applied keys aren't reported again, the others are
+	res, err = n.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Applied) != 0 || len(res.Restart) != 1 || len(res.Errors) != 1 {
+		t.Fatalf("unexpected second reload: %+v", res)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("expected the applier not to be called again, got %v", applied)
+	}
+}
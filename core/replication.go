@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	replicate "github.com/ipfs/go-ipfs/pin/replicate"
+	pubsub "github.com/ipfs/go-ipfs/pubsub"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// DefaultReplicationTopic is the pubsub topic the replication ledger is
+// shared on, unless configured.
+const DefaultReplicationTopic = "/ipfs/replication/1.0.0"
+
+// replicaLabel marks the pins made for the replication, the pins the user
+// made themselves are never removed by it.
+const replicaLabel = "replica"
+
+func (n *IpfsNode) startReplication(ctx context.Context, rcfg config.Replication) error {
+	opts := replicate.Options{
+		Self:   n.Identity,
+		Factor: rcfg.Factor,
+	}
+	for _, s := range rcfg.Peers {
+		p, err := peer.IDB58Decode(s)
+		if err != nil {
+			return fmt.Errorf("parsing Pinning.Replication.Peers: %s", err)
+		}
+		opts.Peers = append(opts.Peers, p)
+	}
+	if rcfg.Interval != "" {
+		dur, err := time.ParseDuration(rcfg.Interval)
+		if err != nil {
+			return fmt.Errorf("parsing Pinning.Replication.Interval: %s", err)
+		}
+		opts.Interval = dur
+	}
+
+	topic := rcfg.Topic
+	if topic == "" {
+		topic = DefaultReplicationTopic
+	}
+	// the ledger is only taken from trusted peers, the messages must be
+	// signed by their authors
+	n.PubSub.SignTopic(topic)
+	sub, err := n.PubSub.Subscribe(topic)
+	if err != nil {
+		return err
+	}
+	tpt := &pubsubTransport{ps: n.PubSub, sub: sub, topic: topic}
+
+	r, err := replicate.New(n.Repo.Datastore(), tpt, &replicaPinner{n}, opts)
+	if err != nil {
+		sub.Cancel()
+		return err
+	}
+	n.Replication = r
+	go func() {
+		defer sub.Cancel()
+		r.Run(ctx)
+	}()
+	return nil
+}
+
+// pubsubTransport shares the ledger on a signed pubsub topic, so that the
+// sender of each message is its verified author.
+type pubsubTransport struct {
+	ps    *pubsub.PubSub
+	sub   *pubsub.Subscription
+	topic string
+}
+
+func (t *pubsubTransport) Publish(data []byte) error {
+	return t.ps.Publish(t.topic, data)
+}
+
+func (t *pubsubTransport) Next(ctx context.Context) (peer.ID, []byte, error) {
+	for {
+		msg, err := t.sub.Next(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(msg.Signature) == 0 {
+			log.Debugf("dropping unsigned replication state from %s", msg.From.Pretty())
+			continue
+		}
+		return msg.From, msg.Data, nil
+	}
+}
+
+// replicaPinner pins the replicas the node is responsible for.
+type replicaPinner struct {
+	n *IpfsNode
+}
+
+func (p *replicaPinner) Pin(ctx context.Context, c *cid.Cid, name string) error {
+	if _, pinned, err := p.n.Pinning.IsPinnedWithType(c, pin.Recursive); err != nil || pinned {
+		return err
+	}
+
+	// fetch before taking the pin lock, not to hold up gc meanwhile
+	ctx = exchange.WithPriority(ctx, exchange.PriorityBackground)
+	if err := dag.FetchGraph(ctx, c, p.n.DAG); err != nil {
+		return err
+	}
+	nd, err := p.n.DAG.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	defer p.n.Blockstore.PinLock().Unlock()
+	if err := p.n.Pinning.Pin(ctx, nd, true); err != nil {
+		return err
+	}
+	if err := p.n.Pinning.Label(c, name, map[string]string{replicaLabel: "true"}); err != nil {
+		return err
+	}
+	return p.n.Pinning.Flush()
+}
+
+func (p *replicaPinner) Unpin(ctx context.Context, c *cid.Cid) error {
+	defer p.n.Blockstore.PinLock().Unlock()
+
+	info, err := p.n.Pinning.PinInfo(c)
+	if err == pin.ErrNotPinned {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := info.Meta[replicaLabel]; !ok {
+		// pinned by the user meanwhile
+		return nil
+	}
+	if err := p.n.Pinning.Unpin(ctx, c, true); err != nil {
+		return err
+	}
+	return p.n.Pinning.Flush()
+}
@@ -0,0 +1,290 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	resolver "github.com/ipfs/go-ipfs/path/resolver"
+	pin "github.com/ipfs/go-ipfs/pin"
+	repo "github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/thirdparty/verifbs"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	routing "github.com/libp2p/go-libp2p-routing"
+)
+
+const (
+	tenantProvideWorkers = 4
+	tenantProvideTimeout = time.Minute
+)
+
+// AddTenant opens r as a tenant of n named name. A tenant has its own
+// blockstore, pins, files root and keystore, while it shares the identity,
+// connections, routing and exchange of n. Blocks of tenants are served to
+// other peers by the exchange of n, but are never read through n itself;
+// blocks fetched for a tenant are also cached in the blockstore of n.
+//
+// The returned node must only be used for the tenant's storage; it is closed
+// together with n. The services it shares with n follow n when n switches
+// modes with SetOnline.
+func (n *IpfsNode) AddTenant(name string, r repo.Repo) (*IpfsNode, error) {
+	defer n.LockServices()()
+	n.tenantsLk.Lock()
+	defer n.tenantsLk.Unlock()
+	if _, ok := n.tenants[name]; ok {
+		return nil, fmt.Errorf("tenant %q already exists", name)
+	}
+
+	t := &IpfsNode{
+		Identity:        n.Identity,
+		Repo:            r,
+		PrivateKey:      n.PrivateKey,
+		PNetFingerprint: n.PNetFingerprint,
+		Peerstore:       n.Peerstore,
+		Reporter:        n.Reporter,
+		ctx:             n.Context(),
+		localModeSet:    n.localModeSet,
+		tenantOf:        n,
+	}
+	t.shareServices(n)
+
+	bs := bstore.Blockstore(&verifbs.VerifBS{bstore.NewBlockstore(r.Datastore())})
+	t.BaseBlocks = bs
+	t.GCLocker = bstore.NewGCLocker()
+	t.Blockstore = bstore.NewGCBlockstore(bs, t.GCLocker)
+
+	t.Exchange = n.tenantExchange(t)
+	t.Blocks = bserv.New(t.Blockstore, t.Exchange)
+	t.DAG = dag.NewDAGService(t.Blocks)
+
+	internalDag := dag.NewDAGService(bserv.New(t.Blockstore, offline.Exchange(t.Blockstore)))
+	var err error
+	t.Pinning, err = pin.LoadPinner(r.Datastore(), t.DAG, internalDag)
+	if err != nil {
+		t.Pinning = pin.NewPinner(r.Datastore(), t.DAG, internalDag)
+	}
+	t.Resolver = resolver.NewBasicResolver(t.DAG)
+
+	if err := t.loadFilesRoot(); err != nil {
+		return nil, err
+	}
+
+	if n.tenants == nil {
+		n.tenants = make(map[string]*IpfsNode)
+	}
+	n.tenants[name] = t
+	return t, nil
+}
+
+// shareServices makes n use the online services of parent, of which it is a
+// tenant.
+func (n *IpfsNode) shareServices(parent *IpfsNode) {
+	n.Discovery = parent.Discovery
+	n.PeerHost = parent.PeerHost
+	n.Routing = parent.Routing
+	n.Namesys = parent.Namesys
+	n.Ping = parent.Ping
+	n.Provided = parent.Provided
+	n.Floodsub = parent.Floodsub
+	n.PubSub = parent.PubSub
+	n.provideRouting = parent.provideRouting
+	n.setMode(parent.getMode())
+}
+
+// tenantExchange returns the exchange of the tenant t, going through the
+// current exchange of n.
+func (n *IpfsNode) tenantExchange(t *IpfsNode) exchange.Interface {
+	if n.Exchange == nil {
+		return offline.Exchange(t.Blockstore)
+	}
+	return newTenantExchange(t.Context(), n.Exchange, t.Blockstore, n.provideRouting)
+}
+
+// repointTenants makes the tenants of n use its online services and exchange
+// again, once SetOnline has swapped them. It is called with servicesLk held,
+// which also guards the services of the tenants.
+func (n *IpfsNode) repointTenants() {
+	n.tenantsLk.RLock()
+	defer n.tenantsLk.RUnlock()
+	for _, t := range n.tenants {
+		t.shareServices(n)
+		prev := t.Exchange
+		t.Exchange = n.tenantExchange(t)
+		bserv.SetExchange(t.Blocks, t.Exchange)
+		if err := prev.Close(); err != nil {
+			log.Warning("closing the previous tenant exchange: ", err)
+		}
+	}
+}
+
+// Tenant returns the tenant of n named name.
+func (n *IpfsNode) Tenant(name string) (*IpfsNode, error) {
+	n.tenantsLk.RLock()
+	defer n.tenantsLk.RUnlock()
+	t, ok := n.tenants[name]
+	if !ok {
+		return nil, fmt.Errorf("no tenant named %q", name)
+	}
+	return t, nil
+}
+
+// Tenants returns the names of the tenants of n.
+func (n *IpfsNode) Tenants() []string {
+	n.tenantsLk.RLock()
+	defer n.tenantsLk.RUnlock()
+	names := make([]string, 0, len(n.tenants))
+	for name := range n.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tenantBlockstore is the blockstore the exchange serves blocks to other
+// peers from, including the blocks of all tenants. It is only used for
+// serving, so the node and its tenants never see each other's blocks.
+type tenantBlockstore struct {
+	bstore.Blockstore
+	node *IpfsNode
+}
+
+func (bs *tenantBlockstore) Has(c *cid.Cid) (bool, error) {
+	has, err := bs.Blockstore.Has(c)
+	if has || err != nil {
+		return has, err
+	}
+
+	bs.node.tenantsLk.RLock()
+	defer bs.node.tenantsLk.RUnlock()
+	for _, t := range bs.node.tenants {
+		if has, err := t.Blockstore.Has(c); has || err != nil {
+			return has, err
+		}
+	}
+	return false, nil
+}
+
+func (bs *tenantBlockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	b, err := bs.Blockstore.Get(c)
+	if err != bstore.ErrNotFound {
+		return b, err
+	}
+
+	bs.node.tenantsLk.RLock()
+	defer bs.node.tenantsLk.RUnlock()
+	for _, t := range bs.node.tenants {
+		if b, err := t.Blockstore.Get(c); err != bstore.ErrNotFound {
+			return b, err
+		}
+	}
+	return nil, bstore.ErrNotFound
+}
+
+// tenantExchange fetches blocks for a tenant through the node's exchange and
+// stores them in the tenant's blockstore. Blocks added by the tenant are
+// announced without being copied into the node's blockstore.
+type tenantExchange struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	parent  exchange.Interface
+	bs      bstore.Blockstore
+	provide chan *cid.Cid
+}
+
+func newTenantExchange(ctx context.Context, parent exchange.Interface, bs bstore.Blockstore, r routing.ContentRouting) *tenantExchange {
+	ctx, cancel := context.WithCancel(ctx)
+	e := &tenantExchange{
+		ctx:    ctx,
+		cancel: cancel,
+		parent: parent,
+		bs:     bs,
+	}
+	if r != nil {
+		e.provide = make(chan *cid.Cid, 128)
+		for i := 0; i < tenantProvideWorkers; i++ {
+			go e.provideWorker(r)
+		}
+	}
+	return e
+}
+
+func (e *tenantExchange) provideWorker(r routing.ContentRouting) {
+	for {
+		select {
+		case c := <-e.provide:
+			ctx, cancel := context.WithTimeout(e.ctx, tenantProvideTimeout)
+			if err := r.Provide(ctx, c, true); err != nil {
+				log.Debugf("providing tenant block %s: %s", c, err)
+			}
+			cancel()
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *tenantExchange) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	b, err := e.parent.GetBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.bs.Put(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (e *tenantExchange) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	in, err := e.parent.GetBlocks(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for b := range in {
+			if err := e.bs.Put(b); err != nil {
+				log.Errorf("storing tenant block %s: %s", b.Cid(), err)
+				return
+			}
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e *tenantExchange) HasBlock(b blocks.Block) error {
+	if e.provide == nil {
+		return nil
+	}
+	select {
+	case e.provide <- b.Cid():
+		return nil
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	}
+}
+
+func (e *tenantExchange) IsOnline() bool {
+	return e.parent.IsOnline()
+}
+
+// Close stops announcing the blocks of the tenant. The exchange it goes
+// through belongs to the node, and is left open.
+func (e *tenantExchange) Close() error {
+	e.cancel()
+	return nil
+}
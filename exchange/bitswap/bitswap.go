@@ -15,6 +15,7 @@ import (
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
 	notifications "github.com/ipfs/go-ipfs/exchange/bitswap/notifications"
+	tracing "github.com/ipfs/go-ipfs/tracing"
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
@@ -41,6 +42,9 @@ const (
 	sizeBatchRequestChan   = 32
 	// kMaxPriority is the max priority as defined by the bitswap protocol
 	kMaxPriority = math.MaxInt32
+	// priorityClassSpan is the range of wantlist priorities used within a
+	// single request priority class
+	priorityClassSpan = 1 << 24
 )
 
 var (
@@ -89,10 +93,12 @@ func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 		return nil
 	})
 
+	serve := newServeBlockstore(bstore)
 	bs := &Bitswap{
 		blockstore:    bstore,
+		serve:         serve,
 		notifications: notif,
-		engine:        decision.NewEngine(ctx, bstore), // TODO close the engine with Close() method
+		engine:        decision.NewEngine(ctx, serve), // TODO close the engine with Close() method
 		network:       network,
 		findKeys:      make(chan *blockRequest, sizeBatchRequestChan),
 		process:       px,
@@ -100,10 +106,13 @@ func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 		provideKeys:   make(chan *cid.Cid, provideKeysBufferSize),
 		wm:            NewWantManager(ctx, network),
 		counters:      new(counters),
+		presence:      newPresencePubSub(),
+		uploadLimiter: newUploadLimiter(),
 
 		dupMetric: dupHist,
 		allMetric: allHist,
 	}
+	bs.wm.allow = bs.allowRequest
 	go bs.wm.Run()
 	network.SetDelegate(bs)
 
@@ -137,10 +146,18 @@ type Bitswap struct {
 	// NB: ensure threadsafety
 	blockstore blockstore.Blockstore
 
+	// serve is the blockstore blocks are served to other peers from, see
+	// SetServeBlockstore
+	serve *serveBlockstore
+
 	// notifications engine for receiving new blocks and routing them to the
 	// appropriate user requests
 	notifications notifications.PubSub
 
+	// presence routes peers' answers to want-have requests to FindHaves
+	// callers
+	presence *presencePubSub
+
 	// findKeys sends keys to a worker to find and connect to providers for them
 	findKeys chan *blockRequest
 	// newBlocks is a channel for newly added blocks to be provided to the
@@ -160,6 +177,25 @@ type Bitswap struct {
 	dupMetric metrics.Histogram
 	allMetric metrics.Histogram
 
+	// policy decides which peers we trade blocks with, see SetPeerPolicy
+	policy   exchange.PeerPolicy
+	policyLk sync.RWMutex
+
+	// receiveHook is called with the blocks received, see SetReceiveHook
+	receiveHook   func(peer.ID, blocks.Block)
+	receiveHookLk sync.RWMutex
+
+	// memory accounts for the blocks being sent, see SetMemoryManager
+	memory   MemoryManager
+	memoryLk sync.RWMutex
+
+	// uploadLimiter shapes the bandwidth used for serving blocks
+	uploadLimiter *uploadLimiter
+
+	// wants persists our wantlist across restarts, see PersistWants
+	wants   *wantStore
+	wantsLk sync.RWMutex
+
 	// Sessions
 	sessions []*Session
 	sessLk   sync.Mutex
@@ -220,6 +256,14 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 		return nil, errors.New("bitswap is closed")
 	default:
 	}
+
+	// the span covers the wait for the blocks, until the request ends
+	ctx, span := tracing.StartSpan(ctx, "Bitswap.GetBlocks")
+	span.SetAttribute("blocks", len(keys))
+	if len(keys) == 1 {
+		span.SetAttribute("cid", keys[0].String())
+	}
+
 	promise := bs.notifications.Subscribe(ctx, keys...)
 
 	for _, k := range keys {
@@ -229,6 +273,7 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 	mses := bs.getNextSessionID()
 
 	bs.wm.WantBlocks(ctx, keys, nil, mses)
+	bs.persistedWants().add(keys)
 
 	// NB: Optimization. Assumes that providers of key[0] are likely to
 	// be able to provide for all keys. This currently holds true in most
@@ -248,6 +293,10 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 		defer close(out)
+		defer func() {
+			span.SetAttribute("missing", remaining.Len())
+			span.Finish()
+		}()
 		defer func() {
 			// can't just defer this call on its own, arguments are resolved *when* the defer is created
 			bs.CancelWants(remaining.Keys(), mses)
@@ -293,6 +342,7 @@ func (bs *Bitswap) CancelWants(cids []*cid.Cid, ses uint64) {
 		return
 	}
 	bs.wm.CancelWants(context.Background(), cids, nil, ses)
+	bs.cancelPersistedWants(cids)
 }
 
 // HasBlock announces the existance of a block to this bitswap service. The
@@ -326,6 +376,7 @@ func (bs *Bitswap) receiveBlockFrom(blk blocks.Block, from peer.ID) error {
 	bs.notifications.Publish(blk)
 
 	k := blk.Cid()
+	bs.persistedWants().remove(k)
 	ks := []*cid.Cid{k}
 	for _, s := range bs.SessionsForBlock(k) {
 		s.receiveBlockFrom(from, blk)
@@ -360,12 +411,19 @@ func (bs *Bitswap) SessionsForBlock(c *cid.Cid) []*Session {
 func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.BitSwapMessage) {
 	atomic.AddUint64(&bs.counters.messagesRecvd, 1)
 
+	incoming = bs.filterMessage(p, incoming)
+
 	// This call records changes to wantlists, blocks received,
 	// and number of bytes transfered.
 	bs.engine.MessageReceived(p, incoming)
 	// TODO: this is bad, and could be easily abused.
 	// Should only track *useful* messages in ledger
 
+	bs.respondToWantHaves(p, incoming)
+	for _, c := range incoming.Haves() {
+		bs.presence.Publish(p, c)
+	}
+
 	iblocks := incoming.Blocks()
 
 	if len(iblocks) == 0 {
@@ -384,6 +442,8 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 
 			if err := bs.receiveBlockFrom(b, p); err != nil {
 				log.Warningf("ReceiveMessage recvBlockFrom error: %s", err)
+			} else {
+				bs.received(p, b)
 			}
 			log.Event(ctx, "Bitswap.GetBlockRequest.End", b.Cid())
 		}(block)
@@ -428,6 +488,7 @@ func (bs *Bitswap) PeerConnected(p peer.ID) {
 func (bs *Bitswap) PeerDisconnected(p peer.ID) {
 	bs.wm.Disconnected(p)
 	bs.engine.PeerDisconnected(p)
+	bs.uploadLimiter.forget(p)
 }
 
 func (bs *Bitswap) ReceiveError(err error) {
@@ -10,10 +10,15 @@ import (
 
 	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
 	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
 	tn "github.com/ipfs/go-ipfs/exchange/bitswap/testnet"
+	peerpolicy "github.com/ipfs/go-ipfs/exchange/peerpolicy"
+	resource "github.com/ipfs/go-ipfs/resource"
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	ds_sync "github.com/ipfs/go-datastore/sync"
 	detectrace "github.com/ipfs/go-detect-race"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	delay "github.com/ipfs/go-ipfs-delay"
@@ -98,6 +103,149 @@ func TestGetBlockFromPeerAfterPeerAnnounces(t *testing.T) {
 	}
 }
 
+func TestPeerPolicyDeniesServing(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	block := blocks.NewBlock([]byte("block"))
+	g := NewTestSessionGenerator(net)
+	defer g.Close()
+
+	peers := g.Instances(2)
+	hasBlock := peers[0]
+	defer hasBlock.Exchange.Close()
+	wantsBlock := peers[1]
+	defer wantsBlock.Exchange.Close()
+
+	policy := peerpolicy.New()
+	policy.Block(wantsBlock.Peer)
+	hasBlock.Exchange.SetPeerPolicy(policy)
+
+	if err := hasBlock.Exchange.HasBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	if _, err := wantsBlock.Exchange.GetBlock(ctx, block.Cid()); err == nil {
+		t.Fatal("blocked peer should not have been served")
+	}
+
+	policy.Unblock(wantsBlock.Peer)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := wantsBlock.Exchange.GetBlock(ctx, block.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeerPolicyBansHashMismatch(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	g := NewTestSessionGenerator(net)
+	defer g.Close()
+
+	peers := g.Instances(2)
+	receiver := peers[0]
+	defer receiver.Exchange.Close()
+	sender := peers[1]
+	defer sender.Exchange.Close()
+
+	policy := peerpolicy.New()
+	receiver.Exchange.SetPeerPolicy(policy)
+
+	// the data doesn't hash to the cid it is sent under
+	c := blocks.NewBlock([]byte("wanted")).Cid()
+	for i := 0; i < peerpolicy.DefaultBanThreshold; i++ {
+		b, err := blocks.NewBlockWithCid([]byte(fmt.Sprintf("forged %d", i)), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := bsmsg.New(false)
+		msg.AddBlock(b)
+		receiver.Exchange.ReceiveMessage(context.Background(), sender.Peer, msg)
+	}
+
+	if has, err := receiver.Blockstore().Has(c); err != nil || has {
+		t.Fatal("a block not matching its hash was stored")
+	}
+	if !policy.Banned(sender.Peer) {
+		t.Fatal("the peer sending invalid blocks wasn't banned")
+	}
+}
+
+func TestMemoryManagerRefusesServing(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	block := blocks.NewBlock([]byte("block"))
+	g := NewTestSessionGenerator(net)
+	defer g.Close()
+
+	peers := g.Instances(2)
+	hasBlock := peers[0]
+	defer hasBlock.Exchange.Close()
+	wantsBlock := peers[1]
+	defer wantsBlock.Exchange.Close()
+
+	// the block doesn't fit in the memory of bitswap
+	limits := resource.Limits{Subsystems: map[string]resource.Limit{"bitswap": {Memory: 1}}}
+	m := resource.NewManager(limits)
+	hasBlock.Exchange.SetMemoryManager(m)
+
+	if err := hasBlock.Exchange.HasBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	if _, err := wantsBlock.Exchange.GetBlock(ctx, block.Cid()); err == nil {
+		t.Fatal("a block over the memory limit should not have been sent")
+	}
+
+	m.SetLimits(resource.Limits{})
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := wantsBlock.Exchange.GetBlock(ctx, block.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	// released once sending returns, which may be after the block arrived
+	for start := time.Now(); m.Stat().System.Usage.Memory != 0; {
+		if time.Since(start) > time.Second {
+			t.Fatal("the memory of the sent block wasn't released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeBlockstore(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	block := blocks.NewBlock([]byte("block"))
+	g := NewTestSessionGenerator(net)
+	defer g.Close()
+
+	peers := g.Instances(2)
+	serving := peers[0]
+	defer serving.Exchange.Close()
+	wantsBlock := peers[1]
+	defer wantsBlock.Exchange.Close()
+
+	other := blockstore.NewBlockstore(ds_sync.MutexWrap(ds.NewMapDatastore()))
+	if err := other.Put(block); err != nil {
+		t.Fatal(err)
+	}
+	serving.Exchange.SetServeBlockstore(other)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := wantsBlock.Exchange.GetBlock(ctx, block.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	has, err := serving.Blockstore().Has(block.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("served block should not be in the blockstore of bitswap")
+	}
+}
+
 func TestLargeSwarm(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
@@ -524,6 +672,10 @@ func assertLedgerMatch(ra, rb *decision.Receipt) error {
 		return fmt.Errorf("mismatch in ledgers (exchanged blocks): %d vs %d ", ra.Exchanged, rb.Exchanged)
 	}
 
+	if ra.BlocksSent != rb.BlocksRecv {
+		return fmt.Errorf("mismatch in ledgers (blocks): %d sent vs %d recvd", ra.BlocksSent, rb.BlocksRecv)
+	}
+
 	return nil
 }
 
@@ -672,3 +824,44 @@ func TestBitswapLedgerTwoWay(t *testing.T) {
 		}
 	}
 }
+
+func TestFindHaves(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := sg.Instances(3)
+	blk := bg.Next()
+	if err := instances[1].Blockstore().Put(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	haves, err := instances[0].Exchange.FindHaves(ctx, []*cid.Cid{blk.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case h := <-haves:
+		if h.Peer != instances[1].Peer {
+			t.Fatalf("expected have from %s, got %s", instances[1].Peer, h.Peer)
+		}
+		if !h.Cid.Equals(blk.Cid()) {
+			t.Fatal("got have for wrong cid")
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for have")
+	}
+
+	// presence requests must not end up on the remote wantlist
+	if wl := instances[1].Exchange.WantlistForPeer(instances[0].Peer); len(wl) != 0 {
+		t.Fatal("want-have should not be added to the peer's wantlist")
+	}
+	if has, _ := instances[0].Blockstore().Has(blk.Cid()); has {
+		t.Fatal("want-have should not transfer the block")
+	}
+}
@@ -113,18 +113,23 @@ func (e *Engine) WantlistForPeer(p peer.ID) (out []*wl.Entry) {
 }
 
 func (e *Engine) LedgerForPeer(p peer.ID) *Receipt {
-	ledger := e.findOrCreate(p)
+	return e.findOrCreate(p).receipt()
+}
 
-	ledger.lk.Lock()
-	defer ledger.lk.Unlock()
+// Ledgers returns receipts for all peers the engine keeps a ledger for.
+func (e *Engine) Ledgers() map[peer.ID]*Receipt {
+	e.lock.Lock()
+	ledgers := make([]*ledger, 0, len(e.ledgerMap))
+	for _, l := range e.ledgerMap {
+		ledgers = append(ledgers, l)
+	}
+	e.lock.Unlock()
 
-	return &Receipt{
-		Peer:      ledger.Partner.String(),
-		Value:     ledger.Accounting.Value(),
-		Sent:      ledger.Accounting.BytesSent,
-		Recv:      ledger.Accounting.BytesRecv,
-		Exchanged: ledger.ExchangeCount(),
+	out := make(map[peer.ID]*Receipt, len(ledgers))
+	for _, l := range ledgers {
+		out[l.Partner] = l.receipt()
 	}
+	return out
 }
 
 func (e *Engine) taskWorker(ctx context.Context) {
@@ -232,6 +237,11 @@ func (e *Engine) MessageReceived(p peer.ID, m bsmsg.BitSwapMessage) error {
 	}
 
 	for _, entry := range m.Wantlist() {
+		if entry.WantHave {
+			// presence requests are answered by bitswap directly and
+			// don't become part of the peer's wantlist
+			continue
+		}
 		if entry.Cancel {
 			log.Debugf("%s cancel %s", p, entry.Cid)
 			l.CancelWant(entry.Cid)
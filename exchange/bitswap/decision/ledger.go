@@ -33,6 +33,10 @@ type ledger struct {
 	// exchangeCount is the number of exchanges with this peer
 	exchangeCount uint64
 
+	// blocksSent and blocksRecv count the blocks exchanged with this peer
+	blocksSent uint64
+	blocksRecv uint64
+
 	// wantList is a (bounded, small) set of keys that Partner desires.
 	wantList *wl.Wantlist
 
@@ -48,11 +52,13 @@ type ledger struct {
 }
 
 type Receipt struct {
-	Peer      string
-	Value     float64
-	Sent      uint64
-	Recv      uint64
-	Exchanged uint64
+	Peer       string
+	Value      float64
+	Sent       uint64
+	Recv       uint64
+	Exchanged  uint64
+	BlocksSent uint64
+	BlocksRecv uint64
 }
 
 type debtRatio struct {
@@ -64,16 +70,33 @@ func (dr *debtRatio) Value() float64 {
 	return float64(dr.BytesSent) / float64(dr.BytesRecv+1)
 }
 
+func (l *ledger) receipt() *Receipt {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	return &Receipt{
+		Peer:       l.Partner.String(),
+		Value:      l.Accounting.Value(),
+		Sent:       l.Accounting.BytesSent,
+		Recv:       l.Accounting.BytesRecv,
+		Exchanged:  l.ExchangeCount(),
+		BlocksSent: l.blocksSent,
+		BlocksRecv: l.blocksRecv,
+	}
+}
+
 func (l *ledger) SentBytes(n int) {
 	l.exchangeCount++
 	l.lastExchange = time.Now()
 	l.Accounting.BytesSent += uint64(n)
+	l.blocksSent++
 }
 
 func (l *ledger) ReceivedBytes(n int) {
 	l.exchangeCount++
 	l.lastExchange = time.Now()
 	l.Accounting.BytesRecv += uint64(n)
+	l.blocksRecv++
 }
 
 func (l *ledger) Wants(k *cid.Cid, priority int) {
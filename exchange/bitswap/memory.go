@@ -0,0 +1,31 @@
+package bitswap
+
+// MemoryManager accounts for the memory of the blocks bitswap holds while
+// sending them to other peers, under the subsystem "bitswap".
+type MemoryManager interface {
+	Reserve(subsystem string, n int64) error
+	Release(subsystem string, n int64)
+}
+
+// SetMemoryManager makes bitswap reserve the size of every block it sends
+// from m first, and skip the blocks m refuses. A nil m removes the manager.
+func (bs *Bitswap) SetMemoryManager(m MemoryManager) {
+	bs.memoryLk.Lock()
+	defer bs.memoryLk.Unlock()
+	bs.memory = m
+}
+
+// reserveMemory reserves n bytes from the memory manager, if any. The
+// returned function releases them.
+func (bs *Bitswap) reserveMemory(n int64) (func(), error) {
+	bs.memoryLk.RLock()
+	m := bs.memory
+	bs.memoryLk.RUnlock()
+	if m == nil {
+		return func() {}, nil
+	}
+	if err := m.Reserve("bitswap", n); err != nil {
+		return nil, err
+	}
+	return func() { m.Release("bitswap", n) }, nil
+}
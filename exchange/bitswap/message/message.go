@@ -30,6 +30,17 @@ type BitSwapMessage interface {
 
 	Cancel(key *cid.Cid)
 
+	// AddWantHave asks the receiver whether it has the given block, without
+	// requesting the block itself.
+	AddWantHave(key *cid.Cid)
+
+	// Haves returns the keys the sender announced having, in response to
+	// want-have entries.
+	Haves() []*cid.Cid
+
+	// AddHave announces that the sender has the given block.
+	AddHave(key *cid.Cid)
+
 	Empty() bool
 
 	// A full wantlist is an authoritative copy, a 'non-full' wantlist is a patch-set
@@ -52,6 +63,7 @@ type impl struct {
 	full     bool
 	wantlist map[string]*Entry
 	blocks   map[string]blocks.Block
+	haves    map[string]*cid.Cid
 }
 
 func New(full bool) BitSwapMessage {
@@ -62,13 +74,15 @@ func newMsg(full bool) *impl {
 	return &impl{
 		blocks:   make(map[string]blocks.Block),
 		wantlist: make(map[string]*Entry),
+		haves:    make(map[string]*cid.Cid),
 		full:     full,
 	}
 }
 
 type Entry struct {
 	*wantlist.Entry
-	Cancel bool
+	Cancel   bool
+	WantHave bool
 }
 
 func newMessageFromProto(pbm pb.Message) (BitSwapMessage, error) {
@@ -78,7 +92,15 @@ func newMessageFromProto(pbm pb.Message) (BitSwapMessage, error) {
 		if err != nil {
 			return nil, fmt.Errorf("incorrectly formatted cid in wantlist: %s", err)
 		}
-		m.addEntry(c, int(e.GetPriority()), e.GetCancel())
+		m.addEntry(c, int(e.GetPriority()), e.GetCancel(), e.GetWantHave())
+	}
+
+	for _, h := range pbm.GetHaves() {
+		c, err := cid.Cast(h)
+		if err != nil {
+			return nil, fmt.Errorf("incorrectly formatted cid in haves: %s", err)
+		}
+		m.AddHave(c)
 	}
 
 	// deprecated
@@ -116,7 +138,7 @@ func (m *impl) Full() bool {
 }
 
 func (m *impl) Empty() bool {
-	return len(m.blocks) == 0 && len(m.wantlist) == 0
+	return len(m.blocks) == 0 && len(m.wantlist) == 0 && len(m.haves) == 0
 }
 
 func (m *impl) Wantlist() []Entry {
@@ -135,28 +157,46 @@ func (m *impl) Blocks() []blocks.Block {
 	return bs
 }
 
+func (m *impl) Haves() []*cid.Cid {
+	out := make([]*cid.Cid, 0, len(m.haves))
+	for _, c := range m.haves {
+		out = append(out, c)
+	}
+	return out
+}
+
 func (m *impl) Cancel(k *cid.Cid) {
 	delete(m.wantlist, k.KeyString())
-	m.addEntry(k, 0, true)
+	m.addEntry(k, 0, true, false)
 }
 
 func (m *impl) AddEntry(k *cid.Cid, priority int) {
-	m.addEntry(k, priority, false)
+	m.addEntry(k, priority, false, false)
+}
+
+func (m *impl) AddWantHave(k *cid.Cid) {
+	m.addEntry(k, 0, false, true)
+}
+
+func (m *impl) AddHave(k *cid.Cid) {
+	m.haves[k.KeyString()] = k
 }
 
-func (m *impl) addEntry(c *cid.Cid, priority int, cancel bool) {
+func (m *impl) addEntry(c *cid.Cid, priority int, cancel, wantHave bool) {
 	k := c.KeyString()
 	e, exists := m.wantlist[k]
 	if exists {
 		e.Priority = priority
 		e.Cancel = cancel
+		e.WantHave = wantHave
 	} else {
 		m.wantlist[k] = &Entry{
 			Entry: &wantlist.Entry{
 				Cid:      c,
 				Priority: priority,
 			},
-			Cancel: cancel,
+			Cancel:   cancel,
+			WantHave: wantHave,
 		}
 	}
 }
@@ -188,10 +228,16 @@ func (m *impl) ToProtoV0() *pb.Message {
 			Block:    proto.String(e.Cid.KeyString()),
 			Priority: proto.Int32(int32(e.Priority)),
 			Cancel:   proto.Bool(e.Cancel),
+			WantHave: proto.Bool(e.WantHave),
 		})
 	}
 	pbm.Wantlist.Full = proto.Bool(m.full)
 
+	pbm.Haves = make([][]byte, 0, len(m.haves))
+	for _, c := range m.haves {
+		pbm.Haves = append(pbm.Haves, c.Bytes())
+	}
+
 	blocks := m.Blocks()
 	pbm.Blocks = make([][]byte, 0, len(blocks))
 	for _, b := range blocks {
@@ -209,10 +255,16 @@ func (m *impl) ToProtoV1() *pb.Message {
 			Block:    proto.String(e.Cid.KeyString()),
 			Priority: proto.Int32(int32(e.Priority)),
 			Cancel:   proto.Bool(e.Cancel),
+			WantHave: proto.Bool(e.WantHave),
 		})
 	}
 	pbm.Wantlist.Full = proto.Bool(m.full)
 
+	pbm.Haves = make([][]byte, 0, len(m.haves))
+	for _, c := range m.haves {
+		pbm.Haves = append(pbm.Haves, c.Bytes())
+	}
+
 	blocks := m.Blocks()
 	pbm.Payload = make([]*pb.Message_Block, 0, len(blocks))
 	for _, b := range blocks {
@@ -164,6 +164,32 @@ func TestToAndFromNetMessage(t *testing.T) {
 	}
 }
 
+func TestToAndFromNetPreservesPresence(t *testing.T) {
+	original := New(false)
+	original.AddWantHave(mkFakeCid("wanted"))
+	original.AddHave(mkFakeCid("had"))
+
+	buf := new(bytes.Buffer)
+	if err := original.ToNetV1(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := FromNet(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wl := m2.Wantlist()
+	if len(wl) != 1 || !wl[0].WantHave || !wl[0].Cid.Equals(mkFakeCid("wanted")) {
+		t.Fatal("want-have entry got lost on marshal")
+	}
+
+	haves := m2.Haves()
+	if len(haves) != 1 || !haves[0].Equals(mkFakeCid("had")) {
+		t.Fatal("haves got lost on marshal")
+	}
+}
+
 func wantlistContains(wantlist *pb.Message_Wantlist, c *cid.Cid) bool {
 	for _, e := range wantlist.GetEntries() {
 		if e.GetBlock() == c.KeyString() {
@@ -26,6 +26,7 @@ type Message struct {
 	Wantlist         *Message_Wantlist `protobuf:"bytes,1,opt,name=wantlist" json:"wantlist,omitempty"`
 	Blocks           [][]byte          `protobuf:"bytes,2,rep,name=blocks" json:"blocks,omitempty"`
 	Payload          []*Message_Block  `protobuf:"bytes,3,rep,name=payload" json:"payload,omitempty"`
+	Haves            [][]byte          `protobuf:"bytes,4,rep,name=haves" json:"haves,omitempty"`
 	XXX_unrecognized []byte            `json:"-"`
 }
 
@@ -54,6 +55,13 @@ func (m *Message) GetPayload() []*Message_Block {
 	return nil
 }
 
+func (m *Message) GetHaves() [][]byte {
+	if m != nil {
+		return m.Haves
+	}
+	return nil
+}
+
 type Message_Wantlist struct {
 	Entries          []*Message_Wantlist_Entry `protobuf:"bytes,1,rep,name=entries" json:"entries,omitempty"`
 	Full             *bool                     `protobuf:"varint,2,opt,name=full" json:"full,omitempty"`
@@ -82,6 +90,7 @@ type Message_Wantlist_Entry struct {
 	Block            *string `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
 	Priority         *int32  `protobuf:"varint,2,opt,name=priority" json:"priority,omitempty"`
 	Cancel           *bool   `protobuf:"varint,3,opt,name=cancel" json:"cancel,omitempty"`
+	WantHave         *bool   `protobuf:"varint,4,opt,name=wantHave" json:"wantHave,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -110,6 +119,13 @@ func (m *Message_Wantlist_Entry) GetCancel() bool {
 	return false
 }
 
+func (m *Message_Wantlist_Entry) GetWantHave() bool {
+	if m != nil && m.WantHave != nil {
+		return *m.WantHave
+	}
+	return false
+}
+
 type Message_Block struct {
 	Prefix           []byte `protobuf:"bytes,1,opt,name=prefix" json:"prefix,omitempty"`
 	Data             []byte `protobuf:"bytes,2,opt,name=data" json:"data,omitempty"`
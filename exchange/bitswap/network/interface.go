@@ -17,6 +17,11 @@ var (
 	ProtocolBitswapNoVers protocol.ID = "/ipfs/bitswap"
 
 	ProtocolBitswap protocol.ID = "/ipfs/bitswap/1.1.0"
+
+	// ProtocolBitswapHave is ProtocolBitswap with want-have entries and
+	// have announcements. Peers speaking older versions would take a
+	// want-have for a plain want, so these are only sent over this one.
+	ProtocolBitswapHave protocol.ID = "/ipfs/bitswap/1.2.0"
 )
 
 // BitSwapNetwork provides network connectivity for BitSwap sessions
@@ -38,6 +43,10 @@ type BitSwapNetwork interface {
 
 	ConnectionManager() ifconnmgr.ConnManager
 
+	// SupportsHave reports whether the peer speaks ProtocolBitswapHave,
+	// and so can be sent want-have entries.
+	SupportsHave(peer.ID) bool
+
 	Routing
 }
 
@@ -30,6 +30,7 @@ func NewFromIpfsHost(host host.Host, r routing.ContentRouting) BitSwapNetwork {
 		host:    host,
 		routing: r,
 	}
+	host.SetStreamHandler(ProtocolBitswapHave, bitswapNetwork.handleNewStream)
 	host.SetStreamHandler(ProtocolBitswap, bitswapNetwork.handleNewStream)
 	host.SetStreamHandler(ProtocolBitswapOne, bitswapNetwork.handleNewStream)
 	host.SetStreamHandler(ProtocolBitswapNoVers, bitswapNetwork.handleNewStream)
@@ -76,12 +77,23 @@ func msgToStream(ctx context.Context, s inet.Stream, msg bsmsg.BitSwapMessage) e
 	}
 
 	switch s.Protocol() {
+	case ProtocolBitswapHave:
+		if err := msg.ToNetV1(s); err != nil {
+			log.Debugf("error: %s", err)
+			return err
+		}
 	case ProtocolBitswap:
+		if hasPresenceEntries(msg) {
+			return fmt.Errorf("remote speaks %s, which has no want-have entries", s.Protocol())
+		}
 		if err := msg.ToNetV1(s); err != nil {
 			log.Debugf("error: %s", err)
 			return err
 		}
 	case ProtocolBitswapOne, ProtocolBitswapNoVers:
+		if hasPresenceEntries(msg) {
+			return fmt.Errorf("remote speaks %s, which has no want-have entries", s.Protocol())
+		}
 		if err := msg.ToNetV0(s); err != nil {
 			log.Debugf("error: %s", err)
 			return err
@@ -96,6 +108,20 @@ func msgToStream(ctx context.Context, s inet.Stream, msg bsmsg.BitSwapMessage) e
 	return nil
 }
 
+// hasPresenceEntries reports whether msg has want-have entries or have
+// announcements, which only peers speaking ProtocolBitswapHave understand.
+func hasPresenceEntries(msg bsmsg.BitSwapMessage) bool {
+	if len(msg.Haves()) > 0 {
+		return true
+	}
+	for _, e := range msg.Wantlist() {
+		if e.WantHave {
+			return true
+		}
+	}
+	return false
+}
+
 func (bsnet *impl) NewMessageSender(ctx context.Context, p peer.ID) (MessageSender, error) {
 	s, err := bsnet.newStreamToPeer(ctx, p)
 	if err != nil {
@@ -106,7 +132,12 @@ func (bsnet *impl) NewMessageSender(ctx context.Context, p peer.ID) (MessageSend
 }
 
 func (bsnet *impl) newStreamToPeer(ctx context.Context, p peer.ID) (inet.Stream, error) {
-	return bsnet.host.NewStream(ctx, p, ProtocolBitswap, ProtocolBitswapOne, ProtocolBitswapNoVers)
+	return bsnet.host.NewStream(ctx, p, ProtocolBitswapHave, ProtocolBitswap, ProtocolBitswapOne, ProtocolBitswapNoVers)
+}
+
+func (bsnet *impl) SupportsHave(p peer.ID) bool {
+	protos, err := bsnet.host.Peerstore().SupportsProtocols(p, string(ProtocolBitswapHave))
+	return err == nil && len(protos) > 0
 }
 
 func (bsnet *impl) SendMessage(
@@ -0,0 +1,183 @@
+package bitswap
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+)
+
+var wantsDatastoreKey = ds.NewKey("/local/bitswap/wants")
+
+// PersistWants makes bitswap remember the blocks it was asked for in d until
+// they are retrieved, cancelled, or ttl has passed since they were first
+// requested. Wants remembered by a previous run are requested again in the
+// background, each until its own expiry.
+func (bs *Bitswap) PersistWants(d ds.Datastore, ttl time.Duration) error {
+	ws := &wantStore{ds: d, ttl: ttl}
+	byExpiry, err := ws.load()
+	if err != nil {
+		return err
+	}
+
+	bs.wantsLk.Lock()
+	bs.wants = ws
+	bs.wantsLk.Unlock()
+
+	for exp, ks := range byExpiry {
+		log.Infof("resuming %d persisted wants", len(ks))
+		if err := bs.resumeWants(ks, time.Unix(0, exp)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeWants requests ks again in the background until deadline.
+func (bs *Bitswap) resumeWants(ks []*cid.Cid, deadline time.Time) error {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	go func() {
+		defer cancel()
+		select {
+		case <-bs.process.Closing():
+		case <-ctx.Done():
+		}
+	}()
+
+	out, err := bs.GetBlocks(ctx, ks)
+	if err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		for range out {
+		}
+	}()
+	return nil
+}
+
+// cancelPersistedWants forgets about the wants in ks which were cancelled.
+// Wants cancelled because bitswap is closing are kept, to be resumed by
+// the next run.
+func (bs *Bitswap) cancelPersistedWants(ks []*cid.Cid) {
+	select {
+	case <-bs.process.Closing():
+	default:
+		bs.persistedWants().remove(ks...)
+	}
+}
+
+func (bs *Bitswap) persistedWants() *wantStore {
+	bs.wantsLk.RLock()
+	defer bs.wantsLk.RUnlock()
+	return bs.wants
+}
+
+// wantStore records wanted blocks in a datastore, along with when they
+// expire.
+type wantStore struct {
+	lk  sync.Mutex
+	ds  ds.Datastore
+	ttl time.Duration
+}
+
+func wantKey(c *cid.Cid) ds.Key {
+	return wantsDatastoreKey.Child(dshelp.CidToDsKey(c))
+}
+
+// add records ks as wanted. Wants already recorded keep their original
+// expiry, so that re-requesting them doesn't extend it.
+func (ws *wantStore) add(ks []*cid.Cid) {
+	if ws == nil {
+		return
+	}
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	expiry := time.Now().Add(ws.ttl).UnixNano()
+	for _, c := range ks {
+		k := wantKey(c)
+		has, err := ws.ds.Has(k)
+		if err != nil {
+			log.Errorf("checking persisted want %s: %s", c, err)
+			continue
+		}
+		if has {
+			continue
+		}
+
+		n := binary.PutVarint(buf, expiry)
+		if err := ws.ds.Put(k, append([]byte(nil), buf[:n]...)); err != nil {
+			log.Errorf("persisting want %s: %s", c, err)
+		}
+	}
+}
+
+// remove forgets about ks, e.g. once they have been received.
+func (ws *wantStore) remove(ks ...*cid.Cid) {
+	if ws == nil {
+		return
+	}
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+
+	for _, c := range ks {
+		if err := ws.ds.Delete(wantKey(c)); err != nil && err != ds.ErrNotFound {
+			log.Errorf("removing persisted want %s: %s", c, err)
+		}
+	}
+}
+
+// load returns the wants which haven't expired yet, grouped by their expiry
+// in nanoseconds, and deletes the expired ones.
+func (ws *wantStore) load() (map[int64][]*cid.Cid, error) {
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+
+	res, err := ws.ds.Query(dsq.Query{Prefix: wantsDatastoreKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byExpiry := make(map[int64][]*cid.Cid)
+	for _, e := range entries {
+		k := ds.NewKey(e.Key)
+		c, err := dshelp.DsKeyToCid(ds.NewKey(k.BaseNamespace()))
+		if err != nil {
+			log.Warningf("dropping malformed persisted want %s: %s", e.Key, err)
+			ws.ds.Delete(k)
+			continue
+		}
+
+		val, ok := e.Value.([]byte)
+		if !ok {
+			ws.ds.Delete(k)
+			continue
+		}
+		exp, n := binary.Varint(val)
+		if n <= 0 {
+			ws.ds.Delete(k)
+			continue
+		}
+
+		if time.Unix(0, exp).Before(now) {
+			if err := ws.ds.Delete(k); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		byExpiry[exp] = append(byExpiry[exp], c)
+	}
+	return byExpiry, nil
+}
@@ -0,0 +1,69 @@
+package bitswap
+
+import (
+	"testing"
+	"time"
+
+	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestWantStore(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	ws := &wantStore{ds: d, ttl: time.Hour}
+
+	bgen := blocksutil.NewBlockGenerator()
+	blks := bgen.Blocks(4)
+	var ks []*cid.Cid
+	for _, b := range blks {
+		ks = append(ks, b.Cid())
+	}
+
+	ws.add(ks[:3])
+	ws.remove(ks[1])
+	ws.ttl = 2 * time.Hour
+	ws.add(ks[3:])
+
+	// a fresh store, as after a restart
+	ws = &wantStore{ds: d, ttl: time.Hour}
+	byExpiry, err := ws.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byExpiry) != 2 {
+		t.Fatalf("expected wants with 2 distinct expiries, got %d", len(byExpiry))
+	}
+	got := cid.NewSet()
+	for exp, loaded := range byExpiry {
+		want := time.Hour
+		if len(loaded) == 1 {
+			want = 2 * time.Hour
+		}
+		if time.Unix(0, exp).Sub(time.Now()) < want-time.Minute {
+			t.Fatal("deadline should be the expiry of each want")
+		}
+		for _, c := range loaded {
+			got.Add(c)
+		}
+	}
+	if got.Len() != 3 || !got.Has(ks[0]) || !got.Has(ks[2]) || !got.Has(ks[3]) {
+		t.Fatal("loaded the wrong wants")
+	}
+
+	expired := &wantStore{ds: d, ttl: -time.Second}
+	other := bgen.Next().Cid()
+	expired.add([]*cid.Cid{other})
+	byExpiry, err = ws.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byExpiry) != 2 {
+		t.Fatal("expired want should not be loaded")
+	}
+	if has, _ := d.Has(wantKey(other)); has {
+		t.Fatal("expired want should have been deleted")
+	}
+}
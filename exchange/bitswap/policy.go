@@ -0,0 +1,109 @@
+package bitswap
+
+import (
+	"errors"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
+
+	blocks "github.com/ipfs/go-block-format"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var _ exchange.PolicyExchange = (*Bitswap)(nil)
+
+var errHashMismatch = errors.New("data doesn't match the hash of the block")
+
+// SetPeerPolicy makes bitswap consult policy before serving blocks to, or
+// requesting blocks from, a peer. Peers sending blocks which fail
+// verification are reported to it. A nil policy allows all peers.
+func (bs *Bitswap) SetPeerPolicy(policy exchange.PeerPolicy) {
+	bs.policyLk.Lock()
+	defer bs.policyLk.Unlock()
+	bs.policy = policy
+}
+
+func (bs *Bitswap) peerPolicy() exchange.PeerPolicy {
+	bs.policyLk.RLock()
+	defer bs.policyLk.RUnlock()
+	return bs.policy
+}
+
+func (bs *Bitswap) allowServe(p peer.ID) bool {
+	policy := bs.peerPolicy()
+	return policy == nil || policy.AllowServe(p)
+}
+
+func (bs *Bitswap) allowRequest(p peer.ID) bool {
+	policy := bs.peerPolicy()
+	return policy == nil || policy.AllowRequest(p)
+}
+
+// filterMessage strips the parts of a message from p the peer policy
+// doesn't let us act on: wants if we may not serve p, and blocks and haves
+// if we may not request from p. Blocks failing verification, with an invalid
+// cid or data not matching it, are dropped and reported.
+func (bs *Bitswap) filterMessage(p peer.ID, incoming bsmsg.BitSwapMessage) bsmsg.BitSwapMessage {
+	policy := bs.peerPolicy()
+	if policy == nil {
+		return incoming
+	}
+
+	serve := policy.AllowServe(p)
+	request := policy.AllowRequest(p)
+
+	out := bsmsg.New(incoming.Full())
+	if serve {
+		for _, e := range incoming.Wantlist() {
+			switch {
+			case e.Cancel:
+				out.Cancel(e.Cid)
+			case e.WantHave:
+				out.AddWantHave(e.Cid)
+			default:
+				out.AddEntry(e.Cid, e.Priority)
+			}
+		}
+	} else if len(incoming.Wantlist()) > 0 {
+		log.Debugf("ignoring wants from %s: denied by peer policy", p)
+	}
+
+	if !request {
+		if len(incoming.Blocks()) > 0 {
+			log.Debugf("ignoring blocks from %s: denied by peer policy", p)
+		}
+		return out
+	}
+
+	for _, c := range incoming.Haves() {
+		out.AddHave(c)
+	}
+	for _, b := range incoming.Blocks() {
+		if err := verifyBlock(b); err != nil {
+			log.Warningf("invalid block %s from %s: %s", b.Cid(), p, err)
+			policy.ReportInvalidBlock(p)
+			continue
+		}
+		out.AddBlock(b)
+	}
+	return out
+}
+
+// verifyBlock checks that the cid of b is acceptable, and that the data of b
+// hashes to it.
+func verifyBlock(b blocks.Block) error {
+	// hash security
+	if err := verifcid.ValidateCid(b.Cid()); err != nil {
+		return err
+	}
+
+	c, err := b.Cid().Prefix().Sum(b.RawData())
+	if err != nil {
+		return err
+	}
+	if !c.Equals(b.Cid()) {
+		return errHashMismatch
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package bitswap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+const sendHavesTimeout = time.Second * 10
+
+// presenceBufferPerKey is how many announcements per key a FindHaves caller
+// may fall behind before further ones are dropped.
+const presenceBufferPerKey = 8
+
+var _ exchange.PresenceChecker = (*Bitswap)(nil)
+
+// FindHaves asks the connected peers supporting want-have entries whether
+// they have the given blocks, without requesting the blocks themselves.
+// Peers confirming presence are sent on the returned channel, which is
+// closed once ctx is done.
+func (bs *Bitswap) FindHaves(ctx context.Context, ks []*cid.Cid) (<-chan exchange.Presence, error) {
+	out := bs.presence.Subscribe(ctx, ks)
+
+	msg := bsmsg.New(false)
+	for _, c := range ks {
+		msg.AddWantHave(c)
+	}
+
+	for _, p := range bs.wm.ConnectedPeers() {
+		if !bs.allowRequest(p) || !bs.network.SupportsHave(p) {
+			continue
+		}
+		go func(p peer.ID) {
+			if err := bs.network.SendMessage(ctx, p, msg); err != nil {
+				log.Infof("sending want-have to %s: %s", p, err)
+			}
+		}(p)
+	}
+	return out, nil
+}
+
+// respondToWantHaves tells p which of the blocks it asked about in
+// want-have entries we have.
+func (bs *Bitswap) respondToWantHaves(p peer.ID, incoming bsmsg.BitSwapMessage) {
+	var haves []*cid.Cid
+	for _, e := range incoming.Wantlist() {
+		if !e.WantHave || e.Cancel {
+			continue
+		}
+		has, err := bs.serve.Has(e.Cid)
+		if err != nil {
+			log.Infof("blockstore.Has error: %s", err)
+			continue
+		}
+		if has {
+			haves = append(haves, e.Cid)
+		}
+	}
+	if len(haves) == 0 {
+		return
+	}
+
+	msg := bsmsg.New(false)
+	for _, c := range haves {
+		msg.AddHave(c)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendHavesTimeout)
+		defer cancel()
+		if err := bs.network.SendMessage(ctx, p, msg); err != nil {
+			log.Infof("sending haves to %s: %s", p, err)
+		}
+	}()
+}
+
+// presencePubSub dispatches have announcements from peers to FindHaves
+// callers.
+type presencePubSub struct {
+	lk   sync.Mutex
+	subs map[string][]*presenceSub
+}
+
+type presenceSub struct {
+	ctx context.Context
+	out chan exchange.Presence
+}
+
+func newPresencePubSub() *presencePubSub {
+	return &presencePubSub{subs: make(map[string][]*presenceSub)}
+}
+
+func (ps *presencePubSub) Subscribe(ctx context.Context, ks []*cid.Cid) <-chan exchange.Presence {
+	sub := &presenceSub{
+		ctx: ctx,
+		out: make(chan exchange.Presence, len(ks)*presenceBufferPerKey),
+	}
+
+	ps.lk.Lock()
+	for _, c := range ks {
+		k := c.KeyString()
+		ps.subs[k] = append(ps.subs[k], sub)
+	}
+	ps.lk.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		ps.lk.Lock()
+		defer ps.lk.Unlock()
+		for _, c := range ks {
+			k := c.KeyString()
+			subs := ps.subs[k]
+			for i, s := range subs {
+				if s == sub {
+					subs[i] = subs[len(subs)-1]
+					subs = subs[:len(subs)-1]
+					break
+				}
+			}
+			if len(subs) == 0 {
+				delete(ps.subs, k)
+			} else {
+				ps.subs[k] = subs
+			}
+		}
+		close(sub.out)
+	}()
+
+	return sub.out
+}
+
+// Publish hands the announcement that p has c to the subscribers of c. It
+// never blocks: announcements to subscribers not keeping up are dropped.
+func (ps *presencePubSub) Publish(p peer.ID, c *cid.Cid) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	for _, sub := range ps.subs[c.KeyString()] {
+		select {
+		case sub.out <- exchange.Presence{Cid: c, Peer: p}:
+		default:
+			log.Debugf("dropping have of %s from %s, subscriber is not keeping up", c, p)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package bitswap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tokenbucket "github.com/ipfs/go-ipfs/thirdparty/tokenbucket"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// UploadLimits bounds the rate at which blocks are served to other peers.
+// Rates are in bytes per second, a zero rate means unlimited. Bursts are in
+// bytes; a zero burst defaults to one second worth of the rate.
+type UploadLimits struct {
+	GlobalRate  int64
+	GlobalBurst int64
+	PeerRate    int64
+	PeerBurst   int64
+}
+
+// SetUploadLimits changes the upload rate limits. It takes effect for the
+// next block sent.
+func (bs *Bitswap) SetUploadLimits(l UploadLimits) {
+	bs.uploadLimiter.setLimits(l)
+}
+
+// UploadLimits returns the current upload rate limits.
+func (bs *Bitswap) UploadLimits() UploadLimits {
+	return bs.uploadLimiter.getLimits()
+}
+
+// uploadLimiter enforces UploadLimits on the block-serving path.
+type uploadLimiter struct {
+	lk     sync.Mutex
+	limits UploadLimits
+	global *tokenbucket.Bucket
+	peers  map[peer.ID]*tokenbucket.Bucket
+
+	now func() time.Time
+}
+
+func newUploadLimiter() *uploadLimiter {
+	return &uploadLimiter{
+		peers: make(map[peer.ID]*tokenbucket.Bucket),
+		now:   time.Now,
+	}
+}
+
+func (l *uploadLimiter) setLimits(limits UploadLimits) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	l.limits = limits
+	l.global = nil
+	if limits.GlobalRate > 0 {
+		l.global = tokenbucket.New(float64(limits.GlobalRate), float64(limits.GlobalBurst), l.now())
+	}
+	l.peers = make(map[peer.ID]*tokenbucket.Bucket)
+}
+
+func (l *uploadLimiter) getLimits() UploadLimits {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.limits
+}
+
+// reserve accounts for n bytes sent to p and returns how long to wait before
+// sending them.
+func (l *uploadLimiter) reserve(p peer.ID, n int) time.Duration {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	if l.global != nil {
+		wait = l.global.Take(n, now)
+	}
+	if l.limits.PeerRate > 0 {
+		b, ok := l.peers[p]
+		if !ok {
+			b = tokenbucket.New(float64(l.limits.PeerRate), float64(l.limits.PeerBurst), now)
+			l.peers[p] = b
+		}
+		if pw := b.Take(n, now); pw > wait {
+			wait = pw
+		}
+	}
+	return wait
+}
+
+// wait blocks until n bytes may be sent to p.
+func (l *uploadLimiter) wait(ctx context.Context, p peer.ID, n int) error {
+	d := l.reserve(p, n)
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// forget drops the per-peer state of p.
+func (l *uploadLimiter) forget(p peer.ID) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	delete(l.peers, p)
+}
@@ -0,0 +1,46 @@
+package bitswap
+
+import (
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+func TestUploadLimiter(t *testing.T) {
+	now := time.Now()
+	l := newUploadLimiter()
+	l.now = func() time.Time { return now }
+
+	a := peer.ID("QmPeerA")
+	b := peer.ID("QmPeerB")
+
+	if d := l.reserve(a, 1<<20); d != 0 {
+		t.Fatal("unlimited uploads should never wait")
+	}
+
+	l.setLimits(UploadLimits{GlobalRate: 1000, PeerRate: 100})
+
+	// within the per-peer burst
+	if d := l.reserve(a, 100); d != 0 {
+		t.Fatalf("expected no wait within the burst, got %s", d)
+	}
+	// a's bucket is empty now, 50 more bytes take half a second
+	if d := l.reserve(a, 50); d != time.Millisecond*500 {
+		t.Fatalf("expected to wait 500ms, got %s", d)
+	}
+	// b has its own bucket
+	if d := l.reserve(b, 100); d != 0 {
+		t.Fatalf("peers should not share a bucket, got %s", d)
+	}
+
+	l.setLimits(UploadLimits{GlobalRate: 1000, GlobalBurst: 500})
+	if d := l.reserve(a, 1000); d != time.Millisecond*500 {
+		t.Fatalf("expected the global limit to apply, got %s", d)
+	}
+
+	now = now.Add(time.Second)
+	if d := l.reserve(b, 400); d != 0 {
+		t.Fatalf("bucket should refill over time, got %s", d)
+	}
+}
@@ -0,0 +1,23 @@
+package bitswap
+
+import (
+	blocks "github.com/ipfs/go-block-format"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// SetReceiveHook makes bitswap call f with every block received from a peer,
+// once it is stored. f must not block. A nil f removes the hook.
+func (bs *Bitswap) SetReceiveHook(f func(p peer.ID, b blocks.Block)) {
+	bs.receiveHookLk.Lock()
+	defer bs.receiveHookLk.Unlock()
+	bs.receiveHook = f
+}
+
+func (bs *Bitswap) received(p peer.ID, b blocks.Block) {
+	bs.receiveHookLk.RLock()
+	f := bs.receiveHook
+	bs.receiveHookLk.RUnlock()
+	if f != nil {
+		f(p, b)
+	}
+}
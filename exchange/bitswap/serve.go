@@ -0,0 +1,55 @@
+package bitswap
+
+import (
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// SetServeBlockstore makes bitswap serve the blocks other peers ask for
+// from s instead of its own blockstore, e.g. to serve blocks stored apart
+// from it. s has to hold the blocks of the blockstore of bitswap too. The
+// blocks fetched for us are still only looked up in and stored to the
+// blockstore of bitswap. A nil s serves the blockstore of bitswap.
+func (bs *Bitswap) SetServeBlockstore(s blockstore.Blockstore) {
+	bs.serve.set(s)
+}
+
+// serveBlockstore is the blockstore the engine serves blocks from. Has and
+// Get read the blockstore set with SetServeBlockstore, if any, the other
+// methods the blockstore of bitswap.
+type serveBlockstore struct {
+	blockstore.Blockstore
+
+	lk    sync.RWMutex
+	serve blockstore.Blockstore
+}
+
+func newServeBlockstore(bs blockstore.Blockstore) *serveBlockstore {
+	return &serveBlockstore{Blockstore: bs}
+}
+
+func (s *serveBlockstore) set(bs blockstore.Blockstore) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.serve = bs
+}
+
+func (s *serveBlockstore) source() blockstore.Blockstore {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	if s.serve == nil {
+		return s.Blockstore
+	}
+	return s.serve
+}
+
+func (s *serveBlockstore) Has(c *cid.Cid) (bool, error) {
+	return s.source().Has(c)
+}
+
+func (s *serveBlockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	return s.source().Get(c)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	notifications "github.com/ipfs/go-ipfs/exchange/bitswap/notifications"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -31,6 +32,8 @@ type Session struct {
 	newReqs      chan []*cid.Cid
 	cancelKeys   chan []*cid.Cid
 	interestReqs chan interestReq
+	wantlistReqs chan chan []*cid.Cid
+	addPeers     chan []peer.ID
 
 	interest  *lru.Cache
 	liveWants map[string]time.Time
@@ -52,6 +55,17 @@ type Session struct {
 // NewSession creates a new bitswap session whose lifetime is bounded by the
 // given context
 func (bs *Bitswap) NewSession(ctx context.Context) *Session {
+	return bs.newSession(ctx, nil)
+}
+
+// NewSessionWithProviders creates a new bitswap session that starts out with
+// the given peers as its active set. Wants are sent to them straight away,
+// and the provider search is only started if they fail to deliver.
+func (bs *Bitswap) NewSessionWithProviders(ctx context.Context, providers []peer.ID) exchange.Fetcher {
+	return bs.newSession(ctx, providers)
+}
+
+func (bs *Bitswap) newSession(ctx context.Context, providers []peer.ID) *Session {
 	s := &Session{
 		activePeers:   make(map[peer.ID]struct{}),
 		liveWants:     make(map[string]time.Time),
@@ -59,6 +73,8 @@ func (bs *Bitswap) NewSession(ctx context.Context) *Session {
 		cancelKeys:    make(chan []*cid.Cid),
 		tofetch:       newCidQueue(),
 		interestReqs:  make(chan interestReq),
+		wantlistReqs:  make(chan chan []*cid.Cid),
+		addPeers:      make(chan []peer.ID),
 		ctx:           ctx,
 		bs:            bs,
 		incoming:      make(chan blkRecv),
@@ -73,6 +89,10 @@ func (bs *Bitswap) NewSession(ctx context.Context) *Session {
 	cache, _ := lru.New(2048)
 	s.interest = cache
 
+	for _, p := range providers {
+		s.addActivePeer(p)
+	}
+
 	bs.sessLk.Lock()
 	bs.sessions = append(bs.sessions, s)
 	bs.sessLk.Unlock()
@@ -205,7 +225,7 @@ func (s *Session) run(ctx context.Context) {
 				s.tofetch.Push(k)
 			}
 		case keys := <-s.cancelKeys:
-			s.cancel(keys)
+			s.cancel(ctx, keys)
 
 		case <-s.tick.C:
 			live := make([]*cid.Cid, 0, len(s.liveWants))
@@ -234,8 +254,14 @@ func (s *Session) run(ctx context.Context) {
 			s.resetTick()
 		case p := <-newpeers:
 			s.addActivePeer(p)
+		case ps := <-s.addPeers:
+			for _, p := range ps {
+				s.addActivePeer(p)
+			}
 		case lwchk := <-s.interestReqs:
 			lwchk.resp <- s.cidIsWanted(lwchk.c)
+		case resp := <-s.wantlistReqs:
+			resp <- s.wantlist()
 		case <-ctx.Done():
 			s.tick.Stop()
 			s.bs.removeSession(s)
@@ -286,19 +312,83 @@ func (s *Session) wantBlocks(ctx context.Context, ks []*cid.Cid) {
 	s.bs.wm.WantBlocks(ctx, ks, s.activePeersArr, s.id)
 }
 
-func (s *Session) cancel(keys []*cid.Cid) {
+func (s *Session) cancel(ctx context.Context, keys []*cid.Cid) {
+	var live []*cid.Cid
 	for _, c := range keys {
 		s.tofetch.Remove(c)
+
+		ks := c.KeyString()
+		if _, ok := s.liveWants[ks]; ok {
+			delete(s.liveWants, ks)
+			live = append(live, c)
+		}
+	}
+	s.bs.CancelWants(live, s.id)
+
+	// refill the slots freed up by the cancelled wants
+	var next []*cid.Cid
+	for len(s.liveWants)+len(next) < activeWantsLimit {
+		c := s.tofetch.Pop()
+		if c == nil {
+			break
+		}
+		next = append(next, c)
+	}
+	if len(next) > 0 {
+		s.wantBlocks(ctx, next)
+	}
+}
+
+func (s *Session) wantlist() []*cid.Cid {
+	out := s.tofetch.Cids()
+	for c := range s.liveWants {
+		cs, _ := cid.Cast([]byte(c))
+		out = append(out, cs)
 	}
+	return out
 }
 
-func (s *Session) cancelWants(keys []*cid.Cid) {
+// CancelWants removes the given keys from this session's wantlist, both
+// locally queued ones and the ones already sent out to peers.
+func (s *Session) CancelWants(keys []*cid.Cid) {
 	select {
 	case s.cancelKeys <- keys:
 	case <-s.ctx.Done():
 	}
 }
 
+// AddProviders adds the given peers to the set of peers this session sends
+// its wants to.
+func (s *Session) AddProviders(peers []peer.ID) {
+	select {
+	case s.addPeers <- peers:
+	case <-s.ctx.Done():
+	}
+}
+
+// FindHaves asks connected peers whether they have the given blocks, see
+// Bitswap.FindHaves.
+func (s *Session) FindHaves(ctx context.Context, ks []*cid.Cid) (<-chan exchange.Presence, error) {
+	return s.bs.FindHaves(ctx, ks)
+}
+
+// Wantlist returns the keys this session is still waiting for.
+func (s *Session) Wantlist() []*cid.Cid {
+	resp := make(chan []*cid.Cid, 1)
+	select {
+	case s.wantlistReqs <- resp:
+	case <-s.ctx.Done():
+		return nil
+	}
+
+	select {
+	case wl := <-resp:
+		return wl
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
 func (s *Session) fetch(ctx context.Context, keys []*cid.Cid) {
 	select {
 	case s.newReqs <- keys:
@@ -312,7 +402,8 @@ func (s *Session) fetch(ctx context.Context, keys []*cid.Cid) {
 // guaranteed on the returned blocks.
 func (s *Session) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan blocks.Block, error) {
 	ctx = logging.ContextWithLoggable(ctx, s.uuid)
-	return getBlocksImpl(ctx, keys, s.notif, s.fetch, s.cancelWants)
+	s.bs.persistedWants().add(keys)
+	return getBlocksImpl(ctx, keys, s.notif, s.fetch, s.CancelWants)
 }
 
 // GetBlock fetches a single block
@@ -359,6 +450,10 @@ func (cq *cidQueue) Has(c *cid.Cid) bool {
 	return cq.eset.Has(c)
 }
 
+func (cq *cidQueue) Cids() []*cid.Cid {
+	return cq.eset.Keys()
+}
+
 func (cq *cidQueue) Len() int {
 	return cq.eset.Len()
 }
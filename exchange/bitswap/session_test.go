@@ -10,6 +10,7 @@ import (
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
 	tu "github.com/libp2p/go-testutil"
 )
 
@@ -324,3 +325,82 @@ func TestWantlistClearsOnCancel(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSessionWithProviders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vnet := getVirtualNetwork()
+	sesgen := NewTestSessionGenerator(vnet)
+	defer sesgen.Close()
+	bgen := blocksutil.NewBlockGenerator()
+
+	inst := sesgen.Instances(10)
+
+	blk := bgen.Next()
+	if err := inst[9].Blockstore().Put(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	ses := inst[0].Exchange.NewSessionWithProviders(ctx, []peer.ID{inst[9].Peer})
+	if _, err := ses.GetBlock(ctx, blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, is := range inst[1:9] {
+		if is.Exchange.counters.messagesRecvd > 0 {
+			t.Fatal("uninvolved nodes should not receive wants", is.Exchange.counters.messagesRecvd)
+		}
+	}
+}
+
+func TestSessionWantlistClearsOnRequestCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	vnet := getVirtualNetwork()
+	sesgen := NewTestSessionGenerator(vnet)
+	defer sesgen.Close()
+	bgen := blocksutil.NewBlockGenerator()
+
+	blks := bgen.Blocks(20)
+	var cids []*cid.Cid
+	for _, blk := range blks {
+		cids = append(cids, blk.Cid())
+	}
+
+	inst := sesgen.Instances(1)
+	a := inst[0]
+
+	ses := a.Exchange.NewSession(ctx)
+
+	reqctx, reqcancel := context.WithCancel(ctx)
+	if _, err := ses.GetBlocks(reqctx, cids); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tu.WaitFor(ctx, func() error {
+		if len(ses.Wantlist()) != len(cids) {
+			return fmt.Errorf("expected %d wants in session", len(cids))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// cancelling the request alone, while the session lives on, must clear
+	// the wants
+	reqcancel()
+
+	if err := tu.WaitFor(ctx, func() error {
+		if len(ses.Wantlist()) > 0 {
+			return fmt.Errorf("expected empty session wantlist")
+		}
+		if len(a.Exchange.GetWantlist()) > 0 {
+			return fmt.Errorf("expected empty wantlist")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,7 +3,11 @@ package bitswap
 import (
 	"sort"
 
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
+
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
 )
 
 type Stat struct {
@@ -42,3 +46,32 @@ func (bs *Bitswap) Stat() (*Stat, error) {
 
 	return st, nil
 }
+
+var _ exchange.Stats = (*Bitswap)(nil)
+
+// PeerStats returns the ledger accounting for the given peer.
+func (bs *Bitswap) PeerStats(p peer.ID) exchange.PeerStats {
+	return receiptToStats(p, bs.engine.LedgerForPeer(p))
+}
+
+// AllPeerStats returns the ledger accounting for every known peer.
+func (bs *Bitswap) AllPeerStats() []exchange.PeerStats {
+	receipts := bs.engine.Ledgers()
+	out := make([]exchange.PeerStats, 0, len(receipts))
+	for p, r := range receipts {
+		out = append(out, receiptToStats(p, r))
+	}
+	return out
+}
+
+func receiptToStats(p peer.ID, r *decision.Receipt) exchange.PeerStats {
+	return exchange.PeerStats{
+		Peer:       p,
+		BytesSent:  r.Sent,
+		BytesRecv:  r.Recv,
+		BlocksSent: r.BlocksSent,
+		BlocksRecv: r.BlocksRecv,
+		Exchanges:  r.Exchanged,
+		DebtRatio:  r.Value,
+	}
+}
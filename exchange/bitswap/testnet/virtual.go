@@ -156,6 +156,12 @@ func (nc *networkClient) ConnectionManager() ifconnmgr.ConnManager {
 	return &ifconnmgr.NullConnMgr{}
 }
 
+// SupportsHave is always true, all peers of the virtual network run this
+// bitswap.
+func (nc *networkClient) SupportsHave(peer.ID) bool {
+	return true
+}
+
 type messagePasser struct {
 	net    *network
 	target peer.ID
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	engine "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
@@ -30,6 +31,9 @@ type WantManager struct {
 	ctx     context.Context
 	cancel  func()
 
+	// allow, if set, reports whether wants may be sent to a peer
+	allow func(peer.ID) bool
+
 	wantlistGauge metrics.Gauge
 	sentHistogram metrics.Histogram
 }
@@ -67,6 +71,7 @@ type msgQueue struct {
 	out     bsmsg.BitSwapMessage
 	network bsnet.BitSwapNetwork
 	wl      *wantlist.ThreadSafe
+	allow   func(peer.ID) bool
 
 	sender bsnet.MessageSender
 
@@ -94,11 +99,12 @@ type wantSet struct {
 }
 
 func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, targets []peer.ID, cancel bool, ses uint64) {
+	base := wirePriority(exchange.PriorityFromContext(ctx))
 	entries := make([]*bsmsg.Entry, 0, len(ks))
 	for i, k := range ks {
 		entries = append(entries, &bsmsg.Entry{
 			Cancel: cancel,
-			Entry:  wantlist.NewRefEntry(k, kMaxPriority-i),
+			Entry:  wantlist.NewRefEntry(k, base-i),
 		})
 	}
 	select {
@@ -108,6 +114,12 @@ func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, targets []
 	}
 }
 
+// wirePriority maps a request priority class to the highest wantlist
+// priority used for it, so that peers serve higher classes first.
+func wirePriority(p exchange.Priority) int {
+	return kMaxPriority - (exchange.NumPriorities-1-int(p))*priorityClassSpan
+}
+
 func (pm *WantManager) ConnectedPeers() []peer.ID {
 	resp := make(chan []peer.ID)
 	pm.peerReqs <- resp
@@ -201,6 +213,11 @@ func (mq *msgQueue) doWork(ctx context.Context) {
 	mq.out = nil
 	mq.outlk.Unlock()
 
+	if mq.allow != nil && !mq.allow(mq.p) {
+		log.Debugf("not sending wantlist to %s: denied by peer policy", mq.p)
+		return
+	}
+
 	// NB: only open a stream if we actually have data to send
 	if mq.sender == nil {
 		err := mq.openSender(ctx)
@@ -357,6 +374,7 @@ func (wm *WantManager) newMsgQueue(p peer.ID) *msgQueue {
 		work:    make(chan struct{}, 1),
 		wl:      wantlist.NewThreadSafe(),
 		network: wm.network,
+		allow:   wm.allow,
 		p:       p,
 		refcnt:  1,
 	}
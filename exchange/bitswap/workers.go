@@ -59,6 +59,11 @@ func (bs *Bitswap) taskWorker(ctx context.Context, id int) {
 				if !ok {
 					continue
 				}
+				if !bs.allowServe(envelope.Peer) {
+					// the peer got denied after its want was queued
+					envelope.Sent()
+					continue
+				}
 				log.Event(ctx, "Bitswap.TaskWorker.Work", logging.LoggableF(func() map[string]interface{} {
 					return logging.LoggableMap{
 						"ID":     id,
@@ -67,6 +72,20 @@ func (bs *Bitswap) taskWorker(ctx context.Context, id int) {
 					}
 				}))
 
+				if err := bs.uploadLimiter.wait(ctx, envelope.Peer, len(envelope.Block.RawData())); err != nil {
+					envelope.Sent()
+					return
+				}
+
+				size := int64(len(envelope.Block.RawData()))
+				release, err := bs.reserveMemory(size)
+				if err != nil {
+					// the peer gets the block when it asks again
+					log.Infof("not sending block %s to %s: %s", envelope.Block.Cid(), envelope.Peer, err)
+					envelope.Sent()
+					continue
+				}
+
 				// update the BS ledger to reflect sent message
 				// TODO: Should only track *useful* messages in ledger
 				outgoing := bsmsg.New(false)
@@ -74,9 +93,10 @@ func (bs *Bitswap) taskWorker(ctx context.Context, id int) {
 				bs.engine.MessageSent(envelope.Peer, outgoing)
 
 				bs.wm.SendBlock(ctx, envelope)
+				release()
 				bs.counterLk.Lock()
 				bs.counters.blocksSent++
-				bs.counters.dataSent += uint64(len(envelope.Block.RawData()))
+				bs.counters.dataSent += uint64(size)
 				bs.counterLk.Unlock()
 			case <-ctx.Done():
 				return
@@ -0,0 +1,214 @@
+// Package delegated announces provider records through a remote HTTP
+// providers API, so that nodes which can't keep up DHT reachability can
+// still make their content discoverable.
+//
+// Announcements are batched and sent as
+//
+//	POST {endpoint}/providers
+//	{"ID": "<peer id>", "Addrs": ["<multiaddr>", ...], "Keys": ["<cid>", ...]}
+//
+// Failed batches are retried with exponential backoff.
+package delegated
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+	routing "github.com/libp2p/go-libp2p-routing"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("delegated")
+
+const (
+	// DefaultBatchSize is the maximum number of keys sent in one request.
+	DefaultBatchSize = 100
+	// DefaultBatchInterval is how long keys are collected before a
+	// partial batch is sent.
+	DefaultBatchInterval = time.Second * 5
+	// DefaultRetries is how many times a failed batch is retried.
+	DefaultRetries = 3
+
+	queueSize = 1024
+)
+
+// retryBackoff is the delay before the first retry, doubled for every
+// following one.
+var retryBackoff = time.Second * 2
+
+// Provider batches provide announcements and sends them to a remote
+// providers API.
+type Provider struct {
+	endpoint string
+	self     peer.ID
+	addrs    func() []ma.Multiaddr
+	client   *http.Client
+
+	BatchSize     int
+	BatchInterval time.Duration
+	Retries       int
+
+	queue chan *cid.Cid
+}
+
+// New creates a Provider announcing self, reachable at the addresses
+// returned by addrs, to the providers API at endpoint. Call Run to start
+// sending announcements.
+func New(endpoint string, self peer.ID, addrs func() []ma.Multiaddr) *Provider {
+	return &Provider{
+		endpoint:      strings.TrimSuffix(endpoint, "/"),
+		self:          self,
+		addrs:         addrs,
+		client:        &http.Client{Timeout: time.Minute},
+		BatchSize:     DefaultBatchSize,
+		BatchInterval: DefaultBatchInterval,
+		Retries:       DefaultRetries,
+		queue:         make(chan *cid.Cid, queueSize),
+	}
+}
+
+// Provide queues c to be announced. When announcements are produced faster
+// than they can be sent, it blocks until there is room in the queue or ctx
+// is done.
+func (p *Provider) Provide(ctx context.Context, c *cid.Cid) error {
+	select {
+	case p.queue <- c:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run sends queued announcements until ctx is done.
+func (p *Provider) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.BatchInterval)
+	defer ticker.Stop()
+
+	var batch []*cid.Cid
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.sendWithRetry(ctx, batch); err != nil {
+			log.Warningf("announcing %d keys to %s: %s", len(batch), p.endpoint, err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case c := <-p.queue:
+			batch = append(batch, c)
+			if len(batch) >= p.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Provider) sendWithRetry(ctx context.Context, keys []*cid.Cid) error {
+	backoff := retryBackoff
+	var err error
+	for i := 0; i <= p.Retries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+		}
+
+		err = p.send(ctx, keys)
+		if err == nil {
+			return nil
+		}
+		log.Debugf("announce attempt %d to %s failed: %s", i+1, p.endpoint, err)
+	}
+	return err
+}
+
+type announcement struct {
+	ID    string
+	Addrs []string
+	Keys  []string
+}
+
+func (p *Provider) send(ctx context.Context, keys []*cid.Cid) error {
+	ann := announcement{ID: p.self.Pretty()}
+	for _, a := range p.addrs() {
+		ann.Addrs = append(ann.Addrs, a.String())
+	}
+	for _, c := range keys {
+		ann.Keys = append(ann.Keys, c.String())
+	}
+
+	body, err := json.Marshal(ann)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", p.endpoint+"/providers", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// routingWithDelegates sends provide announcements to the delegates, and
+// also to the wrapped routing unless exclusive is set.
+type routingWithDelegates struct {
+	routing.ContentRouting
+	delegates []*Provider
+	exclusive bool
+}
+
+// Wrap returns a content routing which, in addition to (or, if exclusive,
+// instead of) announcing through r, queues provide announcements on the
+// given delegates.
+func Wrap(r routing.ContentRouting, exclusive bool, delegates ...*Provider) routing.ContentRouting {
+	return &routingWithDelegates{
+		ContentRouting: r,
+		delegates:      delegates,
+		exclusive:      exclusive,
+	}
+}
+
+func (r *routingWithDelegates) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	var derr error
+	if brdcst {
+		for _, d := range r.delegates {
+			if err := d.Provide(ctx, c); err != nil {
+				derr = err
+			}
+		}
+	}
+
+	if r.exclusive {
+		return derr
+	}
+	return r.ContentRouting.Provide(ctx, c, brdcst)
+}
@@ -0,0 +1,104 @@
+package delegated
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestBatchedAnnounceWithRetry(t *testing.T) {
+	retryBackoff = time.Millisecond
+
+	var lk sync.Mutex
+	var anns []announcement
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lk.Lock()
+		defer lk.Unlock()
+		calls++
+		if calls == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/providers" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var ann announcement
+		if err := json.NewDecoder(r.Body).Decode(&ann); err != nil {
+			t.Error(err)
+		}
+		anns = append(anns, ann)
+	}))
+	defer srv.Close()
+
+	addr, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/4001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(srv.URL+"/", peer.ID("QmPeerA"), func() []ma.Multiaddr { return []ma.Multiaddr{addr} })
+	p.BatchSize = 3
+	p.BatchInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	bgen := blocksutil.NewBlockGenerator()
+	for _, b := range bgen.Blocks(3) {
+		if err := p.Provide(ctx, b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		lk.Lock()
+		n := len(anns)
+		lk.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("batch was never announced")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	lk.Lock()
+	defer lk.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected one retry, got %d calls", calls)
+	}
+	if len(anns[0].Keys) != 3 {
+		t.Fatalf("expected a batch of 3 keys, got %d", len(anns[0].Keys))
+	}
+	if len(anns[0].Addrs) != 1 || anns[0].Addrs[0] != addr.String() {
+		t.Fatal("announcement should carry our addresses")
+	}
+}
+
+func TestProvideBlocksWhenQueueFull(t *testing.T) {
+	p := New("http://127.0.0.1:1", peer.ID("QmPeerA"), func() []ma.Multiaddr { return nil })
+	p.queue = make(chan *cid.Cid, 1)
+
+	bgen := blocksutil.NewBlockGenerator()
+	if err := p.Provide(context.Background(), bgen.Next().Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if err := p.Provide(ctx, bgen.Next().Cid()); err != context.DeadlineExceeded {
+		t.Fatalf("expected provide to block until the deadline, got %v", err)
+	}
+}
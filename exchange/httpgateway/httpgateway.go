@@ -0,0 +1,200 @@
+// Package httpgateway implements an exchange that fetches blocks from a set
+// of trusted HTTP gateways. It is meant as a fallback for nodes which cannot
+// reach the rest of the network over bitswap, e.g. behind restrictive NATs.
+package httpgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("httpgateway")
+
+// ErrHashMismatch is returned when a gateway responds with data which
+// doesn't hash to the requested CID.
+var ErrHashMismatch = errors.New("httpgateway: block data does not match the requested cid")
+
+// ErrNoGateways is returned when the exchange isn't configured with any
+// gateway to fetch from.
+var ErrNoGateways = errors.New("httpgateway: no gateways configured")
+
+const (
+	// MaxBlockSize is the largest block a gateway response may contain.
+	MaxBlockSize = 1 << 21
+
+	// fetchWorkers bounds the number of concurrent requests made by a
+	// single GetBlocks call.
+	fetchWorkers = 8
+
+	requestTimeout = time.Minute
+)
+
+// Exchange is an exchange.Interface which retrieves blocks from HTTP
+// gateways, verifying their hashes on receipt.
+type Exchange struct {
+	gateways []string
+	client   *http.Client
+	bs       blockstore.Blockstore
+}
+
+var _ exchange.Interface = (*Exchange)(nil)
+
+// New creates a new gateway exchange. Fetched blocks are put into the given
+// blockstore. Gateways are tried in order, e.g. "https://ipfs.io".
+func New(bs blockstore.Blockstore, gateways []string) *Exchange {
+	gws := make([]string, 0, len(gateways))
+	for _, gw := range gateways {
+		gws = append(gws, strings.TrimRight(gw, "/"))
+	}
+
+	return &Exchange{
+		gateways: gws,
+		client:   &http.Client{Timeout: requestTimeout},
+		bs:       bs,
+	}
+}
+
+// GetBlock fetches the block for the given cid from the first gateway able
+// to provide it.
+func (e *Exchange) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	if len(e.gateways) == 0 {
+		return nil, ErrNoGateways
+	}
+
+	var lastErr error
+	for _, gw := range e.gateways {
+		blk, err := e.fetch(ctx, gw, c)
+		if err == nil {
+			if err := e.bs.Put(blk); err != nil {
+				return nil, err
+			}
+			return blk, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		log.Debugf("fetching %s from %s: %s", c, gw, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// GetBlocks fetches the given blocks concurrently, returning them on the
+// channel as they arrive. Blocks no gateway could provide are skipped.
+func (e *Exchange) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block)
+	todo := make(chan *cid.Cid)
+
+	workers := fetchWorkers
+	if len(ks) < workers {
+		workers = len(ks)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range todo {
+				blk, err := e.GetBlock(ctx, c)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- blk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(todo)
+		for _, c := range ks {
+			select {
+			case todo <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+func (e *Exchange) fetch(ctx context.Context, gw string, c *cid.Cid) (blocks.Block, error) {
+	url := fmt.Sprintf("%s/ipfs/%s?format=raw", gw, c)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("httpgateway: unexpected status from %s: %s", gw, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxBlockSize {
+		return nil, fmt.Errorf("httpgateway: block from %s exceeds %d bytes", gw, MaxBlockSize)
+	}
+
+	// hash security
+	chk, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !chk.Equals(c) {
+		return nil, ErrHashMismatch
+	}
+
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// HasBlock stores the block locally. Gateways are read-only, so nothing is
+// announced.
+func (e *Exchange) HasBlock(b blocks.Block) error {
+	return e.bs.Put(b)
+}
+
+// IsOnline returns true, gateways are remote by definition.
+func (e *Exchange) IsOnline() bool {
+	return true
+}
+
+// Close is a noop.
+func (e *Exchange) Close() error {
+	return nil
+}
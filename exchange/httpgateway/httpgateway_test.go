@@ -0,0 +1,118 @@
+package httpgateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func newGateway(t *testing.T, blks []blocks.Block, corrupt bool) *httptest.Server {
+	byCid := make(map[string]blocks.Block)
+	for _, b := range blks {
+		byCid[b.Cid().String()] = b
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "raw" {
+			t.Errorf("expected raw block request, got %s", r.URL)
+		}
+		b, ok := byCid[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if corrupt {
+			w.Write([]byte("not the data you're looking for"))
+			return
+		}
+		w.Write(b.RawData())
+	}))
+}
+
+func newBlockstore() blockstore.Blockstore {
+	return blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+}
+
+func TestGetBlock(t *testing.T) {
+	bgen := blocksutil.NewBlockGenerator()
+	blk := bgen.Next()
+
+	empty := newGateway(t, nil, false)
+	defer empty.Close()
+	full := newGateway(t, []blocks.Block{blk}, false)
+	defer full.Close()
+
+	bs := newBlockstore()
+	ex := New(bs, []string{empty.URL, full.URL + "/"})
+
+	out, err := ex.GetBlock(context.Background(), blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Cid().Equals(blk.Cid()) {
+		t.Fatal("got wrong block")
+	}
+
+	has, err := bs.Has(blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("fetched block should be stored locally")
+	}
+}
+
+func TestGetBlockHashMismatch(t *testing.T) {
+	bgen := blocksutil.NewBlockGenerator()
+	blk := bgen.Next()
+
+	gw := newGateway(t, []blocks.Block{blk}, true)
+	defer gw.Close()
+
+	ex := New(newBlockstore(), []string{gw.URL})
+	if _, err := ex.GetBlock(context.Background(), blk.Cid()); err != ErrHashMismatch {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+}
+
+func TestGetBlocks(t *testing.T) {
+	bgen := blocksutil.NewBlockGenerator()
+	blks := bgen.Blocks(20)
+
+	gw := newGateway(t, blks[:15], false)
+	defer gw.Close()
+
+	var ks []*cid.Cid
+	for _, b := range blks {
+		ks = append(ks, b.Cid())
+	}
+
+	ex := New(newBlockstore(), []string{gw.URL})
+	ch, err := ex.GetBlocks(context.Background(), ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := cid.NewSet()
+	for b := range ch {
+		got.Add(b.Cid())
+	}
+	if got.Len() != 15 {
+		t.Fatalf("expected 15 blocks, got %d", got.Len())
+	}
+	for _, b := range blks[:15] {
+		if !got.Has(b.Cid()) {
+			t.Fatalf("missing block %s", b.Cid())
+		}
+	}
+}
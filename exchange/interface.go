@@ -8,6 +8,7 @@ import (
 	blocks "github.com/ipfs/go-block-format"
 
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
 )
 
 // Interface defines the functionality of the IPFS block exchange protocol.
@@ -30,9 +31,148 @@ type Fetcher interface {
 	GetBlocks(context.Context, []*cid.Cid) (<-chan blocks.Block, error)
 }
 
+// WantCanceler is a Fetcher which can be told to drop outstanding wants for
+// blocks that nobody is waiting for anymore.
+type WantCanceler interface {
+	CancelWants([]*cid.Cid)
+}
+
+// Wantlister is a Fetcher which can report the blocks it is still trying to
+// retrieve.
+type Wantlister interface {
+	Wantlist() []*cid.Cid
+}
+
 // SessionExchange is an exchange.Interface which supports
 // sessions.
 type SessionExchange interface {
 	Interface
 	NewSession(context.Context) Interface
 }
+
+// HintedSessionExchange is an exchange.Interface which supports sessions
+// that start out with a known set of providers for the requested blocks.
+type HintedSessionExchange interface {
+	Interface
+	NewSessionWithProviders(context.Context, []peer.ID) Fetcher
+}
+
+// PeerStats holds the accounting of the data exchanged with a single peer.
+type PeerStats struct {
+	Peer       peer.ID
+	BytesSent  uint64
+	BytesRecv  uint64
+	BlocksSent uint64
+	BlocksRecv uint64
+
+	// Exchanges is the number of individual exchanges with the peer.
+	Exchanges uint64
+
+	// DebtRatio is the ratio of bytes sent to bytes received.
+	DebtRatio float64
+}
+
+// Stats is an exchange which keeps per-peer accounting.
+type Stats interface {
+	// PeerStats returns the accounting for the given peer.
+	PeerStats(peer.ID) PeerStats
+
+	// AllPeerStats returns the accounting for all peers the exchange has
+	// interacted with.
+	AllPeerStats() []PeerStats
+}
+
+// Presence reports that a peer claims to have a block.
+type Presence struct {
+	Cid  *cid.Cid
+	Peer peer.ID
+}
+
+// PresenceChecker is an exchange which can ask peers whether they have
+// blocks without transferring them.
+type PresenceChecker interface {
+	// FindHaves asks peers whether they have the given blocks. Peers
+	// confirming presence are sent on the returned channel, which is closed
+	// once the context is done.
+	FindHaves(context.Context, []*cid.Cid) (<-chan Presence, error)
+}
+
+// ProviderAdder is a Fetcher which can be pointed at peers known to have
+// the blocks it is fetching.
+type ProviderAdder interface {
+	AddProviders([]peer.ID)
+}
+
+// Selector describes the part of a DAG to fetch, relative to its root.
+type Selector struct {
+	// MaxDepth limits how many links deep to descend from the root. A
+	// negative value means no limit, 0 only selects the root.
+	MaxDepth int
+}
+
+// SelectAll selects an entire DAG.
+var SelectAll = Selector{MaxDepth: -1}
+
+// DAGFetcher is an exchange which can retrieve a whole (partial) DAG in a
+// single request instead of negotiating every block separately.
+type DAGFetcher interface {
+	// FetchDAG requests the blocks of the DAG under root matched by sel.
+	// The returned channel is closed once the remote side is done or ctx
+	// is cancelled. Blocks may arrive in any order.
+	FetchDAG(ctx context.Context, root *cid.Cid, sel Selector) (<-chan blocks.Block, error)
+}
+
+// PeerPolicy decides which peers an exchange may trade blocks with.
+type PeerPolicy interface {
+	// AllowServe reports whether blocks may be sent to p.
+	AllowServe(p peer.ID) bool
+
+	// AllowRequest reports whether blocks may be requested from, and
+	// accepted from, p.
+	AllowRequest(p peer.ID) bool
+
+	// ReportInvalidBlock records that p sent a block which failed
+	// verification.
+	ReportInvalidBlock(p peer.ID)
+}
+
+// PolicyExchange is an exchange which consults a PeerPolicy before serving
+// or requesting blocks.
+type PolicyExchange interface {
+	SetPeerPolicy(PeerPolicy)
+}
+
+// Priority is the class of a block request. Exchanges serve higher classes
+// first.
+type Priority int
+
+const (
+	// PriorityBackground is for work nobody is waiting on, such as
+	// fetching pinned DAGs.
+	PriorityBackground Priority = iota
+	// PriorityNormal is the default.
+	PriorityNormal
+	// PriorityInteractive is for requests a user is waiting on, such as
+	// gateway page loads.
+	PriorityInteractive
+
+	// NumPriorities is the number of priority classes.
+	NumPriorities = int(PriorityInteractive) + 1
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context making block requests made with it use
+// priority p.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set on ctx with WithPriority,
+// or PriorityNormal.
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
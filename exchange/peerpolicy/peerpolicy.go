@@ -0,0 +1,154 @@
+// Package peerpolicy implements the default exchange.PeerPolicy, supporting
+// allowlists, blocklists and temporary bans for misbehaving peers.
+package peerpolicy
+
+import (
+	"sync"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var log = logging.Logger("peerpolicy")
+
+const (
+	// DefaultBanThreshold is the number of invalid blocks after which a peer
+	// gets banned.
+	DefaultBanThreshold = 3
+
+	// DefaultBanDuration is how long a peer stays banned.
+	DefaultBanDuration = time.Minute * 30
+)
+
+var _ exchange.PeerPolicy = (*Policy)(nil)
+
+// Policy is a PeerPolicy which denies peers on its blocklist, or all peers
+// not on its allowlist if one is set, and temporarily bans peers sending
+// invalid blocks.
+type Policy struct {
+	// BanThreshold is the number of invalid blocks a peer may send before
+	// being banned. Zero disables automatic bans.
+	BanThreshold int
+
+	// BanDuration is how long automatic bans last.
+	BanDuration time.Duration
+
+	lk      sync.Mutex
+	allow   map[peer.ID]struct{}
+	block   map[peer.ID]struct{}
+	invalid map[peer.ID]int
+	bans    map[peer.ID]time.Time
+
+	now func() time.Time
+}
+
+// New returns a policy allowing all peers, with the default ban settings.
+func New() *Policy {
+	return &Policy{
+		BanThreshold: DefaultBanThreshold,
+		BanDuration:  DefaultBanDuration,
+		allow:        make(map[peer.ID]struct{}),
+		block:        make(map[peer.ID]struct{}),
+		invalid:      make(map[peer.ID]int),
+		bans:         make(map[peer.ID]time.Time),
+		now:          time.Now,
+	}
+}
+
+// Allow adds peers to the allowlist. Once the allowlist is non-empty, only
+// the peers on it are traded with.
+func (p *Policy) Allow(peers ...peer.ID) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	for _, pid := range peers {
+		p.allow[pid] = struct{}{}
+	}
+}
+
+// Block adds peers to the blocklist.
+func (p *Policy) Block(peers ...peer.ID) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	for _, pid := range peers {
+		p.block[pid] = struct{}{}
+	}
+}
+
+// Unblock removes peers from the blocklist and lifts their bans.
+func (p *Policy) Unblock(peers ...peer.ID) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	for _, pid := range peers {
+		delete(p.block, pid)
+		delete(p.bans, pid)
+		delete(p.invalid, pid)
+	}
+}
+
+// Banned reports whether pid is currently banned for sending invalid
+// blocks.
+func (p *Policy) Banned(pid peer.ID) bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.bannedLocked(pid)
+}
+
+func (p *Policy) bannedLocked(pid peer.ID) bool {
+	until, ok := p.bans[pid]
+	if !ok {
+		return false
+	}
+	if p.now().After(until) {
+		delete(p.bans, pid)
+		return false
+	}
+	return true
+}
+
+func (p *Policy) allowed(pid peer.ID) bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	if _, ok := p.block[pid]; ok {
+		return false
+	}
+	if len(p.allow) > 0 {
+		if _, ok := p.allow[pid]; !ok {
+			return false
+		}
+	}
+	return !p.bannedLocked(pid)
+}
+
+// AllowServe implements exchange.PeerPolicy.
+func (p *Policy) AllowServe(pid peer.ID) bool {
+	return p.allowed(pid)
+}
+
+// AllowRequest implements exchange.PeerPolicy.
+func (p *Policy) AllowRequest(pid peer.ID) bool {
+	return p.allowed(pid)
+}
+
+// ReportInvalidBlock implements exchange.PeerPolicy. Once a peer reaches
+// BanThreshold invalid blocks it is banned for BanDuration.
+func (p *Policy) ReportInvalidBlock(pid peer.ID) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	if p.BanThreshold <= 0 {
+		return
+	}
+
+	p.invalid[pid]++
+	if p.invalid[pid] < p.BanThreshold {
+		return
+	}
+
+	log.Warningf("banning %s for %s after %d invalid blocks", pid.Pretty(), p.BanDuration, p.invalid[pid])
+	delete(p.invalid, pid)
+	p.bans[pid] = p.now().Add(p.BanDuration)
+}
@@ -0,0 +1,62 @@
+package peerpolicy
+
+import (
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+const (
+	peerA = peer.ID("QmPeerA")
+	peerB = peer.ID("QmPeerB")
+)
+
+func TestAllowAndBlockLists(t *testing.T) {
+	p := New()
+	if !p.AllowServe(peerA) || !p.AllowRequest(peerB) {
+		t.Fatal("empty policy should allow everyone")
+	}
+
+	p.Block(peerA)
+	if p.AllowServe(peerA) || p.AllowRequest(peerA) {
+		t.Fatal("blocked peer should be denied")
+	}
+	p.Unblock(peerA)
+	if !p.AllowServe(peerA) {
+		t.Fatal("unblocked peer should be allowed")
+	}
+
+	p.Allow(peerA)
+	if !p.AllowRequest(peerA) {
+		t.Fatal("allowlisted peer should be allowed")
+	}
+	if p.AllowRequest(peerB) {
+		t.Fatal("peer not on the allowlist should be denied")
+	}
+}
+
+func TestBanAfterInvalidBlocks(t *testing.T) {
+	now := time.Now()
+	p := New()
+	p.BanThreshold = 2
+	p.BanDuration = time.Minute
+	p.now = func() time.Time { return now }
+
+	p.ReportInvalidBlock(peerA)
+	if p.Banned(peerA) {
+		t.Fatal("peer should not be banned below the threshold")
+	}
+	p.ReportInvalidBlock(peerA)
+	if !p.Banned(peerA) || p.AllowRequest(peerA) {
+		t.Fatal("peer should be banned after reaching the threshold")
+	}
+	if !p.AllowRequest(peerB) {
+		t.Fatal("ban should only affect the offending peer")
+	}
+
+	now = now.Add(time.Minute * 2)
+	if p.Banned(peerA) || !p.AllowServe(peerA) {
+		t.Fatal("ban should expire")
+	}
+}
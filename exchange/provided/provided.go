@@ -0,0 +1,271 @@
+// Package provided keeps track of the content the node announces, so that
+// operators can check what is provided and when it was last announced, and
+// of the content they stopped announcing.
+package provided
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	routing "github.com/libp2p/go-libp2p-routing"
+)
+
+var log = logging.Logger("provided")
+
+// RecordLifetime is how long provider records are kept by the peers they
+// are announced to.
+const RecordLifetime = 24 * time.Hour
+
+// pruneInterval is how often the records of content which wasn't announced
+// for RecordLifetime are dropped.
+const pruneInterval = time.Hour
+
+var (
+	recordsKey  = ds.NewKey("/local/provided/records")
+	excludedKey = ds.NewKey("/local/provided/excluded")
+)
+
+// Record is the announcement of a CID.
+type Record struct {
+	Cid *cid.Cid `json:"-"`
+
+	// LastProvided is when the CID was last announced successfully.
+	LastProvided time.Time `json:",omitempty"`
+
+	// LastAttempt is when the CID was last announced.
+	LastAttempt time.Time
+
+	// Err is the error of the last announcement, if it failed.
+	Err string `json:",omitempty"`
+}
+
+// Valid returns whether the last successful announcement hasn't expired yet.
+func (r *Record) Valid() bool {
+	return !r.LastProvided.IsZero() && time.Since(r.LastProvided) < RecordLifetime
+}
+
+// expired returns whether c wasn't even attempted to be announced for
+// RecordLifetime: it was removed, or isn't reprovided anymore, and no peer
+// holds its provider records.
+func (r *Record) expired() bool {
+	return time.Since(r.LastAttempt) >= RecordLifetime
+}
+
+// Provided announces content through a content routing, recording the
+// announcements.
+type Provided struct {
+	routing.ContentRouting
+	d ds.Datastore
+}
+
+// Wrap returns a content routing announcing through r and recording the
+// announcements in d. Content stopped with Stop isn't announced.
+func Wrap(r routing.ContentRouting, d ds.Datastore) *Provided {
+	return &Provided{ContentRouting: r, d: d}
+}
+
+// Provide announces c, unless it was stopped.
+func (p *Provided) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	if !brdcst {
+		return p.ContentRouting.Provide(ctx, c, brdcst)
+	}
+
+	stopped, err := p.d.Has(excludedKey.ChildString(c.String()))
+	if err != nil {
+		return err
+	}
+	if stopped {
+		log.Debugf("not announcing %s, stopped", c)
+		return nil
+	}
+	return p.provide(ctx, c)
+}
+
+func (p *Provided) provide(ctx context.Context, c *cid.Cid) error {
+	now := time.Now()
+	perr := p.ContentRouting.Provide(ctx, c, true)
+	if err := p.record(c, now, perr); err != nil {
+		log.Warningf("recording the announcement of %s: %s", c, err)
+	}
+	return perr
+}
+
+// record records the announcement of c attempted at t. The previous record
+// is only read if the announcement failed, to keep when c was last provided.
+func (p *Provided) record(c *cid.Cid, t time.Time, perr error) error {
+	if perr == nil {
+		return p.putRecord(&Record{Cid: c, LastProvided: t, LastAttempt: t})
+	}
+
+	r, err := p.Record(c)
+	if err != nil {
+		return err
+	}
+	r.LastAttempt = t
+	r.Err = perr.Error()
+	return p.putRecord(r)
+}
+
+// Announce announces c now, resuming its announcements if stopped.
+func (p *Provided) Announce(ctx context.Context, c *cid.Cid) error {
+	err := p.d.Delete(excludedKey.ChildString(c.String()))
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	return p.provide(ctx, c)
+}
+
+// Stop stops announcing c, and forgets its record. The provider records
+// already announced expire on their own, after RecordLifetime at most.
+func (p *Provided) Stop(c *cid.Cid) error {
+	if err := p.d.Put(excludedKey.ChildString(c.String()), []byte{}); err != nil {
+		return err
+	}
+	err := p.d.Delete(recordsKey.ChildString(c.String()))
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// Record returns the record of c, zero if it was never announced or its
+// record expired.
+func (p *Provided) Record(c *cid.Cid) (*Record, error) {
+	r := &Record{Cid: c}
+	v, err := p.d.Get(recordsKey.ChildString(c.String()))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return r, nil
+	default:
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return r, nil
+	}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	if r.expired() {
+		return &Record{Cid: c}, nil
+	}
+	return r, nil
+}
+
+func (p *Provided) putRecord(r *Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return p.d.Put(recordsKey.ChildString(r.Cid.String()), b)
+}
+
+// Records returns the records of the content announced, most recently
+// attempted first.
+func (p *Provided) Records() ([]*Record, error) {
+	all, err := p.records()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0, len(all))
+	for _, r := range all {
+		if !r.expired() {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastAttempt.After(records[j].LastAttempt)
+	})
+	return records, nil
+}
+
+func (p *Provided) records() ([]*Record, error) {
+	res, err := p.d.Query(query.Query{Prefix: recordsKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0, len(entries))
+	for _, e := range entries {
+		c, err := cid.Decode(ds.NewKey(e.Key).BaseNamespace())
+		if err != nil {
+			continue
+		}
+		r := &Record{Cid: c}
+		b, ok := e.Value.([]byte)
+		if !ok || json.Unmarshal(b, r) != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// Prune drops the records of the content which wasn't announced for
+// RecordLifetime, as it was removed or isn't reprovided anymore.
+func (p *Provided) Prune() error {
+	records, err := p.records()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if !r.expired() {
+			continue
+		}
+		err := p.d.Delete(recordsKey.ChildString(r.Cid.String()))
+		if err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run prunes the records periodically until ctx is done.
+func (p *Provided) Run(ctx context.Context) {
+	tick := time.NewTicker(pruneInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if err := p.Prune(); err != nil {
+				log.Warningf("pruning the announcement records: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stopped returns the content which isn't announced anymore.
+func (p *Provided) Stopped() ([]*cid.Cid, error) {
+	res, err := p.d.Query(query.Query{Prefix: excludedKey.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*cid.Cid, 0, len(entries))
+	for _, e := range entries {
+		c, err := cid.Decode(ds.NewKey(e.Key).BaseNamespace())
+		if err != nil {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
@@ -0,0 +1,153 @@
+package provided
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+)
+
+type mockRouting struct {
+	provided []*cid.Cid
+	err      error
+}
+
+func (r *mockRouting) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.provided = append(r.provided, c)
+	return nil
+}
+
+func (r *mockRouting) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo)
+	close(out)
+	return out
+}
+
+func TestProvided(t *testing.T) {
+	ctx := context.Background()
+	r := &mockRouting{}
+	p := Wrap(r, dssync.MutexWrap(ds.NewMapDatastore()))
+
+	a := blocks.NewBlock([]byte("a")).Cid()
+	b := blocks.NewBlock([]byte("b")).Cid()
+
+	if err := p.Provide(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	r.err = errors.New("no peers")
+	if err := p.Provide(ctx, b, true); err != r.err {
+		t.Fatalf("expected %s, got %v", r.err, err)
+	}
+	r.err = nil
+
+	records, err := p.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	// most recently attempted first
+	if !records[0].Cid.Equals(b) || records[0].Err != "no peers" || records[0].Valid() {
+		t.Fatalf("wrong record of the failed announcement: %+v", records[0])
+	}
+	if !records[1].Cid.Equals(a) || records[1].Err != "" || !records[1].Valid() {
+		t.Fatalf("wrong record of the announcement: %+v", records[1])
+	}
+
+	if err := p.Stop(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Provide(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.provided) != 1 {
+		t.Fatal("stopped content was announced")
+	}
+	stopped, err := p.Stopped()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stopped) != 1 || !stopped[0].Equals(a) {
+		t.Fatalf("wrong stopped content: %v", stopped)
+	}
+
+	if err := p.Announce(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.provided) != 2 {
+		t.Fatal("content wasn't announced")
+	}
+	stopped, err = p.Stopped()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stopped) != 0 {
+		t.Fatal("content still stopped after announcing it")
+	}
+	rec, err := p.Record(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Valid() {
+		t.Fatal("expected a valid record")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	r := &mockRouting{}
+	p := Wrap(r, dssync.MutexWrap(ds.NewMapDatastore()))
+
+	a := blocks.NewBlock([]byte("a")).Cid()
+	b := blocks.NewBlock([]byte("b")).Cid()
+
+	if err := p.Provide(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	// a failed announcement keeps when the content was last provided
+	r.err = errors.New("no peers")
+	if err := p.Provide(ctx, a, true); err != r.err {
+		t.Fatalf("expected %s, got %v", r.err, err)
+	}
+	rec, err := p.Record(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.LastProvided.IsZero() || rec.Err != "no peers" {
+		t.Fatalf("wrong record after a failed announcement: %+v", rec)
+	}
+
+	// b wasn't announced for longer than the records are kept
+	old := time.Now().Add(-RecordLifetime - time.Minute)
+	if err := p.putRecord(&Record{Cid: b, LastProvided: old, LastAttempt: old}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := p.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Cid.Equals(a) {
+		t.Fatalf("expected only the record of %s, got %v", a, records)
+	}
+
+	if err := p.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	all, err := p.records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || !all[0].Cid.Equals(a) {
+		t.Fatalf("expected the expired record to be dropped, got %v", all)
+	}
+}
@@ -3,6 +3,7 @@ package reprovide
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
@@ -26,6 +27,7 @@ type Reprovider struct {
 	// The routing system to provide values through
 	rsys routing.ContentRouting
 
+	keyLk       sync.Mutex
 	keyProvider KeyChanFunc
 }
 
@@ -78,9 +80,21 @@ func (rp *Reprovider) Run(tick time.Duration) {
 	}
 }
 
+// SetKeyProvider makes the next reprovides announce the keys of keyProvider,
+// e.g. when the strategy changes.
+func (rp *Reprovider) SetKeyProvider(keyProvider KeyChanFunc) {
+	rp.keyLk.Lock()
+	defer rp.keyLk.Unlock()
+	rp.keyProvider = keyProvider
+}
+
 // Reprovide registers all keys given by rp.keyProvider to libp2p content routing
 func (rp *Reprovider) Reprovide() error {
-	keychan, err := rp.keyProvider(rp.ctx)
+	rp.keyLk.Lock()
+	keyProvider := rp.keyProvider
+	rp.keyLk.Unlock()
+
+	keychan, err := keyProvider(rp.ctx)
 	if err != nil {
 		return fmt.Errorf("Failed to get key chan: %s", err)
 	}
@@ -0,0 +1,169 @@
+package reprovide
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "github.com/ipfs/go-cid"
+	blocks "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Strategy decides which keys get reprovided.
+type Strategy interface {
+	// Keys streams the keys to announce.
+	Keys(context.Context) (<-chan *cid.Cid, error)
+}
+
+// Keys implements Strategy.
+func (f KeyChanFunc) Keys(ctx context.Context) (<-chan *cid.Cid, error) {
+	return f(ctx)
+}
+
+// Env holds the node components strategies draw their keys from.
+type Env struct {
+	Blockstore blocks.Blockstore
+	Pinning    pin.Pinner
+	DAG        ipld.DAGService
+
+	// MFSRoot returns the current root of the files API.
+	MFSRoot func() (*cid.Cid, error)
+}
+
+// StrategyConstructor builds a Strategy from the node's components.
+type StrategyConstructor func(Env) (Strategy, error)
+
+var strategies = struct {
+	sync.Mutex
+	m map[string]StrategyConstructor
+}{m: map[string]StrategyConstructor{
+	"all": func(env Env) (Strategy, error) {
+		return NewBlockstoreProvider(env.Blockstore), nil
+	},
+	"pinned": func(env Env) (Strategy, error) {
+		return NewPinnedProvider(env.Pinning, env.DAG, false), nil
+	},
+	"roots": func(env Env) (Strategy, error) {
+		return NewPinnedProvider(env.Pinning, env.DAG, true), nil
+	},
+	"mfs-root": func(env Env) (Strategy, error) {
+		if env.MFSRoot == nil {
+			return nil, fmt.Errorf("mfs-root strategy needs the files root")
+		}
+		return NewMFSRootProvider(env.MFSRoot), nil
+	},
+}}
+
+// RegisterStrategy makes a custom strategy available under name, for use in
+// the Reprovider.Strategy config option.
+func RegisterStrategy(name string, c StrategyConstructor) error {
+	if name == "" || strings.Contains(name, "+") {
+		return fmt.Errorf("invalid reprovider strategy name '%s'", name)
+	}
+
+	strategies.Lock()
+	defer strategies.Unlock()
+	if _, ok := strategies.m[name]; ok {
+		return fmt.Errorf("reprovider strategy '%s' already registered", name)
+	}
+	strategies.m[name] = c
+	return nil
+}
+
+// NewStrategy builds the strategy registered under name. Several strategies
+// can be combined with '+' (e.g. "roots+mfs-root"), announcing the union of
+// their keys. An empty name means "all".
+func NewStrategy(name string, env Env) (Strategy, error) {
+	if name == "" {
+		name = "all"
+	}
+
+	strategies.Lock()
+	defer strategies.Unlock()
+
+	var parts []Strategy
+	for _, n := range strings.Split(name, "+") {
+		c, ok := strategies.m[n]
+		if !ok {
+			return nil, fmt.Errorf("unknown reprovider strategy '%s'", n)
+		}
+		s, err := c(env)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, s)
+	}
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return NewCombinedProvider(parts...), nil
+}
+
+// NewMFSRootProvider returns a provider supplying only the current root of
+// the files API.
+func NewMFSRootProvider(root func() (*cid.Cid, error)) KeyChanFunc {
+	return func(ctx context.Context) (<-chan *cid.Cid, error) {
+		c, err := root()
+		if err != nil {
+			return nil, err
+		}
+
+		outCh := make(chan *cid.Cid, 1)
+		outCh <- c
+		close(outCh)
+		return outCh, nil
+	}
+}
+
+// NewCombinedProvider returns a provider supplying the keys of all given
+// strategies, each key once.
+func NewCombinedProvider(parts ...Strategy) KeyChanFunc {
+	return func(ctx context.Context) (<-chan *cid.Cid, error) {
+		ctx, cancel := context.WithCancel(ctx)
+
+		var chans []<-chan *cid.Cid
+		for _, s := range parts {
+			ch, err := s.Keys(ctx)
+			if err != nil {
+				// stop the strategies already started and drain their
+				// channels so that none of them is left blocked on a send
+				cancel()
+				for _, ch := range chans {
+					go drain(ch)
+				}
+				return nil, err
+			}
+			chans = append(chans, ch)
+		}
+
+		outCh := make(chan *cid.Cid)
+		go func() {
+			defer close(outCh)
+			defer cancel()
+			seen := cid.NewSet()
+			for _, ch := range chans {
+				for c := range ch {
+					if !seen.Visit(c) {
+						continue
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case outCh <- c:
+					}
+				}
+			}
+		}()
+		return outCh, nil
+	}
+}
+
+func drain(ch <-chan *cid.Cid) {
+	for range ch {
+	}
+}
@@ -0,0 +1,120 @@
+package reprovide_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+	. "github.com/ipfs/go-ipfs/exchange/reprovide"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestCombinedStrategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bgen := blocksutil.NewBlockGenerator()
+	blks := bgen.Blocks(3)
+	for _, b := range blks {
+		if err := bstore.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := blks[0].Cid()
+
+	env := Env{
+		Blockstore: bstore,
+		MFSRoot:    func() (*cid.Cid, error) { return root, nil },
+	}
+
+	s, err := NewStrategy("mfs-root", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := collect(t, ctx, s); len(keys) != 1 || !keys[0].Equals(root) {
+		t.Fatal("mfs-root strategy should only yield the files root")
+	}
+
+	// the root is also in the blockstore and must only be announced once
+	s, err = NewStrategy("all+mfs-root", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := collect(t, ctx, s); len(keys) != len(blks) {
+		t.Fatalf("expected %d keys, got %d", len(blks), len(keys))
+	}
+
+	if _, err := NewStrategy("all+bogus", env); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bgen := blocksutil.NewBlockGenerator()
+	c := bgen.Next().Cid()
+	err := RegisterStrategy("test-custom", func(Env) (Strategy, error) {
+		return NewMFSRootProvider(func() (*cid.Cid, error) { return c, nil }), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterStrategy("all", nil); err == nil {
+		t.Fatal("expected an error when overriding a built-in strategy")
+	}
+
+	s, err := NewStrategy("test-custom", Env{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := collect(t, ctx, s); len(keys) != 1 || !keys[0].Equals(c) {
+		t.Fatal("custom strategy yielded the wrong keys")
+	}
+}
+
+func TestCombinedStrategyStopsOnError(t *testing.T) {
+	stopped := make(chan struct{})
+	started := KeyChanFunc(func(ctx context.Context) (<-chan *cid.Cid, error) {
+		ch := make(chan *cid.Cid)
+		go func() {
+			defer close(stopped)
+			<-ctx.Done()
+		}()
+		return ch, nil
+	})
+	failing := KeyChanFunc(func(context.Context) (<-chan *cid.Cid, error) {
+		return nil, errors.New("failed")
+	})
+
+	s := NewCombinedProvider(started, failing)
+	if _, err := s.Keys(context.Background()); err == nil {
+		t.Fatal("expected the error of the failing strategy")
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second * 5):
+		t.Fatal("strategy started before the failure was not stopped")
+	}
+}
+
+func collect(t *testing.T, ctx context.Context, s Strategy) []*cid.Cid {
+	ch, err := s.Keys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []*cid.Cid
+	for c := range ch {
+		keys = append(keys, c)
+	}
+	return keys
+}
@@ -0,0 +1,160 @@
+// Package tracker lets services other than the DHT, such as trackers,
+// announce the content of the node and find the providers of content.
+// Trackers are registered by name, usually by plugins, and used next to the
+// content routing of the node.
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	routing "github.com/libp2p/go-libp2p-routing"
+)
+
+var log = logging.Logger("tracker")
+
+// Tracker announces content and finds its providers.
+type Tracker interface {
+	// Provide announces that self provides c.
+	Provide(ctx context.Context, c *cid.Cid, self pstore.PeerInfo) error
+
+	// FindProviders returns the providers of c known to the tracker.
+	FindProviders(ctx context.Context, c *cid.Cid) ([]pstore.PeerInfo, error)
+}
+
+var (
+	trackersLk sync.RWMutex
+	trackers   = make(map[string]Tracker)
+)
+
+// Register makes nodes use t, under name, in addition to their content
+// routing.
+func Register(name string, t Tracker) error {
+	trackersLk.Lock()
+	defer trackersLk.Unlock()
+	if _, ok := trackers[name]; ok {
+		return fmt.Errorf("tracker %q already registered", name)
+	}
+	trackers[name] = t
+	return nil
+}
+
+// Registered returns the registered trackers, sorted by name.
+func Registered() []Tracker {
+	trackersLk.RLock()
+	defer trackersLk.RUnlock()
+
+	names := make([]string, 0, len(trackers))
+	for name := range trackers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]Tracker, 0, len(names))
+	for _, name := range names {
+		out = append(out, trackers[name])
+	}
+	return out
+}
+
+// routingWithTrackers announces content to the trackers as well as through
+// the wrapped routing, and finds providers through both.
+type routingWithTrackers struct {
+	routing.ContentRouting
+	trackers []Tracker
+	self     func() pstore.PeerInfo
+}
+
+// Wrap returns a content routing which also announces content to, and finds
+// providers through, the given trackers. self returns the peer announced as
+// the provider.
+func Wrap(r routing.ContentRouting, self func() pstore.PeerInfo, trackers ...Tracker) routing.ContentRouting {
+	return &routingWithTrackers{
+		ContentRouting: r,
+		trackers:       trackers,
+		self:           self,
+	}
+}
+
+func (r *routingWithTrackers) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	if brdcst {
+		self := r.self()
+		for _, t := range r.trackers {
+			if err := t.Provide(ctx, c, self); err != nil {
+				// the DHT announcement still counts
+				log.Debugf("announcing %s to a tracker: %s", c, err)
+			}
+		}
+	}
+	return r.ContentRouting.Provide(ctx, c, brdcst)
+}
+
+// FindProvidersAsync returns the providers found by the trackers and the
+// wrapped routing, as they come, without duplicates. Up to count are
+// returned, or all if count is 0.
+func (r *routingWithTrackers) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	ctx, cancel := context.WithCancel(ctx)
+	found := make(chan pstore.PeerInfo)
+
+	var wg sync.WaitGroup
+	wg.Add(len(r.trackers) + 1)
+	for _, t := range r.trackers {
+		go func(t Tracker) {
+			defer wg.Done()
+			infos, err := t.FindProviders(ctx, c)
+			if err != nil {
+				log.Debugf("finding providers of %s through a tracker: %s", c, err)
+				return
+			}
+			for _, info := range infos {
+				select {
+				case found <- info:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(t)
+	}
+	go func() {
+		defer wg.Done()
+		for info := range r.ContentRouting.FindProvidersAsync(ctx, c, count) {
+			select {
+			case found <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	out := make(chan pstore.PeerInfo)
+	go func() {
+		defer close(out)
+		defer cancel()
+		seen := make(map[peer.ID]struct{})
+		for info := range found {
+			if _, ok := seen[info.ID]; ok {
+				continue
+			}
+			seen[info.ID] = struct{}{}
+			select {
+			case out <- info:
+			case <-ctx.Done():
+				return
+			}
+			if count > 0 && len(seen) >= count {
+				return
+			}
+		}
+	}()
+	return out
+}
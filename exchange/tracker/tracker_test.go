@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	tu "github.com/libp2p/go-testutil"
+)
+
+type mockTracker struct {
+	provided  []*cid.Cid
+	providers []pstore.PeerInfo
+}
+
+func (t *mockTracker) Provide(ctx context.Context, c *cid.Cid, self pstore.PeerInfo) error {
+	t.provided = append(t.provided, c)
+	return nil
+}
+
+func (t *mockTracker) FindProviders(ctx context.Context, c *cid.Cid) ([]pstore.PeerInfo, error) {
+	return t.providers, nil
+}
+
+type mockRouting struct {
+	provided  []*cid.Cid
+	providers []pstore.PeerInfo
+}
+
+func (r *mockRouting) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	r.provided = append(r.provided, c)
+	return nil
+}
+
+func (r *mockRouting) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo, len(r.providers))
+	for _, info := range r.providers {
+		out <- info
+	}
+	close(out)
+	return out
+}
+
+func TestWrap(t *testing.T) {
+	self := tu.RandPeerIDFatal(t)
+	a, b, c := tu.RandPeerIDFatal(t), tu.RandPeerIDFatal(t), tu.RandPeerIDFatal(t)
+
+	tr := &mockTracker{providers: []pstore.PeerInfo{{ID: a}, {ID: b}}}
+	r := &mockRouting{providers: []pstore.PeerInfo{{ID: b}, {ID: c}}}
+	wrapped := Wrap(r, func() pstore.PeerInfo { return pstore.PeerInfo{ID: self} }, tr)
+
+	k := blocks.NewBlock([]byte("foo")).Cid()
+	if err := wrapped.Provide(context.Background(), k, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.provided) != 1 || len(r.provided) != 1 {
+		t.Fatal("content wasn't announced to both the tracker and the routing")
+	}
+
+	found := make(map[peer.ID]int)
+	for info := range wrapped.FindProvidersAsync(context.Background(), k, 0) {
+		found[info.ID]++
+	}
+	if len(found) != 3 || found[a] != 1 || found[b] != 1 || found[c] != 1 {
+		t.Fatalf("wrong providers: %v", found)
+	}
+
+	n := 0
+	for range wrapped.FindProvidersAsync(context.Background(), k, 2) {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 providers, got %d", n)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register("test", new(mockTracker)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("test", new(mockTracker)); err == nil {
+		t.Fatal("expected an error registering a tracker twice")
+	}
+	if len(Registered()) != 1 {
+		t.Fatal("expected the tracker to be registered")
+	}
+}
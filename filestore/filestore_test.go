@@ -5,7 +5,11 @@ import (
 	"context"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 
@@ -162,3 +166,80 @@ func TestDeletes(t *testing.T) {
 		}
 	}
 }
+
+func TestURLs(t *testing.T) {
+	_, fs := newTestFilestore(t)
+
+	buf := make([]byte, 1000)
+	rand.Read(buf)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(buf))
+	}))
+	defer srv.Close()
+
+	n := &posinfo.FilestoreNode{
+		PosInfo: &posinfo.PosInfo{
+			FullPath: srv.URL + "/data",
+			Offset:   100,
+		},
+		Node: dag.NewRawNode(buf[100:200]),
+	}
+	if err := fs.Put(n); err == nil {
+		t.Fatal("expected urls to be rejected")
+	}
+
+	fs.FileManager().AllowUrls = true
+	if err := fs.Put(n); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, err := fs.Get(n.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(blk.RawData(), buf[100:200]) {
+		t.Fatal("data didnt match on the way out")
+	}
+
+	// the data behind the url changes
+	rand.Read(buf)
+	if r := Verify(fs, n.Cid()); r.Status != StatusFileChanged {
+		t.Fatalf("expected status %s, got %s", StatusFileChanged, r.Status)
+	}
+}
+
+func TestClean(t *testing.T) {
+	dir, fs := newTestFilestore(t)
+	_, kept := randomFileAdd(t, fs, dir, 100)
+	fname, gone := randomFileAdd(t, fs, dir, 100)
+
+	if err := os.Remove(fname); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Clean(fs, StatusFileChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 {
+		t.Fatalf("expected no reference to be removed, got %d", len(removed))
+	}
+
+	removed, err = Clean(fs, StatusFileChanged, StatusFileNotFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != len(gone) {
+		t.Fatalf("expected %d references removed, got %d", len(gone), len(removed))
+	}
+	for _, c := range gone {
+		if has, _ := fs.FileManager().Has(c); has {
+			t.Fatal("reference to missing file still in the filestore")
+		}
+	}
+	for _, c := range kept {
+		if _, err := fs.Get(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
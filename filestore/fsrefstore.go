@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	pb "github.com/ipfs/go-ipfs/filestore/pb"
 
@@ -23,11 +25,23 @@ import (
 // FilestorePrefix identifies the key prefix for FileManager blocks.
 var FilestorePrefix = ds.NewKey("filestore")
 
+// urlTimeout bounds fetching a block from its URL, reading the body included.
+const urlTimeout = time.Minute
+
+// urlClient fetches the blocks stored as URLs. Unlike http.DefaultClient, it
+// doesn't wait forever for servers which stopped responding.
+var urlClient = &http.Client{Timeout: urlTimeout}
+
 // FileManager is a blockstore implementation which stores special
 // blocks FilestoreNode type. These nodes only contain a reference
 // to the actual location of the block data in the filesystem
-// (a path and an offset).
+// (a path and an offset), or on the web (a URL and an offset).
 type FileManager struct {
+	// AllowFiles and AllowUrls select the kinds of references
+	// accepted by Put.
+	AllowFiles bool
+	AllowUrls  bool
+
 	ds   ds.Batching
 	root string
 }
@@ -50,8 +64,14 @@ func (c CorruptReferenceError) Error() string {
 // NewFileManager initializes a new file manager with the given
 // datastore and root. All FilestoreNodes paths are relative to the
 // root path given here, which is prepended for any operations.
+// The file manager accepts references to files; set AllowUrls to
+// accept references to URLs as well.
 func NewFileManager(ds ds.Batching, root string) *FileManager {
-	return &FileManager{dsns.Wrap(ds, FilestorePrefix), root}
+	return &FileManager{
+		AllowFiles: true,
+		ds:         dsns.Wrap(ds, FilestorePrefix),
+		root:       root,
+	}
 }
 
 // AllKeysChan returns a channel from which to read the keys stored in
@@ -150,6 +170,13 @@ func unmarshalDataObj(o interface{}) (*pb.DataObj, error) {
 
 // reads and verifies the block
 func (f *FileManager) readDataObj(c *cid.Cid, d *pb.DataObj) ([]byte, error) {
+	if IsURL(d.GetFilePath()) {
+		return f.readURLDataObj(c, d)
+	}
+	return f.readFileDataObj(c, d)
+}
+
+func (f *FileManager) readFileDataObj(c *cid.Cid, d *pb.DataObj) ([]byte, error) {
 	p := filepath.FromSlash(d.GetFilePath())
 	abspath := filepath.Join(f.root, p)
 
@@ -187,6 +214,60 @@ func (f *FileManager) readDataObj(c *cid.Cid, d *pb.DataObj) ([]byte, error) {
 	return outbuf, nil
 }
 
+// readURLDataObj fetches the range of the block from its URL and
+// verifies it.
+func (f *FileManager) readURLDataObj(c *cid.Cid, d *pb.DataObj) ([]byte, error) {
+	req, err := http.NewRequest("GET", d.GetFilePath(), nil)
+	if err != nil {
+		return nil, &CorruptReferenceError{StatusFileError, err}
+	}
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", d.GetOffset(), d.GetOffset()+d.GetSize_()-1))
+
+	// the request is cancelled as soon as the block was read, or on errors
+	ctx, cancel := context.WithTimeout(context.Background(), urlTimeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	res, err := urlClient.Do(req)
+	if err != nil {
+		return nil, &CorruptReferenceError{StatusFileError, err}
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return nil, &CorruptReferenceError{StatusFileNotFound,
+			fmt.Errorf("fetching %s: %s", d.GetFilePath(), res.Status)}
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		return nil, &CorruptReferenceError{StatusFileChanged,
+			fmt.Errorf("fetching %s: %s", d.GetFilePath(), res.Status)}
+	default:
+		return nil, &CorruptReferenceError{StatusFileError,
+			fmt.Errorf("expected partial content fetching %s, got %s", d.GetFilePath(), res.Status)}
+	}
+
+	outbuf := make([]byte, d.GetSize_())
+	_, err = io.ReadFull(res.Body, outbuf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, &CorruptReferenceError{StatusFileChanged, err}
+	} else if err != nil {
+		return nil, &CorruptReferenceError{StatusFileError, err}
+	}
+
+	outcid, err := c.Prefix().Sum(outbuf)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.Equals(outcid) {
+		return nil, &CorruptReferenceError{StatusFileChanged,
+			fmt.Errorf("data at url did not match. %s offset %d", d.GetFilePath(), d.GetOffset())}
+	}
+
+	return outbuf, nil
+}
+
 // Has returns if the FileManager is storing a block reference. It does not
 // validate the data, nor checks if the reference is valid.
 func (f *FileManager) Has(c *cid.Cid) (bool, error) {
@@ -209,16 +290,26 @@ func (f *FileManager) Put(b *posinfo.FilestoreNode) error {
 func (f *FileManager) putTo(b *posinfo.FilestoreNode, to putter) error {
 	var dobj pb.DataObj
 
-	if !filepath.HasPrefix(b.PosInfo.FullPath, f.root) {
-		return fmt.Errorf("cannot add filestore references outside ipfs root (%s)", f.root)
-	}
+	if IsURL(b.PosInfo.FullPath) {
+		if !f.AllowUrls {
+			return fmt.Errorf("urlstore not enabled")
+		}
+		dobj.FilePath = proto.String(b.PosInfo.FullPath)
+	} else {
+		if !f.AllowFiles {
+			return fmt.Errorf("filestore not enabled")
+		}
+		if !filepath.HasPrefix(b.PosInfo.FullPath, f.root) {
+			return fmt.Errorf("cannot add filestore references outside ipfs root (%s)", f.root)
+		}
 
-	p, err := filepath.Rel(f.root, b.PosInfo.FullPath)
-	if err != nil {
-		return err
-	}
+		p, err := filepath.Rel(f.root, b.PosInfo.FullPath)
+		if err != nil {
+			return err
+		}
 
-	dobj.FilePath = proto.String(filepath.ToSlash(p))
+		dobj.FilePath = proto.String(filepath.ToSlash(p))
+	}
 	dobj.Offset = proto.Uint64(b.PosInfo.Offset)
 	dobj.Size_ = proto.Uint64(uint64(len(b.RawData())))
 
@@ -230,6 +321,13 @@ func (f *FileManager) putTo(b *posinfo.FilestoreNode, to putter) error {
 	return to.Put(dshelp.CidToDsKey(b.Cid()), data)
 }
 
+// IsURL returns true if the string represents a valid URL that the
+// urlstore can handle. More specifically it returns true if a string
+// begins with 'http://' or 'https://'.
+func IsURL(str string) bool {
+	return (len(str) > 7 && str[0:7] == "http://") || (len(str) > 8 && str[0:8] == "https://")
+}
+
 // PutMany is like Put() but takes a slice of blocks instead,
 // allowing it to create a batch transaction.
 func (f *FileManager) PutMany(bs []*posinfo.FilestoreNode) error {
@@ -116,6 +116,45 @@ func VerifyAll(fs *Filestore, fileOrder bool) (func() *ListRes, error) {
 	return listAll(fs, true)
 }
 
+// Clean removes the references of the Filestore's FileManager whose
+// block data can't be read back, with one of the given statuses, and
+// returns them. The backing files are left untouched.
+func Clean(fs *Filestore, statuses ...Status) ([]*ListRes, error) {
+	next, err := VerifyAll(fs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var bad []*ListRes
+	for r := next(); r != nil; r = next() {
+		if r.Key != nil && hasStatus(r.Status, statuses) {
+			bad = append(bad, r)
+		}
+	}
+
+	var removed []*ListRes
+	for _, r := range bad {
+		// the reference may have been replaced while verifying the others
+		if !hasStatus(Verify(fs, r.Key).Status, statuses) {
+			continue
+		}
+		if err := fs.fm.DeleteBlock(r.Key); err != nil && err != blockstore.ErrNotFound {
+			return removed, err
+		}
+		removed = append(removed, r)
+	}
+	return removed, nil
+}
+
+func hasStatus(s Status, statuses []Status) bool {
+	for _, st := range statuses {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
+
 func list(fs *Filestore, verify bool, key *cid.Cid) *ListRes {
 	dobj, err := fs.fm.getDataObj(key)
 	if err != nil {
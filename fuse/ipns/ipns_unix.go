@@ -312,6 +312,9 @@ func (s *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	case *mfs.Directory:
 		return &Directory{dir: child}, nil
 	case *mfs.File:
+		if target, ok := child.SymlinkTarget(); ok {
+			return &Link{Target: target}, nil
+		}
 		return &FileNode{fi: child}, nil
 	default:
 		// NB: if this happens, we do not want to continue, unpredictable behaviour
@@ -439,6 +442,21 @@ func (dir *Directory) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Nod
 	return &Directory{dir: child}, nil
 }
 
+// Symlink creates a symlink in this directory.
+func (dir *Directory) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	data, err := ft.SymlinkData(req.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	nd := dag.NodeWithData(data)
+	if err := dir.dir.AddChild(req.NewName, nd); err != nil {
+		return nil, err
+	}
+
+	return &Link{Target: req.Target}, nil
+}
+
 func (fi *FileNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
 	var mfsflag int
 	switch {
@@ -611,4 +629,5 @@ type ipnsFileNode interface {
 }
 
 var _ ipnsFileNode = (*FileNode)(nil)
+var _ fs.NodeSymlinker = (*Directory)(nil)
 var _ ipnsFile = (*File)(nil)
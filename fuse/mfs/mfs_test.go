@@ -0,0 +1,138 @@
+// +build !nofuse
+
+package mfs
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	fstest "bazil.org/fuse/fs/fstestutil"
+	ci "github.com/libp2p/go-testutil/ci"
+)
+
+func maybeSkipFuseTests(t *testing.T) {
+	if ci.NoFuse() {
+		t.Skip("Skipping FUSE tests")
+	}
+}
+
+func setupMfsTest(t *testing.T) (*mfs.Root, *fstest.Mount) {
+	maybeSkipFuseTests(t)
+
+	node, err := core.NewNode(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := mfs.NewRoot(context.Background(), node.DAG, ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mnt, err := fstest.MountedT(t, NewFileSystem(root), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, mnt
+}
+
+func TestMfsReadWrite(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	root, mnt := setupMfsTest(t)
+	defer mnt.Close()
+
+	if err := os.Mkdir(mnt.Dir+"/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("some data")
+	if err := ioutil.WriteFile(mnt.Dir+"/dir/file", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is visible through mfs once closed
+	fsn, err := mfs.Lookup(root, "/dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := fsn.(*mfs.File).Open(mfs.OpenReadOnly, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("expected %q, got %q", data, out)
+	}
+
+	if err := os.Rename(mnt.Dir+"/dir/file", mnt.Dir+"/moved"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = ioutil.ReadFile(mnt.Dir + "/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("expected %q, got %q", data, out)
+	}
+
+	if err := os.Symlink("moved", mnt.Dir+"/link"); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(mnt.Dir + "/link"); err != nil || target != "moved" {
+		t.Fatalf("expected link to moved, got %q (%v)", target, err)
+	}
+
+	if err := os.Remove(mnt.Dir + "/dir"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMfsBusy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	_, mnt := setupMfsTest(t)
+	defer mnt.Close()
+
+	if err := os.Mkdir(mnt.Dir+"/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(mnt.Dir + "/dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second open fails instead of waiting for the first to be closed
+	_, err = os.Open(mnt.Dir + "/dir/file")
+	if perr, ok := err.(*os.PathError); !ok || perr.Err != syscall.EBUSY {
+		t.Fatalf("expected EBUSY, got %v", err)
+	}
+
+	if _, err := f.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// directories are only removed if empty
+	err = os.Remove(mnt.Dir + "/dir")
+	if perr, ok := err.(*os.PathError); !ok || perr.Err != syscall.ENOTEMPTY {
+		t.Fatalf("expected ENOTEMPTY, got %v", err)
+	}
+}
@@ -0,0 +1,497 @@
+// +build !nofuse
+
+// package fuse/mfs implements a read-write fuse filesystem over the mfs root
+// of a node, the files of 'ipfs files'.
+package mfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	ipns "github.com/ipfs/go-ipfs/fuse/ipns"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	fuse "bazil.org/fuse"
+	fs "bazil.org/fuse/fs"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("fuse/mfs")
+
+// FileSystem is the mfs root of a node as a fuse filesystem.
+//
+// Writes go to the files open, and are flushed to their directory when they
+// are closed, so other processes and 'ipfs files' see them then. fsync
+// flushes them up to the root, as 'ipfs files flush' does, and so does
+// unmounting.
+//
+// mfs lets a file be open for writing only once, and not while it is open
+// for reading. Opens which would wait for the other handles of the file to
+// be released fail with EBUSY instead, as requests of the kernel must not
+// block.
+type FileSystem struct {
+	root *mfs.Root
+
+	lk      sync.Mutex
+	handles map[*mfs.File]*handleCount
+}
+
+type handleCount struct {
+	readers int
+	writer  *File
+}
+
+// NewFileSystem returns the fuse filesystem of root.
+func NewFileSystem(root *mfs.Root) *FileSystem {
+	return &FileSystem{
+		root:    root,
+		handles: make(map[*mfs.File]*handleCount),
+	}
+}
+
+// Root returns the root directory of the filesystem.
+func (f *FileSystem) Root() (fs.Node, error) {
+	dir, ok := f.root.GetValue().(*mfs.Directory)
+	if !ok {
+		return nil, errors.New("mfs root is not a directory")
+	}
+	return &Directory{fs: f, dir: dir}, nil
+}
+
+// Destroy flushes the filesystem when it is unmounted.
+func (f *FileSystem) Destroy() {
+	if err := f.root.Flush(); err != nil {
+		log.Errorf("flushing mfs root: %s", err)
+	}
+}
+
+// open opens fi with the given mfs flags, unless it would block.
+func (f *FileSystem) open(fi *mfs.File, flags int) (*File, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	hc := f.handles[fi]
+	if hc == nil {
+		hc = new(handleCount)
+	}
+	write := flags != mfs.OpenReadOnly
+	if hc.writer != nil || (write && hc.readers > 0) {
+		return nil, fuse.Errno(syscall.EBUSY)
+	}
+
+	fd, err := fi.Open(flags, false)
+	if err != nil {
+		return nil, fuseErr(err)
+	}
+	h := &File{fs: f, fi: fi, fd: fd, write: write}
+	if write {
+		hc.writer = h
+	} else {
+		hc.readers++
+	}
+	f.handles[fi] = hc
+	return h, nil
+}
+
+// writer returns the handle fi is open for writing with, if any.
+func (f *FileSystem) writer(fi *mfs.File) *File {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if hc := f.handles[fi]; hc != nil {
+		return hc.writer
+	}
+	return nil
+}
+
+// release counts a handle of fi as released.
+func (f *FileSystem) release(fi *mfs.File, write bool) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	hc := f.handles[fi]
+	if write {
+		hc.writer = nil
+	} else {
+		hc.readers--
+	}
+	if hc.readers == 0 && hc.writer == nil {
+		delete(f.handles, fi)
+	}
+}
+
+// fuseErr returns the fuse error of an mfs error.
+func fuseErr(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case os.ErrNotExist:
+		return fuse.ENOENT
+	case mfs.ErrDirExists:
+		return fuse.Errno(syscall.EEXIST)
+	case mfs.ErrQuotaExceeded:
+		return fuse.Errno(syscall.ENOSPC)
+	default:
+		return err
+	}
+}
+
+// Directory is an mfs directory.
+type Directory struct {
+	fs  *FileSystem
+	dir *mfs.Directory
+}
+
+// Attr returns the attributes of the directory.
+func (d *Directory) Attr(ctx context.Context, a *fuse.Attr) error {
+	attrs := d.dir.Attrs()
+	a.Mode = os.ModeDir | 0755
+	if attrs.Mode != 0 {
+		a.Mode = os.ModeDir | attrs.Mode
+	}
+	a.Mtime = attrs.ModTime
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+	return nil
+}
+
+// Setattr records the mode and modification time of the directory.
+func (d *Directory) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if !req.Valid.Mode() && !req.Valid.Mtime() {
+		return nil
+	}
+	attrs := d.dir.Attrs()
+	if req.Valid.Mode() {
+		attrs.Mode = req.Mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+	if req.Valid.Mtime() {
+		attrs.ModTime = req.Mtime
+	}
+	return fuseErr(d.dir.SetAttrs(attrs))
+}
+
+// Lookup returns the entry of the directory with the given name.
+func (d *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	child, err := d.dir.Child(name)
+	if err != nil {
+		return nil, fuse.ENOENT
+	}
+
+	switch child := child.(type) {
+	case *mfs.Directory:
+		return &Directory{fs: d.fs, dir: child}, nil
+	case *mfs.File:
+		if target, ok := child.SymlinkTarget(); ok {
+			return &ipns.Link{Target: target}, nil
+		}
+		return &FileNode{fs: d.fs, fi: child}, nil
+	default:
+		return nil, fuse.EIO
+	}
+}
+
+// ReadDirAll lists the entries of the directory.
+func (d *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	var entries []fuse.Dirent
+	err := d.dir.ForEachEntry(ctx, func(entry mfs.NodeListing) error {
+		dirent := fuse.Dirent{Name: entry.Name}
+		switch mfs.NodeType(entry.Type) {
+		case mfs.TDir:
+			dirent.Type = fuse.DT_Dir
+		case mfs.TFile:
+			dirent.Type = fuse.DT_File
+		}
+		entries = append(entries, dirent)
+		return nil
+	})
+	return entries, err
+}
+
+// Mkdir creates a directory in the directory.
+func (d *Directory) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	child, err := d.dir.Mkdir(req.Name)
+	if err != nil {
+		return nil, fuseErr(err)
+	}
+	return &Directory{fs: d.fs, dir: child}, nil
+}
+
+// Create creates an empty file in the directory and opens it.
+func (d *Directory) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	flags, err := mfsFlags(req.Flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+	if err := d.dir.AddChild(req.Name, nd); err != nil {
+		return nil, nil, fuseErr(err)
+	}
+	child, err := d.dir.Child(req.Name)
+	if err != nil {
+		return nil, nil, fuseErr(err)
+	}
+	fi, ok := child.(*mfs.File)
+	if !ok {
+		return nil, nil, errors.New("child creation failed")
+	}
+
+	h, err := d.fs.open(fi, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &FileNode{fs: d.fs, fi: fi}, h, nil
+}
+
+// Symlink creates a symlink in the directory.
+func (d *Directory) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	data, err := ft.SymlinkData(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.dir.AddChild(req.NewName, dag.NodeWithData(data)); err != nil {
+		return nil, fuseErr(err)
+	}
+	return &ipns.Link{Target: req.Target}, nil
+}
+
+// Remove removes an entry of the directory, directories only if empty.
+func (d *Directory) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	child, err := d.dir.Child(req.Name)
+	if err != nil {
+		return fuse.ENOENT
+	}
+	if dir, ok := child.(*mfs.Directory); ok {
+		if !req.Dir {
+			return fuse.Errno(syscall.EISDIR)
+		}
+		names, err := dir.ListNames(ctx)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return fuse.Errno(syscall.ENOTEMPTY)
+		}
+	} else if req.Dir {
+		return fuse.Errno(syscall.ENOTDIR)
+	}
+	return fuseErr(d.dir.Unlink(req.Name))
+}
+
+// Rename moves an entry of the directory to newDir, replacing the file with
+// its new name there, if any.
+func (d *Directory) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	dst, ok := newDir.(*Directory)
+	if !ok {
+		return fuse.Errno(syscall.ENOTDIR)
+	}
+
+	cur, err := d.dir.Child(req.OldName)
+	if err != nil {
+		return fuse.ENOENT
+	}
+	nd, err := cur.GetNode()
+	if err != nil {
+		return err
+	}
+
+	if old, err := dst.dir.Child(req.NewName); err == nil {
+		if _, ok := old.(*mfs.Directory); ok {
+			return fuse.Errno(syscall.EEXIST)
+		}
+		if err := dst.dir.Unlink(req.NewName); err != nil {
+			return fuseErr(err)
+		}
+	}
+
+	if err := dst.dir.AddChild(req.NewName, nd); err != nil {
+		return fuseErr(err)
+	}
+	return fuseErr(d.dir.Unlink(req.OldName))
+}
+
+// FileNode is an mfs file.
+type FileNode struct {
+	fs *FileSystem
+	fi *mfs.File
+}
+
+// Attr returns the attributes of the file.
+func (fi *FileNode) Attr(ctx context.Context, a *fuse.Attr) error {
+	size, err := fi.fi.Size()
+	if err != nil {
+		return err
+	}
+	a.Mode = 0644
+	a.Size = uint64(size)
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+
+	nd, err := fi.fi.GetNode()
+	if err != nil {
+		return err
+	}
+	if pbnd, ok := nd.(*dag.ProtoNode); ok {
+		if attrs, err := ft.AttrsFromBytes(pbnd.Data()); err == nil {
+			if attrs.Mode != 0 {
+				a.Mode = attrs.Mode
+			}
+			a.Mtime = attrs.ModTime
+		}
+	}
+	return nil
+}
+
+// Open opens the file.
+func (fi *FileNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	flags, err := mfsFlags(req.Flags)
+	if err != nil {
+		return nil, err
+	}
+	h, err := fi.fs.open(fi.fi, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Flags&fuse.OpenTruncate != 0 && flags != mfs.OpenReadOnly:
+		err = h.fd.Truncate(0)
+	case req.Flags&fuse.OpenAppend != 0 && flags != mfs.OpenReadOnly:
+		_, err = h.fd.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		h.Release(ctx, nil)
+		return nil, err
+	}
+	return h, nil
+}
+
+// Setattr truncates the file if its size is set.
+func (fi *FileNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if !req.Valid.Size() {
+		return nil
+	}
+	h, err := fi.fs.open(fi.fi, mfs.OpenWriteOnly)
+	if err != nil {
+		return err
+	}
+	err = h.fd.Truncate(int64(req.Size))
+	if rerr := h.Release(ctx, nil); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Fsync flushes the file up to the root.
+func (fi *FileNode) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	h := fi.fs.writer(fi.fi)
+	if h == nil {
+		// the file itself has no writes to flush
+		return fuseErr(fi.fs.root.Flush())
+	}
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return fuseErr(h.fd.Flush())
+}
+
+// File is an open mfs file. Its requests are served one at a time, as
+// reads and writes seek its descriptor.
+type File struct {
+	fs    *FileSystem
+	fi    *mfs.File
+	write bool
+
+	lk sync.Mutex
+	fd mfs.FileDescriptor
+}
+
+// Read reads from the file at the offset requested.
+func (h *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if _, err := h.fd.Seek(req.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := h.fd.CtxReadFull(ctx, resp.Data[:req.Size])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	resp.Data = resp.Data[:n]
+	return err
+}
+
+// Write writes to the file at the offset requested.
+func (h *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	n, err := h.fd.WriteAt(req.Data, req.Offset)
+	resp.Size = n
+	return fuseErr(err)
+}
+
+// Flush flushes the writes to the file to its directory, when a descriptor
+// of it is closed.
+func (h *File) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	if !h.write {
+		return nil
+	}
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return fuseErr(h.fd.Sync())
+}
+
+// Release closes the file once all its descriptors are closed.
+func (h *File) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	defer h.fs.release(h.fi, h.write)
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return fuseErr(h.fd.Close())
+}
+
+// mfsFlags returns the mfs flags of open flags.
+func mfsFlags(flags fuse.OpenFlags) (int, error) {
+	switch {
+	case flags.IsReadOnly():
+		return mfs.OpenReadOnly, nil
+	case flags.IsWriteOnly():
+		return mfs.OpenWriteOnly, nil
+	case flags.IsReadWrite():
+		return mfs.OpenReadWrite, nil
+	default:
+		return 0, errors.New("unsupported open flags")
+	}
+}
+
+// to check that the nodes implement the interfaces we want
+var (
+	_ fs.FS          = (*FileSystem)(nil)
+	_ fs.FSDestroyer = (*FileSystem)(nil)
+
+	_ fs.HandleReadDirAller = (*Directory)(nil)
+	_ fs.NodeStringLookuper = (*Directory)(nil)
+	_ fs.NodeMkdirer        = (*Directory)(nil)
+	_ fs.NodeCreater        = (*Directory)(nil)
+	_ fs.NodeSymlinker      = (*Directory)(nil)
+	_ fs.NodeRemover        = (*Directory)(nil)
+	_ fs.NodeRenamer        = (*Directory)(nil)
+	_ fs.NodeSetattrer      = (*Directory)(nil)
+
+	_ fs.NodeOpener    = (*FileNode)(nil)
+	_ fs.NodeFsyncer   = (*FileNode)(nil)
+	_ fs.NodeSetattrer = (*FileNode)(nil)
+
+	_ fs.HandleReader   = (*File)(nil)
+	_ fs.HandleWriter   = (*File)(nil)
+	_ fs.HandleFlusher  = (*File)(nil)
+	_ fs.HandleReleaser = (*File)(nil)
+)
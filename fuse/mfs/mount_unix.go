@@ -0,0 +1,27 @@
+// +build linux darwin freebsd netbsd openbsd
+// +build !nofuse
+
+package mfs
+
+import (
+	"errors"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mount "github.com/ipfs/go-ipfs/fuse/mount"
+)
+
+// Mount mounts the mfs root of ipfs at mountpoint, read-write, and returns
+// a mount.Mount instance.
+func Mount(ipfs *core.IpfsNode, mountpoint string) (mount.Mount, error) {
+	if ipfs.FilesRoot == nil {
+		return nil, errors.New("node has no mfs root")
+	}
+
+	cfg, err := ipfs.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := NewFileSystem(ipfs.FilesRoot)
+	return mount.NewMount(ipfs.Process(), fsys, mountpoint, cfg.Mounts.FuseAllowOther)
+}
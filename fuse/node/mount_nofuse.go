@@ -12,3 +12,7 @@ import (
 func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
 	return errors.New("not compiled in")
 }
+
+func MountMfs(node *core.IpfsNode, mfsdir string) error {
+	return errors.New("not compiled in")
+}
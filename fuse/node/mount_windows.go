@@ -9,3 +9,7 @@ func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
 	// currently a no-op, but we don't want to return an error
 	return nil
 }
+
+func MountMfs(node *core.IpfsNode, mfsdir string) error {
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
 
@@ -137,6 +138,16 @@ func (s *Node) Attr(ctx context.Context, a *fuse.Attr) error {
 	default:
 		return fmt.Errorf("Invalid data type - %s", s.cached.GetType())
 	}
+
+	// use the attributes recorded when the node was added, if any, without
+	// write permissions as the mount is read only
+	attrs := ft.AttrsFromPB(s.cached)
+	if attrs.Mode != 0 && s.cached.GetType() != ftpb.Data_Symlink {
+		a.Mode = a.Mode&os.ModeType | attrs.Mode&^0222
+	}
+	if !attrs.ModTime.IsZero() {
+		a.Mtime = attrs.ModTime
+	}
 	return nil
 }
 
@@ -247,18 +258,15 @@ func (s *Node) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 	lm["req_size"] = req.Size
 	defer log.EventBegin(ctx, "fuseRead", lm).Done()
 
-	r, err := uio.NewDagReader(ctx, s.Nd, s.Ipfs.DAG)
-	if err != nil {
-		return err
-	}
-	o, err := r.Seek(req.Offset, io.SeekStart)
-	lm["res_offset"] = o
+	r, err := uio.NewDagReader(ctx, s.Nd, s.Ipfs.DAG, uio.WithFetchAhead(s.Ipfs.FetchAhead))
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 
+	// only fetch the blocks holding the range read
 	buf := resp.Data[:min(req.Size, int(int64(r.Size())-req.Offset))]
-	n, err := io.ReadFull(r, buf)
+	n, err := r.ReadAt(buf, req.Offset)
 	if err != nil && err != io.EOF {
 		return err
 	}
@@ -0,0 +1,345 @@
+// Package holepunch upgrades connections through relays to direct ones, for
+// peers behind NATs.
+//
+// When two peers get connected through a relay only, the one with the lower
+// ID starts a hole punch: the peers exchange their addresses through the
+// relay and measure its round trip time, then dial each other's addresses at
+// the same time, so that the packets of each dial go through the mapping the
+// NAT of the other peer opened for its own. Once a direct connection is up,
+// the relayed one is closed. If the direct dials fail, the peers keep the
+// relayed connection, and don't try again before retryInterval.
+//
+// As the network reuses any open connection to a peer, the direct dials need
+// a network implementing DirectDialer; on others, no hole punch is started.
+package holepunch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("holepunch")
+
+// ProtocolID is the protocol hole punches are coordinated with.
+const ProtocolID protocol.ID = "/ipfs/holepunch/1.0.0"
+
+const (
+	msgConnect = "connect"
+	msgSync    = "sync"
+
+	// maxAttempts bounds the hole punches run at once.
+	maxAttempts = 8
+)
+
+var (
+	// exchangeTimeout bounds the exchange of the addresses.
+	exchangeTimeout = 10 * time.Second
+
+	// dialTimeout bounds the direct dials.
+	dialTimeout = 15 * time.Second
+
+	// retryInterval is the minimum interval between two hole punches with a
+	// peer.
+	retryInterval = 10 * time.Minute
+)
+
+var errNoAddrs = errors.New("no direct addresses")
+
+// DirectDialer is implemented by networks which can open a connection to a
+// peer on the given addresses while other connections to it are open.
+type DirectDialer interface {
+	DialPeerAddrs(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) (inet.Conn, error)
+}
+
+// message is exchanged to coordinate a hole punch.
+type message struct {
+	Type  string
+	Addrs []string `json:",omitempty"`
+}
+
+// Stats are the hole punches of the node.
+type Stats struct {
+	Attempts  int
+	Successes int
+
+	// LastError is the error of the last hole punch which failed.
+	LastError string `json:",omitempty"`
+}
+
+// Service runs hole punches over the relayed connections of a host.
+type Service struct {
+	host   host.Host
+	dialer DirectDialer
+
+	lk      sync.Mutex
+	started bool
+	tried   map[peer.ID]time.Time
+	running map[peer.ID]struct{}
+	stats   Stats
+}
+
+// New returns a hole punching service for h. Call Start to start punching.
+func New(h host.Host) *Service {
+	d, _ := h.Network().(DirectDialer)
+	return &Service{
+		host:    h,
+		dialer:  d,
+		tried:   make(map[peer.ID]time.Time),
+		running: make(map[peer.ID]struct{}),
+	}
+}
+
+// Start answers the hole punches of other peers, and starts hole punches
+// over new relayed connections.
+func (s *Service) Start() {
+	s.lk.Lock()
+	if s.started {
+		s.lk.Unlock()
+		return
+	}
+	s.started = true
+	s.lk.Unlock()
+
+	if s.dialer == nil {
+		log.Warning("the network can't dial connected peers directly, no hole punches will be run")
+	}
+	s.host.SetStreamHandler(ProtocolID, s.handleStream)
+	s.host.Network().Notify((*notifee)(s))
+}
+
+// Stop stops hole punching. Running hole punches finish.
+func (s *Service) Stop() {
+	s.lk.Lock()
+	if !s.started {
+		s.lk.Unlock()
+		return
+	}
+	s.started = false
+	s.lk.Unlock()
+
+	s.host.RemoveStreamHandler(ProtocolID)
+	s.host.Network().StopNotify((*notifee)(s))
+}
+
+// Stats returns the hole punches run so far.
+func (s *Service) Stats() Stats {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.stats
+}
+
+// begin records a hole punch with p, unless one is running, was tried
+// recently, or too many are running.
+func (s *Service) begin(p peer.ID) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if !s.started || s.dialer == nil || len(s.running) >= maxAttempts {
+		return false
+	}
+	if _, ok := s.running[p]; ok {
+		return false
+	}
+	if t, ok := s.tried[p]; ok && time.Since(t) < retryInterval {
+		return false
+	}
+	for q, t := range s.tried {
+		if time.Since(t) >= retryInterval {
+			delete(s.tried, q)
+		}
+	}
+	s.running[p] = struct{}{}
+	s.tried[p] = time.Now()
+	return true
+}
+
+func (s *Service) end(p peer.ID, err error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.running, p)
+	s.stats.Attempts++
+	if err != nil {
+		s.stats.LastError = p.Pretty() + ": " + err.Error()
+		return
+	}
+	s.stats.Successes++
+}
+
+// punch starts a hole punch with p.
+func (s *Service) punch(p peer.ID) {
+	if !s.begin(p) {
+		return
+	}
+	err := s.initiate(p)
+	if err != nil {
+		log.Debugf("hole punch with %s: %s", p, err)
+	} else {
+		log.Debugf("hole punch with %s succeeded", p)
+	}
+	s.end(p, err)
+}
+
+func (s *Service) initiate(p peer.ID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exchangeTimeout)
+	defer cancel()
+
+	st, err := s.host.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return err
+	}
+	st.SetDeadline(time.Now().Add(exchangeTimeout))
+	enc := json.NewEncoder(st)
+	dec := json.NewDecoder(st)
+
+	start := time.Now()
+	if err := enc.Encode(message{Type: msgConnect, Addrs: s.directAddrs()}); err != nil {
+		st.Reset()
+		return err
+	}
+	var reply message
+	if err := dec.Decode(&reply); err != nil {
+		st.Reset()
+		return err
+	}
+	rtt := time.Since(start)
+	if reply.Type != msgConnect {
+		st.Reset()
+		return errors.New("unexpected message " + reply.Type)
+	}
+	if err := enc.Encode(message{Type: msgSync}); err != nil {
+		st.Reset()
+		return err
+	}
+	st.Close()
+
+	// the sync reaches the other peer in half a round trip, both peers dial
+	// at the same time
+	time.Sleep(rtt / 2)
+	return s.dial(p, reply.Addrs)
+}
+
+func (s *Service) handleStream(st inet.Stream) {
+	p := st.Conn().RemotePeer()
+	if !s.begin(p) {
+		st.Reset()
+		return
+	}
+
+	err := func() error {
+		st.SetDeadline(time.Now().Add(exchangeTimeout))
+		enc := json.NewEncoder(st)
+		dec := json.NewDecoder(st)
+
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			st.Reset()
+			return err
+		}
+		if msg.Type != msgConnect {
+			st.Reset()
+			return errors.New("unexpected message " + msg.Type)
+		}
+		if err := enc.Encode(message{Type: msgConnect, Addrs: s.directAddrs()}); err != nil {
+			st.Reset()
+			return err
+		}
+		var next message
+		if err := dec.Decode(&next); err != nil {
+			st.Reset()
+			return err
+		}
+		if next.Type != msgSync {
+			st.Reset()
+			return errors.New("unexpected message " + next.Type)
+		}
+		st.Close()
+		return s.dial(p, msg.Addrs)
+	}()
+	if err != nil {
+		log.Debugf("hole punch from %s: %s", p, err)
+	}
+	s.end(p, err)
+}
+
+// directAddrs returns the addresses of the node which don't go through
+// relays.
+func (s *Service) directAddrs() []string {
+	var out []string
+	for _, a := range s.host.Addrs() {
+		if !isRelayed(a) {
+			out = append(out, a.String())
+		}
+	}
+	return out
+}
+
+// dial connects to p on addrs, and closes the relayed connections to p once
+// the direct one is up. If the dial fails, they stay open.
+func (s *Service) dial(p peer.ID, addrs []string) error {
+	var direct []ma.Multiaddr
+	for _, a := range addrs {
+		maddr, err := ma.NewMultiaddr(a)
+		if err != nil || isRelayed(maddr) {
+			continue
+		}
+		direct = append(direct, maddr)
+	}
+	if len(direct) == 0 {
+		return errNoAddrs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if _, err := s.dialer.DialPeerAddrs(ctx, p, direct); err != nil {
+		return err
+	}
+
+	for _, c := range s.host.Network().ConnsToPeer(p) {
+		if isRelayed(c.RemoteMultiaddr()) {
+			c.Close()
+		}
+	}
+	return nil
+}
+
+func isRelayed(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(circuit.P_CIRCUIT)
+	return err == nil
+}
+
+// notifee starts hole punches over the new relayed connections.
+type notifee Service
+
+func (nn *notifee) Connected(n inet.Network, c inet.Conn) {
+	if !isRelayed(c.RemoteMultiaddr()) {
+		return
+	}
+	s := (*Service)(nn)
+	p := c.RemotePeer()
+	// one of the peers starts
+	if s.host.ID() > p {
+		return
+	}
+	for _, c := range n.ConnsToPeer(p) {
+		if !isRelayed(c.RemoteMultiaddr()) {
+			return
+		}
+	}
+	go s.punch(p)
+}
+
+func (nn *notifee) Disconnected(inet.Network, inet.Conn)   {}
+func (nn *notifee) Listen(inet.Network, ma.Multiaddr)      {}
+func (nn *notifee) ListenClose(inet.Network, ma.Multiaddr) {}
+func (nn *notifee) OpenedStream(inet.Network, inet.Stream) {}
+func (nn *notifee) ClosedStream(inet.Network, inet.Stream) {}
@@ -0,0 +1,92 @@
+package holepunch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	testutil "github.com/libp2p/go-testutil"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type testDialer struct {
+	addrs []ma.Multiaddr
+	err   error
+}
+
+func (d *testDialer) DialPeerAddrs(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) (inet.Conn, error) {
+	d.addrs = addrs
+	return nil, d.err
+}
+
+func TestDial(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	a, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the connections to b go through its only address, a relayed one
+	relayed, err := ma.NewMultiaddr("/ip4/1.2.3.4/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := mn.AddPeer(sk, relayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mn.ConnectPeers(a.ID(), b.ID()); err != nil {
+		t.Fatal(err)
+	}
+	conns := a.Network().ConnsToPeer(b.ID())
+	if len(conns) != 1 || !isRelayed(conns[0].RemoteMultiaddr()) {
+		t.Fatalf("expected a relayed connection, got %v", conns)
+	}
+
+	s := New(a)
+	if s.dialer != nil {
+		t.Fatal("mocknet can't dial connected peers directly")
+	}
+	d := &testDialer{err: errors.New("unreachable")}
+	s.dialer = d
+
+	direct := "/ip4/5.6.7.8/tcp/4001"
+	if err := s.dial(b.ID(), []string{relayed.String(), direct}); err != d.err {
+		t.Fatalf("expected %s, got %v", d.err, err)
+	}
+	if len(d.addrs) != 1 || d.addrs[0].String() != direct {
+		t.Fatalf("expected to dial %s only, dialed %s", direct, d.addrs)
+	}
+	if len(a.Network().ConnsToPeer(b.ID())) != 1 {
+		t.Fatal("the relayed connection was closed after a failed dial")
+	}
+	for _, k := range a.Peerstore().Addrs(b.ID()) {
+		if k.String() == direct {
+			t.Fatal("the direct address was added to the peerstore")
+		}
+	}
+
+	d.err = nil
+	if err := s.dial(b.ID(), []string{direct}); err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); len(a.Network().ConnsToPeer(b.ID())) != 0; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("the relayed connection wasn't closed after a direct dial")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
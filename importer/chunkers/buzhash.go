@@ -0,0 +1,116 @@
+package chunkers
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+)
+
+// buzhashWindow is the number of bytes the rolling hash of Buzhash covers.
+const buzhashWindow = 32
+
+// buzhashTable maps bytes to the random values the hash is made of. It is
+// generated from a fixed seed: changing it would change the chunks of every
+// file chunked with buzhash.
+var buzhashTable [256]uint32
+
+func init() {
+	// splitmix64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range buzhashTable {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		buzhashTable[i] = uint32(z ^ (z >> 31))
+	}
+}
+
+// Buzhash is a content defined chunker cutting chunks where the cyclic
+// polynomial (buzhash) rolling hash of the last bytes read matches a mask.
+// It is much cheaper to compute than rabin fingerprints.
+type Buzhash struct {
+	r   io.Reader
+	buf []byte
+	n   int
+	err error
+
+	min  int
+	mask uint32
+}
+
+var _ chunker.Splitter = (*Buzhash)(nil)
+
+// NewBuzhash creates a Buzhash chunker of r, producing chunks of min to max
+// bytes, and of avg bytes on average.
+func NewBuzhash(r io.Reader, min, avg, max int) (*Buzhash, error) {
+	if min < buzhashWindow {
+		return nil, fmt.Errorf("buzhash chunker min size must be at least %d", buzhashWindow)
+	}
+	if min >= avg || avg >= max {
+		return nil, fmt.Errorf("buzhash chunker sizes must be min < avg < max")
+	}
+	// cuts happen every 2^k bytes on average after the first min bytes
+	k := uint(bits.Len(uint(avg-min))) - 1
+	return &Buzhash{
+		r:    r,
+		buf:  make([]byte, max),
+		min:  min,
+		mask: 1<<k - 1,
+	}, nil
+}
+
+// Reader returns the io.Reader chunked.
+func (b *Buzhash) Reader() io.Reader {
+	return b.r
+}
+
+// NextBytes returns the next chunk, or io.EOF when all of the data was
+// returned.
+func (b *Buzhash) NextBytes() ([]byte, error) {
+	if b.err == nil && b.n < len(b.buf) {
+		var n int
+		n, b.err = io.ReadFull(b.r, b.buf[b.n:])
+		b.n += n
+		if b.err == io.ErrUnexpectedEOF {
+			b.err = io.EOF
+		}
+	}
+	if b.n == 0 {
+		if b.err == nil {
+			b.err = io.EOF
+		}
+		return nil, b.err
+	}
+	if b.err != nil && b.err != io.EOF {
+		return nil, b.err
+	}
+
+	cut := b.cut()
+	chunk := make([]byte, cut)
+	copy(chunk, b.buf[:cut])
+	b.n = copy(b.buf, b.buf[cut:b.n])
+	return chunk, nil
+}
+
+// cut returns the length of the next chunk in the buffer.
+func (b *Buzhash) cut() int {
+	if b.n <= b.min {
+		return b.n
+	}
+
+	var h uint32
+	for _, c := range b.buf[b.min-buzhashWindow : b.min] {
+		h = bits.RotateLeft32(h, 1) ^ buzhashTable[c]
+	}
+	for i := b.min; i < b.n; i++ {
+		if h&b.mask == 0 {
+			return i
+		}
+		out := b.buf[i-buzhashWindow]
+		h = bits.RotateLeft32(h, 1) ^ bits.RotateLeft32(buzhashTable[out], buzhashWindow) ^ buzhashTable[b.buf[i]]
+	}
+	return b.n
+}
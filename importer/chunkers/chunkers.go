@@ -0,0 +1,182 @@
+// Package chunkers is the registry of the chunkers the importer splits files
+// with, selected by strings like "size-262144" or "rabin-16384-65536-131072".
+//
+// The chunks of a file, and so the cid it is added as, only depend on the
+// chunker string: nodes adding the same file with the same chunker string
+// get the same cid. The default chunker, used for the empty string, is
+// "size-262144", and won't change without a new chunker string for the old
+// behaviour.
+package chunkers
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+)
+
+// DefaultChunker is the chunker used when none is given.
+const DefaultChunker = "size-262144"
+
+// MaxChunkSize is the size of the largest chunks chunkers may produce. Larger
+// blocks can't be exchanged reliably with other nodes.
+const MaxChunkSize = 1 << 20
+
+// Constructor creates a splitter reading r, configured by the arguments
+// following the name of the chunker in the chunker string, if any.
+type Constructor func(r io.Reader, args []uint64) (chunker.Splitter, error)
+
+var (
+	lk           sync.RWMutex
+	constructors = make(map[string]Constructor)
+	presets      = make(map[string]string)
+)
+
+// Register registers the chunker called name. Names can't contain '-', which
+// separates the arguments in chunker strings.
+func Register(name string, c Constructor) error {
+	if name == "" || strings.Contains(name, "-") {
+		return fmt.Errorf("invalid chunker name %q", name)
+	}
+
+	lk.Lock()
+	defer lk.Unlock()
+	if _, ok := constructors[name]; ok {
+		return fmt.Errorf("chunker %q is already registered", name)
+	}
+	if _, ok := presets[name]; ok {
+		return fmt.Errorf("chunker %q is already registered as a preset", name)
+	}
+	constructors[name] = c
+	return nil
+}
+
+// RegisterPreset registers name as an alias of the chunker string spec.
+func RegisterPreset(name, spec string) error {
+	if name == "" || strings.Contains(name, "-") {
+		return fmt.Errorf("invalid chunker preset name %q", name)
+	}
+
+	lk.Lock()
+	defer lk.Unlock()
+	if _, ok := constructors[name]; ok {
+		return fmt.Errorf("chunker preset %q is already registered as a chunker", name)
+	}
+	if _, ok := presets[name]; ok {
+		return fmt.Errorf("chunker preset %q is already registered", name)
+	}
+	presets[name] = spec
+	return nil
+}
+
+// Names returns the sorted names of the chunkers and presets registered.
+func Names() []string {
+	lk.RLock()
+	defer lk.RUnlock()
+
+	names := make([]string, 0, len(constructors)+len(presets))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FromString returns the splitter of r described by spec: the name of a
+// chunker followed by its arguments, separated by '-', or the name of a
+// preset. The empty string selects DefaultChunker.
+func FromString(r io.Reader, spec string) (chunker.Splitter, error) {
+	if spec == "" {
+		spec = DefaultChunker
+	}
+
+	lk.RLock()
+	if p, ok := presets[spec]; ok {
+		spec = p
+	}
+	parts := strings.Split(spec, "-")
+	c, ok := constructors[parts[0]]
+	lk.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unrecognized chunker option: %s", spec)
+	}
+
+	args := make([]uint64, len(parts)-1)
+	for i, p := range parts[1:] {
+		v, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %q of chunker %s", p, parts[0])
+		}
+		args[i] = v
+	}
+	return c(r, args)
+}
+
+// minMaxArgs returns the min, avg and max chunk sizes of args: either none,
+// giving the defaults for def, only the average, or all three.
+func minMaxArgs(name string, args []uint64, def uint64) (min, avg, max uint64, err error) {
+	switch len(args) {
+	case 0:
+		avg = def
+	case 1:
+		avg = args[0]
+	case 3:
+		min, avg, max = args[0], args[1], args[2]
+	default:
+		return 0, 0, 0, fmt.Errorf("%s chunker takes [avg] or [min]-[avg]-[max] arguments", name)
+	}
+	if len(args) < 3 {
+		min, max = avg/3, avg+avg/2
+	}
+	if min >= avg || avg >= max {
+		return 0, 0, 0, fmt.Errorf("%s chunker sizes must be min < avg < max", name)
+	}
+	if max > MaxChunkSize {
+		return 0, 0, 0, fmt.Errorf("%s chunker max size must be at most %d", name, MaxChunkSize)
+	}
+	return min, avg, max, nil
+}
+
+func init() {
+	Register("size", func(r io.Reader, args []uint64) (chunker.Splitter, error) {
+		size := uint64(chunker.DefaultBlockSize)
+		switch len(args) {
+		case 0:
+		case 1:
+			size = args[0]
+		default:
+			return nil, fmt.Errorf("size chunker takes a [size] argument")
+		}
+		if size == 0 || size > MaxChunkSize {
+			return nil, fmt.Errorf("size chunker size must be between 1 and %d", MaxChunkSize)
+		}
+		return chunker.NewSizeSplitter(r, int64(size)), nil
+	})
+
+	Register("rabin", func(r io.Reader, args []uint64) (chunker.Splitter, error) {
+		min, avg, max, err := minMaxArgs("rabin", args, uint64(chunker.DefaultBlockSize))
+		if err != nil {
+			return nil, err
+		}
+		return chunker.NewRabinMinMax(r, min, avg, max), nil
+	})
+
+	Register("buzhash", func(r io.Reader, args []uint64) (chunker.Splitter, error) {
+		min, avg, max, err := minMaxArgs("buzhash", args, uint64(chunker.DefaultBlockSize))
+		if err != nil {
+			return nil, err
+		}
+		return NewBuzhash(r, int(min), int(avg), int(max))
+	})
+
+	RegisterPreset("default", DefaultChunker)
+	RegisterPreset("small", "size-65536")
+	RegisterPreset("large", "size-1048576")
+}
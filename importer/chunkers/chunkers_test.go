@@ -0,0 +1,109 @@
+package chunkers
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+)
+
+func randBuf(t *testing.T, size int) []byte {
+	buf := make([]byte, size)
+	if _, err := rand.New(rand.NewSource(1)).Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func chunks(t *testing.T, spl chunker.Splitter) [][]byte {
+	var out [][]byte
+	for {
+		b, err := spl.NextBytes()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b)
+	}
+}
+
+func TestFromString(t *testing.T) {
+	good := []string{"", "default", "small", "large", "size-1024", "rabin", "rabin-4096", "rabin-1024-4096-8192", "buzhash", "buzhash-1024-4096-8192"}
+	for _, s := range good {
+		if _, err := FromString(bytes.NewReader(nil), s); err != nil {
+			t.Errorf("%q: %s", s, err)
+		}
+	}
+
+	bad := []string{"foo", "size-", "size-0", "size-1-2", "size-99999999", "rabin-1-2", "rabin-3-2-1", "buzhash-8-16-32", "buzhash-a-b-c"}
+	for _, s := range bad {
+		if _, err := FromString(bytes.NewReader(nil), s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register("size", nil); err == nil {
+		t.Fatal("expected registering a chunker twice to fail")
+	}
+	if err := Register("a-b", nil); err == nil {
+		t.Fatal("expected registering a chunker named with a '-' to fail")
+	}
+	if err := RegisterPreset("tiny", "size-1024"); err != nil {
+		t.Fatal(err)
+	}
+	spl, err := FromString(bytes.NewReader(randBuf(t, 4096)), "tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(chunks(t, spl)); n != 4 {
+		t.Fatalf("expected 4 chunks, got %d", n)
+	}
+}
+
+func TestBuzhash(t *testing.T) {
+	data := randBuf(t, 1<<20)
+
+	spl, err := NewBuzhash(bytes.NewReader(data), 1024, 4096, 16384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := chunks(t, spl)
+	if !bytes.Equal(bytes.Join(cs, nil), data) {
+		t.Fatal("chunks don't add up to the data")
+	}
+	for i, c := range cs {
+		if len(c) > 16384 || (len(c) < 1024 && i != len(cs)-1) {
+			t.Fatalf("chunk %d has %d bytes", i, len(c))
+		}
+	}
+	if avg := len(data) / len(cs); avg < 2048 || avg > 8192 {
+		t.Fatalf("average chunk size %d is far from 4096", avg)
+	}
+
+	// the chunks only depend on the content: inserting data at the start
+	// only changes the first chunks
+	spl, err = NewBuzhash(bytes.NewReader(append([]byte("prefix"), data...)), 1024, 4096, 16384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shifted := chunks(t, spl)
+	same := make(map[string]bool)
+	for _, c := range cs {
+		same[string(c)] = true
+	}
+	var n int
+	for _, c := range shifted {
+		if same[string(c)] {
+			n++
+		}
+	}
+	if n < len(cs)-3 {
+		t.Fatalf("only %d of %d chunks are the same after inserting data", n, len(cs))
+	}
+}
@@ -23,10 +23,13 @@ type DagBuilderHelper struct {
 	rawLeaves bool
 	nextData  []byte // the next item to return.
 	maxlinks  int
-	batch     *ipld.Batch
+	builder   *dag.Builder
 	fullPath  string
 	stat      os.FileInfo
 	prefix    *cid.Prefix
+	layout    string
+	attrs     ft.Attrs
+	inline    int
 }
 
 // DagBuilderParams wraps configuration options to create a DagBuilderHelper
@@ -48,6 +51,23 @@ type DagBuilderParams struct {
 	// NoCopy signals to the chunker that it should track fileinfo for
 	// filestore adds
 	NoCopy bool
+
+	// URL the data is read from, recorded as the location of the
+	// leaves of the DAG if NoCopy is set
+	URL string
+
+	// Layout is the name of the layout building the DAG, recorded in the
+	// unixfs data of its root if set
+	Layout string
+
+	// Attrs are the filesystem attributes of the file, recorded in the
+	// unixfs data of the root of its DAG
+	Attrs ft.Attrs
+
+	// InlineLimit is the size up to which nodes are inlined in their CID
+	// with the identity hash instead of being stored, if the Prefix is
+	// CIDv1. 0 inlines no node
+	InlineLimit int
 }
 
 // New generates a new DagBuilderHelper from the given params and a given
@@ -59,12 +79,18 @@ func (dbp *DagBuilderParams) New(spl chunker.Splitter) *DagBuilderHelper {
 		rawLeaves: dbp.RawLeaves,
 		prefix:    dbp.Prefix,
 		maxlinks:  dbp.Maxlinks,
-		batch:     ipld.NewBatch(context.TODO(), dbp.Dagserv),
+		layout:    dbp.Layout,
+		attrs:     dbp.Attrs,
+		inline:    dbp.InlineLimit,
+		builder:   dag.NewBuilder(context.TODO(), dbp.Dagserv),
 	}
 	if fi, ok := spl.Reader().(files.FileInfo); dbp.NoCopy && ok {
 		db.fullPath = fi.AbsPath()
 		db.stat = fi.Stat()
 	}
+	if dbp.URL != "" && dbp.NoCopy {
+		db.fullPath = dbp.URL
+	}
 	return db
 }
 
@@ -198,14 +224,29 @@ func (db *DagBuilderHelper) SetPosInfo(node *UnixfsNode, offset uint64) {
 	}
 }
 
-// Add sends a node to the DAGService, and returns it.
+// Add sends a node to the DAGService as a root, and returns it. It is
+// written by Close, along with its descendants.
 func (db *DagBuilderHelper) Add(node *UnixfsNode) (ipld.Node, error) {
+	if node.raw && (db.layout != "" || !db.attrs.IsZero()) {
+		// raw nodes can't record anything, wrap them
+		root := db.NewUnixfsNode()
+		if err := root.AddChild(node, db); err != nil {
+			return nil, err
+		}
+		node = root
+	}
+	if !node.raw {
+		node.ufmt.Layout = db.layout
+		node.ufmt.Attrs = db.attrs
+	}
+
 	dn, err := node.GetDagNode()
 	if err != nil {
 		return nil, err
 	}
+	dn = dag.Inline(dn, db.inline)
 
-	err = db.dserv.Add(context.TODO(), dn)
+	err = db.builder.AddRoot(dn)
 	if err != nil {
 		return nil, err
 	}
@@ -219,9 +260,10 @@ func (db *DagBuilderHelper) Maxlinks() int {
 	return db.maxlinks
 }
 
-// Close has the DAGServce perform a batch Commit operation.
+// Close writes the nodes buffered by the helper to the DAGService.
 // It should be called at the end of the building process to make
 // sure all data is persisted.
 func (db *DagBuilderHelper) Close() error {
-	return db.batch.Commit()
+	_, err := db.builder.Commit()
+	return err
 }
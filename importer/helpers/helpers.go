@@ -106,6 +106,7 @@ func (n *UnixfsNode) AddChild(child *UnixfsNode, db *DagBuilderHelper) error {
 	if err != nil {
 		return err
 	}
+	childnode = dag.Inline(childnode, db.inline)
 
 	// Add a link to this node without storing a reference to the memory
 	// This way, we avoid nodes building up and consuming all of our RAM
@@ -114,7 +115,7 @@ func (n *UnixfsNode) AddChild(child *UnixfsNode, db *DagBuilderHelper) error {
 		return err
 	}
 
-	err = db.batch.Add(childnode)
+	err = db.builder.Add(childnode)
 
 	return err
 }
@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"testing"
 
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+	dag "github.com/ipfs/go-ipfs/merkledag"
 	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
@@ -61,6 +63,50 @@ func TestBalancedDag(t *testing.T) {
 	}
 }
 
+func TestInlineDag(t *testing.T) {
+	ds := mdtest.Mock()
+	buf := make([]byte, 100)
+	u.NewTimeSeededRand().Read(buf)
+
+	prefix := dag.V1CidPrefix()
+	params := h.DagBuilderParams{
+		Dagserv:     ds,
+		Maxlinks:    h.DefaultLinksPerBlock,
+		RawLeaves:   true,
+		Prefix:      &prefix,
+		InlineLimit: 64,
+	}
+	nd, err := BuildDag(params, chunker.NewSizeSplitter(bytes.NewReader(buf), 40), "balanced")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the leaves are small enough to be inlined, not the root linking them
+	if dag.IsInlined(nd.Cid()) {
+		t.Fatal("expected the root not to be inlined")
+	}
+	if len(nd.Links()) != 3 {
+		t.Fatalf("expected 3 leaves, got %d", len(nd.Links()))
+	}
+	for _, l := range nd.Links() {
+		if !dag.IsInlined(l.Cid) {
+			t.Fatalf("expected leaf %s to be inlined", l.Cid)
+		}
+	}
+
+	dr, err := uio.NewDagReader(context.Background(), nd, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, buf) {
+		t.Fatal("bad read")
+	}
+}
+
 func BenchmarkBalancedReadSmallBlock(b *testing.B) {
 	b.StopTimer()
 	nbytes := int64(10000000)
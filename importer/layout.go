@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	bal "github.com/ipfs/go-ipfs/importer/balanced"
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+	trickle "github.com/ipfs/go-ipfs/importer/trickle"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// DefaultLayout is the layout used when none is given.
+const DefaultLayout = "balanced"
+
+// Layout builds the DAG of a file from the chunks of db, and returns its
+// root. It must write the root with db.Add, and the DAG with db.Close.
+type Layout func(db *h.DagBuilderHelper) (ipld.Node, error)
+
+var (
+	layoutsLk sync.RWMutex
+	layouts   = map[string]Layout{
+		"balanced": bal.Layout,
+		"trickle":  trickle.Layout,
+	}
+)
+
+// builtinLayouts are the layouts whose name isn't recorded in the DAGs they
+// build, so that files keep the cids they had before layouts were recorded.
+var builtinLayouts = map[string]bool{
+	"balanced": true,
+	"trickle":  true,
+}
+
+// RegisterLayout registers the layout called name, which can then be used to
+// build DAGs with BuildDag.
+func RegisterLayout(name string, l Layout) error {
+	if name == "" {
+		return fmt.Errorf("invalid layout name %q", name)
+	}
+
+	layoutsLk.Lock()
+	defer layoutsLk.Unlock()
+	if _, ok := layouts[name]; ok {
+		return fmt.Errorf("layout %q is already registered", name)
+	}
+	layouts[name] = l
+	return nil
+}
+
+// LayoutNames returns the sorted names of the layouts registered.
+func LayoutNames() []string {
+	layoutsLk.RLock()
+	defer layoutsLk.RUnlock()
+
+	names := make([]string, 0, len(layouts))
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildDag builds the DAG of the data of spl with the layout called name, or
+// DefaultLayout if it is empty. The name of the layout is recorded in the
+// unixfs data of the root, unless it is balanced or trickle.
+func BuildDag(dbp h.DagBuilderParams, spl chunker.Splitter, name string) (ipld.Node, error) {
+	if name == "" {
+		name = DefaultLayout
+	}
+
+	layoutsLk.RLock()
+	l, ok := layouts[name]
+	layoutsLk.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown layout %q", name)
+	}
+
+	if !builtinLayouts[name] {
+		dbp.Layout = name
+	}
+	return l(dbp.New(spl))
+}
@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	u "github.com/ipfs/go-ipfs-util"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// flatLayout links the chunks from the root, up to the max links.
+func flatLayout(db *h.DagBuilderHelper) (ipld.Node, error) {
+	root := db.NewUnixfsNode()
+	if err := db.FillNodeLayer(root); err != nil {
+		return nil, err
+	}
+	out, err := db.Add(root)
+	if err != nil {
+		return nil, err
+	}
+	return out, db.Close()
+}
+
+func TestLayouts(t *testing.T) {
+	if err := RegisterLayout("flat", flatLayout); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterLayout("trickle", flatLayout); err == nil {
+		t.Fatal("expected registering a layout twice to fail")
+	}
+
+	buf := make([]byte, 10000)
+	u.NewTimeSeededRand().Read(buf)
+	build := func(layout string) (ipld.Node, ipld.DAGService) {
+		ds := mdtest.Mock()
+		dbp := h.DagBuilderParams{
+			Dagserv:  ds,
+			Maxlinks: 1000,
+		}
+		nd, err := BuildDag(dbp, chunker.NewSizeSplitter(bytes.NewReader(buf), 100), layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd, ds
+	}
+	layoutOf := func(nd ipld.Node) string {
+		fsn, err := ft.FSNodeFromBytes(nd.(*dag.ProtoNode).Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fsn.Layout
+	}
+
+	nd, ds := build("flat")
+	if len(nd.Links()) != 100 {
+		t.Fatalf("expected 100 links, got %d", len(nd.Links()))
+	}
+	if l := layoutOf(nd); l != "flat" {
+		t.Fatalf("expected the layout to be recorded, got %q", l)
+	}
+	dr, err := uio.NewDagReader(context.Background(), nd, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, buf) {
+		t.Fatal("bad read")
+	}
+
+	// the built-in layouts aren't recorded, and keep their cids
+	nd, _ = build("")
+	if l := layoutOf(nd); l != "" {
+		t.Fatalf("expected no layout to be recorded, got %q", l)
+	}
+	exp, err := BuildDagFromReader(mdtest.Mock(), chunker.NewSizeSplitter(bytes.NewReader(buf), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(exp.Cid()) {
+		t.Fatal("expected the default layout to be balanced")
+	}
+
+	if _, err := BuildDag(h.DagBuilderParams{Dagserv: mdtest.Mock()}, chunker.DefaultSplitter(bytes.NewReader(buf)), "foo"); err == nil {
+		t.Fatal("expected an unknown layout to fail")
+	}
+}
@@ -0,0 +1,96 @@
+package merkledag
+
+import (
+	"context"
+	"errors"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ErrBuilderCommitted is returned when adding nodes to a committed Builder.
+var ErrBuilderCommitted = errors.New("dag builder was committed already")
+
+// These limits bound the nodes a Builder buffers before writing them.
+var (
+	BuilderMaxSize  = 8 << 20
+	BuilderMaxNodes = 128
+)
+
+// Builder buffers the nodes of DAGs being created, and writes them to a
+// DAGService in batches with AddMany. A node added several times, like a
+// chunk repeated in a file, is only encoded and written once.
+//
+// A Builder isn't safe for concurrent use.
+type Builder struct {
+	ctx context.Context
+	ds  ipld.DAGService
+
+	seen  map[string]struct{}
+	nodes []ipld.Node
+	size  int
+
+	roots     []*cid.Cid
+	committed bool
+}
+
+// NewBuilder creates a Builder writing to ds.
+func NewBuilder(ctx context.Context, ds ipld.DAGService) *Builder {
+	return &Builder{
+		ctx:  ctx,
+		ds:   ds,
+		seen: make(map[string]struct{}),
+	}
+}
+
+// Add buffers nd, unless it was added before. The buffered nodes are
+// written once they exceed the limits of the builder.
+func (b *Builder) Add(nd ipld.Node) error {
+	if b.committed {
+		return ErrBuilderCommitted
+	}
+
+	k := nd.Cid().KeyString()
+	if _, ok := b.seen[k]; ok {
+		return nil
+	}
+	b.seen[k] = struct{}{}
+
+	b.nodes = append(b.nodes, nd)
+	b.size += len(nd.RawData())
+	if b.size >= BuilderMaxSize || len(b.nodes) >= BuilderMaxNodes {
+		return b.flush()
+	}
+	return nil
+}
+
+// AddRoot adds nd, and records it as one of the roots returned by Commit.
+func (b *Builder) AddRoot(nd ipld.Node) error {
+	if err := b.Add(nd); err != nil {
+		return err
+	}
+	b.roots = append(b.roots, nd.Cid())
+	return nil
+}
+
+func (b *Builder) flush() error {
+	if len(b.nodes) == 0 {
+		return nil
+	}
+	err := b.ds.AddMany(b.ctx, b.nodes)
+	b.nodes = nil
+	b.size = 0
+	return err
+}
+
+// Commit writes the buffered nodes, and returns the roots added.
+func (b *Builder) Commit() ([]*cid.Cid, error) {
+	if b.committed {
+		return nil, ErrBuilderCommitted
+	}
+	b.committed = true
+	if err := b.flush(); err != nil {
+		return nil, err
+	}
+	return b.roots, nil
+}
@@ -0,0 +1,73 @@
+package merkledag_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// countingDAG counts the nodes written to it.
+type countingDAG struct {
+	ipld.DAGService
+	added int
+}
+
+func (d *countingDAG) Add(ctx context.Context, nd ipld.Node) error {
+	d.added++
+	return d.DAGService.Add(ctx, nd)
+}
+
+func (d *countingDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	d.added += len(nds)
+	return d.DAGService.AddMany(ctx, nds)
+}
+
+func TestBuilder(t *testing.T) {
+	ctx := context.Background()
+	ds := &countingDAG{DAGService: dstest.Mock()}
+
+	chunk := NodeWithData([]byte("chunk"))
+	other := NodeWithData([]byte("other"))
+	root := NodeWithData([]byte("root"))
+	for _, c := range []*ProtoNode{chunk, other, chunk} {
+		if err := root.AddNodeLink("", c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := NewBuilder(ctx, ds)
+	for _, nd := range []ipld.Node{chunk, other, chunk} {
+		if err := b.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.AddRoot(root); err != nil {
+		t.Fatal(err)
+	}
+	if ds.added != 0 {
+		t.Fatal("expected the nodes to be buffered until Commit")
+	}
+
+	roots, err := b.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(root.Cid()) {
+		t.Fatalf("expected the root to be returned, got %v", roots)
+	}
+	if ds.added != 3 {
+		t.Fatalf("expected 3 distinct nodes to be written, got %d", ds.added)
+	}
+	if err := EnumerateChildren(ctx, GetLinksWithDAG(ds), root.Cid(), cid.NewSet().Visit); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Add(chunk); err != ErrBuilderCommitted {
+		t.Fatalf("expected ErrBuilderCommitted, got %v", err)
+	}
+}
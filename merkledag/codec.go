@@ -0,0 +1,99 @@
+package merkledag
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ipldcbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Codec is an IPLD format the DAG services can decode, traverse and resolve
+// paths through the nodes of.
+type Codec struct {
+	// Name is the multicodec name of the format, like "dag-cbor".
+	Name string
+
+	// Code is the multicodec code of the format, used in the cids of its
+	// blocks.
+	Code uint64
+
+	Decode ipld.DecodeBlockFunc
+}
+
+var codecs = struct {
+	sync.RWMutex
+	byCode map[uint64]Codec
+}{byCode: make(map[uint64]Codec)}
+
+// TODO: We should move these registrations elsewhere. Really, most of the IPLD
+// functionality should go in a `go-ipld` repo but that will take a lot of work
+// and design.
+func init() {
+	for _, c := range []Codec{
+		{Name: "dag-pb", Code: cid.DagProtobuf, Decode: DecodeProtobufBlock},
+		{Name: "raw", Code: cid.Raw, Decode: DecodeRawBlock},
+		{Name: "dag-cbor", Code: cid.DagCBOR, Decode: ipldcbor.DecodeBlock},
+		{Name: "dag-json", Code: DagJSON, Decode: DecodeJSONBlock},
+	} {
+		if err := RegisterCodec(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterCodec makes the nodes of c decodable by the DAG services. It fails
+// if a codec with the same name or code is registered already.
+func RegisterCodec(c Codec) error {
+	if c.Name == "" || c.Decode == nil {
+		return fmt.Errorf("codec %d needs a name and a decoder", c.Code)
+	}
+
+	codecs.Lock()
+	defer codecs.Unlock()
+	if old, ok := codecs.byCode[c.Code]; ok {
+		return fmt.Errorf("codec %d is registered already as %q", c.Code, old.Name)
+	}
+	for _, old := range codecs.byCode {
+		if old.Name == c.Name {
+			return fmt.Errorf("codec %q is registered already with code %d", c.Name, old.Code)
+		}
+	}
+	codecs.byCode[c.Code] = c
+	ipld.Register(c.Code, c.Decode)
+	return nil
+}
+
+// CodecByName returns the registered codec with the given name.
+func CodecByName(name string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	for _, c := range codecs.byCode {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Codec{}, false
+}
+
+// CodecByCode returns the registered codec with the given multicodec code.
+func CodecByCode(code uint64) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.byCode[code]
+	return c, ok
+}
+
+// Codecs returns the registered codecs, sorted by code.
+func Codecs() []Codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	out := make([]Codec, 0, len(codecs.byCode))
+	for _, c := range codecs.byCode {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
@@ -0,0 +1,107 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipldcbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// DagJSON is the multicodec code of dag-json blocks.
+const DagJSON uint64 = 0x0129
+
+// JSONNode is a dag-json node: a JSON document whose objects of the form
+// {"/": "<cid>"} are links. It is traversed and resolved like a dag-cbor node
+// of the same content.
+type JSONNode struct {
+	*ipldcbor.Node
+
+	raw []byte
+	cid *cid.Cid
+}
+
+// NewJSONNode parses data as a dag-json node, and computes its cid with
+// prefix.
+func NewJSONNode(data []byte, prefix cid.Prefix) (*JSONNode, error) {
+	prefix.Codec = DagJSON
+	if prefix.Version == 0 {
+		prefix.Version = 1
+	}
+	c, err := prefix.Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(data, c)
+}
+
+// DecodeJSONBlock is a block decoder for dag-json nodes conforming to
+// `node.DecodeBlockFunc`.
+func DecodeJSONBlock(b blocks.Block) (ipld.Node, error) {
+	if b.Cid().Type() != DagJSON {
+		return nil, fmt.Errorf("dag-json nodes cannot be decoded from blocks of codec %d", b.Cid().Type())
+	}
+	return decodeJSON(b.RawData(), b.Cid())
+}
+
+var _ ipld.DecodeBlockFunc = DecodeJSONBlock
+
+func decodeJSON(data []byte, c *cid.Cid) (*JSONNode, error) {
+	nd, err := ipldcbor.FromJson(bytes.NewReader(data), math.MaxUint64, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONNode{Node: nd, raw: data, cid: c}, nil
+}
+
+// Cid returns the cid of the JSON encoding of the node.
+func (n *JSONNode) Cid() *cid.Cid {
+	return n.cid
+}
+
+// RawData returns the JSON encoding of the node.
+func (n *JSONNode) RawData() []byte {
+	return n.raw
+}
+
+// Size returns the length of the JSON encoding of the node.
+func (n *JSONNode) Size() (uint64, error) {
+	return uint64(len(n.raw)), nil
+}
+
+// Stat returns statistics about the node.
+func (n *JSONNode) Stat() (*ipld.NodeStat, error) {
+	return &ipld.NodeStat{
+		Hash:           n.cid.String(),
+		NumLinks:       len(n.Links()),
+		BlockSize:      len(n.raw),
+		DataSize:       len(n.raw),
+		CumulativeSize: len(n.raw),
+	}, nil
+}
+
+// Copy returns a copy of the node.
+func (n *JSONNode) Copy() ipld.Node {
+	raw := make([]byte, len(n.raw))
+	copy(raw, n.raw)
+	nd, _ := decodeJSON(raw, n.cid)
+	return nd
+}
+
+// Loggable returns a loggable representation of the node.
+func (n *JSONNode) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"node_type": "dag-json",
+		"cid":       n.cid,
+	}
+}
+
+// String returns the cid of the node.
+func (n *JSONNode) String() string {
+	return n.cid.String()
+}
+
+var _ ipld.Node = (*JSONNode)(nil)
@@ -0,0 +1,75 @@
+package merkledag_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+	path "github.com/ipfs/go-ipfs/path"
+	resolver "github.com/ipfs/go-ipfs/path/resolver"
+
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestJSONNode(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	leaf := NodeWithData([]byte("leaf"))
+	if err := ds.Add(ctx, leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := cid.Prefix{MhType: mh.SHA2_256, MhLength: -1}
+	nd, err := NewJSONNode([]byte(`{"a": {"b": {"/": "`+leaf.Cid().String()+`"}}, "c": 1}`), prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Cid().Type() != DagJSON {
+		t.Fatalf("expected a dag-json cid, got codec %d", nd.Cid().Type())
+	}
+	if err := ds.Add(ctx, nd); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ds.Get(ctx, nd.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.(*JSONNode); !ok {
+		t.Fatalf("expected a *JSONNode, got %T", got)
+	}
+	if string(got.RawData()) != string(nd.RawData()) {
+		t.Fatal("the JSON encoding wasn't kept")
+	}
+	if links := got.Links(); len(links) != 1 || !links[0].Cid.Equals(leaf.Cid()) {
+		t.Fatalf("expected a link to the leaf, got %v", links)
+	}
+
+	p, err := path.FromSegments("/ipfs/", nd.Cid().String(), "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := resolver.NewBasicResolver(ds).ResolvePath(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Cid().Equals(leaf.Cid()) {
+		t.Fatalf("expected %s to resolve to the leaf, got %s", p, res.Cid())
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	c, ok := CodecByName("dag-json")
+	if !ok || c.Code != DagJSON {
+		t.Fatal("dag-json isn't registered")
+	}
+	if err := RegisterCodec(Codec{Name: "other-json", Code: DagJSON, Decode: DecodeJSONBlock}); err == nil {
+		t.Fatal("expected registering a codec twice to fail")
+	}
+	if err := RegisterCodec(Codec{Name: "dag-json", Code: 0x300001, Decode: DecodeJSONBlock}); err == nil {
+		t.Fatal("expected registering a codec name twice to fail")
+	}
+}
@@ -0,0 +1,40 @@
+package merkledag
+
+import (
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// IsInlined reports whether c inlines the data of its node with the identity
+// hash, so the node is never stored or fetched.
+func IsInlined(c *cid.Cid) bool {
+	return c.Prefix().MhType == mh.ID
+}
+
+// Inline returns a copy of nd whose CID inlines its data, if nd is a
+// ProtoNode or RawNode with CIDv1 encoded in at most limit bytes. Other nodes
+// are returned as is.
+func Inline(nd ipld.Node, limit int) ipld.Node {
+	if limit <= 0 || len(nd.RawData()) > limit || nd.Cid().Prefix().Version == 0 {
+		return nd
+	}
+
+	prefix := nd.Cid().Prefix()
+	prefix.MhType = mh.ID
+	prefix.MhLength = -1
+	switch nd := nd.(type) {
+	case *ProtoNode:
+		inlined := nd.Copy().(*ProtoNode)
+		inlined.SetPrefix(&prefix)
+		return inlined
+	case *RawNode:
+		inlined, err := NewRawNodeWPrefix(nd.RawData(), prefix)
+		if err != nil {
+			return nd
+		}
+		return inlined
+	default:
+		return nd
+	}
+}
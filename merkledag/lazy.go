@@ -0,0 +1,216 @@
+package merkledag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+var errTruncatedProtobuf = errors.New("truncated protobuf")
+
+// protobufFields calls f with the fields of the protobuf message encoded in
+// data: with the value of varint fields, or the bytes of length-delimited
+// ones, which alias data.
+func protobufFields(data []byte, f func(field, varint uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncatedProtobuf
+		}
+		data = data[n:]
+
+		var v uint64
+		var b []byte
+		switch key & 7 {
+		case 0:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncatedProtobuf
+			}
+			data = data[n:]
+		case 1, 5:
+			size := 8
+			if key&7 == 5 {
+				size = 4
+			}
+			if len(data) < size {
+				return errTruncatedProtobuf
+			}
+			data = data[size:]
+		case 2:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < l {
+				return errTruncatedProtobuf
+			}
+			b = data[n : n+int(l)]
+			data = data[n+int(l):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+
+		if err := f(key>>3, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeProtobufLinks decodes the links of a dag-pb node, skipping its data.
+func DecodeProtobufLinks(encoded []byte) ([]*ipld.Link, error) {
+	var links []*ipld.Link
+	err := protobufFields(encoded, func(field, _ uint64, b []byte) error {
+		if field != 2 {
+			return nil
+		}
+
+		l := new(ipld.Link)
+		err := protobufFields(b, func(field, v uint64, b []byte) error {
+			switch field {
+			case 1:
+				c, err := cid.Cast(b)
+				if err != nil {
+					return fmt.Errorf("Link hash #%d is not valid multihash. %v", len(links), err)
+				}
+				l.Cid = c
+			case 2:
+				l.Name = string(b)
+			case 3:
+				l.Size = v
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if l.Cid == nil {
+			return fmt.Errorf("Link #%d has no hash", len(links))
+		}
+		links = append(links, l)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal failed. %v", err)
+	}
+	sort.Stable(LinkSlice(links)) // keep links sorted
+	return links, nil
+}
+
+// BlockLinks returns the links of the node encoded in b, decoding as little
+// of it as possible.
+func BlockLinks(b blocks.Block) ([]*ipld.Link, error) {
+	switch b.Cid().Type() {
+	case cid.Raw:
+		return nil, nil
+	case cid.DagProtobuf:
+		return DecodeProtobufLinks(b.RawData())
+	default:
+		nd, err := ipld.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		return nd.Links(), nil
+	}
+}
+
+// LazyNode is a dag-pb node which only decodes its links up front. The rest
+// of the node, with its data, is decoded when first needed. Traversals only
+// following links, like marking blocks for the garbage collection, save the
+// decoding and copying of the data of every node.
+type LazyNode struct {
+	blocks.Block
+
+	links []*ipld.Link
+	proto *ProtoNode
+}
+
+// DecodeLazyBlock decodes the links of the dag-pb node of b.
+func DecodeLazyBlock(b blocks.Block) (*LazyNode, error) {
+	if b.Cid().Type() != cid.DagProtobuf {
+		return nil, ErrNotProtobuf
+	}
+	links, err := DecodeProtobufLinks(b.RawData())
+	if err != nil {
+		return nil, err
+	}
+	return &LazyNode{Block: b, links: links}, nil
+}
+
+// Proto decodes the whole node.
+func (n *LazyNode) Proto() (*ProtoNode, error) {
+	if n.proto == nil {
+		nd, err := DecodeProtobufBlock(n.Block)
+		if err != nil {
+			return nil, err
+		}
+		n.proto = nd.(*ProtoNode)
+	}
+	return n.proto, nil
+}
+
+// Links returns the links of the node.
+func (n *LazyNode) Links() []*ipld.Link {
+	return n.links
+}
+
+// ResolveLink consumes the first element of the path and obtains the link
+// corresponding to it from the node.
+func (n *LazyNode) ResolveLink(path []string) (*ipld.Link, []string, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("end of path, no more links to resolve")
+	}
+	for _, l := range n.links {
+		if l.Name == path[0] {
+			return &ipld.Link{Name: l.Name, Size: l.Size, Cid: l.Cid}, path[1:], nil
+		}
+	}
+	return nil, nil, ErrLinkNotFound
+}
+
+// Resolve is an alias for ResolveLink.
+func (n *LazyNode) Resolve(path []string) (interface{}, []string, error) {
+	return n.ResolveLink(path)
+}
+
+// Tree returns the link names of the node.
+func (n *LazyNode) Tree(p string, depth int) []string {
+	if p != "" {
+		return nil
+	}
+	out := make([]string, 0, len(n.links))
+	for _, l := range n.links {
+		out = append(out, l.Name)
+	}
+	return out
+}
+
+// Copy returns a copy of the node, sharing its block.
+func (n *LazyNode) Copy() ipld.Node {
+	links := make([]*ipld.Link, len(n.links))
+	copy(links, n.links)
+	return &LazyNode{Block: n.Block, links: links}
+}
+
+// Size returns the total size of the data addressed by the node.
+func (n *LazyNode) Size() (uint64, error) {
+	s := uint64(len(n.RawData()))
+	for _, l := range n.links {
+		s += l.Size
+	}
+	return s, nil
+}
+
+// Stat decodes the node and returns its statistics.
+func (n *LazyNode) Stat() (*ipld.NodeStat, error) {
+	nd, err := n.Proto()
+	if err != nil {
+		return nil, err
+	}
+	return nd.Stat()
+}
+
+var _ ipld.Node = (*LazyNode)(nil)
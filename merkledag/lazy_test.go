@@ -0,0 +1,122 @@
+package merkledag_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	blocks "github.com/ipfs/go-block-format"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// mkLinkedNode returns a node with the given number of links and data size.
+func mkLinkedNode(t testing.TB, links, data int) *ProtoNode {
+	nd := NodeWithData(bytes.Repeat([]byte{'x'}, data))
+	for i := 0; i < links; i++ {
+		child := NodeWithData([]byte{byte(i), byte(i >> 8)})
+		if err := nd.AddNodeLink(string(rune('a'+i%26))+string(rune('a'+i/26%26)), child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nd
+}
+
+func TestLazyNode(t *testing.T) {
+	nd := mkLinkedNode(t, 100, 1000)
+	blk, err := blocks.NewBlockWithCid(nd.RawData(), nd.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := DecodeLazyBlock(blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lazy.Links()) != len(nd.Links()) {
+		t.Fatalf("expected %d links, got %d", len(nd.Links()), len(lazy.Links()))
+	}
+	for i, l := range nd.Links() {
+		ll := lazy.Links()[i]
+		if ll.Name != l.Name || ll.Size != l.Size || !ll.Cid.Equals(l.Cid) {
+			t.Fatalf("link %d differs: %v != %v", i, ll, l)
+		}
+	}
+
+	lnk, rest, err := lazy.ResolveLink([]string{"ab", "more"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, _ := nd.GetNodeLink("ab")
+	if !lnk.Cid.Equals(exp.Cid) || len(rest) != 1 {
+		t.Fatal("resolved the wrong link")
+	}
+
+	full, err := lazy.Proto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(full.Data(), nd.Data()) {
+		t.Fatal("decoded the wrong data")
+	}
+	stat, err := lazy.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.DataSize != 1000 || stat.NumLinks != 100 {
+		t.Fatalf("unexpected statistics: %+v", stat)
+	}
+
+	if _, err := DecodeProtobufLinks(nd.RawData()[:len(nd.RawData())/2]); err == nil {
+		t.Fatal("expected decoding a truncated node to fail")
+	}
+}
+
+func TestGetLinksLazy(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+	nd := mkLinkedNode(t, 10, 100)
+	if err := ds.Add(ctx, nd); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := ipld.GetLinks(ctx, ds, nd.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 10 {
+		t.Fatalf("expected 10 links, got %d", len(links))
+	}
+}
+
+func benchmarkDecode(b *testing.B, decode func(blocks.Block) ([]*ipld.Link, error)) {
+	nd := mkLinkedNode(b, 174, 256*1024)
+	blk, err := blocks.NewBlockWithCid(nd.RawData(), nd.Cid())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decode(blk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeFull(b *testing.B) {
+	benchmarkDecode(b, func(blk blocks.Block) ([]*ipld.Link, error) {
+		nd, err := DecodeProtobufBlock(blk)
+		if err != nil {
+			return nil, err
+		}
+		return nd.Links(), nil
+	})
+}
+
+func BenchmarkDecodeLinks(b *testing.B) {
+	benchmarkDecode(b, BlockLinks)
+}
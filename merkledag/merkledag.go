@@ -10,19 +10,9 @@ import (
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
-	ipldcbor "github.com/ipfs/go-ipld-cbor"
 	ipld "github.com/ipfs/go-ipld-format"
 )
 
-// TODO: We should move these registrations elsewhere. Really, most of the IPLD
-// functionality should go in a `go-ipld` repo but that will take a lot of work
-// and design.
-func init() {
-	ipld.Register(cid.DagProtobuf, DecodeProtobufBlock)
-	ipld.Register(cid.Raw, DecodeRawBlock)
-	ipld.Register(cid.DagCBOR, ipldcbor.DecodeBlock)
-}
-
 // contextKey is a type to use as value for the ProgressTracker contexts.
 type contextKey string
 
@@ -86,11 +76,15 @@ func (n *dagService) GetLinks(ctx context.Context, c *cid.Cid) ([]*ipld.Link, er
 	if c.Type() == cid.Raw {
 		return nil, nil
 	}
-	node, err := n.Get(ctx, c)
+
+	b, err := n.Blocks.GetBlock(ctx, c)
 	if err != nil {
-		return nil, err
+		if err == bserv.ErrNotFound {
+			return nil, ipld.ErrNotFound
+		}
+		return nil, fmt.Errorf("Failed to get block for %s: %v", c, err)
 	}
-	return node.Links(), nil
+	return BlockLinks(b)
 }
 
 func (n *dagService) Remove(ctx context.Context, c *cid.Cid) error {
@@ -159,11 +153,7 @@ func (n *dagService) Session(ctx context.Context) ipld.NodeGetter {
 
 // FetchGraph fetches all nodes that are children of the given node
 func FetchGraph(ctx context.Context, root *cid.Cid, serv ipld.DAGService) error {
-	var ng ipld.NodeGetter = serv
-	ds, ok := serv.(*dagService)
-	if ok {
-		ng = &sesGetter{bserv.NewSession(ctx, ds.Blocks)}
-	}
+	ng := NewSession(ctx, serv)
 
 	v, _ := ctx.Value(progressContextKey).(*ProgressTracker)
 	if v == nil {
@@ -213,7 +203,8 @@ func getNodesFromBG(ctx context.Context, bs bserv.BlockGetter, keys []*cid.Cid)
 			case b, ok := <-blocks:
 				if !ok {
 					if count != len(keys) {
-						out <- &ipld.NodeOption{Err: fmt.Errorf("failed to fetch all nodes")}
+						// some of the blocks couldn't be found
+						out <- &ipld.NodeOption{Err: ipld.ErrNotFound}
 					}
 					return
 				}
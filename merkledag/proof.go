@@ -0,0 +1,165 @@
+package merkledag
+
+import (
+	"context"
+	"errors"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ErrInvalidProof is returned when the blocks of a proof don't link its root
+// to its target.
+var ErrInvalidProof = errors.New("proof doesn't link the root to the target")
+
+// ErrNotInDag is returned when proving that a node is part of a DAG which
+// doesn't contain it.
+var ErrNotInDag = errors.New("node is not part of the dag")
+
+// Proof proves that the node of Target is part of the DAG of Root, with the
+// blocks of the chain of nodes from the root to the parent of the target,
+// each linking to the next one. It can be verified without accessing the
+// DAG, by clients only trusting the cid of the root.
+type Proof struct {
+	Root   *cid.Cid
+	Target *cid.Cid
+
+	// Path is the path from Root to Target the proof was made for, if any.
+	Path []string `json:",omitempty"`
+
+	Blocks [][]byte
+}
+
+// Prove resolves path from root, and returns a proof that the node found is
+// part of the DAG of root, at the end of path.
+func Prove(ctx context.Context, ng ipld.NodeGetter, root *cid.Cid, path []string) (*Proof, error) {
+	p := &Proof{Root: root, Target: root, Path: path}
+	for len(path) > 0 {
+		nd, err := ng.Get(ctx, p.Target)
+		if err != nil {
+			return nil, err
+		}
+		lnk, rest, err := nd.ResolveLink(path)
+		if err != nil {
+			return nil, err
+		}
+		p.Blocks = append(p.Blocks, nd.RawData())
+		p.Target = lnk.Cid
+		path = rest
+	}
+	return p, nil
+}
+
+// ProveNode searches the DAG of root for the node of target, and returns a
+// proof that it is part of it. It returns ErrNotInDag if the DAG doesn't
+// contain target.
+func ProveNode(ctx context.Context, ng ipld.NodeGetter, root, target *cid.Cid) (*Proof, error) {
+	ng = NewSession(ctx, ng)
+	p := &Proof{Root: root, Target: target}
+	seen := cid.NewSet()
+
+	var find func(c *cid.Cid) (bool, error)
+	find = func(c *cid.Cid) (bool, error) {
+		if c.Equals(target) {
+			return true, nil
+		}
+		if !seen.Visit(c) {
+			return false, nil
+		}
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return false, err
+		}
+		p.Blocks = append(p.Blocks, nd.RawData())
+		for _, l := range nd.Links() {
+			found, err := find(l.Cid)
+			if err != nil || found {
+				return found, err
+			}
+		}
+		p.Blocks = p.Blocks[:len(p.Blocks)-1]
+		return false, nil
+	}
+
+	found, err := find(root)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotInDag
+	}
+	return p, nil
+}
+
+// Verify checks that the blocks of p link its root to its target, and if p
+// has a path, that they do so through the links it names. It only needs the
+// codecs of the blocks, not the DAG they are part of.
+func (p *Proof) Verify() error {
+	// proofs come from untrusted peers
+	if p.Root == nil || p.Target == nil {
+		return ErrInvalidProof
+	}
+
+	c := p.Root
+	path := p.Path
+	for i, data := range p.Blocks {
+		nd, err := decodeProofBlock(c, data)
+		if err != nil {
+			return err
+		}
+
+		if len(p.Path) > 0 {
+			if len(path) == 0 {
+				return ErrInvalidProof
+			}
+			lnk, rest, err := nd.ResolveLink(path)
+			if err != nil {
+				return err
+			}
+			c, path = lnk.Cid, rest
+			continue
+		}
+
+		// without a path, follow the link to the next block
+		var next *cid.Cid
+		for _, l := range nd.Links() {
+			if i == len(p.Blocks)-1 {
+				if l.Cid.Equals(p.Target) {
+					next = l.Cid
+					break
+				}
+				continue
+			}
+			if sum, err := l.Cid.Prefix().Sum(p.Blocks[i+1]); err == nil && sum.Equals(l.Cid) {
+				next = l.Cid
+				break
+			}
+		}
+		if next == nil {
+			return ErrInvalidProof
+		}
+		c = next
+	}
+
+	if len(path) > 0 || !c.Equals(p.Target) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// decodeProofBlock decodes data after checking that it is the block of c.
+func decodeProofBlock(c *cid.Cid, data []byte) (ipld.Node, error) {
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, ErrInvalidProof
+	}
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return ipld.Decode(b)
+}
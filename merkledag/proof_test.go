@@ -0,0 +1,84 @@
+package merkledag_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+)
+
+func TestProof(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+	root, names := mkWalkDag(t, ds)
+
+	p, err := Prove(ctx, ds, root.Cid(), []string{"a", "y", "z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names[p.Target.KeyString()] != "ayz" {
+		t.Fatalf("proved %s instead of ayz", names[p.Target.KeyString()])
+	}
+	if len(p.Blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(p.Blocks))
+	}
+	if err := p.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// proofs can be exchanged as json
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p2 Proof
+	if err := json.Unmarshal(data, &p2); err != nil {
+		t.Fatal(err)
+	}
+	if err := p2.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	p3, err := ProveNode(ctx, ds, root.Cid(), p.Target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p3.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a different path doesn't match the blocks
+	p2.Path = []string{"a", "x"}
+	if err := p2.Verify(); err == nil {
+		t.Fatal("expected a proof with the wrong path to fail")
+	}
+
+	// nor a different target
+	p3.Target = root.Cid()
+	if err := p3.Verify(); err != ErrInvalidProof {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+
+	// nor tampered blocks
+	p.Blocks[1] = append([]byte(nil), p.Blocks[1]...)
+	p.Blocks[1][len(p.Blocks[1])-1]++
+	if err := p.Verify(); err != ErrInvalidProof {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+
+	// proofs decoded from json may lack the root or the target
+	var p4 Proof
+	if err := json.Unmarshal([]byte(`{"Blocks":[]}`), &p4); err != nil {
+		t.Fatal(err)
+	}
+	if err := p4.Verify(); err != ErrInvalidProof {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+
+	other := NodeWithData([]byte("other"))
+	if _, err := ProveNode(ctx, ds, root.Cid(), other.Cid()); err != ErrNotInDag {
+		t.Fatalf("expected ErrNotInDag, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package merkledag
+
+import (
+	"context"
+	"math/bits"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// DagStat describes the shape of a DAG, to help choosing chunking and
+// layout options.
+type DagStat struct {
+	// Nodes is the number of distinct nodes, Size their total size.
+	Nodes int
+	Size  uint64
+
+	// Refs is the number of references to nodes: the links of the
+	// distinct nodes, plus the root. Nodes referenced several times count
+	// once in Nodes but several times in Refs.
+	Refs int
+
+	// Missing is the number of distinct nodes which couldn't be fetched.
+	Missing int
+
+	MaxDepth int
+
+	// Interior is the number of nodes with links.
+	Interior int
+
+	// SizeHistogram counts the distinct blocks by size: SizeHistogram[i]
+	// counts the blocks of 2^(i-1) to 2^i - 1 bytes.
+	SizeHistogram []int
+}
+
+// AvgFanout returns the average number of links of the nodes with links.
+func (s *DagStat) AvgFanout() float64 {
+	if s.Interior == 0 {
+		return 0
+	}
+	return float64(s.Refs-1) / float64(s.Interior)
+}
+
+// DuplicateRatio returns the share of the references to nodes which point
+// to a node referenced before.
+func (s *DagStat) DuplicateRatio() float64 {
+	if s.Refs == 0 {
+		return 0
+	}
+	return float64(s.Refs-s.Nodes-s.Missing) / float64(s.Refs)
+}
+
+// Stat walks the DAG below root and returns its statistics. The children of
+// every node are fetched concurrently; nodes ng can't get are counted as
+// missing. If progress isn't nil, it is called with the statistics so far
+// after every node, and must not keep or modify them.
+func Stat(ctx context.Context, ng ipld.NodeGetter, root *cid.Cid, progress func(*DagStat)) (*DagStat, error) {
+	nd, err := ng.Get(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &statWalker{
+		ng:       ng,
+		progress: progress,
+		seen:     cid.NewSet(),
+		stat:     &DagStat{Refs: 1},
+	}
+	s.seen.Add(root)
+	if err := s.walk(ctx, nd, 0); err != nil {
+		return nil, err
+	}
+	return s.stat, nil
+}
+
+type statWalker struct {
+	ng       ipld.NodeGetter
+	progress func(*DagStat)
+	seen     *cid.Set
+	stat     *DagStat
+}
+
+func (s *statWalker) walk(ctx context.Context, nd ipld.Node, depth int) error {
+	st := s.stat
+	st.Nodes++
+	size := len(nd.RawData())
+	st.Size += uint64(size)
+	if depth > st.MaxDepth {
+		st.MaxDepth = depth
+	}
+	b := bits.Len(uint(size))
+	for len(st.SizeHistogram) <= b {
+		st.SizeHistogram = append(st.SizeHistogram, 0)
+	}
+	st.SizeHistogram[b]++
+
+	links := nd.Links()
+	st.Refs += len(links)
+	if len(links) > 0 {
+		st.Interior++
+	}
+	if s.progress != nil {
+		s.progress(st)
+	}
+
+	var keys []*cid.Cid
+	for _, l := range links {
+		if s.seen.Visit(l.Cid) {
+			keys = append(keys, l.Cid)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	got := make(map[string]ipld.Node, len(keys))
+	for opt := range s.ng.GetMany(ctx, keys) {
+		if opt.Err != nil && opt.Err != ipld.ErrNotFound {
+			return opt.Err
+		}
+		if opt.Node != nil {
+			got[opt.Node.Cid().KeyString()] = opt.Node
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for _, k := range keys {
+		child, ok := got[k.KeyString()]
+		if !ok {
+			st.Missing++
+			continue
+		}
+		if err := s.walk(ctx, child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package merkledag_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+)
+
+func TestStat(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	// root -> a -> leaf
+	//      -> b -> leaf
+	//      -> missing
+	leaf := NodeWithData([]byte("leaf"))
+	a := NodeWithData([]byte("a"))
+	b := NodeWithData([]byte("b"))
+	missing := NodeWithData([]byte("missing"))
+	root := NodeWithData([]byte("root"))
+	for _, l := range []struct{ parent, child *ProtoNode }{
+		{a, leaf}, {b, leaf}, {root, a}, {root, b}, {root, missing},
+	} {
+		if err := l.parent.AddNodeLink("", l.child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, nd := range []*ProtoNode{leaf, a, b, root} {
+		if err := ds.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var updates int
+	st, err := Stat(ctx, ds, root.Cid(), func(*DagStat) { updates++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Nodes != 4 || st.Missing != 1 || st.Refs != 6 || st.MaxDepth != 2 || st.Interior != 3 {
+		t.Fatalf("unexpected statistics: %+v", st)
+	}
+	if updates != 4 {
+		t.Fatalf("expected 4 progress updates, got %d", updates)
+	}
+
+	var size uint64
+	for _, nd := range []*ProtoNode{leaf, a, b, root} {
+		size += uint64(len(nd.RawData()))
+	}
+	if st.Size != size {
+		t.Fatalf("expected a size of %d, got %d", size, st.Size)
+	}
+
+	var histogram int
+	for _, c := range st.SizeHistogram {
+		histogram += c
+	}
+	if histogram != 4 {
+		t.Fatalf("expected 4 blocks in the histogram, got %d", histogram)
+	}
+	if r := st.DuplicateRatio(); r != 1.0/6 {
+		t.Fatalf("expected a duplicate ratio of 1/6, got %f", r)
+	}
+	if f := st.AvgFanout(); f != 5.0/3 {
+		t.Fatalf("expected an average fan-out of 5/3, got %f", f)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"strings"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -52,12 +54,7 @@ func ApplyChange(ctx context.Context, ds ipld.DAGService, nd *dag.ProtoNode, cs
 				return nil, err
 			}
 
-			childpb, ok := child.(*dag.ProtoNode)
-			if !ok {
-				return nil, dag.ErrNotProtobuf
-			}
-
-			err = e.InsertNodeAtPath(ctx, c.Path, childpb, nil)
+			err = e.InsertNodeAtPath(ctx, c.Path, child, nil)
 			if err != nil {
 				return nil, err
 			}
@@ -78,12 +75,7 @@ func ApplyChange(ctx context.Context, ds ipld.DAGService, nd *dag.ProtoNode, cs
 				return nil, err
 			}
 
-			childpb, ok := child.(*dag.ProtoNode)
-			if !ok {
-				return nil, dag.ErrNotProtobuf
-			}
-
-			err = e.InsertNodeAtPath(ctx, c.Path, childpb, nil)
+			err = e.InsertNodeAtPath(ctx, c.Path, child, nil)
 			if err != nil {
 				return nil, err
 			}
@@ -93,9 +85,15 @@ func ApplyChange(ctx context.Context, ds ipld.DAGService, nd *dag.ProtoNode, cs
 	return e.Finalize(ctx, ds)
 }
 
-// Diff returns a set of changes that transform node 'a' into node 'b'
+// Diff returns a set of changes that transform node 'a' into node 'b'.
+// It descends into the links both nodes have under the same name, so it
+// works on unixfs directories as well as on generic IPLD DAGs. Unixfs files
+// are compared as a whole.
 func Diff(ctx context.Context, ds ipld.DAGService, a, b ipld.Node) ([]*Change, error) {
-	if len(a.Links()) == 0 && len(b.Links()) == 0 {
+	if a.Cid().Equals(b.Cid()) {
+		return nil, nil
+	}
+	if !diffable(a) || !diffable(b) || (len(a.Links()) == 0 && len(b.Links()) == 0) {
 		return []*Change{
 			&Change{
 				Type:   Mod,
@@ -105,60 +103,61 @@ func Diff(ctx context.Context, ds ipld.DAGService, a, b ipld.Node) ([]*Change, e
 		}, nil
 	}
 
-	var out []*Change
-	cleanA := a.Copy().(*dag.ProtoNode)
-	cleanB := b.Copy().(*dag.ProtoNode)
+	blinks := make(map[string]*ipld.Link)
+	for _, lnk := range b.Links() {
+		if _, ok := blinks[lnk.Name]; !ok {
+			blinks[lnk.Name] = lnk
+		}
+	}
 
-	// strip out unchanged stuff
+	var out []*Change
+	seen := make(map[string]bool)
 	for _, lnk := range a.Links() {
-		l, _, err := b.ResolveLink([]string{lnk.Name})
-		if err == nil {
-			if l.Cid.Equals(lnk.Cid) {
-				// no change... ignore it
-			} else {
-				anode, err := lnk.GetNode(ctx, ds)
-				if err != nil {
-					return nil, err
-				}
-
-				bnode, err := l.GetNode(ctx, ds)
-				if err != nil {
-					return nil, err
-				}
-
-				anodepb, ok := anode.(*dag.ProtoNode)
-				if !ok {
-					return nil, dag.ErrNotProtobuf
-				}
-
-				bnodepb, ok := bnode.(*dag.ProtoNode)
-				if !ok {
-					return nil, dag.ErrNotProtobuf
-				}
-
-				sub, err := Diff(ctx, ds, anodepb, bnodepb)
-				if err != nil {
-					return nil, err
-				}
-
-				for _, subc := range sub {
-					subc.Path = path.Join(lnk.Name, subc.Path)
-					out = append(out, subc)
-				}
-			}
-			cleanA.RemoveNodeLink(l.Name)
-			cleanB.RemoveNodeLink(l.Name)
+		if seen[lnk.Name] {
+			continue
 		}
-	}
+		seen[lnk.Name] = true
 
-	for _, lnk := range cleanA.Links() {
-		out = append(out, &Change{
-			Type:   Remove,
-			Path:   lnk.Name,
-			Before: lnk.Cid,
-		})
+		l, ok := blinks[lnk.Name]
+		if !ok {
+			out = append(out, &Change{
+				Type:   Remove,
+				Path:   lnk.Name,
+				Before: lnk.Cid,
+			})
+			continue
+		}
+		if l.Cid.Equals(lnk.Cid) {
+			// no change... ignore it
+			continue
+		}
+
+		anode, err := lnk.GetNode(ctx, ds)
+		if err != nil {
+			return nil, err
+		}
+
+		bnode, err := l.GetNode(ctx, ds)
+		if err != nil {
+			return nil, err
+		}
+
+		sub, err := Diff(ctx, ds, anode, bnode)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subc := range sub {
+			subc.Path = path.Join(lnk.Name, subc.Path)
+			out = append(out, subc)
+		}
 	}
-	for _, lnk := range cleanB.Links() {
+
+	for _, lnk := range b.Links() {
+		if seen[lnk.Name] {
+			continue
+		}
+		seen[lnk.Name] = true
 		out = append(out, &Change{
 			Type:  Add,
 			Path:  lnk.Name,
@@ -166,9 +165,38 @@ func Diff(ctx context.Context, ds ipld.DAGService, a, b ipld.Node) ([]*Change, e
 		})
 	}
 
+	if len(out) == 0 {
+		// same links, but the content of the nodes differs
+		out = append(out, &Change{
+			Type:   Mod,
+			Before: a.Cid(),
+			After:  b.Cid(),
+		})
+	}
 	return out, nil
 }
 
+// diffable reports whether Diff descends into the links of nd, which it
+// doesn't for files.
+func diffable(nd ipld.Node) bool {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return false
+	case *dag.ProtoNode:
+		if len(nd.Data()) == 0 {
+			return true
+		}
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			// not unixfs
+			return true
+		}
+		return fsn.Type == ft.TDirectory || fsn.Type == ft.THAMTShard
+	default:
+		return true
+	}
+}
+
 // Conflict represents two incompatible changes and is returned by MergeDiffs().
 type Conflict struct {
 	A *Change
@@ -203,3 +231,63 @@ func MergeDiffs(a, b []*Change) ([]*Change, []Conflict) {
 	}
 	return out, conflicts
 }
+
+// Merge merges the changes made to the directory tree base in a and in b,
+// by applying the changes of b on top of a. Changes of b touching a path
+// changed in a, or a path above or below it, are not applied but returned
+// as conflicts, unless both made the same change.
+func Merge(ctx context.Context, ds ipld.DAGService, base, a, b *dag.ProtoNode) (*dag.ProtoNode, []Conflict, error) {
+	ca, err := Diff(ctx, ds, base, a)
+	if err != nil {
+		return nil, nil, err
+	}
+	cb, err := Diff(ctx, ds, base, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var apply []*Change
+	var conflicts []Conflict
+	for _, c := range cb {
+		if c.Path == "" {
+			return nil, nil, fmt.Errorf("cannot merge changes of the root itself")
+		}
+
+		conflict, same := false, false
+		for _, o := range ca {
+			if !overlaps(o.Path, c.Path) {
+				continue
+			}
+			if o.Path == c.Path && o.Type == c.Type && sameCid(o.After, c.After) {
+				same = true
+				continue
+			}
+			conflicts = append(conflicts, Conflict{A: o, B: c})
+			conflict = true
+			break
+		}
+		if !conflict && !same {
+			apply = append(apply, c)
+		}
+	}
+
+	nd, err := ApplyChange(ctx, ds, a, apply)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nd, conflicts, nil
+}
+
+// overlaps reports whether p and q are the same path, or one is below the
+// other.
+func overlaps(p, q string) bool {
+	return p == q || p == "" || q == "" ||
+		strings.HasPrefix(q, p+"/") || strings.HasPrefix(p, q+"/")
+}
+
+func sameCid(a, b *cid.Cid) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equals(b)
+}
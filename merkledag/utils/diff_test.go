@@ -0,0 +1,134 @@
+package dagutils
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+func mkFile(t *testing.T, ds ipld.DAGService, content string) ipld.Node {
+	nd := dag.NodeWithData(ft.FilePBData([]byte(content), uint64(len(content))))
+	if err := ds.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func mkDir(t *testing.T, ds ipld.DAGService, entries map[string]ipld.Node) *dag.ProtoNode {
+	nd := ft.EmptyDirNode()
+	for name, child := range entries {
+		if err := nd.AddNodeLink(name, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func changeStrings(cs []*Change) string {
+	var out []string
+	for _, c := range cs {
+		out = append(out, []string{"add", "rm", "mod"}[c.Type]+" "+c.Path)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	a := mkDir(t, ds, map[string]ipld.Node{
+		"same": mkFile(t, ds, "same"),
+		"gone": mkFile(t, ds, "gone"),
+		"sub": mkDir(t, ds, map[string]ipld.Node{
+			"file": mkFile(t, ds, "old"),
+		}),
+	})
+	b := mkDir(t, ds, map[string]ipld.Node{
+		"same": mkFile(t, ds, "same"),
+		"new":  mkFile(t, ds, "new"),
+		"sub": mkDir(t, ds, map[string]ipld.Node{
+			"file": mkFile(t, ds, "changed"),
+		}),
+	})
+
+	changes, err := Diff(ctx, ds, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := changeStrings(changes), "add new, mod sub/file, rm gone"; got != exp {
+		t.Fatalf("expected %q, got %q", exp, got)
+	}
+
+	changes, err = Diff(ctx, ds, a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes between equal nodes, got %v", changes)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	base := mkDir(t, ds, map[string]ipld.Node{
+		"x": mkFile(t, ds, "x"),
+		"y": mkFile(t, ds, "y"),
+		"sub": mkDir(t, ds, map[string]ipld.Node{
+			"z": mkFile(t, ds, "z"),
+		}),
+	})
+	a := mkDir(t, ds, map[string]ipld.Node{
+		"x": mkFile(t, ds, "x from a"),
+		"y": mkFile(t, ds, "y"),
+		"sub": mkDir(t, ds, map[string]ipld.Node{
+			"z": mkFile(t, ds, "z"),
+		}),
+		"both": mkFile(t, ds, "both"),
+	})
+	b := mkDir(t, ds, map[string]ipld.Node{
+		"x": mkFile(t, ds, "x from b"),
+		"sub": mkDir(t, ds, map[string]ipld.Node{
+			"z": mkFile(t, ds, "z"),
+			"w": mkFile(t, ds, "w"),
+		}),
+		"both": mkFile(t, ds, "both"),
+	})
+
+	merged, conflicts, err := Merge(ctx, ds, base, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].A.Path != "x" || conflicts[0].B.Path != "x" {
+		t.Fatalf("expected a conflict on x, got %v", conflicts)
+	}
+
+	changes, err := Diff(ctx, ds, base, merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := changeStrings(changes), "add both, add sub/w, mod x, rm y"; got != exp {
+		t.Fatalf("expected %q, got %q", exp, got)
+	}
+
+	x, _, err := merged.ResolveLink([]string{"x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ax, _, _ := a.ResolveLink([]string{"x"})
+	if !x.Cid.Equals(ax.Cid) {
+		t.Fatal("expected the change of a to win the conflict")
+	}
+}
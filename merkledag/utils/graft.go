@@ -0,0 +1,29 @@
+package dagutils
+
+import (
+	"context"
+
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Graft makes the DAG below nd, usually a subtree of a larger DAG, a
+// standalone root. With an empty name, that is nd itself. Otherwise nd is
+// wrapped in a new unixfs directory under name, keeping the name it had in
+// its parent. Only the wrapping directory is written to ds, the blocks below
+// nd are shared with the DAG it was taken from.
+func Graft(ctx context.Context, ds ipld.DAGService, nd ipld.Node, name string) (ipld.Node, error) {
+	if name == "" {
+		return nd, nil
+	}
+
+	dir := ft.EmptyDirNode()
+	if err := dir.AddNodeLink(name, nd); err != nil {
+		return nil, err
+	}
+	if err := ds.Add(ctx, dir); err != nil {
+		return nil, err
+	}
+	return dir, nil
+}
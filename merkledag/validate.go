@@ -0,0 +1,166 @@
+package merkledag
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("merkledag")
+
+// Validator checks the shape of a node, like "this must look like a unixfs
+// directory", and returns an error describing what is wrong with it.
+type Validator func(nd ipld.Node) error
+
+// ErrInvalidNode is returned when adding a node a validator rejects.
+type ErrInvalidNode struct {
+	Cid *cid.Cid
+	Err error
+}
+
+func (e ErrInvalidNode) Error() string {
+	return fmt.Sprintf("invalid node %s: %s", e.Cid, e.Err)
+}
+
+// Validators are the validators of nodes, per codec.
+type Validators struct {
+	lk      sync.RWMutex
+	byCodec map[uint64][]Validator
+}
+
+// NewValidators creates an empty set of validators.
+func NewValidators() *Validators {
+	return &Validators{byCodec: make(map[uint64][]Validator)}
+}
+
+// Register adds v to the validators of the nodes of codec.
+func (vs *Validators) Register(codec uint64, v Validator) {
+	vs.lk.Lock()
+	defer vs.lk.Unlock()
+	vs.byCodec[codec] = append(vs.byCodec[codec], v)
+}
+
+// Validate runs the validators of the codec of nd, and returns the first
+// rejection as an ErrInvalidNode.
+func (vs *Validators) Validate(nd ipld.Node) error {
+	vs.lk.RLock()
+	validators := vs.byCodec[nd.Cid().Type()]
+	vs.lk.RUnlock()
+
+	for _, v := range validators {
+		if err := v(nd); err != nil {
+			return ErrInvalidNode{Cid: nd.Cid(), Err: err}
+		}
+	}
+	return nil
+}
+
+// ValidatingService is a DAGService rejecting the nodes added to it which
+// its validators reject. The nodes fetched through it are checked too, but
+// only reported to OnInvalid, as they may be needed regardless.
+type ValidatingService struct {
+	ipld.DAGService
+
+	Validators *Validators
+
+	// OnInvalid is called with the errors of the invalid nodes fetched. By
+	// default, they are logged.
+	OnInvalid func(err error)
+}
+
+var _ ipld.DAGService = (*ValidatingService)(nil)
+
+func (vs *ValidatingService) flag(nd ipld.Node) {
+	err := vs.Validators.Validate(nd)
+	if err == nil {
+		return
+	}
+	if vs.OnInvalid != nil {
+		vs.OnInvalid(err)
+		return
+	}
+	log.Warningf("fetched %s", err)
+}
+
+// Add validates nd, and adds it to the underlying DAGService.
+func (vs *ValidatingService) Add(ctx context.Context, nd ipld.Node) error {
+	if err := vs.Validators.Validate(nd); err != nil {
+		return err
+	}
+	return vs.DAGService.Add(ctx, nd)
+}
+
+// AddMany validates nds, and adds them to the underlying DAGService if they
+// are all valid.
+func (vs *ValidatingService) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		if err := vs.Validators.Validate(nd); err != nil {
+			return err
+		}
+	}
+	return vs.DAGService.AddMany(ctx, nds)
+}
+
+// Get fetches a node, reporting it if it is invalid.
+func (vs *ValidatingService) Get(ctx context.Context, c *cid.Cid) (ipld.Node, error) {
+	nd, err := vs.DAGService.Get(ctx, c)
+	if err == nil {
+		vs.flag(nd)
+	}
+	return nd, err
+}
+
+// GetMany fetches nodes, reporting those which are invalid.
+func (vs *ValidatingService) GetMany(ctx context.Context, cids []*cid.Cid) <-chan *ipld.NodeOption {
+	return vs.flagMany(ctx, vs.DAGService.GetMany(ctx, cids))
+}
+
+func (vs *ValidatingService) flagMany(ctx context.Context, in <-chan *ipld.NodeOption) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, cap(in))
+	go func() {
+		defer close(out)
+		for opt := range in {
+			if opt.Err == nil {
+				vs.flag(opt.Node)
+			}
+			select {
+			case out <- opt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// GetLinks returns the links of the node of c, without validating it.
+func (vs *ValidatingService) GetLinks(ctx context.Context, c *cid.Cid) ([]*ipld.Link, error) {
+	return ipld.GetLinks(ctx, vs.DAGService, c)
+}
+
+// Session returns a NodeGetter using a session of the underlying
+// DAGService, if it supports them, reporting the invalid nodes fetched.
+func (vs *ValidatingService) Session(ctx context.Context) ipld.NodeGetter {
+	return &validatingGetter{NewSession(ctx, vs.DAGService), vs}
+}
+
+type validatingGetter struct {
+	ng ipld.NodeGetter
+	vs *ValidatingService
+}
+
+func (g *validatingGetter) Get(ctx context.Context, c *cid.Cid) (ipld.Node, error) {
+	nd, err := g.ng.Get(ctx, c)
+	if err == nil {
+		g.vs.flag(nd)
+	}
+	return nd, err
+}
+
+func (g *validatingGetter) GetMany(ctx context.Context, cids []*cid.Cid) <-chan *ipld.NodeOption {
+	return g.vs.flagMany(ctx, g.ng.GetMany(ctx, cids))
+}
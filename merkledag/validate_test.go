@@ -0,0 +1,67 @@
+package merkledag_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+func TestValidatingService(t *testing.T) {
+	ctx := context.Background()
+	base := dstest.Mock()
+
+	vs := NewValidators()
+	vs.Register(cid.DagProtobuf, func(nd ipld.Node) error {
+		if string(nd.(*ProtoNode).Data()) == "bad" {
+			return errors.New("bad data")
+		}
+		return nil
+	})
+
+	var flagged []error
+	ds := &ValidatingService{
+		DAGService: base,
+		Validators: vs,
+		OnInvalid:  func(err error) { flagged = append(flagged, err) },
+	}
+
+	good := NodeWithData([]byte("good"))
+	bad := NodeWithData([]byte("bad"))
+	if err := ds.Add(ctx, good); err != nil {
+		t.Fatal(err)
+	}
+	err := ds.Add(ctx, bad)
+	if e, ok := err.(ErrInvalidNode); !ok || !e.Cid.Equals(bad.Cid()) {
+		t.Fatalf("expected ErrInvalidNode, got %v", err)
+	}
+	if err := ds.AddMany(ctx, []ipld.Node{good, bad}); err == nil {
+		t.Fatal("expected adding a batch with an invalid node to fail")
+	}
+
+	// other codecs aren't affected
+	if err := ds.Add(ctx, NewRawNode([]byte("bad"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// invalid nodes fetched are flagged, not rejected
+	if err := base.Add(ctx, bad); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Get(ctx, bad.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	for opt := range ds.GetMany(ctx, []*cid.Cid{good.Cid(), bad.Cid()}) {
+		if opt.Err != nil {
+			t.Fatal(opt.Err)
+		}
+	}
+	if len(flagged) != 2 {
+		t.Fatalf("expected the invalid node to be flagged twice, got %d", len(flagged))
+	}
+}
@@ -0,0 +1,223 @@
+package merkledag
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strconv"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// SkipLinks may be returned by a VisitFunc to make Walk skip the links of the
+// node visited.
+var SkipLinks = errors.New("skip the links of this node")
+
+// VisitFunc is called by Walk with every node selected, and its depth below
+// the root.
+type VisitFunc func(nd ipld.Node, depth int) error
+
+// Selector selects the nodes of a DAG visited by Walk, and the links it
+// follows. Selectors don't keep state, so they can be reused and combined
+// freely.
+type Selector interface {
+	// Visit reports whether nd, depth links below the root, is passed to
+	// the VisitFunc.
+	Visit(nd ipld.Node, depth int) bool
+
+	// Follow returns the links of nd, depth links below the root, to walk
+	// into.
+	Follow(nd ipld.Node, depth int, links []*ipld.Link) []*ipld.Link
+}
+
+type selectAll struct{}
+
+func (selectAll) Visit(ipld.Node, int) bool { return true }
+
+func (selectAll) Follow(nd ipld.Node, depth int, links []*ipld.Link) []*ipld.Link {
+	return links
+}
+
+// SelectAll selects every node of the DAG.
+var SelectAll Selector = selectAll{}
+
+type maxDepth struct {
+	Selector
+	max int
+}
+
+func (s maxDepth) Visit(nd ipld.Node, depth int) bool {
+	return depth <= s.max && s.Selector.Visit(nd, depth)
+}
+
+func (s maxDepth) Follow(nd ipld.Node, depth int, links []*ipld.Link) []*ipld.Link {
+	if depth >= s.max {
+		return nil
+	}
+	return s.Selector.Follow(nd, depth, links)
+}
+
+// MaxDepth limits s to the nodes at most max links below the root.
+func MaxDepth(s Selector, max int) Selector {
+	return maxDepth{s, max}
+}
+
+type matchLinks struct {
+	Selector
+	pattern string
+}
+
+func (s matchLinks) Follow(nd ipld.Node, depth int, links []*ipld.Link) []*ipld.Link {
+	var out []*ipld.Link
+	for _, l := range s.Selector.Follow(nd, depth, links) {
+		if ok, _ := path.Match(s.pattern, l.Name); ok {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// MatchLinks limits s to the links whose name matches pattern, using the
+// syntax of path.Match.
+func MatchLinks(s Selector, pattern string) Selector {
+	return matchLinks{s, pattern}
+}
+
+type filter struct {
+	Selector
+	f func(ipld.Node) bool
+}
+
+func (s filter) Visit(nd ipld.Node, depth int) bool {
+	return s.f(nd) && s.Selector.Visit(nd, depth)
+}
+
+// Filter limits the nodes s passes to the VisitFunc to those f returns true
+// for. The links of the other nodes are still followed.
+func Filter(s Selector, f func(ipld.Node) bool) Selector {
+	return filter{s, f}
+}
+
+type selectPath struct {
+	next     Selector
+	segments []string
+}
+
+func (s selectPath) Visit(nd ipld.Node, depth int) bool {
+	if depth < len(s.segments) {
+		return false
+	}
+	return s.next.Visit(nd, depth-len(s.segments))
+}
+
+func (s selectPath) Follow(nd ipld.Node, depth int, links []*ipld.Link) []*ipld.Link {
+	if depth >= len(s.segments) {
+		return s.next.Follow(nd, depth-len(s.segments), links)
+	}
+	var out []*ipld.Link
+	for _, l := range links {
+		if ok, _ := path.Match(s.segments[depth], l.Name); ok {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// SelectPath follows the links named after segments from the root, and
+// applies next to the DAGs found at the end of the path, with depths
+// relative to them. The segments may be patterns using the syntax of
+// path.Match.
+func SelectPath(next Selector, segments ...string) Selector {
+	return selectPath{next, segments}
+}
+
+// Walk walks the DAG below root depth-first, calling visit with the nodes
+// selected by sel. The children of every node are fetched concurrently,
+// using a session of ng if it supports them. A node reachable through
+// several paths is walked once per depth it is found at.
+func Walk(ctx context.Context, ng ipld.NodeGetter, root *cid.Cid, sel Selector, visit VisitFunc) error {
+	ng = NewSession(ctx, ng)
+	nd, err := ng.Get(ctx, root)
+	if err != nil {
+		return err
+	}
+	w := &walker{
+		ng:    ng,
+		sel:   sel,
+		visit: visit,
+		seen:  make(map[string]struct{}),
+	}
+	return w.walk(ctx, nd, 0)
+}
+
+type walker struct {
+	ng    ipld.NodeGetter
+	sel   Selector
+	visit VisitFunc
+	seen  map[string]struct{}
+}
+
+func (w *walker) walk(ctx context.Context, nd ipld.Node, depth int) error {
+	if w.sel.Visit(nd, depth) {
+		switch err := w.visit(nd, depth); err {
+		case nil:
+		case SkipLinks:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	var keys []*cid.Cid
+	for _, l := range w.sel.Follow(nd, depth, nd.Links()) {
+		k := strconv.Itoa(depth+1) + "/" + l.Cid.KeyString()
+		if _, ok := w.seen[k]; ok {
+			continue
+		}
+		w.seen[k] = struct{}{}
+		keys = append(keys, l.Cid)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	children, err := w.getMany(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := w.walk(ctx, child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getMany fetches the nodes of keys concurrently, and returns them in the
+// same order.
+func (w *walker) getMany(ctx context.Context, keys []*cid.Cid) ([]ipld.Node, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	got := make(map[string]ipld.Node, len(keys))
+	for opt := range w.ng.GetMany(ctx, keys) {
+		if opt.Err != nil {
+			return nil, opt.Err
+		}
+		got[opt.Node.Cid().KeyString()] = opt.Node
+	}
+
+	out := make([]ipld.Node, len(keys))
+	for i, k := range keys {
+		nd, ok := got[k.KeyString()]
+		if !ok {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, ipld.ErrNotFound
+		}
+		out[i] = nd
+	}
+	return out, nil
+}
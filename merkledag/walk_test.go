@@ -0,0 +1,126 @@
+package merkledag_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// mkWalkDag builds
+//
+//	root -a-> a -x-> ax
+//	            -y-> ay -z-> ayz
+//	     -b-> b -x-> bx
+//
+// and returns the root, with the names of the nodes keyed by cid.
+func mkWalkDag(t *testing.T, ds ipld.DAGService) (*ProtoNode, map[string]string) {
+	names := make(map[string]string)
+	mk := func(name string, children map[string]*ProtoNode) *ProtoNode {
+		nd := NodeWithData([]byte(name))
+		for l, c := range children {
+			if err := nd.AddNodeLink(l, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ds.Add(context.Background(), nd); err != nil {
+			t.Fatal(err)
+		}
+		names[nd.Cid().KeyString()] = name
+		return nd
+	}
+
+	ayz := mk("ayz", nil)
+	ax := mk("ax", nil)
+	ay := mk("ay", map[string]*ProtoNode{"z": ayz})
+	a := mk("a", map[string]*ProtoNode{"x": ax, "y": ay})
+	bx := mk("bx", nil)
+	b := mk("b", map[string]*ProtoNode{"x": bx})
+	root := mk("root", map[string]*ProtoNode{"a": a, "b": b})
+	return root, names
+}
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+	root, names := mkWalkDag(t, ds)
+
+	walk := func(sel Selector) string {
+		var out []string
+		err := Walk(ctx, ds, root.Cid(), sel, func(nd ipld.Node, depth int) error {
+			out = append(out, names[nd.Cid().KeyString()])
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(out)
+		return strings.Join(out, " ")
+	}
+
+	cases := []struct {
+		sel Selector
+		exp string
+	}{
+		{SelectAll, "a ax ay ayz b bx root"},
+		{MaxDepth(SelectAll, 1), "a b root"},
+		{MatchLinks(SelectAll, "[ay]"), "a ay root"},
+		{SelectPath(SelectAll, "*", "x"), "ax bx"},
+		{SelectPath(MaxDepth(SelectAll, 0), "a"), "a"},
+		{SelectPath(SelectAll, "a", "y"), "ay ayz"},
+		{Filter(SelectAll, func(nd ipld.Node) bool { return len(nd.Links()) == 0 }), "ax ayz bx"},
+	}
+	for i, c := range cases {
+		if got := walk(c.sel); got != c.exp {
+			t.Errorf("case %d: expected %q, got %q", i, c.exp, got)
+		}
+	}
+
+	// SkipLinks prunes the DAG below the node visited
+	var visited []string
+	err := Walk(ctx, ds, root.Cid(), SelectAll, func(nd ipld.Node, depth int) error {
+		name := names[nd.Cid().KeyString()]
+		visited = append(visited, name)
+		if name == "a" {
+			return SkipLinks
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(visited)
+	if got := strings.Join(visited, " "); got != "a b bx root" {
+		t.Fatalf("expected the children of a to be skipped, got %q", got)
+	}
+}
+
+func TestWalkMissing(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+	root, _ := mkWalkDag(t, ds)
+
+	a, err := root.GetLinkedNode(ctx, ds, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Remove(ctx, a.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Walk(ctx, ds, root.Cid(), SelectAll, func(ipld.Node, int) error { return nil })
+	if err == nil {
+		t.Fatal("expected walking a DAG with a missing node to fail")
+	}
+
+	// the missing node isn't needed when not walked into
+	err = Walk(ctx, ds, root.Cid(), MatchLinks(SelectAll, "b"), func(ipld.Node, int) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
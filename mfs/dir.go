@@ -38,6 +38,11 @@ type Directory struct {
 	modTime time.Time
 
 	name string
+
+	// usage caches the cumulative size of the directory, if usageOK.
+	usageLk sync.Mutex
+	usage   uint64
+	usageOK bool
 }
 
 // NewDirectory constructs a new MFS directory.
@@ -72,6 +77,21 @@ func (d *Directory) SetPrefix(prefix *cid.Prefix) {
 	d.dirbuilder.SetPrefix(prefix)
 }
 
+// Attrs returns the filesystem attributes recorded in the directory.
+func (d *Directory) Attrs() ft.Attrs {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.dirbuilder.Attrs()
+}
+
+// SetAttrs records the filesystem attributes of the directory.
+func (d *Directory) SetAttrs(a ft.Attrs) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.invalidateUsage()
+	return d.dirbuilder.SetAttrs(a)
+}
+
 // closeChild updates the child by the given name to the dag node 'nd'
 // and changes its own dag node
 func (d *Directory) closeChild(name string, nd ipld.Node, sync bool) error {
@@ -95,6 +115,7 @@ func (d *Directory) closeChildUpdate(name string, nd ipld.Node, sync bool) (*dag
 	if err != nil {
 		return nil, err
 	}
+	d.invalidateUsage()
 
 	if sync {
 		return d.flushCurrentNode()
@@ -326,6 +347,8 @@ func (d *Directory) Mkdir(name string) (*Directory, error) {
 	}
 
 	d.childDirs[name] = dirobj
+	d.invalidateUsage()
+	d.emit(OpMkdir, name, nil, ndir.Cid())
 	return dirobj, nil
 }
 
@@ -333,10 +356,28 @@ func (d *Directory) Unlink(name string) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
+	// only look the node removed up if the change is watched
+	p, root := d.entryPath(name)
+	var old *cid.Cid
+	if root != nil && root.watchers.active() {
+		if c, err := d.childUnsync(name); err == nil {
+			if nd, err := c.GetNode(); err == nil {
+				old = nd.Cid()
+			}
+		}
+	}
+
 	delete(d.childDirs, name)
 	delete(d.files, name)
 
-	return d.dirbuilder.RemoveChild(d.ctx, name)
+	if err := d.dirbuilder.RemoveChild(d.ctx, name); err != nil {
+		return err
+	}
+	d.invalidateUsage()
+	if old != nil {
+		root.watchers.emit(OpRemove, p, old, nil)
+	}
+	return nil
 }
 
 func (d *Directory) Flush() error {
@@ -350,10 +391,18 @@ func (d *Directory) Flush() error {
 
 // AddChild adds the node 'nd' under this directory giving it the name 'name'
 func (d *Directory) AddChild(name string, nd ipld.Node) error {
+	size, err := nd.Size()
+	if err != nil {
+		return err
+	}
+	if err := d.checkQuota(size); err != nil {
+		return err
+	}
+
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	_, err := d.childUnsync(name)
+	_, err = d.childUnsync(name)
 	if err == nil {
 		return ErrDirExists
 	}
@@ -369,6 +418,8 @@ func (d *Directory) AddChild(name string, nd ipld.Node) error {
 	}
 
 	d.modTime = time.Now()
+	d.invalidateUsage()
+	d.emit(OpAdd, name, nil, nd.Cid())
 	return nil
 }
 
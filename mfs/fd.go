@@ -128,12 +128,34 @@ func (fi *fileDescriptor) flushUp(fullsync bool) error {
 	}
 
 	fi.inode.nodelk.Lock()
-	fi.inode.node = nd
+	old := fi.inode.node
 	name := fi.inode.name
 	parent := fi.inode.parent
 	fi.inode.nodelk.Unlock()
 
-	return parent.closeChild(name, nd, fullsync)
+	if dir, ok := parent.(*Directory); ok {
+		if err := dir.checkQuota(sizeGrowth(old, nd)); err != nil {
+			return err
+		}
+	}
+
+	fi.inode.nodelk.Lock()
+	fi.inode.node = nd
+	fi.inode.nodelk.Unlock()
+
+	if err := parent.closeChild(name, nd, fullsync); err != nil {
+		return err
+	}
+
+	if !old.Cid().Equals(nd.Cid()) {
+		switch parent := parent.(type) {
+		case *Directory:
+			parent.emit(OpWrite, name, old.Cid(), nd.Cid())
+		case *Root:
+			parent.watchers.emit(OpWrite, "/", old.Cid(), nd.Cid())
+		}
+	}
+	return nil
 }
 
 // Seek implements io.Seeker
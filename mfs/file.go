@@ -42,6 +42,24 @@ func NewFile(name string, node ipld.Node, parent childCloser, dserv ipld.DAGServ
 	return fi, nil
 }
 
+// SymlinkTarget returns the target of the file if it is a symlink. Symlinks
+// are files for mfs, which can't be opened.
+func (fi *File) SymlinkTarget() (string, bool) {
+	fi.nodelk.Lock()
+	node := fi.node
+	fi.nodelk.Unlock()
+
+	pbnd, ok := node.(*dag.ProtoNode)
+	if !ok {
+		return "", false
+	}
+	fsn, err := ft.FSNodeFromBytes(pbnd.Data())
+	if err != nil || fsn.Type != ft.TSymlink {
+		return "", false
+	}
+	return string(fsn.Data), true
+}
+
 const (
 	OpenReadOnly = iota
 	OpenWriteOnly
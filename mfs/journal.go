@@ -0,0 +1,305 @@
+package mfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+// ErrNoJournal is returned when asking for the past events of a filesystem
+// which doesn't record them.
+var ErrNoJournal = errors.New("the changes of the filesystem aren't recorded")
+
+// Op is the kind of change an Event describes.
+type Op string
+
+const (
+	// OpAdd is emitted when a node is added to a directory.
+	OpAdd Op = "add"
+
+	// OpMkdir is emitted when a directory is created.
+	OpMkdir Op = "mkdir"
+
+	// OpRemove is emitted when an entry is removed from a directory.
+	OpRemove Op = "remove"
+
+	// OpWrite is emitted when the changes to a file are flushed.
+	OpWrite Op = "write"
+)
+
+// Event describes a change of the content of a filesystem.
+type Event struct {
+	// Seq numbers the events of a filesystem in the order they happened.
+	Seq  uint64
+	Time time.Time
+	Op   Op
+
+	// Path is the path of the entry changed, from the root of the
+	// filesystem.
+	Path string
+
+	// Old and New are the nodes of the entry before and after the change,
+	// if it existed.
+	Old *cid.Cid `json:",omitempty"`
+	New *cid.Cid `json:",omitempty"`
+}
+
+// Journal records the events of a filesystem, so they can be replayed after
+// a restart.
+type Journal interface {
+	// Append records ev.
+	Append(ev Event) error
+
+	// Since returns the events recorded after the one numbered seq, oldest
+	// first.
+	Since(seq uint64) ([]Event, error)
+
+	// LastSeq returns the number of the last event recorded, or 0.
+	LastSeq() (uint64, error)
+}
+
+// datastoreJournal is a Journal keeping the last events in a datastore.
+type datastoreJournal struct {
+	lk     sync.Mutex
+	dstore ds.Datastore
+	prefix ds.Key
+	max    int
+}
+
+// NewDatastoreJournal returns a journal recording events in dstore under
+// prefix, keeping only the last max ones.
+func NewDatastoreJournal(dstore ds.Datastore, prefix ds.Key, max int) Journal {
+	return &datastoreJournal{dstore: dstore, prefix: prefix, max: max}
+}
+
+func (j *datastoreJournal) key(seq uint64) ds.Key {
+	// zero padded so keys sort in the order of events
+	return j.prefix.ChildString(fmt.Sprintf("%016x", seq))
+}
+
+func (j *datastoreJournal) Append(ev Event) error {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	data, err := json.Marshal(&ev)
+	if err != nil {
+		return err
+	}
+	if err := j.dstore.Put(j.key(ev.Seq), data); err != nil {
+		return err
+	}
+
+	if ev.Seq > uint64(j.max) {
+		return j.trim(ev.Seq - uint64(j.max))
+	}
+	return nil
+}
+
+// trim removes the events numbered up to seq.
+func (j *datastoreJournal) trim(seq uint64) error {
+	res, err := j.dstore.Query(dsq.Query{Prefix: j.prefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		k := ds.NewKey(e.Key)
+		n, err := strconv.ParseUint(k.Name(), 16, 64)
+		if err != nil || n > seq {
+			continue
+		}
+		if err := j.dstore.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// events returns the events recorded, oldest first.
+func (j *datastoreJournal) events() ([]Event, error) {
+	res, err := j.dstore.Query(dsq.Query{Prefix: j.prefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Event, 0, len(entries))
+	for _, e := range entries {
+		var ev Event
+		if err := json.Unmarshal(e.Value.([]byte), &ev); err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].Seq < out[b].Seq
+	})
+	return out, nil
+}
+
+func (j *datastoreJournal) Since(seq uint64) ([]Event, error) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	evs, err := j.events()
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(evs), func(i int) bool {
+		return evs[i].Seq > seq
+	})
+	return evs[i:], nil
+}
+
+func (j *datastoreJournal) LastSeq() (uint64, error) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	evs, err := j.events()
+	if err != nil || len(evs) == 0 {
+		return 0, err
+	}
+	return evs[len(evs)-1].Seq, nil
+}
+
+// watchers delivers the events of a filesystem to subscribers, and records
+// them in its journal.
+type watchers struct {
+	lk      sync.Mutex
+	subs    map[int]func(Event)
+	nextID  int
+	seq     uint64
+	journal Journal
+}
+
+// active reports whether events are delivered or recorded, so they need to
+// be built.
+func (w *watchers) active() bool {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	return len(w.subs) > 0 || w.journal != nil
+}
+
+func (w *watchers) emit(op Op, p string, old, nw *cid.Cid) {
+	w.lk.Lock()
+	if len(w.subs) == 0 && w.journal == nil {
+		w.lk.Unlock()
+		return
+	}
+
+	w.seq++
+	ev := Event{
+		Seq:  w.seq,
+		Time: time.Now(),
+		Op:   op,
+		Path: p,
+		Old:  old,
+		New:  nw,
+	}
+	if w.journal != nil {
+		if err := w.journal.Append(ev); err != nil {
+			log.Errorf("recording mfs event: %s", err)
+		}
+	}
+	subs := make([]func(Event), 0, len(w.subs))
+	for _, f := range w.subs {
+		subs = append(subs, f)
+	}
+	w.lk.Unlock()
+
+	for _, f := range subs {
+		f(ev)
+	}
+}
+
+// Subscribe registers f to be called with every change of the filesystem
+// until cancel is called. f must not block, nor access the filesystem.
+func (kr *Root) Subscribe(f func(Event)) (cancel func()) {
+	w := &kr.watchers
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	if w.subs == nil {
+		w.subs = make(map[int]func(Event))
+	}
+	id := w.nextID
+	w.nextID++
+	w.subs[id] = f
+	return func() {
+		w.lk.Lock()
+		delete(w.subs, id)
+		w.lk.Unlock()
+	}
+}
+
+// SetJournal makes the filesystem record its changes in j. The events are
+// numbered following the last one recorded in j.
+func (kr *Root) SetJournal(j Journal) error {
+	seq, err := j.LastSeq()
+	if err != nil {
+		return err
+	}
+
+	w := &kr.watchers
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	w.journal = j
+	if seq > w.seq {
+		w.seq = seq
+	}
+	return nil
+}
+
+// EventsSince returns the changes recorded in the journal of the filesystem
+// after the one numbered seq, oldest first. Older changes may have been
+// dropped from the journal.
+func (kr *Root) EventsSince(seq uint64) ([]Event, error) {
+	kr.watchers.lk.Lock()
+	j := kr.watchers.journal
+	kr.watchers.lk.Unlock()
+	if j == nil {
+		return nil, ErrNoJournal
+	}
+	return j.Since(seq)
+}
+
+// entryPath returns the path of the entry called name in d, from the root of
+// its filesystem, and the Root of the filesystem, if d is part of one.
+func (d *Directory) entryPath(name string) (string, *Root) {
+	p := name
+	cur := d
+	for {
+		switch parent := cur.parent.(type) {
+		case *Directory:
+			p = path.Join(cur.name, p)
+			cur = parent
+		case *Root:
+			return path.Join("/", p), parent
+		default:
+			return path.Join("/", p), nil
+		}
+	}
+}
+
+// emit emits the event of the change op of the entry called name in d, if
+// the filesystem is watched.
+func (d *Directory) emit(op Op, name string, old, nw *cid.Cid) {
+	p, root := d.entryPath(name)
+	if root != nil {
+		root.watchers.emit(op, p, old, nw)
+	}
+}
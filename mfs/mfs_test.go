@@ -1106,3 +1106,187 @@ func TestFileDescriptors(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dserv, rt := setupRoot(ctx, t)
+
+	journal := NewDatastoreJournal(ds.NewMapDatastore(), ds.NewKey("/journal"), 3)
+	if err := rt.SetJournal(journal); err != nil {
+		t.Fatal(err)
+	}
+
+	var lk sync.Mutex
+	var events []Event
+	stop := rt.Subscribe(func(ev Event) {
+		lk.Lock()
+		events = append(events, ev)
+		lk.Unlock()
+	})
+	defer stop()
+
+	rootdir := rt.GetValue().(*Directory)
+	dir, err := rootdir.Mkdir("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi := getRandFile(t, dserv, 1000)
+	if err := dir.AddChild("f", fi); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(rt, "/a/f", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rootdir.Unlink("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	lk.Lock()
+	defer lk.Unlock()
+	expected := []struct {
+		op   Op
+		path string
+	}{
+		{OpMkdir, "/a"},
+		{OpAdd, "/a/f"},
+		{OpWrite, "/a/f"},
+		{OpRemove, "/a"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, ev := range events {
+		if ev.Seq != uint64(i+1) || ev.Op != expected[i].op || ev.Path != expected[i].path {
+			t.Fatalf("expected event %d to be %s %s, got %d %s %s", i+1, expected[i].op, expected[i].path, ev.Seq, ev.Op, ev.Path)
+		}
+	}
+	if !events[1].New.Equals(fi.Cid()) || events[1].Old != nil {
+		t.Fatal("wrong cids in add event")
+	}
+	if !events[2].Old.Equals(fi.Cid()) || events[2].New == nil || events[2].New.Equals(fi.Cid()) {
+		t.Fatal("wrong cids in write event")
+	}
+	if events[3].Old == nil || events[3].New != nil {
+		t.Fatal("wrong cids in remove event")
+	}
+
+	// the journal keeps the last 3 events
+	past, err := rt.EventsSince(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(past) != 3 || past[0].Seq != 2 || past[2].Seq != 4 {
+		t.Fatalf("wrong events recorded: %v", past)
+	}
+	past, err = rt.EventsSince(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(past) != 1 || past[0].Op != OpRemove {
+		t.Fatalf("wrong events since 3: %v", past)
+	}
+
+	// a new root recording in the same journal numbers events after them
+	_, rt2 := setupRoot(ctx, t)
+	if err := rt2.SetJournal(journal); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt2.GetValue().(*Directory).Mkdir("b"); err != nil {
+		t.Fatal(err)
+	}
+	past, err = rt2.EventsSince(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(past) != 1 || past[0].Seq != 5 || past[0].Path != "/b" {
+		t.Fatalf("wrong events after reload: %v", past)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dserv, rt := setupRoot(ctx, t)
+
+	dstore := ds.NewMapDatastore()
+	if err := rt.SetQuotaStore(dstore, ds.NewKey("/quotas")); err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir := rt.GetValue().(*Directory)
+	dir, err := rootdir.Mkdir("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := dir.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.SetQuota("/a/", empty+3000); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dir.AddChild("f", getRandFile(t, dserv, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	used, err := dir.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used < empty+1000 {
+		t.Fatalf("expected usage to grow by the file added, got %d from %d", used, empty)
+	}
+	total, err := rootdir.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total < used {
+		t.Fatalf("expected the root to use at least %d bytes, got %d", used, total)
+	}
+
+	// over the quota of /a, but not below another directory
+	big := getRandFile(t, dserv, 2500)
+	if err := dir.AddChild("g", big); err != ErrQuotaExceeded {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if err := rootdir.AddChild("g", big); err != nil {
+		t.Fatal(err)
+	}
+
+	// growing a file over the quota fails on flush
+	fsn, err := Lookup(rt, "/a/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := fsn.(*File).Open(OpenWriteOnly, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write(make([]byte, 2500)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != ErrQuotaExceeded {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+
+	// quotas are kept in the store
+	_, rt2 := setupRoot(ctx, t)
+	if err := rt2.SetQuotaStore(dstore, ds.NewKey("/quotas")); err != nil {
+		t.Fatal(err)
+	}
+	qs := rt2.Quotas()
+	if len(qs) != 1 || qs[0].Path != "/a" || qs[0].Limit != empty+3000 {
+		t.Fatalf("wrong quotas loaded: %v", qs)
+	}
+
+	if err := rt.SetQuota("/a", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.AddChild("g", big); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,220 @@
+package mfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	gopath "path"
+	"sort"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ErrQuotaExceeded is returned by changes which would make a directory use
+// more space than its quota allows.
+var ErrQuotaExceeded = errors.New("directory quota exceeded")
+
+// quotas are the space limits set on the directories of a filesystem, by
+// path.
+type quotas struct {
+	lk     sync.Mutex
+	limits map[string]uint64
+
+	// dstore persists the limits under key, if set.
+	dstore ds.Datastore
+	key    ds.Key
+}
+
+// Quota describes the space limit set on a directory.
+type Quota struct {
+	Path  string
+	Limit uint64
+}
+
+// SetQuota limits the cumulative size of the directory at path, the size of
+// the DAG of its node, to limit bytes. A limit of 0 removes the quota.
+// Quotas are enforced when nodes are added to the directory or below it,
+// and when the changes to files below it are flushed. They stay attached
+// to the path when directories are moved.
+func (kr *Root) SetQuota(path string, limit uint64) error {
+	path = cleanQuotaPath(path)
+
+	q := &kr.quotas
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	if q.limits == nil {
+		q.limits = make(map[string]uint64)
+	}
+	if limit == 0 {
+		delete(q.limits, path)
+	} else {
+		q.limits[path] = limit
+	}
+	return q.save()
+}
+
+// Quotas returns the quotas set, sorted by path.
+func (kr *Root) Quotas() []Quota {
+	q := &kr.quotas
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	out := make([]Quota, 0, len(q.limits))
+	for p, l := range q.limits {
+		out = append(out, Quota{Path: p, Limit: l})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// quota returns the quota set on the directory at path, or 0.
+func (kr *Root) quota(path string) uint64 {
+	kr.quotas.lk.Lock()
+	defer kr.quotas.lk.Unlock()
+	return kr.quotas.limits[path]
+}
+
+// SetQuotaStore loads the quotas saved in dstore under key, replacing the
+// ones set, and saves them there from now on.
+func (kr *Root) SetQuotaStore(dstore ds.Datastore, key ds.Key) error {
+	limits := make(map[string]uint64)
+	val, err := dstore.Get(key)
+	switch err {
+	case nil:
+		data, ok := val.([]byte)
+		if !ok {
+			return fmt.Errorf("invalid quotas value in datastore")
+		}
+		if err := json.Unmarshal(data, &limits); err != nil {
+			return err
+		}
+	case ds.ErrNotFound:
+	default:
+		return err
+	}
+
+	q := &kr.quotas
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	q.limits = limits
+	q.dstore = dstore
+	q.key = key
+	return nil
+}
+
+// save persists the quotas, if they have a store. It must be called with the
+// lock taken.
+func (q *quotas) save() error {
+	if q.dstore == nil {
+		return nil
+	}
+	data, err := json.Marshal(q.limits)
+	if err != nil {
+		return err
+	}
+	return q.dstore.Put(q.key, data)
+}
+
+func cleanQuotaPath(path string) string {
+	return gopath.Clean("/" + path)
+}
+
+// dirPath returns the path of d from the root of its filesystem, and the
+// Root of the filesystem, if d is part of one.
+func (d *Directory) dirPath() (string, *Root) {
+	switch parent := d.parent.(type) {
+	case *Directory:
+		return parent.entryPath(d.name)
+	case *Root:
+		return "/", parent
+	default:
+		return "/", nil
+	}
+}
+
+// Usage returns the cumulative size of the directory: the size of the DAG of
+// its node, including the changes to its entries not flushed yet. It is
+// cached until the directory or its entries change.
+func (d *Directory) Usage() (uint64, error) {
+	d.usageLk.Lock()
+	if d.usageOK {
+		u := d.usage
+		d.usageLk.Unlock()
+		return u, nil
+	}
+	d.usageLk.Unlock()
+
+	nd, err := d.GetNode()
+	if err != nil {
+		return 0, err
+	}
+	u, err := nd.Size()
+	if err != nil {
+		return 0, err
+	}
+
+	d.usageLk.Lock()
+	d.usage = u
+	d.usageOK = true
+	d.usageLk.Unlock()
+	return u, nil
+}
+
+// invalidateUsage drops the usage cached for d and the directories it is
+// part of, after a change.
+func (d *Directory) invalidateUsage() {
+	for cur := d; cur != nil; {
+		cur.usageLk.Lock()
+		cur.usageOK = false
+		cur.usageLk.Unlock()
+
+		cur, _ = cur.parent.(*Directory)
+	}
+}
+
+// checkQuota returns ErrQuotaExceeded if growing d by grow bytes would make
+// it, or a directory it is part of, use more space than its quota. It must
+// be called without holding the lock of d.
+func (d *Directory) checkQuota(grow uint64) error {
+	if grow == 0 {
+		return nil
+	}
+	if _, root := d.dirPath(); root == nil || len(root.Quotas()) == 0 {
+		return nil
+	}
+
+	for cur := d; cur != nil; {
+		p, root := cur.dirPath()
+		if limit := root.quota(p); limit > 0 {
+			u, err := cur.Usage()
+			if err != nil {
+				return err
+			}
+			if u+grow > limit {
+				log.Debugf("%s: using %d of %d bytes, cannot grow by %d", p, u, limit, grow)
+				return ErrQuotaExceeded
+			}
+		}
+
+		cur, _ = cur.parent.(*Directory)
+	}
+	return nil
+}
+
+// sizeGrowth returns by how many bytes the cumulative size of nw exceeds the
+// one of old, or 0.
+func sizeGrowth(old, nw ipld.Node) uint64 {
+	before, err := old.Size()
+	if err != nil {
+		return 0
+	}
+	after, err := nw.Size()
+	if err != nil || after <= before {
+		return 0
+	}
+	return after - before
+}
@@ -60,6 +60,12 @@ type Root struct {
 
 	dserv ipld.DAGService
 
+	// watchers are notified of the changes of the filesystem.
+	watchers watchers
+
+	// quotas limit the space used by directories of the filesystem.
+	quotas quotas
+
 	Type string
 }
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	path "github.com/ipfs/go-ipfs/path"
+	tracing "github.com/ipfs/go-ipfs/tracing"
 
 	ds "github.com/ipfs/go-datastore"
 	isd "github.com/jbenet/go-is-domain"
@@ -81,14 +82,26 @@ func (ns *mpns) ResolveN(ctx context.Context, name string, depth int) (path.Path
 		return path.ParsePath("/ipfs/" + name)
 	}
 
-	return resolve(ctx, ns, name, depth, "/ipns/")
+	ctx, span := tracing.StartSpan(ctx, "Namesys.Resolve")
+	defer span.Finish()
+	span.SetAttribute("name", name)
+
+	p, err := resolve(ctx, ns, name, depth, "/ipns/")
+	span.SetError(err)
+	return p, err
 }
 
 // resolveOnce implements resolver.
-func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error) {
+func (ns *mpns) resolveOnce(ctx context.Context, name string) (_ path.Path, err error) {
 	if !strings.HasPrefix(name, "/ipns/") {
 		name = "/ipns/" + name
 	}
+	ctx, span := tracing.StartSpan(ctx, "Namesys.ResolveOnce")
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+	span.SetAttribute("name", name)
 	segments := strings.SplitN(name, "/", 4)
 	if len(segments) < 3 || segments[0] != "" {
 		log.Debugf("invalid name syntax for %s", name)
@@ -110,10 +123,11 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 	// 3. otherwise resolve through the "proquint" resolver
 	key := segments[2]
 
-	_, err := mh.FromB58String(key)
+	_, err = mh.FromB58String(key)
 	if err == nil {
 		res, ok := ns.resolvers["pubsub"]
 		if ok {
+			span.SetAttribute("resolver", "pubsub")
 			p, err := res.resolveOnce(ctx, key)
 			if err == nil {
 				return makePath(p)
@@ -122,6 +136,7 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 
 		res, ok = ns.resolvers["dht"]
 		if ok {
+			span.SetAttribute("resolver", "dht")
 			p, err := res.resolveOnce(ctx, key)
 			if err == nil {
 				return makePath(p)
@@ -134,6 +149,7 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 	if isd.IsDomain(key) {
 		res, ok := ns.resolvers["dns"]
 		if ok {
+			span.SetAttribute("resolver", "dns")
 			p, err := res.resolveOnce(ctx, key)
 			if err == nil {
 				return makePath(p)
@@ -145,6 +161,7 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 
 	res, ok := ns.resolvers["proquint"]
 	if ok {
+		span.SetAttribute("resolver", "proquint")
 		p, err := res.resolveOnce(ctx, key)
 		if err == nil {
 			return makePath(p)
@@ -245,6 +245,31 @@ func InitializeKeyspace(ctx context.Context, pub Publisher, pins pin.Pinner, key
 	return pub.Publish(ctx, key, path.FromCid(emptyDir.Cid()))
 }
 
+// GetPublished returns the value of the record for id last published by
+// this node, as kept in its datastore, or ds.ErrNotFound if it never
+// published one.
+func GetPublished(dstore ds.Datastore, id peer.ID) (path.Path, error) {
+	_, ipnskey := IpnsKeysForID(id)
+	ival, err := dstore.Get(dshelp.NewKeyFromBinary([]byte(ipnskey)))
+	if err != nil {
+		return "", err
+	}
+	val, ok := ival.([]byte)
+	if !ok {
+		return "", fmt.Errorf("unexpected type returned from datastore: %#v", ival)
+	}
+
+	dhtrec := new(dhtpb.Record)
+	if err := proto.Unmarshal(val, dhtrec); err != nil {
+		return "", err
+	}
+	e := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(dhtrec.GetValue(), e); err != nil {
+		return "", err
+	}
+	return path.Path(e.GetValue()), nil
+}
+
 func IpnsKeysForID(id peer.ID) (name, ipns string) {
 	namekey := "/pk/" + string(id)
 	ipnskey := "/ipns/" + string(id)
@@ -12,6 +12,7 @@ import (
 	dssync "github.com/ipfs/go-datastore/sync"
 	dshelp "github.com/ipfs/go-ipfs-ds-help"
 	mockrouting "github.com/ipfs/go-ipfs-routing/mock"
+	offroute "github.com/ipfs/go-ipfs-routing/offline"
 	ci "github.com/libp2p/go-libp2p-crypto"
 	peer "github.com/libp2p/go-libp2p-peer"
 	testutil "github.com/libp2p/go-testutil"
@@ -113,3 +114,34 @@ func TestRSAPublisher(t *testing.T) {
 func TestEd22519Publisher(t *testing.T) {
 	testNamekeyPublisher(t, ci.Ed25519, ds.ErrNotFound, false)
 }
+
+func TestGetPublished(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	priv, _, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetPublished(dstore, id); err != ds.ErrNotFound {
+		t.Fatalf("expected ErrNotFound before publishing, got %v", err)
+	}
+
+	value := path.Path("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	routing := offroute.NewOfflineRouter(dstore, priv)
+	if err := NewRoutingPublisher(routing, dstore).Publish(ctx, priv, value); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetPublished(dstore, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != value {
+		t.Fatalf("expected %s, got %s", value, got)
+	}
+}
@@ -268,6 +268,7 @@ func (r *PubsubResolver) resolveOnce(ctx context.Context, name string) (path.Pat
 
 		return "", ErrResolveFailed
 	}
+	RecordTTL(ctx, entryTTL(entry))
 
 	value, err := path.ParsePath(string(entry.GetValue()))
 	return value, err
@@ -136,3 +136,47 @@ func verifyCanResolve(r Resolver, name string, exp path.Path) error {
 
 	return nil
 }
+
+func TestResolveTTL(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
+
+	resolver := NewRoutingResolver(d, 0)
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	pubctx := context.WithValue(context.Background(), "ipns-publish-ttl", 10*time.Minute)
+	err = publisher.Publish(pubctx, privk, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, ttl := ContextWithTTL(context.Background())
+	if _, ok := ttl(); ok {
+		t.Fatal("expected no TTL before resolving")
+	}
+	if _, err := resolver.Resolve(ctx, id.Pretty()); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := ttl()
+	if !ok || got != 10*time.Minute {
+		t.Fatalf("expected a TTL of 10m, got %s (%t)", got, ok)
+	}
+
+	// a shorter TTL along the way wins
+	RecordTTL(ctx, time.Second)
+	RecordTTL(ctx, time.Hour)
+	if got, _ := ttl(); got != time.Second {
+		t.Fatalf("expected a TTL of 1s, got %s", got)
+	}
+}
@@ -27,7 +27,7 @@ type routingResolver struct {
 	cache *lru.Cache
 }
 
-func (r *routingResolver) cacheGet(name string) (path.Path, bool) {
+func (r *routingResolver) cacheGet(ctx context.Context, name string) (path.Path, bool) {
 	if r.cache == nil {
 		return "", false
 	}
@@ -44,6 +44,7 @@ func (r *routingResolver) cacheGet(name string) (path.Path, bool) {
 	}
 
 	if time.Now().Before(entry.eol) {
+		RecordTTL(ctx, time.Until(entry.eol))
 		return entry.val, true
 	}
 
@@ -57,20 +58,7 @@ func (r *routingResolver) cacheSet(name string, val path.Path, rec *pb.IpnsEntry
 		return
 	}
 
-	// if completely unspecified, just use one minute
-	ttl := DefaultResolverCacheTTL
-	if rec.Ttl != nil {
-		recttl := time.Duration(rec.GetTtl())
-		if recttl >= 0 {
-			ttl = recttl
-		}
-	}
-
-	cacheTil := time.Now().Add(ttl)
-	eol, ok := checkEOL(rec)
-	if ok && eol.Before(cacheTil) {
-		cacheTil = eol
-	}
+	cacheTil := time.Now().Add(entryTTL(rec))
 
 	r.cache.Add(name, cacheEntry{
 		val: val,
@@ -117,7 +105,7 @@ func (r *routingResolver) ResolveN(ctx context.Context, name string, depth int)
 // resolve SFS-like names.
 func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Path, error) {
 	log.Debugf("RoutingResolver resolving %s", name)
-	cached, ok := r.cacheGet(name)
+	cached, ok := r.cacheGet(ctx, name)
 	if ok {
 		return cached, nil
 	}
@@ -163,6 +151,7 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 		log.Debugf("RoutingResolver: could not unmarshal value for name %s: %s", name, err)
 		return "", err
 	}
+	RecordTTL(ctx, entryTTL(entry))
 
 	// check for old style record:
 	valh, err := mh.Cast(entry.GetValue())
@@ -0,0 +1,73 @@
+package namesys
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+)
+
+// ttlKey is the context key of the ttlRecorder of a resolution.
+type ttlKey struct{}
+
+// ttlRecorder holds the shortest TTL of the records a resolution went through.
+type ttlRecorder struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	set bool
+}
+
+// ContextWithTTL returns a context whose resolutions record the TTL of the
+// records they go through, and a function returning the shortest one: how long
+// the result of the resolutions can be cached for. The function returns false
+// when no record had a TTL, as with DNS records, whose TTL isn't known.
+func ContextWithTTL(ctx context.Context) (context.Context, func() (time.Duration, bool)) {
+	rec := new(ttlRecorder)
+	get := func() (time.Duration, bool) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.ttl, rec.set
+	}
+	return context.WithValue(ctx, ttlKey{}, rec), get
+}
+
+// RecordTTL records that the name resolved with ctx is valid for ttl, if ctx
+// was made by ContextWithTTL. Name systems call it for each record they
+// resolve through.
+func RecordTTL(ctx context.Context, ttl time.Duration) {
+	rec, ok := ctx.Value(ttlKey{}).(*ttlRecorder)
+	if !ok {
+		return
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !rec.set || ttl < rec.ttl {
+		rec.ttl = ttl
+		rec.set = true
+	}
+}
+
+// entryTTL returns how long the value of the record e can be cached for: its
+// TTL, or DefaultResolverCacheTTL if it has none, capped by its EOL.
+func entryTTL(e *pb.IpnsEntry) time.Duration {
+	// if completely unspecified, just use one minute
+	ttl := DefaultResolverCacheTTL
+	if e.Ttl != nil {
+		recttl := time.Duration(e.GetTtl())
+		if recttl >= 0 {
+			ttl = recttl
+		}
+	}
+
+	if eol, ok := checkEOL(e); ok {
+		if untilEOL := time.Until(eol); untilEOL < ttl {
+			ttl = untilEOL
+		}
+	}
+	return ttl
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
+	tracing "github.com/ipfs/go-ipfs/tracing"
 
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -22,7 +24,9 @@ var log = logging.Logger("pathresolv")
 var ErrNoComponents = errors.New(
 	"path must contain at least one component")
 
-// ErrNoLink is returned when a link is not found in a path
+// ErrNoLink is returned when a link is not found in a path. For nodes with
+// fields, like dag-cbor nodes, Name is the path of the missing field within
+// the node.
 type ErrNoLink struct {
 	Name string
 	Node *cid.Cid
@@ -34,6 +38,30 @@ func (e ErrNoLink) Error() string {
 	return fmt.Sprintf("no link named %q under %s", e.Name, e.Node.String())
 }
 
+// ErrNotLink is returned when a path leads to a value of a node which isn't
+// a link, where a node is expected.
+type ErrNotLink struct {
+	Name string
+	Node *cid.Cid
+}
+
+// Error implements the Error interface for ErrNotLink.
+func (e ErrNotLink) Error() string {
+	return fmt.Sprintf("%q under %s is not a link", e.Name, e.Node.String())
+}
+
+// ErrMissingBlock is returned when a block linked to from a path can't be
+// fetched.
+type ErrMissingBlock struct {
+	Cid *cid.Cid
+	Err error
+}
+
+// Error implements the Error interface for ErrMissingBlock.
+func (e ErrMissingBlock) Error() string {
+	return fmt.Sprintf("block %s of the path can't be fetched: %s", e.Cid, e.Err)
+}
+
 // Resolver provides path resolution to IPFS
 // It has a pointer to a DAGService, which is uses to resolve nodes.
 // TODO: now that this is more modular, try to unify this code with the
@@ -68,12 +96,12 @@ func (r *Resolver) ResolveToLastNode(ctx context.Context, fpath path.Path) (ipld
 	for len(p) > 0 {
 		val, rest, err := nd.Resolve(p)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, resolveError(nd, p, err)
 		}
 
 		switch val := val.(type) {
 		case *ipld.Link:
-			next, err := val.GetNode(ctx, r.DAG)
+			next, err := getNode(ctx, r.DAG, val)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -114,10 +142,14 @@ func ResolveSingle(ctx context.Context, ds ipld.NodeGetter, nd ipld.Node, names
 func (r *Resolver) ResolvePathComponents(ctx context.Context, fpath path.Path) ([]ipld.Node, error) {
 	evt := log.EventBegin(ctx, "resolvePathComponents", logging.LoggableMap{"fpath": fpath})
 	defer evt.Done()
+	ctx, span := tracing.StartSpan(ctx, "Resolver.ResolvePathComponents")
+	defer span.Finish()
+	span.SetAttribute("path", fpath.String())
 
 	h, parts, err := path.SplitAbsPath(fpath)
 	if err != nil {
 		evt.Append(logging.LoggableMap{"error": err.Error()})
+		span.SetError(err)
 		return nil, err
 	}
 
@@ -125,6 +157,7 @@ func (r *Resolver) ResolvePathComponents(ctx context.Context, fpath path.Path) (
 	nd, err := r.DAG.Get(ctx, h)
 	if err != nil {
 		evt.Append(logging.LoggableMap{"error": err.Error()})
+		span.SetError(err)
 		return nil, err
 	}
 
@@ -142,6 +175,10 @@ func (r *Resolver) ResolveLinks(ctx context.Context, ndd ipld.Node, names []stri
 
 	evt := log.EventBegin(ctx, "resolveLinks", logging.LoggableMap{"names": names})
 	defer evt.Done()
+	ctx, span := tracing.StartSpan(ctx, "Resolver.ResolveLinks")
+	defer span.Finish()
+	span.SetAttribute("names", strings.Join(names, "/"))
+
 	result := make([]ipld.Node, 0, len(names)+1)
 	result = append(result, ndd)
 	nd := ndd // dup arg workaround
@@ -153,17 +190,17 @@ func (r *Resolver) ResolveLinks(ctx context.Context, ndd ipld.Node, names []stri
 		defer cancel()
 
 		lnk, rest, err := r.ResolveOnce(ctx, r.DAG, nd, names)
-		if err == dag.ErrLinkNotFound {
-			evt.Append(logging.LoggableMap{"error": err.Error()})
-			return result, ErrNoLink{Name: names[0], Node: nd.Cid()}
-		} else if err != nil {
+		if err != nil {
+			err = resolveError(nd, names, err)
 			evt.Append(logging.LoggableMap{"error": err.Error()})
+			span.SetError(err)
 			return result, err
 		}
 
-		nextnode, err := lnk.GetNode(ctx, r.DAG)
+		nextnode, err := getNode(ctx, r.DAG, lnk)
 		if err != nil {
 			evt.Append(logging.LoggableMap{"error": err.Error()})
+			span.SetError(err)
 			return result, err
 		}
 
@@ -173,3 +210,40 @@ func (r *Resolver) ResolveLinks(ctx context.Context, ndd ipld.Node, names []stri
 	}
 	return result, nil
 }
+
+// getNode fetches the node lnk points to, reporting a missing block with
+// ErrMissingBlock.
+func getNode(ctx context.Context, ng ipld.NodeGetter, lnk *ipld.Link) (ipld.Node, error) {
+	nd, err := lnk.GetNode(ctx, ng)
+	if err == ipld.ErrNotFound {
+		return nil, ErrMissingBlock{Cid: lnk.Cid, Err: err}
+	}
+	return nd, err
+}
+
+// resolveError turns the error of resolving names in nd into an ErrNoLink
+// naming the first missing field, or an ErrNotLink if names lead to a value
+// which isn't a link.
+func resolveError(nd ipld.Node, names []string, err error) error {
+	switch err {
+	case ipld.ErrNotFound, context.Canceled, context.DeadlineExceeded:
+		return err
+	case dag.ErrLinkNotFound:
+		return ErrNoLink{Name: names[0], Node: nd.Cid()}
+	}
+	if _, ok := err.(ErrMissingBlock); ok {
+		return err
+	}
+
+	for i := 1; i <= len(names); i++ {
+		val, rest, rerr := nd.Resolve(names[:i])
+		if rerr != nil {
+			return ErrNoLink{Name: strings.Join(names[:i], "/"), Node: nd.Cid()}
+		}
+		if _, ok := val.(*ipld.Link); ok || len(rest) > 0 {
+			// the path leaves nd here, so the error is not about its fields
+			return err
+		}
+	}
+	return ErrNotLink{Name: strings.Join(names, "/"), Node: nd.Cid()}
+}
@@ -10,8 +10,10 @@ import (
 	path "github.com/ipfs/go-ipfs/path"
 	"github.com/ipfs/go-ipfs/path/resolver"
 
+	cid "github.com/ipfs/go-cid"
 	util "github.com/ipfs/go-ipfs-util"
 	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
 )
 
 func randNode() *merkledag.ProtoNode {
@@ -68,3 +70,66 @@ func TestRecurivePathResolution(t *testing.T) {
 			p.String(), key.String(), cKey.String()))
 	}
 }
+
+func TestFieldPathResolution(t *testing.T) {
+	ctx := context.Background()
+	dagService := dagmock.Mock()
+
+	leaf := randNode()
+	missing := randNode()
+	if err := dagService.Add(ctx, leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := cid.Prefix{MhType: mh.SHA2_256, MhLength: -1}
+	doc := fmt.Sprintf(`{"a": {"b": [{"c": {"/": "%s"}}, {"c": {"/": "%s"}}], "n": 1}}`, leaf.Cid(), missing.Cid())
+	root, err := merkledag.NewJSONNode([]byte(doc), prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dagService.Add(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+
+	r := resolver.NewBasicResolver(dagService)
+	resolve := func(segments ...string) (ipld.Node, error) {
+		p, err := path.FromSegments("/ipfs/", append([]string{root.Cid().String()}, segments...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.ResolvePath(ctx, p)
+	}
+
+	nd, err := resolve("a", "b", "0", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(leaf.Cid()) {
+		t.Fatalf("expected the leaf, got %s", nd.Cid())
+	}
+
+	_, err = resolve("a", "x", "c")
+	if e, ok := err.(resolver.ErrNoLink); !ok || e.Name != "a/x" {
+		t.Fatalf("expected ErrNoLink for a/x, got %#v", err)
+	}
+
+	_, err = resolve("a", "b", "2", "c")
+	if e, ok := err.(resolver.ErrNoLink); !ok || e.Name != "a/b/2" {
+		t.Fatalf("expected ErrNoLink for a/b/2, got %#v", err)
+	}
+
+	_, err = resolve("a", "n")
+	if _, ok := err.(resolver.ErrNotLink); !ok {
+		t.Fatalf("expected ErrNotLink, got %#v", err)
+	}
+
+	_, err = resolve("a", "b", "1", "c")
+	if e, ok := err.(resolver.ErrMissingBlock); !ok || !e.Cid.Equals(missing.Cid()) {
+		t.Fatalf("expected ErrMissingBlock, got %#v", err)
+	}
+
+	_, err = resolve("a", "b", "0", "c", "nope")
+	if e, ok := err.(resolver.ErrNoLink); !ok || !e.Node.Equals(leaf.Cid()) {
+		t.Fatalf("expected ErrNoLink under the leaf, got %#v", err)
+	}
+}
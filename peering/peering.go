@@ -0,0 +1,263 @@
+// Package peering keeps the node connected to a set of peers, such as the
+// other gateways or mirrors of an operator. Dropped connections are
+// reconnected with exponential backoff, and the peers are tagged so that the
+// connection manager doesn't close their connections.
+package peering
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("peering")
+
+const (
+	// connMgrTag tags the peers in the connection manager, with a value
+	// high enough for their connections to be closed last.
+	connMgrTag      = "ipfs-peering"
+	connMgrTagValue = 1 << 20
+
+	connectTimeout = 30 * time.Second
+
+	// defaultInitialBackoff is the delay before reconnecting to a peer
+	// after a failed attempt, doubled after each following one up to
+	// maxBackoff.
+	defaultInitialBackoff = 5 * time.Second
+	maxBackoff            = 10 * time.Minute
+)
+
+// ErrSelf is returned when adding the node itself as a peer.
+var ErrSelf = errors.New("can't peer with self")
+
+// Service maintains connections to its peers while started.
+type Service struct {
+	host host.Host
+
+	// initialBackoff is the first delay before reconnecting to a peer
+	initialBackoff time.Duration
+
+	lk      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	handles map[peer.ID]*handle
+}
+
+// handle keeps one peer connected.
+type handle struct {
+	id peer.ID
+
+	lk    sync.Mutex
+	addrs []ma.Multiaddr
+
+	// wake interrupts the wait of the handle, when the peer disconnects or
+	// its addresses change
+	wake   chan struct{}
+	cancel context.CancelFunc
+}
+
+// NewService returns a peering service for h. Call Start to start connecting
+// to the peers.
+func NewService(h host.Host) *Service {
+	return &Service{
+		host:           h,
+		initialBackoff: defaultInitialBackoff,
+		handles:        make(map[peer.ID]*handle),
+	}
+}
+
+// Start connects to the peers added, and the ones added later, until Stop.
+func (s *Service) Start() error {
+	s.lk.Lock()
+	if s.ctx != nil {
+		s.lk.Unlock()
+		return nil
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, h := range s.handles {
+		s.run(h)
+	}
+	s.lk.Unlock()
+
+	// outside of s.lk, which the notifications take
+	s.host.Network().Notify((*notifee)(s))
+	return nil
+}
+
+// Stop stops maintaining the connections. The peers are kept.
+func (s *Service) Stop() error {
+	s.lk.Lock()
+	if s.ctx == nil {
+		s.lk.Unlock()
+		return nil
+	}
+	s.cancel()
+	s.ctx, s.cancel = nil, nil
+	for _, h := range s.handles {
+		h.cancel = nil
+	}
+	s.lk.Unlock()
+
+	s.host.Network().StopNotify((*notifee)(s))
+	return nil
+}
+
+// AddPeer keeps the node connected to info.ID, at info.Addrs. Adding a peer
+// again replaces its addresses.
+func (s *Service) AddPeer(info pstore.PeerInfo) error {
+	if info.ID == s.host.ID() {
+		return ErrSelf
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.host.Peerstore().AddAddrs(info.ID, info.Addrs, pstore.PermanentAddrTTL)
+	s.host.ConnManager().TagPeer(info.ID, connMgrTag, connMgrTagValue)
+
+	if h, ok := s.handles[info.ID]; ok {
+		h.lk.Lock()
+		h.addrs = info.Addrs
+		h.lk.Unlock()
+		h.poke()
+		return nil
+	}
+
+	h := &handle{
+		id:    info.ID,
+		addrs: info.Addrs,
+		wake:  make(chan struct{}, 1),
+	}
+	s.handles[info.ID] = h
+	if s.ctx != nil {
+		s.run(h)
+	}
+	return nil
+}
+
+// RemovePeer stops keeping the node connected to id. Its connections are
+// left open, for the connection manager to close.
+func (s *Service) RemovePeer(id peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	h, ok := s.handles[id]
+	if !ok {
+		return
+	}
+	if h.cancel != nil {
+		h.cancel()
+	}
+	delete(s.handles, id)
+
+	s.host.ConnManager().UntagPeer(id, connMgrTag)
+	h.lk.Lock()
+	s.host.Peerstore().SetAddrs(id, h.addrs, pstore.TempAddrTTL)
+	h.lk.Unlock()
+}
+
+// ListPeers returns the peers, with the addresses they were added with.
+func (s *Service) ListPeers() []pstore.PeerInfo {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	out := make([]pstore.PeerInfo, 0, len(s.handles))
+	for _, h := range s.handles {
+		h.lk.Lock()
+		addrs := append([]ma.Multiaddr(nil), h.addrs...)
+		h.lk.Unlock()
+		out = append(out, pstore.PeerInfo{ID: h.id, Addrs: addrs})
+	}
+	return out
+}
+
+// run starts the loop of h. s.lk must be held.
+func (s *Service) run(h *handle) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	h.cancel = cancel
+	go s.loop(ctx, h)
+}
+
+// loop waits while the peer is connected, and reconnects to it otherwise.
+func (s *Service) loop(ctx context.Context, h *handle) {
+	backoff := s.initialBackoff
+	for {
+		if s.host.Network().Connectedness(h.id) == inet.Connected {
+			backoff = s.initialBackoff
+			select {
+			case <-h.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		h.lk.Lock()
+		info := pstore.PeerInfo{ID: h.id, Addrs: h.addrs}
+		h.lk.Unlock()
+
+		cctx, cancel := context.WithTimeout(ctx, connectTimeout)
+		err := s.host.Connect(cctx, info)
+		cancel()
+		if err == nil {
+			log.Debugf("connected to peer %s", h.id)
+			continue
+		}
+		log.Debugf("connecting to peer %s: %s", h.id, err)
+
+		// jitter keeps the peers of a network from reconnecting in step
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-h.wake:
+			t.Stop()
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (h *handle) poke() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notifee wakes up the handles of the peers which disconnect.
+type notifee Service
+
+func (nn *notifee) Disconnected(n inet.Network, c inet.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) == inet.Connected {
+		return
+	}
+	s := (*Service)(nn)
+	s.lk.Lock()
+	h, ok := s.handles[p]
+	s.lk.Unlock()
+	if ok {
+		h.poke()
+	}
+}
+
+func (nn *notifee) Listen(inet.Network, ma.Multiaddr)      {}
+func (nn *notifee) ListenClose(inet.Network, ma.Multiaddr) {}
+func (nn *notifee) Connected(inet.Network, inet.Conn)      {}
+func (nn *notifee) OpenedStream(inet.Network, inet.Stream) {}
+func (nn *notifee) ClosedStream(inet.Network, inet.Stream) {}
@@ -0,0 +1,64 @@
+package peering
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func waitConnected(t *testing.T, n inet.Network, info pstore.PeerInfo, connected bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for (n.Connectedness(info.ID) == inet.Connected) != connected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected connected to be %t", connected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mn, err := mocknet.FullMeshLinked(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1, h2 := mn.Hosts()[0], mn.Hosts()[1]
+	info := pstore.PeerInfo{ID: h2.ID(), Addrs: h2.Addrs()}
+
+	s := NewService(h1)
+	s.initialBackoff = 10 * time.Millisecond
+	if err := s.AddPeer(pstore.PeerInfo{ID: h1.ID()}); err != ErrSelf {
+		t.Fatalf("expected %s, got %v", ErrSelf, err)
+	}
+	if err := s.AddPeer(info); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	waitConnected(t, h1.Network(), info, true)
+
+	// dropped connections come back
+	if err := h1.Network().ClosePeer(h2.ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitConnected(t, h1.Network(), info, true)
+
+	if peers := s.ListPeers(); len(peers) != 1 || peers[0].ID != h2.ID() {
+		t.Fatalf("wrong peers: %v", peers)
+	}
+
+	// removed peers don't
+	s.RemovePeer(h2.ID())
+	if err := h1.Network().ClosePeer(h2.ID()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	waitConnected(t, h1.Network(), info, false)
+}
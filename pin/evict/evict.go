@@ -0,0 +1,166 @@
+// Package evict removes the least recently used unpinned blocks when the
+// repo grows too large, as a gentler alternative to a full garbage
+// collection.
+package evict
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("evict")
+
+// Result describes an eviction run.
+type Result struct {
+	Removed uint64 // number of blocks removed
+	Freed   uint64 // bytes of block data removed
+}
+
+// Evictor removes unpinned blocks in least recently used order.
+type Evictor struct {
+	bs      *Tracker
+	pinning pin.Pinner
+
+	// roots returns the roots of the blocks to keep besides the pinned
+	// ones, e.g. the files API root.
+	roots func() ([]*cid.Cid, error)
+}
+
+// New creates an evictor removing blocks from bs which aren't pinned by
+// pinning or reachable from the roots returned by roots.
+func New(bs *Tracker, pinning pin.Pinner, roots func() ([]*cid.Cid, error)) *Evictor {
+	return &Evictor{
+		bs:      bs,
+		pinning: pinning,
+		roots:   roots,
+	}
+}
+
+type candidate struct {
+	c    *cid.Cid
+	last time.Time
+}
+
+// Evict removes unpinned blocks, least recently used first, until at least
+// need bytes of block data were freed or no unpinned blocks are left. The
+// GC lock is only held to read the roots, and to mark what changed since
+// and remove the blocks once the DAGs were walked.
+func (e *Evictor) Evict(ctx context.Context, need uint64) (Result, error) {
+	var res Result
+
+	// walk the DAGs without counting it as accesses
+	raw := e.bs.GCBlockstore
+	ng := dag.NewDAGService(bserv.New(raw, offline.Exchange(raw)))
+	m := gc.NewMarker(e.pinning, ng, e.roots)
+
+	unlocker := e.bs.GCLock()
+	err := m.Snapshot()
+	unlocker.Unlock()
+	if err != nil {
+		return res, err
+	}
+
+	output := make(chan gc.Result)
+	var markErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range output {
+			if r.Error != nil && markErr == nil {
+				markErr = r.Error
+			}
+		}
+	}()
+	// markResult returns the error of a marking step, preferring the first
+	// one reported on output.
+	markResult := func(err error) error {
+		close(output)
+		<-done
+		if err != nil && markErr != nil {
+			return markErr
+		}
+		return err
+	}
+
+	if err := m.Mark(ctx, output); err != nil {
+		return res, markResult(err)
+	}
+
+	if err := e.bs.Flush(); err != nil {
+		return res, markResult(err)
+	}
+
+	keys, err := raw.AllKeysChan(ctx)
+	if err != nil {
+		return res, markResult(err)
+	}
+	var candidates []candidate
+	for k := range keys {
+		if m.Keep.Has(k) {
+			continue
+		}
+		last, err := e.bs.LastAccess(k)
+		if err != nil {
+			return res, markResult(err)
+		}
+		candidates = append(candidates, candidate{c: k, last: last})
+	}
+	if err := ctx.Err(); err != nil {
+		return res, markResult(err)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].last.Before(candidates[j].last)
+	})
+
+	unlocker = e.bs.GCLock()
+	defer unlocker.Unlock()
+
+	// the blocks pinned while marking are kept
+	if err := markResult(m.Remark(ctx, output)); err != nil {
+		return res, err
+	}
+
+	for _, cand := range candidates {
+		if res.Freed >= need {
+			break
+		}
+		if m.Keep.Has(cand.c) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		blk, err := raw.Get(cand.c)
+		if err == bstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		size := len(blk.RawData())
+		if err := e.bs.DeleteBlock(cand.c); err != nil {
+			if err == bstore.ErrNotFound {
+				continue
+			}
+			return res, err
+		}
+		res.Removed++
+		res.Freed += uint64(size)
+	}
+
+	log.Infof("evicted %d blocks, freeing %d bytes", res.Removed, res.Freed)
+	return res, nil
+}
@@ -0,0 +1,189 @@
+package evict
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func makeBlock(i int) blocks.Block {
+	// 10 bytes each
+	return blocks.NewBlock([]byte(fmt.Sprintf("block %04d", i)))
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	base := bstore.NewGCBlockstore(bstore.NewBlockstore(dstore), bstore.NewGCLocker())
+	clock := &testClock{t: time.Unix(1000000, 0)}
+	tracker := NewTracker(base, dstore)
+	tracker.now = clock.now
+
+	dserv := mdag.NewDAGService(bserv.New(tracker, offline.Exchange(tracker)))
+	pinner := pin.NewPinner(dstore, dserv, dserv)
+
+	pinned := mdag.NodeWithData([]byte("pinned"))
+	if err := dserv.Add(ctx, pinned); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinner.Pin(ctx, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+	kept := mdag.NodeWithData([]byte("files root"))
+	if err := dserv.Add(ctx, kept); err != nil {
+		t.Fatal(err)
+	}
+
+	var blks []blocks.Block
+	for i := 0; i < 4; i++ {
+		clock.advance(time.Hour)
+		b := makeBlock(i)
+		if err := tracker.Put(b); err != nil {
+			t.Fatal(err)
+		}
+		blks = append(blks, b)
+	}
+
+	// reading block 0 makes block 1 the least recently used one
+	clock.advance(time.Hour)
+	if _, err := tracker.Get(blks[0].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	// age the pinned and kept nodes, they must survive anyway
+	clock.advance(time.Hour)
+
+	e := New(tracker, pinner, func() ([]*cid.Cid, error) {
+		return []*cid.Cid{kept.Cid()}, nil
+	})
+	res, err := e.Evict(ctx, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 2 || res.Freed != 20 {
+		t.Fatalf("expected 2 blocks and 20 bytes evicted, got %d and %d", res.Removed, res.Freed)
+	}
+
+	for i, want := range []bool{true, false, false, true} {
+		has, err := base.Has(blks[i].Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != want {
+			t.Errorf("block %d: expected present=%t, got %t", i, want, has)
+		}
+	}
+	for _, c := range []*cid.Cid{pinned.Cid(), kept.Cid()} {
+		has, err := base.Has(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !has {
+			t.Errorf("%s should not have been evicted", c)
+		}
+	}
+
+	// the access index of evicted blocks is cleaned up
+	last, err := tracker.LastAccess(blks[1].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.IsZero() {
+		t.Fatalf("expected no access time for an evicted block, got %s", last)
+	}
+}
+
+func TestEvictKeepsPinsAddedWhileMarking(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	base := bstore.NewGCBlockstore(bstore.NewBlockstore(dstore), bstore.NewGCLocker())
+	tracker := NewTracker(base, dstore)
+	dserv := mdag.NewDAGService(bserv.New(tracker, offline.Exchange(tracker)))
+	pinner := pin.NewPinner(dstore, dserv, dserv)
+
+	pinnedLater := mdag.NodeWithData([]byte("pinned later"))
+	if err := dserv.Add(ctx, pinnedLater); err != nil {
+		t.Fatal(err)
+	}
+	garbage := makeBlock(0)
+	if err := tracker.Put(garbage); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	e := New(tracker, pinner, func() ([]*cid.Cid, error) {
+		calls++
+		if calls == 2 {
+			// pinned once the roots were read the first time
+			pinner.PinWithMode(pinnedLater.Cid(), pin.Recursive)
+		}
+		return nil, nil
+	})
+	res, err := e.Evict(ctx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the roots to be read twice, got %d", calls)
+	}
+	if res.Removed != 1 {
+		t.Fatalf("expected 1 block evicted, got %d", res.Removed)
+	}
+	if has, _ := base.Has(garbage.Cid()); has {
+		t.Fatal("the unpinned block wasn't evicted")
+	}
+	if has, _ := base.Has(pinnedLater.Cid()); !has {
+		t.Fatal("the block pinned while marking was evicted")
+	}
+}
+
+func TestTrackerPersistsAccessTimes(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	base := bstore.NewGCBlockstore(bstore.NewBlockstore(dstore), bstore.NewGCLocker())
+	clock := &testClock{t: time.Unix(2000000, 0)}
+	tracker := NewTracker(base, dstore)
+	tracker.now = clock.now
+
+	b := makeBlock(0)
+	if err := tracker.Put(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewTracker(base, dstore)
+	last, err := reopened.LastAccess(b.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.Equal(clock.t) {
+		t.Fatalf("expected access time %s, got %s", clock.t, last)
+	}
+}
@@ -0,0 +1,194 @@
+package evict
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+)
+
+// accessIndexKey is where the last access times are recorded.
+var accessIndexKey = ds.NewKey("/local/evict/atime")
+
+// accessResolution is how much an access time has to advance before it is
+// written to the index again, to keep the index writes down for hot blocks.
+const accessResolution = time.Minute
+
+// Tracker is a blockstore recording when each block was last read or
+// written, in an index next to the blocks.
+type Tracker struct {
+	bstore.GCBlockstore
+	index ds.Datastore
+
+	lk    sync.Mutex
+	last  map[string]time.Time // KeyString -> last access seen
+	dirty map[string]*cid.Cid
+
+	now func() time.Time
+}
+
+// NewTracker records the accesses to bs in index.
+func NewTracker(bs bstore.GCBlockstore, index ds.Datastore) *Tracker {
+	return &Tracker{
+		GCBlockstore: bs,
+		index:        index,
+		last:         make(map[string]time.Time),
+		dirty:        make(map[string]*cid.Cid),
+		now:          time.Now,
+	}
+}
+
+func accessKey(c *cid.Cid) ds.Key {
+	return accessIndexKey.Child(dshelp.CidToDsKey(c))
+}
+
+func (t *Tracker) touch(c *cid.Cid) {
+	now := t.now()
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	k := c.KeyString()
+	if prev, ok := t.last[k]; ok && now.Sub(prev) < accessResolution {
+		return
+	}
+	t.last[k] = now
+	t.dirty[k] = c
+}
+
+// Get implements Blockstore.
+func (t *Tracker) Get(c *cid.Cid) (blocks.Block, error) {
+	b, err := t.GCBlockstore.Get(c)
+	if err == nil {
+		t.touch(c)
+	}
+	return b, err
+}
+
+// Put implements Blockstore.
+func (t *Tracker) Put(b blocks.Block) error {
+	if err := t.GCBlockstore.Put(b); err != nil {
+		return err
+	}
+	t.touch(b.Cid())
+	return nil
+}
+
+// PutMany implements Blockstore.
+func (t *Tracker) PutMany(bs []blocks.Block) error {
+	if err := t.GCBlockstore.PutMany(bs); err != nil {
+		return err
+	}
+	for _, b := range bs {
+		t.touch(b.Cid())
+	}
+	return nil
+}
+
+// DeleteBlock implements Blockstore.
+func (t *Tracker) DeleteBlock(c *cid.Cid) error {
+	if err := t.GCBlockstore.DeleteBlock(c); err != nil {
+		return err
+	}
+
+	t.lk.Lock()
+	delete(t.last, c.KeyString())
+	delete(t.dirty, c.KeyString())
+	t.lk.Unlock()
+
+	if err := t.index.Delete(accessKey(c)); err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// LastAccess returns when c was last accessed, or the zero time if it
+// never was while tracked.
+func (t *Tracker) LastAccess(c *cid.Cid) (time.Time, error) {
+	t.lk.Lock()
+	last, ok := t.last[c.KeyString()]
+	t.lk.Unlock()
+	if ok {
+		return last, nil
+	}
+
+	val, err := t.index.Get(accessKey(c))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, err
+	}
+	b, ok := val.([]byte)
+	if !ok {
+		return time.Time{}, nil
+	}
+	secs, n := binary.Varint(b)
+	if n <= 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// Flush writes the access times recorded since the last flush to the index.
+// Access times are only kept in memory until they are flushed.
+func (t *Tracker) Flush() error {
+	t.lk.Lock()
+	dirty := t.dirty
+	t.dirty = make(map[string]*cid.Cid)
+	times := make(map[string]time.Time, len(dirty))
+	for k := range dirty {
+		times[k] = t.last[k]
+	}
+	t.lk.Unlock()
+
+	var buf [binary.MaxVarintLen64]byte
+	for k, c := range dirty {
+		n := binary.PutVarint(buf[:], times[k].Unix())
+		if err := t.index.Put(accessKey(c), append([]byte(nil), buf[:n]...)); err != nil {
+			// try again next time
+			t.lk.Lock()
+			for k, c := range dirty {
+				t.dirty[k] = c
+			}
+			t.lk.Unlock()
+			return err
+		}
+	}
+
+	// forget what is on disk now, unless it was accessed again meanwhile
+	t.lk.Lock()
+	for k := range dirty {
+		if _, again := t.dirty[k]; !again {
+			delete(t.last, k)
+		}
+	}
+	t.lk.Unlock()
+	return nil
+}
+
+// Run flushes the access times every period, and once more when ctx is
+// canceled.
+func (t *Tracker) Run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				log.Errorf("flushing block access times: %s", err)
+			}
+			return
+		}
+		if err := t.Flush(); err != nil {
+			log.Errorf("flushing block access times: %s", err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package gc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	// EventStarted is emitted once the garbage collection holds the lock.
+	EventStarted EventType = "started"
+
+	// EventMarked is emitted once the blocks to keep are marked.
+	EventMarked EventType = "marked"
+
+	// EventRemoved is emitted for every block removed.
+	EventRemoved EventType = "removed"
+
+	// EventFinished is emitted last, with the statistics of the run.
+	EventFinished EventType = "finished"
+)
+
+// Event describes the progress of a garbage collection.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Key is the block removed, for EventRemoved.
+	Key *cid.Cid `json:",omitempty"`
+
+	// Stats are the statistics of the run so far.
+	Stats Stats
+}
+
+// Stats are the statistics of a garbage collection run.
+type Stats struct {
+	Started time.Time
+
+	// Roots is the number of roots marked from, Marked the number of
+	// blocks marked.
+	Roots  int
+	Marked int
+
+	Removed uint64
+	Errors  int
+
+	// MarkDuration is the time spent marking, Duration the time of the
+	// whole run, when finished.
+	MarkDuration time.Duration
+	Duration     time.Duration
+}
+
+// Bus delivers the events of garbage collections to subscribers, and keeps
+// the statistics of the last run.
+type Bus struct {
+	lk     sync.Mutex
+	subs   map[int]func(Event)
+	nextID int
+	last   *Stats
+}
+
+// NewBus creates a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]func(Event))}
+}
+
+// Subscribe registers f to be called with every event until cancel is
+// called. f must not block.
+func (b *Bus) Subscribe(f func(Event)) (cancel func()) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = f
+	return func() {
+		b.lk.Lock()
+		delete(b.subs, id)
+		b.lk.Unlock()
+	}
+}
+
+// Last returns the statistics of the last finished run, or nil.
+func (b *Bus) Last() *Stats {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if b.last == nil {
+		return nil
+	}
+	last := *b.last
+	return &last
+}
+
+func (b *Bus) emit(ev Event) {
+	b.lk.Lock()
+	if ev.Type == EventFinished {
+		stats := ev.Stats
+		b.last = &stats
+	}
+	subs := make([]func(Event), 0, len(b.subs))
+	for _, f := range b.subs {
+		subs = append(subs, f)
+	}
+	b.lk.Unlock()
+
+	for _, f := range subs {
+		f(ev)
+	}
+}
+
+type busKey struct{}
+
+// WithEvents returns a context making the garbage collections run with it
+// emit their events on b.
+func WithEvents(ctx context.Context, b *Bus) context.Context {
+	return context.WithValue(ctx, busKey{}, b)
+}
+
+// reporter tracks the statistics of a run and emits its events, if the
+// context of the run carries a bus.
+type reporter struct {
+	bus   *Bus
+	stats Stats
+}
+
+func newReporter(ctx context.Context) *reporter {
+	b, _ := ctx.Value(busKey{}).(*Bus)
+	return &reporter{bus: b}
+}
+
+func (r *reporter) emit(t EventType, k *cid.Cid) {
+	if r.bus == nil {
+		return
+	}
+	r.bus.emit(Event{Type: t, Time: time.Now(), Key: k, Stats: r.stats})
+}
+
+func (r *reporter) started() {
+	r.stats.Started = time.Now()
+	r.emit(EventStarted, nil)
+}
+
+func (r *reporter) marked(roots, marked int) {
+	r.stats.Roots = roots
+	r.stats.Marked = marked
+	r.stats.MarkDuration = time.Since(r.stats.Started)
+	r.emit(EventMarked, nil)
+}
+
+func (r *reporter) removed(k *cid.Cid) {
+	r.stats.Removed++
+	r.emit(EventRemoved, k)
+}
+
+func (r *reporter) failed() {
+	r.stats.Errors++
+}
+
+func (r *reporter) finished() {
+	r.stats.Duration = time.Since(r.stats.Started)
+	r.emit(EventFinished, nil)
+}
@@ -27,6 +27,9 @@ var log = logging.Logger("gc")
 type Result struct {
 	KeyRemoved *cid.Cid
 	Error      error
+
+	// Size is the size of the data of KeyRemoved, only reported by DryRun.
+	Size uint64
 }
 
 // GC performs a mark and sweep garbage collection of the blocks in the blockstore
@@ -38,6 +41,8 @@ type Result struct {
 //
 // The routine then iterates over every block in the blockstore and
 // deletes any block that is not found in the marked set.
+//
+// The progress is emitted on the Bus of ctx, if set with WithEvents.
 func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, bestEffortRoots []*cid.Cid) <-chan Result {
 
 	elock := log.EventBegin(ctx, "GC.lockWait")
@@ -50,14 +55,25 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 	ds := dag.NewDAGService(bsrv)
 
 	output := make(chan Result, 128)
+	rep := newReporter(ctx)
+	rep.started()
 
 	go func() {
 		defer close(output)
+		defer rep.finished()
 		defer unlocker.Unlock()
 		defer elock.Done()
 
-		gcs, err := ColoredSet(ctx, pn, ds, bestEffortRoots, output)
+		roots := rootSet{
+			recursive:  pn.RecursiveKeys(),
+			bestEffort: bestEffortRoots,
+			direct:     pn.DirectKeys(),
+			internal:   pn.InternalPins(),
+		}
+		gcs := cid.NewSet()
+		err := roots.color(ctx, ds, gcs, output)
 		if err != nil {
+			rep.failed()
 			output <- Result{Error: err}
 			return
 		}
@@ -65,10 +81,12 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			"blackSetSize": fmt.Sprintf("%d", gcs.Len()),
 		})
 		emark.Done()
+		rep.marked(roots.len(), gcs.Len())
 		esweep := log.EventBegin(ctx, "GC.sweep")
 
 		keychan, err := bs.AllKeysChan(ctx)
 		if err != nil {
+			rep.failed()
 			output <- Result{Error: err}
 			return
 		}
@@ -88,11 +106,13 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 					removed++
 					if err != nil {
 						errors = true
+						rep.failed()
 						output <- Result{Error: &CannotDeleteBlockError{k, err}}
 						//log.Errorf("Error removing key from blockstore: %s", err)
 						// continue as error is non-fatal
 						continue loop
 					}
+					rep.removed(k)
 					select {
 					case output <- Result{KeyRemoved: k}:
 					case <-ctx.Done():
@@ -111,22 +131,209 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			output <- Result{Error: ErrCannotDeleteSomeBlocks}
 		}
 
-		defer log.EventBegin(ctx, "GC.datastore").Done()
-		gds, ok := dstor.(dstore.GCDatastore)
-		if !ok {
+		collectDatastore(ctx, dstor, output)
+	}()
+
+	return output
+}
+
+// ConcurrentGC performs the same garbage collection as GC without blocking
+// adds and pins while marking. It holds the GC lock twice, shortly: to
+// snapshot the roots when starting, and to mark what changed since and
+// sweep when done. The blocks written in between are recorded in j, which
+// must journal every write to bs, and are kept.
+//
+// roots is called under the GC lock and returns the best effort roots of
+// the moment, e.g. the files API root.
+func ConcurrentGC(ctx context.Context, bs bstore.GCBlockstore, j *Journal, dstor dstore.Datastore, pn pin.Pinner, roots func() ([]*cid.Cid, error)) <-chan Result {
+	output := make(chan Result, 128)
+
+	elock := log.EventBegin(ctx, "GC.lockWait")
+	unlocker := bs.GCLock()
+	elock.Done()
+
+	err := j.start()
+	if err != nil {
+		unlocker.Unlock()
+		output <- Result{Error: err}
+		close(output)
+		return output
+	}
+	snap, err := snapshotRoots(pn, roots)
+	unlocker.Unlock()
+	if err != nil {
+		j.stop()
+		output <- Result{Error: err}
+		close(output)
+		return output
+	}
+
+	bsrv := bserv.New(bs, offline.Exchange(bs))
+	ds := dag.NewDAGService(bsrv)
+
+	rep := newReporter(ctx)
+	rep.started()
+
+	go func() {
+		defer close(output)
+		defer rep.finished()
+		defer j.stop()
+
+		emark := log.EventBegin(ctx, "GC.mark")
+		gcs := cid.NewSet()
+		err := snap.color(ctx, ds, gcs, output)
+		if err != nil {
+			rep.failed()
+			output <- Result{Error: err}
+			return
+		}
+		emark.Append(logging.LoggableMap{
+			"blackSetSize": fmt.Sprintf("%d", gcs.Len()),
+		})
+		emark.Done()
+		rep.marked(snap.len(), gcs.Len())
+
+		// the blocks listed now are all older than the journal, the ones
+		// written from here on don't need to be considered
+		var candidates []*cid.Cid
+		keychan, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			rep.failed()
+			output <- Result{Error: err}
 			return
 		}
+		for k := range keychan {
+			if !gcs.Has(k) {
+				candidates = append(candidates, k)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		elock := log.EventBegin(ctx, "GC.lockWait")
+		unlocker := bs.GCLock()
+		elock.Done()
+		elock = log.EventBegin(ctx, "GC.locked")
 
-		err = gds.CollectGarbage()
+		eremark := log.EventBegin(ctx, "GC.remark")
+		now, err := snapshotRoots(pn, roots)
+		if err == nil {
+			err = now.since(snap).color(ctx, ds, gcs, output)
+		}
+		eremark.Done()
 		if err != nil {
+			unlocker.Unlock()
+			elock.Done()
+			rep.failed()
 			output <- Result{Error: err}
 			return
 		}
+
+		esweep := log.EventBegin(ctx, "GC.sweep")
+		errors := false
+		var removed uint64
+	loop:
+		for _, k := range candidates {
+			if gcs.Has(k) {
+				continue
+			}
+			deleted, err := j.deleteUnlessWritten(bs, k)
+			if err != nil {
+				errors = true
+				rep.failed()
+				output <- Result{Error: &CannotDeleteBlockError{k, err}}
+				continue
+			}
+			if !deleted {
+				continue
+			}
+			removed++
+			rep.removed(k)
+			select {
+			case output <- Result{KeyRemoved: k}:
+			case <-ctx.Done():
+				break loop
+			}
+		}
+		esweep.Append(logging.LoggableMap{
+			"whiteSetSize": fmt.Sprintf("%d", removed),
+		})
+		esweep.Done()
+		unlocker.Unlock()
+		elock.Done()
+		if errors {
+			output <- Result{Error: ErrCannotDeleteSomeBlocks}
+		}
+
+		collectDatastore(ctx, dstor, output)
 	}()
 
 	return output
 }
 
+// DryRun marks the blocks like GC does, and reports the blocks GC would
+// remove along with their size, without removing anything. It doesn't hold
+// the GC lock, so the result is an estimate: blocks added or unpinned
+// meanwhile may be reported or not.
+func DryRun(ctx context.Context, bs bstore.Blockstore, pn pin.Pinner, bestEffortRoots []*cid.Cid) <-chan Result {
+	bsrv := bserv.New(bs, offline.Exchange(bs))
+	ds := dag.NewDAGService(bsrv)
+
+	output := make(chan Result, 128)
+
+	go func() {
+		defer close(output)
+
+		gcs, err := ColoredSet(ctx, pn, ds, bestEffortRoots, output)
+		if err != nil {
+			output <- Result{Error: err}
+			return
+		}
+
+		keychan, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			output <- Result{Error: err}
+			return
+		}
+		for k := range keychan {
+			if gcs.Has(k) {
+				continue
+			}
+			b, err := bs.Get(k)
+			switch err {
+			case nil:
+			case bstore.ErrNotFound:
+				// removed since listed
+				continue
+			default:
+				output <- Result{Error: err}
+				continue
+			}
+			select {
+			case output <- Result{KeyRemoved: k, Size: uint64(len(b.RawData()))}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return output
+}
+
+func collectDatastore(ctx context.Context, dstor dstore.Datastore, output chan<- Result) {
+	defer log.EventBegin(ctx, "GC.datastore").Done()
+	gds, ok := dstor.(dstore.GCDatastore)
+	if !ok {
+		return
+	}
+
+	err := gds.CollectGarbage()
+	if err != nil {
+		output <- Result{Error: err}
+	}
+}
+
 // Descendants recursively finds all the descendants of the given roots and
 // adds them to the given cid.Set, using the provided dag.GetLinks function
 // to walk the tree.
@@ -171,8 +378,113 @@ func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots
 func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffortRoots []*cid.Cid, output chan<- Result) (*cid.Set, error) {
 	// KeySet currently implemented in memory, in the future, may be bloom filter or
 	// disk backed to conserve memory.
-	errors := false
 	gcs := cid.NewSet()
+	roots := rootSet{
+		recursive:  pn.RecursiveKeys(),
+		bestEffort: bestEffortRoots,
+		direct:     pn.DirectKeys(),
+		internal:   pn.InternalPins(),
+	}
+	err := roots.color(ctx, ng, gcs, output)
+	if err != nil {
+		return nil, err
+	}
+	return gcs, nil
+}
+
+// Marker marks the blocks to keep like ColoredSet in three steps, so that
+// the GC lock is only held while the roots are read and while marking what
+// changed meanwhile, not while walking the DAGs.
+type Marker struct {
+	pn    pin.Pinner
+	ng    ipld.NodeGetter
+	roots func() ([]*cid.Cid, error)
+	snap  rootSet
+
+	// Keep holds the blocks marked so far.
+	Keep *cid.Set
+}
+
+// NewMarker creates a marker of the blocks pinned by pn, or reachable from
+// the best effort roots returned by roots.
+func NewMarker(pn pin.Pinner, ng ipld.NodeGetter, roots func() ([]*cid.Cid, error)) *Marker {
+	return &Marker{pn: pn, ng: ng, roots: roots, Keep: cid.NewSet()}
+}
+
+// Snapshot records the roots to mark. It must be called with the GC lock
+// held.
+func (m *Marker) Snapshot() error {
+	snap, err := snapshotRoots(m.pn, m.roots)
+	if err != nil {
+		return err
+	}
+	m.snap = snap
+	return nil
+}
+
+// Mark marks the blocks reachable from the roots of the snapshot. It doesn't
+// need the GC lock.
+func (m *Marker) Mark(ctx context.Context, output chan<- Result) error {
+	return m.snap.color(ctx, m.ng, m.Keep, output)
+}
+
+// Remark marks the blocks reachable from the roots added since the
+// snapshot. It must be called with the GC lock held, after Mark; the blocks
+// not kept can then be removed until the lock is released.
+func (m *Marker) Remark(ctx context.Context, output chan<- Result) error {
+	now, err := snapshotRoots(m.pn, m.roots)
+	if err != nil {
+		return err
+	}
+	return now.since(m.snap).color(ctx, m.ng, m.Keep, output)
+}
+
+// rootSet holds the roots of a marking.
+type rootSet struct {
+	recursive  []*cid.Cid
+	bestEffort []*cid.Cid
+	direct     []*cid.Cid
+	internal   []*cid.Cid
+}
+
+func snapshotRoots(pn pin.Pinner, roots func() ([]*cid.Cid, error)) (rootSet, error) {
+	bestEffort, err := roots()
+	if err != nil {
+		return rootSet{}, err
+	}
+	return rootSet{
+		recursive:  pn.RecursiveKeys(),
+		bestEffort: bestEffort,
+		direct:     pn.DirectKeys(),
+		internal:   pn.InternalPins(),
+	}, nil
+}
+
+func (rs rootSet) len() int {
+	return len(rs.recursive) + len(rs.bestEffort) + len(rs.direct) + len(rs.internal)
+}
+
+// since returns the roots to mark again to account for the changes from old
+// to rs. Only the new recursive pins are kept, walking the other roots again
+// is cheap as their unchanged descendants are already marked.
+func (rs rootSet) since(old rootSet) rootSet {
+	seen := cid.NewSet()
+	for _, c := range old.recursive {
+		seen.Add(c)
+	}
+	var recursive []*cid.Cid
+	for _, c := range rs.recursive {
+		if !seen.Has(c) {
+			recursive = append(recursive, c)
+		}
+	}
+	rs.recursive = recursive
+	return rs
+}
+
+// color adds the roots and their descendants to gcs.
+func (rs rootSet) color(ctx context.Context, ng ipld.NodeGetter, gcs *cid.Set, output chan<- Result) error {
+	errors := false
 	getLinks := func(ctx context.Context, cid *cid.Cid) ([]*ipld.Link, error) {
 		links, err := ipld.GetLinks(ctx, ng, cid)
 		if err != nil {
@@ -181,7 +493,7 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffo
 		}
 		return links, nil
 	}
-	err := Descendants(ctx, getLinks, gcs, pn.RecursiveKeys())
+	err := Descendants(ctx, getLinks, gcs, rs.recursive)
 	if err != nil {
 		errors = true
 		output <- Result{Error: err}
@@ -195,27 +507,26 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffo
 		}
 		return links, nil
 	}
-	err = Descendants(ctx, bestEffortGetLinks, gcs, bestEffortRoots)
+	err = Descendants(ctx, bestEffortGetLinks, gcs, rs.bestEffort)
 	if err != nil {
 		errors = true
 		output <- Result{Error: err}
 	}
 
-	for _, k := range pn.DirectKeys() {
+	for _, k := range rs.direct {
 		gcs.Add(k)
 	}
 
-	err = Descendants(ctx, getLinks, gcs, pn.InternalPins())
+	err = Descendants(ctx, getLinks, gcs, rs.internal)
 	if err != nil {
 		errors = true
 		output <- Result{Error: err}
 	}
 
 	if errors {
-		return nil, ErrCannotFetchAllLinks
+		return ErrCannotFetchAllLinks
 	}
-
-	return gcs, nil
+	return nil
 }
 
 // ErrCannotFetchAllLinks is returned as the last Result in the GC output
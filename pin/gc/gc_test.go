@@ -0,0 +1,174 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestConcurrentGC(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	j := NewJournal()
+	bs := bstore.NewGCBlockstore(j.Blockstore(bstore.NewBlockstore(dstore)), bstore.NewGCLocker())
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pn := pin.NewPinner(dstore, dserv, dserv)
+
+	node := func(data string, children ...*dag.ProtoNode) *dag.ProtoNode {
+		nd := dag.NodeWithData([]byte(data))
+		for _, c := range children {
+			if err := nd.AddNodeLinkClean(c.Cid().String(), c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+
+	pinned := node("pinned", node("pinned child"))
+	if err := pn.Pin(ctx, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+	garbage := node("garbage")
+	pinnedLater := node("pinned later", node("pinned later child"))
+	writtenLater := node("written later")
+
+	calls := 0
+	roots := func() ([]*cid.Cid, error) {
+		calls++
+		if calls == 2 {
+			// changes made while marking
+			pn.PinWithMode(pinnedLater.Cid(), pin.Recursive)
+			if err := bs.Put(writtenLater); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return nil, nil
+	}
+
+	var removed []*cid.Cid
+	for res := range ConcurrentGC(ctx, bs, j, dstore, pn, roots) {
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		removed = append(removed, res.KeyRemoved)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected the roots to be read twice, got %d", calls)
+	}
+	if len(removed) != 1 || !removed[0].Equals(garbage.Cid()) {
+		t.Fatalf("expected only %s to be removed, got %v", garbage.Cid(), removed)
+	}
+	for _, nd := range []*dag.ProtoNode{pinned, pinnedLater, writtenLater} {
+		for _, l := range nd.Links() {
+			if has, _ := bs.Has(l.Cid); !has {
+				t.Fatalf("child %s was removed", l.Cid)
+			}
+		}
+		if has, _ := bs.Has(nd.Cid()); !has {
+			t.Fatalf("%s was removed", nd.Cid())
+		}
+	}
+
+	if err := j.start(); err != nil {
+		t.Fatal("journal wasn't stopped after gc:", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(dstore)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pn := pin.NewPinner(dstore, dserv, dserv)
+
+	pinned := dag.NodeWithData([]byte("pinned"))
+	garbage := dag.NodeWithData([]byte("some garbage"))
+	for _, nd := range []*dag.ProtoNode{pinned, garbage} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pn.Pin(ctx, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var count, size uint64
+	for res := range DryRun(ctx, bs, pn, nil) {
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if !res.KeyRemoved.Equals(garbage.Cid()) {
+			t.Fatalf("unexpected block %s", res.KeyRemoved)
+		}
+		count++
+		size += res.Size
+	}
+	if count != 1 || size != uint64(len(garbage.RawData())) {
+		t.Fatalf("expected 1 block of %d bytes, got %d of %d bytes", len(garbage.RawData()), count, size)
+	}
+	if has, _ := bs.Has(garbage.Cid()); !has {
+		t.Fatal("dry run removed a block")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(dstore), bstore.NewGCLocker())
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pn := pin.NewPinner(dstore, dserv, dserv)
+
+	pinned := dag.NodeWithData([]byte("pinned"))
+	for _, nd := range []*dag.ProtoNode{pinned, dag.NodeWithData([]byte("a")), dag.NodeWithData([]byte("b"))} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pn.Pin(ctx, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := NewBus()
+	var events []Event
+	cancel := bus.Subscribe(func(ev Event) {
+		events = append(events, ev)
+	})
+	defer cancel()
+
+	for res := range GC(WithEvents(ctx, bus), bs, dstore, pn, nil) {
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+	}
+
+	var types []EventType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	expected := []EventType{EventStarted, EventMarked, EventRemoved, EventRemoved, EventFinished}
+	if fmt.Sprint(types) != fmt.Sprint(expected) {
+		t.Fatalf("expected events %v, got %v", expected, types)
+	}
+
+	last := bus.Last()
+	if last == nil || last.Removed != 2 || last.Marked != 1 || last.Roots != 1 || last.Errors != 0 {
+		t.Fatalf("wrong statistics: %+v", last)
+	}
+}
@@ -0,0 +1,88 @@
+package gc
+
+import (
+	"errors"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// ErrAlreadyRunning is returned by ConcurrentGC when another concurrent
+// garbage collection is using the journal.
+var ErrAlreadyRunning = errors.New("garbage collection already running")
+
+// Journal records the blocks written while a concurrent garbage collection
+// is running, so its sweep doesn't remove blocks it didn't see when marking.
+type Journal struct {
+	lk sync.Mutex
+
+	// keys is nil while no garbage collection is running
+	keys *cid.Set
+}
+
+// NewJournal creates an idle journal.
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Blockstore wraps bs so the blocks put into it are recorded in the journal.
+// Every blockstore a concurrent garbage collection may race with must write
+// through a wrapped blockstore.
+func (j *Journal) Blockstore(bs bstore.Blockstore) bstore.Blockstore {
+	return &journalingBlockstore{bs, j}
+}
+
+func (j *Journal) start() error {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	if j.keys != nil {
+		return ErrAlreadyRunning
+	}
+	j.keys = cid.NewSet()
+	return nil
+}
+
+func (j *Journal) stop() {
+	j.lk.Lock()
+	j.keys = nil
+	j.lk.Unlock()
+}
+
+func (j *Journal) record(c *cid.Cid) {
+	j.lk.Lock()
+	if j.keys != nil {
+		j.keys.Add(c)
+	}
+	j.lk.Unlock()
+}
+
+// deleteUnlessWritten removes c from bs unless it was written since the
+// journal started. Writes are recorded before they reach the blockstore, so
+// a block can't be written between the check and the deletion.
+func (j *Journal) deleteUnlessWritten(bs bstore.Blockstore, c *cid.Cid) (bool, error) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	if j.keys != nil && j.keys.Has(c) {
+		return false, nil
+	}
+	return true, bs.DeleteBlock(c)
+}
+
+type journalingBlockstore struct {
+	bstore.Blockstore
+	j *Journal
+}
+
+func (bs *journalingBlockstore) Put(b blocks.Block) error {
+	bs.j.record(b.Cid())
+	return bs.Blockstore.Put(b)
+}
+
+func (bs *journalingBlockstore) PutMany(bls []blocks.Block) error {
+	for _, b := range bls {
+		bs.j.record(b.Cid())
+	}
+	return bs.Blockstore.PutMany(bls)
+}
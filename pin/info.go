@@ -0,0 +1,275 @@
+package pin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// linkInfo is the link of the pin set root to the pin information.
+const linkInfo = "info"
+
+// maxInfoChunk is the size above which the pin information is split into
+// another node.
+const maxInfoChunk = 256 << 10
+
+// PinInfo describes a direct or recursive pin.
+type PinInfo struct {
+	Cid  *cid.Cid
+	Mode Mode
+
+	// Name is a name assigned by the user, it doesn't have to be unique.
+	Name string
+
+	// Meta holds arbitrary labels.
+	Meta map[string]string
+
+	// Created is when the pin was added. It is zero for pins added before
+	// pins had this information.
+	Created time.Time
+}
+
+// copy returns a deep copy of pi with the given mode.
+func (pi *PinInfo) copy(mode Mode) *PinInfo {
+	out := *pi
+	out.Mode = mode
+	out.Meta = copyMeta(pi.Meta)
+	return &out
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	if meta == nil {
+		return nil
+	}
+	out := make(map[string]string, len(meta))
+	for k, v := range meta {
+		out[k] = v
+	}
+	return out
+}
+
+// Filter selects pins by their mode and information.
+type Filter struct {
+	// Mode is Recursive, Direct or Any.
+	Mode Mode
+
+	// Name matches pins of that name, if set.
+	Name string
+
+	// Meta matches pins having all of the given labels. An empty value
+	// matches any value of the label.
+	Meta map[string]string
+}
+
+func (f *Filter) matches(pi *PinInfo) bool {
+	if f.Mode != Any && f.Mode != pi.Mode {
+		return false
+	}
+	if f.Name != "" && f.Name != pi.Name {
+		return false
+	}
+	for k, v := range f.Meta {
+		pv, ok := pi.Meta[k]
+		if !ok || (v != "" && v != pv) {
+			return false
+		}
+	}
+	return true
+}
+
+type infoRecord struct {
+	Cid     string
+	Name    string            `json:",omitempty"`
+	Meta    map[string]string `json:",omitempty"`
+	Created time.Time
+}
+
+// storeInfo stores the pin information in chunks of JSON records linked from
+// the returned node.
+func storeInfo(ctx context.Context, dag ipld.DAGService, infos []*PinInfo, internalKeys keyObserver) (*mdag.ProtoNode, error) {
+	root := new(mdag.ProtoNode)
+
+	var chunk []byte
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		nd := mdag.NodeWithData(append(append([]byte("["), chunk...), ']'))
+		if err := dag.Add(ctx, nd); err != nil {
+			return err
+		}
+		internalKeys(nd.Cid())
+		if err := root.AddNodeLinkClean(strconv.Itoa(len(root.Links())), nd); err != nil {
+			return err
+		}
+		chunk = nil
+		return nil
+	}
+
+	for _, pi := range infos {
+		b, err := json.Marshal(&infoRecord{
+			Cid:     pi.Cid.String(),
+			Name:    pi.Name,
+			Meta:    pi.Meta,
+			Created: pi.Created,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) > 0 {
+			chunk = append(chunk, ',')
+		}
+		chunk = append(chunk, b...)
+		if len(chunk) >= maxInfoChunk {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if err := dag.Add(ctx, root); err != nil {
+		return nil, err
+	}
+	internalKeys(root.Cid())
+	return root, nil
+}
+
+// loadInfo loads the pin information linked from the pin set root, keyed by
+// the key strings of the cids. Pin sets written without it have none.
+func loadInfo(ctx context.Context, dag ipld.DAGService, root *mdag.ProtoNode, internalKeys keyObserver) (map[string]*PinInfo, error) {
+	infos := make(map[string]*PinInfo)
+
+	l, err := root.GetNodeLink(linkInfo)
+	if err == mdag.ErrLinkNotFound {
+		return infos, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	internalKeys(l.Cid)
+	nd, err := l.GetNode(ctx, dag)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cl := range nd.Links() {
+		internalKeys(cl.Cid)
+		chunk, err := cl.GetNode(ctx, dag)
+		if err != nil {
+			return nil, err
+		}
+		pn, ok := chunk.(*mdag.ProtoNode)
+		if !ok {
+			return nil, mdag.ErrNotProtobuf
+		}
+
+		var records []infoRecord
+		if err := json.Unmarshal(pn.Data(), &records); err != nil {
+			return nil, fmt.Errorf("invalid pin information: %s", err)
+		}
+		for _, r := range records {
+			c, err := cid.Decode(r.Cid)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pin information: %s", err)
+			}
+			infos[c.KeyString()] = &PinInfo{
+				Cid:     c,
+				Name:    r.Name,
+				Meta:    r.Meta,
+				Created: r.Created,
+			}
+		}
+	}
+	return infos, nil
+}
+
+// addInfo records the creation of the pin of c, unless it is known already.
+func (p *pinner) addInfo(c *cid.Cid) {
+	if _, ok := p.info[c.KeyString()]; !ok {
+		p.info[c.KeyString()] = &PinInfo{Cid: c, Created: time.Now()}
+	}
+}
+
+// mode returns the mode of the direct or recursive pin of c.
+func (p *pinner) mode(c *cid.Cid) (Mode, bool) {
+	switch {
+	case p.recursePin.Has(c):
+		return Recursive, true
+	case p.directPin.Has(c):
+		return Direct, true
+	default:
+		return NotPinned, false
+	}
+}
+
+// PinInfo implements Pinner.
+func (p *pinner) PinInfo(c *cid.Cid) (*PinInfo, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	mode, ok := p.mode(c)
+	if !ok {
+		return nil, ErrNotPinned
+	}
+	if info, ok := p.info[c.KeyString()]; ok {
+		return info.copy(mode), nil
+	}
+	return &PinInfo{Cid: c, Mode: mode}, nil
+}
+
+// Label implements Pinner.
+func (p *pinner) Label(c *cid.Cid, name string, meta map[string]string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.mode(c); !ok {
+		return ErrNotPinned
+	}
+	info, ok := p.info[c.KeyString()]
+	if !ok {
+		info = &PinInfo{Cid: c}
+		p.info[c.KeyString()] = info
+	}
+	info.Name = name
+	info.Meta = copyMeta(meta)
+	return nil
+}
+
+// Query implements Pinner.
+func (p *pinner) Query(f Filter) []*PinInfo {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	var out []*PinInfo
+	add := func(keys []*cid.Cid, mode Mode) {
+		for _, c := range keys {
+			info, ok := p.info[c.KeyString()]
+			if !ok {
+				info = &PinInfo{Cid: c}
+			}
+			info = info.copy(mode)
+			if f.matches(info) {
+				out = append(out, info)
+			}
+		}
+	}
+	add(p.recursePin.Keys(), Recursive)
+	add(p.directPin.Keys(), Direct)
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+	return out
+}
@@ -0,0 +1,110 @@
+package pin
+
+import (
+	"context"
+	"testing"
+
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestPinInfo(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+
+	a, ac := randNode()
+	b, bc := randNode()
+	c, cc := randNode()
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, c, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Label(ac, "photos", map[string]string{"owner": "alice", "year": "2018"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Label(bc, "notes", map[string]string{"owner": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	_, other := randNode()
+	if err := p.Label(other, "nope", nil); err != ErrNotPinned {
+		t.Fatalf("expected ErrNotPinned, got %v", err)
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	np, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := np.PinInfo(ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "photos" || info.Meta["year"] != "2018" || info.Mode != Recursive || info.Created.IsZero() {
+		t.Fatalf("pin information wasn't persisted: %+v", info)
+	}
+
+	for _, tc := range []struct {
+		f        Filter
+		expected int
+	}{
+		{Filter{Mode: Any}, 3},
+		{Filter{Mode: Recursive}, 2},
+		{Filter{Mode: Any, Meta: map[string]string{"owner": ""}}, 2},
+		{Filter{Mode: Any, Meta: map[string]string{"owner": "bob"}}, 1},
+		{Filter{Mode: Recursive, Meta: map[string]string{"owner": "bob"}}, 0},
+		{Filter{Mode: Any, Name: "photos"}, 1},
+	} {
+		if n := len(np.Query(tc.f)); n != tc.expected {
+			t.Errorf("%+v: expected %d pins, got %d", tc.f, tc.expected, n)
+		}
+	}
+
+	// the internal nodes holding the information must survive gc
+	info2, _ := np.PinInfo(cc)
+	if info2.Name != "" {
+		t.Fatalf("unexpected name %q", info2.Name)
+	}
+	if len(np.InternalPins()) < 2 {
+		t.Fatal("pin information nodes aren't internal pins")
+	}
+
+	d, dc := randNode()
+	if err := dserv.Add(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := np.Update(ctx, ac, dc, true); err != nil {
+		t.Fatal(err)
+	}
+	info, err = np.PinInfo(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "photos" {
+		t.Fatalf("updated pin should keep the name, got %q", info.Name)
+	}
+
+	if err := np.Unpin(ctx, bc, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(np.Query(Filter{Mode: Any, Name: "notes"})) != 0 {
+		t.Fatal("information of removed pin is still listed")
+	}
+}
@@ -0,0 +1,129 @@
+package pin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// ManifestVersion is the version of the manifests written by Export.
+const ManifestVersion = 1
+
+// ErrBadManifestSignature is returned when verifying a manifest whose
+// signature doesn't match its content.
+var ErrBadManifestSignature = errors.New("pin manifest signature doesn't match its content")
+
+// Manifest is a signed description of the direct and recursive pins of a
+// node, used to copy them to other nodes.
+type Manifest struct {
+	Version int
+	Created time.Time
+	Pins    []ManifestPin
+
+	// PublicKey is the key of the node which signed the manifest.
+	PublicKey []byte
+	Signature []byte `json:",omitempty"`
+}
+
+// ManifestPin is a pin of a manifest.
+type ManifestPin struct {
+	Cid     string
+	Mode    string
+	Name    string            `json:",omitempty"`
+	Meta    map[string]string `json:",omitempty"`
+	Created time.Time
+}
+
+// Export returns a manifest of the pins of p, with their information,
+// signed with sk.
+func Export(p Pinner, sk ci.PrivKey) (*Manifest, error) {
+	pk, err := ci.MarshalPublicKey(sk.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Version:   ManifestVersion,
+		Created:   time.Now().UTC(),
+		PublicKey: pk,
+	}
+	for _, info := range p.Query(Filter{Mode: Any}) {
+		mode, _ := ModeToString(info.Mode)
+		m.Pins = append(m.Pins, ManifestPin{
+			Cid:     info.Cid.String(),
+			Mode:    mode,
+			Name:    info.Name,
+			Meta:    info.Meta,
+			Created: info.Created,
+		})
+	}
+
+	data, err := m.signedData()
+	if err != nil {
+		return nil, err
+	}
+	m.Signature, err = sk.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// signedData returns the serialization of m covered by the signature.
+func (m *Manifest) signedData() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+	return json.Marshal(&unsigned)
+}
+
+// Verify checks the signature of m, and returns the ID of the node which
+// signed it.
+func (m *Manifest) Verify() (peer.ID, error) {
+	if m.Version != ManifestVersion {
+		return "", fmt.Errorf("unsupported pin manifest version %d", m.Version)
+	}
+	pk, err := ci.UnmarshalPublicKey(m.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := m.signedData()
+	if err != nil {
+		return "", err
+	}
+	ok, err := pk.Verify(data, m.Signature)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrBadManifestSignature
+	}
+	return peer.IDFromPublicKey(pk)
+}
+
+// PinInfos parses the pins of m.
+func (m *Manifest) PinInfos() ([]*PinInfo, error) {
+	out := make([]*PinInfo, 0, len(m.Pins))
+	for _, mp := range m.Pins {
+		c, err := cid.Decode(mp.Cid)
+		if err != nil {
+			return nil, err
+		}
+		mode, ok := StringToMode(mp.Mode)
+		if !ok || (mode != Recursive && mode != Direct) {
+			return nil, fmt.Errorf("invalid mode %q of pin %s", mp.Mode, mp.Cid)
+		}
+		out = append(out, &PinInfo{
+			Cid:     c,
+			Mode:    mode,
+			Name:    mp.Name,
+			Meta:    copyMeta(mp.Meta),
+			Created: mp.Created,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,91 @@
+package pin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+func TestManifest(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+
+	a, ac := randNode()
+	b, bc := randNode()
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Label(ac, "photos", map[string]string{"owner": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sk, pk, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Export(p, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the manifest must survive a round trip through its serialization
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m2 Manifest
+	if err := json.Unmarshal(data, &m2); err != nil {
+		t.Fatal(err)
+	}
+	id, err := m2.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := peer.IDFromPublicKey(pk); id != expected {
+		t.Fatalf("expected signer %s, got %s", expected, id)
+	}
+
+	infos, err := m2.PinInfos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 pins, got %d", len(infos))
+	}
+	for _, info := range infos {
+		switch {
+		case info.Cid.Equals(ac):
+			if info.Mode != Recursive || info.Name != "photos" || info.Meta["owner"] != "alice" {
+				t.Fatalf("wrong information for %s: %+v", ac, info)
+			}
+		case info.Cid.Equals(bc):
+			if info.Mode != Direct {
+				t.Fatalf("expected %s to be pinned directly", bc)
+			}
+		default:
+			t.Fatalf("unexpected pin %s", info.Cid)
+		}
+	}
+
+	m2.Pins[0].Name = "tampered"
+	if _, err := m2.Verify(); err != ErrBadManifestSignature {
+		t.Fatalf("expected ErrBadManifestSignature, got %v", err)
+	}
+}
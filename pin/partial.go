@@ -0,0 +1,332 @@
+package pin
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// partialPinsKey is where the partial pins are tracked, under their cid.
+var partialPinsKey = ds.NewKey("/local/partialpins")
+
+// DefaultPartialRetryInterval is how often the missing parts of partial pins
+// are fetched again.
+const DefaultPartialRetryInterval = 10 * time.Minute
+
+// partialFetchTimeout limits the time spent fetching the missing parts of a
+// partial pin per retry.
+const partialFetchTimeout = time.Minute
+
+// PartialPin is a best-effort pin of a DAG of which only some blocks are
+// available.
+type PartialPin struct {
+	Cid  *cid.Cid
+	Name string
+
+	// Missing are the roots of the missing parts of the DAG.
+	Missing []*cid.Cid
+
+	Created   time.Time
+	LastTried time.Time
+}
+
+type partialPinRecord struct {
+	Cid       string
+	Name      string `json:",omitempty"`
+	Missing   []string
+	Created   time.Time
+	LastTried time.Time
+}
+
+// PartialPins keeps the available blocks of DAGs which can't be fetched
+// entirely, and retries fetching the missing blocks in the background. Once
+// a DAG is complete, it is pinned recursively and its partial pin removed.
+//
+// The garbage collection must keep the blocks reachable from Roots.
+type PartialPins struct {
+	lk     sync.Mutex
+	dstore ds.Datastore
+	local  ipld.NodeGetter
+	fetch  ipld.DAGService
+	pinner Pinner
+	locker bstore.GCLocker
+
+	readOnly bool
+}
+
+// NewPartialPins tracks partial pins in d. local must only return locally
+// available nodes, fetch may fetch them from the network. Complete DAGs are
+// pinned to pinner, holding the pin lock of locker.
+func NewPartialPins(d ds.Datastore, local ipld.NodeGetter, fetch ipld.DAGService, pinner Pinner, locker bstore.GCLocker) *PartialPins {
+	return &PartialPins{
+		dstore: d,
+		local:  local,
+		fetch:  fetch,
+		pinner: pinner,
+		locker: locker,
+	}
+}
+
+// SetReadOnly makes pp refuse to change the partial pins: Add, Remove and
+// Retry return ErrReadOnly, while List and Roots still report them.
+func (pp *PartialPins) SetReadOnly() {
+	pp.readOnly = true
+}
+
+func partialPinKey(c *cid.Cid) ds.Key {
+	return partialPinsKey.ChildString(c.String())
+}
+
+// Add pins the locally available blocks of the DAG of c. If the DAG is
+// complete, c is pinned recursively right away and the returned pin has no
+// missing parts.
+func (pp *PartialPins) Add(ctx context.Context, c *cid.Cid, name string) (*PartialPin, error) {
+	if pp.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	defer pp.locker.PinLock().Unlock()
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	now := time.Now()
+	p := &PartialPin{Cid: c, Name: name, Created: now, LastTried: now}
+	_, err := pp.updateLocked(ctx, p)
+	return p, err
+}
+
+// updateLocked finds the missing parts of p, and either stores it or pins it
+// recursively if complete.
+func (pp *PartialPins) updateLocked(ctx context.Context, p *PartialPin) (bool, error) {
+	missing, err := pp.missing(ctx, p.Cid)
+	if err != nil {
+		return false, err
+	}
+	p.Missing = missing
+
+	if len(missing) == 0 {
+		nd, err := pp.local.Get(ctx, p.Cid)
+		if err != nil {
+			return false, err
+		}
+		if err := pp.pinner.Pin(ctx, nd, true); err != nil {
+			return false, err
+		}
+		if p.Name != "" {
+			if err := pp.pinner.Label(p.Cid, p.Name, nil); err != nil {
+				return false, err
+			}
+		}
+		if err := pp.pinner.Flush(); err != nil {
+			return false, err
+		}
+		err = pp.dstore.Delete(partialPinKey(p.Cid))
+		if err == ds.ErrNotFound {
+			err = nil
+		}
+		return true, err
+	}
+
+	rec := partialPinRecord{
+		Cid:       p.Cid.String(),
+		Name:      p.Name,
+		Created:   p.Created,
+		LastTried: p.LastTried,
+	}
+	for _, m := range missing {
+		rec.Missing = append(rec.Missing, m.String())
+	}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return false, err
+	}
+	return false, pp.dstore.Put(partialPinKey(p.Cid), data)
+}
+
+// missing returns the roots of the parts of the DAG of c which aren't
+// available locally.
+func (pp *PartialPins) missing(ctx context.Context, c *cid.Cid) ([]*cid.Cid, error) {
+	var missing []*cid.Cid
+	seen := cid.NewSet()
+	var walk func(c *cid.Cid) error
+	walk = func(c *cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+		nd, err := pp.local.Get(ctx, c)
+		switch err {
+		case nil:
+		case ipld.ErrNotFound:
+			missing = append(missing, c)
+			return nil
+		default:
+			return err
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return missing, walk(c)
+}
+
+// Remove removes the partial pin of c.
+func (pp *PartialPins) Remove(c *cid.Cid) error {
+	if pp.readOnly {
+		return ErrReadOnly
+	}
+
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	k := partialPinKey(c)
+	has, err := pp.dstore.Has(k)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ErrNotPinned
+	}
+	return pp.dstore.Delete(k)
+}
+
+// List returns the partial pins, oldest first.
+func (pp *PartialPins) List() ([]*PartialPin, error) {
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	res, err := pp.dstore.Query(dsq.Query{Prefix: partialPinsKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*PartialPin, 0, len(entries))
+	for _, e := range entries {
+		var rec partialPinRecord
+		if err := json.Unmarshal(e.Value.([]byte), &rec); err != nil {
+			return nil, err
+		}
+		c, err := cid.Decode(rec.Cid)
+		if err != nil {
+			return nil, err
+		}
+		p := &PartialPin{
+			Cid:       c,
+			Name:      rec.Name,
+			Created:   rec.Created,
+			LastTried: rec.LastTried,
+		}
+		for _, s := range rec.Missing {
+			m, err := cid.Decode(s)
+			if err != nil {
+				return nil, err
+			}
+			p.Missing = append(p.Missing, m)
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+	return out, nil
+}
+
+// Roots returns the roots of the partial pins, which the garbage collection
+// must keep the available descendants of.
+func (pp *PartialPins) Roots() ([]*cid.Cid, error) {
+	pins, err := pp.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*cid.Cid, len(pins))
+	for i, p := range pins {
+		out[i] = p.Cid
+	}
+	return out, nil
+}
+
+// Retry fetches the missing parts of the partial pins, and pins the DAGs
+// which are complete. It returns the pins completed.
+func (pp *PartialPins) Retry(ctx context.Context) ([]*cid.Cid, error) {
+	if pp.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	pins, err := pp.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []*cid.Cid
+	for _, p := range pins {
+		fctx, cancel := context.WithTimeout(ctx, partialFetchTimeout)
+		for _, m := range p.Missing {
+			if err := mdag.FetchGraph(fctx, m, pp.fetch); err != nil {
+				log.Debugf("partial pin %s: fetching %s: %s", p.Cid, m, err)
+			}
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return completed, ctx.Err()
+		}
+
+		complete, err := pp.retried(ctx, p)
+		if err != nil {
+			return completed, err
+		}
+		if complete {
+			completed = append(completed, p.Cid)
+		}
+	}
+	return completed, nil
+}
+
+func (pp *PartialPins) retried(ctx context.Context, p *PartialPin) (bool, error) {
+	defer pp.locker.PinLock().Unlock()
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	// it may have been removed meanwhile
+	has, err := pp.dstore.Has(partialPinKey(p.Cid))
+	if err != nil || !has {
+		return false, err
+	}
+	p.LastTried = time.Now()
+	return pp.updateLocked(ctx, p)
+}
+
+// Run retries the partial pins every interval until ctx is done.
+func (pp *PartialPins) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			completed, err := pp.Retry(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Errorf("retrying partial pins: %s", err)
+			}
+			for _, c := range completed {
+				log.Infof("partial pin %s is complete, pinned recursively", c)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package pin
+
+import (
+	"context"
+	"testing"
+
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestPartialPins(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+	pp := NewPartialPins(dstore, dserv, dserv, p, blockstore.NewGCLocker())
+
+	available, _ := randNode()
+	missing, _ := randNode()
+	root, _ := randNode()
+	if err := root.AddNodeLinkClean("available", available); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLinkClean("missing", missing); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*mdag.ProtoNode{root, available} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rc := root.Cid()
+
+	partial, err := pp.Add(ctx, rc, "intermittent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partial.Missing) != 1 || !partial.Missing[0].Equals(missing.Cid()) {
+		t.Fatalf("expected %s to be missing, got %v", missing.Cid(), partial.Missing)
+	}
+	if _, pinned, _ := p.IsPinnedWithType(rc, Recursive); pinned {
+		t.Fatal("incomplete dag was pinned recursively")
+	}
+
+	roots, err := pp.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(rc) {
+		t.Fatalf("expected %s as root, got %v", rc, roots)
+	}
+
+	// nothing changes until the missing part shows up
+	completed, err := pp.Retry(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 0 {
+		t.Fatal("incomplete dag reported complete")
+	}
+
+	if err := dserv.Add(ctx, missing); err != nil {
+		t.Fatal(err)
+	}
+	completed, err = pp.Retry(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 1 {
+		t.Fatal("complete dag wasn't upgraded")
+	}
+	info, err := p.PinInfo(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode != Recursive || info.Name != "intermittent" {
+		t.Fatalf("expected a recursive pin named intermittent, got %+v", info)
+	}
+	pins, err := pp.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Fatal("upgraded partial pin is still listed")
+	}
+	if err := pp.Remove(rc); err != ErrNotPinned {
+		t.Fatalf("expected ErrNotPinned, got %v", err)
+	}
+}
@@ -149,6 +149,17 @@ type Pinner interface {
 	// InternalPins returns all cids kept pinned for the internal state of the
 	// pinner
 	InternalPins() []*cid.Cid
+
+	// PinInfo returns the information on the direct or recursive pin of c,
+	// or ErrNotPinned.
+	PinInfo(c *cid.Cid) (*PinInfo, error)
+
+	// Label sets the name and the labels of the direct or recursive pin of
+	// c. It returns ErrNotPinned if there is none.
+	Label(c *cid.Cid, name string, meta map[string]string) error
+
+	// Query returns the direct and recursive pins matching f, oldest first.
+	Query(f Filter) []*PinInfo
 }
 
 // Pinned represents CID which has been pinned with a pinning strategy.
@@ -191,6 +202,10 @@ type pinner struct {
 	dserv       ipld.DAGService
 	internal    ipld.DAGService // dagservice used to store internal objects
 	dstore      ds.Datastore
+
+	// info holds the information on direct and recursive pins, keyed by
+	// cid key string.
+	info map[string]*PinInfo
 }
 
 // NewPinner creates a new pinner using the given datastore as a backend
@@ -206,6 +221,7 @@ func NewPinner(dstore ds.Datastore, serv, internal ipld.DAGService) Pinner {
 		dstore:      dstore,
 		internal:    internal,
 		internalPin: cid.NewSet(),
+		info:        make(map[string]*PinInfo),
 	}
 }
 
@@ -236,6 +252,7 @@ func (p *pinner) Pin(ctx context.Context, node ipld.Node, recurse bool) error {
 		}
 
 		p.recursePin.Add(c)
+		p.addInfo(c)
 	} else {
 		if _, err := p.dserv.Get(ctx, c); err != nil {
 			return err
@@ -246,6 +263,7 @@ func (p *pinner) Pin(ctx context.Context, node ipld.Node, recurse bool) error {
 		}
 
 		p.directPin.Add(c)
+		p.addInfo(c)
 	}
 	return nil
 }
@@ -268,11 +286,13 @@ func (p *pinner) Unpin(ctx context.Context, c *cid.Cid, recursive bool) error {
 	case "recursive":
 		if recursive {
 			p.recursePin.Remove(c)
+			delete(p.info, c.KeyString())
 			return nil
 		}
 		return fmt.Errorf("%s is pinned recursively", c)
 	case "direct":
 		p.directPin.Remove(c)
+		delete(p.info, c.KeyString())
 		return nil
 	default:
 		return fmt.Errorf("%s is pinned indirectly under %s", c, reason)
@@ -426,6 +446,9 @@ func (p *pinner) RemovePinWithMode(c *cid.Cid, mode Mode) {
 		// programmer error, panic OK
 		panic("unrecognized pin type")
 	}
+	if !p.directPin.Has(c) && !p.recursePin.Has(c) {
+		delete(p.info, c.KeyString())
+	}
 }
 
 func cidSetWithValues(cids []*cid.Cid) *cid.Set {
@@ -487,6 +510,11 @@ func LoadPinner(d ds.Datastore, dserv, internal ipld.DAGService) (Pinner, error)
 		p.directPin = cidSetWithValues(directKeys)
 	}
 
+	p.info, err = loadInfo(ctx, internal, rootpb, recordInternal)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load pin information: %v", err)
+	}
+
 	p.internalPin = internalset
 
 	// assign services
@@ -524,8 +552,18 @@ func (p *pinner) Update(ctx context.Context, from, to *cid.Cid, unpin bool) erro
 	}
 
 	p.recursePin.Add(to)
+	if _, ok := p.info[to.KeyString()]; !ok {
+		info := &PinInfo{Cid: to, Created: time.Now()}
+		if old, ok := p.info[from.KeyString()]; ok {
+			// the new pin takes over the name and labels
+			info.Name = old.Name
+			info.Meta = copyMeta(old.Meta)
+		}
+		p.info[to.KeyString()] = info
+	}
 	if unpin {
 		p.recursePin.Remove(from)
+		delete(p.info, from.KeyString())
 	}
 	return nil
 }
@@ -561,6 +599,22 @@ func (p *pinner) Flush() error {
 		}
 	}
 
+	{
+		var infos []*PinInfo
+		for _, info := range p.info {
+			if p.directPin.Has(info.Cid) || p.recursePin.Has(info.Cid) {
+				infos = append(infos, info)
+			}
+		}
+		n, err := storeInfo(ctx, p.internal, infos, recordInternal)
+		if err != nil {
+			return err
+		}
+		if err := root.AddNodeLink(linkInfo, n); err != nil {
+			return err
+		}
+	}
+
 	// add the empty node, its referenced by the pin sets but never created
 	err := p.internal.Add(ctx, new(mdag.ProtoNode))
 	if err != nil {
@@ -602,7 +656,10 @@ func (p *pinner) PinWithMode(c *cid.Cid, mode Mode) {
 		p.recursePin.Add(c)
 	case Direct:
 		p.directPin.Add(c)
+	default:
+		return
 	}
+	p.addInfo(c)
 }
 
 // hasChild recursively looks for a Cid among the children of a root Cid.
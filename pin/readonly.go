@@ -0,0 +1,51 @@
+package pin
+
+import (
+	"context"
+	"errors"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ErrReadOnly is returned when changing the pins of a read-only pinner.
+var ErrReadOnly = errors.New("pins are read-only")
+
+// NewReadOnlyPinner returns a pinner reporting the pins of p which refuses
+// to change them. PinWithMode and RemovePinWithMode are ignored, the Flush
+// following them returns ErrReadOnly.
+func NewReadOnlyPinner(p Pinner) Pinner {
+	return &readOnlyPinner{p}
+}
+
+type readOnlyPinner struct {
+	Pinner
+}
+
+func (p *readOnlyPinner) Pin(context.Context, ipld.Node, bool) error {
+	return ErrReadOnly
+}
+
+func (p *readOnlyPinner) Unpin(context.Context, *cid.Cid, bool) error {
+	return ErrReadOnly
+}
+
+func (p *readOnlyPinner) Update(context.Context, *cid.Cid, *cid.Cid, bool) error {
+	return ErrReadOnly
+}
+
+func (p *readOnlyPinner) Label(*cid.Cid, string, map[string]string) error {
+	return ErrReadOnly
+}
+
+func (p *readOnlyPinner) PinWithMode(c *cid.Cid, mode Mode) {
+	log.Warningf("not pinning %s, pins are read-only", c)
+}
+
+func (p *readOnlyPinner) RemovePinWithMode(c *cid.Cid, mode Mode) {
+	log.Warningf("not unpinning %s, pins are read-only", c)
+}
+
+func (p *readOnlyPinner) Flush() error {
+	return ErrReadOnly
+}
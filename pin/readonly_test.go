@@ -0,0 +1,85 @@
+package pin
+
+import (
+	"context"
+	"testing"
+
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestReadOnlyPinner(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+
+	a, ak := randNode()
+	if err := dserv.Add(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+
+	ro := NewReadOnlyPinner(p)
+	assertPinned(t, ro, ak, "read-only pinner should report existing pins")
+
+	b, bk := randNode()
+	if err := dserv.Add(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := ro.Pin(ctx, b, true); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := ro.Unpin(ctx, ak, true); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+
+	ro.PinWithMode(bk, Recursive)
+	if err := ro.Flush(); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	assertUnpinned(t, p, bk, "PinWithMode should be ignored")
+	assertPinned(t, p, ak, "Unpin should be ignored")
+}
+
+func TestReadOnlyPartialPins(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewReadOnlyPinner(NewPinner(dstore, dserv, dserv))
+	pp := NewPartialPins(dstore, dserv, dserv, p, blockstore.NewGCLocker())
+	pp.SetReadOnly()
+
+	root, _ := randNode()
+	missing, _ := randNode()
+	if err := root.AddNodeLinkClean("missing", missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.Add(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pp.Add(ctx, root.Cid(), ""); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := pp.Remove(root.Cid()); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if _, err := pp.Retry(ctx); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if roots, err := pp.Roots(); err != nil || len(roots) != 0 {
+		t.Fatalf("expected no partial pins to be stored, got %v, %v", roots, err)
+	}
+}
@@ -0,0 +1,330 @@
+package pin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	remote "github.com/ipfs/go-ipfs/pin/remote"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+// remotePinsKey is where the remote pins are tracked, under the name of
+// their service and their request id.
+var remotePinsKey = ds.NewKey("/local/remotepins")
+
+// ErrUnknownService is returned for a remote pinning service which isn't
+// configured.
+var ErrUnknownService = errors.New("unknown remote pinning service")
+
+// RemotePin is a pin requested from a remote pinning service.
+type RemotePin struct {
+	Service   string
+	RequestID string
+	Cid       *cid.Cid
+	Name      string
+	Status    remote.Status
+	Created   time.Time
+}
+
+type remotePinRecord struct {
+	RequestID string
+	Cid       string
+	Name      string
+	Status    remote.Status
+	Created   time.Time
+}
+
+// RemoteManager delegates pins to remote pinning services and tracks their
+// status locally, so that they can be listed without asking the services
+// and reconciled with the local pins.
+type RemoteManager struct {
+	lk       sync.Mutex
+	dstore   ds.Datastore
+	local    Pinner
+	services map[string]*remote.Client
+
+	// Origins, if set, returns the addresses sent along with new pins, so
+	// that services can fetch the data from us directly.
+	Origins func() []string
+}
+
+// NewRemoteManager returns a RemoteManager for the given services, tracking
+// their pins in d. Reconcile compares them with the pins of local.
+func NewRemoteManager(d ds.Datastore, local Pinner, services map[string]*remote.Client) *RemoteManager {
+	return &RemoteManager{
+		dstore:   d,
+		local:    local,
+		services: services,
+	}
+}
+
+// Services returns the names of the configured services.
+func (m *RemoteManager) Services() []string {
+	names := make([]string, 0, len(m.services))
+	for name := range m.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *RemoteManager) client(service string) (*remote.Client, error) {
+	c, ok := m.services[service]
+	if !ok {
+		return nil, fmt.Errorf("%s: %q", ErrUnknownService, service)
+	}
+	return c, nil
+}
+
+func (m *RemoteManager) recordKey(service, requestID string) ds.Key {
+	return remotePinsKey.ChildString(service).ChildString(requestID)
+}
+
+func (m *RemoteManager) put(service string, st *remote.PinStatus) (*RemotePin, error) {
+	c, err := cid.Decode(st.Pin.Cid)
+	if err != nil {
+		return nil, fmt.Errorf("service returned an invalid cid: %s", err)
+	}
+
+	b, err := json.Marshal(&remotePinRecord{
+		RequestID: st.RequestID,
+		Cid:       st.Pin.Cid,
+		Name:      st.Pin.Name,
+		Status:    st.Status,
+		Created:   st.Created,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := m.dstore.Put(m.recordKey(service, st.RequestID), b); err != nil {
+		return nil, err
+	}
+
+	return &RemotePin{
+		Service:   service,
+		RequestID: st.RequestID,
+		Cid:       c,
+		Name:      st.Pin.Name,
+		Status:    st.Status,
+		Created:   st.Created,
+	}, nil
+}
+
+func (m *RemoteManager) newPin(c *cid.Cid, name string) remote.Pin {
+	p := remote.Pin{Cid: c.String(), Name: name}
+	if m.Origins != nil {
+		p.Origins = m.Origins()
+	}
+	return p
+}
+
+// Add asks service to pin c under name and tracks the request.
+func (m *RemoteManager) Add(ctx context.Context, service string, c *cid.Cid, name string) (*RemotePin, error) {
+	client, err := m.client(service)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := client.Add(ctx, m.newPin(c, name))
+	if err != nil {
+		return nil, err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.put(service, st)
+}
+
+// Remove removes all tracked pins of c from service. It returns ErrNotPinned
+// if there are none.
+func (m *RemoteManager) Remove(ctx context.Context, service string, c *cid.Cid) error {
+	client, err := m.client(service)
+	if err != nil {
+		return err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	pins, err := m.list(service)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	for _, p := range pins {
+		if !p.Cid.Equals(c) {
+			continue
+		}
+		found = true
+		if err := m.remove(ctx, client, p); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return ErrNotPinned
+	}
+	return nil
+}
+
+func (m *RemoteManager) remove(ctx context.Context, client *remote.Client, p *RemotePin) error {
+	if err := client.Remove(ctx, p.RequestID); err != nil && !remote.IsNotFound(err) {
+		return err
+	}
+	return m.dstore.Delete(m.recordKey(p.Service, p.RequestID))
+}
+
+// List returns the tracked pins of service, as of the last Refresh.
+func (m *RemoteManager) List(service string) ([]*RemotePin, error) {
+	if _, err := m.client(service); err != nil {
+		return nil, err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.list(service)
+}
+
+func (m *RemoteManager) list(service string) ([]*RemotePin, error) {
+	svcKey := remotePinsKey.ChildString(service)
+	res, err := m.dstore.Query(dsq.Query{Prefix: svcKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make([]*RemotePin, 0, len(entries))
+	for _, e := range entries {
+		if !ds.RawKey(e.Key).Parent().Equal(svcKey) {
+			// a service whose name starts with ours
+			continue
+		}
+		var r remotePinRecord
+		if err := json.Unmarshal(e.Value.([]byte), &r); err != nil {
+			return nil, fmt.Errorf("invalid remote pin record %s: %s", e.Key, err)
+		}
+		c, err := cid.Decode(r.Cid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote pin record %s: %s", e.Key, err)
+		}
+		pins = append(pins, &RemotePin{
+			Service:   service,
+			RequestID: r.RequestID,
+			Cid:       c,
+			Name:      r.Name,
+			Status:    r.Status,
+			Created:   r.Created,
+		})
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].Created.After(pins[j].Created)
+	})
+	return pins, nil
+}
+
+// Refresh updates the status of the tracked pins of service. Pins the
+// service no longer knows are no longer tracked.
+func (m *RemoteManager) Refresh(ctx context.Context, service string) error {
+	client, err := m.client(service)
+	if err != nil {
+		return err
+	}
+
+	sts, err := client.List(ctx, remote.ListOptions{Status: remote.AllStatuses})
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*remote.PinStatus, len(sts))
+	for i := range sts {
+		byID[sts[i].RequestID] = &sts[i]
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	pins, err := m.list(service)
+	if err != nil {
+		return err
+	}
+	for _, p := range pins {
+		st, ok := byID[p.RequestID]
+		if !ok {
+			log.Debugf("remote pin %s of %s is gone from %s", p.RequestID, p.Cid, service)
+			if err := m.dstore.Delete(m.recordKey(service, p.RequestID)); err != nil {
+				return err
+			}
+			continue
+		}
+		if st.Status != p.Status {
+			if _, err := m.put(service, st); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reconcile makes service pin every recursively pinned cid of the local
+// pinner which isn't pinned by it yet, replacing failed pins. If unpin is
+// set, tracked pins of cids no longer pinned locally are removed from the
+// service. It returns the cids added and removed.
+func (m *RemoteManager) Reconcile(ctx context.Context, service string, unpin bool) (added, removed []*cid.Cid, err error) {
+	client, err := m.client(service)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	pins, err := m.list(service)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	local := cid.NewSet()
+	for _, c := range m.local.RecursiveKeys() {
+		local.Add(c)
+	}
+
+	remotes := cid.NewSet()
+	for _, p := range pins {
+		if p.Status == remote.Failed {
+			if err := m.remove(ctx, client, p); err != nil {
+				return added, removed, err
+			}
+			continue
+		}
+		if unpin && !local.Has(p.Cid) {
+			if err := m.remove(ctx, client, p); err != nil {
+				return added, removed, err
+			}
+			removed = append(removed, p.Cid)
+			continue
+		}
+		remotes.Add(p.Cid)
+	}
+
+	for _, c := range local.Keys() {
+		if remotes.Has(c) {
+			continue
+		}
+		st, err := client.Add(ctx, m.newPin(c, ""))
+		if err != nil {
+			return added, removed, err
+		}
+		if _, err := m.put(service, st); err != nil {
+			return added, removed, err
+		}
+		added = append(added, c)
+	}
+	return added, removed, nil
+}
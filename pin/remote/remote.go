@@ -0,0 +1,245 @@
+// Package remote is a client for the IPFS pinning service API, a REST API
+// offered by services which pin content on behalf of their users:
+//
+//	GET    {endpoint}/pins              list pin requests
+//	POST   {endpoint}/pins              add a pin request
+//	GET    {endpoint}/pins/{requestid}  get a pin request
+//	DELETE {endpoint}/pins/{requestid}  remove a pin request
+//
+// Requests are authenticated with a bearer token.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the status of a pin request.
+type Status string
+
+const (
+	// Queued pins are waiting to be processed by the service.
+	Queued Status = "queued"
+	// Pinning pins are being fetched by the service.
+	Pinning Status = "pinning"
+	// Pinned pins are stored by the service.
+	Pinned Status = "pinned"
+	// Failed pins couldn't be completed.
+	Failed Status = "failed"
+)
+
+// AllStatuses lists every Status, to list pin requests regardless of their
+// status. By default services only list pinned ones.
+var AllStatuses = []Status{Queued, Pinning, Pinned, Failed}
+
+// maxPageSize is the largest page the API allows.
+const maxPageSize = 1000
+
+// Pin is an object to be pinned by a service.
+type Pin struct {
+	Cid  string `json:"cid"`
+	Name string `json:"name,omitempty"`
+
+	// Origins are multiaddrs of peers known to have the data.
+	Origins []string `json:"origins,omitempty"`
+
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// PinStatus is the state of a pin request.
+type PinStatus struct {
+	RequestID string    `json:"requestid"`
+	Status    Status    `json:"status"`
+	Created   time.Time `json:"created"`
+	Pin       Pin       `json:"pin"`
+
+	// Delegates are multiaddrs of the service's peers fetching the data.
+	Delegates []string `json:"delegates"`
+
+	Info map[string]string `json:"info,omitempty"`
+}
+
+// ListOptions filters the pin requests listed. Empty fields don't filter.
+type ListOptions struct {
+	Cids   []string
+	Name   string
+	Status []Status
+	Before time.Time
+	After  time.Time
+
+	// Limit bounds the number of requests returned, zero lists all.
+	Limit int
+}
+
+// Error is an error returned by a service.
+type Error struct {
+	StatusCode int
+	Reason     string
+	Details    string
+}
+
+func (e *Error) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("pinning service: %s (%d): %s", e.Reason, e.StatusCode, e.Details)
+	}
+	return fmt.Sprintf("pinning service: %s (%d)", e.Reason, e.StatusCode)
+}
+
+// IsNotFound returns whether err says that a pin request doesn't exist.
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Client talks to a pinning service.
+type Client struct {
+	endpoint string
+	key      string
+	client   *http.Client
+}
+
+// NewClient returns a client for the service at endpoint, authenticating
+// with the access token key.
+func NewClient(endpoint, key string) *Client {
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		key:      key,
+		client:   &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Add asks the service to pin p.
+func (c *Client) Add(ctx context.Context, p Pin) (*PinStatus, error) {
+	var st PinStatus
+	if err := c.do(ctx, "POST", "/pins", nil, p, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Get returns the pin request with the given id.
+func (c *Client) Get(ctx context.Context, requestID string) (*PinStatus, error) {
+	var st PinStatus
+	if err := c.do(ctx, "GET", "/pins/"+url.PathEscape(requestID), nil, nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Remove removes the pin request with the given id, letting the service
+// drop the data.
+func (c *Client) Remove(ctx context.Context, requestID string) error {
+	return c.do(ctx, "DELETE", "/pins/"+url.PathEscape(requestID), nil, nil, nil)
+}
+
+type listResponse struct {
+	Count   int         `json:"count"`
+	Results []PinStatus `json:"results"`
+}
+
+// List returns the pin requests matching opts, newest first.
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]PinStatus, error) {
+	q := url.Values{}
+	if len(opts.Cids) > 0 {
+		q.Set("cid", strings.Join(opts.Cids, ","))
+	}
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+	}
+	if len(opts.Status) > 0 {
+		st := make([]string, len(opts.Status))
+		for i, s := range opts.Status {
+			st[i] = string(s)
+		}
+		q.Set("status", strings.Join(st, ","))
+	}
+	if !opts.After.IsZero() {
+		q.Set("after", opts.After.Format(time.RFC3339Nano))
+	}
+
+	before := opts.Before
+	var out []PinStatus
+	for {
+		limit := maxPageSize
+		if opts.Limit > 0 && opts.Limit-len(out) < limit {
+			limit = opts.Limit - len(out)
+		}
+		q.Set("limit", strconv.Itoa(limit))
+		if !before.IsZero() {
+			q.Set("before", before.Format(time.RFC3339Nano))
+		}
+
+		var page listResponse
+		if err := c.do(ctx, "GET", "/pins", q, nil, &page); err != nil {
+			return nil, err
+		}
+		out = append(out, page.Results...)
+
+		if len(page.Results) == 0 || len(page.Results) >= page.Count || len(out) == opts.Limit {
+			return out, nil
+		}
+		// the next page holds the requests created before the last one
+		before = page.Results[len(page.Results)-1].Created
+	}
+}
+
+type errorResponse struct {
+	Error struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	} `json:"error"`
+}
+
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, in, out interface{}) error {
+	u := c.endpoint + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		e := &Error{StatusCode: resp.StatusCode, Reason: resp.Status}
+		var er errorResponse
+		if json.NewDecoder(resp.Body).Decode(&er) == nil && er.Error.Reason != "" {
+			e.Reason = er.Error.Reason
+			e.Details = er.Error.Details
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,264 @@
+package pin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	remote "github.com/ipfs/go-ipfs/pin/remote"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// fakeService is an in memory pinning service.
+type fakeService struct {
+	lk     sync.Mutex
+	pins   map[string]*remote.PinStatus
+	nextID int
+}
+
+func newFakeService(t *testing.T) (*fakeService, *httptest.Server) {
+	f := &fakeService{pins: make(map[string]*remote.PinStatus)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"reason":"UNAUTHORIZED"}}`)
+			return
+		}
+
+		f.lk.Lock()
+		defer f.lk.Unlock()
+		id := strings.TrimPrefix(r.URL.Path, "/pins/")
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/pins":
+			var p remote.Pin
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				t.Error(err)
+			}
+			f.nextID++
+			st := &remote.PinStatus{
+				RequestID: strconv.Itoa(f.nextID),
+				Status:    remote.Queued,
+				Created:   time.Unix(int64(f.nextID), 0).UTC(),
+				Pin:       p,
+			}
+			f.pins[st.RequestID] = st
+			json.NewEncoder(w).Encode(st)
+		case r.Method == "GET" && r.URL.Path == "/pins":
+			f.list(w, r)
+		case r.Method == "DELETE" && f.pins[id] != nil:
+			delete(f.pins, id)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"reason":"NOT_FOUND"}}`)
+		}
+	}))
+	return f, srv
+}
+
+func (f *fakeService) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	var before time.Time
+	if b := q.Get("before"); b != "" {
+		before, _ = time.Parse(time.RFC3339Nano, b)
+	}
+
+	var all []remote.PinStatus
+	for _, st := range f.pins {
+		if strings.Contains(q.Get("status"), string(st.Status)) {
+			all = append(all, *st)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Created.After(all[j].Created) })
+
+	count := len(all)
+	var page []remote.PinStatus
+	for _, st := range all {
+		if !before.IsZero() && !st.Created.Before(before) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, st)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"count": count, "results": page})
+}
+
+func (f *fakeService) setStatus(s remote.Status) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for _, st := range f.pins {
+		st.Status = s
+	}
+}
+
+func newRemoteManager(endpoint string) (*RemoteManager, Pinner) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+
+	m := NewRemoteManager(dstore, p, map[string]*remote.Client{
+		"fake": remote.NewClient(endpoint, "secret"),
+	})
+	return m, p
+}
+
+func TestRemoteAddRemove(t *testing.T) {
+	ctx := context.Background()
+	f, srv := newFakeService(t)
+	defer srv.Close()
+	m, _ := newRemoteManager(srv.URL)
+
+	_, c := randNode()
+	rp, err := m.Add(ctx, "fake", c, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.Status != remote.Queued || !rp.Cid.Equals(c) || rp.Name != "test" {
+		t.Fatalf("unexpected pin %+v", rp)
+	}
+
+	f.setStatus(remote.Pinned)
+	if err := m.Refresh(ctx, "fake"); err != nil {
+		t.Fatal(err)
+	}
+	pins, err := m.List("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || pins[0].Status != remote.Pinned {
+		t.Fatalf("expected one pinned pin, got %+v", pins)
+	}
+
+	if err := m.Remove(ctx, "fake", c); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.pins) != 0 {
+		t.Fatal("pin wasn't removed from the service")
+	}
+	if err := m.Remove(ctx, "fake", c); err != ErrNotPinned {
+		t.Fatalf("expected ErrNotPinned, got %v", err)
+	}
+
+	if _, err := m.Add(ctx, "other", c, ""); err == nil {
+		t.Fatal("expected an error for an unknown service")
+	}
+}
+
+func TestRemoteUnauthorized(t *testing.T) {
+	_, srv := newFakeService(t)
+	defer srv.Close()
+
+	c := remote.NewClient(srv.URL, "wrong")
+	_, err := c.Add(context.Background(), remote.Pin{Cid: "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"})
+	rerr, ok := err.(*remote.Error)
+	if !ok || rerr.StatusCode != http.StatusUnauthorized || rerr.Reason != "UNAUTHORIZED" {
+		t.Fatalf("expected an unauthorized error, got %v", err)
+	}
+}
+
+func TestRemoteListPages(t *testing.T) {
+	ctx := context.Background()
+	_, srv := newFakeService(t)
+	defer srv.Close()
+	m, _ := newRemoteManager(srv.URL)
+
+	for i := 0; i < 5; i++ {
+		_, c := randNode()
+		if _, err := m.Add(ctx, "fake", c, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sts, err := m.services["fake"].List(ctx, remote.ListOptions{Status: remote.AllStatuses, Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sts) != 3 {
+		t.Fatalf("expected 3 pins, got %d", len(sts))
+	}
+
+	sts, err = m.services["fake"].List(ctx, remote.ListOptions{Status: remote.AllStatuses})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sts) != 5 {
+		t.Fatalf("expected 5 pins, got %d", len(sts))
+	}
+}
+
+func TestRemoteReconcile(t *testing.T) {
+	ctx := context.Background()
+	f, srv := newFakeService(t)
+	defer srv.Close()
+	m, p := newRemoteManager(srv.URL)
+
+	a, ac := randNode()
+	b, bc := randNode()
+	for _, nd := range []*mdag.ProtoNode{a, b} {
+		if err := p.Pin(ctx, nd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	added, removed, err := m.Reconcile(ctx, "fake", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 || len(removed) != 0 {
+		t.Fatalf("expected 2 added and none removed, got %v and %v", added, removed)
+	}
+
+	// nothing to do the second time
+	added, _, err = m.Reconcile(ctx, "fake", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 0 {
+		t.Fatalf("expected nothing added, got %v", added)
+	}
+
+	if err := p.Unpin(ctx, bc, true); err != nil {
+		t.Fatal(err)
+	}
+	_, removed, err = m.Reconcile(ctx, "fake", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || !removed[0].Equals(bc) {
+		t.Fatalf("expected %s removed, got %v", bc, removed)
+	}
+
+	// failed pins are requested again
+	f.setStatus(remote.Failed)
+	if err := m.Refresh(ctx, "fake"); err != nil {
+		t.Fatal(err)
+	}
+	added, _, err = m.Reconcile(ctx, "fake", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || !added[0].Equals(ac) {
+		t.Fatalf("expected %s added again, got %v", ac, added)
+	}
+	if len(f.pins) != 1 {
+		t.Fatalf("expected the failed pin to be replaced, service has %d pins", len(f.pins))
+	}
+}
@@ -0,0 +1,489 @@
+// Package replicate keeps pins replicated on a number of nodes among a set
+// of trusted peers, without a separate cluster daemon. The peers share a
+// ledger of the pins to replicate, along with the pins each of them holds,
+// and every peer pins the content it is responsible for.
+//
+// The peers responsible for a pin are the first ones, by rendezvous
+// hashing, among the peers heard from recently. Peers therefore agree on
+// who holds what without coordination, and pins move when peers come and
+// go. A peer only drops a pin once the peers responsible for it hold it.
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var log = logging.Logger("replicate")
+
+// DefaultInterval is how often the peers publish their state by default.
+const DefaultInterval = time.Minute
+
+// missedIntervals is the number of intervals after which a silent peer is
+// considered gone.
+const missedIntervals = 3
+
+var (
+	ledgerKey  = ds.NewKey("/local/replication/ledger")
+	holdingKey = ds.NewKey("/local/replication/holding")
+)
+
+// ErrNotReplicated is returned when removing a pin which isn't in the
+// ledger.
+var ErrNotReplicated = errors.New("not replicated")
+
+// Transport carries the messages of the peers, e.g. over pubsub.
+type Transport interface {
+	// Publish sends data to all peers.
+	Publish(data []byte) error
+
+	// Next returns the next message and its sender, possibly ourselves.
+	// The sender must be authenticated, e.g. by the signature of the
+	// message, as only the messages of trusted peers are taken.
+	Next(ctx context.Context) (peer.ID, []byte, error)
+}
+
+// Pinner pins the content the local node is responsible for.
+type Pinner interface {
+	Pin(ctx context.Context, c *cid.Cid, name string) error
+	Unpin(ctx context.Context, c *cid.Cid) error
+}
+
+// Options configures a Replicator.
+type Options struct {
+	// Self is the local peer.
+	Self peer.ID
+
+	// Peers are the trusted peers sharing the ledger, messages from other
+	// peers are ignored.
+	Peers []peer.ID
+
+	// Factor is the number of peers holding a pin when it isn't given.
+	Factor int
+
+	// Interval is how often the state is published and pins are
+	// reconciled.
+	Interval time.Duration
+}
+
+// entry is a pin of the ledger. The most recently updated entry of a cid
+// wins, removed entries are kept so that the removal propagates.
+type entry struct {
+	Cid     string
+	Name    string `json:",omitempty"`
+	Factor  int
+	Updated time.Time
+	Removed bool `json:",omitempty"`
+}
+
+// message is the state published by a peer.
+type message struct {
+	Ledger  []*entry
+	Holding []string
+}
+
+type peerState struct {
+	seen    time.Time
+	holding map[string]bool
+}
+
+// Status is the replication status of a pin.
+type Status struct {
+	Cid     *cid.Cid
+	Name    string
+	Factor  int
+	Holders []peer.ID
+}
+
+// Replicator keeps the pins of the ledger replicated.
+type Replicator struct {
+	opts    Options
+	tpt     Transport
+	pinner  Pinner
+	dstore  ds.Datastore
+	trusted map[peer.ID]bool
+	changed chan struct{}
+
+	lk      sync.Mutex
+	ledger  map[string]*entry
+	peers   map[peer.ID]*peerState
+	holding map[string]bool
+}
+
+// New creates a replicator sharing its ledger over tpt, and restores the
+// ledger and the local pins from d.
+func New(d ds.Datastore, tpt Transport, pinner Pinner, opts Options) (*Replicator, error) {
+	if opts.Factor < 1 {
+		opts.Factor = 1
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+
+	r := &Replicator{
+		opts:    opts,
+		tpt:     tpt,
+		pinner:  pinner,
+		dstore:  d,
+		trusted: map[peer.ID]bool{opts.Self: true},
+		changed: make(chan struct{}, 1),
+		ledger:  make(map[string]*entry),
+		peers:   make(map[peer.ID]*peerState),
+		holding: make(map[string]bool),
+	}
+	for _, p := range opts.Peers {
+		r.trusted[p] = true
+	}
+
+	var ledger []*entry
+	if err := r.load(ledgerKey, &ledger); err != nil {
+		return nil, err
+	}
+	for _, e := range ledger {
+		r.ledger[e.Cid] = e
+	}
+	var holding []string
+	if err := r.load(holdingKey, &holding); err != nil {
+		return nil, err
+	}
+	for _, c := range holding {
+		r.holding[c] = true
+	}
+	return r, nil
+}
+
+func (r *Replicator) load(k ds.Key, v interface{}) error {
+	data, err := r.dstore.Get(k)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+	b, ok := data.([]byte)
+	if !ok {
+		return errors.New("replication state isn't bytes")
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (r *Replicator) store(k ds.Key, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return r.dstore.Put(k, b)
+}
+
+// Add adds c to the ledger, to be held by factor peers, or by the default
+// number of peers if factor is 0.
+func (r *Replicator) Add(c *cid.Cid, name string, factor int) error {
+	if factor <= 0 {
+		factor = r.opts.Factor
+	}
+	return r.update(&entry{
+		Cid:     c.String(),
+		Name:    name,
+		Factor:  factor,
+		Updated: time.Now().UTC(),
+	})
+}
+
+// Remove removes c from the ledger, the peers holding it unpin it.
+func (r *Replicator) Remove(c *cid.Cid) error {
+	r.lk.Lock()
+	e, ok := r.ledger[c.String()]
+	r.lk.Unlock()
+	if !ok || e.Removed {
+		return ErrNotReplicated
+	}
+	return r.update(&entry{
+		Cid:     c.String(),
+		Updated: time.Now().UTC(),
+		Removed: true,
+	})
+}
+
+func (r *Replicator) update(e *entry) error {
+	r.lk.Lock()
+	r.ledger[e.Cid] = e
+	err := r.store(ledgerKey, r.ledgerLocked())
+	r.lk.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (r *Replicator) ledgerLocked() []*entry {
+	out := make([]*entry, 0, len(r.ledger))
+	for _, e := range r.ledger {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Cid < out[j].Cid })
+	return out
+}
+
+// Status returns the replication status of the pins of the ledger.
+func (r *Replicator) Status() []Status {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	var out []Status
+	for _, e := range r.ledgerLocked() {
+		if e.Removed {
+			continue
+		}
+		c, err := cid.Decode(e.Cid)
+		if err != nil {
+			continue
+		}
+		out = append(out, Status{
+			Cid:     c,
+			Name:    e.Name,
+			Factor:  e.Factor,
+			Holders: r.holdersLocked(e.Cid),
+		})
+	}
+	return out
+}
+
+// alivePeersLocked returns the peers heard from recently, and ourselves.
+func (r *Replicator) alivePeersLocked() []peer.ID {
+	out := []peer.ID{r.opts.Self}
+	deadline := time.Now().Add(-missedIntervals * r.opts.Interval)
+	for p, st := range r.peers {
+		if p != r.opts.Self && st.seen.After(deadline) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (r *Replicator) holdersLocked(c string) []peer.ID {
+	var out []peer.ID
+	for _, p := range r.alivePeersLocked() {
+		if p == r.opts.Self {
+			if r.holding[c] {
+				out = append(out, p)
+			}
+		} else if r.peers[p].holding[c] {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// responsible returns the factor peers among alive responsible for c.
+func responsible(c string, alive []peer.ID, factor int) []peer.ID {
+	score := func(p peer.ID) []byte {
+		h := sha256.Sum256([]byte(string(p) + c))
+		return h[:]
+	}
+	sorted := append([]peer.ID(nil), alive...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(score(sorted[i]), score(sorted[j])) < 0
+	})
+	if factor < len(sorted) {
+		sorted = sorted[:factor]
+	}
+	return sorted
+}
+
+// Run publishes the state and reconciles the local pins every interval,
+// and whenever the ledger changes, until ctx is done.
+func (r *Replicator) Run(ctx context.Context) {
+	go r.receive(ctx)
+
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		r.publish()
+		r.reconcile(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-r.changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Replicator) publish() {
+	r.lk.Lock()
+	msg := message{Ledger: r.ledgerLocked()}
+	for c := range r.holding {
+		msg.Holding = append(msg.Holding, c)
+	}
+	r.lk.Unlock()
+
+	data, err := json.Marshal(&msg)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if err := r.tpt.Publish(data); err != nil {
+		log.Warningf("publishing replication state: %s", err)
+	}
+}
+
+func (r *Replicator) receive(ctx context.Context) {
+	for {
+		from, data, err := r.tpt.Next(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warningf("receiving replication state: %s", err)
+			}
+			return
+		}
+		if from == r.opts.Self {
+			continue
+		}
+		if !r.trusted[from] {
+			log.Debugf("ignoring replication state from untrusted peer %s", from.Pretty())
+			continue
+		}
+
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Warningf("invalid replication state from %s: %s", from.Pretty(), err)
+			continue
+		}
+		if r.merge(from, &msg) {
+			select {
+			case r.changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// merge records the state of from, and returns whether the ledger changed.
+func (r *Replicator) merge(from peer.ID, msg *message) bool {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	st := &peerState{seen: time.Now(), holding: make(map[string]bool)}
+	for _, c := range msg.Holding {
+		st.holding[c] = true
+	}
+	_, known := r.peers[from]
+	r.peers[from] = st
+
+	changed := !known
+	for _, e := range msg.Ledger {
+		if cur, ok := r.ledger[e.Cid]; ok && !e.Updated.After(cur.Updated) {
+			continue
+		}
+		r.ledger[e.Cid] = e
+		changed = true
+	}
+	if changed {
+		if err := r.store(ledgerKey, r.ledgerLocked()); err != nil {
+			log.Errorf("storing replication ledger: %s", err)
+		}
+	}
+	return changed
+}
+
+// reconcile pins what we are responsible for, and unpins what we aren't
+// once the responsible peers hold it.
+func (r *Replicator) reconcile(ctx context.Context) {
+	type action struct {
+		c    *cid.Cid
+		name string
+		pin  bool
+	}
+
+	r.lk.Lock()
+	alive := r.alivePeersLocked()
+	var actions []action
+	for _, e := range r.ledger {
+		c, err := cid.Decode(e.Cid)
+		if err != nil {
+			continue
+		}
+		held := r.holding[e.Cid]
+		if e.Removed {
+			if held {
+				actions = append(actions, action{c: c})
+			}
+			continue
+		}
+
+		resp := responsible(e.Cid, alive, e.Factor)
+		mine := false
+		ready := true
+		for _, p := range resp {
+			if p == r.opts.Self {
+				mine = true
+			} else if !r.peers[p].holding[e.Cid] {
+				ready = false
+			}
+		}
+		switch {
+		case mine && !held:
+			actions = append(actions, action{c: c, name: e.Name, pin: true})
+		case !mine && held && ready:
+			actions = append(actions, action{c: c})
+		}
+	}
+	r.lk.Unlock()
+
+	for _, a := range actions {
+		if ctx.Err() != nil {
+			return
+		}
+		if a.pin {
+			if err := r.pinner.Pin(ctx, a.c, a.name); err != nil {
+				log.Warningf("replicating %s: %s", a.c, err)
+				continue
+			}
+		} else {
+			if err := r.pinner.Unpin(ctx, a.c); err != nil {
+				log.Warningf("dropping replica of %s: %s", a.c, err)
+				continue
+			}
+		}
+
+		r.lk.Lock()
+		if a.pin {
+			r.holding[a.c.String()] = true
+		} else {
+			delete(r.holding, a.c.String())
+		}
+		holding := make([]string, 0, len(r.holding))
+		for c := range r.holding {
+			holding = append(holding, c)
+		}
+		err := r.store(holdingKey, holding)
+		r.lk.Unlock()
+		if err != nil {
+			log.Errorf("storing replicas: %s", err)
+		}
+	}
+
+	if len(actions) > 0 {
+		// let the others know without waiting for the next interval
+		r.publish()
+	}
+}
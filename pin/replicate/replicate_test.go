@@ -0,0 +1,245 @@
+package replicate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	u "github.com/ipfs/go-ipfs-util"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+type hubMessage struct {
+	from peer.ID
+	data []byte
+}
+
+// hub delivers the messages of every peer to all peers still connected.
+type hub struct {
+	lk    sync.Mutex
+	peers map[peer.ID]chan hubMessage
+
+	// received counts the messages each peer received from each peer
+	received map[peer.ID]map[peer.ID]int
+}
+
+func (h *hub) count(to, from peer.ID) int {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return h.received[to][from]
+}
+
+type hubTransport struct {
+	h    *hub
+	self peer.ID
+}
+
+func (t *hubTransport) Publish(data []byte) error {
+	t.h.lk.Lock()
+	defer t.h.lk.Unlock()
+	if _, ok := t.h.peers[t.self]; !ok {
+		return fmt.Errorf("%s is disconnected", t.self)
+	}
+	for _, ch := range t.h.peers {
+		select {
+		case ch <- hubMessage{t.self, data}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (t *hubTransport) Next(ctx context.Context) (peer.ID, []byte, error) {
+	t.h.lk.Lock()
+	ch := t.h.peers[t.self]
+	t.h.lk.Unlock()
+	select {
+	case m := <-ch:
+		t.h.lk.Lock()
+		if t.h.received[t.self] == nil {
+			t.h.received[t.self] = make(map[peer.ID]int)
+		}
+		t.h.received[t.self][m.from]++
+		t.h.lk.Unlock()
+		return m.from, m.data, nil
+	case <-ctx.Done():
+		t.h.lk.Lock()
+		delete(t.h.peers, t.self)
+		t.h.lk.Unlock()
+		return "", nil, ctx.Err()
+	}
+}
+
+type fakePinner struct {
+	lk   sync.Mutex
+	pins map[string]bool
+}
+
+func (p *fakePinner) Pin(ctx context.Context, c *cid.Cid, name string) error {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.pins[c.KeyString()] = true
+	return nil
+}
+
+func (p *fakePinner) Unpin(ctx context.Context, c *cid.Cid) error {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	delete(p.pins, c.KeyString())
+	return nil
+}
+
+func (p *fakePinner) has(c *cid.Cid) bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.pins[c.KeyString()]
+}
+
+type testPeer struct {
+	r      *Replicator
+	pinner *fakePinner
+	cancel func()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// settle waits for each of the peers to have received two more messages
+// from each of the others and from senders: every peer has then gone
+// through a whole round since, publishing its state, reconciling its pins
+// and publishing again.
+func settle(t *testing.T, h *hub, peers []peer.ID, senders ...peer.ID) {
+	from := append(append([]peer.ID(nil), peers...), senders...)
+	start := make(map[peer.ID]map[peer.ID]int)
+	for _, to := range peers {
+		start[to] = make(map[peer.ID]int)
+		for _, f := range from {
+			start[to][f] = h.count(to, f)
+		}
+	}
+	waitFor(t, "the peers to settle", func() bool {
+		for _, to := range peers {
+			for _, f := range from {
+				if f != to && h.count(to, f) < start[to][f]+2 {
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
+
+func TestReplication(t *testing.T) {
+	h := &hub{
+		peers:    make(map[peer.ID]chan hubMessage),
+		received: make(map[peer.ID]map[peer.ID]int),
+	}
+	ids := []peer.ID{"peer-a", "peer-b", "peer-c", "peer-d"}
+	untrusted := peer.ID("peer-x")
+
+	var peers []*testPeer
+	for _, id := range append(ids, untrusted) {
+		h.peers[id] = make(chan hubMessage, 100)
+		pinner := &fakePinner{pins: make(map[string]bool)}
+		r, err := New(dssync.MutexWrap(ds.NewMapDatastore()), &hubTransport{h, id}, pinner, Options{
+			Self:     id,
+			Peers:    ids,
+			Factor:   2,
+			Interval: 20 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go r.Run(ctx)
+		peers = append(peers, &testPeer{r, pinner, cancel})
+	}
+	rogue := peers[len(peers)-1]
+	peers = peers[:len(peers)-1]
+
+	holders := func(c *cid.Cid) []*testPeer {
+		var out []*testPeer
+		for _, p := range peers {
+			if p.pinner.has(c) {
+				out = append(out, p)
+			}
+		}
+		return out
+	}
+
+	c := cid.NewCidV0(u.Hash([]byte("replicated")))
+	if err := peers[0].r.Add(c, "data", 0); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "2 replicas", func() bool {
+		return len(holders(c)) == 2 && len(peers[0].r.Status()[0].Holders) == 2
+	})
+	settle(t, h, ids)
+	if n := len(holders(c)); n != 2 {
+		t.Fatalf("expected the replicas to stay at 2, got %d", n)
+	}
+
+	// the pin moves when a holder leaves
+	gone := holders(c)[0]
+	gone.cancel()
+	waitFor(t, "a replacement replica", func() bool {
+		var n int
+		for _, p := range holders(c) {
+			if p != gone {
+				n++
+			}
+		}
+		return n == 2
+	})
+
+	// messages of untrusted peers are ignored
+	evil := cid.NewCidV0(u.Hash([]byte("evil")))
+	if err := rogue.r.Add(evil, "", 4); err != nil {
+		t.Fatal(err)
+	}
+	var live []peer.ID
+	for i, p := range peers {
+		if p != gone {
+			live = append(live, ids[i])
+		}
+	}
+	settle(t, h, live, untrusted)
+	if len(holders(evil)) != 0 {
+		t.Fatal("pin of an untrusted peer was replicated")
+	}
+	for _, p := range peers {
+		for _, st := range p.r.Status() {
+			if st.Cid.Equals(evil) {
+				t.Fatal("pin of an untrusted peer was added to the ledger")
+			}
+		}
+	}
+
+	if err := peers[1].r.Remove(c); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the replicas to be removed", func() bool {
+		for _, p := range holders(c) {
+			if p != gone {
+				return false
+			}
+		}
+		return true
+	})
+	if err := peers[1].r.Remove(c); err != ErrNotReplicated {
+		t.Fatalf("expected ErrNotReplicated, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package pin
+
+import (
+	"context"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// VerifyOptions configures VerifyPins.
+type VerifyOptions struct {
+	// Rehash re-hashes every block, to find corrupt blocks besides missing
+	// ones.
+	Rehash bool
+
+	// IncludeOk reports complete pins too, not only broken ones.
+	IncludeOk bool
+}
+
+// BadNode is a block referenced by a pin which is missing, corrupt or can't
+// be decoded.
+type BadNode struct {
+	Cid *cid.Cid
+	Err error
+}
+
+// VerifyResult is the result of verifying a recursive pin.
+type VerifyResult struct {
+	Cid      *cid.Cid
+	Ok       bool
+	BadNodes []BadNode
+}
+
+// VerifyPins walks the recursive pins of p and checks that every block they
+// reference is in bs, without fetching anything. One result per pin is sent
+// on the returned channel, which is closed when all pins are checked or ctx
+// is done. Blocks shared by several pins are checked once.
+func VerifyPins(ctx context.Context, p Pinner, bs bstore.Blockstore, opts VerifyOptions) <-chan VerifyResult {
+	visited := make(map[string]*VerifyResult)
+
+	bad := func(c *cid.Cid, err error) *VerifyResult {
+		res := &VerifyResult{Cid: c, BadNodes: []BadNode{{Cid: c, Err: err}}}
+		visited[c.KeyString()] = res
+		return res
+	}
+
+	var check func(c *cid.Cid) *VerifyResult
+	check = func(c *cid.Cid) *VerifyResult {
+		if res, ok := visited[c.KeyString()]; ok {
+			return res
+		}
+		if err := ctx.Err(); err != nil {
+			return &VerifyResult{Cid: c}
+		}
+
+		if err := verifcid.ValidateCid(c); err != nil {
+			return bad(c, err)
+		}
+
+		blk, err := bs.Get(c)
+		if err != nil {
+			return bad(c, err)
+		}
+		if opts.Rehash {
+			actual, err := c.Prefix().Sum(blk.RawData())
+			if err != nil {
+				return bad(c, err)
+			}
+			if !actual.Equals(c) {
+				return bad(c, bstore.ErrHashMismatch)
+			}
+		}
+		links, err := mdag.BlockLinks(blk)
+		if err != nil {
+			return bad(c, err)
+		}
+
+		res := &VerifyResult{Cid: c, Ok: true}
+		for _, l := range links {
+			child := check(l.Cid)
+			if !child.Ok {
+				res.Ok = false
+				res.BadNodes = append(res.BadNodes, child.BadNodes...)
+			}
+		}
+		visited[c.KeyString()] = res
+		return res
+	}
+
+	out := make(chan VerifyResult)
+	go func() {
+		defer close(out)
+		for _, c := range p.RecursiveKeys() {
+			res := check(c)
+			if ctx.Err() != nil {
+				return
+			}
+			if res.Ok && !opts.IncludeOk {
+				continue
+			}
+			select {
+			case out <- *res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
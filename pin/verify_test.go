@@ -0,0 +1,91 @@
+package pin
+
+import (
+	"context"
+	"testing"
+
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestVerifyPins(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+
+	// the child of the first pin goes missing, the child of the second is
+	// replaced by a decodable block with the wrong hash
+	var roots []*mdag.ProtoNode
+	var children []*mdag.ProtoNode
+	for i := 0; i < 3; i++ {
+		child, _ := randNode()
+		root, _ := randNode()
+		if err := root.AddNodeLinkClean("child", child); err != nil {
+			t.Fatal(err)
+		}
+		if err := dserv.Add(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Pin(ctx, root, true); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		children = append(children, child)
+	}
+
+	if err := bstore.DeleteBlock(children[0].Cid()); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := randNode()
+	corrupt, err := blocks.NewBlockWithCid(other.RawData(), children[1].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.DeleteBlock(corrupt.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.Put(corrupt); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(opts VerifyOptions) map[string]VerifyResult {
+		out := make(map[string]VerifyResult)
+		for r := range VerifyPins(ctx, p, bstore, opts) {
+			out[r.Cid.KeyString()] = r
+		}
+		return out
+	}
+
+	res := collect(VerifyOptions{})
+	if len(res) != 1 {
+		t.Fatalf("expected 1 broken pin without rehashing, got %d", len(res))
+	}
+	missing := res[roots[0].Cid().KeyString()]
+	if missing.Ok || len(missing.BadNodes) != 1 || !missing.BadNodes[0].Cid.Equals(children[0].Cid()) {
+		t.Fatalf("missing block not reported: %+v", missing)
+	}
+	if missing.BadNodes[0].Err != blockstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", missing.BadNodes[0].Err)
+	}
+
+	res = collect(VerifyOptions{Rehash: true, IncludeOk: true})
+	if len(res) != 3 {
+		t.Fatalf("expected 3 pins, got %d", len(res))
+	}
+	broken := res[roots[1].Cid().KeyString()]
+	if broken.Ok || broken.BadNodes[0].Err != blockstore.ErrHashMismatch {
+		t.Fatalf("corrupt block not reported: %+v", broken)
+	}
+	if !res[roots[2].Cid().KeyString()].Ok {
+		t.Fatal("complete pin reported as broken")
+	}
+}
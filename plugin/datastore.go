@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+)
+
+// PluginDatastore is an interface that can be implemented to add datastore
+// backends, which the Datastore.Spec of the config can refer to by type
+type PluginDatastore interface {
+	Plugin
+
+	DatastoreTypeName() string
+	DatastoreConfigParser() fsrepo.ConfigFromMap
+}
@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"github.com/ipfs/go-ipfs/core/coredag"
+	"github.com/ipfs/go-ipfs/merkledag"
 
 	ipld "github.com/ipfs/go-ipld-format"
 )
@@ -14,3 +15,12 @@ type PluginIPLD interface {
 	RegisterBlockDecoders(dec ipld.BlockDecoder) error
 	RegisterInputEncParsers(iec coredag.InputEncParsers) error
 }
+
+// PluginCodec is an interface that can be implemented to add named IPLD
+// codecs to the DAG layer, which nodes of can be decoded, traversed and
+// resolved
+type PluginCodec interface {
+	Plugin
+
+	Codecs() []merkledag.Codec
+}
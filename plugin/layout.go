@@ -0,0 +1,13 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-ipfs/importer"
+)
+
+// PluginLayout is an interface that can be implemented to add layouts the
+// importer can build the DAGs of files with, keyed by name
+type PluginLayout interface {
+	Plugin
+
+	Layouts() map[string]importer.Layout
+}
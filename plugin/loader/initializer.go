@@ -1,8 +1,14 @@
 package loader
 
 import (
+	"fmt"
+
 	"github.com/ipfs/go-ipfs/core/coredag"
+	"github.com/ipfs/go-ipfs/exchange/tracker"
+	"github.com/ipfs/go-ipfs/importer"
+	"github.com/ipfs/go-ipfs/merkledag"
 	"github.com/ipfs/go-ipfs/plugin"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	ipld "github.com/ipfs/go-ipld-format"
 )
@@ -24,6 +30,26 @@ func run(plugins []plugin.Plugin) error {
 		if err != nil {
 			return err
 		}
+
+		err = runCodecPlugin(pl)
+		if err != nil {
+			return err
+		}
+
+		err = runLayoutPlugin(pl)
+		if err != nil {
+			return err
+		}
+
+		err = runDatastorePlugin(pl)
+		if err != nil {
+			return err
+		}
+
+		err = runTrackerPlugin(pl)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -41,3 +67,61 @@ func runIPLDPlugin(pl plugin.Plugin) error {
 
 	return ipldpl.RegisterInputEncParsers(coredag.DefaultInputEncParsers)
 }
+
+func runCodecPlugin(pl plugin.Plugin) error {
+	codecpl, ok := pl.(plugin.PluginCodec)
+	if !ok {
+		return nil
+	}
+
+	for _, c := range codecpl.Codecs() {
+		err := merkledag.RegisterCodec(c)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %s", pl.Name(), err)
+		}
+	}
+	return nil
+}
+
+func runLayoutPlugin(pl plugin.Plugin) error {
+	layoutpl, ok := pl.(plugin.PluginLayout)
+	if !ok {
+		return nil
+	}
+
+	for name, l := range layoutpl.Layouts() {
+		err := importer.RegisterLayout(name, l)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %s", pl.Name(), err)
+		}
+	}
+	return nil
+}
+
+func runDatastorePlugin(pl plugin.Plugin) error {
+	dspl, ok := pl.(plugin.PluginDatastore)
+	if !ok {
+		return nil
+	}
+
+	err := fsrepo.AddDatastoreConfigHandler(dspl.DatastoreTypeName(), dspl.DatastoreConfigParser())
+	if err != nil {
+		return fmt.Errorf("plugin %s: %s", pl.Name(), err)
+	}
+	return nil
+}
+
+func runTrackerPlugin(pl plugin.Plugin) error {
+	trackerpl, ok := pl.(plugin.PluginTracker)
+	if !ok {
+		return nil
+	}
+
+	for name, t := range trackerpl.Trackers() {
+		err := tracker.Register(name, t)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %s", pl.Name(), err)
+		}
+	}
+	return nil
+}
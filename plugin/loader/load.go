@@ -15,6 +15,13 @@ var loadPluginsFunc = func(string) ([]plugin.Plugin, error) {
 	return nil, nil
 }
 
+// Preload adds plugins compiled into the binary to the ones loaded by
+// LoadPlugins. Programs embedding go-ipfs can call it from an init function
+// instead of listing the plugins in preload_list.
+func Preload(pls ...plugin.Plugin) {
+	preloadPlugins = append(preloadPlugins, pls...)
+}
+
 // LoadPlugins loads and initializes plugins.
 func LoadPlugins(pluginDir string) ([]plugin.Plugin, error) {
 	plMap := make(map[string]plugin.Plugin)
@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-ipfs/exchange/tracker"
+)
+
+// PluginTracker is an interface that can be implemented to add trackers,
+// which content is announced to and whose providers are found through next
+// to the DHT, keyed by name
+type PluginTracker interface {
+	Plugin
+
+	Trackers() map[string]tracker.Tracker
+}
@@ -0,0 +1,81 @@
+// Code generated by protoc-gen-gogo.
+// source: pubsub.proto
+// DO NOT EDIT!
+
+/*
+Package pubsub_pb is a generated protocol buffer package.
+
+It is generated from these files:
+	pubsub.proto
+
+It has these top-level messages:
+	Message
+*/
+package pubsub_pb
+
+import proto "github.com/gogo/protobuf/proto"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = math.Inf
+
+type Message struct {
+	From             []byte  `protobuf:"bytes,1,req,name=from" json:"from,omitempty"`
+	Seqno            *uint64 `protobuf:"varint,2,req,name=seqno" json:"seqno,omitempty"`
+	Topic            *string `protobuf:"bytes,3,req,name=topic" json:"topic,omitempty"`
+	Data             []byte  `protobuf:"bytes,4,opt,name=data" json:"data,omitempty"`
+	Key              []byte  `protobuf:"bytes,5,opt,name=key" json:"key,omitempty"`
+	Signature        []byte  `protobuf:"bytes,6,opt,name=signature" json:"signature,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+
+func (m *Message) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *Message) GetSeqno() uint64 {
+	if m != nil && m.Seqno != nil {
+		return *m.Seqno
+	}
+	return 0
+}
+
+func (m *Message) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
+	}
+	return ""
+}
+
+func (m *Message) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Message) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *Message) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Message)(nil), "pubsub.pb.Message")
+}
@@ -0,0 +1,456 @@
+// Package pubsub layers signed messages, per-topic validators and the
+// persistence of the last messages of each topic over floodsub.
+//
+// Every topic is signed unless signing is turned off for the node, to talk to
+// floodsub clients which neither send nor understand signed messages; topics
+// can then still be signed one by one. On a signed topic, every message is
+// signed by the key of the node publishing it, and messages whose signature
+// doesn't verify, or which replay a message already received by any
+// subscription, are dropped. Messages of the other topics are passed through
+// as floodsub carries them. On every topic, messages the validator of the
+// topic rejects are dropped before reaching subscribers.
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/pubsub/pb"
+
+	proto "github.com/gogo/protobuf/proto"
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+	floodsub "github.com/libp2p/go-floodsub"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var log = logging.Logger("pubsub")
+
+// ErrInvalidSignature is returned for messages whose signature doesn't verify.
+var ErrInvalidSignature = errors.New("pubsub: invalid message signature")
+
+// Message is a message received on a topic. The messages of signed topics
+// are verified and carry their Signature; on the other topics Signature is
+// nil and From is the sender floodsub reports, unverified.
+type Message struct {
+	From      peer.ID
+	Seqno     uint64
+	Topic     string
+	Data      []byte
+	Signature []byte
+}
+
+// Validator decides whether a message of a topic is delivered to the
+// subscribers.
+type Validator func(ctx context.Context, msg *Message) bool
+
+// validateTimeout bounds the time validators have for a message.
+const validateTimeout = 10 * time.Second
+
+// PubSub publishes and receives signed messages through floodsub.
+type PubSub struct {
+	fs   *floodsub.PubSub
+	self peer.ID
+	sk   ci.PrivKey
+	// key is the public key sent with messages, when it can't be extracted
+	// from the peer ID
+	key []byte
+
+	seqLk sync.Mutex
+	seq   uint64
+
+	signLk  sync.RWMutex
+	signAll bool
+	signed  map[string]bool
+
+	// windows has the seqnos of the signed messages received from each
+	// author, by any subscription
+	winLk   sync.Mutex
+	windows map[peer.ID]*seqnoWindow
+
+	valLk      sync.RWMutex
+	validators map[string]Validator
+
+	store *store
+}
+
+// New returns a pubsub signing the messages it publishes with sk, on every
+// topic if signAll is set and otherwise on the topics given to SignTopic.
+// When persist is above 0, the last persist messages received on each topic
+// the node subscribes to are kept in d, to be replayed to new subscribers.
+func New(fs *floodsub.PubSub, sk ci.PrivKey, d ds.Datastore, persist int, signAll bool) (*PubSub, error) {
+	self, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PubSub{
+		fs:         fs,
+		self:       self,
+		sk:         sk,
+		seq:        uint64(time.Now().UnixNano()),
+		signAll:    signAll,
+		signed:     make(map[string]bool),
+		windows:    make(map[peer.ID]*seqnoWindow),
+		validators: make(map[string]Validator),
+	}
+	if self.ExtractPublicKey() == nil {
+		p.key, err = sk.GetPublic().Bytes()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if persist > 0 {
+		p.store = newStore(d, persist)
+	}
+	return p, nil
+}
+
+// SignTopic makes the messages of topic signed, even when the node doesn't
+// sign every topic: the ones published are signed, and the ones received are
+// dropped unless their signature verifies. Every node on the topic must sign
+// it.
+func (p *PubSub) SignTopic(topic string) {
+	p.signLk.Lock()
+	defer p.signLk.Unlock()
+	p.signed[topic] = true
+}
+
+// Signed reports whether the messages of topic are signed.
+func (p *PubSub) Signed(topic string) bool {
+	p.signLk.RLock()
+	defer p.signLk.RUnlock()
+	return p.signAll || p.signed[topic]
+}
+
+// acceptSeqno records that the signed message seqno of from was received in
+// the floodsub message carrier, and reports whether it is new: not received
+// before, or only in that same floodsub message, by another subscription.
+func (p *PubSub) acceptSeqno(from peer.ID, seqno uint64, carrier string) bool {
+	p.winLk.Lock()
+	defer p.winLk.Unlock()
+
+	w, ok := p.windows[from]
+	if !ok {
+		w = new(seqnoWindow)
+		p.windows[from] = w
+	}
+	return w.accept(seqno, carrier)
+}
+
+// Publish publishes data to topic, signed if the topic is.
+func (p *PubSub) Publish(topic string, data []byte) error {
+	if !p.Signed(topic) {
+		return p.fs.Publish(topic, data)
+	}
+	b, err := p.encodeMessage(topic, data)
+	if err != nil {
+		return err
+	}
+	return p.fs.Publish(topic, b)
+}
+
+// encodeMessage returns the signed message of data on topic.
+func (p *PubSub) encodeMessage(topic string, data []byte) ([]byte, error) {
+	p.seqLk.Lock()
+	p.seq++
+	seqno := p.seq
+	p.seqLk.Unlock()
+
+	m := &pb.Message{
+		From:  []byte(p.self),
+		Seqno: proto.Uint64(seqno),
+		Topic: proto.String(topic),
+		Data:  data,
+		Key:   p.key,
+	}
+	unsigned, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	m.Signature, err = p.sk.Sign(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+// RegisterTopicValidator makes the messages of topic go through v before
+// being delivered.
+func (p *PubSub) RegisterTopicValidator(topic string, v Validator) error {
+	p.valLk.Lock()
+	defer p.valLk.Unlock()
+	if _, ok := p.validators[topic]; ok {
+		return fmt.Errorf("pubsub: topic %q already has a validator", topic)
+	}
+	p.validators[topic] = v
+	return nil
+}
+
+// UnregisterTopicValidator removes the validator of topic.
+func (p *PubSub) UnregisterTopicValidator(topic string) {
+	p.valLk.Lock()
+	defer p.valLk.Unlock()
+	delete(p.validators, topic)
+}
+
+func (p *PubSub) validator(topic string) Validator {
+	p.valLk.RLock()
+	defer p.valLk.RUnlock()
+	return p.validators[topic]
+}
+
+// Topics returns the topics the node is subscribed to.
+func (p *PubSub) Topics() []string {
+	return p.fs.GetTopics()
+}
+
+// Peers returns the peers subscribed to topic, or to any topic if empty,
+// the node is connected to.
+func (p *PubSub) Peers(topic string) []peer.ID {
+	return p.fs.ListPeers(topic)
+}
+
+// Replay returns the messages of topic kept, oldest first.
+func (p *PubSub) Replay(topic string) ([]*Message, error) {
+	if p.store == nil {
+		return nil, nil
+	}
+	return p.store.messages(topic)
+}
+
+// Subscription receives the messages of a topic.
+type Subscription struct {
+	p      *PubSub
+	topic  string
+	sub    *floodsub.Subscription
+	signed bool
+}
+
+// Subscribe subscribes to topic.
+func (p *PubSub) Subscribe(topic string) (*Subscription, error) {
+	sub, err := p.fs.Subscribe(topic)
+	if err != nil {
+		return nil, err
+	}
+	return newSubscription(p, topic, sub), nil
+}
+
+func newSubscription(p *PubSub, topic string, sub *floodsub.Subscription) *Subscription {
+	return &Subscription{
+		p:      p,
+		topic:  topic,
+		sub:    sub,
+		signed: p.Signed(topic),
+	}
+}
+
+// Topic returns the topic of s.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Next returns the next message of the topic, dropping the ones which don't
+// verify or validate.
+func (s *Subscription) Next(ctx context.Context) (*Message, error) {
+	for {
+		fm, err := s.sub.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := s.receive(ctx, fm); ok {
+			return m, nil
+		}
+	}
+}
+
+// receive returns the message fm carries, and false if it is dropped.
+func (s *Subscription) receive(ctx context.Context, fm *floodsub.Message) (*Message, bool) {
+	var m *Message
+	var raw []byte
+	if s.signed {
+		var err error
+		raw = fm.GetData()
+		m, err = decodeMessage(raw)
+		if err != nil {
+			log.Debugf("dropping message on %s from %s: %s", s.topic, peer.ID(fm.GetFrom()), err)
+			return nil, false
+		}
+		if m.Topic != s.topic {
+			log.Debugf("dropping message from %s, published on %s but received on %s", m.From, m.Topic, s.topic)
+			return nil, false
+		}
+
+		carrier := string(fm.GetFrom()) + string(fm.GetSeqno())
+		if !s.p.acceptSeqno(m.From, m.Seqno, carrier) {
+			log.Debugf("dropping message on %s from %s, replaying seqno %d", s.topic, m.From, m.Seqno)
+			return nil, false
+		}
+	} else {
+		m = &Message{
+			From:  peer.ID(fm.GetFrom()),
+			Seqno: seqnoFromBytes(fm.GetSeqno()),
+			Topic: s.topic,
+			Data:  fm.GetData(),
+		}
+	}
+
+	if v := s.p.validator(s.topic); v != nil {
+		vctx, cancel := context.WithTimeout(ctx, validateTimeout)
+		ok := v(vctx, m)
+		cancel()
+		if !ok {
+			log.Debugf("dropping message on %s from %s, rejected by the validator", s.topic, m.From)
+			return nil, false
+		}
+	}
+
+	if s.p.store != nil {
+		if raw == nil {
+			var err error
+			raw, err = encodeUnsigned(m)
+			if err != nil {
+				log.Warningf("persisting message on %s: %s", s.topic, err)
+				return m, true
+			}
+		}
+		if err := s.p.store.add(m, raw); err != nil {
+			log.Warningf("persisting message on %s: %s", s.topic, err)
+		}
+	}
+	return m, true
+}
+
+// seqnoWindowSize is how far behind the highest seqno of an author a
+// message may be and still be received.
+const seqnoWindowSize = 64
+
+// seqnoWindow tracks the seqnos received from an author: the highest one,
+// and which of the seqnoWindowSize ones up to it, along with the floodsub
+// message which carried each. Messages whose seqno was received already in
+// another floodsub message, or is too old to tell, are replays.
+type seqnoWindow struct {
+	max      uint64
+	seen     uint64 // bit i is set when max-i was received
+	carriers [seqnoWindowSize]string
+}
+
+// accept records seqno, carried by carrier, and reports whether it wasn't
+// received before in another carrier.
+func (w *seqnoWindow) accept(seqno uint64, carrier string) bool {
+	switch {
+	case w.seen == 0:
+		w.max, w.seen = seqno, 1
+	case seqno > w.max:
+		if d := seqno - w.max; d < seqnoWindowSize {
+			w.seen = w.seen<<d | 1
+		} else {
+			w.seen = 1
+		}
+		w.max = seqno
+	default:
+		d := w.max - seqno
+		if d >= seqnoWindowSize {
+			return false
+		}
+		if w.seen&(1<<d) != 0 {
+			return w.carriers[seqno%seqnoWindowSize] == carrier
+		}
+		w.seen |= 1 << d
+	}
+	w.carriers[seqno%seqnoWindowSize] = carrier
+	return true
+}
+
+// seqnoFromBytes returns the seqno of a floodsub message, big endian.
+func seqnoFromBytes(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// Cancel unsubscribes.
+func (s *Subscription) Cancel() {
+	s.sub.Cancel()
+}
+
+// encodeUnsigned returns the encoding of m, a message of an unsigned topic,
+// as it is kept in the store.
+func encodeUnsigned(m *Message) ([]byte, error) {
+	return proto.Marshal(&pb.Message{
+		From:  []byte(m.From),
+		Seqno: proto.Uint64(m.Seqno),
+		Topic: proto.String(m.Topic),
+		Data:  m.Data,
+	})
+}
+
+// decodeKept unmarshals a message kept in the store, verifying its
+// signature if it has one.
+func decodeKept(b []byte) (*Message, error) {
+	var m pb.Message
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if len(m.Signature) > 0 {
+		return decodeMessage(b)
+	}
+	return &Message{
+		From:  peer.ID(m.GetFrom()),
+		Seqno: m.GetSeqno(),
+		Topic: m.GetTopic(),
+		Data:  m.GetData(),
+	}, nil
+}
+
+// decodeMessage unmarshals a message and verifies its signature.
+func decodeMessage(b []byte) (*Message, error) {
+	var m pb.Message
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	from, err := peer.IDFromBytes(m.GetFrom())
+	if err != nil {
+		return nil, err
+	}
+
+	pk := from.ExtractPublicKey()
+	if pk == nil {
+		if len(m.Key) == 0 {
+			return nil, errors.New("pubsub: message without the key of its author")
+		}
+		pk, err = ci.UnmarshalPublicKey(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		if !from.MatchesPublicKey(pk) {
+			return nil, errors.New("pubsub: message key doesn't match its author")
+		}
+	}
+
+	sig := m.Signature
+	m.Signature = nil
+	unsigned, err := proto.Marshal(&m)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := pk.Verify(unsigned, sig)
+	if err != nil || !ok {
+		return nil, ErrInvalidSignature
+	}
+
+	return &Message{
+		From:      from,
+		Seqno:     m.GetSeqno(),
+		Topic:     m.GetTopic(),
+		Data:      m.GetData(),
+		Signature: sig,
+	}, nil
+}
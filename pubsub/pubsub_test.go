@@ -0,0 +1,203 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	pb "github.com/ipfs/go-ipfs/pubsub/pb"
+
+	proto "github.com/gogo/protobuf/proto"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	floodsub "github.com/libp2p/go-floodsub"
+	fspb "github.com/libp2p/go-floodsub/pb"
+	ci "github.com/libp2p/go-libp2p-crypto"
+)
+
+func newTestPubSub(t *testing.T) *PubSub {
+	sk, _, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(nil, sk, dssync.MutexWrap(ds.NewMapDatastore()), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestMessageSignature(t *testing.T) {
+	p := newTestPubSub(t)
+
+	b, err := p.encodeMessage("foo", []byte("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decodeMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.From != p.self || m.Topic != "foo" || string(m.Data) != "bar" {
+		t.Fatalf("wrong message: %v", m)
+	}
+
+	var pm pb.Message
+	if err := proto.Unmarshal(b, &pm); err != nil {
+		t.Fatal(err)
+	}
+	pm.Data = []byte("baz")
+	tampered, err := proto.Marshal(&pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(tampered); err != ErrInvalidSignature {
+		t.Fatalf("expected %s, got %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	p := newTestPubSub(t)
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	s := newStore(d, 3)
+
+	add := func(topic string, i int) (*Message, []byte) {
+		b, err := p.encodeMessage(topic, []byte(fmt.Sprint(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := decodeMessage(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.add(m, b); err != nil {
+			t.Fatal(err)
+		}
+		return m, b
+	}
+
+	var last *Message
+	var raw []byte
+	for i := 0; i < 5; i++ {
+		last, raw = add("foo", i)
+	}
+	add("foobar", 0)
+
+	// the same message received twice is kept once
+	if err := s.add(last, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(s *store) {
+		msgs, err := s.messages("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 3 {
+			t.Fatalf("expected 3 messages, got %d", len(msgs))
+		}
+		for i, m := range msgs {
+			if string(m.Data) != fmt.Sprint(i+2) {
+				t.Fatalf("wrong message %d: %s", i, m.Data)
+			}
+		}
+	}
+	check(s)
+	// a new store reads the messages kept
+	check(newStore(d, 3))
+}
+
+// floodsubMessage returns data as floodsub delivers it, from p.
+func floodsubMessage(p *PubSub, topic string, seqno uint64, data []byte) *floodsub.Message {
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, seqno)
+	return &floodsub.Message{Message: &fspb.Message{
+		From:     []byte(p.self),
+		Data:     data,
+		Seqno:    seq,
+		TopicIDs: []string{topic},
+	}}
+}
+
+func TestReplayedMessage(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPubSub(t)
+	p.SignTopic("foo")
+	sub := newSubscription(p, "foo", nil)
+
+	b, err := p.encodeMessage("foo", []byte("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sub.receive(ctx, floodsubMessage(p, "foo", 1, b)); !ok {
+		t.Fatal("expected the message to be received")
+	}
+	// a captured message sent again, in a new floodsub message
+	if _, ok := sub.receive(ctx, floodsubMessage(p, "foo", 2, b)); ok {
+		t.Fatal("expected the replayed message to be dropped")
+	}
+	// even to a subscription made after it was received
+	other := newSubscription(p, "foo", nil)
+	if _, ok := other.receive(ctx, floodsubMessage(p, "foo", 2, b)); ok {
+		t.Fatal("expected the replayed message to be dropped by a new subscription")
+	}
+	// while the original floodsub message reaches every subscription
+	if _, ok := other.receive(ctx, floodsubMessage(p, "foo", 1, b)); !ok {
+		t.Fatal("expected the message to be received by every subscription")
+	}
+
+	// messages arriving out of order are received once each
+	var msgs [][]byte
+	for i := 0; i < seqnoWindowSize+1; i++ {
+		b, err := p.encodeMessage("foo", []byte(fmt.Sprint(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, b)
+	}
+	last := len(msgs) - 1
+	if _, ok := sub.receive(ctx, floodsubMessage(p, "foo", 3, msgs[last-1])); !ok {
+		t.Fatal("expected the message to be received")
+	}
+	if _, ok := sub.receive(ctx, floodsubMessage(p, "foo", 4, msgs[1])); !ok {
+		t.Fatal("expected a message within the window to be received")
+	}
+	if _, ok := sub.receive(ctx, floodsubMessage(p, "foo", 5, msgs[last])); !ok {
+		t.Fatal("expected the message to be received")
+	}
+	// too old to tell whether it was received
+	if _, ok := sub.receive(ctx, floodsubMessage(p, "foo", 6, msgs[0])); ok {
+		t.Fatal("expected a message behind the window to be dropped")
+	}
+}
+
+func TestUnsignedTopic(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPubSub(t)
+	sub := newSubscription(p, "foo", nil)
+
+	m, ok := sub.receive(ctx, floodsubMessage(p, "foo", 7, []byte("bar")))
+	if !ok {
+		t.Fatal("expected the unsigned message to be received")
+	}
+	if m.From != p.self || m.Seqno != 7 || string(m.Data) != "bar" || m.Signature != nil {
+		t.Fatalf("wrong message: %v", m)
+	}
+
+	// signed messages aren't unwrapped on unsigned topics
+	b, err := p.encodeMessage("foo", []byte("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok = sub.receive(ctx, floodsubMessage(p, "foo", 8, b))
+	if !ok || string(m.Data) != string(b) {
+		t.Fatal("expected the message to be passed through")
+	}
+
+	// and unsigned messages are dropped on signed topics
+	p.SignTopic("baz")
+	if _, ok := newSubscription(p, "baz", nil).receive(ctx, floodsubMessage(p, "baz", 9, []byte("bar"))); ok {
+		t.Fatal("expected the unsigned message to be dropped")
+	}
+}
@@ -0,0 +1,152 @@
+package pubsub
+
+import (
+	"encoding/base32"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+)
+
+// storeKey is where the last messages of the topics are kept.
+var storeKey = ds.NewKey("/local/pubsub/messages")
+
+var topicEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// store keeps the last messages of each topic, as received, under keys
+// ordered by arrival.
+type store struct {
+	d    ds.Datastore
+	size int
+
+	lk     sync.Mutex
+	topics map[string]*topicStore
+}
+
+type topicStore struct {
+	kept []keptMessage // oldest first
+	ids  map[string]struct{}
+	next uint64
+}
+
+type keptMessage struct {
+	key ds.Key
+	id  string
+}
+
+func newStore(d ds.Datastore, size int) *store {
+	return &store{
+		d:      d,
+		size:   size,
+		topics: make(map[string]*topicStore),
+	}
+}
+
+func topicKey(topic string) ds.Key {
+	return storeKey.ChildString(topicEncoding.EncodeToString([]byte(topic)))
+}
+
+func messageID(m *Message) string {
+	return string(m.From) + strconv.FormatUint(m.Seqno, 10)
+}
+
+// load reads the messages of topic kept, the first time the topic is used.
+func (s *store) load(topic string) (*topicStore, error) {
+	if ts, ok := s.topics[topic]; ok {
+		return ts, nil
+	}
+
+	// the trailing slash keeps the topics whose key extends this one out
+	res, err := s.d.Query(query.Query{Prefix: topicKey(topic).String() + "/"})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	ts := &topicStore{ids: make(map[string]struct{})}
+	for _, e := range entries {
+		k := ds.NewKey(e.Key)
+		n, err := strconv.ParseUint(k.BaseNamespace(), 10, 64)
+		if err != nil {
+			continue
+		}
+		if n >= ts.next {
+			ts.next = n + 1
+		}
+		km := keptMessage{key: k}
+		if m, err := decodeKept(e.Value.([]byte)); err == nil {
+			km.id = messageID(m)
+			ts.ids[km.id] = struct{}{}
+		}
+		ts.kept = append(ts.kept, km)
+	}
+	s.topics[topic] = ts
+	return ts, nil
+}
+
+// add keeps raw, the encoding of m, unless kept already, and drops the
+// oldest messages of its topic above the size of the store.
+func (s *store) add(m *Message, raw []byte) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ts, err := s.load(m.Topic)
+	if err != nil {
+		return err
+	}
+	id := messageID(m)
+	if _, ok := ts.ids[id]; ok {
+		// received through another subscription
+		return nil
+	}
+
+	// zero padded, so that keys sort by arrival
+	k := topicKey(m.Topic).ChildString(fmt.Sprintf("%020d", ts.next))
+	if err := s.d.Put(k, raw); err != nil {
+		return err
+	}
+	ts.next++
+	ts.kept = append(ts.kept, keptMessage{key: k, id: id})
+	ts.ids[id] = struct{}{}
+
+	for len(ts.kept) > s.size {
+		oldest := ts.kept[0]
+		if err := s.d.Delete(oldest.key); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+		delete(ts.ids, oldest.id)
+		ts.kept = ts.kept[1:]
+	}
+	return nil
+}
+
+// messages returns the messages of topic kept, oldest first.
+func (s *store) messages(topic string) ([]*Message, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ts, err := s.load(topic)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Message, 0, len(ts.kept))
+	for _, km := range ts.kept {
+		v, err := s.d.Get(km.key)
+		if err != nil {
+			return nil, err
+		}
+		m, err := decodeKept(v.([]byte))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
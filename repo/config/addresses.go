@@ -7,4 +7,5 @@ type Addresses struct {
 	NoAnnounce []string // swarm addresses not to announce to the network
 	API        string   // address for the local API (RPC)
 	Gateway    string   // address to listen on for IPFS HTTP object gateway
+	RPC        string   // address for the gRPC core API, disabled when empty
 }
@@ -24,9 +24,19 @@ type Config struct {
 	Gateway   Gateway   // local node's gateway server options
 	API       API       // local node's API settings
 	Swarm     SwarmConfig
+	Peering   Peering
+	Exchange  Exchange
 
+	Provider     Provider
 	Reprovider   Reprovider
+	Routing      Routing
+	Pinning      Pinning
+	Pubsub       Pubsub
+	Tracing      Tracing
 	Experimental Experiments
+
+	// Tenants are further repos served by the daemon, by name.
+	Tenants map[string]Tenant `json:",omitempty"`
 }
 
 const (
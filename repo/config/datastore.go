@@ -23,6 +23,89 @@ type Datastore struct {
 
 	HashOnRead      bool
 	BloomFilterSize int
+
+	// EnumerationWorkers is the number of concurrent queries listing all
+	// blocks, e.g. for GC and reproviding. Zero picks a default, one lists
+	// them with a single query.
+	EnumerationWorkers int
+
+	// ReadOnly refuses all changes to blocks and pins, e.g. to serve a repo
+	// from immutable media.
+	ReadOnly bool
+
+	// PersistBloomFilter saves the bloom filter on shutdown and loads it on
+	// start instead of rebuilding it.
+	PersistBloomFilter bool
+
+	// Tiered splits the blockstore into a hot and a cold tier.
+	Tiered TieredBlocks
+
+	// Scrub configures the background blockstore scrubber.
+	Scrub Scrub
+
+	// Quota limits the storage used by blocks.
+	Quota Quota
+
+	// Eviction removes least recently used unpinned blocks when the repo
+	// exceeds StorageGCWatermark.
+	Eviction Eviction
+}
+
+// Eviction configures the removal of least recently used unpinned blocks.
+type Eviction struct {
+	Enabled bool
+
+	// LowWatermark is the percentage of StorageMax eviction frees space
+	// down to.
+	LowWatermark int64 `json:",omitempty"`
+
+	// Period is the time between checks of the repo size.
+	Period string `json:",omitempty"`
+}
+
+// Quota limits the storage used by blocks added locally and by blocks cached
+// from the network separately.
+type Quota struct {
+	Local   QuotaLimit
+	Network QuotaLimit
+
+	// Thresholds are the percentages of the limits at which warnings are
+	// emitted.
+	Thresholds []int `json:",omitempty"`
+}
+
+// QuotaLimit is a storage quota. Empty fields mean no limit.
+type QuotaLimit struct {
+	MaxSize   string `json:",omitempty"` // in B, kB, kiB, MB, ...
+	MaxBlocks uint64 `json:",omitempty"`
+}
+
+// Scrub configures the background process re-hashing stored blocks.
+type Scrub struct {
+	Enabled bool
+
+	// Rate bounds the block data read per second, in B, kB, kiB, MB, ...
+	Rate string `json:",omitempty"`
+
+	// Interval is the time between scrub passes.
+	Interval string `json:",omitempty"`
+
+	// Repair replaces corrupt blocks with copies fetched from the network.
+	Repair bool
+}
+
+// TieredBlocks configures the tiered blockstore.
+type TieredBlocks struct {
+	Enabled bool
+
+	// HotCapacity is the amount of block data kept in the hot tier, in B,
+	// kB, kiB, MB, ...
+	HotCapacity string `json:",omitempty"`
+
+	// ColdPrefix is the datastore key prefix cold blocks are stored under,
+	// "/cold" by default. Mount a datastore at ColdPrefix + "/blocks" in the
+	// Spec to choose where they go.
+	ColdPrefix string `json:",omitempty"`
 }
 
 // DataStorePath returns the default data store path given a configuration root
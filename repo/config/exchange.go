@@ -0,0 +1,53 @@
+package config
+
+// Exchange contains options for retrieving blocks from other nodes.
+type Exchange struct {
+	// GatewayFallback is a list of trusted HTTP gateways blocks are fetched
+	// from when bitswap can't find them in time.
+	GatewayFallback []string `json:",omitempty"`
+
+	// GatewayFallbackDelay is how long to wait on bitswap before asking the
+	// fallback gateways (default: 10s).
+	GatewayFallbackDelay string `json:",omitempty"`
+
+	// PersistentWantsTTL, if set, makes bitswap remember requested blocks
+	// across restarts and keep asking for them until they are retrieved or
+	// this much time has passed since they were first requested.
+	PersistentWantsTTL string `json:",omitempty"`
+
+	// UploadRateLimit caps the total rate at which blocks are served to
+	// other peers, e.g. "1MB" per second. Empty means unlimited.
+	UploadRateLimit string `json:",omitempty"`
+
+	// UploadRateBurst is how far uploads may exceed UploadRateLimit for a
+	// short time (default: one second worth of the rate).
+	UploadRateBurst string `json:",omitempty"`
+
+	// PeerUploadRateLimit caps the rate at which blocks are served to each
+	// peer. Empty means unlimited.
+	PeerUploadRateLimit string `json:",omitempty"`
+
+	// PeerUploadRateBurst is the burst for PeerUploadRateLimit.
+	PeerUploadRateBurst string `json:",omitempty"`
+
+	// FetchAheadBlocks is how many blocks of a file being read are requested
+	// ahead of the one being read, per level of its DAG (default: 32).
+	FetchAheadBlocks int `json:",omitempty"`
+
+	// FetchAheadMemory bounds the amount of file data requested ahead of the
+	// one being read, per level of its DAG, e.g. "8MB" (default: 8MiB).
+	FetchAheadMemory string `json:",omitempty"`
+
+	// AllowPeers, if set, are the only peers blocks are traded with.
+	AllowPeers []string `json:",omitempty"`
+
+	// BlockPeers are peers blocks are never traded with.
+	BlockPeers []string `json:",omitempty"`
+
+	// BanThreshold is the number of invalid blocks after which a peer is
+	// temporarily banned (default: 3). Negative disables bans.
+	BanThreshold int `json:",omitempty"`
+
+	// BanDuration is how long peers stay banned (default: 30m).
+	BanDuration string `json:",omitempty"`
+}
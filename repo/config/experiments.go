@@ -2,6 +2,11 @@ package config
 
 type Experiments struct {
 	FilestoreEnabled     bool
+	UrlstoreEnabled      bool
 	ShardingEnabled      bool
+	ShardingThreshold    int `json:",omitempty"`
+	ShardingSizeLimit    int `json:",omitempty"`
+	FilesJournal         int `json:",omitempty"`
 	Libp2pStreamMounting bool
+	MimeIndexEnabled     bool
 }
@@ -6,4 +6,40 @@ type Gateway struct {
 	RootRedirect string
 	Writable     bool
 	PathPrefixes []string
+	Limits       GatewayLimits
+
+	// WriteTokens, if any, are the bearer tokens one of which writes to a
+	// writable gateway must carry.
+	WriteTokens []string `json:",omitempty"`
+
+	// Archives lets directories be downloaded as tar or zip archives, with
+	// ?format=tar or ?format=zip.
+	Archives bool `json:",omitempty"`
+}
+
+// GatewayLimits protects a public gateway from abuse. Zero values mean
+// unlimited.
+type GatewayLimits struct {
+	// ClientRequestsPerMinute is how many requests each client IP may make
+	// per minute, and ClientRequestBurst how many more it may make at once
+	// (default: a minute worth of requests).
+	ClientRequestsPerMinute int `json:",omitempty"`
+	ClientRequestBurst      int `json:",omitempty"`
+
+	// MaxConcurrentRequests caps the requests served at once.
+	MaxConcurrentRequests int `json:",omitempty"`
+
+	// MaxRequestBlocks is how many blocks a request may read, bounding the
+	// DAGs it can traverse.
+	MaxRequestBlocks int64 `json:",omitempty"`
+
+	// MaxRequestDuration is how long a request may take, e.g. "1m"
+	// (default: 1h).
+	MaxRequestDuration string `json:",omitempty"`
+
+	// BandwidthLimit caps the total rate at which responses are written,
+	// e.g. "10MB" per second, and BandwidthBurst is how far it may be
+	// exceeded for a short time (default: one second worth of the rate).
+	BandwidthLimit string `json:",omitempty"`
+	BandwidthBurst string `json:",omitempty"`
 }
@@ -2,7 +2,10 @@ package config
 
 // Mounts stores the (string) mount points
 type Mounts struct {
-	IPFS           string
-	IPNS           string
+	IPFS string
+	IPNS string
+	// MFS is where the files of 'ipfs files' are mounted read-write, if
+	// set.
+	MFS            string `json:",omitempty"`
 	FuseAllowOther bool
 }
@@ -0,0 +1,8 @@
+package config
+
+// Peering configures the peers the node stays connected to.
+type Peering struct {
+	// Peers are the addresses of the peers, as /ipfs/<peer id> suffixed
+	// multiaddrs. A peer with several addresses is listed once for each.
+	Peers []string `json:",omitempty"`
+}
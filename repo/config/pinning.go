@@ -0,0 +1,41 @@
+package config
+
+// Pinning configures the delegation of pins to remote pinning services and
+// their replication among trusted peers.
+type Pinning struct {
+	// RemoteServices are the pinning services pins can be delegated to, by
+	// name.
+	RemoteServices map[string]RemotePinningService `json:",omitempty"`
+
+	// Replication configures the replication of pins among trusted peers.
+	Replication Replication
+}
+
+// RemotePinningService is a service implementing the IPFS pinning service
+// API.
+type RemotePinningService struct {
+	// Endpoint is the URL of the API, e.g. https://pinning.example.com/api/v1.
+	Endpoint string
+
+	// Key is the access token sent to the service.
+	Key string
+}
+
+// Replication configures the cooperative pinning of a set of trusted peers,
+// which share a ledger of pins and keep each of them on a number of peers.
+type Replication struct {
+	Enabled bool
+
+	// Topic is the pubsub topic the peers share the ledger on.
+	Topic string `json:",omitempty"`
+
+	// Peers are the IDs of the trusted peers.
+	Peers []string
+
+	// Factor is the number of peers holding a pin, unless given when
+	// adding it.
+	Factor int
+
+	// Interval is how often the peers publish their state, e.g. "1m".
+	Interval string `json:",omitempty"`
+}
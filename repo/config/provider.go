@@ -0,0 +1,12 @@
+package config
+
+// Provider contains options for announcing content to the network.
+type Provider struct {
+	// Delegates are URLs of remote providers APIs announcements are sent
+	// to, in addition to the DHT.
+	Delegates []string `json:",omitempty"`
+
+	// DelegateOnly stops announcing through the DHT when delegates are
+	// configured.
+	DelegateOnly bool `json:",omitempty"`
+}
@@ -0,0 +1,20 @@
+package config
+
+// Pubsub configures the publish-subscribe system, enabled with the
+// --enable-pubsub-experiment flag of the daemon.
+type Pubsub struct {
+	// PersistMessages is the number of messages of each topic subscribed
+	// to kept in the repo, to be replayed to new subscribers. Persistence
+	// is off when 0.
+	PersistMessages int `json:",omitempty"`
+
+	// DisableSigning turns off signing the messages of every topic, to
+	// talk to floodsub clients which neither send nor understand signed
+	// messages. Only the SignedTopics are then signed.
+	DisableSigning bool `json:",omitempty"`
+
+	// SignedTopics are the topics whose messages are signed, and dropped
+	// unless their signature verifies, when DisableSigning is set. Every
+	// node on such a topic must sign it.
+	SignedTopics []string `json:",omitempty"`
+}
@@ -0,0 +1,16 @@
+package config
+
+// Routing configures the participation of the node in the DHT.
+type Routing struct {
+	// Type is the routing of the daemon when its --routing flag isn't
+	// given: "dht", "dhtclient", "dhtauto" or "none". Defaults to "dht".
+	Type string `json:",omitempty"`
+
+	// MaxRecords bounds the records, provider records included, the DHT
+	// stores for other peers. No limit when 0.
+	MaxRecords int `json:",omitempty"`
+
+	// MaxConcurrentQueries bounds the DHT queries the node runs at once.
+	// No limit when 0.
+	MaxConcurrentQueries int `json:",omitempty"`
+}
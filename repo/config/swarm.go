@@ -6,8 +6,11 @@ type SwarmConfig struct {
 	DisableNatPortMap       bool
 	DisableRelay            bool
 	EnableRelayHop          bool
+	EnableAutoRelay         bool
+	EnableHolePunching      bool
 
-	ConnMgr ConnMgr
+	ConnMgr     ConnMgr
+	ResourceMgr ResourceMgr
 }
 
 // ConnMgr defines configuration options for the libp2p connection manager
@@ -17,3 +20,23 @@ type ConnMgr struct {
 	HighWater   int
 	GracePeriod string
 }
+
+// ResourceMgr defines the limits of the resources used by the connections and
+// streams of the node
+type ResourceMgr struct {
+	// System limits the whole node
+	System ResourceLimit
+	// Peer limits each peer
+	Peer ResourceLimit
+	// Subsystems limits subsystems, such as "bitswap" or "dht"
+	Subsystems map[string]ResourceLimit `json:",omitempty"`
+	// Protocols limits protocols, such as "/ipfs/bitswap/1.1.0"
+	Protocols map[string]ResourceLimit `json:",omitempty"`
+}
+
+// ResourceLimit bounds resources, zero values are unlimited
+type ResourceLimit struct {
+	Conns   int    `json:",omitempty"`
+	Streams int    `json:",omitempty"`
+	Memory  string `json:",omitempty"`
+}
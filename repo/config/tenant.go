@@ -0,0 +1,10 @@
+package config
+
+// Tenant is a repo opened by the daemon next to its own one. Tenants have
+// separate blockstores, pins and keys, but share the identity and the
+// connections of the daemon.
+type Tenant struct {
+	// Path is the path of the tenant's repo. It has to be initialized with
+	// 'ipfs init' beforehand.
+	Path string
+}
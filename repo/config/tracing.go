@@ -0,0 +1,9 @@
+package config
+
+// Tracing configures the export of the traces of the operations of the node.
+type Tracing struct {
+	// OTLPEndpoint is the URL of the OTLP/HTTP traces endpoint of an
+	// OpenTelemetry collector spans are sent to, e.g.
+	// http://localhost:4318/v1/traces. Tracing is off when empty.
+	OTLPEndpoint string `json:",omitempty"`
+}
@@ -0,0 +1,108 @@
+package fsrepo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+)
+
+var _ repo.Compacter = (*FSRepo)(nil)
+var _ repo.TempFileCounter = (*FSRepo)(nil)
+
+// flatfs writes blocks to temporary files with this prefix before renaming
+// them into place.
+const flatfsTempPrefix = "put-"
+
+// tempFileGrace is how old a temporary file has to be before it is
+// considered orphaned, so that writes in progress aren't touched.
+const tempFileGrace = time.Hour
+
+// flatfsPaths returns the directories of the flatfs datastores in the given
+// datastore config.
+func flatfsPaths(repoPath string, dsc DatastoreConfig) []string {
+	switch c := dsc.(type) {
+	case *mountDatastoreConfig:
+		var out []string
+		for _, m := range c.mounts {
+			out = append(out, flatfsPaths(repoPath, m.ds)...)
+		}
+		return out
+	case *measureDatastoreConfig:
+		return flatfsPaths(repoPath, c.child)
+	case *logDatastoreConfig:
+		return flatfsPaths(repoPath, c.child)
+	case *flatfsDatastoreConfig:
+		p := c.path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(repoPath, p)
+		}
+		return []string{p}
+	default:
+		return nil
+	}
+}
+
+// walkOrphanedTempFiles calls fn for every orphaned temporary file.
+func (r *FSRepo) walkOrphanedTempFiles(fn func(p string, fi os.FileInfo) error) error {
+	dsc, err := AnyDatastoreConfig(r.config.Datastore.Spec)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-tempFileGrace)
+	for _, dir := range flatfsPaths(r.path, dsc) {
+		err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				log.Debugf("filepath.Walk error: %s", err)
+				return nil
+			}
+			if fi.IsDir() || !strings.HasPrefix(fi.Name(), flatfsTempPrefix) {
+				return nil
+			}
+			if fi.ModTime().After(cutoff) {
+				return nil
+			}
+			return fn(p, fi)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OrphanedTempFiles returns the number and total size of temporary files
+// left behind in flatfs datastores by interrupted writes.
+func (r *FSRepo) OrphanedTempFiles() (count uint64, size uint64, err error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	err = r.walkOrphanedTempFiles(func(p string, fi os.FileInfo) error {
+		count++
+		size += uint64(fi.Size())
+		return nil
+	})
+	return count, size, err
+}
+
+// Compact removes orphaned temporary files, and compacts the datastore if
+// its backend supports it.
+func (r *FSRepo) Compact() error {
+	packageLock.Lock()
+	err := r.walkOrphanedTempFiles(func(p string, fi os.FileInfo) error {
+		log.Debugf("removing orphaned temp file %s", p)
+		return os.Remove(p)
+	})
+	packageLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if c, ok := r.ds.(repo.Compacter); ok {
+		return c.Compact()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package fsrepo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestCompactRemovesOrphanedTempFiles(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("compact", t)
+	defer os.RemoveAll(path)
+
+	if err := Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	old := filepath.Join(path, "blocks", flatfsTempPrefix+"old")
+	fresh := filepath.Join(path, "blocks", flatfsTempPrefix+"fresh")
+	for _, p := range []string{old, fresh} {
+		if err := ioutil.WriteFile(p, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := time.Now().Add(-2 * tempFileGrace)
+	if err := os.Chtimes(old, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	fsr := r.(*FSRepo)
+	count, size, err := fsr.OrphanedTempFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || size != 4 {
+		t.Fatalf("expected one orphaned file of 4 bytes, got %d files of %d bytes", count, size)
+	}
+
+	if err := fsr.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("orphaned temp file should have been removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatal("recent temp file should have been kept")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -217,3 +218,231 @@ func TestMeasureConfig(t *testing.T) {
 		t.Errorf("expected '*measure.measure' got '%s'", typ)
 	}
 }
+
+var s3dsConfig = []byte(`{
+      "type": "s3ds",
+      "endpoint": "https://s3.example.com",
+      "bucket": "blocks",
+      "rootDirectory": "node1",
+      "accessKey": "key",
+      "secretKey": "secret",
+      "workers": 4,
+      "partSize": "16MiB"
+    }`)
+
+func TestS3dsConfig(t *testing.T) {
+	spec := make(map[string]interface{})
+	err := json.Unmarshal(s3dsConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"bucket":"blocks","endpoint":"https://s3.example.com","rootDirectory":"node1","type":"s3ds"}`
+	if dsc.DiskSpec().String() != expected {
+		t.Errorf("expected '%s' got '%s' as DiskId", expected, dsc.DiskSpec().String())
+	}
+
+	ds, err := dsc.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if typ := reflect.TypeOf(ds).String(); typ != "*s3ds.Datastore" {
+		t.Errorf("expected '*s3ds.Datastore' got '%s'", typ)
+	}
+}
+
+var encryptedConfig = []byte(`{
+      "type": "encrypted",
+      "keySource": "passphrase",
+      "child": {
+        "type": "levelds",
+        "path": "datastore",
+        "compression": "none"
+      }
+    }`)
+
+func TestEncryptedConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-datastore-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(EnvDatastorePassphrase)
+
+	spec := make(map[string]interface{})
+	if err := json.Unmarshal(encryptedConfig, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	dsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"child":{"path":"datastore","type":"levelds"},"keySource":"passphrase","type":"encrypted"}`
+	if dsc.DiskSpec().String() != expected {
+		t.Errorf("expected '%s' got '%s' as DiskId", expected, dsc.DiskSpec().String())
+	}
+
+	if _, err := dsc.Create(dir); err == nil {
+		t.Fatal("expected an error without a passphrase")
+	}
+
+	os.Setenv(EnvDatastorePassphrase, "correct horse")
+	ds, err := dsc.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := reflect.TypeOf(ds).String(); typ != "*encryptds.Datastore" {
+		t.Errorf("expected '*encryptds.Datastore' got '%s'", typ)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(EnvDatastorePassphrase, "battery staple")
+	if _, err := dsc.Create(dir); err == nil {
+		t.Fatal("expected an error with the wrong passphrase")
+	}
+}
+
+func TestEncryptedKeyFileConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-datastore-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyDir, err := ioutil.TempDir("", "ipfs-datastore-key-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keyDir)
+
+	create := func(keyPath string) error {
+		spec := make(map[string]interface{})
+		if err := json.Unmarshal(encryptedConfig, &spec); err != nil {
+			t.Fatal(err)
+		}
+		spec["keySource"] = "keyFile"
+		spec["keyPath"] = keyPath
+
+		dsc, err := AnyDatastoreConfig(spec)
+		if err != nil {
+			return err
+		}
+		ds, err := dsc.Create(dir)
+		if err != nil {
+			return err
+		}
+		return ds.Close()
+	}
+
+	if err := create("key"); err == nil {
+		t.Fatal("expected an error with a relative key path")
+	}
+	if err := create(filepath.Join(dir, "key")); err == nil {
+		t.Fatal("expected an error with a key file inside the repo")
+	}
+
+	keyPath := filepath.Join(keyDir, "key")
+	if err := create(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(keyPath); err != nil {
+		t.Fatal("expected the key file to be generated:", err)
+	}
+	if err := create(keyPath); err != nil {
+		t.Fatal("reopening with the same key file:", err)
+	}
+
+	if err := ioutil.WriteFile(keyPath, make([]byte, 32), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := create(keyPath); err == nil {
+		t.Fatal("expected an error with another key")
+	}
+}
+
+var encryptedMountConfig = []byte(`{
+      "type": "mount",
+      "mounts": [
+        {
+          "mountpoint": "/blocks",
+          "type": "measure",
+          "prefix": "flatfs.datastore",
+          "child": {
+            "type": "encrypted",
+            "keySource": "keystore",
+            "child": {
+              "type": "flatfs",
+              "path": "blocks",
+              "sync": true,
+              "shardFunc": "/repo/flatfs/shard/v1/next-to-last/2"
+            }
+          }
+        },
+        {
+          "mountpoint": "/",
+          "type": "encrypted",
+          "keySource": "passphrase",
+          "child": {
+            "type": "levelds",
+            "path": "datastore",
+            "compression": "none"
+          }
+        }
+      ]
+    }`)
+
+func TestEncryptedMountConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-datastore-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(EnvDatastorePassphrase)
+
+	spec := make(map[string]interface{})
+	if err := json.Unmarshal(encryptedMountConfig, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	dsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"mounts":[{"child":{"path":"blocks","shardFunc":"/repo/flatfs/shard/v1/next-to-last/2","type":"flatfs"},"keyName":"datastore","keySource":"keystore","mountpoint":"/blocks","type":"encrypted"},{"child":{"path":"datastore","type":"levelds"},"keySource":"passphrase","mountpoint":"/","type":"encrypted"}],"type":"mount"}`
+	if dsc.DiskSpec().String() != expected {
+		t.Errorf("expected '%s' got '%s' as DiskId", expected, dsc.DiskSpec().String())
+	}
+
+	os.Setenv(EnvDatastorePassphrase, "correct horse")
+	ds, err := dsc.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// each mount keeps the check of its own key
+	for _, name := range []string{"datastore_encryption", "datastore_encryption_blocks"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected the %s file: %s", name, err)
+		}
+	}
+	ds, err = dsc.Create(dir)
+	if err != nil {
+		t.Fatal("reopening with the same keys:", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
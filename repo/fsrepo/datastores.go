@@ -2,13 +2,19 @@ package fsrepo
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	repo "github.com/ipfs/go-ipfs/repo"
+	encryptds "github.com/ipfs/go-ipfs/thirdparty/encryptds"
+	s3ds "github.com/ipfs/go-ipfs/thirdparty/s3ds"
 
 	flatfs "github.com/ipfs/go-ds-flatfs"
 	measure "github.com/ipfs/go-ds-measure"
@@ -19,6 +25,7 @@ import (
 	humanize "github.com/dustin/go-humanize"
 	badgerds "github.com/ipfs/go-ds-badger"
 	levelds "github.com/ipfs/go-ds-leveldb"
+	ci "github.com/libp2p/go-libp2p-crypto"
 	ldbopts "github.com/syndtr/goleveldb/leveldb/opt"
 )
 
@@ -60,16 +67,28 @@ var datastores map[string]ConfigFromMap
 
 func init() {
 	datastores = map[string]ConfigFromMap{
-		"mount":    MountDatastoreConfig,
-		"flatfs":   FlatfsDatastoreConfig,
-		"levelds":  LeveldsDatastoreConfig,
-		"badgerds": BadgerdsDatastoreConfig,
-		"mem":      MemDatastoreConfig,
-		"log":      LogDatastoreConfig,
-		"measure":  MeasureDatastoreConfig,
+		"mount":     MountDatastoreConfig,
+		"flatfs":    FlatfsDatastoreConfig,
+		"levelds":   LeveldsDatastoreConfig,
+		"badgerds":  BadgerdsDatastoreConfig,
+		"mem":       MemDatastoreConfig,
+		"log":       LogDatastoreConfig,
+		"measure":   MeasureDatastoreConfig,
+		"s3ds":      S3dsDatastoreConfig,
+		"encrypted": EncryptedDatastoreConfig,
 	}
 }
 
+// AddDatastoreConfigHandler adds a datastore type, which specs can refer to
+// by name.
+func AddDatastoreConfigHandler(name string, dsc ConfigFromMap) error {
+	if _, ok := datastores[name]; ok {
+		return fmt.Errorf("datastore config handler for %s already registered", name)
+	}
+	datastores[name] = dsc
+	return nil
+}
+
 // AnyDatastoreConfig returns a DatastoreConfig from a spec based on
 // the "type" parameter
 func AnyDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
@@ -84,6 +103,12 @@ func AnyDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error)
 	return fun(params)
 }
 
+// mountedConfig is implemented by the configs which depend on where they are
+// mounted, and by the configs wrapping them.
+type mountedConfig interface {
+	setMountpoint(prefix ds.Key)
+}
+
 type mountDatastoreConfig struct {
 	mounts []premount
 }
@@ -116,10 +141,14 @@ func MountDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error
 			return nil, fmt.Errorf("no 'mountpoint' on mount")
 		}
 
-		res.mounts = append(res.mounts, premount{
+		m := premount{
 			ds:     child,
 			prefix: ds.NewKey(prefix.(string)),
-		})
+		}
+		if mc, ok := child.(mountedConfig); ok {
+			mc.setMountpoint(m.prefix)
+		}
+		res.mounts = append(res.mounts, m)
 	}
 	sort.Slice(res.mounts,
 		func(i, j int) bool {
@@ -306,6 +335,12 @@ func (c *logDatastoreConfig) DiskSpec() DiskSpec {
 	return c.child.DiskSpec()
 }
 
+func (c *logDatastoreConfig) setMountpoint(prefix ds.Key) {
+	if mc, ok := c.child.(mountedConfig); ok {
+		mc.setMountpoint(prefix)
+	}
+}
+
 type measureDatastoreConfig struct {
 	child  DatastoreConfig
 	prefix string
@@ -332,6 +367,12 @@ func (c *measureDatastoreConfig) DiskSpec() DiskSpec {
 	return c.child.DiskSpec()
 }
 
+func (c *measureDatastoreConfig) setMountpoint(prefix ds.Key) {
+	if mc, ok := c.child.(mountedConfig); ok {
+		mc.setMountpoint(prefix)
+	}
+}
+
 func (c measureDatastoreConfig) Create(path string) (repo.Datastore, error) {
 	child, err := c.child.Create(path)
 	if err != nil {
@@ -412,3 +453,313 @@ func (c *badgerdsDatastoreConfig) Create(path string) (repo.Datastore, error) {
 
 	return badgerds.NewDatastore(p, &defopts)
 }
+
+type s3dsDatastoreConfig struct {
+	cfg s3ds.Config
+}
+
+// S3dsDatastoreConfig returns a DatastoreConfig for a datastore backed by an
+// S3 compatible object storage service. The credentials may be left out of
+// the spec and taken from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func S3dsDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
+	var c s3dsDatastoreConfig
+
+	for name, field := range map[string]*string{
+		"endpoint":      &c.cfg.Endpoint,
+		"region":        &c.cfg.Region,
+		"bucket":        &c.cfg.Bucket,
+		"rootDirectory": &c.cfg.RootDirectory,
+		"accessKey":     &c.cfg.AccessKey,
+		"secretKey":     &c.cfg.SecretKey,
+	} {
+		v, ok := params[name]
+		if !ok {
+			continue
+		}
+		if *field, ok = v.(string); !ok {
+			return nil, fmt.Errorf("'%s' field was not a string", name)
+		}
+	}
+	if c.cfg.Endpoint == "" {
+		return nil, fmt.Errorf("'endpoint' field is missing")
+	}
+	if c.cfg.Bucket == "" {
+		return nil, fmt.Errorf("'bucket' field is missing")
+	}
+	if c.cfg.AccessKey == "" {
+		c.cfg.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if c.cfg.SecretKey == "" {
+		c.cfg.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	for name, field := range map[string]*int{
+		"workers":           &c.cfg.Workers,
+		"metadataCacheSize": &c.cfg.MetadataCacheSize,
+	} {
+		v, ok := params[name]
+		if !ok {
+			continue
+		}
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("'%s' field was not a number", name)
+		}
+		*field = int(n)
+	}
+
+	if ps, ok := params["partSize"]; ok {
+		s, ok := ps.(string)
+		if !ok {
+			return nil, fmt.Errorf("'partSize' field was not a string")
+		}
+		n, err := humanize.ParseBytes(s)
+		if err != nil {
+			return nil, err
+		}
+		c.cfg.PartSize = int(n)
+	}
+
+	return &c, nil
+}
+
+func (c *s3dsDatastoreConfig) DiskSpec() DiskSpec {
+	return map[string]interface{}{
+		"type":          "s3ds",
+		"endpoint":      c.cfg.Endpoint,
+		"bucket":        c.cfg.Bucket,
+		"rootDirectory": c.cfg.RootDirectory,
+	}
+}
+
+func (c *s3dsDatastoreConfig) Create(string) (repo.Datastore, error) {
+	return s3ds.New(c.cfg)
+}
+
+// EnvDatastorePassphrase is the environment variable the passphrase of
+// encrypted datastores is read from.
+const EnvDatastorePassphrase = "IPFS_DATASTORE_PASSPHRASE"
+
+// encryptionFile holds the salt and the key check of an encrypted datastore,
+// relative to the repo root. Datastores mounted elsewhere than at the root
+// have their own, suffixed with their mountpoint.
+const encryptionFile = "datastore_encryption"
+
+const defaultEncryptionKeyName = "datastore"
+
+type encryptedDatastoreConfig struct {
+	child      DatastoreConfig
+	keySource  string
+	keyName    string
+	keyPath    string
+	mountpoint ds.Key
+}
+
+// EncryptedDatastoreConfig returns a DatastoreConfig for a datastore
+// encrypting the values stored in its child. The key is either derived from
+// a passphrase taken from IPFS_DATASTORE_PASSPHRASE, from a key in the
+// keystore, or read from a key file outside the repo; keys are generated if
+// needed.
+func EncryptedDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
+	childField, ok := params["child"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'child' field is missing or not a map")
+	}
+	child, err := AnyDatastoreConfig(childField)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &encryptedDatastoreConfig{
+		child:      child,
+		mountpoint: ds.NewKey("/"),
+	}
+	c.keySource, ok = params["keySource"].(string)
+	if !ok {
+		return nil, fmt.Errorf("'keySource' field is missing or not a string")
+	}
+	switch c.keySource {
+	case "passphrase":
+	case "keystore":
+		c.keyName = defaultEncryptionKeyName
+		if kn, ok := params["keyName"]; ok {
+			if c.keyName, ok = kn.(string); !ok {
+				return nil, fmt.Errorf("'keyName' field was not a string")
+			}
+		}
+	case "keyFile":
+		c.keyPath, ok = params["keyPath"].(string)
+		if !ok {
+			return nil, fmt.Errorf("'keyPath' field is missing or not a string")
+		}
+		if !filepath.IsAbs(c.keyPath) {
+			return nil, fmt.Errorf("'keyPath' must be an absolute path")
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized value for keySource: %s", c.keySource)
+	}
+	return c, nil
+}
+
+// DiskSpec includes the key source, as the data on disk can only be read with
+// the key it was written with. The location of a key file is left out, so
+// that the file can be moved.
+func (c *encryptedDatastoreConfig) DiskSpec() DiskSpec {
+	spec := map[string]interface{}{
+		"type":      "encrypted",
+		"keySource": c.keySource,
+		"child":     map[string]interface{}(c.child.DiskSpec()),
+	}
+	if c.keySource == "keystore" {
+		spec["keyName"] = c.keyName
+	}
+	return spec
+}
+
+func (c *encryptedDatastoreConfig) setMountpoint(prefix ds.Key) {
+	c.mountpoint = prefix
+}
+
+// paramsFile returns the name of the file holding the encryption parameters
+// of the datastore: datastore_encryption at the root, and e.g.
+// datastore_encryption_blocks for the datastore mounted at /blocks.
+func (c *encryptedDatastoreConfig) paramsFile() string {
+	if c.mountpoint.Equal(ds.NewKey("/")) {
+		return encryptionFile
+	}
+	name := strings.Replace(strings.TrimPrefix(c.mountpoint.String(), "/"), "/", "_", -1)
+	return encryptionFile + "_" + name
+}
+
+func (c *encryptedDatastoreConfig) Create(path string) (repo.Datastore, error) {
+	file := filepath.Join(path, c.paramsFile())
+	params, err := loadEncryptionParams(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	switch c.keySource {
+	case "passphrase":
+		pass := os.Getenv(EnvDatastorePassphrase)
+		if pass == "" {
+			return nil, fmt.Errorf("datastore is encrypted, set %s to its passphrase", EnvDatastorePassphrase)
+		}
+		key = encryptds.DeriveKey([]byte(pass), params.Salt, encryptds.DefaultIterations)
+	case "keystore":
+		key, err = keystoreEncryptionKey(path, c.keyName)
+		if err != nil {
+			return nil, err
+		}
+	case "keyFile":
+		key, err = fileEncryptionKey(path, c.keyPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params.Check == nil {
+		params.Check = encryptds.KeyCheck(key)
+		if err := saveEncryptionParams(file, params); err != nil {
+			return nil, err
+		}
+	} else if !bytes.Equal(params.Check, encryptds.KeyCheck(key)) {
+		return nil, fmt.Errorf("wrong datastore encryption key")
+	}
+
+	child, err := c.child.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return encryptds.New(child, key)
+}
+
+type encryptionParams struct {
+	Salt  []byte
+	Check []byte `json:",omitempty"`
+}
+
+func loadEncryptionParams(file string) (*encryptionParams, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		p := &encryptionParams{Salt: make([]byte, 16)}
+		if _, err := rand.Read(p.Salt); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p encryptionParams
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("invalid %s file: %s", filepath.Base(file), err)
+	}
+	return &p, nil
+}
+
+func saveEncryptionParams(file string, p *encryptionParams) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0600)
+}
+
+// keystoreEncryptionKey derives the key of an encrypted datastore from the key
+// name in the keystore of the repo at path, generating it if needed. The key
+// is stored unencrypted in the repo: it only protects the data if the
+// keystore is kept on another disk than the datastore.
+func keystoreEncryptionKey(path, name string) ([]byte, error) {
+	ks, err := keystore.NewFSKeystore(filepath.Join(path, "keystore"))
+	if err != nil {
+		return nil, err
+	}
+
+	sk, err := ks.Get(name)
+	if err == keystore.ErrNoSuchKey {
+		sk, _, err = ci.GenerateKeyPair(ci.Ed25519, 256)
+		if err != nil {
+			return nil, err
+		}
+		err = ks.Put(name, sk)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return encryptds.KeyFromPrivKey(sk)
+}
+
+// fileEncryptionKey reads the key of an encrypted datastore from keyPath,
+// generating it if the file doesn't exist. The key has to be kept outside of
+// the repo at path, a key stored next to the data wouldn't protect it.
+func fileEncryptionKey(path, keyPath string) ([]byte, error) {
+	repoPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(repoPath, filepath.Clean(keyPath))
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("the datastore key file %s is inside the repo", keyPath)
+	}
+
+	key, err := ioutil.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		key = make([]byte, encryptds.KeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(keyPath, key, 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != encryptds.KeySize {
+		return nil, fmt.Errorf("the datastore key file %s doesn't hold a %d bytes key", keyPath, encryptds.KeySize)
+	}
+	return key, nil
+}
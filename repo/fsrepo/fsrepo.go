@@ -171,7 +171,7 @@ func open(repoPath string) (repo.Repo, error) {
 		return nil, err
 	}
 
-	if r.config.Experimental.FilestoreEnabled {
+	if r.config.Experimental.FilestoreEnabled || r.config.Experimental.UrlstoreEnabled {
 		r.filemgr = filestore.NewFileManager(r.ds, filepath.Dir(r.path))
 	}
 
@@ -543,6 +543,28 @@ func (r *FSRepo) SetConfig(updated *config.Config) error {
 	return r.setConfigUnsynced(updated)
 }
 
+// ReloadConfig reads the config file again, picking up the changes made to
+// it since the repo was opened.
+func (r *FSRepo) ReloadConfig() error {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	if r.closed {
+		return errors.New("repo is closed")
+	}
+
+	configFilename, err := config.Filename(r.path)
+	if err != nil {
+		return err
+	}
+	conf, err := serialize.Load(configFilename)
+	if err != nil {
+		return err
+	}
+	*r.config = *conf
+	return nil
+}
+
 // GetConfigKey retrieves only the value of a particular key.
 func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 	packageLock.Lock()
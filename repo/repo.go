@@ -62,3 +62,23 @@ type Datastore interface {
 	ds.Batching // should be threadsafe, just be careful
 	io.Closer
 }
+
+// ConfigReloader is a repo whose config can be changed behind its back, e.g.
+// by editing its config file.
+type ConfigReloader interface {
+	// ReloadConfig reads the config from storage again.
+	ReloadConfig() error
+}
+
+// Compacter is a repo whose storage can reclaim space left behind by deleted
+// or partially written data.
+type Compacter interface {
+	Compact() error
+}
+
+// TempFileCounter is a repo which can report temporary files left behind by
+// interrupted writes.
+type TempFileCounter interface {
+	// OrphanedTempFiles returns the number and total size of such files.
+	OrphanedTempFiles() (count uint64, size uint64, err error)
+}
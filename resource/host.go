@@ -0,0 +1,120 @@
+package resource
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("resource")
+
+// trimInterval is the minimum interval between two trims of the connections
+// asked by a Manager.
+var trimInterval = 10 * time.Second
+
+// managedHost accounts for the connections and streams of its host.
+type managedHost struct {
+	host.Host
+	m *Manager
+}
+
+// Wrap returns h, accounting for its connections and streams in m. Streams
+// are only accounted for when their handlers are set through the returned
+// host, or when opened through it. Connections and streams over the limits
+// are closed, and opening them returns a *LimitError.
+func Wrap(h host.Host, m *Manager) host.Host {
+	var lk sync.Mutex
+	var last time.Time
+	m.lk.Lock()
+	m.trim = func() {
+		lk.Lock()
+		defer lk.Unlock()
+		if time.Since(last) < trimInterval {
+			return
+		}
+		last = time.Now()
+		go h.ConnManager().TrimOpenConns(context.Background())
+	}
+	m.lk.Unlock()
+
+	h.Network().Notify((*notifee)(m))
+	return &managedHost{Host: h, m: m}
+}
+
+func (h *managedHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.Host.SetStreamHandler(pid, h.handler(handler))
+}
+
+func (h *managedHost) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler inet.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(pid, match, h.handler(handler))
+}
+
+// handler returns handler, refusing the streams over the limits.
+func (h *managedHost) handler(handler inet.StreamHandler) inet.StreamHandler {
+	return func(s inet.Stream) {
+		release, err := h.m.OpenStream(s.Conn().RemotePeer(), s.Protocol(), true)
+		if err != nil {
+			log.Debugf("refusing stream %s from %s: %s", s.Protocol(), s.Conn().RemotePeer(), err)
+			s.Reset()
+			return
+		}
+		handler(&stream{Stream: s, release: release})
+	}
+}
+
+func (h *managedHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	// the protocol is only known once negotiated
+	release, err := h.m.OpenStream(p, s.Protocol(), false)
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+	return &stream{Stream: s, release: release}, nil
+}
+
+// stream releases its resources once closed or reset.
+type stream struct {
+	inet.Stream
+	release func()
+}
+
+func (s *stream) Close() error {
+	defer s.release()
+	return s.Stream.Close()
+}
+
+func (s *stream) Reset() error {
+	defer s.release()
+	return s.Stream.Reset()
+}
+
+// notifee accounts for the connections of the network.
+type notifee Manager
+
+func (nn *notifee) Connected(n inet.Network, c inet.Conn) {
+	if err := (*Manager)(nn).OpenConn(c); err != nil {
+		log.Debugf("closing connection to %s: %s", c.RemotePeer(), err)
+		// not while the network notifies
+		go c.Close()
+	}
+}
+
+func (nn *notifee) Disconnected(n inet.Network, c inet.Conn) {
+	(*Manager)(nn).CloseConn(c)
+}
+
+func (nn *notifee) Listen(inet.Network, ma.Multiaddr)      {}
+func (nn *notifee) ListenClose(inet.Network, ma.Multiaddr) {}
+func (nn *notifee) OpenedStream(inet.Network, inet.Stream) {}
+func (nn *notifee) ClosedStream(inet.Network, inet.Stream) {}
@@ -0,0 +1,361 @@
+// Package resource accounts for the connections, streams and memory used by
+// the node, by subsystem, protocol and peer, and refuses the ones above the
+// configured limits.
+//
+// Limits degrade gracefully: when the connections of the node reach their
+// limit, the connection manager is asked to trim its connections first, and
+// the last tenth of every limit of the node is kept for the streams the node
+// opens itself, refusing the streams opened by other peers.
+package resource
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+)
+
+// StreamMemory is the memory accounted for each open stream, an estimate of
+// the buffers of the stream multiplexer.
+var StreamMemory int64 = 64 << 10
+
+// Limit bounds the resources of a scope. Zero fields are unlimited.
+type Limit struct {
+	Conns   int
+	Streams int
+	Memory  int64
+}
+
+// Usage is the resources used in a scope.
+type Usage struct {
+	Conns      int
+	StreamsIn  int
+	StreamsOut int
+	Memory     int64
+}
+
+// Limits are the limits of the scopes of a Manager.
+type Limits struct {
+	// System is the limit of the whole node.
+	System Limit
+	// Peer is the limit of each peer.
+	Peer Limit
+	// Subsystems are the limits of subsystems, by name. See Subsystem.
+	Subsystems map[string]Limit
+	// Protocols are the limits of protocols.
+	Protocols map[protocol.ID]Limit
+}
+
+// LimitError is returned when a resource is refused.
+type LimitError struct {
+	Scope    string
+	Resource string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("resource limit of %s exceeded: %s", e.Scope, e.Resource)
+}
+
+// subsystems maps the prefixes of protocols to their subsystem.
+var subsystems = []struct {
+	prefix    string
+	subsystem string
+}{
+	{"/ipfs/bitswap", "bitswap"},
+	{"/ipfs/kad/", "dht"},
+	{"/ipfs/dht", "dht"},
+	{"/floodsub/", "pubsub"},
+	{"/meshsub/", "pubsub"},
+	{"/ipfs/ping/", "ping"},
+	{"/ipfs/id/", "identify"},
+	{"/libp2p/circuit/relay/", "relay"},
+	{"/x/", "p2p"},
+}
+
+// Subsystem returns the subsystem of protocol pid: one of "bitswap", "dht",
+// "pubsub", "ping", "identify", "relay" and "p2p" (the streams of 'ipfs
+// p2p'), or "other".
+func Subsystem(pid protocol.ID) string {
+	for _, s := range subsystems {
+		if strings.HasPrefix(string(pid), s.prefix) {
+			return s.subsystem
+		}
+	}
+	return "other"
+}
+
+// scope is a set of resources under a limit.
+type scope struct {
+	name  string
+	limit Limit
+	usage Usage
+}
+
+// check returns the error of adding d to the usage of s. Only the given share
+// of the limits is available.
+func (s *scope) check(d Usage, share float64) error {
+	l := s.limit
+	if l.Conns > 0 && d.Conns > 0 && float64(s.usage.Conns+d.Conns) > float64(l.Conns)*share {
+		return &LimitError{Scope: s.name, Resource: "connections"}
+	}
+	streams := s.usage.StreamsIn + s.usage.StreamsOut + d.StreamsIn + d.StreamsOut
+	if l.Streams > 0 && d.StreamsIn+d.StreamsOut > 0 && float64(streams) > float64(l.Streams)*share {
+		return &LimitError{Scope: s.name, Resource: "streams"}
+	}
+	if l.Memory > 0 && d.Memory > 0 && float64(s.usage.Memory+d.Memory) > float64(l.Memory)*share {
+		return &LimitError{Scope: s.name, Resource: "memory"}
+	}
+	return nil
+}
+
+func (s *scope) add(d Usage, sign int) {
+	s.usage.Conns += sign * d.Conns
+	s.usage.StreamsIn += sign * d.StreamsIn
+	s.usage.StreamsOut += sign * d.StreamsOut
+	s.usage.Memory += int64(sign) * d.Memory
+}
+
+func (s *scope) unused() bool {
+	return s.usage == Usage{}
+}
+
+// inboundShare is the share of the limits of the node available to the
+// streams opened by other peers.
+const inboundShare = 0.9
+
+// Manager accounts for the resources of the node.
+type Manager struct {
+	lk         sync.Mutex
+	limits     Limits
+	system     *scope
+	subsystems map[string]*scope
+	protocols  map[protocol.ID]*scope
+	peers      map[peer.ID]*scope
+	conns      map[inet.Conn]struct{}
+
+	// trim asks the connection manager to close connections, when the
+	// connections of the node reach their limit
+	trim func()
+}
+
+// NewManager returns a manager enforcing limits.
+func NewManager(limits Limits) *Manager {
+	m := &Manager{
+		system:     &scope{name: "system"},
+		subsystems: make(map[string]*scope),
+		protocols:  make(map[protocol.ID]*scope),
+		peers:      make(map[peer.ID]*scope),
+		conns:      make(map[inet.Conn]struct{}),
+	}
+	m.SetLimits(limits)
+	return m
+}
+
+// SetLimits replaces the limits. Resources in use above the new limits are
+// kept, new ones are refused until the usage goes below the limits.
+func (m *Manager) SetLimits(limits Limits) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.limits = limits
+	m.system.limit = limits.System
+	for name, s := range m.subsystems {
+		s.limit = limits.Subsystems[name]
+	}
+	for pid, s := range m.protocols {
+		s.limit = limits.Protocols[pid]
+	}
+	for _, s := range m.peers {
+		s.limit = limits.Peer
+	}
+}
+
+func (m *Manager) subsystemScope(name string) *scope {
+	s, ok := m.subsystems[name]
+	if !ok {
+		s = &scope{name: "subsystem " + name, limit: m.limits.Subsystems[name]}
+		m.subsystems[name] = s
+	}
+	return s
+}
+
+func (m *Manager) protocolScope(pid protocol.ID) *scope {
+	s, ok := m.protocols[pid]
+	if !ok {
+		s = &scope{name: "protocol " + string(pid), limit: m.limits.Protocols[pid]}
+		m.protocols[pid] = s
+	}
+	return s
+}
+
+func (m *Manager) peerScope(p peer.ID) *scope {
+	s, ok := m.peers[p]
+	if !ok {
+		s = &scope{name: "peer " + p.Pretty(), limit: m.limits.Peer}
+		m.peers[p] = s
+	}
+	return s
+}
+
+// reserve adds d to the scopes if none of their limits is exceeded. share
+// is the share of the limits of the node available.
+func (m *Manager) reserve(scopes []*scope, d Usage, share float64) error {
+	for _, s := range scopes {
+		sh := 1.0
+		if s == m.system {
+			sh = share
+		}
+		if err := s.check(d, sh); err != nil {
+			m.cleanup(scopes)
+			return err
+		}
+	}
+	for _, s := range scopes {
+		s.add(d, 1)
+	}
+	return nil
+}
+
+func (m *Manager) release(scopes []*scope, d Usage) {
+	for _, s := range scopes {
+		s.add(d, -1)
+	}
+	m.cleanup(scopes)
+}
+
+// cleanup forgets the unused scopes of peers and protocols, which come and
+// go.
+func (m *Manager) cleanup(scopes []*scope) {
+	for p, s := range m.peers {
+		if s.unused() && containsScope(scopes, s) {
+			delete(m.peers, p)
+		}
+	}
+	for pid, s := range m.protocols {
+		if s.unused() && containsScope(scopes, s) {
+			delete(m.protocols, pid)
+		}
+	}
+}
+
+func containsScope(scopes []*scope, s *scope) bool {
+	for _, sc := range scopes {
+		if sc == s {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenConn accounts for c, unless it exceeds the limits of the node or of
+// its peer.
+func (m *Manager) OpenConn(c inet.Conn) error {
+	m.lk.Lock()
+	scopes := []*scope{m.system, m.peerScope(c.RemotePeer())}
+	err := m.reserve(scopes, Usage{Conns: 1}, 1)
+	if err == nil {
+		m.conns[c] = struct{}{}
+	}
+	trim := m.trim
+	full := m.limits.System.Conns > 0 && m.system.usage.Conns >= m.limits.System.Conns
+	m.lk.Unlock()
+
+	if full && trim != nil {
+		trim()
+	}
+	return err
+}
+
+// CloseConn releases the resources of c.
+func (m *Manager) CloseConn(c inet.Conn) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	if _, ok := m.conns[c]; !ok {
+		return
+	}
+	delete(m.conns, c)
+	m.release([]*scope{m.system, m.peerScope(c.RemotePeer())}, Usage{Conns: 1})
+}
+
+// OpenStream accounts for a stream of pid with p, opened by p when inbound,
+// unless it exceeds the limits of the node, the subsystem, the protocol or
+// the peer. The returned function releases the stream.
+func (m *Manager) OpenStream(p peer.ID, pid protocol.ID, inbound bool) (func(), error) {
+	d := Usage{StreamsOut: 1, Memory: StreamMemory}
+	share := 1.0
+	if inbound {
+		d = Usage{StreamsIn: 1, Memory: StreamMemory}
+		share = inboundShare
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	scopes := []*scope{m.system, m.subsystemScope(Subsystem(pid)), m.protocolScope(pid), m.peerScope(p)}
+	if err := m.reserve(scopes, d, share); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.lk.Lock()
+			defer m.lk.Unlock()
+			m.release(scopes, d)
+		})
+	}, nil
+}
+
+// Reserve accounts for n bytes of memory used by subsystem, unless it
+// exceeds the limits of the node or of the subsystem.
+func (m *Manager) Reserve(subsystem string, n int64) error {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.reserve([]*scope{m.system, m.subsystemScope(subsystem)}, Usage{Memory: n}, 1)
+}
+
+// Release releases n bytes of memory reserved by subsystem.
+func (m *Manager) Release(subsystem string, n int64) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.release([]*scope{m.system, m.subsystemScope(subsystem)}, Usage{Memory: n})
+}
+
+// ScopeStat is the usage and the limit of a scope.
+type ScopeStat struct {
+	Usage Usage
+	Limit Limit
+}
+
+// Stat is the usage of the resources of the node.
+type Stat struct {
+	System     ScopeStat
+	Subsystems map[string]ScopeStat
+	Protocols  map[string]ScopeStat
+	Peers      map[string]ScopeStat
+}
+
+// Stat returns the current usage of the resources, of the node and of the
+// subsystems, protocols and peers using some.
+func (m *Manager) Stat() Stat {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	st := Stat{
+		System:     ScopeStat{Usage: m.system.usage, Limit: m.system.limit},
+		Subsystems: make(map[string]ScopeStat, len(m.subsystems)),
+		Protocols:  make(map[string]ScopeStat, len(m.protocols)),
+		Peers:      make(map[string]ScopeStat, len(m.peers)),
+	}
+	for name, s := range m.subsystems {
+		st.Subsystems[name] = ScopeStat{Usage: s.usage, Limit: s.limit}
+	}
+	for pid, s := range m.protocols {
+		st.Protocols[string(pid)] = ScopeStat{Usage: s.usage, Limit: s.limit}
+	}
+	for p, s := range m.peers {
+		st.Peers[p.Pretty()] = ScopeStat{Usage: s.usage, Limit: s.limit}
+	}
+	return st
+}
@@ -0,0 +1,138 @@
+package resource
+
+import (
+	"testing"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+)
+
+func TestSubsystem(t *testing.T) {
+	cases := map[protocol.ID]string{
+		"/ipfs/bitswap/1.1.0":    "bitswap",
+		"/ipfs/kad/1.0.0":        "dht",
+		"/floodsub/1.0.0":        "pubsub",
+		"/x/ssh":                 "p2p",
+		"/ipfs/ping/1.0.0":       "ping",
+		"/something/else/1.0.0":  "other",
+		"/libp2p/circuit/relay/": "relay",
+	}
+	for pid, expected := range cases {
+		if s := Subsystem(pid); s != expected {
+			t.Errorf("subsystem of %s: expected %s, got %s", pid, expected, s)
+		}
+	}
+}
+
+func TestStreamLimits(t *testing.T) {
+	p1 := peer.ID("peer1")
+	p2 := peer.ID("peer2")
+	m := NewManager(Limits{
+		Peer:       Limit{Streams: 2},
+		Subsystems: map[string]Limit{"dht": {Streams: 3}},
+	})
+
+	var releases []func()
+	open := func(p peer.ID, pid protocol.ID) error {
+		release, err := m.OpenStream(p, pid, false)
+		if err == nil {
+			releases = append(releases, release)
+		}
+		return err
+	}
+
+	if err := open(p1, "/ipfs/kad/1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := open(p1, "/ipfs/kad/1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := open(p1, "/ipfs/bitswap/1.1.0"); err == nil {
+		t.Fatal("expected the limit of the peer to be exceeded")
+	}
+	if err := open(p2, "/ipfs/kad/1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	err := open(p2, "/ipfs/kad/1.0.0")
+	if lerr, ok := err.(*LimitError); !ok || lerr.Scope != "subsystem dht" {
+		t.Fatalf("expected the limit of the dht to be exceeded, got %v", err)
+	}
+	if err := open(p2, "/ipfs/bitswap/1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	st := m.Stat()
+	if st.System.Usage.StreamsOut != 4 || st.System.Usage.Memory != 4*StreamMemory {
+		t.Fatalf("unexpected usage of the node: %+v", st.System.Usage)
+	}
+	if st.Subsystems["dht"].Usage.StreamsOut != 3 {
+		t.Fatalf("unexpected usage of the dht: %+v", st.Subsystems["dht"].Usage)
+	}
+
+	for _, release := range releases {
+		release()
+		// releasing again does nothing
+		release()
+	}
+	st = m.Stat()
+	if st.System.Usage != (Usage{}) {
+		t.Fatalf("expected all resources to be released, got %+v", st.System.Usage)
+	}
+	if len(st.Peers) != 0 || len(st.Protocols) != 0 {
+		t.Fatal("expected the unused peers and protocols to be forgotten")
+	}
+}
+
+func TestInboundShare(t *testing.T) {
+	m := NewManager(Limits{System: Limit{Streams: 10}})
+
+	p := peer.ID("peer")
+	for i := 0; i < 9; i++ {
+		if _, err := m.OpenStream(p, "/ipfs/bitswap/1.1.0", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.OpenStream(p, "/ipfs/bitswap/1.1.0", true); err == nil {
+		t.Fatal("expected the last tenth of the limit to be kept for outbound streams")
+	}
+	if _, err := m.OpenStream(p, "/ipfs/bitswap/1.1.0", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.OpenStream(p, "/ipfs/bitswap/1.1.0", false); err == nil {
+		t.Fatal("expected the limit of the node to be exceeded")
+	}
+}
+
+func TestReserveMemory(t *testing.T) {
+	m := NewManager(Limits{
+		System:     Limit{Memory: 1000},
+		Subsystems: map[string]Limit{"bitswap": {Memory: 600}},
+	})
+
+	if err := m.Reserve("bitswap", 500); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reserve("bitswap", 200); err == nil {
+		t.Fatal("expected the limit of bitswap to be exceeded")
+	}
+	if err := m.Reserve("dht", 500); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reserve("dht", 1); err == nil {
+		t.Fatal("expected the limit of the node to be exceeded")
+	}
+
+	m.Release("bitswap", 500)
+	if err := m.Reserve("dht", 400); err != nil {
+		t.Fatal(err)
+	}
+
+	// lowering the limits keeps the resources in use
+	m.SetLimits(Limits{System: Limit{Memory: 100}})
+	if u := m.Stat().System.Usage.Memory; u != 900 {
+		t.Fatalf("expected 900 bytes in use, got %d", u)
+	}
+	if err := m.Reserve("dht", 1); err == nil {
+		t.Fatal("expected the new limit of the node to be exceeded")
+	}
+}
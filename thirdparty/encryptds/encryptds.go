@@ -0,0 +1,209 @@
+// Package encryptds implements a datastore encrypting the values stored in
+// another datastore, so that the data on disk can't be read without the key.
+// Keys are stored unencrypted.
+package encryptds
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	pbkdf2 "github.com/ipfs/go-ipfs/thirdparty/pbkdf2"
+
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	goprocess "github.com/jbenet/goprocess"
+	ci "github.com/libp2p/go-libp2p-crypto"
+)
+
+// KeySize is the size of the keys used, in bytes.
+const KeySize = 32
+
+// DefaultIterations is the default number of PBKDF2 iterations DeriveKey is
+// used with.
+const DefaultIterations = 100000
+
+// ErrDecrypt is returned when a value can't be decrypted, because it was
+// stored with another key or it was corrupted.
+var ErrDecrypt = errors.New("encryptds: value can't be decrypted with this key")
+
+// Datastore encrypts the values stored in a child datastore with AES-GCM.
+type Datastore struct {
+	child ds.Batching
+	aead  cipher.AEAD
+}
+
+var _ ds.Batching = (*Datastore)(nil)
+
+// New returns a datastore storing the values encrypted with key in child.
+func New(child ds.Batching, key []byte) (*Datastore, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("encryptds: invalid key size")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Datastore{child: child, aead: aead}, nil
+}
+
+// DeriveKey derives a key from a passphrase with PBKDF2-HMAC-SHA256.
+func DeriveKey(passphrase, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(passphrase, salt, iterations, KeySize, sha256.New)
+}
+
+// KeyFromPrivKey derives a key from a private key, e.g. one kept in the
+// keystore.
+func KeyFromPrivKey(sk ci.PrivKey) ([]byte, error) {
+	b, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, b)
+	mac.Write([]byte("ipfs datastore encryption"))
+	return mac.Sum(nil), nil
+}
+
+// KeyCheck returns a value identifying key without revealing it, to detect
+// a wrong key before any values are decrypted.
+func KeyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("ipfs datastore key check"))
+	return mac.Sum(nil)
+}
+
+func (d *Datastore) seal(k ds.Key, value interface{}) ([]byte, error) {
+	plain, ok := value.([]byte)
+	if !ok {
+		return nil, ds.ErrInvalidType
+	}
+
+	nonce := make([]byte, d.aead.NonceSize(), d.aead.NonceSize()+len(plain)+d.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// binding the key keeps values from being swapped between keys
+	return d.aead.Seal(nonce, nonce, plain, k.Bytes()), nil
+}
+
+func (d *Datastore) open(k ds.Key, value interface{}) ([]byte, error) {
+	sealed, ok := value.([]byte)
+	if !ok {
+		return nil, ds.ErrInvalidType
+	}
+	ns := d.aead.NonceSize()
+	if len(sealed) < ns {
+		return nil, ErrDecrypt
+	}
+	plain, err := d.aead.Open(nil, sealed[:ns], sealed[ns:], k.Bytes())
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// Put implements Datastore. Values have to be byte slices.
+func (d *Datastore) Put(k ds.Key, value interface{}) error {
+	sealed, err := d.seal(k, value)
+	if err != nil {
+		return err
+	}
+	return d.child.Put(k, sealed)
+}
+
+// Get implements Datastore.
+func (d *Datastore) Get(k ds.Key) (interface{}, error) {
+	val, err := d.child.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	return d.open(k, val)
+}
+
+// Has implements Datastore.
+func (d *Datastore) Has(k ds.Key) (bool, error) {
+	return d.child.Has(k)
+}
+
+// Delete implements Datastore.
+func (d *Datastore) Delete(k ds.Key) error {
+	return d.child.Delete(k)
+}
+
+// Query implements Datastore. Filters and orders are applied to the
+// decrypted values.
+func (d *Datastore) Query(q dsq.Query) (dsq.Results, error) {
+	res, err := d.child.Query(dsq.Query{
+		Prefix:   q.Prefix,
+		KeysOnly: q.KeysOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !q.KeysOnly {
+		child := res
+		res = dsq.ResultsWithProcess(q, func(worker goprocess.Process, out chan<- dsq.Result) {
+			defer child.Close()
+			for r := range child.Next() {
+				if r.Error == nil {
+					r.Value, r.Error = d.open(ds.RawKey(r.Key), r.Value)
+				}
+				select {
+				case out <- r:
+				case <-worker.Closing():
+					return
+				}
+			}
+		})
+	}
+
+	q.Prefix = ""
+	return dsq.NaiveQueryApply(q, res), nil
+}
+
+// Batch implements Batching.
+func (d *Datastore) Batch() (ds.Batch, error) {
+	b, err := d.child.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &batch{d: d, b: b}, nil
+}
+
+// Close closes the child datastore, if it can be closed.
+func (d *Datastore) Close() error {
+	if c, ok := d.child.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type batch struct {
+	d *Datastore
+	b ds.Batch
+}
+
+func (b *batch) Put(k ds.Key, value interface{}) error {
+	sealed, err := b.d.seal(k, value)
+	if err != nil {
+		return err
+	}
+	return b.b.Put(k, sealed)
+}
+
+func (b *batch) Delete(k ds.Key) error {
+	return b.b.Delete(k)
+}
+
+func (b *batch) Commit() error {
+	return b.b.Commit()
+}
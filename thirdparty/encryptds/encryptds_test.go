@@ -0,0 +1,110 @@
+package encryptds
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+func newStore(t *testing.T, pass string) (*Datastore, *ds.MapDatastore) {
+	child := ds.NewMapDatastore()
+	d, err := New(child, DeriveKey([]byte(pass), []byte("salt"), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, child
+}
+
+func TestRoundTrip(t *testing.T) {
+	d, child := newStore(t, "secret")
+	k := ds.NewKey("/blocks/foo")
+	plain := []byte("some plaintext value")
+
+	if err := d.Put(k, plain); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := child.Get(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw.([]byte), plain) {
+		t.Fatal("child datastore holds the plaintext")
+	}
+
+	val, err := d.Get(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val.([]byte), plain) {
+		t.Fatalf("expected %q, got %q", plain, val)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	d, child := newStore(t, "secret")
+	k := ds.NewKey("/foo")
+	if err := d.Put(k, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := New(child, DeriveKey([]byte("other"), []byte("salt"), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Get(k); err != ErrDecrypt {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+
+	// a value moved to another key must not decrypt either
+	raw, _ := child.Get(k)
+	child.Put(ds.NewKey("/bar"), raw)
+	if _, err := d.Get(ds.NewKey("/bar")); err != ErrDecrypt {
+		t.Fatalf("expected ErrDecrypt for a moved value, got %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	d, _ := newStore(t, "secret")
+	for _, k := range []string{"/a/1", "/a/2", "/b/1"} {
+		if err := d.Put(ds.NewKey(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := d.Query(dsq.Query{Prefix: "/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	for _, e := range entries {
+		if string(e.Value.([]byte)) != e.Key {
+			t.Fatalf("entry %s has value %q", e.Key, e.Value)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 test vectors
+	for _, tc := range []struct {
+		iterations int
+		expected   string
+	}{
+		{1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+	} {
+		key := hex.EncodeToString(DeriveKey([]byte("password"), []byte("salt"), tc.iterations))
+		if key != tc.expected {
+			t.Errorf("%d iterations: expected %s, got %s", tc.iterations, tc.expected, key)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+// Package s3ds implements a datastore storing values as objects in an S3
+// compatible object storage service.
+package s3ds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	goprocess "github.com/jbenet/goprocess"
+)
+
+var log = logging.Logger("s3ds")
+
+const (
+	// DefaultWorkers is the default number of requests a batch runs at once.
+	DefaultWorkers = 16
+
+	// DefaultPartSize is the default size of multipart upload parts.
+	DefaultPartSize = 8 << 20
+
+	// DefaultMetadataCacheSize is the default number of keys whose existence
+	// is remembered locally.
+	DefaultMetadataCacheSize = 1 << 16
+
+	// minPartSize is the smallest part size S3 accepts.
+	minPartSize = 5 << 20
+)
+
+// Config configures a Datastore.
+type Config struct {
+	// Endpoint is the base URL of the service, e.g.
+	// "https://s3.us-east-1.amazonaws.com". Buckets are addressed by path.
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	// RootDirectory is prepended to the names of all objects.
+	RootDirectory string
+
+	AccessKey string
+	SecretKey string
+
+	// Workers bounds the number of requests a batch commit runs at once.
+	Workers int
+
+	// PartSize is the size of multipart upload parts. Values larger than
+	// this are uploaded in parts, concurrently.
+	PartSize int
+
+	// MetadataCacheSize is the number of keys whose existence is cached, so
+	// that Has doesn't need a request for recently seen keys.
+	MetadataCacheSize int
+
+	// Client is used to make requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Datastore stores values as objects in a bucket. It assumes it is the only
+// writer under its root directory.
+type Datastore struct {
+	cfg    Config
+	base   *url.URL
+	client *http.Client
+
+	// known caches the keys known to exist
+	known *lru.Cache
+}
+
+var _ ds.Batching = (*Datastore)(nil)
+
+// New creates a datastore from cfg.
+func New(cfg Config) (*Datastore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3ds: no bucket given")
+	}
+	base, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3ds: invalid endpoint: %s", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	if cfg.PartSize < minPartSize {
+		cfg.PartSize = minPartSize
+	}
+	if cfg.MetadataCacheSize <= 0 {
+		cfg.MetadataCacheSize = DefaultMetadataCacheSize
+	}
+	cfg.RootDirectory = strings.Trim(cfg.RootDirectory, "/")
+
+	known, err := lru.New(cfg.MetadataCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Datastore{
+		cfg:    cfg,
+		base:   base,
+		client: client,
+		known:  known,
+	}, nil
+}
+
+// objectName returns the name of the object holding k.
+func (d *Datastore) objectName(k ds.Key) string {
+	name := strings.TrimPrefix(k.String(), "/")
+	if d.cfg.RootDirectory == "" {
+		return name
+	}
+	return d.cfg.RootDirectory + "/" + name
+}
+
+// objectKey returns the datastore key stored in the given object.
+func (d *Datastore) objectKey(name string) ds.Key {
+	if d.cfg.RootDirectory != "" {
+		name = strings.TrimPrefix(name, d.cfg.RootDirectory+"/")
+	}
+	return ds.NewKey(name)
+}
+
+// responseError is an error response of the service.
+type responseError struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *responseError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3ds: request failed with status %d", e.Status)
+	}
+	return fmt.Sprintf("s3ds: %s: %s", e.Code, e.Message)
+}
+
+// do sends a signed request for object (or the bucket if object is empty)
+// and returns the response if its status is 2xx.
+func (d *Datastore) do(method, object string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u := *d.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + d.cfg.Bucket
+	if object != "" {
+		u.Path += "/" + object
+	}
+	u.RawPath = strings.TrimSuffix(d.base.EscapedPath(), "/") + "/" + uriEncode(d.cfg.Bucket, false)
+	if object != "" {
+		u.RawPath += "/" + uriEncode(object, true)
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	sign(req, d.cfg.AccessKey, d.cfg.SecretKey, d.cfg.Region, hashHex(body), time.Now())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ds.ErrNotFound
+	}
+	rerr := &responseError{Status: resp.StatusCode}
+	if data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16)); err == nil {
+		xml.Unmarshal(data, rerr)
+	}
+	return nil, rerr
+}
+
+// Put stores value, which must be a []byte, under key.
+func (d *Datastore) Put(key ds.Key, value interface{}) error {
+	val, ok := value.([]byte)
+	if !ok {
+		return ds.ErrInvalidType
+	}
+
+	name := d.objectName(key)
+	if len(val) > d.cfg.PartSize {
+		if err := d.putMultipart(name, val); err != nil {
+			return err
+		}
+	} else {
+		resp, err := d.do("PUT", name, nil, val, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+
+	d.known.Add(key.String(), struct{}{})
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// putMultipart uploads val in parts, running up to Workers uploads at once.
+func (d *Datastore) putMultipart(name string, val []byte) error {
+	resp, err := d.do("POST", name, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	uploadID := initiated.UploadID
+
+	nparts := (len(val) + d.cfg.PartSize - 1) / d.cfg.PartSize
+	parts := make([]completedPart, nparts)
+	err = d.parallel(nparts, func(i int) error {
+		start := i * d.cfg.PartSize
+		end := start + d.cfg.PartSize
+		if end > len(val) {
+			end = len(val)
+		}
+		q := url.Values{
+			"partNumber": {strconv.Itoa(i + 1)},
+			"uploadId":   {uploadID},
+		}
+		resp, err := d.do("PUT", name, q, val[start:end], nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		parts[i] = completedPart{PartNumber: i + 1, ETag: resp.Header.Get("ETag")}
+		return nil
+	})
+	if err != nil {
+		if resp, aerr := d.do("DELETE", name, url.Values{"uploadId": {uploadID}}, nil, nil); aerr == nil {
+			resp.Body.Close()
+		} else {
+			log.Warningf("aborting multipart upload of %s: %s", name, aerr)
+		}
+		return err
+	}
+
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err = d.do("POST", name, url.Values{"uploadId": {uploadID}}, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the service may report an error with a 200 status
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		rerr := &responseError{Status: resp.StatusCode}
+		xml.Unmarshal(data, rerr)
+		return rerr
+	}
+	return nil
+}
+
+// parallel runs fn for 0..n-1, at most Workers at once, and returns the
+// first error.
+func (d *Datastore) parallel(n int, fn func(i int) error) error {
+	work := make(chan int)
+	errs := make(chan error, n)
+
+	workers := d.cfg.Workers
+	if workers > n {
+		workers = n
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if err := fn(i); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// Get returns the value stored under key.
+func (d *Datastore) Get(key ds.Key) (interface{}, error) {
+	resp, err := d.do("GET", d.objectName(key), nil, nil, nil)
+	if err == ds.ErrNotFound {
+		d.known.Remove(key.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	val, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	d.known.Add(key.String(), struct{}{})
+	return val, nil
+}
+
+// Has returns whether key is stored, answering from the metadata cache if
+// possible.
+func (d *Datastore) Has(key ds.Key) (bool, error) {
+	if d.known.Contains(key.String()) {
+		return true, nil
+	}
+
+	resp, err := d.do("HEAD", d.objectName(key), nil, nil, nil)
+	switch err {
+	case nil:
+		resp.Body.Close()
+		d.known.Add(key.String(), struct{}{})
+		return true, nil
+	case ds.ErrNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Delete removes key. S3 doesn't report whether the object existed, so this
+// only returns ds.ErrNotFound if the key is known to be missing.
+func (d *Datastore) Delete(key ds.Key) error {
+	has, err := d.Has(key)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ds.ErrNotFound
+	}
+
+	resp, err := d.do("DELETE", d.objectName(key), nil, nil, nil)
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	d.known.Remove(key.String())
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Query lists the objects under the query's prefix. Values are fetched one
+// by one unless the query is keys only.
+func (d *Datastore) Query(q dsq.Query) (dsq.Results, error) {
+	prefix := d.objectName(ds.NewKey(q.Prefix))
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	qr := dsq.ResultsWithProcess(q, func(worker goprocess.Process, out chan<- dsq.Result) {
+		send := func(r dsq.Result) bool {
+			select {
+			case out <- r:
+				return true
+			case <-worker.Closing():
+				return false
+			}
+		}
+
+		token := ""
+		for {
+			params := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+			if token != "" {
+				params.Set("continuation-token", token)
+			}
+			resp, err := d.do("GET", "", params, nil, nil)
+			if err != nil {
+				send(dsq.Result{Error: err})
+				return
+			}
+			var list listBucketResult
+			err = xml.NewDecoder(resp.Body).Decode(&list)
+			resp.Body.Close()
+			if err != nil {
+				send(dsq.Result{Error: err})
+				return
+			}
+
+			for _, obj := range list.Contents {
+				k := d.objectKey(obj.Key)
+				d.known.Add(k.String(), struct{}{})
+				e := dsq.Entry{Key: k.String()}
+				if !q.KeysOnly {
+					val, err := d.Get(k)
+					if err == ds.ErrNotFound {
+						// deleted since the listing
+						continue
+					}
+					if err != nil {
+						send(dsq.Result{Error: err})
+						return
+					}
+					e.Value = val
+				}
+				if !send(dsq.Result{Entry: e}) {
+					return
+				}
+			}
+
+			if !list.IsTruncated {
+				return
+			}
+			token = list.NextContinuationToken
+		}
+	})
+
+	// the prefix was applied by the service
+	naive := q
+	naive.Prefix = ""
+	return dsq.NaiveQueryApply(naive, qr), nil
+}
+
+// Close implements io.Closer.
+func (d *Datastore) Close() error {
+	return nil
+}
+
+type op struct {
+	key    ds.Key
+	value  []byte
+	delete bool
+}
+
+type batch struct {
+	d   *Datastore
+	ops []op
+}
+
+// Batch returns a batch whose operations are run concurrently on commit.
+func (d *Datastore) Batch() (ds.Batch, error) {
+	return &batch{d: d}, nil
+}
+
+func (b *batch) Put(key ds.Key, value interface{}) error {
+	val, ok := value.([]byte)
+	if !ok {
+		return ds.ErrInvalidType
+	}
+	b.ops = append(b.ops, op{key: key, value: val})
+	return nil
+}
+
+func (b *batch) Delete(key ds.Key) error {
+	b.ops = append(b.ops, op{key: key, delete: true})
+	return nil
+}
+
+func (b *batch) Commit() error {
+	ops := b.ops
+	b.ops = nil
+	return b.d.parallel(len(ops), func(i int) error {
+		if ops[i].delete {
+			err := b.d.Delete(ops[i].key)
+			if err == ds.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+		return b.d.Put(ops[i].key, ops[i].value)
+	})
+}
@@ -0,0 +1,219 @@
+package s3ds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+// fakeS3 is a minimal in-memory S3 service.
+type fakeS3 struct {
+	lk       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	requests int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.requests++
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), signAlgorithm+" Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != "bucket" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	if len(parts) == 1 {
+		// ListObjectsV2, without pagination
+		var res listBucketResult
+		var names []string
+		for name := range f.objects {
+			if strings.HasPrefix(name, q.Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res.Contents = append(res.Contents, struct {
+				Key string `xml:"Key"`
+			}{name})
+		}
+		xml.NewEncoder(w).Encode(res)
+		return
+	}
+	name := parts[1]
+
+	switch {
+	case r.Method == "POST" && q["uploads"] != nil:
+		id := fmt.Sprintf("upload%d", len(f.uploads))
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == "PUT" && q.Get("uploadId") != "":
+		var n int
+		fmt.Sscan(q.Get("partNumber"), &n)
+		f.uploads[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, n))
+	case r.Method == "POST" && q.Get("uploadId") != "":
+		var complete completeMultipartUpload
+		xml.Unmarshal(body, &complete)
+		var buf bytes.Buffer
+		for _, p := range complete.Parts {
+			buf.Write(f.uploads[q.Get("uploadId")][p.PartNumber])
+		}
+		f.objects[name] = buf.Bytes()
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == "PUT":
+		f.objects[name] = body
+	case r.Method == "GET" || r.Method == "HEAD":
+		val, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(val)
+	case r.Method == "DELETE":
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestDatastore(t *testing.T) (*Datastore, *fakeS3, func()) {
+	f := newFakeS3()
+	srv := httptest.NewServer(f)
+	d, err := New(Config{
+		Endpoint:      srv.URL,
+		Bucket:        "bucket",
+		RootDirectory: "root",
+		AccessKey:     "key",
+		SecretKey:     "secret",
+		PartSize:      minPartSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, f, srv.Close
+}
+
+func TestPutGetDelete(t *testing.T) {
+	d, f, done := newTestDatastore(t)
+	defer done()
+
+	k := ds.NewKey("/blocks/ABC")
+	if err := d.Put(k, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.objects["root/blocks/ABC"]; !ok {
+		t.Fatal("object not stored under the root directory")
+	}
+
+	val, err := d.Get(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val.([]byte)) != "hello" {
+		t.Fatalf("got wrong value %q", val)
+	}
+
+	// answered by the metadata cache
+	before := f.requests
+	has, err := d.Has(k)
+	if err != nil || !has {
+		t.Fatal("expected key to exist", err)
+	}
+	if f.requests != before {
+		t.Fatal("Has of a known key should not make a request")
+	}
+
+	if err := d.Delete(k); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(k); err != ds.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := d.Delete(k); err != ds.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMultipartPut(t *testing.T) {
+	d, f, done := newTestDatastore(t)
+	defer done()
+
+	val := bytes.Repeat([]byte("0123456789"), minPartSize/4)
+	k := ds.NewKey("/big")
+	if err := d.Put(k, val); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.uploads) != 1 {
+		t.Fatal("expected a multipart upload")
+	}
+	if !bytes.Equal(f.objects["root/big"], val) {
+		t.Fatal("parts were assembled wrongly")
+	}
+}
+
+func TestBatchAndQuery(t *testing.T) {
+	d, _, done := newTestDatastore(t)
+	defer done()
+
+	b, err := d.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := b.Put(ds.NewKey(fmt.Sprintf("/blocks/%02d", i)), []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Put(ds.NewKey("/other"), []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := d.Query(dsq.Query{Prefix: "/blocks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 50 {
+		t.Fatalf("expected 50 entries, got %d", len(entries))
+	}
+	for _, e := range entries {
+		var i int
+		fmt.Sscanf(e.Key, "/blocks/%d", &i)
+		if v := e.Value.([]byte); len(v) != 1 || int(v[0]) != i {
+			t.Fatalf("wrong value for %s", e.Key)
+		}
+	}
+}
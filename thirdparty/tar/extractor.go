@@ -8,11 +8,21 @@ import (
 	gopath "path"
 	fp "path/filepath"
 	"strings"
+	"time"
 )
 
 type Extractor struct {
 	Path     string
 	Progress func(int64) int64
+
+	// dirTimes are the modification times of the directories extracted,
+	// set once their contents are.
+	dirTimes []dirTime
+}
+
+type dirTime struct {
+	path    string
+	modTime time.Time
 }
 
 func (te *Extractor) Extract(reader io.Reader) error {
@@ -58,6 +68,16 @@ func (te *Extractor) Extract(reader io.Reader) error {
 			return fmt.Errorf("unrecognized tar header type: %d", header.Typeflag)
 		}
 	}
+
+	// set the times of the directories last, from the deepest up, as
+	// extracting their contents changes them
+	for i := len(te.dirTimes) - 1; i >= 0; i-- {
+		dt := te.dirTimes[i]
+		if err := os.Chtimes(dt.path, dt.modTime, dt.modTime); err != nil {
+			return err
+		}
+	}
+	te.dirTimes = nil
 	return nil
 }
 
@@ -79,7 +99,15 @@ func (te *Extractor) extractDir(h *tar.Header, depth int) error {
 		te.Path = path
 	}
 
-	return os.MkdirAll(path, 0755)
+	// the permissions are masked by the umask, like the ones of files
+	if err := os.MkdirAll(path, os.FileMode(h.Mode).Perm()); err != nil {
+		return err
+	}
+	if err := setXAttrs(path, h.Xattrs); err != nil {
+		return err
+	}
+	te.dirTimes = append(te.dirTimes, dirTime{path, h.ModTime})
+	return nil
 }
 
 func (te *Extractor) extractSymlink(h *tar.Header) error {
@@ -101,13 +129,23 @@ func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootEx
 		} // else if old file exists, just overwrite it.
 	}
 
-	file, err := os.Create(path)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(h.Mode).Perm())
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	return copyWithProgress(file, r, te.Progress)
+	err = copyWithProgress(file, r, te.Progress)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := setXAttrs(path, h.Xattrs); err != nil {
+		return err
+	}
+	return os.Chtimes(path, h.ModTime, h.ModTime)
 }
 
 func copyWithProgress(to io.Writer, from io.Reader, cb func(int64) int64) error {
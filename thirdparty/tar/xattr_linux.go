@@ -0,0 +1,20 @@
+package tar
+
+import (
+	"syscall"
+)
+
+// setXAttrs sets the extended attributes of the file at path, unless its
+// filesystem doesn't support them.
+func setXAttrs(path string, xattrs map[string]string) error {
+	for name, val := range xattrs {
+		err := syscall.Setxattr(path, name, []byte(val), 0)
+		if err == syscall.ENOTSUP {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
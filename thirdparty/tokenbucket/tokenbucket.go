@@ -0,0 +1,65 @@
+// Package tokenbucket implements a token bucket rate limiter which may go
+// into debt, so that requests larger than the burst can still be served
+// eventually. Buckets are not safe for concurrent use.
+package tokenbucket
+
+import "time"
+
+// Bucket is a token bucket refilled at a fixed rate up to its burst.
+type Bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New returns a full bucket refilled at rate tokens per second, holding at
+// most burst tokens. A burst of zero or less defaults to one second worth
+// of the rate.
+func New(rate, burst float64, now time.Time) *Bucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &Bucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+func (b *Bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// Take removes n tokens from the bucket, returning how long to wait until
+// the bucket is out of debt.
+func (b *Bucket) Take(n int, now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Allow removes a token from the bucket if it has one, or returns how long
+// until it does.
+func (b *Bucket) Allow(now time.Time) (time.Duration, bool) {
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
+
+// Full reports whether the bucket is back to its full burst.
+func (b *Bucket) Full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
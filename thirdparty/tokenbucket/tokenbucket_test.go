@@ -0,0 +1,28 @@
+package tokenbucket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := New(100, 0, now)
+	if d := b.Take(150, now); d != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, got %s", d)
+	}
+	if b.Full(now.Add(time.Second)) {
+		t.Fatal("bucket shouldn't be full yet")
+	}
+	if !b.Full(now.Add(2 * time.Second)) {
+		t.Fatal("bucket should be full")
+	}
+
+	b = New(1, 1, now)
+	if _, ok := b.Allow(now); !ok {
+		t.Fatal("a full bucket should allow a request")
+	}
+	if d, ok := b.Allow(now); ok || d != time.Second {
+		t.Fatalf("expected to wait 1s for the next request, got %s", d)
+	}
+}
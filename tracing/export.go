@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("tracing")
+
+const (
+	// batchSize is the number of spans handed to the exporter at once.
+	batchSize = 512
+	// flushInterval is how long finished spans wait for a batch to fill.
+	flushInterval = 5 * time.Second
+	// queueSize is the number of finished spans waiting to be exported
+	// above which spans are dropped.
+	queueSize = 4 * batchSize
+)
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	Export(spans []SpanData) error
+}
+
+type recorder struct {
+	exp    Exporter
+	spans  chan SpanData
+	closed chan struct{}
+	done   chan struct{}
+}
+
+var (
+	recLk sync.RWMutex
+	rec   *recorder
+)
+
+func current() *recorder {
+	recLk.RLock()
+	defer recLk.RUnlock()
+	return rec
+}
+
+// Start records spans, exporting them with exp in batches, until stop is
+// called. Starting again replaces the exporter.
+func Start(exp Exporter) (stop func()) {
+	r := &recorder{
+		exp:    exp,
+		spans:  make(chan SpanData, queueSize),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+
+	recLk.Lock()
+	prev := rec
+	rec = r
+	recLk.Unlock()
+	if prev != nil {
+		prev.stop()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			recLk.Lock()
+			if rec == r {
+				rec = nil
+			}
+			recLk.Unlock()
+			r.stop()
+		})
+	}
+}
+
+func (r *recorder) record(s SpanData) {
+	select {
+	case r.spans <- s:
+	default:
+		// don't slow down the node for a slow backend
+		log.Debugf("dropping span %s, the export queue is full", s.Name)
+	}
+}
+
+// stop exports the spans recorded so far, and returns once done.
+func (r *recorder) stop() {
+	close(r.closed)
+	<-r.done
+}
+
+func (r *recorder) run() {
+	defer close(r.done)
+
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.exp.Export(batch); err != nil {
+			log.Warningf("exporting %d spans: %s", len(batch), err)
+		}
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case s := <-r.spans:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case <-r.closed:
+			for {
+				select {
+				case s := <-r.spans:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ServiceName is the name of the service the spans exported over OTLP are
+// reported for.
+const ServiceName = "go-ipfs"
+
+const otlpTimeout = 10 * time.Second
+
+// OTLPExporter sends spans to an OpenTelemetry collector with OTLP over HTTP,
+// JSON encoded.
+type OTLPExporter struct {
+	// Endpoint is the URL spans are posted to, e.g.
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+
+	Client *http.Client
+}
+
+// NewOTLPExporter returns an exporter posting spans to endpoint.
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	return &OTLPExporter{
+		Endpoint: endpoint,
+		Client:   &http.Client{Timeout: otlpTimeout},
+	}
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(spans []SpanData) error {
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.Client.Post(e.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// The types below are the subset of the OTLP trace request the exporter
+// sends, in its JSON mapping.
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpKindInternal = 1
+	otlpStatusError  = 2
+)
+
+func otlpRequest(spans []SpanData) otlpTraceRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		sp := otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			Name:              s.Name,
+			Kind:              otlpKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.Error != "" {
+			sp.Status = &otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		out = append(out, sp)
+	}
+
+	return otlpTraceRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: otlpAttributes(map[string]interface{}{
+					"service.name": ServiceName,
+				}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: ServiceName},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var v map[string]interface{}
+		switch a := attrs[k].(type) {
+		case bool:
+			v = map[string]interface{}{"boolValue": a}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(int64(a), 10)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(a, 10)}
+		case uint64:
+			v = map[string]interface{}{"intValue": strconv.FormatUint(a, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": a}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(a)}
+		}
+		out = append(out, otlpKeyValue{Key: k, Value: v})
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+// Package tracing records the spans of the operations of a node, such as the
+// resolution of a name, the walk of a DAG or the fetch of a block, in
+// traces propagated through contexts, so that the latency of a request can
+// be followed across subsystems.
+//
+// Spans are only recorded once Start has been called with an exporter;
+// until then StartSpan returns nil spans, whose methods do nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpanData is a finished span, as handed to exporters.
+type SpanData struct {
+	TraceID  string
+	SpanID   string
+	ParentID string `json:",omitempty"`
+
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{} `json:",omitempty"`
+
+	// Error is the error the operation failed with, if any.
+	Error string `json:",omitempty"`
+}
+
+// Span is an operation in progress.
+type Span struct {
+	lk       sync.Mutex
+	data     SpanData
+	finished bool
+}
+
+type spanKey struct{}
+
+// StartSpan starts a span named name, child of the span of ctx if any, and
+// returns a context carrying it. The span is nil when tracing isn't started.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	if current() == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		data: SpanData{
+			SpanID: newID(8),
+			Name:   name,
+			Start:  time.Now(),
+		},
+	}
+	if parent := FromContext(ctx); parent != nil {
+		s.data.TraceID = parent.data.TraceID
+		s.data.ParentID = parent.data.SpanID
+	} else {
+		s.data.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the span of ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// TraceID returns the ID of the trace of s.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+// SetAttribute sets the attribute key of s to v, which is formatted unless it
+// is a string, a bool or a number.
+func (s *Span) SetAttribute(key string, v interface{}) {
+	if s == nil {
+		return
+	}
+	switch v.(type) {
+	case string, bool, int, int64, uint64, float64:
+	default:
+		v = fmt.Sprint(v)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.finished {
+		return
+	}
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = v
+}
+
+// SetError records that the operation of s failed with err, if not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.data.Error = err.Error()
+}
+
+// Finish ends s and hands it to the exporter. Later calls do nothing.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.lk.Lock()
+	if s.finished {
+		s.lk.Unlock()
+		return
+	}
+	s.finished = true
+	s.data.End = time.Now()
+	data := s.data
+	s.lk.Unlock()
+
+	if r := current(); r != nil {
+		r.record(data)
+	}
+}
+
+func newID(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
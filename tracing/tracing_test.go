@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type memExporter struct {
+	lk    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memExporter) Export(spans []SpanData) error {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestNotStarted(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), "op")
+	if span != nil {
+		t.Fatal("expected no span when tracing isn't started")
+	}
+	if FromContext(ctx) != nil {
+		t.Fatal("expected no span in the context")
+	}
+	// nil spans are no-ops
+	span.SetAttribute("k", "v")
+	span.SetError(errors.New("failed"))
+	span.Finish()
+}
+
+func TestSpans(t *testing.T) {
+	exp := new(memExporter)
+	stop := Start(exp)
+
+	ctx, root := StartSpan(context.Background(), "root")
+	_, child := StartSpan(ctx, "child")
+	child.SetAttribute("cid", "QmFoo")
+	child.SetError(errors.New("failed"))
+	child.Finish()
+	child.Finish()
+	root.Finish()
+
+	stop()
+	if s := FromContext(ctx); s != root {
+		t.Fatal("context doesn't carry the span")
+	}
+
+	if len(exp.spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(exp.spans))
+	}
+	c, r := exp.spans[0], exp.spans[1]
+	if c.Name != "child" || r.Name != "root" {
+		t.Fatalf("wrong spans: %+v", exp.spans)
+	}
+	if c.TraceID != r.TraceID || c.ParentID != r.SpanID || r.ParentID != "" {
+		t.Fatal("child isn't part of the trace of the root")
+	}
+	if c.Attributes["cid"] != "QmFoo" || c.Error != "failed" {
+		t.Fatalf("wrong child: %+v", c)
+	}
+
+	// stopped
+	if _, span := StartSpan(context.Background(), "op"); span != nil {
+		t.Fatal("expected no span once stopped")
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var req otlpTraceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(srv.URL)
+	err := exp.Export([]SpanData{{
+		TraceID:    "0123456789abcdef0123456789abcdef",
+		SpanID:     "0123456789abcdef",
+		Name:       "op",
+		Attributes: map[string]interface{}{"blocks": 3, "cid": "QmFoo"},
+		Error:      "failed",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Name != "op" || s.TraceID != "0123456789abcdef0123456789abcdef" {
+		t.Fatalf("wrong span: %+v", s)
+	}
+	if s.Status == nil || s.Status.Code != otlpStatusError {
+		t.Fatal("expected an error status")
+	}
+	if len(s.Attributes) != 2 || s.Attributes[0].Key != "blocks" || s.Attributes[0].Value["intValue"] != "3" {
+		t.Fatalf("wrong attributes: %+v", s.Attributes)
+	}
+
+	srv.Close()
+	if err := exp.Export(nil); err == nil {
+		t.Fatal("expected an error once the collector is gone")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"path"
 
 	tar "github.com/ipfs/go-ipfs/unixfs/archive/tar"
+	zip "github.com/ipfs/go-ipfs/unixfs/archive/zip"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	ipld "github.com/ipfs/go-ipld-format"
@@ -30,8 +31,9 @@ func (i *identityWriteCloser) Close() error {
 	return nil
 }
 
-// DagArchive is equivalent to `ipfs getdag $hash | maybe_tar | maybe_gzip`
-func DagArchive(ctx context.Context, nd ipld.Node, name string, dag ipld.DAGService, archive bool, compression int) (io.Reader, error) {
+// DagArchive is equivalent to `ipfs getdag $hash | maybe_tar | maybe_gzip`.
+// The files are read with the given reader options.
+func DagArchive(ctx context.Context, nd ipld.Node, name string, dag ipld.DAGService, archive bool, compression int, opts ...uio.ReaderOption) (io.Reader, error) {
 
 	_, filename := path.Split(name)
 
@@ -66,7 +68,7 @@ func DagArchive(ctx context.Context, nd ipld.Node, name string, dag ipld.DAGServ
 
 	if !archive && compression != gzip.NoCompression {
 		// the case when the node is a file
-		dagr, err := uio.NewDagReader(ctx, nd, dag)
+		dagr, err := uio.NewDagReader(ctx, nd, dag, opts...)
 		if checkErrAndClosePipe(err) {
 			return nil, err
 		}
@@ -85,6 +87,7 @@ func DagArchive(ctx context.Context, nd ipld.Node, name string, dag ipld.DAGServ
 		if checkErrAndClosePipe(err) {
 			return nil, err
 		}
+		w.ReaderOptions = opts
 
 		go func() {
 			// write all the nodes recursively
@@ -105,3 +108,35 @@ func newMaybeGzWriter(w io.Writer, compression int) (io.WriteCloser, error) {
 	}
 	return &identityWriteCloser{w}, nil
 }
+
+// DagZipArchive returns a zip archive of nd, named name in the archive: the
+// entries of the directories are written in the order of their names, and
+// the entries which don't record their modification time get a fixed one,
+// so a DAG is always archived the same way. The files are read with the given
+// reader options.
+func DagZipArchive(ctx context.Context, nd ipld.Node, name string, dag ipld.DAGService, opts ...uio.ReaderOption) (io.Reader, error) {
+	_, filename := path.Split(name)
+
+	piper, pipew := io.Pipe()
+	bufw := bufio.NewWriterSize(pipew, DefaultBufSize)
+	w := zip.NewWriter(ctx, dag, bufw)
+	w.ReaderOptions = opts
+
+	go func() {
+		if err := w.WriteNode(nd, filename); err != nil {
+			pipew.CloseWithError(err)
+			return
+		}
+		if err := w.Close(); err != nil {
+			pipew.CloseWithError(err)
+			return
+		}
+		if err := bufw.Flush(); err != nil {
+			pipew.CloseWithError(err)
+			return
+		}
+		pipew.Close()
+	}()
+
+	return piper, nil
+}
@@ -16,6 +16,7 @@ import (
 	upb "github.com/ipfs/go-ipfs/unixfs/pb"
 
 	proto "github.com/gogo/protobuf/proto"
+	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
 )
 
@@ -26,6 +27,9 @@ type Writer struct {
 	Dag  ipld.DAGService
 	TarW *tar.Writer
 
+	// ReaderOptions are the options of the readers of the files.
+	ReaderOptions []uio.ReaderOption
+
 	ctx context.Context
 }
 
@@ -38,18 +42,33 @@ func NewWriter(ctx context.Context, dag ipld.DAGService, archive bool, compressi
 	}, nil
 }
 
-func (w *Writer) writeDir(nd *mdag.ProtoNode, fpath string) error {
-	if err := writeDirHeader(w.TarW, fpath); err != nil {
+func (w *Writer) writeDir(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
+	if err := writeDirHeader(w.TarW, fpath, ft.AttrsFromPB(pb)); err != nil {
+		return err
+	}
+
+	dir, err := uio.NewDirectoryFromNode(w.Dag, nd)
+	if err != nil {
+		return err
+	}
+	// entries are written in the order of their names, so the same
+	// directory is always archived the same way
+	links, err := dir.SortedLinks(w.ctx)
+	if err != nil {
 		return err
 	}
 
-	for i, ng := range ipld.GetDAG(w.ctx, w.Dag, nd) {
+	cids := make([]*cid.Cid, len(links))
+	for i, l := range links {
+		cids[i] = l.Cid
+	}
+	for i, ng := range ipld.GetNodes(w.ctx, w.Dag, cids) {
 		child, err := ng.Get(w.ctx)
 		if err != nil {
 			return err
 		}
 
-		npath := path.Join(fpath, nd.Links()[i].Name)
+		npath := path.Join(fpath, links[i].Name)
 		if err := w.WriteNode(child, npath); err != nil {
 			return err
 		}
@@ -59,11 +78,11 @@ func (w *Writer) writeDir(nd *mdag.ProtoNode, fpath string) error {
 }
 
 func (w *Writer) writeFile(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
-	if err := writeFileHeader(w.TarW, fpath, pb.GetFilesize()); err != nil {
+	if err := writeFileHeader(w.TarW, fpath, pb.GetFilesize(), ft.AttrsFromPB(pb)); err != nil {
 		return err
 	}
 
-	dagr := uio.NewPBFileReader(w.ctx, nd, pb, w.Dag)
+	dagr := uio.NewPBFileReader(w.ctx, nd, pb, w.Dag, w.ReaderOptions...)
 	if _, err := dagr.WriteTo(w.TarW); err != nil {
 		return err
 	}
@@ -83,8 +102,8 @@ func (w *Writer) WriteNode(nd ipld.Node, fpath string) error {
 		switch pb.GetType() {
 		case upb.Data_Metadata:
 			fallthrough
-		case upb.Data_Directory:
-			return w.writeDir(nd, fpath)
+		case upb.Data_Directory, upb.Data_HAMTShard:
+			return w.writeDir(nd, pb, fpath)
 		case upb.Data_Raw:
 			fallthrough
 		case upb.Data_File:
@@ -95,7 +114,7 @@ func (w *Writer) WriteNode(nd ipld.Node, fpath string) error {
 			return ft.ErrUnrecognizedType
 		}
 	case *mdag.RawNode:
-		if err := writeFileHeader(w.TarW, fpath, uint64(len(nd.RawData()))); err != nil {
+		if err := writeFileHeader(w.TarW, fpath, uint64(len(nd.RawData())), ft.Attrs{}); err != nil {
 			return err
 		}
 
@@ -114,25 +133,47 @@ func (w *Writer) Close() error {
 	return w.TarW.Close()
 }
 
-func writeDirHeader(w *tar.Writer, fpath string) error {
-	return w.WriteHeader(&tar.Header{
+// defaultModTime is the modification time of the entries which don't record
+// theirs, so archives don't depend on the time they are written at.
+var defaultModTime = time.Unix(0, 0)
+
+func writeDirHeader(w *tar.Writer, fpath string, attrs ft.Attrs) error {
+	h := &tar.Header{
 		Name:     fpath,
 		Typeflag: tar.TypeDir,
 		Mode:     0777,
-		ModTime:  time.Now(),
-		// TODO: set mode, dates, etc. when added to unixFS
-	})
+		ModTime:  defaultModTime,
+	}
+	setAttrs(h, attrs)
+	return w.WriteHeader(h)
 }
 
-func writeFileHeader(w *tar.Writer, fpath string, size uint64) error {
-	return w.WriteHeader(&tar.Header{
+func writeFileHeader(w *tar.Writer, fpath string, size uint64, attrs ft.Attrs) error {
+	h := &tar.Header{
 		Name:     fpath,
 		Size:     int64(size),
 		Typeflag: tar.TypeReg,
 		Mode:     0644,
-		ModTime:  time.Now(),
-		// TODO: set mode, dates, etc. when added to unixFS
-	})
+		ModTime:  defaultModTime,
+	}
+	setAttrs(h, attrs)
+	return w.WriteHeader(h)
+}
+
+// setAttrs sets the fields of h recorded in attrs.
+func setAttrs(h *tar.Header, attrs ft.Attrs) {
+	if attrs.Mode != 0 {
+		h.Mode = int64(attrs.UnixMode())
+	}
+	if !attrs.ModTime.IsZero() {
+		h.ModTime = attrs.ModTime
+	}
+	if len(attrs.XAttrs) > 0 {
+		h.Xattrs = make(map[string]string, len(attrs.XAttrs))
+		for name, val := range attrs.XAttrs {
+			h.Xattrs[name] = string(val)
+		}
+	}
 }
 
 func writeSymlinkHeader(w *tar.Writer, target, fpath string) error {
@@ -140,6 +181,7 @@ func writeSymlinkHeader(w *tar.Writer, target, fpath string) error {
 		Name:     fpath,
 		Linkname: target,
 		Mode:     0777,
+		ModTime:  defaultModTime,
 		Typeflag: tar.TypeSymlink,
 	})
 }
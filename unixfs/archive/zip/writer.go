@@ -0,0 +1,172 @@
+// Package zip provides functionality to write a unixfs merkledag
+// as a zip archive.
+package zip
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	upb "github.com/ipfs/go-ipfs/unixfs/pb"
+
+	proto "github.com/gogo/protobuf/proto"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// defaultModTime is the modification time of the entries which don't record
+// theirs, so archives don't depend on the time they are written at. It is
+// the earliest time zip archives can hold.
+var defaultModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Writer is a utility structure that helps to write
+// unixfs merkledag nodes as a zip archive.
+// It wraps any io.Writer.
+type Writer struct {
+	Dag  ipld.DAGService
+	ZipW *zip.Writer
+
+	// Method is the compression method of the files, zip.Deflate by
+	// default.
+	Method uint16
+
+	// ReaderOptions are the options of the readers of the files.
+	ReaderOptions []uio.ReaderOption
+
+	ctx context.Context
+}
+
+// NewWriter wraps given io.Writer.
+func NewWriter(ctx context.Context, dag ipld.DAGService, w io.Writer) *Writer {
+	return &Writer{
+		Dag:    dag,
+		ZipW:   zip.NewWriter(w),
+		Method: zip.Deflate,
+		ctx:    ctx,
+	}
+}
+
+func (w *Writer) writeDir(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
+	if fpath != "" {
+		h := newHeader(fpath+"/", os.ModeDir|0755, ft.AttrsFromPB(pb))
+		if _, err := w.ZipW.CreateHeader(h); err != nil {
+			return err
+		}
+	}
+
+	dir, err := uio.NewDirectoryFromNode(w.Dag, nd)
+	if err != nil {
+		return err
+	}
+	// entries are written in the order of their names, so the same
+	// directory is always archived the same way
+	links, err := dir.SortedLinks(w.ctx)
+	if err != nil {
+		return err
+	}
+
+	cids := make([]*cid.Cid, len(links))
+	for i, l := range links {
+		cids[i] = l.Cid
+	}
+	for i, ng := range ipld.GetNodes(w.ctx, w.Dag, cids) {
+		child, err := ng.Get(w.ctx)
+		if err != nil {
+			return err
+		}
+
+		npath := path.Join(fpath, links[i].Name)
+		if err := w.WriteNode(child, npath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeFile(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
+	h := newHeader(fpath, 0644, ft.AttrsFromPB(pb))
+	h.Method = w.Method
+	fw, err := w.ZipW.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+
+	dagr := uio.NewPBFileReader(w.ctx, nd, pb, w.Dag, w.ReaderOptions...)
+	_, err = dagr.WriteTo(fw)
+	return err
+}
+
+// WriteNode adds a node to the archive. A directory written with an empty
+// path has its entries written at the root of the archive.
+func (w *Writer) WriteNode(nd ipld.Node, fpath string) error {
+	switch nd := nd.(type) {
+	case *mdag.ProtoNode:
+		pb := new(upb.Data)
+		if err := proto.Unmarshal(nd.Data(), pb); err != nil {
+			return err
+		}
+
+		switch pb.GetType() {
+		case upb.Data_Metadata:
+			fallthrough
+		case upb.Data_Directory, upb.Data_HAMTShard:
+			return w.writeDir(nd, pb, fpath)
+		case upb.Data_Raw:
+			fallthrough
+		case upb.Data_File:
+			return w.writeFile(nd, pb, fpath)
+		case upb.Data_Symlink:
+			// zip archives store the target of symlinks as their content
+			fw, err := w.ZipW.CreateHeader(newHeader(fpath, os.ModeSymlink|0777, ft.Attrs{}))
+			if err != nil {
+				return err
+			}
+			_, err = fw.Write(pb.GetData())
+			return err
+		default:
+			return ft.ErrUnrecognizedType
+		}
+	case *mdag.RawNode:
+		h := newHeader(fpath, 0644, ft.Attrs{})
+		h.Method = w.Method
+		fw, err := w.ZipW.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(nd.RawData())
+		return err
+	default:
+		return fmt.Errorf("nodes of type %T are not supported in unixfs", nd)
+	}
+}
+
+// Close finishes writing the archive. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	return w.ZipW.Close()
+}
+
+// newHeader returns the header of an entry with the given mode, unless attrs
+// record another one.
+func newHeader(fpath string, mode os.FileMode, attrs ft.Attrs) *zip.FileHeader {
+	if attrs.Mode != 0 {
+		mode = mode&os.ModeType | attrs.Mode
+	}
+	modTime := defaultModTime
+	if !attrs.ModTime.IsZero() {
+		modTime = attrs.ModTime
+	}
+
+	h := &zip.FileHeader{Name: fpath}
+	h.SetMode(mode)
+	h.SetModTime(modTime)
+	return h
+}
@@ -0,0 +1,148 @@
+package unixfs
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/unixfs/pb"
+
+	proto "github.com/gogo/protobuf/proto"
+)
+
+// Attrs are the optional filesystem attributes of a unixfs file, directory
+// or symlink, recorded when adding it with the matching options.
+type Attrs struct {
+	// Mode holds the permission bits of the entry, along with its setuid,
+	// setgid and sticky bits. Zero if not recorded.
+	Mode os.FileMode
+
+	// ModTime is the time of the last modification of the entry. Zero if
+	// not recorded.
+	ModTime time.Time
+
+	// XAttrs are the extended attributes of the entry, by name.
+	XAttrs map[string][]byte
+}
+
+// IsZero reports whether no attribute is recorded in a.
+func (a *Attrs) IsZero() bool {
+	return a.Mode == 0 && a.ModTime.IsZero() && len(a.XAttrs) == 0
+}
+
+// Equal reports whether a and b record the same attributes.
+func (a *Attrs) Equal(b Attrs) bool {
+	if a.Mode != b.Mode || !a.ModTime.Equal(b.ModTime) || len(a.XAttrs) != len(b.XAttrs) {
+		return false
+	}
+	for name, v := range a.XAttrs {
+		bv, ok := b.XAttrs[name]
+		if !ok || !bytes.Equal(v, bv) {
+			return false
+		}
+	}
+	return true
+}
+
+// unix mode bits of the special bits of os.FileMode
+const (
+	modeSetuid = 04000
+	modeSetgid = 02000
+	modeSticky = 01000
+)
+
+// UnixMode returns the mode of a as the bits of a unix file mode.
+func (a *Attrs) UnixMode() uint32 {
+	m := uint32(a.Mode & os.ModePerm)
+	if a.Mode&os.ModeSetuid != 0 {
+		m |= modeSetuid
+	}
+	if a.Mode&os.ModeSetgid != 0 {
+		m |= modeSetgid
+	}
+	if a.Mode&os.ModeSticky != 0 {
+		m |= modeSticky
+	}
+	return m
+}
+
+// AttrsFromPB returns the attributes recorded in pbd.
+func AttrsFromPB(pbd *pb.Data) Attrs {
+	var a Attrs
+	if pbd.Mode != nil {
+		m := pbd.GetMode()
+		a.Mode = os.FileMode(m) & os.ModePerm
+		if m&modeSetuid != 0 {
+			a.Mode |= os.ModeSetuid
+		}
+		if m&modeSetgid != 0 {
+			a.Mode |= os.ModeSetgid
+		}
+		if m&modeSticky != 0 {
+			a.Mode |= os.ModeSticky
+		}
+	}
+	if pbd.Mtime != nil {
+		a.ModTime = time.Unix(pbd.GetMtime(), int64(pbd.GetMtimeNsecs()))
+	}
+	if len(pbd.Xattrs) > 0 {
+		a.XAttrs = make(map[string][]byte, len(pbd.Xattrs))
+		for _, x := range pbd.Xattrs {
+			a.XAttrs[x.GetName()] = x.GetValue()
+		}
+	}
+	return a
+}
+
+// AttrsFromBytes returns the attributes recorded in the unixfs data of a
+// node.
+func AttrsFromBytes(data []byte) (Attrs, error) {
+	pbd, err := FromBytes(data)
+	if err != nil {
+		return Attrs{}, err
+	}
+	return AttrsFromPB(pbd), nil
+}
+
+// setAttrsPB records a in pbd, replacing the attributes recorded in it. The
+// extended attributes are sorted by name, so the same attributes are always
+// encoded the same way.
+func setAttrsPB(pbd *pb.Data, a Attrs) {
+	pbd.Mode = nil
+	if a.Mode != 0 {
+		pbd.Mode = proto.Uint32(a.UnixMode())
+	}
+
+	pbd.Mtime, pbd.MtimeNsecs = nil, nil
+	if !a.ModTime.IsZero() {
+		pbd.Mtime = proto.Int64(a.ModTime.Unix())
+		if ns := a.ModTime.Nanosecond(); ns != 0 {
+			pbd.MtimeNsecs = proto.Uint32(uint32(ns))
+		}
+	}
+
+	pbd.Xattrs = nil
+	names := make([]string, 0, len(a.XAttrs))
+	for name := range a.XAttrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pbd.Xattrs = append(pbd.Xattrs, &pb.XAttr{
+			Name:  proto.String(name),
+			Value: a.XAttrs[name],
+		})
+	}
+}
+
+// SetAttrs records a in the unixfs data of a node, replacing the attributes
+// recorded in it, and returns the new data.
+func SetAttrs(data []byte, a Attrs) ([]byte, error) {
+	pbd, err := FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	setAttrsPB(pbd, a)
+	return proto.Marshal(pbd)
+}
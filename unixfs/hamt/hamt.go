@@ -21,6 +21,7 @@
 package hamt
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math"
@@ -224,7 +225,6 @@ func (ds *Shard) Label() string {
 
 // Set sets 'name' = nd in the HAMT
 func (ds *Shard) Set(ctx context.Context, name string, nd ipld.Node) error {
-	hv := &hashBits{b: hash([]byte(name))}
 	err := ds.dserv.Add(ctx, nd)
 	if err != nil {
 		return err
@@ -234,9 +234,21 @@ func (ds *Shard) Set(ctx context.Context, name string, nd ipld.Node) error {
 	if err != nil {
 		return err
 	}
-	lnk.Name = ds.linkNamePrefix(0) + name
 
-	return ds.modifyValue(ctx, hv, name, lnk)
+	return ds.SetLink(ctx, name, lnk)
+}
+
+// SetLink sets 'name' to the node lnk points to in the HAMT, without adding
+// it to the DAGService.
+func (ds *Shard) SetLink(ctx context.Context, name string, lnk *ipld.Link) error {
+	hv := &hashBits{b: hash([]byte(name))}
+	val := &ipld.Link{
+		Name: ds.linkNamePrefix(0) + name,
+		Size: lnk.Size,
+		Cid:  lnk.Cid,
+	}
+
+	return ds.modifyValue(ctx, hv, name, val)
 }
 
 // Remove deletes the named entry if it exists, this operation is idempotent.
@@ -284,6 +296,18 @@ func (ds *Shard) getChild(ctx context.Context, i int) (child, error) {
 // loadChild reads the i'th child node of this shard from disk and returns it
 // as a 'child' interface
 func (ds *Shard) loadChild(ctx context.Context, i int) (child, error) {
+	c, err := ds.readChild(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.children[i] = c
+	return c, nil
+}
+
+// readChild reads the i'th child node of this shard from disk, without
+// caching it.
+func (ds *Shard) readChild(ctx context.Context, i int) (child, error) {
 	lnk := ds.nd.Links()[i]
 	if len(lnk.Name) < ds.maxpadlen {
 		return nil, fmt.Errorf("invalid link name '%s'", lnk.Name)
@@ -323,8 +347,6 @@ func (ds *Shard) loadChild(ctx context.Context, i int) (child, error) {
 			val: &lnk2,
 		}
 	}
-
-	ds.children[i] = c
 	return c, nil
 }
 
@@ -423,6 +445,82 @@ func (ds *Shard) ForEachLink(ctx context.Context, f func(*ipld.Link) error) erro
 	})
 }
 
+// ForEachLinkAfter walks the links of the Shard in the order of the hashes of
+// their names, starting after the link named after, or from the first one if
+// after is empty, and calls f on each of them. A walk interrupted after some
+// link can so be resumed after it, even if that link was removed since.
+//
+// Unlike ForEachLink, it doesn't cache the shards it loads, so walking huge
+// directories only takes memory for the shards between the root and the
+// link being visited.
+func (ds *Shard) ForEachLinkAfter(ctx context.Context, after string, f func(*ipld.Link) error) error {
+	var hv *hashBits
+	if after != "" {
+		hv = &hashBits{b: hash([]byte(after))}
+	}
+	return ds.walkAfter(ctx, hv, after, f)
+}
+
+// walkAfter walks the values of the shard following the position of the
+// hash hv, if not nil, in the trie.
+func (ds *Shard) walkAfter(ctx context.Context, hv *hashBits, after string, f func(*ipld.Link) error) error {
+	start := 0
+	if hv != nil {
+		start = hv.Next(ds.tableSizeLg2)
+	}
+
+	for i := start; i < ds.tableSize; i++ {
+		if ds.bitfield.Bit(i) == 0 {
+			continue
+		}
+
+		idx := ds.indexForBitPos(i)
+		c := ds.children[idx]
+		if c == nil {
+			var err error
+			c, err = ds.readChild(ctx, idx)
+			if err != nil {
+				return err
+			}
+		}
+
+		// only the child on the path of hv is walked from it, the following
+		// ones are walked entirely
+		var chv *hashBits
+		if hv != nil && i == start {
+			chv = hv
+		}
+
+		switch c := c.(type) {
+		case *shardValue:
+			if chv != nil && !valueAfter(c.key, after) {
+				continue
+			}
+			lnk := *c.val
+			lnk.Name = c.key
+			if err := f(&lnk); err != nil {
+				return err
+			}
+		case *Shard:
+			if err := c.walkAfter(ctx, chv, after, f); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected child type: %#v", c)
+		}
+	}
+	return nil
+}
+
+// valueAfter reports whether the value named key comes after the one named
+// after in the trie, which orders values by the hashes of their names.
+func valueAfter(key, after string) bool {
+	if c := bytes.Compare(hash([]byte(key)), hash([]byte(after))); c != 0 {
+		return c > 0
+	}
+	return key > after
+}
+
 func (ds *Shard) walkTrie(ctx context.Context, cb func(*shardValue) error) error {
 	for i := 0; i < ds.tableSize; i++ {
 		if ds.bitfield.Bit(i) == 0 {
@@ -565,3 +565,76 @@ func TestHamtBadSize(t *testing.T) {
 		t.Fatal("should have failed to construct hamt with bad size")
 	}
 }
+
+func TestForEachLinkAfter(t *testing.T) {
+	ds := mdtest.Mock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a small width gives a deep trie
+	_, s, err := makeDirWidth(ds, 1000, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := s.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nds, err := NewHamtFromDag(ds, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.EnumLinks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	err = nds.ForEachLinkAfter(ctx, "", func(l *ipld.Link) error {
+		names = append(names, l.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(all) {
+		t.Fatalf("expected %d links, got %d", len(all), len(names))
+	}
+	for i, l := range all {
+		if names[i] != l.Name {
+			t.Fatalf("expected links in trie order, got %s at %d", names[i], i)
+		}
+	}
+
+	// resuming after any link gives the following ones
+	for _, i := range []int{0, 1, 500, 998, 999} {
+		var rest []string
+		err := nds.ForEachLinkAfter(ctx, names[i], func(l *ipld.Link) error {
+			rest = append(rest, l.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(rest) != fmt.Sprint(names[i+1:]) {
+			t.Fatalf("wrong links after %s", names[i])
+		}
+	}
+
+	// even once the link was removed
+	if err := nds.Remove(ctx, names[500]); err != nil {
+		t.Fatal(err)
+	}
+	var rest []string
+	err = nds.ForEachLinkAfter(ctx, names[500], func(l *ipld.Link) error {
+		rest = append(rest, l.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(rest) != fmt.Sprint(names[501:]) {
+		t.Fatalf("wrong links after removed %s", names[500])
+	}
+}
@@ -28,6 +28,10 @@ type DagReader interface {
 	Size() uint64
 	CtxReadFull(context.Context, []byte) (int, error)
 	Offset() int64
+
+	// ReadAt reads from the given offset without moving the offset of the
+	// reader, fetching only the blocks holding the data read.
+	io.ReaderAt
 }
 
 // A ReadSeekCloser implements interfaces to read, copy, seek and close.
@@ -38,9 +42,78 @@ type ReadSeekCloser interface {
 	io.WriterTo
 }
 
+// FetchAheadLimits bound the children of a file node requested ahead of the
+// one being read, so their blocks are fetched in parallel.
+type FetchAheadLimits struct {
+	// Blocks is the number of children requested ahead,
+	// DefaultFetchAheadBlocks if 0.
+	Blocks int
+
+	// Bytes bounds the amount of file data held by the children requested
+	// ahead, and so the memory fetched blocks waiting to be read use,
+	// DefaultFetchAheadBytes if 0.
+	Bytes int
+}
+
+const (
+	// DefaultFetchAheadBlocks is the number of children requested ahead by
+	// readers without limits.
+	DefaultFetchAheadBlocks = 32
+
+	// DefaultFetchAheadBytes bounds the data of the children requested
+	// ahead by readers without limits.
+	DefaultFetchAheadBytes = 8 << 20
+)
+
+func (l FetchAheadLimits) blocks() int {
+	if l.Blocks > 0 {
+		return l.Blocks
+	}
+	return DefaultFetchAheadBlocks
+}
+
+func (l FetchAheadLimits) bytes() int {
+	if l.Bytes > 0 {
+		return l.Bytes
+	}
+	return DefaultFetchAheadBytes
+}
+
+// readerConfig is the configuration of a reader and of the readers of its
+// children.
+type readerConfig struct {
+	fetchAhead FetchAheadLimits
+}
+
+// ReaderOption configures the readers created by NewDagReader and
+// NewPBFileReader.
+type ReaderOption func(*readerConfig)
+
+// WithFetchAhead sets the fetch-ahead limits of the reader.
+func WithFetchAhead(l FetchAheadLimits) ReaderOption {
+	return func(c *readerConfig) {
+		c.fetchAhead = l
+	}
+}
+
+func newReaderConfig(opts []ReaderOption) readerConfig {
+	var c readerConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
 // NewDagReader creates a new reader object that reads the data represented by
-// the given node, using the passed in DAGService for data retreival
-func NewDagReader(ctx context.Context, n ipld.Node, serv ipld.NodeGetter) (DagReader, error) {
+// the given node, using the passed in DAGService for data retreival. Blocks
+// are fetched through a single session, and children of each node are
+// requested ahead of the one being read, within the limits set by
+// WithFetchAhead.
+func NewDagReader(ctx context.Context, n ipld.Node, serv ipld.NodeGetter, opts ...ReaderOption) (DagReader, error) {
+	return newDagReader(ctx, n, mdag.NewSession(ctx, serv), newReaderConfig(opts))
+}
+
+func newDagReader(ctx context.Context, n ipld.Node, serv ipld.NodeGetter, cfg readerConfig) (DagReader, error) {
 	switch n := n.(type) {
 	case *mdag.RawNode:
 		return NewBufDagReader(n.RawData()), nil
@@ -55,7 +128,7 @@ func NewDagReader(ctx context.Context, n ipld.Node, serv ipld.NodeGetter) (DagRe
 			// Dont allow reading directories
 			return nil, ErrIsDir
 		case ftpb.Data_File, ftpb.Data_Raw:
-			return NewPBFileReader(ctx, n, pb, serv), nil
+			return newPBFileReader(ctx, n, pb, serv, cfg), nil
 		case ftpb.Data_Metadata:
 			if len(n.Links()) == 0 {
 				return nil, errors.New("incorrectly formatted metadata object")
@@ -69,7 +142,7 @@ func NewDagReader(ctx context.Context, n ipld.Node, serv ipld.NodeGetter) (DagRe
 			if !ok {
 				return nil, mdag.ErrNotProtobuf
 			}
-			return NewDagReader(ctx, childpb, serv)
+			return newDagReader(ctx, childpb, serv, cfg)
 		case ftpb.Data_Symlink:
 			return nil, ErrCantReadSymlinks
 		default:
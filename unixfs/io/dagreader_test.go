@@ -82,7 +82,8 @@ func TestSeekAndReadLarge(t *testing.T) {
 	ctx, closer := context.WithCancel(context.Background())
 	defer closer()
 
-	reader, err := NewDagReader(ctx, node, dserv)
+	const fetchAheadBlocks = 10
+	reader, err := NewDagReader(ctx, node, dserv, WithFetchAhead(FetchAheadLimits{Blocks: fetchAheadBlocks}))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -117,8 +118,8 @@ func TestSeekAndReadLarge(t *testing.T) {
 		}
 	}
 	// -1 because we read some and it cleared one
-	if count != preloadSize-1 {
-		t.Fatalf("expected %d preloaded promises, got %d", preloadSize-1, count)
+	if count != fetchAheadBlocks-1 {
+		t.Fatalf("expected %d preloaded promises, got %d", fetchAheadBlocks-1, count)
 	}
 }
 
@@ -302,3 +303,141 @@ func readByte(t testing.TB, reader DagReader) byte {
 
 	return out[0]
 }
+
+func TestReadAt(t *testing.T) {
+	dserv := testu.GetDAGServ()
+	inbuf := make([]byte, 20000)
+	rand.Read(inbuf)
+
+	node := testu.GetNode(t, dserv, inbuf, testu.UseRawLeaves)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReader(ctx, node, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range [][2]int{{0, 100}, {10000, 10100}, {499, 1501}, {19900, 20000}, {0, 20000}} {
+		buf := make([]byte, r[1]-r[0])
+		n, err := reader.ReadAt(buf, int64(r[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(buf) || !bytes.Equal(buf, inbuf[r[0]:r[1]]) {
+			t.Fatalf("read wrong data at %d", r[0])
+		}
+	}
+
+	// reading past the end returns what is left with io.EOF
+	buf := make([]byte, 100)
+	n, err := reader.ReadAt(buf, 19950)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if n != 50 || !bytes.Equal(buf[:n], inbuf[19950:]) {
+		t.Fatal("read wrong data at the end")
+	}
+
+	// ReadAt doesn't move the reader
+	if reader.Offset() != 0 {
+		t.Fatalf("expected offset 0, got %d", reader.Offset())
+	}
+}
+
+func TestSeekPastEnd(t *testing.T) {
+	dserv := testu.GetDAGServ()
+	inbuf := make([]byte, 20000)
+	rand.Read(inbuf)
+
+	node := testu.GetNode(t, dserv, inbuf, testu.UseProtoBufLeaves)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReader(ctx, node, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reader.Seek(19990, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Seek(20000, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reader.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Fatalf("expected nothing to read, got %d bytes and %v", n, err)
+	}
+
+	// seeking back within the last block reads it again
+	if _, err := reader.Seek(19990, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 10)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, inbuf[19990:]) {
+		t.Fatal("read wrong data after seeking back")
+	}
+}
+
+func TestFetchAheadBytes(t *testing.T) {
+	dserv := testu.GetDAGServ()
+	inbuf := make([]byte, 20000)
+	rand.Read(inbuf)
+
+	node := testu.GetNode(t, dserv, inbuf, testu.UseProtoBufLeaves)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	// the data of 4 leaves
+	reader, err := NewDagReader(ctx, node, dserv, WithFetchAhead(FetchAheadLimits{Bytes: 2000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readByte(t, reader)
+
+	pbdr := reader.(*PBDagReader)
+	for i, p := range pbdr.promises {
+		if (p != nil) != (i > 0 && i < 4) {
+			t.Fatalf("unexpected request state of child %d", i)
+		}
+	}
+}
+
+func TestFetchEnd(t *testing.T) {
+	dserv := testu.GetDAGServ()
+	inbuf := make([]byte, 20000)
+	rand.Read(inbuf)
+
+	node := testu.GetNode(t, dserv, inbuf, testu.UseProtoBufLeaves)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReader(ctx, node, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbdr := reader.(*PBDagReader)
+	pbdr.SetFetchEnd(1200) // in the third leaf
+	readByte(t, reader)
+
+	for i, p := range pbdr.promises {
+		if (p != nil) != (i > 0 && i < 3) {
+			t.Fatalf("unexpected request state of child %d", i)
+		}
+	}
+
+	// the data past the end is still read
+	if _, err := reader.Seek(5000, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 100)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, inbuf[5000:5100]) {
+		t.Fatal("read past the fetch end failed")
+	}
+}
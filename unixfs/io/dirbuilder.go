@@ -2,8 +2,10 @@ package io
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	format "github.com/ipfs/go-ipfs/unixfs"
@@ -15,11 +17,25 @@ import (
 
 // ShardSplitThreshold specifies how large of an unsharded directory
 // the Directory code will generate. Adding entries over this value will
-// result in the node being restructured into a sharded object.
-var ShardSplitThreshold = 1000
+// result in the node being restructured into a sharded object, and removing
+// entries back under it into an unsharded one. Zero means no limit.
+var ShardSplitThreshold = 0
+
+// DefaultShardSizeThreshold is the default value of ShardSizeThreshold.
+const DefaultShardSizeThreshold = 256 << 10
+
+// ShardSizeThreshold is the estimated size of the node of an unsharded
+// directory, in bytes, over which it is restructured into a sharded object
+// too, whatever the number of its entries.
+var ShardSizeThreshold = DefaultShardSizeThreshold
+
+func tooLarge(entries, size int) bool {
+	return (ShardSplitThreshold > 0 && entries > ShardSplitThreshold) || size > ShardSizeThreshold
+}
 
 // UseHAMTSharding is a global flag that signifies whether or not to use the
-// HAMT sharding scheme for directory creation
+// HAMT sharding scheme for directory creation. Without it, directories are
+// only sharded past ShardSplitThreshold or ShardSizeThreshold.
 var UseHAMTSharding = false
 
 // DefaultShardWidth is the default value used for hamt sharding width.
@@ -33,6 +49,9 @@ type Directory struct {
 	dirnode *mdag.ProtoNode
 
 	shard *hamt.Shard
+
+	// attrs are the attributes of the directory, recorded in dirnode
+	attrs format.Attrs
 }
 
 // NewDirectory returns a Directory. It needs a DAGService to add the Children
@@ -72,6 +91,7 @@ func NewDirectoryFromNode(dserv ipld.DAGService, nd ipld.Node) (*Directory, erro
 		return &Directory{
 			dserv:   dserv,
 			dirnode: pbnd.Copy().(*mdag.ProtoNode),
+			attrs:   format.AttrsFromPB(pbd),
 		}, nil
 	case format.THAMTShard:
 		shard, err := hamt.NewHamtFromDag(dserv, nd)
@@ -101,8 +121,8 @@ func (d *Directory) SetPrefix(prefix *cid.Prefix) {
 // AddChild adds a (name, key)-pair to the root node.
 func (d *Directory) AddChild(ctx context.Context, name string, nd ipld.Node) error {
 	if d.shard == nil {
-		if !UseHAMTSharding {
-			_ = d.dirnode.RemoveNodeLink(name)
+		_ = d.dirnode.RemoveNodeLink(name)
+		if !d.needsSharding(name, nd.Cid()) {
 			return d.dirnode.AddNodeLinkClean(name, nd)
 		}
 
@@ -115,6 +135,28 @@ func (d *Directory) AddChild(ctx context.Context, name string, nd ipld.Node) err
 	return d.shard.Set(ctx, name, nd)
 }
 
+// linkSize estimates the size of a link in the node of an unsharded
+// directory.
+func linkSize(name string, c *cid.Cid) int {
+	// the varints of the size and field lengths, and the field tags
+	return len(name) + len(c.Bytes()) + 16
+}
+
+// needsSharding reports whether the unsharded directory gets too large with
+// a link to c named name.
+func (d *Directory) needsSharding(name string, c *cid.Cid) bool {
+	if UseHAMTSharding {
+		return true
+	}
+
+	links := d.dirnode.Links()
+	size := len(d.dirnode.Data()) + linkSize(name, c)
+	for _, l := range links {
+		size += linkSize(l.Name, l.Cid)
+	}
+	return tooLarge(len(links)+1, size)
+}
+
 func (d *Directory) switchToSharding(ctx context.Context) error {
 	s, err := hamt.NewShard(d.dserv, DefaultShardWidth)
 	if err != nil {
@@ -122,20 +164,65 @@ func (d *Directory) switchToSharding(ctx context.Context) error {
 	}
 	s.SetPrefix(&d.dirnode.Prefix)
 
-	d.shard = s
 	for _, lnk := range d.dirnode.Links() {
-		cnd, err := d.dserv.Get(ctx, lnk.Cid)
+		err = s.SetLink(ctx, lnk.Name, lnk)
 		if err != nil {
 			return err
 		}
+	}
+
+	d.shard = s
+	d.dirnode = nil
+	return nil
+}
+
+// errTooLarge stops counting the entries of a sharded directory too large
+// to be unsharded.
+var errTooLarge = errors.New("directory too large to be unsharded")
+
+// maybeSwitchToBasic turns the sharded directory back into an unsharded one
+// if it doesn't need sharding anymore, unless UseHAMTSharding is set.
+func (d *Directory) maybeSwitchToBasic(ctx context.Context) error {
+	if UseHAMTSharding {
+		return nil
+	}
+
+	var links []*ipld.Link
+	size := len(format.FolderPBData())
+	err := d.shard.ForEachLink(ctx, func(l *ipld.Link) error {
+		size += linkSize(l.Name, l.Cid)
+		if tooLarge(len(links)+1, size) {
+			return errTooLarge
+		}
+		links = append(links, &ipld.Link{Name: l.Name, Size: l.Size, Cid: l.Cid})
+		return nil
+	})
+	switch err {
+	case nil:
+	case errTooLarge:
+		return nil
+	default:
+		return err
+	}
 
-		err = d.shard.Set(ctx, lnk.Name, cnd)
+	dirnode := format.EmptyDirNode()
+	dirnode.SetPrefix(d.shard.Prefix())
+	if !d.attrs.IsZero() {
+		data, err := format.SetAttrs(dirnode.Data(), d.attrs)
+		if err != nil {
+			return err
+		}
+		dirnode.SetData(data)
+	}
+	for _, l := range links {
+		err := dirnode.AddRawLink(l.Name, l)
 		if err != nil {
 			return err
 		}
 	}
 
-	d.dirnode = nil
+	d.dirnode = dirnode
+	d.shard = nil
 	return nil
 }
 
@@ -153,6 +240,28 @@ func (d *Directory) ForEachLink(ctx context.Context, f func(*ipld.Link) error) e
 	return d.shard.ForEachLink(ctx, f)
 }
 
+// ForEachLinkAfter applies the given function to the links of the directory
+// following the one named after, or to all of them if after is empty, so an
+// interrupted listing can be resumed. The links of basic directories come in
+// the order of their names, the ones of sharded directories in the order of
+// the hashes of their names. Sharded directories are walked without keeping
+// their shards in memory.
+func (d *Directory) ForEachLinkAfter(ctx context.Context, after string, f func(*ipld.Link) error) error {
+	if d.shard == nil {
+		for _, l := range d.dirnode.Links() {
+			if after != "" && l.Name <= after {
+				continue
+			}
+			if err := f(l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return d.shard.ForEachLinkAfter(ctx, after, f)
+}
+
 // Links returns the all the links in the directory node.
 func (d *Directory) Links(ctx context.Context) ([]*ipld.Link, error) {
 	if d.shard == nil {
@@ -162,6 +271,21 @@ func (d *Directory) Links(ctx context.Context) ([]*ipld.Link, error) {
 	return d.shard.EnumLinks(ctx)
 }
 
+// SortedLinks returns all the links in the directory node, in the order of
+// their names, whether the directory is sharded or not.
+func (d *Directory) SortedLinks(ctx context.Context) ([]*ipld.Link, error) {
+	links, err := d.Links(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]*ipld.Link, len(links))
+	copy(sorted, links)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted, nil
+}
+
 // Find returns the root node of the file named 'name' within this directory.
 // In the case of HAMT-directories, it will traverse the tree.
 func (d *Directory) Find(ctx context.Context, name string) (ipld.Node, error) {
@@ -192,7 +316,12 @@ func (d *Directory) RemoveChild(ctx context.Context, name string) error {
 		return d.dirnode.RemoveNodeLink(name)
 	}
 
-	return d.shard.Remove(ctx, name)
+	err := d.shard.Remove(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return d.maybeSwitchToBasic(ctx)
 }
 
 // GetNode returns the root of this Directory
@@ -204,6 +333,26 @@ func (d *Directory) GetNode() (ipld.Node, error) {
 	return d.shard.Node()
 }
 
+// Attrs returns the filesystem attributes recorded in the directory.
+func (d *Directory) Attrs() format.Attrs {
+	return d.attrs
+}
+
+// SetAttrs records the filesystem attributes of the directory. They are
+// kept while the directory is sharded, but only recorded in the nodes of
+// unsharded directories.
+func (d *Directory) SetAttrs(a format.Attrs) error {
+	if d.shard == nil {
+		data, err := format.SetAttrs(d.dirnode.Data(), a)
+		if err != nil {
+			return err
+		}
+		d.dirnode.SetData(data)
+	}
+	d.attrs = a
+	return nil
+}
+
 // GetPrefix returns the CID Prefix used
 func (d *Directory) GetPrefix() *cid.Prefix {
 	if d.shard == nil {
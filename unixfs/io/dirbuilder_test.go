@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	mdag "github.com/ipfs/go-ipfs/merkledag"
 	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 )
@@ -156,3 +157,79 @@ func TestDirBuilder(t *testing.T) {
 		t.Fatal("wrong number of links", len(links), count)
 	}
 }
+
+func TestDirectoryAutoSharding(t *testing.T) {
+	defer func(n, s int) { ShardSplitThreshold, ShardSizeThreshold = n, s }(ShardSplitThreshold, ShardSizeThreshold)
+	ShardSplitThreshold = 10
+
+	ds := mdtest.Mock()
+	ctx := context.Background()
+	d := ft.EmptyDirNode()
+	ds.Add(ctx, d)
+
+	isShard := func(dir *Directory) bool {
+		nd, err := dir.GetNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pbd, err := ft.FromBytes(nd.(*mdag.ProtoNode).Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pbd.GetType() == ft.THAMTShard
+	}
+
+	dir := NewDirectory(ds)
+	for i := 0; i < 10; i++ {
+		if err := dir.AddChild(ctx, fmt.Sprintf("dir%d", i), d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if isShard(dir) {
+		t.Fatal("directory was sharded under the threshold")
+	}
+	basic, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dir.AddChild(ctx, "dir10", d); err != nil {
+		t.Fatal(err)
+	}
+	if !isShard(dir) {
+		t.Fatal("directory wasn't sharded over the threshold")
+	}
+	links, err := dir.Links(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 11 {
+		t.Fatalf("expected 11 links, got %d", len(links))
+	}
+
+	if err := dir.RemoveChild(ctx, "dir10"); err != nil {
+		t.Fatal(err)
+	}
+	if isShard(dir) {
+		t.Fatal("directory wasn't unsharded under the threshold")
+	}
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(basic.Cid()) {
+		t.Fatal("unsharded directory differs from the original one")
+	}
+
+	// large entries shard directories too
+	ShardSizeThreshold = 1024
+	dir = NewDirectory(ds)
+	for i := 0; i < 5; i++ {
+		if err := dir.AddChild(ctx, fmt.Sprintf("%0200d", i), d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !isShard(dir) {
+		t.Fatal("directory wasn't sharded over the size threshold")
+	}
+}
@@ -41,6 +41,12 @@ type PBDagReader struct {
 	// current offset for the read head within the 'file'
 	offset int64
 
+	// the offset past which children aren't requested ahead, if not 0
+	fetchEnd int64
+
+	// the configuration of the reader, passed on to its children
+	cfg readerConfig
+
 	// Our context
 	ctx context.Context
 
@@ -51,7 +57,11 @@ type PBDagReader struct {
 var _ DagReader = (*PBDagReader)(nil)
 
 // NewPBFileReader constructs a new PBFileReader.
-func NewPBFileReader(ctx context.Context, n *mdag.ProtoNode, pb *ftpb.Data, serv ipld.NodeGetter) *PBDagReader {
+func NewPBFileReader(ctx context.Context, n *mdag.ProtoNode, pb *ftpb.Data, serv ipld.NodeGetter, opts ...ReaderOption) *PBDagReader {
+	return newPBFileReader(ctx, n, pb, serv, newReaderConfig(opts))
+}
+
+func newPBFileReader(ctx context.Context, n *mdag.ProtoNode, pb *ftpb.Data, serv ipld.NodeGetter, cfg readerConfig) *PBDagReader {
 	fctx, cancel := context.WithCancel(ctx)
 	curLinks := getLinkCids(n)
 	return &PBDagReader{
@@ -63,20 +73,54 @@ func NewPBFileReader(ctx context.Context, n *mdag.ProtoNode, pb *ftpb.Data, serv
 		ctx:      fctx,
 		cancel:   cancel,
 		pbdata:   pb,
+		cfg:      cfg,
 	}
 }
 
-const preloadSize = 10
-
-func (dr *PBDagReader) preloadNextNodes(ctx context.Context) {
+// fetchAhead requests the children of the node in the fetch-ahead window
+// starting at the one to be read next which weren't requested yet. Requests
+// are batched: they are only made once the child to read wasn't requested,
+// or half of the window was read.
+func (dr *PBDagReader) fetchAhead(ctx context.Context) {
 	beg := dr.linkPosition
-	end := beg + preloadSize
-	if end >= len(dr.links) {
-		end = len(dr.links)
+	sizes := dr.pbdata.GetBlocksizes()
+
+	// the window always holds the child to read and the following one, so
+	// the next subtree is fetched while reading the current one
+	end := beg
+	var size uint64
+	limits := dr.cfg.fetchAhead
+	for end < len(dr.links) && end-beg < limits.blocks() {
+		if end > beg && dr.fetchEnd > 0 && end < len(sizes) {
+			if start, _ := dr.childRange(end); start >= dr.fetchEnd {
+				break
+			}
+		}
+		if end < len(sizes) {
+			size += sizes[end]
+		}
+		if end-beg >= 2 && size > uint64(limits.bytes()) {
+			break
+		}
+		end++
+	}
+
+	var missing []int
+	for i := beg; i < end; i++ {
+		if dr.promises[i] == nil {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 || (dr.promises[beg] != nil && len(missing) < (end-beg+1)/2) {
+		return
 	}
 
-	for i, p := range ipld.GetNodes(ctx, dr.serv, dr.links[beg:end]) {
-		dr.promises[beg+i] = p
+	cids := make([]*cid.Cid, len(missing))
+	for i, idx := range missing {
+		cids[i] = dr.links[idx]
+	}
+	for i, p := range ipld.GetNodes(ctx, dr.serv, cids) {
+		dr.promises[missing[i]] = p
 	}
 }
 
@@ -92,9 +136,7 @@ func (dr *PBDagReader) precalcNextBuf(ctx context.Context) error {
 		return io.EOF
 	}
 
-	if dr.promises[dr.linkPosition] == nil {
-		dr.preloadNextNodes(ctx)
-	}
+	dr.fetchAhead(ctx)
 
 	nxt, err := dr.promises[dr.linkPosition].Get(ctx)
 	if err != nil {
@@ -116,7 +158,9 @@ func (dr *PBDagReader) precalcNextBuf(ctx context.Context) error {
 			// A directory should not exist within a file
 			return ft.ErrInvalidDirLocation
 		case ftpb.Data_File:
-			dr.buf = NewPBFileReader(dr.ctx, nxt, pb, dr.serv)
+			child := newPBFileReader(dr.ctx, nxt, pb, dr.serv, dr.cfg)
+			child.fetchEnd = dr.childFetchEnd(dr.linkPosition - 1)
+			dr.buf = child
 			return nil
 		case ftpb.Data_Raw:
 			dr.buf = NewBufDagReader(pb.GetData())
@@ -130,7 +174,7 @@ func (dr *PBDagReader) precalcNextBuf(ctx context.Context) error {
 		}
 	default:
 		var err error
-		dr.buf, err = NewDagReader(ctx, nxt, dr.serv)
+		dr.buf, err = newDagReader(ctx, nxt, dr.serv, dr.cfg)
 		return err
 	}
 }
@@ -144,6 +188,30 @@ func getLinkCids(n ipld.Node) []*cid.Cid {
 	return out
 }
 
+// SetFetchEnd makes the reader stop requesting blocks ahead of the data read
+// past offset end, when only the data before end is going to be read, such
+// as for HTTP range requests. The data past end can still be read, but its
+// blocks are only requested once read. 0 removes the limit.
+func (dr *PBDagReader) SetFetchEnd(end int64) {
+	dr.fetchEnd = end
+	if child, ok := dr.buf.(*PBDagReader); ok && dr.linkPosition > 0 {
+		child.SetFetchEnd(dr.childFetchEnd(dr.linkPosition - 1))
+	}
+}
+
+// childFetchEnd returns the fetch end of the reader of the child i.
+func (dr *PBDagReader) childFetchEnd(i int) int64 {
+	if dr.fetchEnd <= 0 || i >= len(dr.pbdata.Blocksizes) {
+		return 0
+	}
+	start, _ := dr.childRange(i)
+	if dr.fetchEnd <= start {
+		// reading past the end already
+		return 0
+	}
+	return dr.fetchEnd - start
+}
+
 // Size return the total length of the data from the DAG structured file.
 func (dr *PBDagReader) Size() uint64 {
 	return dr.pbdata.GetFilesize()
@@ -221,6 +289,111 @@ func (dr *PBDagReader) WriteTo(w io.Writer) (int64, error) {
 	}
 }
 
+// ReadAt reads len(b) bytes of the file starting at off. It descends the DAG
+// from the root to the blocks holding them, without reading the blocks
+// before, and doesn't change the offset of the reader, so calls can run in
+// parallel with each other and with Read.
+func (dr *PBDagReader) ReadAt(b []byte, off int64) (int, error) {
+	return dr.CtxReadAt(dr.ctx, b, off)
+}
+
+// CtxReadAt reads len(b) bytes of the file starting at off, like ReadAt.
+func (dr *PBDagReader) CtxReadAt(ctx context.Context, b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("Invalid offset")
+	}
+	n, err := readAt(ctx, dr.serv, dr.node, dr.pbdata, b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// readAt reads the data of the file node nd, whose unixfs data is pb, from
+// off into b, until b is full or the data ends. It only fetches the children
+// of nd holding the data read.
+func readAt(ctx context.Context, serv ipld.NodeGetter, nd *mdag.ProtoNode, pb *ftpb.Data, b []byte, off int64) (int, error) {
+	n := 0
+	data := pb.GetData()
+	if off < int64(len(data)) {
+		n = copy(b, data[off:])
+	}
+
+	// find the children holding the rest of the range
+	links := nd.Links()
+	if len(links) != len(pb.Blocksizes) {
+		return n, errors.New("number of links and block sizes of file node differ")
+	}
+	start := int64(len(data))
+	first, last := -1, -1
+	var starts []int64
+	for i, size := range pb.Blocksizes {
+		end := start + int64(size)
+		if n < len(b) && end > off && start < off+int64(len(b)) {
+			if first < 0 {
+				first = i
+			}
+			last = i
+			starts = append(starts, start)
+		}
+		start = end
+	}
+	if first < 0 {
+		return n, nil
+	}
+
+	cids := make([]*cid.Cid, 0, last-first+1)
+	for _, l := range links[first : last+1] {
+		cids = append(cids, l.Cid)
+	}
+	for i, p := range ipld.GetNodes(ctx, serv, cids) {
+		child, err := p.Get(ctx)
+		if err != nil {
+			return n, err
+		}
+
+		// offset in the child of the next byte to read
+		coff := off + int64(n) - starts[i]
+		var cn int
+		switch child := child.(type) {
+		case *mdag.ProtoNode:
+			cpb := new(ftpb.Data)
+			if err := proto.Unmarshal(child.Data(), cpb); err != nil {
+				return n, fmt.Errorf("incorrectly formatted protobuf: %s", err)
+			}
+
+			switch cpb.GetType() {
+			case ftpb.Data_Directory:
+				return n, ft.ErrInvalidDirLocation
+			case ftpb.Data_File, ftpb.Data_Raw:
+				cn, err = readAt(ctx, serv, child, cpb, b[n:], coff)
+				if err != nil {
+					return n + cn, err
+				}
+			case ftpb.Data_Metadata:
+				return n, errors.New("shouldnt have had metadata object inside file")
+			case ftpb.Data_Symlink:
+				return n, errors.New("shouldnt have had symlink inside file")
+			default:
+				return n, ft.ErrUnrecognizedType
+			}
+		case *mdag.RawNode:
+			if raw := child.RawData(); coff < int64(len(raw)) {
+				cn = copy(b[n:], raw[coff:])
+			}
+		default:
+			return n, fmt.Errorf("unrecognized node type")
+		}
+
+		n += cn
+		// a child shorter than its block size leaves a gap we can't fill
+		if n < len(b) && i < len(cids)-1 && off+int64(n) < starts[i+1] {
+			return n, errors.New("file node child is smaller than its block size")
+		}
+	}
+	return n, nil
+}
+
 // Close closes the reader.
 func (dr *PBDagReader) Close() error {
 	dr.cancel()
@@ -232,10 +405,21 @@ func (dr *PBDagReader) Offset() int64 {
 	return dr.offset
 }
 
+// childRange returns the offsets in the file of the first byte of the child
+// i and of the byte following it.
+func (dr *PBDagReader) childRange(i int) (start, end int64) {
+	pb := dr.pbdata
+	start = int64(len(pb.Data))
+	for _, size := range pb.Blocksizes[:i] {
+		start += int64(size)
+	}
+	return start, start + int64(pb.Blocksizes[i])
+}
+
 // Seek implements io.Seeker, and will seek to a given offset in the file
-// interface matches standard unix seek
-// TODO: check if we can do relative seeks, to reduce the amount of dagreader
-// recreations that need to happen.
+// interface matches standard unix seek. It only loads the blocks on the path
+// from the root to the offset, and seeks within the child being read when
+// the offset falls in it, without reloading it.
 func (dr *PBDagReader) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case io.SeekStart:
@@ -264,19 +448,44 @@ func (dr *PBDagReader) Seek(offset int64, whence int) (int64, error) {
 			return offset, nil
 		}
 
+		// seek within the child being read if the offset falls in it
+		if dr.buf != nil && dr.linkPosition > 0 && dr.linkPosition <= len(pb.Blocksizes) {
+			start, end := dr.childRange(dr.linkPosition - 1)
+			if offset >= start && offset < end {
+				if _, err := dr.buf.Seek(offset-start, io.SeekStart); err != nil {
+					return -1, err
+				}
+				dr.offset = offset
+				return offset, nil
+			}
+		}
+
 		// skip past root block data
 		left -= int64(len(pb.Data))
 
 		// iterate through links and find where we need to be
+		found := false
 		for i := 0; i < len(pb.Blocksizes); i++ {
 			if pb.Blocksizes[i] > uint64(left) {
 				dr.linkPosition = i
+				found = true
 				break
 			} else {
 				left -= int64(pb.Blocksizes[i])
 			}
 		}
 
+		// the offset is past the last child: there is nothing left to read
+		if !found {
+			if dr.buf != nil {
+				dr.buf.Close()
+				dr.buf = nil
+			}
+			dr.linkPosition = len(dr.links)
+			dr.offset = offset
+			return offset, nil
+		}
+
 		// start sub-block request
 		err := dr.precalcNextBuf(dr.ctx)
 		if err != nil {
@@ -0,0 +1,110 @@
+// Package mimeindex records the content types detected for unixfs files,
+// with their size, so they are only detected once: when the files are added
+// or first served.
+package mimeindex
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsns "github.com/ipfs/go-datastore/namespace"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("mimeindex")
+
+// SniffLen is the number of leading bytes of files the content type is
+// detected from.
+const SniffLen = 512
+
+// Entry is what is recorded for a file.
+type Entry struct {
+	Type string
+	Size uint64
+}
+
+// Index records the entries of files by the CID of their root, in a
+// datastore.
+type Index struct {
+	dstore ds.Datastore
+}
+
+// New returns the index stored in dstore under prefix.
+func New(dstore ds.Datastore, prefix ds.Key) *Index {
+	return &Index{dstore: dsns.Wrap(dstore, prefix)}
+}
+
+// Get returns the entry recorded for the file with root c, if any.
+func (ix *Index) Get(c *cid.Cid) (Entry, bool) {
+	var e Entry
+	data, err := ix.dstore.Get(dsKey(c))
+	if err != nil {
+		if err != ds.ErrNotFound {
+			log.Warningf("reading the content type of %s: %s", c, err)
+		}
+		return e, false
+	}
+	b, ok := data.([]byte)
+	if !ok || json.Unmarshal(b, &e) != nil {
+		return e, false
+	}
+	return e, true
+}
+
+// Put records e for the file with root c.
+func (ix *Index) Put(c *cid.Cid, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return ix.dstore.Put(dsKey(c), b)
+}
+
+// Detect returns the content type of a file starting with head, of which
+// only the first SniffLen bytes are considered.
+func Detect(head []byte) string {
+	return http.DetectContentType(head)
+}
+
+// Sniff reads the first SniffLen bytes of r, or all of them if shorter,
+// and returns their content type.
+func Sniff(r io.Reader) (string, error) {
+	head := make([]byte, SniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return Detect(head[:n]), nil
+}
+
+func dsKey(c *cid.Cid) ds.Key {
+	return ds.NewKey(c.String())
+}
+
+// Sniffer passes the data read from a reader on, keeping its first SniffLen
+// bytes, so the content type of a file can be detected while it is added.
+type Sniffer struct {
+	R    io.Reader
+	head []byte
+	size uint64
+}
+
+func (s *Sniffer) Read(b []byte) (int, error) {
+	n, err := s.R.Read(b)
+	if rest := SniffLen - len(s.head); rest > 0 {
+		if rest > n {
+			rest = n
+		}
+		s.head = append(s.head, b[:rest]...)
+	}
+	s.size += uint64(n)
+	return n, err
+}
+
+// Entry returns the entry of the data read so far.
+func (s *Sniffer) Entry() Entry {
+	return Entry{Type: Detect(s.head), Size: s.size}
+}
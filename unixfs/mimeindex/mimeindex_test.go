@@ -0,0 +1,54 @@
+package mimeindex
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	u "github.com/ipfs/go-ipfs-util"
+)
+
+func TestPutGet(t *testing.T) {
+	ix := New(ds.NewMapDatastore(), ds.NewKey("/local/mimetypes"))
+	c := cid.NewCidV0(u.Hash([]byte("file")))
+
+	if _, ok := ix.Get(c); ok {
+		t.Fatal("expected no entry")
+	}
+	e := Entry{Type: "image/png", Size: 1234}
+	if err := ix.Put(c, e); err != nil {
+		t.Fatal(err)
+	}
+	out, ok := ix.Get(c)
+	if !ok || out != e {
+		t.Fatalf("expected %v, got %v", e, out)
+	}
+}
+
+func TestSniffer(t *testing.T) {
+	data := "<html><body>" + strings.Repeat("x", 2*SniffLen) + "</body></html>"
+	s := &Sniffer{R: strings.NewReader(data)}
+	out, err := ioutil.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != data {
+		t.Fatal("data changed while read")
+	}
+
+	e := s.Entry()
+	if e.Type != "text/html; charset=utf-8" || e.Size != uint64(len(data)) {
+		t.Fatalf("unexpected entry %v", e)
+	}
+
+	ctype, err := Sniff(bytes.NewReader([]byte{0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctype != "application/octet-stream" {
+		t.Fatalf("expected application/octet-stream, got %s", ctype)
+	}
+}
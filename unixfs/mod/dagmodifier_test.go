@@ -1,6 +1,7 @@
 package mod
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -9,11 +10,14 @@ import (
 
 	h "github.com/ipfs/go-ipfs/importer/helpers"
 	trickle "github.com/ipfs/go-ipfs/importer/trickle"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
 
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	testu "github.com/ipfs/go-ipfs/unixfs/test"
 
+	cid "github.com/ipfs/go-cid"
 	u "github.com/ipfs/go-ipfs-util"
+	ipld "github.com/ipfs/go-ipld-format"
 )
 
 func testModWrite(t *testing.T, beg, size uint64, orig []byte, dm *DagModifier, opts testu.NodeOpts) []byte {
@@ -750,3 +754,81 @@ func BenchmarkDagmodWrite(b *testing.B) {
 		}
 	}
 }
+
+func TestPatch(t *testing.T) {
+	runAllSubtests(t, testPatch)
+}
+
+func testPatch(t *testing.T, opts testu.NodeOpts) {
+	dserv := testu.GetDAGServ()
+	orig, n := testu.GetRandomNode(t, dserv, 50000, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	readAll := func(nd ipld.Node) []byte {
+		rd, err := uio.NewDagReader(ctx, nd, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// overwrite a range in the middle
+	patch := make([]byte, 100)
+	u.NewTimeSeededRand().Read(patch)
+	nd, err := Patch(ctx, n, dserv, 10000, bytes.NewReader(patch), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append([]byte(nil), orig...)
+	copy(expected[10000:], patch)
+	if err := testu.ArrComp(readAll(nd), expected); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the leaves written and their parents differ
+	old := cid.NewSet()
+	err = mdag.EnumerateChildren(ctx, mdag.GetLinksWithDAG(dserv), n.Cid(), old.Visit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed int
+	err = mdag.EnumerateChildren(ctx, mdag.GetLinksWithDAG(dserv), nd.Cid(), func(c *cid.Cid) bool {
+		if !old.Has(c) {
+			changed++
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed > 4 {
+		t.Fatalf("expected the patched file to share most blocks, %d differ", changed)
+	}
+
+	// append past the end, filling the gap with zeros
+	nd, err = Patch(ctx, nd, dserv, 51000, bytes.NewReader(patch), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, make([]byte, 1000)...)
+	expected = append(expected, patch...)
+	if err := testu.ArrComp(readAll(nd), expected); err != nil {
+		t.Fatal(err)
+	}
+
+	// truncate after the data written
+	nd, err = Patch(ctx, nd, dserv, 20000, bytes.NewReader(patch), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(expected[20000:], patch)
+	expected = expected[:20100]
+	if err := testu.ArrComp(readAll(nd), expected); err != nil {
+		t.Fatal(err)
+	}
+}
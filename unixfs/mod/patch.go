@@ -0,0 +1,44 @@
+package mod
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Patch writes the data of r over the file nd from offset, and returns the
+// root of the modified file. The file grows if the data goes past its end,
+// and offsets past its end are filled with zeros. If truncate is set, the
+// file is truncated at the end of the data written.
+//
+// Only the blocks holding the range written, and their parents, are
+// rewritten: the new file shares all other blocks with nd, so patching or
+// appending to a large file costs about as much as the data written.
+func Patch(ctx context.Context, nd ipld.Node, ds ipld.DAGService, offset int64, r io.Reader, truncate bool) (ipld.Node, error) {
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	dm, err := NewDagModifier(ctx, nd, ds, chunker.DefaultSplitter)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dm.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(dm, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if truncate {
+		if err := dm.Truncate(offset + n); err != nil {
+			return nil, err
+		}
+	}
+	return dm.GetNode()
+}
@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Data
+	XAttr
 	Metadata
 */
 package unixfs_pb
@@ -75,6 +76,11 @@ type Data struct {
 	Blocksizes       []uint64       `protobuf:"varint,4,rep,name=blocksizes" json:"blocksizes,omitempty"`
 	HashType         *uint64        `protobuf:"varint,5,opt,name=hashType" json:"hashType,omitempty"`
 	Fanout           *uint64        `protobuf:"varint,6,opt,name=fanout" json:"fanout,omitempty"`
+	Layout           *string        `protobuf:"bytes,7,opt,name=layout" json:"layout,omitempty"`
+	Mode             *uint32        `protobuf:"varint,8,opt,name=mode" json:"mode,omitempty"`
+	Mtime            *int64         `protobuf:"varint,9,opt,name=mtime" json:"mtime,omitempty"`
+	MtimeNsecs       *uint32        `protobuf:"varint,10,opt,name=mtimeNsecs" json:"mtimeNsecs,omitempty"`
+	Xattrs           []*XAttr       `protobuf:"bytes,11,rep,name=xattrs" json:"xattrs,omitempty"`
 	XXX_unrecognized []byte         `json:"-"`
 }
 
@@ -124,6 +130,65 @@ func (m *Data) GetFanout() uint64 {
 	return 0
 }
 
+func (m *Data) GetLayout() string {
+	if m != nil && m.Layout != nil {
+		return *m.Layout
+	}
+	return ""
+}
+
+func (m *Data) GetMode() uint32 {
+	if m != nil && m.Mode != nil {
+		return *m.Mode
+	}
+	return 0
+}
+
+func (m *Data) GetMtime() int64 {
+	if m != nil && m.Mtime != nil {
+		return *m.Mtime
+	}
+	return 0
+}
+
+func (m *Data) GetMtimeNsecs() uint32 {
+	if m != nil && m.MtimeNsecs != nil {
+		return *m.MtimeNsecs
+	}
+	return 0
+}
+
+func (m *Data) GetXattrs() []*XAttr {
+	if m != nil {
+		return m.Xattrs
+	}
+	return nil
+}
+
+type XAttr struct {
+	Name             *string `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
+	Value            []byte  `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *XAttr) Reset()         { *m = XAttr{} }
+func (m *XAttr) String() string { return proto.CompactTextString(m) }
+func (*XAttr) ProtoMessage()    {}
+
+func (m *XAttr) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *XAttr) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
 type Metadata struct {
 	MimeType         *string `protobuf:"bytes,1,opt,name=MimeType" json:"MimeType,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
@@ -142,6 +207,7 @@ func (m *Metadata) GetMimeType() string {
 
 func init() {
 	proto.RegisterType((*Data)(nil), "unixfs.pb.Data")
+	proto.RegisterType((*XAttr)(nil), "unixfs.pb.XAttr")
 	proto.RegisterType((*Metadata)(nil), "unixfs.pb.Metadata")
 	proto.RegisterEnum("unixfs.pb.Data_DataType", Data_DataType_name, Data_DataType_value)
 }
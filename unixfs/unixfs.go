@@ -151,6 +151,13 @@ type FSNode struct {
 
 	// node type of this node
 	Type pb.Data_DataType
+
+	// Layout is the name of the importer layout the DAG of a file was
+	// built with, if recorded.
+	Layout string
+
+	// Attrs are the filesystem attributes of the file, if recorded.
+	Attrs Attrs
 }
 
 // FSNodeFromBytes unmarshal a protobuf message onto an FSNode.
@@ -166,6 +173,8 @@ func FSNodeFromBytes(b []byte) (*FSNode, error) {
 	n.blocksizes = pbn.Blocksizes
 	n.subtotal = pbn.GetFilesize() - uint64(len(n.Data))
 	n.Type = pbn.GetType()
+	n.Layout = pbn.GetLayout()
+	n.Attrs = AttrsFromPB(pbn)
 	return n, nil
 }
 
@@ -188,6 +197,10 @@ func (n *FSNode) GetBytes() ([]byte, error) {
 	pbn.Filesize = proto.Uint64(uint64(len(n.Data)) + n.subtotal)
 	pbn.Blocksizes = n.blocksizes
 	pbn.Data = n.Data
+	if n.Layout != "" {
+		pbn.Layout = proto.String(n.Layout)
+	}
+	setAttrsPB(pbn, n.Attrs)
 	return proto.Marshal(pbn)
 }
 
@@ -2,7 +2,9 @@ package unixfs
 
 import (
 	"bytes"
+	"os"
 	"testing"
+	"time"
 
 	proto "github.com/gogo/protobuf/proto"
 
@@ -159,3 +161,54 @@ func TestMetadata(t *testing.T) {
 	}
 
 }
+
+func TestAttrs(t *testing.T) {
+	a := Attrs{
+		Mode:    0755 | os.ModeSetgid,
+		ModTime: time.Unix(1500000000, 123),
+		XAttrs: map[string][]byte{
+			"user.b": []byte("2"),
+			"user.a": []byte("1"),
+		},
+	}
+
+	data, err := SetAttrs(FolderPBData(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := AttrsFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Mode != a.Mode {
+		t.Fatalf("expected mode %s, got %s", a.Mode, out.Mode)
+	}
+	if out.UnixMode() != 02755 {
+		t.Fatalf("expected unix mode 02755, got %o", out.UnixMode())
+	}
+	if !out.ModTime.Equal(a.ModTime) {
+		t.Fatalf("expected mtime %s, got %s", a.ModTime, out.ModTime)
+	}
+	if len(out.XAttrs) != 2 || string(out.XAttrs["user.a"]) != "1" || string(out.XAttrs["user.b"]) != "2" {
+		t.Fatalf("wrong xattrs: %v", out.XAttrs)
+	}
+
+	// xattrs are encoded in order, whatever the order of the map
+	pbd, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pbd.Xattrs[0].GetName() != "user.a" || pbd.Xattrs[1].GetName() != "user.b" {
+		t.Fatal("xattrs aren't sorted by name")
+	}
+
+	// clearing the attributes gives back the data without them
+	data, err = SetAttrs(data, Attrs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, FolderPBData()) {
+		t.Fatal("data without attributes differs from the original")
+	}
+}
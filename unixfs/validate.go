@@ -0,0 +1,47 @@
+package unixfs
+
+import (
+	"fmt"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Validate is a merkledag.Validator for dag-pb nodes which must be unixfs
+// nodes: their data must decode, directories must have named and unique
+// entries, and files as many block sizes as links.
+func Validate(nd ipld.Node) error {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return dag.ErrNotProtobuf
+	}
+	pbd, err := FromBytes(pn.Data())
+	if err != nil {
+		return err
+	}
+
+	switch pbd.GetType() {
+	case TDirectory:
+		names := make(map[string]struct{}, len(pn.Links()))
+		for _, l := range pn.Links() {
+			if l.Name == "" {
+				return fmt.Errorf("directory has an unnamed entry %s", l.Cid)
+			}
+			if _, ok := names[l.Name]; ok {
+				return fmt.Errorf("directory has several entries named %q", l.Name)
+			}
+			names[l.Name] = struct{}{}
+		}
+	case TFile, TRaw:
+		if n := len(pbd.GetBlocksizes()); n != len(pn.Links()) {
+			return fmt.Errorf("file has %d links but %d block sizes", len(pn.Links()), n)
+		}
+	case TSymlink, TMetadata, THAMTShard:
+	default:
+		return ErrUnrecognizedType
+	}
+	return nil
+}
+
+var _ dag.Validator = Validate
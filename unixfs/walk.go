@@ -0,0 +1,48 @@
+package unixfs
+
+import (
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pb "github.com/ipfs/go-ipfs/unixfs/pb"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// nodeType returns the unixfs type of nd, or false if it isn't a unixfs
+// node.
+func nodeType(nd ipld.Node) (pb.Data_DataType, bool) {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return 0, false
+	}
+	pbd, err := FromBytes(pn.Data())
+	if err != nil {
+		return 0, false
+	}
+	return pbd.GetType(), true
+}
+
+type skipFileData struct {
+	dag.Selector
+}
+
+func (s skipFileData) Follow(nd ipld.Node, depth int, links []*ipld.Link) []*ipld.Link {
+	if t, ok := nodeType(nd); ok && (t == TFile || t == TRaw) {
+		return nil
+	}
+	return s.Selector.Follow(nd, depth, links)
+}
+
+// SkipFileData limits s to the directories and the roots of the files of a
+// unixfs DAG, without walking into the blocks holding the file contents.
+func SkipFileData(s dag.Selector) dag.Selector {
+	return skipFileData{s}
+}
+
+// DirectoriesOnly limits the nodes s passes to the VisitFunc to directories,
+// including sharded ones.
+func DirectoriesOnly(s dag.Selector) dag.Selector {
+	return dag.Filter(s, func(nd ipld.Node) bool {
+		t, ok := nodeType(nd)
+		return ok && (t == TDirectory || t == THAMTShard)
+	})
+}